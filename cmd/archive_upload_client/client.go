@@ -22,11 +22,14 @@ const (
 )
 
 var (
-	server  = flag.String("server", "localhost:9876", "The host:port of the gRPC server.")
-	file    = flag.String("file", "", "A local File to transfer to cloud storage.")
-	saKey   = flag.String("sa_key", "", "Service account private key.")
-	project = flag.String("project", "", "Determines which project this file belongs to.")
-	useTLS  = flag.Bool("use_tls", true, "Enable TLS if true.")
+	server     = flag.String("server", "localhost:9876", "The host:port of the gRPC server.")
+	file       = flag.String("file", "", "A local File to transfer to cloud storage.")
+	saKey      = flag.String("sa_key", "", "Service account private key.")
+	project    = flag.String("project", "", "Determines which project this file belongs to.")
+	useTLS     = flag.Bool("use_tls", true, "Enable TLS if true.")
+	convertNow = flag.Bool("convert_now", false, "Have the server convert the archive for BigQuery before it responds.")
+	transcode  = flag.Bool("transcode", false, "Store a gzip compressed text file for HTTP clients to get it decompressed.")
+	generation = flag.Int64("if_generation_match", 0, "Replace the object of the file only if it is at this generation, needs an admin of the project.")
 )
 
 func newConn(ctx context.Context, host string, saPath string) (*grpc.ClientConn, error) {
@@ -51,10 +54,15 @@ func makeReq(path string, proj pb.FileRequest_Project) (*pb.FileRequest, error)
 		return nil, err
 	}
 	return &pb.FileRequest{
-		Filename: path,
-		Content:  raw,
-		Md5Sum:   fmt.Sprintf("%x", md5.Sum(raw)),
-		Project:  proj,
+		Filename:   path,
+		Content:    raw,
+		Md5Sum:     fmt.Sprintf("%x", md5.Sum(raw)),
+		Project:    proj,
+		ConvertNow: *convertNow,
+		Transcode:  *transcode,
+		// Replacing a generation is an overwrite of it.
+		AllowOverwrite:    *generation != 0,
+		IfGenerationMatch: *generation,
 	}, nil
 }
 
@@ -81,5 +89,9 @@ func main() {
 		log.Fatalf("failed to upload file(%v): %v", *file, err)
 	}
 
-	fmt.Printf("Successfully uploaded file(%v) status: %v\n", *file, resp.GetStatus())
+	fmt.Printf("Successfully uploaded file(%v) status: %v generation: %d\n", *file, resp.GetStatus(), resp.GetGeneration())
+	fmt.Printf("Object: %s size: %d md5sum: %s crc32c: %s\n", resp.GetObject(), resp.GetSize(), resp.GetMd5Sum(), resp.GetCrc32C())
+	if c := resp.GetConversion(); c != nil {
+		fmt.Printf("Conversion: %v %s%s\n", c.GetStatus(), c.GetObject(), c.GetErrorMessage())
+	}
 }
@@ -5,18 +5,28 @@
 // Basic flow is:
 //   1) start at the top of an FTP site.
 //   2) download each file in turn, walking the remote directory tree.
-//   3) calculate the md5() checksum for each file downloaded.
-//   4) validate that the checksum matches the cloud-storage object's MD5 value.
+//   3) calculate the checksum (md5 by default) for each file downloaded.
+//   4) validate that the checksum matches the cloud-storage object's checksum.
 //   5) if there is a mis-match, upload the ftp content to cloud-storage.
 package main
 
 import (
+	"bytes"
 	"context"
-	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"hash"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,48 +34,180 @@ import (
 	"cloud.google.com/go/storage"
 	"github.com/golang/glog"
 	"github.com/jlaffaye/ftp"
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	"github.com/routeviews/google-cloud-storage/pkg/audit"
 	"github.com/routeviews/google-cloud-storage/pkg/auth"
+	"github.com/routeviews/google-cloud-storage/pkg/breaker"
+	"github.com/routeviews/google-cloud-storage/pkg/budget"
+	"github.com/routeviews/google-cloud-storage/pkg/dialer"
+	"github.com/routeviews/google-cloud-storage/pkg/inflight"
+	"github.com/routeviews/google-cloud-storage/pkg/lease"
+	"github.com/routeviews/google-cloud-storage/pkg/listing"
+	"github.com/routeviews/google-cloud-storage/pkg/notify"
+	pathrewrite "github.com/routeviews/google-cloud-storage/pkg/path_rewrite"
+	"github.com/routeviews/google-cloud-storage/pkg/progress"
+	resourceusage "github.com/routeviews/google-cloud-storage/pkg/resource_usage"
+	retryqueue "github.com/routeviews/google-cloud-storage/pkg/retry_queue"
+	"github.com/routeviews/google-cloud-storage/pkg/shard"
+	"github.com/routeviews/google-cloud-storage/pkg/tracing"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
 	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
 )
 
 const (
 	dialTimeout = 5 * time.Second
 	// Max message size set to 50mb.
 	maxMsgSize = 512 * 1024 * 1024
-	// Max ftp errors before exiting the process.
-	maxFTPErrs = 50
-	// Max grpc errors before processing stops. (NOTE: this is evaluated per thread)
-	maxGrpcErrs = 50
 	// Channel buffer size for the Walk() function to fill.
 	maxWalk = 5000
+
+	// Kinds of change of an archived file at the ftp site.
+	// truncated: the ftp content is shorter than the archived file, likely a
+	//            partial or corrupt copy upstream.
+	// regenerated: the ftp content has a different checksum, but is not shorter.
+	truncated   = "truncated"
+	regenerated = "regenerated"
+)
+
+// Exit codes of the process, for wrappers and schedulers. Invalid flags exit 2,
+// from the flag package.
+const (
+	// exitOK: the sync completed, possibly with nothing to do.
+	exitOK = 0
+	// exitFatal: an infrastructure failure, the sync could not start or was
+	// aborted. glog.Exit exits with it.
+	exitFatal = 1
+	// exitFileErrors: the sync completed, but some files failed.
+	exitFileErrors = 3
 )
 
 var (
 	// Google Cloud Storage bucket name to put content into.
 	bucket = flag.String("bucket", "", "Bucket to mirror content into.")
 	// Remote ftp archive URL to use as a starting point to read content from.
-	archive = flag.String("archive", "", "Site URL to mirror content from: ftp://site/dir.")
+	archive = flag.String("archive", "", "Site URL to mirror content from: ftp://site/dir, or file:///path of a local copy of a site.")
 	aUser   = flag.String("archive_user", "ftp", "Site userid to use with FTP.")
 	aPasswd = flag.String("archive_pass", "mirror@", "Site password to use with this FTP.")
 
+	// Connection settings of nonstandard ftp sites, see connectFtp.
+	ftpPort    = flag.Int("ftp_port", 21, "Port of the ftp site, unless the archive URL has one.")
+	ftpPASV    = flag.Bool("ftp_pasv", false, "Use PASV rather than EPSV for the data connections.")
+	ftpTimeout = flag.Duration("ftp_timeout", 5*time.Second, "Timeout of the connections to the ftp site.")
+
 	// gRPC endpoint (https url) to upload replacement content to and credentials file, if necessary.
 	grpcService   = flag.String("uploadURL", "rv-server-cgfq4yjmfa-uc.a.run.app:443", "Upload service host:port.")
 	svcAccountKey = flag.String("saKey", "", "File location of service account key, if required.")
 	threads       = flag.Int("threads", 10, "Number of ftp/cloud processing threads.")
 
 	useTLS = flag.Bool("use_tls", true, "Enable TLS if true.")
+	// Egress proxy of the ftp and upload service connections, see dialer.
+	proxyURL = flag.String("proxy", "", "Proxy for the ftp and upload service connections: socks5://host:port or http://host:port (CONNECT).")
+	// MRT archives are compressed already, gzip helps plain-text files and metadata.
+	compress = flag.Bool("compress", false, "Enable gzip compression of the uploads to the upload service.")
+
+	// Transfers which fail part way are resumed from the received offset, see contentFromFTP.
+	ftpRetries = flag.Int("ftp_retries", 3, "Times to resume a failed ftp transfer from the received offset.")
+
+	// Project whose archive layout is mirrored, see archiveprofile.
+	project = flag.String("project", "routeviews", "Archive profile of the ftp site: routeviews, routeviews-rib, ris, pch or one of -profiles.")
+	// YAML file of additional archive profiles, see archiveprofile.Load.
+	profiles = flag.String("profiles", "", "YAML file of additional archive profiles.")
+
+	// Checksum algorithm used to compare ftp content to cloud-storage.
+	checksum = flag.String("checksum", uploadutils.MD5, "Checksum to verify content with: md5, crc32c or sha256.")
+
+	// Files which never change once published are compared by size, see readChannel.
+	immutable     = flag.String("immutable", `(^|/)updates\.[^/]*$`, "Regexp of the ftp paths of files which never change, they are skipped without a download if the sizes match.")
+	verifyPercent = flag.Float64("verify_percent", 1, "Percentage of the immutable files matching by size which are downloaded and verified by checksum anyway.")
+
+	// Handling of archived files whose ftp content changed, see preserve().
+	historyPrefix      = flag.String("history_prefix", "_history/", "Prefix to preserve the replaced generation of changed files under.")
+	overwriteTruncated = flag.Bool("overwrite_truncated", false, "Upload ftp content which is shorter than the archived file.")
+
+	// Read-back of the uploaded objects, to catch content stored other than sent.
+	verifyAfterUpload = flag.Bool("verify_after_upload", false, "Re-read the attributes of each uploaded object and confirm its md5 matches the content sent.")
+
+	// Partition of the archives across parallel instances, by collector and month, see shard.
+	shardN      = flag.Int("shard", 0, "Shard of the archives this instance syncs, from 0 to total_shards-1.")
+	totalShards = flag.Int("total_shards", 1, "Number of instances syncing the site in parallel.")
+
+	// Lease which keeps overlapping runs of a collector from double-uploading, see lease.
+	leaseName = flag.String("lease", "", "Name of the lease to hold for the run, e.g. the collector; empty runs without a lease.")
+	leaseTTL  = flag.Duration("lease_ttl", 10*time.Minute, "Time a lease is held without a renewal, it is renewed every third of it.")
+
+	// Queue of the files which failed to process, consumed first by the next run, see retryqueue.
+	retryQueue = flag.String("retry_queue", "", "File, or gs://bucket/object, to persist the failed files to and retry them from; empty disables it.")
+
+	// Prefix of the per-run audit logs of the changes to the bucket, see audit.
+	auditPrefix = flag.String("audit_prefix", "_audit/", "Prefix to write the audit log of each run under; empty disables it.")
+
+	// Rewrite rules mapping ftp paths to cloud-storage object names, see init().
+	rewrites pathrewrite.Rules
+
+	// Circuit breaker of the ftp, cloud-storage and upload service operations, see breaker.
+	breakerWindow    = flag.Duration("breaker_window", 5*time.Minute, "Window the error rate of remote operations is measured over.")
+	breakerThreshold = flag.Float64("breaker_threshold", 0.5, "Error rate (0 to 1) over the window which pauses processing.")
+	breakerPause     = flag.Duration("breaker_pause", time.Minute, "Time to pause processing for once the error threshold is crossed.")
+	breakerTrips     = flag.Int("breaker_trips", 5, "Consecutive pauses without a success before the sync is aborted.")
+
+	// Budget of a run, a run which exhausts it stops and checkpoints, see budget.
+	maxFiles       = flag.Int64("max_files", 0, "Max files to process in this run; 0 is unlimited.")
+	maxBytes       = flag.Int64("max_bytes", 0, "Max bytes to transfer from the ftp site in this run; 0 is unlimited.")
+	maxDuration    = flag.Duration("max_duration", 0, "Max wall time of this run, e.g. 6h; 0 is unlimited.")
+	checkpointFile = flag.String("checkpoint", "", "File to save the checkpoint of a run stopped by its budget to, and resume from.")
+
+	// Bytes of file content buffered in memory at once, across the threads.
+	maxInflightBytes = flag.Int64("max_inflight_bytes", 0, "Max bytes of file content buffered in memory at once; 0 is unlimited.")
+
+	// Interval to log the progress of the sync at, 0 disables the progress log.
+	progressInterval = flag.Duration("progress_interval", time.Minute, "Interval to log the progress and ETA at; 0 disables it.")
+
+	// HTTP server of the health and status of the sync, for orchestrators to restart a hung job.
+	httpAddr     = flag.String("http_addr", "", "Address to serve /healthz and /statusz on, e.g. :8080; empty disables it.")
+	stallTimeout = flag.Duration("stall_timeout", 15*time.Minute, "Time without activity after which /healthz reports the sync as hung.")
+
+	// Tracing of the walk, checksum and upload pipeline, see tracing.
+	traceProject = flag.String("trace_project", "", "GCP project to export traces to Cloud Trace in; empty disables tracing.")
+	traceRatio   = flag.Float64("trace_ratio", 0.1, "Fraction (0 to 1) of the files to trace.")
+
+	// Webhook to post the summary of the run to, e.g. a Slack incoming webhook.
+	notifyURL = flag.String("notify_url", "", "URL to POST a JSON summary of the run to when it ends or aborts; empty disables it.")
+
+	// Interval to log the resource usage of the process at, 0 disables the periodic report.
+	usageInterval = flag.Duration("usage_interval", 0, "Interval to log resource usage at, e.g. 10m; 0 reports only at completion.")
 )
 
+// tracer traces the processing of each file.
+var tracer = otel.Tracer("github.com/routeviews/google-cloud-storage/cmd/archive_upload_mass_upload")
+
+func init() {
+	flag.Var(&rewrites, "rewrite", "Rewrite rule PATTERN=>REPLACEMENT mapping ftp paths to object names, may be repeated.")
+}
+
 type client struct {
-	site    string
-	user    string
-	passwd  string
+	// src is the site the archives are synced from.
+	src     ArchiveSource
 	gClient pb.RVClient
-	bs      *storage.Client
-	bh      *storage.BucketHandle
-	fc      *ftp.ServerConn
-	bucket  string
+	// callOpts are the options of each upload call.
+	callOpts []grpc.CallOption
+	bs       *storage.Client
+	bh       *storage.BucketHandle
+	bucket   string
+	// checksum is the algorithm used to compare content, see uploadutils.
+	checksum string
+	// profile is the archive layout of the ftp site.
+	profile *archiveprofile.Profile
+	// shard is the partition of the archives this instance syncs.
+	shard *shard.Shard
+	// rewrites map the ftp paths to cloud-storage object names.
+	rewrites pathrewrite.Rules
 	// A buffered channel which will contain files to possibly download.
 	ch chan *evalFile
 	// A WaitGroup used to synchronize ending the reading jobs/processing.
@@ -74,6 +216,31 @@ type client struct {
 	mu sync.Mutex
 	// Metrics, collect copied vs not for exit reporting.
 	metrics map[string]int
+	// leaseErr is the reason the lease of the run was lost, protected by mu.
+	leaseErr error
+	// mismatches are the uploaded objects which failed verification, protected by mu.
+	mismatches []string
+	// listing caches the cloud-storage attributes of the objects, by directory.
+	listing *listing.Cache
+	// immutable matches the ftp paths of files which never change.
+	immutable *regexp.Regexp
+	// retry is the queue of the files which failed to process.
+	retry *retryqueue.Queue
+	// audit records the uploads and preserved generations of the run.
+	audit *audit.Log
+	// progress tracks the files discovered and processed, for the ETA.
+	progress *progress.Tracker
+	// breaker pauses processing on sustained remote errors, and aborts the sync.
+	breaker *breaker.Breaker
+	// budget limits the run, the files left once it is exhausted are
+	// collected in the checkpoint.
+	budget     *budget.Budget
+	checkpoint *budget.Checkpoint
+	// inflight bounds the content buffered by the threads at once.
+	inflight *inflight.Limiter
+	// resume is the checkpoint of an earlier run, files sorting before it
+	// were processed already.
+	resume string
 }
 
 type evalFile struct {
@@ -82,34 +249,68 @@ type evalFile struct {
 	name string
 	// chksum is an md5 checksum
 	chksum string
+	// size is the size of the file at the ftp site, -1 if unknown.
+	size int64
+}
+
+// parseArchive splits an archive URL, ftp://site[:port]/dir/, into the
+// site host:port and the directory to walk. port is used if the URL has none.
+func parseArchive(archive string, port int) (string, string, error) {
+	if !strings.Contains(archive, "://") {
+		archive = "ftp://" + archive
+	}
+	u, err := url.Parse(archive)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid archive URL(%s): %v", archive, err)
+	}
+	if u.Scheme != "ftp" || u.Hostname() == "" {
+		return "", "", fmt.Errorf("invalid archive URL(%s), want ftp://site/dir", archive)
+	}
+	p := u.Port()
+	if p == "" {
+		p = strconv.Itoa(port)
+	}
+	return net.JoinHostPort(u.Hostname(), p), "/" + strings.Trim(u.Path, "/"), nil
 }
 
-func connectFtp(site string) (*ftp.ServerConn, error) {
-	conn, err := ftp.Dial(site, ftp.DialWithTimeout(dialTimeout))
+func connectFtp(site string, dial dialer.DialFunc) (*ftp.ServerConn, error) {
+	conn, err := ftp.Dial(site,
+		ftp.DialWithDialFunc(dial.Func()),
+		ftp.DialWithTimeout(*ftpTimeout),
+		ftp.DialWithDisabledEPSV(*ftpPASV))
 	return conn, err
 }
 
 // newGRPC makes a new grpc (over https) connection for the upload service.
 // host is the hostname to connect to, saPath is a path to a stored json service account key.
-func newGRPC(ctx context.Context, host, saPath string) (*grpc.ClientConn, error) {
+func newGRPC(ctx context.Context, host, saPath string, dial dialer.DialFunc) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}),
+		// Uploads are traced as children of the file span.
+		grpc.WithChainUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+	}
 	if *useTLS {
-		return auth.NewAuthConn(ctx, host, saPath)
+		return auth.NewAuthConn(ctx, host, saPath, opts...)
 	}
-	return auth.InsecureConn(host)
+	return auth.InsecureConn(host, opts...)
 }
 
-func new(ctx context.Context, aUser, aPasswd, site, bucket, grpcService, saKey string, threads int) (*client, error) {
-	f, err := connectFtp(site)
+func new(ctx context.Context, src ArchiveSource, bucket, grpcService, saKey, checksum, project string, rewrites pathrewrite.Rules, threads int) (*client, error) {
+	if !uploadutils.ValidChecksum(checksum) {
+		return nil, fmt.Errorf("unsupported checksum algorithm: %v", checksum)
+	}
+	profile, err := archiveprofile.Lookup(project)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to the ftp site(%v): %v", site, err)
+		return nil, err
 	}
-
-	// Login to cloud-storage, and get a bucket handle to the archive bucket.
-	if err := f.Login(aUser, aPasswd); err != nil {
-		return nil, fmt.Errorf("failed to login to site(%v) as u/p (%v/%v): %v",
-			site, aUser, aPasswd, err)
+	immutableRe, err := regexp.Compile(*immutable)
+	if err != nil {
+		return nil, fmt.Errorf("invalid immutable pattern: %v", err)
 	}
 
+	// Login to cloud-storage, and get a bucket handle to the archive bucket.
 	c, errS := storage.NewClient(ctx)
 	if errS != nil {
 		return nil, fmt.Errorf("failed to create a new storage client: %v", errS)
@@ -118,7 +319,11 @@ func new(ctx context.Context, aUser, aPasswd, site, bucket, grpcService, saKey s
 	bh := c.Bucket(bucket)
 
 	// Create a new upload service client.
-	gc, err := newGRPC(ctx, grpcService, saKey)
+	grpcDial, err := dialer.New(*proxyURL, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	gc, err := newGRPC(ctx, grpcService, saKey, grpcDial)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC client: %v", err)
 	}
@@ -127,52 +332,147 @@ func new(ctx context.Context, aUser, aPasswd, site, bucket, grpcService, saKey s
 	wg.Add(threads)
 
 	return &client{
-		site:    site,
-		user:    aUser,
-		passwd:  aPasswd,
-		gClient: pb.NewRVClient(gc),
-		bs:      c,
-		bh:      bh,
-		fc:      f,
-		bucket:  bucket,
-		ch:      make(chan *evalFile, maxWalk),
-		wg:      wg,
-		mu:      sync.Mutex{},
-		metrics: map[string]int{"sync": 0, "skip": 0, "error": 0},
+		src:        src,
+		gClient:    pb.NewRVClient(gc),
+		bs:         c,
+		bh:         bh,
+		listing:    listing.New(bh),
+		immutable:  immutableRe,
+		bucket:     bucket,
+		checksum:   checksum,
+		profile:    profile,
+		rewrites:   rewrites,
+		ch:         make(chan *evalFile, maxWalk),
+		wg:         wg,
+		mu:         sync.Mutex{},
+		metrics:    map[string]int{"sync": 0, "skip": 0, "error": 0, "skip_size": 0, truncated: 0, regenerated: 0, "verify_mismatch": 0, "invalid_format": 0},
+		progress:   progress.New(),
+		audit:      audit.New(identity()),
+		retry:      retryqueue.New(),
+		breaker:    breaker.New(*breakerWindow, *breakerThreshold, *breakerPause, *breakerTrips),
+		budget:     budget.New(*maxFiles, *maxBytes, *maxDuration),
+		checkpoint: &budget.Checkpoint{},
+		inflight:   inflight.New(*maxInflightBytes),
 	}, nil
 }
 
-// close politely closes the handles to cloud-storage and the ftp archive.
+// close politely closes the handles to cloud-storage and the archive source.
 func (c *client) close() {
-	c.fc.Quit()
+	c.src.Close()
 	if err := c.bs.Close(); err != nil {
-		glog.Fatalf("failed to close the cloud-storage client: %v", err)
+		glog.Exitf("failed to close the cloud-storage client: %v", err)
+	}
+}
+
+// walk walks the directories of the archive source, sending each file
+// which matches the archive profile to a channel for further evaluation.
+// The channel is closed once the walk ends, the readChannel threads drain it.
+func (c *client) walk(dirs []string) {
+	defer close(c.ch)
+	defer c.progress.WalkDone()
+
+	// The files which failed in the previous runs are processed first.
+	for _, e := range c.retry.Entries() {
+		glog.Infof("Sending file for retry(%d failed runs): %s", e.Attempts, e.Name)
+		c.progress.Discovered()
+		c.ch <- &evalFile{name: e.Name, size: -1}
+	}
+
+	// Walk the directory trees, stat/evaluate files, else continue walking.
+	for _, dir := range dirs {
+		if err := c.walkDir(dir); err == errStopWalk {
+			return
+		} else if err != nil {
+			glog.Errorf("Walk of %s stopped: %v", dir, err)
+		}
 	}
 }
 
-// ftpWalk walks a defined directory, sending each file
-// which matches a known pattern (updates) to a channel for further evaluation.
-func (c *client) ftpWalk(dir string) {
-	// Start the walk activity.
-	w := c.fc.Walk(dir)
-
-	// Walk the directory tree, stat/evaluate files, else continue walking.
-	for w.Next() {
-		e := w.Stat()
-		// The only check prior to sending the file for collection is that is a file.
-		if e.Type == ftp.EntryTypeFile {
-			// Add the file to the channel, for evaluation and potential copy.
-			glog.Infof("Sending file for eval: %s", w.Path())
-			c.ch <- &evalFile{name: strings.TrimLeft(w.Path(), "/")}
-		}
-	}
-	if w.Err() != nil {
-		glog.Errorf("Next returned false, closing channel and returning: %v", w.Err())
-		glog.Errorf("Current working directory: %s", w.Path())
-		// Close the channel, the readChannel threads should continue to drain this channel.
-		close(c.ch)
+// walkDir walks a directory of the archive source, it returns errStopWalk if
+// the walk ended early, as the budget is exhausted.
+func (c *client) walkDir(dir string) error {
+	stopped := false
+	err := c.src.Walk(dir, func(e *sourceEntry) error {
+		path := strings.TrimLeft(e.path, "/")
+		if e.dir {
+			c.progress.Walking(path)
+		}
+		// Directories processed by an earlier run are not walked again.
+		if e.dir && c.resume != "" && budget.Before(path, c.resume) {
+			return filepath.SkipDir
+		}
+		// Only files which are archives of the project are sent for collection.
+		if !e.file {
+			return nil
+		}
+		if c.resume != "" && path < c.resume || c.retry.Queued(path) {
+			return nil
+		}
+		a, ok := c.profile.Parse(e.path)
+		if !ok {
+			return nil
+		}
+		// Months of the collector are synced by the instance owning them.
+		if !c.shard.Owns(a.Collector + "/" + a.Time.Format("2006.01")) {
+			return nil
+		}
+		// The budget is exhausted, the rest of the walk is left for the next run.
+		if c.budget.Exhausted() != "" {
+			c.checkpoint.Skip(path)
+			stopped = true
+			return errStopWalk
+		}
+		// Add the file to the channel, for evaluation and potential copy.
+		glog.Infof("Sending file for eval: %s", e.path)
+		c.progress.Discovered()
+		c.ch <- &evalFile{name: path, size: e.size}
+		return nil
+	})
+	if stopped {
+		return errStopWalk
+	}
+	return err
+}
+
+// identity names this run in leases and audit logs: host/pid.
+func identity() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// exitCode is the exit code of a run which aborted, or completed with
+// fileErrors failed files.
+func exitCode(aborted error, fileErrors int) int {
+	switch {
+	case aborted != nil:
+		return exitFatal
+	case fileErrors > 0:
+		return exitFileErrors
+	}
+	return exitOK
+}
+
+// notifyRun posts the summary of the run to -notify_url, if set.
+func notifyRun(start time.Time, status, reason string, metrics map[string]int) {
+	if *notifyURL == "" {
 		return
 	}
+	host, _ := os.Hostname()
+	s := &notify.Summary{
+		Tool:     "mass_upload",
+		Host:     host,
+		Archive:  *archive,
+		Status:   status,
+		Reason:   reason,
+		Started:  start,
+		Duration: time.Since(start).Round(time.Second).String(),
+		Metrics:  metrics,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := notify.Post(ctx, *notifyURL, s); err != nil {
+		glog.Error(err)
+	}
 }
 
 func (c *client) metric(k string) {
@@ -181,26 +481,84 @@ func (c *client) metric(k string) {
 	c.metrics[k]++
 }
 
-// readChannel reads FTP file results from a channel, collects and compares MD5 checksums
+// status is the /statusz report of the sync.
+type status struct {
+	Dir          string         `json:"dir"`
+	LastActivity time.Time      `json:"last_activity"`
+	Discovered   int64          `json:"discovered"`
+	Processed    int64          `json:"processed"`
+	Bytes        int64          `json:"bytes"`
+	WalkDone     bool           `json:"walk_done"`
+	Metrics      map[string]int `json:"metrics"`
+	Stopped      string         `json:"stopped,omitempty"`
+	Aborted      string         `json:"aborted,omitempty"`
+}
+
+// healthz fails once no directory was walked, and no file discovered or
+// processed, for stallTimeout.
+func (c *client) healthz(w http.ResponseWriter, r *http.Request) {
+	rep := c.progress.Report()
+	if idle := time.Since(rep.LastActivity); idle > *stallTimeout {
+		http.Error(w, fmt.Sprintf("stalled: no activity for %v, in directory(%s)", idle.Round(time.Second), rep.Dir),
+			http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// statusz reports the directory being walked, the counts and the last activity.
+func (c *client) statusz(w http.ResponseWriter, r *http.Request) {
+	rep := c.progress.Report()
+	st := &status{
+		Dir:          rep.Dir,
+		LastActivity: rep.LastActivity,
+		Discovered:   rep.Discovered,
+		Processed:    rep.Processed,
+		Bytes:        rep.Bytes,
+		WalkDone:     rep.Final,
+		Metrics:      map[string]int{},
+		Stopped:      c.budget.Exhausted(),
+	}
+	if err := c.breaker.Err(); err != nil {
+		st.Aborted = err.Error()
+	}
+	c.mu.Lock()
+	for k, v := range c.metrics {
+		st.Metrics[k] = v
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		glog.Errorf("json.Encode: %v", err)
+	}
+}
+
+// readChannel reads file results from a channel, collects and compares checksums
 // and uploads files to cloud-storage if mismatches occur.
 func (c *client) readChannel(ctx context.Context) {
 	defer c.wg.Done()
-	ftpErrs := 0
-	grpcErrs := 0
 
-	// Open a new, bespoke FTP connection, so overlapping
-	// command/data channel problems are avoided.
-	f, err := connectFtp(c.site)
+	// Each thread reads the source with its own connection.
+	conn, err := c.src.Open()
 	if err != nil {
-		glog.Errorf("failed to open new FTP connection: %v", err)
-		return
-	}
-	if err := f.Login(c.user, c.passwd); err != nil {
-		glog.Errorf("failed to login to FTP site: %v", err)
+		glog.Errorf("failed to open a connection to the archive source: %v", err)
 		return
 	}
+	defer conn.Close()
 
 	for {
+		// The run was cancelled, ie: the lease was lost.
+		if err := ctx.Err(); err != nil {
+			glog.Errorf("Stopping readChannel: %v", err)
+			return
+		}
+		// Pause while the remote services fail, stop if they do not recover.
+		if err := c.breaker.Wait(ctx); err != nil {
+			glog.Errorf("Stopping readChannel: %v", err)
+			return
+		}
+
 		ef, ok := <-c.ch
 		// Exit if ok is false, this is the 'channel closed' signal.
 		if !ok {
@@ -208,101 +566,388 @@ func (c *client) readChannel(ctx context.Context) {
 			break
 		}
 
-		fn := strings.TrimLeft(ef.name, "/")
-		// If the file doesn't have UPDATES/updates do not process further.
-		if !strings.Contains(fn, "UPDATES/updates") {
-			continue
+		c.process(ctx, conn, ef)
+	}
+}
+
+// process evaluates a file, and uploads it to cloud-storage if it is missing
+// or its checksum mismatches. Each file is traced: the cloud-storage lookup,
+// the transfer from the source (which computes the checksums), and the upload.
+func (c *client) process(ctx context.Context, conn SourceConn, ef *evalFile) {
+	fn := strings.TrimLeft(ef.name, "/")
+	ctx, span := tracer.Start(ctx, "mass_upload.file", trace.WithAttributes(
+		attribute.String("file", fn),
+		attribute.Int64("size", ef.size)))
+	defer span.End()
+
+	// The object name may differ from the ftp path.
+	obj := c.rewrites.Apply(fn)
+	_, attrsSpan := tracer.Start(ctx, "gcs.attrs")
+	attrs, err := c.listing.Attrs(ctx, obj)
+	attrsSpan.End()
+	switch {
+	case err == storage.ErrObjectNotExist:
+		attrs = nil
+	case err != nil:
+		// Without the archived checksum the file would be uploaded blindly.
+		glog.Errorf("failed to get cloud-storage attributes(%s): %v", obj, err)
+		c.breaker.Record(err)
+		c.fail(span, fn, err)
+		return
+	}
+	c.breaker.Record(nil)
+
+	// Files which never change are skipped without a download if the archived
+	// size matches, but for a sample which is verified by checksum.
+	if attrs != nil && ef.size == attrs.Size && c.immutable.MatchString(fn) && rand.Float64()*100 >= *verifyPercent {
+		c.metric("skip_size")
+		c.progress.Processed(0)
+		c.retry.Done(fn)
+		return
+	}
+
+	// Drain the channel once the budget is exhausted, the files are left for the next run.
+	// Queued files stay in the retry queue.
+	if !c.budget.Take() {
+		if !c.retry.Queued(fn) {
+			c.checkpoint.Skip(fn)
 		}
+		return
+	}
+
+	algo, csSum := c.sumFromAttrs(attrs)
 
-		csSum, err := c.md5FromGCS(ctx, fn)
+	// The content is buffered until the upload completes.
+	need := inflightSize(ef, attrs)
+	if err := c.inflight.Acquire(ctx, need); err != nil {
+		// The run was aborted.
+		return
+	}
+	defer c.inflight.Release(need)
+
+	_, retrSpan := tracer.Start(ctx, "source.retr")
+	fc, sums, err := c.contentFromSource(ef.name, conn, algo, uploadutils.MD5, uploadutils.CRC32C, uploadutils.SHA256)
+	retrSpan.SetAttributes(attribute.Int("bytes", len(fc)))
+	retrSpan.End()
+	c.progress.Processed(int64(len(fc)))
+	c.budget.Spend(int64(len(fc)))
+	c.breaker.Record(err)
+	if err != nil {
+		glog.Errorf("error getting content(%s): %v", ef.name, err)
+		c.fail(span, fn, err)
+		return
+	}
+
+	fSum := sums[algo]
+	if csSum == fSum {
+		c.metric("skip")
+		c.retry.Done(fn)
+		return
+	}
+
+	// The file is archived already, but its content changed at the ftp site.
+	// Keep the archived generation and flag the change for review rather
+	// than overwrite history with possibly corrupt content.
+	if csSum != "" {
+		kind := changeKind(attrs.Size, len(fc))
+		c.metric(kind)
+		pctx, preserveSpan := tracer.Start(ctx, "gcs.preserve")
+		err := c.preserve(pctx, attrs, kind)
+		preserveSpan.End()
 		if err != nil {
-			csSum = ""
+			glog.Errorf("failed to preserve changed file(%s), not uploading: %v", obj, err)
+			c.fail(span, fn, err)
+			return
+		}
+		glog.Warningf("REVIEW: archived file(%s) size(%d) %s changed at ftp site, size(%d)",
+			obj, attrs.Size, kind, len(fc))
+		if kind == truncated && !*overwriteTruncated {
+			c.retry.Done(fn)
+			return
 		}
+	}
+
+	// The upload service verifies content with md5, whichever algorithm
+	// was used for the comparison, and has cloud-storage verify the write
+	// with the crc32c. The sha256 is stored with the object.
+	md5Sum := sums[uploadutils.MD5]
+
+	glog.Infof("Archiving file(%s) as(%s) size(%d) %s(%s) to cloud.", ef.name, obj, len(fc), algo, fSum)
+	req := pb.FileRequest{
+		Filename: obj,
+		Content:  fc,
+		Md5Sum:   md5Sum,
+		Crc32C:   sums[uploadutils.CRC32C],
+		Sha256:   sums[uploadutils.SHA256],
+		Project:  c.profile.Project,
+		// A retry of the call, ie: by a proxy, is not processed twice.
+		RequestId: fmt.Sprintf("%016x", rand.Uint64()),
+		SourceUrl: c.src.URL(ef.name),
+		// The archived generation of a changed file is preserved, it may be
+		// replaced.
+		AllowOverwrite: csSum != "",
+	}
+	resp, err := c.gClient.FileUpload(ctx, &req, c.callOpts...)
+	c.breaker.Record(err)
+	if err != nil {
+		glog.Errorf("failed uploading(%s) to grpcService: %v", ef.name, err)
+		c.fail(span, fn, err)
+		return
+	}
+	// The server rejected the content as not MRT, ie: a truncated transfer
+	// from the ftp site, retrying the same content would not help.
+	if resp.GetStatus() == pb.FileResponse_INVALID_FORMAT {
+		glog.Errorf("REVIEW: file(%s) as(%s) is not valid MRT, not archived: %s", ef.name, obj, resp.GetErrorMessage())
+		c.metric("invalid_format")
+		c.retry.Done(fn)
+		return
+	}
+	c.audit.Record(obj, md5Sum, int64(len(fc)), audit.Upload)
+	glog.Infof("File upload status: %s", resp.GetStatus())
 
-		fSum, fc, err := c.md5FromFTP(ef.name, f)
+	if *verifyAfterUpload {
+		vctx, verifySpan := tracer.Start(ctx, "gcs.verify")
+		stored, err := c.bh.Object(obj).Attrs(vctx)
+		verifySpan.End()
+		c.breaker.Record(err)
 		if err != nil {
-			if ftpErrs < maxFTPErrs {
-				glog.Infof("error getting md5(%s): %v", ef.name, err)
-				ftpErrs++
-				continue
-			}
-			// Enough failures have happened, exit and restart.
-			glog.Fatalf("failed to get ftp md5 for file(%s): %v", ef.name, err)
+			glog.Errorf("failed to verify upload(%s): %v", obj, err)
+			c.fail(span, fn, err)
+			return
 		}
-
-		if csSum == fSum {
-			c.metric("skip")
-			continue
+		if got := uploadutils.ChecksumFromAttrs(uploadutils.MD5, stored); got != md5Sum {
+			err := fmt.Errorf("stored md5(%s) does not match the uploaded md5(%s)", got, md5Sum)
+			glog.Errorf("REVIEW: uploaded file(%s) as(%s) failed verification: %v", ef.name, obj, err)
+			c.mu.Lock()
+			c.mismatches = append(c.mismatches, obj)
+			c.mu.Unlock()
+			c.metric("verify_mismatch")
+			c.fail(span, fn, err)
+			return
 		}
+	}
+	c.metric("sync")
+	c.retry.Done(fn)
+}
 
-		glog.Infof("Archiving file(%s) size(%d) to cloud.", ef.name, len(fc))
-		req := pb.FileRequest{
-			Filename: ef.name,
-			Content:  fc,
-			Md5Sum:   fSum,
-			Project:  pb.FileRequest_ROUTEVIEWS,
-		}
-		resp, err := c.gClient.FileUpload(ctx, &req)
-		if err != nil {
-			glog.Errorf("failed uploading(%s) to grpcService: %v", ef.name, err)
-			c.metric("error")
-			if grpcErrs >= maxGrpcErrs {
-				return
-			}
-			grpcErrs++
-			continue
+// inflightSize is the size of the content of a file to reserve: the ftp size,
+// or the archived size if the ftp size is unknown (a retried file). A file of
+// unknown size reserves the whole budget.
+func inflightSize(ef *evalFile, attrs *storage.ObjectAttrs) int64 {
+	switch {
+	case ef.size >= 0:
+		return ef.size
+	case attrs != nil:
+		return attrs.Size
+	}
+	return *maxInflightBytes
+}
+
+// fail records a failure to process the file fn, it is retried by the next run.
+func (c *client) fail(span trace.Span, fn string, err error) {
+	c.metric("error")
+	c.retry.Fail(fn, err)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// sumFromAttrs returns the algorithm and checksum cloud-storage holds for an object,
+// the checksum is empty if the object does not exist (attrs is nil).
+// The configured algorithm is preferred, md5 is used if cloud-storage has no record of it.
+// Composite objects have no md5, so crc32c (recorded for every object) is the last resort.
+func (c *client) sumFromAttrs(attrs *storage.ObjectAttrs) (string, string) {
+	if attrs == nil {
+		return c.checksum, ""
+	}
+	for _, algo := range []string{c.checksum, uploadutils.MD5, uploadutils.CRC32C} {
+		if sum := uploadutils.ChecksumFromAttrs(algo, attrs); sum != "" {
+			return algo, sum
 		}
-		c.metric("sync")
-		glog.Infof("File upload status: %s", resp.GetStatus())
 	}
+	return c.checksum, ""
 }
 
-func (c *client) md5FromGCS(ctx context.Context, path string) (string, error) {
-	attrs, err := c.bh.Object(path).Attrs(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to get attrs for obj: %v", err)
+// changeKind classifies the change of an archived file from its size and
+// the size of the ftp content.
+func changeKind(archived int64, ftpSize int) string {
+	if int64(ftpSize) < archived {
+		return truncated
 	}
-	return hex.EncodeToString(attrs.MD5), nil
+	return regenerated
 }
 
-func (c *client) md5FromFTP(path string, fc *ftp.ServerConn) (string, []byte, error) {
-	r, err := fc.Retr(path)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to RETR the path: %v", err)
+// preserve copies the archived generation of a changed file to the history prefix:
+//   _history/<object>.<generation>
+// The copy is flagged for review in its metadata, list the history prefix to review
+// the changes.
+func (c *client) preserve(ctx context.Context, attrs *storage.ObjectAttrs, kind string) error {
+	if *historyPrefix == "" {
+		return nil
+	}
+	dst := fmt.Sprintf("%s%s.%d", *historyPrefix, attrs.Name, attrs.Generation)
+	cp := c.bh.Object(dst).CopierFrom(c.bh.Object(attrs.Name).Generation(attrs.Generation))
+	cp.Metadata = map[string]string{}
+	for k, v := range attrs.Metadata {
+		cp.Metadata[k] = v
+	}
+	cp.Metadata["changeKind"] = kind
+	cp.Metadata["reviewStatus"] = "pending"
+	cp.Metadata["originalObject"] = attrs.Name
+	cp.ContentType = attrs.ContentType
+	if _, err := cp.Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy generation %d to %s: %v", attrs.Generation, dst, err)
+	}
+	c.audit.Record(dst, hex.EncodeToString(attrs.MD5), attrs.Size, audit.Preserve)
+	glog.Infof("Preserved %s generation(%d) as %s", attrs.Name, attrs.Generation, dst)
+	return nil
+}
+
+// contentFromSource reads a file from the archive source, and returns its
+// content and checksums, keyed by algorithm. The checksums are computed as the
+// content is received, so a transfer which fails part way is resumed from the
+// received offset (REST), up to ftpRetries times, and the checksums still
+// cover the whole file.
+func (c *client) contentFromSource(path string, conn SourceConn, algos ...string) ([]byte, map[string]string, error) {
+	buf := &bytes.Buffer{}
+	ws := []io.Writer{buf}
+	hashes := map[string]hash.Hash{}
+	for _, algo := range algos {
+		h, err := uploadutils.NewHash(algo)
+		if err != nil {
+			return nil, nil, err
+		}
+		hashes[algo] = h
+		ws = append(ws, h)
+	}
+	w := io.MultiWriter(ws...)
+
+	for attempt := 0; ; attempt++ {
+		resumable, err := conn.RetrFrom(path, buf.Len(), w)
+		if err == nil {
+			break
+		}
+		if !resumable || attempt >= *ftpRetries {
+			return nil, nil, err
+		}
+		glog.Infof("Resuming transfer(%s) at offset(%d) after: %v", path, buf.Len(), err)
 	}
-	defer r.Close()
 
-	buf, err := ioutil.ReadAll(r)
-	return fmt.Sprintf("%x", md5.Sum(buf)), buf, nil
+	sums := map[string]string{}
+	for algo, h := range hashes {
+		sums[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+	return buf.Bytes(), sums, nil
 }
 
 func main() {
 	flag.Parse()
+	// A different sample of the immutable files is verified each run.
+	rand.Seed(time.Now().UnixNano())
 	if *bucket == "" || *archive == "" {
-		glog.Fatal("set archive and bucket, or there is nothing to do")
+		glog.Exit("set archive and bucket, or there is nothing to do")
 	}
-
-	// Clean up the archive (ftp://blah.org/floop/) to be a host/directory.
-	var site, dir string
-	site = *archive
-	if strings.HasPrefix(site, "ftp://") {
-		site = strings.TrimLeft(site, "ftp://")
+	if *profiles != "" {
+		if err := archiveprofile.Load(*profiles); err != nil {
+			glog.Exitf("failed to load the archive profiles: %v", err)
+		}
 	}
-	if strings.HasSuffix(site, "/") {
-		site = strings.TrimRight(site, "/")
+
+	sh, err := shard.New(*shardN, *totalShards)
+	if err != nil {
+		glog.Exit(err)
 	}
-	parts := strings.Split(site, "/")
-	site = parts[0] + ":21"
-	dir = strings.Join(parts[1:], "/")
-	dir = "/" + dir
 
 	// Create a client, and start processing.
 	// NOTE: Consider spawning N goroutines as fetch processors for the
 	//       pathnames which are output from Walk().
-	ctx := context.Background()
-	c, err := new(ctx, *aUser, *aPasswd, site, *bucket, *grpcService, *svcAccountKey, *threads)
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// flushTraces exports the buffered spans, call it before the process exits.
+	flushTraces := func(context.Context) error { return nil }
+	if *traceProject != "" {
+		if flushTraces, err = tracing.Init(ctx, *traceProject, "mass_upload", *traceRatio); err != nil {
+			glog.Exit(err)
+		}
+	}
+	// The directory may have wildcards, ie: /route-views*/bgpdata.
+	var dirs []string
+	src, dir, err := newSource(*archive)
+	if err == nil {
+		dirs, err = expandDirs(src, dir)
+	}
+	if err != nil {
+		notifyRun(start, notify.Aborted, err.Error(), nil)
+		glog.Exit(err)
+	}
+	c, err := new(ctx, src, *bucket, *grpcService, *svcAccountKey, *checksum, *project, rewrites, *threads)
 	if err != nil {
-		glog.Fatalf("failed to create the client: %v", err)
+		notifyRun(start, notify.Aborted, fmt.Sprintf("failed to create the client: %v", err), nil)
+		glog.Exitf("failed to create the client: %v", err)
+	}
+	c.shard = sh
+	if *compress {
+		c.callOpts = append(c.callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	if *checkpointFile != "" {
+		if c.resume, err = budget.Load(*checkpointFile); err != nil {
+			glog.Exit(err)
+		}
+		if c.resume != "" {
+			glog.Infof("Resuming from checkpoint: %s", c.resume)
+		}
+	}
+
+	if *retryQueue != "" {
+		if c.retry, err = retryqueue.Load(ctx, c.bs, *retryQueue); err != nil {
+			glog.Exit(err)
+		}
+	}
+
+	// Hold the lease for the whole run, it is lost if a renewal fails.
+	var l *lease.Lease
+	if *leaseName != "" {
+		l, err = lease.Acquire(ctx, c.bh, *leaseName, identity(), *leaseTTL)
+		if herr, ok := err.(*lease.HeldError); ok {
+			// An overlapping run syncs the collector, there is nothing to do.
+			fmt.Println(herr)
+			c.close()
+			return
+		}
+		if err != nil {
+			glog.Exit(err)
+		}
+		glog.Infof("Acquired lease %s as %s", l.Name, l.Holder)
+		go func() {
+			if err := l.Keep(ctx, *leaseTTL/3); err != nil {
+				glog.Errorf("Lost the lease, stopping: %v", err)
+				c.mu.Lock()
+				c.leaseErr = err
+				c.mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+
+	if *httpAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", c.healthz)
+		mux.HandleFunc("/statusz", c.statusz)
+		go func() {
+			glog.Exit(http.ListenAndServe(*httpAddr, mux))
+		}()
+	}
+
+	if *usageInterval > 0 {
+		go resourceusage.Report(ctx, *usageInterval, func(u *resourceusage.Usage) {
+			glog.Infof("Resource usage: %s", u)
+		})
+	}
+
+	if *progressInterval > 0 {
+		go c.progress.Every(ctx, *progressInterval, func(r *progress.Report) {
+			glog.Infof("Progress: %s", r)
+		})
 	}
 
 	// Start the readChannel threads.
@@ -310,17 +955,86 @@ func main() {
 		go c.readChannel(ctx)
 	}
 
-	// Start the FTP walk, then read from the channel and evaluate each file.
-	go c.ftpWalk(dir)
+	// Start the walk, then read from the channel and evaluate each file.
+	go c.walk(dirs)
 
 	// Wait on all readChannel routines to finish.
 	c.wg.Wait()
 
-	// All operations ended, close the external services.
+	// All operations ended, release the lease and close the external services.
 	glog.Info("Ending transmission/comparison.")
+	cancel()
+	c.mu.Lock()
+	aborted := c.leaseErr
+	c.mu.Unlock()
+	if l != nil && aborted == nil {
+		if err := l.Release(context.Background()); err != nil {
+			glog.Error(err)
+		}
+	}
+	if *auditPrefix != "" && c.audit.Len() > 0 {
+		name := *auditPrefix + start.UTC().Format("2006/01/02/150405") + "-" + strings.Replace(identity(), "/", "-", -1) + ".jsonl"
+		if err := c.audit.Write(context.Background(), c.bh, name); err != nil {
+			glog.Error(err)
+		} else {
+			glog.Infof("Wrote the audit log of %d changes to %s", c.audit.Len(), name)
+		}
+	}
+	if *retryQueue != "" {
+		if err := c.retry.Save(context.Background(), c.bs, *retryQueue); err != nil {
+			glog.Error(err)
+		}
+	}
 	c.close()
+	if err := c.breaker.Err(); err != nil {
+		aborted = err
+	}
+	if aborted != nil {
+		fmt.Printf("Aborted: %v\n", aborted)
+	}
+	if reason := c.budget.Exhausted(); reason != "" && aborted == nil {
+		fmt.Printf("Stopped: %s\nCheckpoint: %s\n", reason, c.checkpoint.Path())
+	}
+	// An aborted run keeps the checkpoint it started from, a complete run clears it.
+	if *checkpointFile != "" && aborted == nil {
+		if err := budget.Save(*checkpointFile, c.checkpoint.Path()); err != nil {
+			glog.Error(err)
+		}
+	}
 	fmt.Println("Metrics for file sync activity:")
 	for k, v := range c.metrics {
 		fmt.Printf("%s: %d\n", k, v)
 	}
+	fmt.Printf("Progress: %s\n", c.progress.Report())
+	if entries := c.retry.Entries(); len(entries) > 0 {
+		fmt.Printf("Failed files queued for retry: %d\n", len(entries))
+	}
+	if len(c.mismatches) > 0 {
+		fmt.Printf("REVIEW: uploaded objects which failed verification: %d\n", len(c.mismatches))
+		for _, obj := range c.mismatches {
+			fmt.Printf("  %s\n", obj)
+		}
+	}
+	if u, err := resourceusage.Snapshot(); err != nil {
+		glog.Errorf("failed to collect resource usage: %v", err)
+	} else {
+		fmt.Println("Resource usage:")
+		fmt.Printf("cpu user: %v\ncpu system: %v\npeak rss: %d bytes\ngoroutines: %d\nheap: %d bytes\ngc runs: %d\ngc pause: %v\n",
+			u.UserCPU, u.SystemCPU, u.MaxRSS, u.Goroutines, u.HeapAlloc, u.NumGC, u.GCPauseTotal)
+	}
+	switch {
+	case aborted != nil:
+		notifyRun(start, notify.Aborted, aborted.Error(), c.metrics)
+	case c.metrics["error"] > 0:
+		notifyRun(start, notify.Errors, c.budget.Exhausted(), c.metrics)
+	default:
+		notifyRun(start, notify.OK, c.budget.Exhausted(), c.metrics)
+	}
+	if err := flushTraces(context.Background()); err != nil {
+		glog.Errorf("failed to export the traces: %v", err)
+	}
+	if code := exitCode(aborted, c.metrics["error"]); code != exitOK {
+		glog.Flush()
+		os.Exit(code)
+	}
 }
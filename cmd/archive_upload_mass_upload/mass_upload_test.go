@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseArchive(t *testing.T) {
+	tests := []struct {
+		archive  string
+		port     int
+		wantSite string
+		wantDir  string
+		wantErr  bool
+	}{
+		{archive: "ftp://archive.routeviews.org/", port: 21, wantSite: "archive.routeviews.org:21", wantDir: "/"},
+		{archive: "ftp://archive.routeviews.org/route-views4/bgpdata/", port: 21, wantSite: "archive.routeviews.org:21", wantDir: "/route-views4/bgpdata"},
+		{archive: "ftp://ftp.ripe.net/ripe/mrt", port: 21, wantSite: "ftp.ripe.net:21", wantDir: "/ripe/mrt"},
+		{archive: "ftp://archive.routeviews.org/route-views[46]*/bgpdata", port: 21, wantSite: "archive.routeviews.org:21", wantDir: "/route-views[46]*/bgpdata"},
+		{archive: "ftp://mirror.example.net:2121/pub", port: 21, wantSite: "mirror.example.net:2121", wantDir: "/pub"},
+		{archive: "mirror.example.net/pub", port: 2121, wantSite: "mirror.example.net:2121", wantDir: "/pub"},
+		{archive: "http://archive.routeviews.org/", port: 21, wantErr: true},
+		{archive: "ftp:///pub", port: 21, wantErr: true},
+	}
+	for _, test := range tests {
+		site, dir, err := parseArchive(test.archive, test.port)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseArchive(%s) = %v; want err %v", test.archive, err, test.wantErr)
+			continue
+		}
+		if site != test.wantSite || dir != test.wantDir {
+			t.Errorf("parseArchive(%s) = %s, %s; want %s, %s", test.archive, site, dir, test.wantSite, test.wantDir)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		desc       string
+		aborted    error
+		fileErrors int
+		want       int
+	}{
+		{desc: "clean", want: exitOK},
+		{desc: "file errors", fileErrors: 2, want: exitFileErrors},
+		{desc: "aborted", aborted: errors.New("lease lost"), want: exitFatal},
+		{desc: "aborted with file errors", aborted: errors.New("breaker tripped"), fileErrors: 6, want: exitFatal},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.aborted, test.fileErrors); got != test.want {
+			t.Errorf("%s: exitCode() = %d; want %d", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestSourceURL(t *testing.T) {
+	tests := []struct {
+		src  ArchiveSource
+		path string
+		want string
+	}{
+		{src: &ftpSource{site: "archive.routeviews.org:21"}, path: "/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", want: "ftp://archive.routeviews.org/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2"},
+		{src: &ftpSource{site: "mirror.example.net:2121"}, path: "/pub/rib.bz2", want: "ftp://mirror.example.net:2121/pub/rib.bz2"},
+		{src: &localSource{root: "/data/site"}, path: "/rrc00/2022.01/updates.20220109.1830.gz", want: "file:///data/site/rrc00/2022.01/updates.20220109.1830.gz"},
+	}
+	for _, test := range tests {
+		if got := test.src.URL(test.path); got != test.want {
+			t.Errorf("URL(%s) = %s; want %s", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/routeviews/google-cloud-storage/pkg/dialer"
+)
+
+// ArchiveSource is a site the archives are synced from: an ftp site, or a
+// directory on local disk which mirrors the layout of one.
+type ArchiveSource interface {
+	// Walk calls fn for each entry below dir. fn returns filepath.SkipDir to
+	// skip a directory, or errStopWalk to end the walk.
+	Walk(dir string, fn func(e *sourceEntry) error) error
+	// Open opens a connection to read files with, each thread has its own.
+	Open() (SourceConn, error)
+	// Close closes the connection of the walk.
+	Close() error
+	// URL returns the URL of the file at path, it is stored with the object.
+	URL(path string) string
+}
+
+// SourceConn reads the files of an ArchiveSource.
+type SourceConn interface {
+	// RetrFrom copies the file at path to w, starting at offset. The error is
+	// resumable if the copy started, but failed part way.
+	RetrFrom(path string, offset int, w io.Writer) (resumable bool, err error)
+	Close() error
+}
+
+// sourceEntry is a file or directory found by a walk.
+type sourceEntry struct {
+	// path is the path from the root of the site, with a leading "/".
+	path string
+	dir  bool
+	// file is false for directories and for links, and other special files.
+	file bool
+	size int64
+}
+
+// errStopWalk ends a walk early, it is not an error of the walk.
+var errStopWalk = errors.New("walk stopped")
+
+// newSource returns the source of an archive URL, and the directory to walk:
+//
+//	ftp://site[:port]/dir/
+//	file:///path/
+//
+// The local path is the root of the site, its layout mirrors the ftp site.
+func newSource(archive string) (ArchiveSource, string, error) {
+	if strings.HasPrefix(archive, "file://") {
+		u, err := url.Parse(archive)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid archive URL(%s): %v", archive, err)
+		}
+		root := filepath.Clean(u.Path)
+		if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+			return nil, "", fmt.Errorf("invalid archive URL(%s), %s is not a directory", archive, root)
+		}
+		return &localSource{root: root}, "/", nil
+	}
+
+	// Clean up the archive (ftp://blah.org/floop/) to be a host:port/directory.
+	site, dir, err := parseArchive(archive, *ftpPort)
+	if err != nil {
+		return nil, "", err
+	}
+	dial, err := dialer.New(*proxyURL, *ftpTimeout)
+	if err != nil {
+		return nil, "", err
+	}
+	src := &ftpSource{site: site, user: *aUser, passwd: *aPasswd, dial: dial}
+	if src.fc, err = src.connect(); err != nil {
+		return nil, "", err
+	}
+	return src, dir, nil
+}
+
+// expandDirs expands the wildcards (*, ? and [...], see path.Match) of the
+// directory to walk into the matching directories of the source, ie:
+// /route-views*/bgpdata matches the bgpdata directory of each collector.
+func expandDirs(src ArchiveSource, dir string) ([]string, error) {
+	dirs := []string{"/"}
+	for _, elem := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if elem == "" {
+			continue
+		}
+		if !strings.ContainsAny(elem, "*?[") {
+			for i := range dirs {
+				dirs[i] = path.Join(dirs[i], elem)
+			}
+			continue
+		}
+		if _, err := path.Match(elem, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern(%s): %v", elem, err)
+		}
+		var matched []string
+		for _, d := range dirs {
+			err := src.Walk(d, func(e *sourceEntry) error {
+				if !e.dir {
+					return nil
+				}
+				if ok, _ := path.Match(elem, path.Base(e.path)); ok {
+					matched = append(matched, e.path)
+				}
+				// Only the directories of d are listed.
+				return filepath.SkipDir
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand %s: %v", dir, err)
+			}
+		}
+		dirs = matched
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no directories match %s", dir)
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// ftpSource is an ftp site.
+type ftpSource struct {
+	site   string
+	user   string
+	passwd string
+	// dial dials the ftp connections, through the proxy if one is set.
+	dial dialer.DialFunc
+	// fc is the connection of the walk.
+	fc *ftp.ServerConn
+}
+
+// connect opens and logs in a connection to the ftp site.
+func (s *ftpSource) connect() (*ftp.ServerConn, error) {
+	fc, err := connectFtp(s.site, s.dial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the ftp site(%v): %v", s.site, err)
+	}
+	if err := fc.Login(s.user, s.passwd); err != nil {
+		return nil, fmt.Errorf("failed to login to site(%v) as u/p (%v/%v): %v",
+			s.site, s.user, s.passwd, err)
+	}
+	return fc, nil
+}
+
+func (s *ftpSource) Walk(dir string, fn func(e *sourceEntry) error) error {
+	w := s.fc.Walk(dir)
+	for w.Next() {
+		e := w.Stat()
+		err := fn(&sourceEntry{
+			path: w.Path(),
+			dir:  e.Type == ftp.EntryTypeFolder,
+			file: e.Type == ftp.EntryTypeFile,
+			size: int64(e.Size),
+		})
+		switch {
+		case err == filepath.SkipDir:
+			w.SkipDir()
+		case err == errStopWalk:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+	if err := w.Err(); err != nil {
+		return fmt.Errorf("walk failed in directory(%s): %v", w.Path(), err)
+	}
+	return nil
+}
+
+// Open opens a new, bespoke ftp connection, so overlapping command/data
+// channel problems are avoided.
+func (s *ftpSource) Open() (SourceConn, error) {
+	fc, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	return &ftpConn{fc: fc}, nil
+}
+
+func (s *ftpSource) Close() error {
+	return s.fc.Quit()
+}
+
+// URL returns the ftp URL of the file, without the default port.
+func (s *ftpSource) URL(p string) string {
+	host := s.site
+	if h, port, err := net.SplitHostPort(s.site); err == nil && port == "21" {
+		host = h
+	}
+	return (&url.URL{Scheme: "ftp", Host: host, Path: p}).String()
+}
+
+// ftpConn reads files from an ftp site.
+type ftpConn struct {
+	fc *ftp.ServerConn
+}
+
+// RetrFrom retrieves the file with the REST command, from offset.
+func (c *ftpConn) RetrFrom(path string, offset int, w io.Writer) (bool, error) {
+	r, err := c.fc.RetrFrom(path, uint64(offset))
+	if err != nil {
+		return false, fmt.Errorf("failed to RETR the path: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return true, fmt.Errorf("failed to read the path: %v", err)
+	}
+	return false, nil
+}
+
+func (c *ftpConn) Close() error {
+	return c.fc.Quit()
+}
+
+// localSource is a directory on local disk, ie: data shipped on disk by a
+// collector, or an unpacked tarball.
+type localSource struct {
+	root string
+}
+
+// Walk walks the directory in lexical order, links are not followed.
+func (s *localSource) Walk(dir string, fn func(e *sourceEntry) error) error {
+	start := filepath.Join(s.root, filepath.FromSlash(dir))
+	err := filepath.Walk(start, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == start {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		return fn(&sourceEntry{
+			path: "/" + filepath.ToSlash(rel),
+			dir:  fi.IsDir(),
+			file: fi.Mode().IsRegular(),
+			size: fi.Size(),
+		})
+	})
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+// Open returns the source itself, local reads share no state.
+func (s *localSource) Open() (SourceConn, error) {
+	return s, nil
+}
+
+func (s *localSource) Close() error {
+	return nil
+}
+
+// URL returns the file URL of the file on local disk.
+func (s *localSource) URL(p string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(s.root, filepath.FromSlash(p)))}).String()
+}
+
+// RetrFrom reads the file from offset, local reads are not resumed.
+func (s *localSource) RetrFrom(path string, offset int, w io.Writer) (bool, error) {
+	f, err := os.Open(filepath.Join(s.root, filepath.FromSlash(path)))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(int64(offset), io.SeekStart); err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return false, fmt.Errorf("failed to read the path: %v", err)
+	}
+	return false, nil
+}
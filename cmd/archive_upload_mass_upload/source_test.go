@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocalSource(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2": "updates",
+		"route-views4/bgpdata/2022.01/RIBS/rib.20220109.1800.bz2":        "rib",
+		"route-views6/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2": "other",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src, dir, err := newSource("file://" + root)
+	if err != nil {
+		t.Fatalf("newSource() = %v; want nil err", err)
+	}
+	if dir != "/" {
+		t.Errorf("newSource() dir = %s; want /", dir)
+	}
+
+	// The walk skips route-views6, and stops after the first update.
+	var got []string
+	err = src.Walk(dir, func(e *sourceEntry) error {
+		if e.dir && e.path == "/route-views6" {
+			return filepath.SkipDir
+		}
+		if e.file {
+			got = append(got, e.path)
+		}
+		if e.path == "/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2" {
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() = %v; want nil err", err)
+	}
+	want := []string{
+		"/route-views4/bgpdata/2022.01/RIBS/rib.20220109.1800.bz2",
+		"/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Walk() files diff (-want +got):\n%s", diff)
+	}
+
+	conn, err := src.Open()
+	if err != nil {
+		t.Fatalf("Open() = %v; want nil err", err)
+	}
+	defer conn.Close()
+	buf := &bytes.Buffer{}
+	if _, err := conn.RetrFrom("route-views4/bgpdata/2022.01/RIBS/rib.20220109.1800.bz2", 1, buf); err != nil {
+		t.Fatalf("RetrFrom() = %v; want nil err", err)
+	}
+	if got := buf.String(); got != "ib" {
+		t.Errorf("RetrFrom(offset 1) = %q; want %q", got, "ib")
+	}
+
+	if _, _, err := newSource("file://" + filepath.Join(root, "missing")); err == nil {
+		t.Error("newSource(missing directory): nil err; want non-nil err")
+	}
+}
+
+func TestExpandDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{
+		"route-views2/bgpdata",
+		"route-views4/bgpdata",
+		"route-views6/bgpdata",
+		"route-views.sydney/bgpdata",
+		"rrc00/bview",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file matching the pattern is not a directory to walk.
+	if err := ioutil.WriteFile(filepath.Join(root, "route-views.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := &localSource{root: root}
+
+	tests := []struct {
+		desc    string
+		dir     string
+		want    []string
+		wantErr bool
+	}{{
+		desc: "no wildcards",
+		dir:  "/route-views4/bgpdata",
+		want: []string{"/route-views4/bgpdata"},
+	}, {
+		desc: "root",
+		dir:  "/",
+		want: []string{"/"},
+	}, {
+		desc: "collectors",
+		dir:  "/route-views*/bgpdata",
+		want: []string{"/route-views.sydney/bgpdata", "/route-views2/bgpdata", "/route-views4/bgpdata", "/route-views6/bgpdata"},
+	}, {
+		desc: "character class",
+		dir:  "/route-views[46]/bgpdata/",
+		want: []string{"/route-views4/bgpdata", "/route-views6/bgpdata"},
+	}, {
+		desc: "every level",
+		dir:  "/*/*",
+		want: []string{"/route-views.sydney/bgpdata", "/route-views2/bgpdata", "/route-views4/bgpdata", "/route-views6/bgpdata", "/rrc00/bview"},
+	}, {
+		desc:    "no match",
+		dir:     "/route-views9*",
+		wantErr: true,
+	}, {
+		desc:    "invalid pattern",
+		dir:     "/route-views[4",
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		got, err := expandDirs(src, test.dir)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: expandDirs(%s) = %v; want err %v", test.desc, test.dir, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: expandDirs(%s) diff (-want +got):\n%s", test.desc, test.dir, diff)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// attrsTTL is how long the attributes of an object are kept. An object
+	// written by another instance is seen at most this late.
+	attrsTTL = 30 * time.Second
+	// attrsSize bounds the objects kept, the least recently used are evicted
+	// first.
+	attrsSize = 10000
+)
+
+// attrsKey is an object of a bucket.
+type attrsKey struct {
+	bkt, obj string
+}
+
+// attrsEntry is the attributes of an object, in the LRU list of the cache.
+type attrsEntry struct {
+	key     attrsKey
+	attrs   *storage.ObjectAttrs
+	expires time.Time
+}
+
+// attrsCache is an LRU cache of the attributes of the objects looked up
+// recently, ie: by the lookup of an upload for unchanged content, and
+// ObjectStat, which saves the metadata reads of the retries and the stats of
+// an object. The writes of the server replace the attributes of the object
+// written, and deletes drop them. It is safe for concurrent use, and a nil
+// cache caches nothing.
+//
+// The cache is per instance: the writes of another instance are seen once the
+// attributes expire. The writes are conditioned on the generation looked up,
+// so a write over stale attributes fails rather than replace the object.
+type attrsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	lru     *list.List
+	entries map[attrsKey]*list.Element
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newAttrsCache returns a cache of the attributes of at most size objects,
+// each kept for ttl.
+func newAttrsCache(ttl time.Duration, size int) *attrsCache {
+	return &attrsCache{
+		ttl:     ttl,
+		size:    size,
+		lru:     list.New(),
+		entries: map[attrsKey]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+// get returns the attributes of an object, false if they are not kept or
+// expired.
+func (c *attrsCache) get(bkt, obj string) (*storage.ObjectAttrs, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[attrsKey{bkt, obj}]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*attrsEntry)
+	if !c.now().Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.attrs, true
+}
+
+// put keeps the attributes of an object, ie: of the object written, the least
+// recently used object is evicted if the cache is full.
+func (c *attrsCache) put(attrs *storage.ObjectAttrs) {
+	if c == nil || attrs == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := attrsKey{attrs.Bucket, attrs.Name}
+	e := &attrsEntry{key: key, attrs: attrs, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*attrsEntry).key)
+	}
+}
+
+// drop drops the attributes of an object, ie: before it is written or once it
+// is deleted.
+func (c *attrsCache) drop(bkt, obj string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[attrsKey{bkt, obj}]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, attrsKey{bkt, obj})
+	}
+}
+
+// lookup returns the attributes of an object, from the cache if it keeps them,
+// storage.ErrObjectNotExist if it does not exist. The objects which do not
+// exist are not cached, they are about to be written.
+func (r rvServer) lookup(ctx context.Context, bkt, obj string) (*storage.ObjectAttrs, error) {
+	if attrs, ok := r.recent.get(bkt, obj); ok {
+		return attrs, nil
+	}
+	attrs, err := r.objects().attrs(ctx, bkt, obj)
+	if err != nil {
+		return nil, err
+	}
+	r.recent.put(attrs)
+	return attrs, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestAttrsCache(t *testing.T) {
+	now := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	c := newAttrsCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	foo := &storage.ObjectAttrs{Bucket: "bkt", Name: "foo", Generation: 1}
+	bar := &storage.ObjectAttrs{Bucket: "bkt", Name: "bar", Generation: 2}
+	baz := &storage.ObjectAttrs{Bucket: "bkt", Name: "baz", Generation: 3}
+	c.put(foo)
+	c.put(bar)
+	if got, ok := c.get("bkt", "foo"); !ok || got != foo {
+		t.Errorf("get(foo) = %v, %v, want %v", got, ok, foo)
+	}
+	// foo was used more recently than bar, bar is evicted.
+	c.put(baz)
+	if _, ok := c.get("bkt", "bar"); ok {
+		t.Errorf("get(bar) of the least recently used object got ok, want evicted")
+	}
+	if _, ok := c.get("bkt", "foo"); !ok {
+		t.Errorf("get(foo) got not ok, want kept")
+	}
+
+	// A write replaces the attributes of its object.
+	foo2 := &storage.ObjectAttrs{Bucket: "bkt", Name: "foo", Generation: 4}
+	c.put(foo2)
+	if got, _ := c.get("bkt", "foo"); got != foo2 {
+		t.Errorf("get(foo) after put = %v, want %v", got, foo2)
+	}
+
+	c.drop("bkt", "foo")
+	if _, ok := c.get("bkt", "foo"); ok {
+		t.Errorf("get(foo) after drop got ok, want dropped")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get("bkt", "baz"); ok {
+		t.Errorf("get(baz) after the ttl got ok, want expired")
+	}
+
+	var nilCache *attrsCache
+	nilCache.put(foo)
+	nilCache.drop("bkt", "foo")
+	if _, ok := nilCache.get("bkt", "foo"); ok {
+		t.Errorf("get(foo) of a nil cache got ok, want not ok")
+	}
+}
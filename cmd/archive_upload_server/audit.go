@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// auditMessage is the message of the audit records, filter the log entries
+// with it, ie: jsonPayload.message="rv upload audit".
+const auditMessage = "rv upload audit"
+
+// uploadRecord is the structured audit record of an upload, or a delete. Cloud Logging
+// parses each JSON line the server writes to stdout into a log entry, with
+// the fields in its jsonPayload, and a log sink may route them to BigQuery.
+type uploadRecord struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// RPC is the method of the request: FileUpload, FileUploadStream,
+	// FinishUpload or DeleteObject.
+	RPC      string `json:"rpc"`
+	Caller   string `json:"caller"`
+	Filename string `json:"filename"`
+	Project  string `json:"project"`
+	// Object is the stored object, gs://<bucket>/<object>.
+	Object string `json:"object,omitempty"`
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5"`
+	// Result is the status of the upload: SUCCESS, UNCHANGED or FAIL.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Generation is the generation of the object stored, or of the object
+	// found unchanged.
+	Generation int64 `json:"generation,omitempty"`
+	// Replaced is the generation of the object of other content replaced by
+	// the upload, with allow_overwrite.
+	Replaced int64 `json:"replaced,omitempty"`
+	// FailedOver is the bucket which was unavailable, of an upload stored to
+	// its failover bucket, pending the copy back to it.
+	FailedOver string `json:"failed_over,omitempty"`
+	// Reason is why the object was deleted, of a DeleteObject.
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// auditLog writes the audit records as JSON lines, it is safe for concurrent
+// use.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newAuditLog returns an audit log which writes to w.
+func newAuditLog(w io.Writer) *auditLog {
+	return &auditLog{w: w, now: time.Now}
+}
+
+func (a *auditLog) write(rec *uploadRecord) {
+	rec.Message = auditMessage
+	rec.Time = a.now().UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		glog.Errorf("failed to marshal the audit record of %s: %v", rec.Filename, err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.w.Write(append(line, '\n'))
+}
+
+// auditUpload completes the audit record of an upload, or a delete, with the
+// caller and the result, and writes it. The audit log is off when r.audit is nil.
+func (r rvServer) auditUpload(ctx context.Context, rec *uploadRecord, st pb.FileResponse_Status, err error) {
+	if r.audit == nil {
+		return
+	}
+	// The caller is recorded whether callers are authorized or not, a request
+	// without a valid ID token has no caller.
+	if caller, cErr := r.caller(ctx); cErr == nil {
+		rec.Caller = caller
+	}
+	rec.Severity = "INFO"
+	rec.Result = st.String()
+	if err != nil {
+		rec.Severity = "ERROR"
+		rec.Result = pb.FileResponse_FAIL.String()
+		rec.Error = err.Error()
+	}
+	r.audit.write(rec)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/metadata"
+)
+
+// testAuditLog returns an audit log to buf with a fixed clock.
+func testAuditLog(buf *bytes.Buffer) *auditLog {
+	a := newAuditLog(buf)
+	a.now = func() time.Time { return time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return a
+}
+
+// auditRecords decodes the audit records of buf.
+func auditRecords(t *testing.T, buf *bytes.Buffer) []*uploadRecord {
+	t.Helper()
+	var recs []*uploadRecord
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		rec := &uploadRecord{}
+		if err := dec.Decode(rec); err != nil {
+			t.Fatalf("failed to decode an audit record: %v", err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestAuditUpload(t *testing.T) {
+	var buf bytes.Buffer
+	r := rvServer{audit: testAuditLog(&buf), validate: fakeValidate}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer mirror"))
+
+	r.auditUpload(ctx, &uploadRecord{
+		RPC:        "FileUpload",
+		Filename:   "bar",
+		Project:    "ROUTEVIEWS",
+		Object:     "gs://foo/bar",
+		Size:       11,
+		MD5:        "50e3903156f5d2dac6c9f89626d48c75",
+		Generation: 42,
+	}, pb.FileResponse_SUCCESS, nil)
+	// A caller without an ID token, of an upload which failed.
+	r.auditUpload(context.Background(), &uploadRecord{
+		RPC:      "FileUploadStream",
+		Filename: "baz",
+	}, pb.FileResponse_SUCCESS, errors.New("checksum failure"))
+
+	when := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := []*uploadRecord{{
+		Severity:   "INFO",
+		Message:    auditMessage,
+		RPC:        "FileUpload",
+		Caller:     "mirror@example.iam.gserviceaccount.com",
+		Filename:   "bar",
+		Project:    "ROUTEVIEWS",
+		Object:     "gs://foo/bar",
+		Size:       11,
+		MD5:        "50e3903156f5d2dac6c9f89626d48c75",
+		Result:     pb.FileResponse_SUCCESS.String(),
+		Generation: 42,
+		Time:       when,
+	}, {
+		Severity: "ERROR",
+		Message:  auditMessage,
+		RPC:      "FileUploadStream",
+		Filename: "baz",
+		Result:   pb.FileResponse_FAIL.String(),
+		Error:    "checksum failure",
+		Time:     when,
+	}}
+	if diff := cmp.Diff(auditRecords(t, &buf), want); diff != "" {
+		t.Errorf("audit records diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestFileUploadAudit(t *testing.T) {
+	var buf bytes.Buffer
+	r := rvServer{conf: &config{}, audit: testAuditLog(&buf), validate: fakeValidate}
+	req := &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "abcdefg123456",
+		Content:  []byte("Foo Bar Baz"),
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}
+	if _, err := r.FileUpload(context.Background(), req); err == nil {
+		t.Fatal("FileUpload(bad checksum): nil err; want non-nil err")
+	}
+	recs := auditRecords(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("got %d audit records; want 1", len(recs))
+	}
+	if got := recs[0]; got.Filename != "bar" || got.Result != pb.FileResponse_FAIL.String() || got.Size != 11 {
+		t.Errorf("audit record = %+v; want a FAIL record of bar, size 11", got)
+	}
+}
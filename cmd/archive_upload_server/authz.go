@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// validateFunc validates an ID token, see idtoken.Validate.
+type validateFunc func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+
+// checkCallers checks the projects of the callers in the config are known.
+func checkCallers(callers map[string][]string) error {
+	for caller, projs := range callers {
+		for _, proj := range projs {
+			if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+				return fmt.Errorf("bad project %s of caller %s", proj, caller)
+			}
+		}
+	}
+	return nil
+}
+
+// caller returns the identity of the caller of a request: the email, or else
+// the subject, of the ID token in the authorization metadata. Cloud Run
+// passes the header along once it has checked the token may invoke the
+// service, the token is validated again as the server may run elsewhere.
+func (r rvServer) caller(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	for _, v := range md.Get("authorization") {
+		if strings.HasPrefix(v, "Bearer ") {
+			token = strings.TrimPrefix(v, "Bearer ")
+			break
+		}
+	}
+	if token == "" {
+		return "", status.Error(codes.Unauthenticated, "no ID token in the request")
+	}
+	validate := r.validate
+	if validate == nil {
+		validate = idtoken.Validate
+	}
+	payload, err := validate(ctx, token, r.audience)
+	if err != nil {
+		return "", status.Errorf(codes.Unauthenticated, "invalid ID token: %v", err)
+	}
+	if email, ok := payload.Claims["email"].(string); ok && email != "" {
+		return email, nil
+	}
+	return payload.Subject, nil
+}
+
+// authorize checks that the caller of a request may upload to the project.
+// Without callers in the config every caller may upload to every project.
+func (r rvServer) authorize(ctx context.Context, proj string) error {
+	if len(r.config().Callers) == 0 {
+		return nil
+	}
+	caller, err := r.caller(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range r.config().Callers[caller] {
+		if p == proj {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "caller %s may not upload to %s", caller, proj)
+}
+
+// authorizeAdmin checks that the caller of a request is an admin of the
+// project, which may delete or replace its objects. Without admins in the
+// config no caller may, unlike uploads.
+func (r rvServer) authorizeAdmin(ctx context.Context, proj string) error {
+	caller, err := r.caller(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range r.config().Admins[caller] {
+		if p == proj {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "caller %s is not an admin of %s", caller, proj)
+}
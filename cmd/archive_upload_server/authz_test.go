@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeValidate accepts the tokens "mirror" and "robot", the former carries an
+// email and the latter only a subject.
+func fakeValidate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	switch token {
+	case "mirror":
+		return &idtoken.Payload{
+			Subject: "1234",
+			Claims:  map[string]interface{}{"email": "mirror@example.iam.gserviceaccount.com"},
+		}, nil
+	case "robot":
+		return &idtoken.Payload{Subject: "5678"}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestAuthorize(t *testing.T) {
+	callers := map[string][]string{
+		"mirror@example.iam.gserviceaccount.com": {"ROUTEVIEWS", "ROUTEVIEWS_RIB"},
+		"5678":                                   {"RPKI_RARC"},
+	}
+	tests := []struct {
+		desc    string
+		callers map[string][]string
+		auth    string
+		proj    string
+		want    codes.Code
+	}{{
+		desc: "no callers, every caller is allowed",
+		proj: "ROUTEVIEWS",
+		want: codes.OK,
+	}, {
+		desc:    "caller allowed by email",
+		callers: callers,
+		auth:    "Bearer mirror",
+		proj:    "ROUTEVIEWS_RIB",
+		want:    codes.OK,
+	}, {
+		desc:    "caller allowed by subject",
+		callers: callers,
+		auth:    "Bearer robot",
+		proj:    "RPKI_RARC",
+		want:    codes.OK,
+	}, {
+		desc:    "caller not allowed the project",
+		callers: callers,
+		auth:    "Bearer mirror",
+		proj:    "RPKI_RARC",
+		want:    codes.PermissionDenied,
+	}, {
+		desc:    "no token",
+		callers: callers,
+		proj:    "ROUTEVIEWS",
+		want:    codes.Unauthenticated,
+	}, {
+		desc:    "invalid token",
+		callers: callers,
+		auth:    "Bearer forged",
+		proj:    "ROUTEVIEWS",
+		want:    codes.Unauthenticated,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := rvServer{conf: &config{Callers: test.callers}, validate: fakeValidate}
+			ctx := context.Background()
+			if test.auth != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+			}
+			err := r.authorize(ctx, test.proj)
+			if got := status.Code(err); got != test.want {
+				t.Errorf("authorize() = %v; want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckCallers(t *testing.T) {
+	if err := checkCallers(map[string][]string{"mirror": {"ROUTEVIEWS"}}); err != nil {
+		t.Errorf("checkCallers() = %v; want nil err", err)
+	}
+	if err := checkCallers(map[string][]string{"mirror": {"NOT_A_PROJECT"}}); err == nil {
+		t.Error("checkCallers(unknown project): nil err; want non-nil err")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// logf logs a line, ie: glog.Infof.
+type logf func(format string, args ...interface{})
+
+// peerAddr returns the address of the peer of a call, unknown if it is not
+// known.
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// msgSize returns the size of a message of a call, zero if it is not a proto
+// message.
+func msgSize(m interface{}) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
+
+// logCall logs a call: its method, peer, the bytes of the messages it received
+// and sent, its latency and status.
+func logCall(log logf, ctx context.Context, method string, recv, sent int64, start time.Time, err error) {
+	log("Call %s peer(%s) recv(%d bytes) sent(%d bytes) latency(%v) status(%v)",
+		method, peerAddr(ctx), recv, sent, time.Since(start).Round(time.Millisecond), status.Code(err))
+}
+
+// callLogUnaryInterceptor logs each unary RPC, see logCall.
+func callLogUnaryInterceptor(log logf) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(log, ctx, info.FullMethod, msgSize(req), msgSize(resp), start, err)
+		return resp, err
+	}
+}
+
+// countingStream is a server stream which counts the bytes of the messages it
+// receives and sends.
+type countingStream struct {
+	grpc.ServerStream
+	recv, sent int64
+}
+
+func (s *countingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recv += msgSize(m)
+	}
+	return err
+}
+
+func (s *countingStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent += msgSize(m)
+	}
+	return err
+}
+
+// callLogStreamInterceptor logs each streaming RPC, see logCall, with the
+// bytes of all the messages of the stream.
+func callLogStreamInterceptor(log logf) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		cs := &countingStream{ServerStream: ss}
+		err := handler(srv, cs)
+		logCall(log, ss.Context(), info.FullMethod, cs.recv, cs.sent, start, err)
+		return err
+	}
+}
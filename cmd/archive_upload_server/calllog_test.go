@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServerStream is a server stream of a context, which receives and sends
+// nothing.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context    { return s.ctx }
+func (s *fakeServerStream) RecvMsg(m interface{}) error { return nil }
+func (s *fakeServerStream) SendMsg(m interface{}) error { return nil }
+
+func TestCallLogInterceptors(t *testing.T) {
+	var lines []string
+	log := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	unary := &grpc.UnaryServerInfo{FullMethod: "/rv.RV/ObjectStat"}
+	_, err := callLogUnaryInterceptor(log)(context.Background(), nil, unary, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("callLogUnaryInterceptor() got err %v, want %v", err, codes.NotFound)
+	}
+
+	stream := &grpc.StreamServerInfo{FullMethod: "/rv.RV/FileUploadStream"}
+	ss := &fakeServerStream{ctx: context.Background()}
+	err = callLogStreamInterceptor(log)(nil, ss, stream, func(srv interface{}, ss grpc.ServerStream) error {
+		if err := ss.RecvMsg(nil); err != nil {
+			return err
+		}
+		return ss.SendMsg(nil)
+	})
+	if err != nil {
+		t.Errorf("callLogStreamInterceptor() got err: %v", err)
+	}
+
+	want := []string{
+		"Call /rv.RV/ObjectStat peer(unknown) recv(0 bytes) sent(0 bytes)",
+		"Call /rv.RV/FileUploadStream peer(unknown) recv(0 bytes) sent(0 bytes)",
+	}
+	wantStatus := []string{"status(NotFound)", "status(OK)"}
+	if len(lines) != len(want) {
+		t.Fatalf("call log got lines %q, want %d lines", lines, len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) || !strings.HasSuffix(line, wantStatus[i]) {
+			t.Errorf("call log got line %q, want %q ... %q", line, want[i], wantStatus[i])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	storagetier "github.com/routeviews/google-cloud-storage/pkg/storage_tier"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// classRule sets the storage class of the files of a project captured longer
+// than an age ago, ie: historical RIBs. A rule without an age applies to every
+// file, files without a capture time in their filename match only those.
+type classRule struct {
+	OlderThan time.Duration `yaml:"older_than"`
+	Class     string
+}
+
+// checkStorageClasses checks the projects and storage classes of the rules in
+// the config are known.
+func checkStorageClasses(rules map[string][]*classRule) error {
+	for proj, rs := range rules {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of storage classes", proj)
+		}
+		for _, rule := range rs {
+			switch rule.Class {
+			case storagetier.Standard, storagetier.Nearline, storagetier.Coldline, storagetier.Archive:
+			default:
+				return fmt.Errorf("bad storage class %q of %s", rule.Class, proj)
+			}
+			if rule.OlderThan < 0 {
+				return fmt.Errorf("bad older_than(%v) of %s: must not be negative", rule.OlderThan, proj)
+			}
+		}
+	}
+	return nil
+}
+
+// storageClass returns the storage class of a file, of the first rule of its
+// project which matches, empty for the default class of the bucket.
+func (c *config) storageClass(proj pb.FileRequest_Project, fn string, now time.Time) string {
+	rules := c.StorageClasses[proj.String()]
+	if len(rules) == 0 {
+		return ""
+	}
+	var captured time.Time
+	if a, ok := archiveprofile.ParseProject(proj, fn); ok {
+		captured = a.Time
+	}
+	for _, rule := range rules {
+		if rule.OlderThan == 0 {
+			return rule.Class
+		}
+		if !captured.IsZero() && now.Sub(captured) > rule.OlderThan {
+			return rule.Class
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"gopkg.in/yaml.v2"
+)
+
+func TestStorageClass(t *testing.T) {
+	raw := []byte(`
+storage_classes:
+  ROUTEVIEWS_RIB:
+    - older_than: 8760h
+      class: COLDLINE
+    - older_than: 720h
+      class: NEARLINE
+  RPKI_RARC:
+    - class: NEARLINE
+`)
+	c := &config{}
+	if err := yaml.Unmarshal(raw, c); err != nil {
+		t.Fatalf("failed to parse the config: %v", err)
+	}
+	if err := checkStorageClasses(c.StorageClasses); err != nil {
+		t.Fatalf("checkStorageClasses() = %v; want nil err", err)
+	}
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc string
+		proj pb.FileRequest_Project
+		fn   string
+		want string
+	}{{
+		desc: "historical rib",
+		proj: pb.FileRequest_ROUTEVIEWS_RIB,
+		fn:   "route-views4/bgpdata/2020.01/RIBS/rib.20200109.1800.bz2",
+		want: "COLDLINE",
+	}, {
+		desc: "rib of last quarter",
+		proj: pb.FileRequest_ROUTEVIEWS_RIB,
+		fn:   "route-views4/bgpdata/2022.03/RIBS/rib.20220309.1800.bz2",
+		want: "NEARLINE",
+	}, {
+		desc: "recent rib",
+		proj: pb.FileRequest_ROUTEVIEWS_RIB,
+		fn:   "route-views4/bgpdata/2022.05/RIBS/rib.20220530.1800.bz2",
+	}, {
+		desc: "no capture time",
+		proj: pb.FileRequest_ROUTEVIEWS_RIB,
+		fn:   "route-views4/README",
+	}, {
+		desc: "rule without an age",
+		proj: pb.FileRequest_RPKI_RARC,
+		fn:   "2022/01/09/rpki-20220109T183000Z.tgz",
+		want: "NEARLINE",
+	}, {
+		desc: "project without rules",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		fn:   "route-views4/bgpdata/2020.01/UPDATES/updates.20200109.1830.bz2",
+	}}
+	for _, test := range tests {
+		if got := c.storageClass(test.proj, test.fn, now); got != test.want {
+			t.Errorf("%s: storageClass(%s) = %q; want %q", test.desc, test.fn, got, test.want)
+		}
+	}
+}
+
+func TestCheckStorageClasses(t *testing.T) {
+	tests := []struct {
+		desc    string
+		rules   map[string][]*classRule
+		wantErr bool
+	}{{
+		desc:  "no rules",
+		rules: nil,
+	}, {
+		desc:    "unknown project",
+		rules:   map[string][]*classRule{"ISOLARIO": {{Class: "NEARLINE"}}},
+		wantErr: true,
+	}, {
+		desc:    "unknown class",
+		rules:   map[string][]*classRule{"ROUTEVIEWS": {{Class: "GLACIER"}}},
+		wantErr: true,
+	}, {
+		desc:    "negative age",
+		rules:   map[string][]*classRule{"ROUTEVIEWS": {{OlderThan: -time.Hour, Class: "NEARLINE"}}},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		err := checkStorageClasses(test.rules)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("%s: checkStorageClasses() got nil err; want non-nil err", test.desc)
+		case !test.wantErr && err != nil:
+			t.Errorf("%s: checkStorageClasses() got err: %v; want nil err", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+)
+
+const (
+	// compositePrefix is the prefix of the temporary component objects of
+	// the parallel composite uploads, in the bucket of their project:
+	//
+	//	_composite/<object>.<time of the upload>/<part>
+	//
+	// The components carry no project source, the converter skips them, and
+	// they are deleted once the object is composed, or the upload fails.
+	compositePrefix = "_composite/"
+	// composeLimit is the most source objects of a compose request.
+	composeLimit = 32
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// composite writes the object of a streamed upload as parts: each part is
+// written as a component object as soon as it is received, in parallel with
+// the rest of the stream, and the object is composed of the components on
+// Close. A file of a single part is written by its storage.Writer, as if the
+// upload were not composite.
+//
+// The writer of the object carries the attributes of the object, and the
+// conditions of the write, which the composed object gets. The md5sum of the
+// content, verified by the server, is kept in its metadata, cloud-storage
+// records none for composite objects, and the composed object is verified
+// against the crc32c of the content. The rest of its metadata is set once it
+// is composed, see setStoredMeta.
+type composite struct {
+	r   rvServer
+	wc  *storage.Writer
+	ctx context.Context
+	// cancel aborts the writes of the parts.
+	cancel context.CancelFunc
+	bkt    string
+	obj    string
+	conds  storage.Conditions
+	md5sum string
+	prefix string
+	size   int
+	buf    []byte
+	crc    hash.Hash32
+	// parts are the components written, in order, temps every object to
+	// delete once the object is composed.
+	parts []string
+	temps []string
+	sem   chan struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	err   error
+	attrs *storage.ObjectAttrs
+	done  bool
+}
+
+// newComposite returns the composite writer of an object, of the writer of
+// the object and the conditions it was made with, and the md5sum of the
+// content. A part size of zero writes the object with wc only.
+func (r rvServer) newComposite(ctx context.Context, wc *storage.Writer, bkt, obj string, conds storage.Conditions, md5sum string) *composite {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &composite{
+		r:      r,
+		wc:     wc,
+		ctx:    ctx,
+		cancel: cancel,
+		bkt:    bkt,
+		obj:    obj,
+		conds:  conds,
+		md5sum: md5sum,
+		prefix: compositePrefix + obj + "." + time.Now().UTC().Format("20060102T150405.000000000Z") + "/",
+		size:   r.partSize,
+		crc:    crc32.New(castagnoli),
+	}
+	if c.size > 0 {
+		parallel := r.partParallelism
+		if parallel < 1 {
+			parallel = 1
+		}
+		c.sem = make(chan struct{}, parallel)
+	}
+	return c
+}
+
+// Write buffers the content, and writes each full part as a component. It
+// fails once the write of a part failed.
+func (c *composite) Write(b []byte) (int, error) {
+	if c.size <= 0 {
+		return c.wc.Write(b)
+	}
+	if err := c.failed(); err != nil {
+		return 0, err
+	}
+	n := len(b)
+	c.crc.Write(b)
+	for len(b) > 0 {
+		m := c.size - len(c.buf)
+		if m > len(b) {
+			m = len(b)
+		}
+		c.buf = append(c.buf, b[:m]...)
+		b = b[m:]
+		if len(c.buf) == c.size {
+			c.flush()
+		}
+	}
+	return n, nil
+}
+
+// flush writes the buffered part as the next component, it waits for a slot
+// of the parallel writes.
+func (c *composite) flush() {
+	name := fmt.Sprintf("%s%05d", c.prefix, len(c.parts))
+	c.parts = append(c.parts, name)
+	c.temps = append(c.temps, name)
+	b := c.buf
+	c.buf = make([]byte, 0, c.size)
+	c.sem <- struct{}{}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() { <-c.sem }()
+		if err := c.writePart(name, b); err != nil {
+			c.fail(err)
+		}
+	}()
+}
+
+// writePart writes a component, verified by cloud-storage against its crc32c,
+// encrypted as the object is.
+func (c *composite) writePart(name string, b []byte) error {
+	w := c.r.object(c.bkt, name).NewWriter(c.ctx)
+	w.KMSKeyName = c.wc.KMSKeyName
+	w.CRC32C = crc32.Checksum(b, castagnoli)
+	w.SendCRC32C = true
+	if _, err := w.Write(b); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (c *composite) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		// The parts in flight are aborted, the upload fails.
+		c.cancel()
+	}
+}
+
+func (c *composite) failed() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close commits the object: it composes the object of the components, or
+// writes a file of a single part with wc. The components are deleted.
+func (c *composite) Close() error {
+	if c.size <= 0 {
+		return c.wc.Close()
+	}
+	c.done = true
+	if len(c.parts) == 0 {
+		if _, err := c.wc.Write(c.buf); err != nil {
+			c.wc.Close()
+			return err
+		}
+		if err := c.wc.Close(); err != nil {
+			return err
+		}
+		c.attrs = c.wc.Attrs()
+		return nil
+	}
+	if len(c.buf) > 0 {
+		c.flush()
+	}
+	c.wg.Wait()
+	defer c.cleanup()
+	if err := c.failed(); err != nil {
+		return err
+	}
+	attrs, err := c.compose()
+	if err != nil {
+		return err
+	}
+	c.attrs = attrs
+	glog.Infof("Composed gs://%s/%s of %d parts", c.bkt, c.obj, len(c.parts))
+	return nil
+}
+
+// compose composes the object of the components, through intermediate
+// objects of composeLimit components each while there are more.
+func (c *composite) compose() (*storage.ObjectAttrs, error) {
+	srcs := c.parts
+	for level := 1; len(srcs) > composeLimit; level++ {
+		var next []string
+		for i := 0; i < len(srcs); i += composeLimit {
+			j := i + composeLimit
+			if j > len(srcs) {
+				j = len(srcs)
+			}
+			name := fmt.Sprintf("%slevel%d-%05d", c.prefix, level, len(next))
+			comp := c.r.object(c.bkt, name).ComposerFrom(c.handles(srcs[i:j])...)
+			comp.KMSKeyName = c.wc.KMSKeyName
+			c.temps = append(c.temps, name)
+			if _, err := comp.Run(c.ctx); err != nil {
+				return nil, err
+			}
+			next = append(next, name)
+		}
+		srcs = next
+	}
+	comp := c.r.object(c.bkt, c.obj).If(c.conds).ComposerFrom(c.handles(srcs)...)
+	comp.ContentType, comp.ContentEncoding = c.wc.ContentType, c.wc.ContentEncoding
+	comp.StorageClass, comp.KMSKeyName = c.wc.StorageClass, c.wc.KMSKeyName
+	comp.EventBasedHold, comp.TemporaryHold = c.wc.EventBasedHold, c.wc.TemporaryHold
+	comp.Metadata = map[string]string{uploadutils.MD5MetadataKey: c.md5sum}
+	comp.CRC32C, comp.SendCRC32C = c.crc.Sum32(), true
+	return comp.Run(c.ctx)
+}
+
+func (c *composite) handles(names []string) []*storage.ObjectHandle {
+	hs := make([]*storage.ObjectHandle, len(names))
+	for i, name := range names {
+		hs[i] = c.r.sc.Bucket(c.bkt).Object(name)
+	}
+	return hs
+}
+
+// Attrs returns the attributes of the object, once it is committed.
+func (c *composite) Attrs() *storage.ObjectAttrs {
+	if c.size <= 0 {
+		return c.wc.Attrs()
+	}
+	return c.attrs
+}
+
+// abort aborts an upload which was not committed, and deletes its
+// components.
+func (c *composite) abort() {
+	c.cancel()
+	if c.size <= 0 || c.done {
+		return
+	}
+	c.wg.Wait()
+	c.cleanup()
+}
+
+// cleanup deletes the components, and the intermediate objects, past the
+// deadline of the request. A failure is logged, the objects are left to a
+// lifecycle rule of the bucket.
+func (c *composite) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, name := range c.temps {
+		c.sem <- struct{}{}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-c.sem }()
+			if err := c.r.object(c.bkt, name).Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+				glog.Errorf("failed to delete component gs://%s/%s: %v", c.bkt, name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+	c.temps = nil
+}
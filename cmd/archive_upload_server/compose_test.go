@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+func TestCompositeUpload(t *testing.T) {
+	tests := []struct {
+		desc     string
+		content  string
+		partSize int
+		// composed is whether the object is composed, rather than written
+		// as a single part.
+		composed bool
+	}{{
+		desc:     "single part",
+		content:  "Foo Bar Baz",
+		partSize: 64,
+	}, {
+		desc:     "parts",
+		content:  "Foo Bar Baz",
+		partSize: 4,
+		composed: true,
+	}, {
+		desc:     "intermediate objects",
+		content:  strings.Repeat("Foo Bar Baz ", 6),
+		partSize: 2,
+		composed: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			srv := fakestorage.NewServer(nil)
+			defer srv.Stop()
+			srv.CreateBucket("foo")
+			conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+			cli, events := gcsEventsClient(t, srv)
+			fs, err := newRVServer(context.Background(), createConf(t, conf), cli, nil)
+			if err != nil {
+				t.Fatalf("failed initialzing server: %v", err)
+			}
+			fs.partSize, fs.partParallelism = test.partSize, 2
+
+			sum := md5.Sum([]byte(test.content))
+			chunks := []*pb.FileChunk{{Metadata: &pb.FileRequest{Filename: "bar", Md5Sum: hex.EncodeToString(sum[:]), Project: pb.FileRequest_ROUTEVIEWS}}}
+			for i := 0; i < len(test.content); i += 5 {
+				j := i + 5
+				if j > len(test.content) {
+					j = len(test.content)
+				}
+				chunks = append(chunks, &pb.FileChunk{Content: []byte(test.content[i:j])})
+			}
+			stream := &uploadStream{chunks: chunks}
+			if err := fs.FileUploadStream(stream); err != nil {
+				t.Fatalf("FileUploadStream() got err: %v", err)
+			}
+			if got := stream.resp.GetStatus(); got != pb.FileResponse_SUCCESS {
+				t.Errorf("FileUploadStream() status = %v; want SUCCESS", got)
+			}
+			if got, want := stream.resp.GetMd5Sum(), hex.EncodeToString(sum[:]); got != want {
+				t.Errorf("FileUploadStream() md5sum = %q; want %q", got, want)
+			}
+
+			obj, err := srv.GetObject("foo", "bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(obj.Content); got != test.content {
+				t.Errorf("stored content = %q; want %q", got, test.content)
+			}
+			if got := obj.ObjectAttrs.Metadata[converter.ProjectMetadataKey]; got != pb.FileRequest_ROUTEVIEWS.String() {
+				t.Errorf("got metadata %s=%s; want %s", converter.ProjectMetadataKey, got, pb.FileRequest_ROUTEVIEWS.String())
+			}
+			if !events.converts("foo", "bar") {
+				t.Error("FileUploadStream() updated no metadata of the object; want the update which triggers its conversion")
+			}
+			_, composed := obj.ObjectAttrs.Metadata[uploadutils.MD5MetadataKey]
+			if composed != test.composed {
+				t.Errorf("got metadata %s of a composed object: %v; want %v", uploadutils.MD5MetadataKey, composed, test.composed)
+			}
+			objs, _, err := srv.ListObjectsWithOptions("foo", fakestorage.ListOptions{Prefix: compositePrefix})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objs) > 0 {
+				t.Errorf("got %d components left; want none", len(objs))
+			}
+		})
+	}
+}
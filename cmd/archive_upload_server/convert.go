@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// convertTimeout bounds the conversion of an archive before the response, a
+// conversion beyond it is left to the notification of the object.
+const convertTimeout = 3 * time.Minute
+
+// convertFunc converts the archive of an object for BigQuery, and returns the
+// converted object, gs://<bucket>/<object>.
+type convertFunc func(ctx context.Context, bkt, obj string) (string, error)
+
+// newConverter returns a convertFunc which converts archives to the BigQuery
+// bucket, as the converter does on the notifications of the objects.
+func newConverter(sc *storage.Client, dst string) convertFunc {
+	return func(ctx context.Context, bkt, obj string) (string, error) {
+		if err := converter.ProcessMRTArchive(ctx, sc, &converter.Config{
+			SrcBucket: bkt,
+			SrcObject: obj,
+			DstBucket: dst,
+		}); err != nil {
+			return "", err
+		}
+		return "gs://" + dst + "/" + converter.ConvertedName(obj), nil
+	}
+}
+
+// checkConvertNow checks the projects of the config which are converted
+// before the response.
+func checkConvertNow(c *config) error {
+	for _, proj := range c.ConvertNow {
+		if !convertible(pb.FileRequest_Project(pb.FileRequest_Project_value[proj])) {
+			return fmt.Errorf("bad project %s of convert_now, want ROUTEVIEWS or RIPE_RIS", proj)
+		}
+	}
+	return nil
+}
+
+// convertible reports whether the converter converts the archives of a
+// project.
+func convertible(proj pb.FileRequest_Project) bool {
+	return proj == pb.FileRequest_ROUTEVIEWS || proj == pb.FileRequest_RIPE_RIS
+}
+
+// convertsNow reports whether the archive of an upload is converted before
+// the response: if the request sets convert_now, or its project is in the
+// convert_now of the config.
+func (r rvServer) convertsNow(meta *pb.FileRequest) bool {
+	if meta.GetConvertNow() {
+		return true
+	}
+	for _, proj := range r.config().ConvertNow {
+		if pb.FileRequest_Project_value[proj] == int32(meta.GetProject()) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertNow converts the stored archive of an upload for BigQuery, if
+// convertsNow, and returns the status of the conversion, nil if it is not
+// converted now. Updates archives are converted, other files are skipped. A
+// failure does not fail the upload, the notification of the object converts
+// it later.
+func (r rvServer) convertNow(ctx context.Context, meta *pb.FileRequest, attrs *storage.ObjectAttrs) *pb.Conversion {
+	if !r.convertsNow(meta) || attrs == nil {
+		return nil
+	}
+	fn := meta.GetFilename()
+	if r.convert == nil {
+		return &pb.Conversion{Status: pb.Conversion_SKIPPED, ErrorMessage: "the server does not convert archives"}
+	}
+	// The RIB dumps, ie: the RIPE RIS bview dumps, take too long to convert
+	// before the response, they are left to their notifications.
+	_, ok := archiveprofile.ParseProject(meta.GetProject(), fn)
+	if !ok || !convertible(meta.GetProject()) || !strings.HasPrefix(path.Base(fn), "updates.") {
+		return &pb.Conversion{Status: pb.Conversion_SKIPPED, ErrorMessage: fmt.Sprintf("%s is not an updates archive of %s", fn, meta.GetProject())}
+	}
+	defer r.stats.converting()()
+	ctx, span := tracer.Start(ctx, "convert", objectAttrs(attrs.Bucket, attrs.Name))
+	ctx, cancel := context.WithTimeout(ctx, convertTimeout)
+	defer cancel()
+	obj, err := r.convert(ctx, attrs.Bucket, attrs.Name)
+	endSpan(span, err)
+	if err != nil {
+		glog.Errorf("failed to convert gs://%s/%s, left to its notification: %v", attrs.Bucket, attrs.Name, err)
+		return &pb.Conversion{Status: pb.Conversion_FAILED, ErrorMessage: err.Error()}
+	}
+	glog.Infof("Converted gs://%s/%s to %s", attrs.Bucket, attrs.Name, obj)
+	return &pb.Conversion{Status: pb.Conversion_CONVERTED, Object: obj}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestConvertNow(t *testing.T) {
+	const updates = "route-views4/bgpdata/2021.12/UPDATES/updates.20211212.0015.bz2"
+	attrs := &storage.ObjectAttrs{Bucket: "foo", Name: updates}
+	converted := func(ctx context.Context, bkt, obj string) (string, error) {
+		return "gs://bar/" + obj, nil
+	}
+	failed := func(ctx context.Context, bkt, obj string) (string, error) {
+		return "", errors.New("conversion failed")
+	}
+	tests := []struct {
+		desc    string
+		conf    *config
+		convert convertFunc
+		req     *pb.FileRequest
+		want    *pb.Conversion
+	}{{
+		desc:    "not requested",
+		conf:    &config{},
+		convert: converted,
+		req:     &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Filename: updates},
+	}, {
+		desc:    "requested",
+		conf:    &config{},
+		convert: converted,
+		req:     &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Filename: updates, ConvertNow: true},
+		want:    &pb.Conversion{Status: pb.Conversion_CONVERTED, Object: "gs://bar/" + updates},
+	}, {
+		desc:    "project of the config",
+		conf:    &config{ConvertNow: []string{"ROUTEVIEWS"}},
+		convert: converted,
+		req:     &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Filename: updates},
+		want:    &pb.Conversion{Status: pb.Conversion_CONVERTED, Object: "gs://bar/" + updates},
+	}, {
+		desc:    "failed",
+		conf:    &config{},
+		convert: failed,
+		req:     &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Filename: updates, ConvertNow: true},
+		want:    &pb.Conversion{Status: pb.Conversion_FAILED, ErrorMessage: "conversion failed"},
+	}, {
+		desc: "disabled",
+		conf: &config{},
+		req:  &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Filename: updates, ConvertNow: true},
+		want: &pb.Conversion{Status: pb.Conversion_SKIPPED},
+	}, {
+		desc:    "not an updates archive",
+		conf:    &config{},
+		convert: converted,
+		req:     &pb.FileRequest{Project: pb.FileRequest_RIPE_RIS, Filename: "rrc00/2022.01/bview.20220109.1600.gz", ConvertNow: true},
+		want:    &pb.Conversion{Status: pb.Conversion_SKIPPED},
+	}, {
+		desc:    "not converted project",
+		conf:    &config{},
+		convert: converted,
+		req:     &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "rpki/2022/01/09/output.tgz", ConvertNow: true},
+		want:    &pb.Conversion{Status: pb.Conversion_SKIPPED},
+	}}
+	for _, test := range tests {
+		r := rvServer{conf: test.conf, convert: test.convert}
+		got := r.convertNow(context.Background(), test.req, attrs)
+		// The reason of a skipped conversion is set, but not compared.
+		if got.GetStatus() == pb.Conversion_SKIPPED {
+			if got.GetErrorMessage() == "" {
+				t.Errorf("%s: convertNow() = %v, want SKIPPED with an error message", test.desc, got)
+			}
+			got.ErrorMessage = ""
+		}
+		if diff := cmp.Diff(got, test.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: convertNow() got diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestCheckConvertNow(t *testing.T) {
+	if err := checkConvertNow(&config{ConvertNow: []string{"ROUTEVIEWS", "RIPE_RIS"}}); err != nil {
+		t.Errorf("checkConvertNow(ROUTEVIEWS, RIPE_RIS) got err: %v; want nil err", err)
+	}
+	if err := checkConvertNow(&config{ConvertNow: []string{"RPKI_RARC"}}); err == nil {
+		t.Error("checkConvertNow(RPKI_RARC) got nil err, want err")
+	}
+}
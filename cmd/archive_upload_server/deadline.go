@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+)
+
+const (
+	// cleanupReserve is the time kept from the deadline of a request for the
+	// cleanup of a stage which timed out, and the response.
+	cleanupReserve = 10 * time.Second
+	// cleanupTimeout bounds the deletion of a partial object, past the
+	// deadline of its request.
+	cleanupTimeout = 30 * time.Second
+)
+
+// withDeadline returns the context of a request, with the default timeout if
+// the caller set no deadline. A zero timeout leaves the request unbounded.
+func withDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// deadlineUnaryInterceptor sets the default deadline, see withDeadline, of the
+// unary RPCs.
+func deadlineUnaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel := withDeadline(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// deadlineStream is a server stream with the context of withDeadline.
+type deadlineStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *deadlineStream) Context() context.Context {
+	return s.ctx
+}
+
+// deadlineStreamInterceptor sets the default deadline, see withDeadline, of the
+// streaming RPCs.
+func deadlineStreamInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withDeadline(ss.Context(), timeout)
+		defer cancel()
+		return handler(srv, &deadlineStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// stageContext returns the context of a stage of a request, ie: a storage
+// write, which expires after max, zero is unbounded, and cleanupReserve before
+// the deadline of the request, for the request to clean up and respond once
+// the stage times out. A request with less than twice the reserve left gives
+// half of it to the stage.
+func stageContext(ctx context.Context, max time.Duration) (context.Context, context.CancelFunc) {
+	var deadline time.Time
+	if max > 0 {
+		deadline = time.Now().Add(max)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		left := time.Until(d)
+		end := d.Add(-cleanupReserve)
+		if left < 2*cleanupReserve {
+			end = time.Now().Add(left / 2)
+		}
+		if deadline.IsZero() || end.Before(deadline) {
+			deadline = end
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// deletePartial deletes the generation of an object stored by a request which
+// failed after, ie: its metadata was not set before the deadline, for a retry
+// of the request to store it whole rather than find it unchanged. It runs past
+// the deadline of the request, and a failure is logged.
+func (r rvServer) deletePartial(bkt, obj string, gen int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	err := r.sc.Bucket(bkt).Object(obj).If(storage.Conditions{GenerationMatch: gen}).Delete(ctx)
+	if err != nil && err != storage.ErrObjectNotExist {
+		glog.Errorf("failed to delete partial object gs://%s/%s#%d: %v", bkt, obj, gen, err)
+		return
+	}
+	glog.Infof("Deleted partial object gs://%s/%s#%d", bkt, obj, gen)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+	ctx, cancel := withDeadline(context.Background(), time.Hour)
+	defer cancel()
+	d, ok := ctx.Deadline()
+	if !ok || time.Until(d) > time.Hour || time.Until(d) < 59*time.Minute {
+		t.Errorf("withDeadline(no deadline, 1h) got deadline(%v, %v), want in 1h", d, ok)
+	}
+
+	caller, cancelCaller := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelCaller()
+	ctx, cancel = withDeadline(caller, time.Hour)
+	defer cancel()
+	if got, _ := ctx.Deadline(); time.Until(got) > time.Minute {
+		t.Errorf("withDeadline(1m deadline, 1h) got deadline in %v, want the deadline of the caller", time.Until(got))
+	}
+
+	ctx, cancel = withDeadline(context.Background(), 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("withDeadline(no deadline, 0) got a deadline, want none")
+	}
+}
+
+func TestStageContext(t *testing.T) {
+	tests := []struct {
+		desc     string
+		deadline time.Duration
+		max      time.Duration
+		// want is the time to the deadline of the stage, zero is none.
+		want time.Duration
+	}{{
+		desc: "unbounded",
+	}, {
+		desc: "max only",
+		max:  5 * time.Minute,
+		want: 5 * time.Minute,
+	}, {
+		desc:     "deadline of the request only",
+		deadline: time.Hour,
+		want:     time.Hour - cleanupReserve,
+	}, {
+		desc:     "max before the deadline",
+		deadline: time.Hour,
+		max:      5 * time.Minute,
+		want:     5 * time.Minute,
+	}, {
+		desc:     "deadline before the max",
+		deadline: time.Minute,
+		max:      5 * time.Minute,
+		want:     time.Minute - cleanupReserve,
+	}, {
+		desc:     "deadline within the reserve",
+		deadline: 10 * time.Second,
+		max:      5 * time.Minute,
+		want:     5 * time.Second,
+	}}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.deadline > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.deadline)
+			defer cancel()
+		}
+		sctx, cancel := stageContext(ctx, test.max)
+		defer cancel()
+		d, ok := sctx.Deadline()
+		switch {
+		case test.want == 0 && ok:
+			t.Errorf("%s: stageContext() got deadline in %v, want none", test.desc, time.Until(d))
+		case test.want > 0 && !ok:
+			t.Errorf("%s: stageContext() got no deadline, want in %v", test.desc, test.want)
+		case test.want > 0 && (time.Until(d) > test.want || time.Until(d) < test.want-time.Second):
+			t.Errorf("%s: stageContext() got deadline in %v, want in %v", test.desc, time.Until(d), test.want)
+		}
+	}
+}
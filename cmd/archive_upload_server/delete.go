@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeleteObject deletes the object of a corrupt file. Only the admins of the
+// project may, and each delete is audited with its reason. The filename is
+// checked as that of an upload, see checkFilename, so the objects the server
+// keeps under its reserved prefixes, ie: _audit/, are never deleted. The
+// generation looked up is the one deleted, so an object replaced meanwhile is
+// kept.
+func (r rvServer) DeleteObject(ctx context.Context, req *pb.DeleteObjectRequest) (resp *pb.DeleteObjectResponse, err error) {
+	if err := r.needsGCS("DeleteObject"); err != nil {
+		return nil, err
+	}
+	rec := &uploadRecord{
+		RPC:      "DeleteObject",
+		Filename: req.GetFilename(),
+		Project:  req.GetProject().String(),
+		Reason:   req.GetReason(),
+	}
+	defer func() { r.auditUpload(ctx, rec, pb.FileResponse_SUCCESS, err) }()
+
+	proj := req.GetProject()
+	fn := req.GetFilename()
+	if err := requireFields("DeleteObjectRequest",
+		field{"filename", len(fn) > 0},
+		field{"project", proj != pb.FileRequest_UNKNOWN},
+		field{"reason", strings.TrimSpace(req.GetReason()) != ""}); err != nil {
+		return nil, err
+	}
+	fn, err = r.checkFilename(proj, fn)
+	if err != nil {
+		return nil, err
+	}
+	rec.Filename = fn
+	if err := r.authorizeAdmin(ctx, proj.String()); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, 0); err != nil {
+		return nil, err
+	}
+	bkt, prefix, ok := r.config().route(proj.String())
+	if !ok {
+		return nil, unsupportedProject("project", proj)
+	}
+	obj := prefix + fn
+	rec.Object = "gs://" + bkt + "/" + obj
+
+	o := r.sc.Bucket(bkt).Object(obj)
+	actx, span := tracer.Start(ctx, "gcs.attrs", objectAttrs(bkt, obj))
+	attrs, err := o.Attrs(actx)
+	span.End()
+	switch {
+	case err == storage.ErrObjectNotExist:
+		return nil, status.Errorf(codes.NotFound, "%s does not exist", fn)
+	case err != nil:
+		return nil, storageError(err, "failed to get the attributes of %s", fn)
+	}
+	rec.Size = attrs.Size
+	rec.MD5 = uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	rec.Generation = attrs.Generation
+	if gen := req.GetGeneration(); gen != 0 && gen != attrs.Generation {
+		return nil, status.Errorf(codes.FailedPrecondition, "generation(%d) of %s is not the current generation(%d)", gen, fn, attrs.Generation)
+	}
+
+	// The precondition makes the delete safe to retry.
+	dctx, span := tracer.Start(ctx, "gcs.delete", objectAttrs(bkt, obj))
+	err = o.If(storage.Conditions{GenerationMatch: attrs.Generation}).Delete(dctx)
+	endSpan(span, err)
+	if err != nil {
+		return nil, storageError(err, "failed to delete %s", fn)
+	}
+	r.recent.drop(bkt, obj)
+	glog.Warningf("Deleted %s generation(%d) of %s: %s", rec.Object, attrs.Generation, proj, req.GetReason())
+	return &pb.DeleteObjectResponse{Generation: attrs.Generation}, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorizeAdmin(t *testing.T) {
+	admins := map[string][]string{"5678": {"RPKI_RARC"}}
+	tests := []struct {
+		desc   string
+		admins map[string][]string
+		auth   string
+		proj   string
+		want   codes.Code
+	}{{
+		desc: "no admins, no caller is allowed",
+		auth: "Bearer robot",
+		proj: "RPKI_RARC",
+		want: codes.PermissionDenied,
+	}, {
+		desc:   "admin of the project",
+		admins: admins,
+		auth:   "Bearer robot",
+		proj:   "RPKI_RARC",
+		want:   codes.OK,
+	}, {
+		desc:   "admin of another project",
+		admins: admins,
+		auth:   "Bearer robot",
+		proj:   "ROUTEVIEWS",
+		want:   codes.PermissionDenied,
+	}, {
+		desc:   "not an admin",
+		admins: admins,
+		auth:   "Bearer mirror",
+		proj:   "RPKI_RARC",
+		want:   codes.PermissionDenied,
+	}, {
+		desc:   "no token",
+		admins: admins,
+		proj:   "RPKI_RARC",
+		want:   codes.Unauthenticated,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := rvServer{conf: &config{Admins: test.admins}, validate: fakeValidate}
+			ctx := context.Background()
+			if test.auth != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+			}
+			err := r.authorizeAdmin(ctx, test.proj)
+			if got := status.Code(err); got != test.want {
+				t.Errorf("authorizeAdmin() = %v; want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestDeleteObjectRejected(t *testing.T) {
+	var buf bytes.Buffer
+	r := rvServer{
+		conf:     &config{Admins: map[string][]string{"5678": {"RPKI_RARC"}}},
+		audit:    testAuditLog(&buf),
+		validate: fakeValidate,
+	}
+	tests := []struct {
+		desc string
+		auth string
+		req  *pb.DeleteObjectRequest
+		want codes.Code
+	}{{
+		desc: "no reason",
+		auth: "Bearer robot",
+		req:  &pb.DeleteObjectRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar"},
+		want: codes.InvalidArgument,
+	}, {
+		desc: "not an admin",
+		auth: "Bearer mirror",
+		req:  &pb.DeleteObjectRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Reason: "corrupt"},
+		want: codes.PermissionDenied,
+	}}
+	for _, test := range tests {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", test.auth))
+		if _, err := r.DeleteObject(ctx, test.req); status.Code(err) != test.want {
+			t.Errorf("%s: DeleteObject() = %v; want %v", test.desc, err, test.want)
+		}
+	}
+	recs := auditRecords(t, &buf)
+	if len(recs) != len(tests) {
+		t.Fatalf("got %d audit records; want %d", len(recs), len(tests))
+	}
+	for _, rec := range recs {
+		if rec.RPC != "DeleteObject" || rec.Result != pb.FileResponse_FAIL.String() {
+			t.Errorf("audit record = %+v; want a FAIL record of DeleteObject", rec)
+		}
+	}
+}
+
+func TestDeleteObject(t *testing.T) {
+	srv := fakestorage.NewServer([]fakestorage.Object{{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: "rarc/bar"},
+		Content:     []byte("Foo Bar Baz"),
+	}, {
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: "rarc/_audit/20220101.log"},
+		Content:     []byte("{}"),
+	}})
+	defer srv.Stop()
+	obj, err := srv.GetObject("foo", "rarc/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	r := rvServer{
+		conf: &config{
+			Routes: map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}},
+			Admins: map[string][]string{"5678": {"RPKI_RARC"}},
+		},
+		sc:       srv.Client(),
+		audit:    testAuditLog(&buf),
+		validate: fakeValidate,
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer robot"))
+
+	req := &pb.DeleteObjectRequest{
+		Project:    pb.FileRequest_RPKI_RARC,
+		Filename:   "bar",
+		Reason:     "truncated upload",
+		Generation: obj.Generation + 1,
+	}
+	if _, err := r.DeleteObject(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeleteObject(other generation) = %v; want FailedPrecondition", err)
+	}
+	req.Generation = obj.Generation
+	resp, err := r.DeleteObject(ctx, req)
+	if err != nil || resp.GetGeneration() != obj.Generation {
+		t.Fatalf("DeleteObject() = %v, %v; want generation %d", resp, err, obj.Generation)
+	}
+	if _, err := srv.GetObject("foo", "rarc/bar"); err == nil {
+		t.Error("object exists after DeleteObject(); want it deleted")
+	}
+	if _, err := r.DeleteObject(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("DeleteObject(deleted) = %v; want NotFound", err)
+	}
+	reserved := &pb.DeleteObjectRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "/_audit/20220101.log", Reason: "corrupt"}
+	if _, err := r.DeleteObject(ctx, reserved); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("DeleteObject(reserved) = %v; want InvalidArgument", err)
+	}
+	if _, err := srv.GetObject("foo", "rarc/_audit/20220101.log"); err != nil {
+		t.Errorf("reserved object got err %v after DeleteObject(); want it kept", err)
+	}
+
+	recs := auditRecords(t, &buf)
+	if len(recs) != 4 {
+		t.Fatalf("got %d audit records; want 4", len(recs))
+	}
+	if got := recs[1]; got.Result != pb.FileResponse_SUCCESS.String() || got.Reason != "truncated upload" || got.Object != "gs://foo/rarc/bar" || got.Caller != "5678" {
+		t.Errorf("audit record = %+v; want a SUCCESS record of gs://foo/rarc/bar by 5678, with its reason", got)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo details of the errors of the
+// server.
+const errorDomain = "archive.routeviews.org"
+
+// The reasons of the ErrorInfo details of the errors of the server, clients
+// branch on the reason rather than the message, which may change.
+const (
+	reasonMissingField       = "MISSING_FIELD"
+	reasonInvalidField       = "INVALID_FIELD"
+	reasonUnsupportedProject = "UNSUPPORTED_PROJECT"
+	reasonInvalidChecksum    = "INVALID_CHECKSUM"
+	reasonChecksumMismatch   = "CHECKSUM_MISMATCH"
+	reasonObjectExists       = "OBJECT_EXISTS"
+	reasonWrittenMeanwhile   = "WRITTEN_MEANWHILE"
+	reasonGenerationMismatch = "GENERATION_MISMATCH"
+	reasonOffsetMismatch     = "OFFSET_MISMATCH"
+	reasonFileTooLarge       = "FILE_TOO_LARGE"
+	reasonNamingPolicy       = "NAMING_POLICY"
+	reasonStorageUnavailable = "STORAGE_UNAVAILABLE"
+	reasonStorageDegraded    = "STORAGE_DEGRADED"
+)
+
+// withReason returns a status with the ErrorInfo of a reason. A detail which
+// fails to encode is logged, the status is returned without it.
+func withReason(st *status.Status, reason string, meta map[string]string) *status.Status {
+	d, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: meta})
+	if err != nil {
+		glog.Errorf("failed to add the ErrorInfo of %s: %v", reason, err)
+		return st
+	}
+	return d
+}
+
+// reasonOf returns the reason of the ErrorInfo of an error, empty if it has
+// none.
+func reasonOf(err error) string {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+// field is a field of a request, and whether it is set.
+type field struct {
+	name string
+	set  bool
+}
+
+// requireFields returns an InvalidArgument error naming the fields of a
+// request which are not set, in a BadRequest, or nil if all are set.
+func requireFields(req string, fields ...field) error {
+	var missing []string
+	br := &errdetails.BadRequest{}
+	for _, f := range fields {
+		if f.set {
+			continue
+		}
+		missing = append(missing, f.name)
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.name, Description: "required"})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	st := withReason(status.Newf(codes.InvalidArgument, "base requirements for %s unmet, missing %s", req, strings.Join(missing, ", ")),
+		reasonMissingField, map[string]string{"fields": strings.Join(missing, ",")})
+	if d, err := st.WithDetails(br); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// badField returns an InvalidArgument error of a field of a request, with the
+// ErrorInfo of the reason, and the field in a BadRequest.
+func badField(name, reason, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	st := withReason(status.New(codes.InvalidArgument, msg), reason, map[string]string{"field": name})
+	if d, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: name, Description: msg}},
+	}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// unsupportedProject returns the error of a request to a project which the
+// config neither routes nor stores.
+func unsupportedProject(name string, proj fmt.Stringer) error {
+	return badField(name, reasonUnsupportedProject, "%s is not supported", proj)
+}
+
+// invalidChecksum returns the error of a checksum which is not well formed.
+func invalidChecksum(name, kind, sum string) error {
+	return badField(name, reasonInvalidChecksum, "invalid %s(%q)", kind, sum)
+}
+
+// fileTooLarge returns the InvalidArgument error of a file of a project which
+// exceeds the max size of the project, the size and the limit are in the
+// ErrorInfo, and the field in a BadRequest.
+func fileTooLarge(name string, proj fmt.Stringer, size, limit int64) error {
+	msg := fmt.Sprintf("file of %d bytes exceeds the max size(%d bytes) of %s", size, limit, proj)
+	st := withReason(status.New(codes.InvalidArgument, msg), reasonFileTooLarge, map[string]string{
+		"field":    name,
+		"size":     strconv.FormatInt(size, 10),
+		"max_size": strconv.FormatInt(limit, 10),
+	})
+	if d, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: name, Description: msg}},
+	}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// checkSum returns a FailedPrecondition error if the checksum of a kind
+// calculated of the content is not the checksum of the request, unless the
+// request has none. The kind and both checksums are in the ErrorInfo, and a
+// PreconditionFailure.
+func checkSum(kind, want, calc string) error {
+	if want == "" || want == calc {
+		return nil
+	}
+	msg := fmt.Sprintf("%s failure req(%q) != calc(%q)", kind, want, calc)
+	st := withReason(status.New(codes.FailedPrecondition, msg), reasonChecksumMismatch,
+		map[string]string{"checksum": kind, "req": want, "calc": calc})
+	if d, err := st.WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{{Type: "CHECKSUM", Subject: kind, Description: msg}},
+	}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
+
+// storageError returns the error of a failed cloud-storage call, Unavailable
+// as the call may succeed on a retry, unless the request was cancelled or
+// timed out.
+func storageError(err error, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...) + ": " + err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, msg)
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, msg)
+	}
+	return withReason(status.New(codes.Unavailable, msg), reasonStorageUnavailable, nil).Err()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fieldViolations returns the fields of the BadRequest of an error, if any.
+func fieldViolations(err error) []string {
+	var fields []string
+	for _, d := range status.Convert(err).Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestRequireFields(t *testing.T) {
+	if err := requireFields("FileRequest", field{"filename", true}, field{"content", true}); err != nil {
+		t.Errorf("requireFields(all set) got err: %v; want nil err", err)
+	}
+	err := requireFields("FileRequest", field{"filename", true}, field{"content", false}, field{"project", false})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("requireFields() got err %v, want %v", err, codes.InvalidArgument)
+	}
+	if got := reasonOf(err); got != reasonMissingField {
+		t.Errorf("requireFields() got reason %q, want %q", got, reasonMissingField)
+	}
+	if diff := cmp.Diff(fieldViolations(err), []string{"content", "project"}); diff != "" {
+		t.Errorf("requireFields() got field violations diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestCheckSum(t *testing.T) {
+	tests := []struct {
+		desc string
+		want string
+		calc string
+		code codes.Code
+	}{{
+		desc: "match",
+		want: "50e3903156f5d2dac6c9f89626d48c75",
+		calc: "50e3903156f5d2dac6c9f89626d48c75",
+	}, {
+		desc: "unset",
+		calc: "50e3903156f5d2dac6c9f89626d48c75",
+	}, {
+		desc: "mismatch",
+		want: "0123",
+		calc: "50e3903156f5d2dac6c9f89626d48c75",
+		code: codes.FailedPrecondition,
+	}}
+	for _, test := range tests {
+		err := checkSum("md5sum", test.want, test.calc)
+		if got := status.Code(err); got != test.code {
+			t.Errorf("%s: checkSum() got err %v, want %v", test.desc, err, test.code)
+			continue
+		}
+		if err != nil && reasonOf(err) != reasonChecksumMismatch {
+			t.Errorf("%s: checkSum() got reason %q, want %q", test.desc, reasonOf(err), reasonChecksumMismatch)
+		}
+	}
+}
+
+func TestStorageError(t *testing.T) {
+	tests := []struct {
+		desc   string
+		err    error
+		want   codes.Code
+		reason string
+	}{{
+		desc:   "storage",
+		err:    errors.New("googleapi: Error 503: backend error"),
+		want:   codes.Unavailable,
+		reason: reasonStorageUnavailable,
+	}, {
+		desc: "cancelled",
+		err:  fmt.Errorf("write: %w", context.Canceled),
+		want: codes.Canceled,
+	}, {
+		desc: "timed out",
+		err:  context.DeadlineExceeded,
+		want: codes.DeadlineExceeded,
+	}}
+	for _, test := range tests {
+		err := storageError(test.err, "failed storing object: %s/%s", "foo", "bar")
+		if got := status.Code(err); got != test.want {
+			t.Errorf("%s: storageError() got err %v, want %v", test.desc, err, test.want)
+		}
+		if got := reasonOf(err); got != test.reason {
+			t.Errorf("%s: storageError() got reason %q, want %q", test.desc, got, test.reason)
+		}
+	}
+}
+
+func TestInvalidChecksum(t *testing.T) {
+	_, err := parseCRC32C("xyz")
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("parseCRC32C() got err %v, want %v", err, codes.InvalidArgument)
+	}
+	if got := reasonOf(err); got != reasonInvalidChecksum {
+		t.Errorf("parseCRC32C() got reason %q, want %q", got, reasonInvalidChecksum)
+	}
+	if diff := cmp.Diff(fieldViolations(err), []string{"crc32c"}); diff != "" {
+		t.Errorf("parseCRC32C() got field violations diff (-got +want):\n%s", diff)
+	}
+}
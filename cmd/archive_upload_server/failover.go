@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkFailover checks that the failover buckets are of the buckets of the
+// config, and that each failover bucket exists and is not the bucket itself.
+func checkFailover(ctx context.Context, client *storage.Client, c *config) error {
+	known := map[string]bool{}
+	for _, bkt := range c.buckets() {
+		known[bkt] = true
+	}
+	for bkt, sec := range c.Failover {
+		if !known[bkt] {
+			return fmt.Errorf("failover of unknown bucket %s", bkt)
+		}
+		if sec == bkt {
+			return fmt.Errorf("bucket %s is its own failover", bkt)
+		}
+		if _, err := client.Bucket(sec).Attrs(ctx); err != nil {
+			return fmt.Errorf("bad failover bucket %s of %s: %v", sec, bkt, err)
+		}
+	}
+	return nil
+}
+
+// failOver writes the object of a FileUpload to the failover bucket of its
+// bucket, once the write to the bucket failed as cloud-storage is
+// unavailable, so collectors need not buffer their files through a regional
+// incident. The object written is checked as in its bucket, see
+// checkOverwrite, and its copy back to the bucket is left pending, for
+// reconcile to copy once the bucket recovers; the copy does not replace an
+// object written to the bucket meanwhile.
+//
+// The error of the write is returned if the bucket has no failover bucket,
+// or the write failed otherwise.
+func (r rvServer) failOver(ctx context.Context, bkt, obj string, req *pb.FileRequest, rec *uploadRecord, werr error) (*storage.ObjectAttrs, bool, error) {
+	sec, ok := r.config().Failover[bkt]
+	if !ok || status.Code(werr) != codes.Unavailable {
+		return nil, false, werr
+	}
+	glog.Warningf("Failing over the write of %s/%s to %s: %v", bkt, obj, sec, werr)
+	rec.Object, rec.FailedOver = "gs://"+sec+"/"+obj, bkt
+	existing, unchanged, err := r.checkOverwrite(ctx, sec, obj, req, rec)
+	if err != nil {
+		return nil, false, err
+	}
+	attrs := existing
+	if !unchanged {
+		if attrs, err = r.objects().write(ctx, sec, obj, req, writeConditions(existing)); err != nil {
+			return nil, false, err
+		}
+	}
+	// A retry which finds the object of a failover unchanged leaves its copy
+	// pending again, it is pending once.
+	if r.replicas != nil {
+		r.replicas.fail(replicaCopy{bkt: sec, obj: obj, gen: attrs.Generation, dst: bkt, failover: true})
+	}
+	return attrs, unchanged, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckFailoverBad(t *testing.T) {
+	tests := []struct {
+		desc string
+		conf *config
+	}{{
+		desc: "unknown bucket",
+		conf: &config{
+			Buckets:  map[string]string{"RPKI_RARC": "foo"},
+			Failover: map[string]string{"bar": "bar-dr"},
+		},
+	}, {
+		desc: "own failover",
+		conf: &config{
+			Buckets:  map[string]string{"RPKI_RARC": "foo"},
+			Failover: map[string]string{"foo": "foo"},
+		},
+	}}
+	for _, test := range tests {
+		if err := checkFailover(context.Background(), nil, test.conf); err == nil {
+			t.Errorf("%s: checkFailover() got nil err, want err", test.desc)
+		}
+	}
+}
+
+func TestFailOver(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	srv.CreateBucket("foo-dr")
+	r := rvServer{
+		conf: &config{
+			Routes:   map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}},
+			Failover: map[string]string{"foo": "foo-dr"},
+		},
+		sc:       gcsClient(t, srv),
+		replicas: newReplicator(),
+	}
+	req := &pb.FileRequest{
+		Project:  pb.FileRequest_RPKI_RARC,
+		Filename: "bar",
+		Content:  []byte("Foo Bar Baz"),
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+	}
+	unavailable := status.Error(codes.Unavailable, "failed storing object")
+
+	// Other errors, and buckets without a failover bucket, do not fail over.
+	for _, test := range []struct {
+		desc string
+		bkt  string
+		err  error
+	}{{
+		desc: "written meanwhile",
+		bkt:  "foo",
+		err:  writtenMeanwhile("bar", nil),
+	}, {
+		desc: "no failover bucket",
+		bkt:  "foo-dr",
+		err:  unavailable,
+	}} {
+		if _, _, err := r.failOver(ctx, test.bkt, "rarc/bar", req, &uploadRecord{}, test.err); err != test.err {
+			t.Errorf("%s: failOver() got err: %v; want %v", test.desc, err, test.err)
+		}
+	}
+
+	rec := &uploadRecord{}
+	attrs, unchanged, err := r.failOver(ctx, "foo", "rarc/bar", req, rec, unavailable)
+	if err != nil {
+		t.Fatalf("failOver() got err: %v", err)
+	}
+	if attrs.Bucket != "foo-dr" || unchanged {
+		t.Errorf("failOver() stored to %s, unchanged %v; want foo-dr, false", attrs.Bucket, unchanged)
+	}
+	if rec.Object != "gs://foo-dr/rarc/bar" || rec.FailedOver != "foo" {
+		t.Errorf("failOver() recorded object %q failed over from %q, want gs://foo-dr/rarc/bar from foo", rec.Object, rec.FailedOver)
+	}
+	pending := []replicaCopy{{bkt: "foo-dr", obj: "rarc/bar", gen: attrs.Generation, dst: "foo", failover: true}}
+	if got := r.replicas.copies(); !cmp.Equal(got, pending, cmp.AllowUnexported(replicaCopy{})) {
+		t.Errorf("failOver() left pending %v, want %v", got, pending)
+	}
+
+	// The retry of the upload finds the object of the failover unchanged.
+	if _, unchanged, err := r.failOver(ctx, "foo", "rarc/bar", req, &uploadRecord{}, unavailable); err != nil || !unchanged {
+		t.Errorf("failOver() of a retry got unchanged %v, err: %v; want true, nil err", unchanged, err)
+	}
+
+	// The bucket recovered, the object is copied back to it.
+	r.reconcileReplicas(ctx)
+	if _, err := srv.Client().Bucket("foo").Object("rarc/bar").Attrs(ctx); err != nil {
+		t.Errorf("reconcileReplicas() did not copy rarc/bar back to foo: %v", err)
+	}
+	if got, want := r.replicas.String(), "0 pending, 1 failed, 1 reconciled, 0 dropped"; got != want {
+		t.Errorf("reconcileReplicas() counters = %q, want %q", got, want)
+	}
+
+	// The copy back does not replace an object written to the bucket
+	// meanwhile.
+	r.replicas.fail(replicaCopy{bkt: "foo-dr", obj: "rarc/bar", gen: attrs.Generation, dst: "foo", failover: true})
+	r.reconcileReplicas(ctx)
+	if got, want := r.replicas.String(), "0 pending, 2 failed, 1 reconciled, 1 dropped"; got != want {
+		t.Errorf("reconcileReplicas() counters = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/routeviews/google-cloud-storage/pkg/version"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// gatewayPath is the path of the FileUpload of the REST gateway.
+	gatewayPath = "/v1/files"
+	// fileUploadMethod is the method FileUpload is called as by the gateway,
+	// for the interceptors.
+	fileUploadMethod = "/rv.proto.RV/FileUpload"
+	// gatewayMemory is the memory a multipart form is parsed in, larger
+	// contents are buffered in temporary files.
+	gatewayMemory = 32 << 20
+)
+
+// gatewayHeaders are the headers passed to FileUpload as the metadata of a
+// call: the ID token, and the client and protocol versions.
+var gatewayHeaders = []string{"authorization", version.ProtocolKey, version.ClientKey}
+
+// gateway serves FileUpload over HTTP, for the upload scripts which can not
+// speak gRPC: POST /v1/files with the FileRequest as JSON, its content in
+// base64, or as a multipart form of the fields of the FileRequest, its
+// content in a file part. The request goes through the interceptors of the
+// gRPC server, with the headers of the request as metadata, so it is logged,
+// authorized and limited as the gRPC calls are. The response is the
+// FileResponse as JSON, or the google.rpc.Status of the error, with the HTTP
+// status of its code.
+type gateway struct {
+	srv pb.RVServer
+	// intercept runs the interceptors of the gRPC server.
+	intercept grpc.UnaryServerInterceptor
+	// maxBytes is the message size limit of the server.
+	maxBytes int
+}
+
+// newGateway returns the gateway of a server, of its message size limit and
+// its unary interceptors, in the order of the gRPC server.
+func newGateway(srv pb.RVServer, maxBytes int, interceptors ...grpc.UnaryServerInterceptor) *gateway {
+	return &gateway{srv: srv, intercept: chainUnary(interceptors...), maxBytes: maxBytes}
+}
+
+// chainUnary returns the interceptor which runs the interceptors in order, as
+// grpc.ChainUnaryInterceptor does.
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		h := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next, in := h, interceptors[i]
+			h = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return in(ctx, req, info, next)
+			}
+		}
+		return h(ctx, req)
+	}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != gatewayPath {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The body carries the content in base64 or a multipart form, both
+	// larger than the content.
+	req.Body = http.MaxBytesReader(w, req.Body, int64(g.maxBytes)*2)
+	fr, err := g.fileRequest(req)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	md := metadata.MD{}
+	for _, k := range gatewayHeaders {
+		if v := req.Header.Values(k); len(v) > 0 {
+			md.Set(k, v...)
+		}
+	}
+	ctx := metadata.NewIncomingContext(req.Context(), md)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: httpAddr(req.RemoteAddr)})
+	info := &grpc.UnaryServerInfo{Server: g.srv, FullMethod: fileUploadMethod}
+	resp, err := g.intercept(ctx, fr, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return g.srv.FileUpload(ctx, req.(*pb.FileRequest))
+	})
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	b, err := protojson.Marshal(resp.(*pb.FileResponse))
+	if err != nil {
+		writeStatus(w, status.Errorf(codes.Internal, "failed to marshal the response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// fileRequest returns the FileRequest of an HTTP request, of its JSON body or
+// multipart form. It fails with InvalidArgument, or ResourceExhausted for a
+// request beyond the message size limit, as the gRPC server would.
+func (g *gateway) fileRequest(req *http.Request) (*pb.FileRequest, error) {
+	mt, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad Content-Type: %v", err)
+	}
+	fr := &pb.FileRequest{}
+	switch mt {
+	case "application/json":
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to read the request: %v", err)
+		}
+		if err := protojson.Unmarshal(b, fr); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad FileRequest: %v", err)
+		}
+	case "multipart/form-data":
+		if err := req.ParseMultipartForm(gatewayMemory); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad multipart form: %v", err)
+		}
+		defer req.MultipartForm.RemoveAll()
+		if fr, err = formRequest(req); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported Content-Type %q, want application/json or multipart/form-data", mt)
+	}
+	if size := proto.Size(fr); size > g.maxBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "FileRequest of %d bytes exceeds the message size limit(%d bytes)", size, g.maxBytes)
+	}
+	return fr, nil
+}
+
+// formRequest returns the FileRequest of a multipart form: a field of each
+// field of the FileRequest set, by its proto name, ie: md5sum or
+// allow_overwrite, and the content in the file part "content".
+func formRequest(req *http.Request) (*pb.FileRequest, error) {
+	fr := &pb.FileRequest{}
+	for k, vs := range req.MultipartForm.Value {
+		v := vs[0]
+		var err error
+		switch k {
+		case "filename":
+			fr.Filename = v
+		case "md5sum":
+			fr.Md5Sum = v
+		case "project":
+			p, ok := pb.FileRequest_Project_value[v]
+			if !ok {
+				return nil, badField("project", reasonInvalidField, "unknown project %q", v)
+			}
+			fr.Project = pb.FileRequest_Project(p)
+		case "crc32c":
+			fr.Crc32C = v
+		case "sha256":
+			fr.Sha256 = v
+		case "content_encoding":
+			fr.ContentEncoding = v
+		case "request_id":
+			fr.RequestId = v
+		case "source_url":
+			fr.SourceUrl = v
+		case "allow_overwrite":
+			fr.AllowOverwrite, err = strconv.ParseBool(v)
+		case "convert_now":
+			fr.ConvertNow, err = strconv.ParseBool(v)
+		case "transcode":
+			fr.Transcode, err = strconv.ParseBool(v)
+		case "if_generation_match":
+			fr.IfGenerationMatch, err = strconv.ParseInt(v, 10, 64)
+		default:
+			return nil, badField(k, reasonInvalidField, "unknown field %q of a FileRequest", k)
+		}
+		if err != nil {
+			return nil, badField(k, reasonInvalidField, "bad %s(%q): %v", k, v, err)
+		}
+	}
+	f, _, err := req.FormFile("content")
+	if err != nil {
+		return nil, badField("content", reasonMissingField, "no content file part: %v", err)
+	}
+	defer f.Close()
+	if fr.Content, err = ioutil.ReadAll(f); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to read the content: %v", err)
+	}
+	return fr, nil
+}
+
+// httpStatus maps the code of a gRPC status to the HTTP status of the
+// gateway, as grpc-gateway does.
+var httpStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// writeStatus writes the google.rpc.Status of an error as JSON, with its
+// details, ie: the ErrorInfo of its reason.
+func writeStatus(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := httpStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	b, merr := protojson.Marshal(st.Proto())
+	if merr != nil {
+		glog.Errorf("failed to marshal the status %v: %v", err, merr)
+		http.Error(w, st.Message(), code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// httpAddr is the address of the client of an HTTP request, as a peer.
+type httpAddr string
+
+func (a httpAddr) Network() string { return "tcp" }
+func (a httpAddr) String() string  { return string(a) }
+
+// serveGateway serves the gateway on a listener, with TLS if tc is not nil,
+// until the server is stopped.
+func serveGateway(srv *http.Server, lis net.Listener, tc *tls.Config) {
+	if tc != nil {
+		lis = tls.NewListener(lis, tc)
+	}
+	if err := srv.Serve(lis); err != nil && err != http.ErrServerClosed {
+		glog.Fatalf("failed to serve the gateway: %v", err)
+	}
+}
+
+// gatewayStopper stops the HTTP server of the gateway as a stopper, see
+// drain.
+type gatewayStopper struct {
+	*http.Server
+}
+
+func (s gatewayStopper) GracefulStop() { s.Shutdown(context.Background()) }
+func (s gatewayStopper) Stop()         { s.Close() }
+
+// stoppers stops several servers together.
+type stoppers []stopper
+
+func (ss stoppers) GracefulStop() {
+	var wg sync.WaitGroup
+	for _, s := range ss {
+		wg.Add(1)
+		go func(s stopper) {
+			defer wg.Done()
+			s.GracefulStop()
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (ss stoppers) Stop() {
+	for _, s := range ss {
+		s.Stop()
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/routeviews/google-cloud-storage/pkg/version"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+// fakeUploads is an RVServer which keeps the FileRequest and the metadata of
+// each FileUpload, and fails them with err.
+type fakeUploads struct {
+	pb.UnimplementedRVServer
+	reqs []*pb.FileRequest
+	md   metadata.MD
+	err  error
+}
+
+func (f *fakeUploads) FileUpload(ctx context.Context, req *pb.FileRequest) (*pb.FileResponse, error) {
+	f.reqs = append(f.reqs, req)
+	f.md, _ = metadata.FromIncomingContext(ctx)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &pb.FileResponse{Status: pb.FileResponse_SUCCESS}, nil
+}
+
+// multipartBody returns the multipart form of fields, and the content as its
+// file part, and its Content-Type.
+func multipartBody(t *testing.T, fields map[string]string, content []byte) (*bytes.Buffer, string) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			t.Fatalf("WriteField(%q) got err: %v", k, err)
+		}
+	}
+	if content != nil {
+		f, err := w.CreateFormFile("content", "file")
+		if err != nil {
+			t.Fatalf("CreateFormFile() got err: %v", err)
+		}
+		f.Write(content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("multipart Close() got err: %v", err)
+	}
+	return &b, w.FormDataContentType()
+}
+
+func TestGateway(t *testing.T) {
+	form, formType := multipartBody(t, map[string]string{
+		"filename":        "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+		"project":         "ROUTEVIEWS",
+		"md5sum":          "9a0364b9e99bb480dd25e1f0284c8555",
+		"allow_overwrite": "true",
+	}, []byte("content"))
+	badForm, badFormType := multipartBody(t, map[string]string{"filename": "a.bz2", "colour": "blue"}, []byte("content"))
+	noContent, noContentType := multipartBody(t, map[string]string{"filename": "a.bz2"}, nil)
+	tests := []struct {
+		desc        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		err         error
+		wantCode    int
+		wantReq     *pb.FileRequest
+	}{{
+		desc:        "json",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: "application/json",
+		body:        `{"filename": "a.bz2", "project": "ROUTEVIEWS", "content": "Y29udGVudA==", "md5sum": "9a0364b9e99bb480dd25e1f0284c8555"}`,
+		wantCode:    http.StatusOK,
+		wantReq: &pb.FileRequest{
+			Filename: "a.bz2",
+			Project:  pb.FileRequest_ROUTEVIEWS,
+			Content:  []byte("content"),
+			Md5Sum:   "9a0364b9e99bb480dd25e1f0284c8555",
+		},
+	}, {
+		desc:        "multipart",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: formType,
+		body:        form.String(),
+		wantCode:    http.StatusOK,
+		wantReq: &pb.FileRequest{
+			Filename:       "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+			Project:        pb.FileRequest_ROUTEVIEWS,
+			Content:        []byte("content"),
+			Md5Sum:         "9a0364b9e99bb480dd25e1f0284c8555",
+			AllowOverwrite: true,
+		},
+	}, {
+		desc:        "unknown field",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: badFormType,
+		body:        badForm.String(),
+		wantCode:    http.StatusBadRequest,
+	}, {
+		desc:        "no content",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: noContentType,
+		body:        noContent.String(),
+		wantCode:    http.StatusBadRequest,
+	}, {
+		desc:        "bad json",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: "application/json",
+		body:        `{"filename": 1}`,
+		wantCode:    http.StatusBadRequest,
+	}, {
+		desc:        "unsupported content type",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: "text/plain",
+		body:        "content",
+		wantCode:    http.StatusBadRequest,
+	}, {
+		desc:     "method",
+		method:   http.MethodGet,
+		path:     gatewayPath,
+		wantCode: http.StatusMethodNotAllowed,
+	}, {
+		desc:     "path",
+		method:   http.MethodPost,
+		path:     "/v1/objects",
+		wantCode: http.StatusNotFound,
+	}, {
+		desc:        "upload error",
+		method:      http.MethodPost,
+		path:        gatewayPath,
+		contentType: "application/json",
+		body:        `{"filename": "a.bz2", "project": "ROUTEVIEWS", "content": "Y29udGVudA=="}`,
+		err:         status.Error(codes.PermissionDenied, "caller may not upload to ROUTEVIEWS"),
+		wantCode:    http.StatusForbidden,
+		wantReq: &pb.FileRequest{
+			Filename: "a.bz2",
+			Project:  pb.FileRequest_ROUTEVIEWS,
+			Content:  []byte("content"),
+		},
+	}}
+	for _, test := range tests {
+		f := &fakeUploads{err: test.err}
+		g := newGateway(f, maxMsgSize)
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		if w.Code != test.wantCode {
+			t.Errorf("%s: ServeHTTP() got status %d, want %d: %s", test.desc, w.Code, test.wantCode, w.Body)
+		}
+		var got *pb.FileRequest
+		if len(f.reqs) > 0 {
+			got = f.reqs[0]
+		}
+		if diff := cmp.Diff(got, test.wantReq, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: ServeHTTP() got FileRequest diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestGatewayMetadata(t *testing.T) {
+	f := &fakeUploads{}
+	var method string
+	intercept := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method = info.FullMethod
+		return handler(ctx, req)
+	}
+	g := newGateway(f, maxMsgSize, intercept)
+	req := httptest.NewRequest(http.MethodPost, gatewayPath, strings.NewReader(`{"filename": "a.bz2", "content": "Y29udGVudA=="}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Rv-Protocol-Version", version.Protocol)
+	req.Header.Set("X-Other", "other")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP() got status %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if method != fileUploadMethod {
+		t.Errorf("ServeHTTP() intercepted method %q, want %q", method, fileUploadMethod)
+	}
+	want := metadata.MD{"authorization": {"Bearer token"}, version.ProtocolKey: {version.Protocol}}
+	if diff := cmp.Diff(f.md, want); diff != "" {
+		t.Errorf("ServeHTTP() got metadata diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestGatewayLimit(t *testing.T) {
+	f := &fakeUploads{}
+	// The body is within the limit of the body, the content beyond the
+	// message size limit.
+	g := newGateway(f, 30)
+	req := httptest.NewRequest(http.MethodPost, gatewayPath, strings.NewReader(`{"content": "Y29udGVudCBiZXlvbmQgdGhlIHNpemUgbGltaXQ="}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("ServeHTTP() got status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if len(f.reqs) != 0 {
+		t.Errorf("ServeHTTP() got %d uploads beyond the limit, want none", len(f.reqs))
+	}
+}
+
+func TestChainUnary(t *testing.T) {
+	var order []string
+	in := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}
+	}
+	chain := chainUnary(in("first"), in("second"))
+	chain(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return nil, nil
+	})
+	if diff := cmp.Diff(order, []string{"first", "second", "handler"}); diff != "" {
+		t.Errorf("chainUnary() got order diff (-got +want):\n%s", diff)
+	}
+}
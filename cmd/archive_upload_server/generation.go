@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkGeneration checks the if_generation_match of a request against the
+// attributes of the object of its file, nil if it does not exist: the object
+// must be at the generation. A request without one is not checked.
+func checkGeneration(meta *pb.FileRequest, attrs *storage.ObjectAttrs) error {
+	want := meta.GetIfGenerationMatch()
+	if want == 0 {
+		return nil
+	}
+	var got int64
+	if attrs != nil {
+		got = attrs.Generation
+	}
+	if got == want {
+		return nil
+	}
+	return withReason(status.Newf(codes.FailedPrecondition, "%s is at generation(%d), not if_generation_match(%d)", meta.GetFilename(), got, want),
+		reasonGenerationMismatch, map[string]string{"generation": strconv.FormatInt(got, 10)}).Err()
+}
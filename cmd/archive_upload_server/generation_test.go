@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckGeneration(t *testing.T) {
+	tests := []struct {
+		desc  string
+		req   *pb.FileRequest
+		attrs *storage.ObjectAttrs
+		want  codes.Code
+	}{{
+		desc:  "no if_generation_match",
+		req:   &pb.FileRequest{Filename: "foo"},
+		attrs: &storage.ObjectAttrs{Generation: 42},
+	}, {
+		desc: "no if_generation_match of a new object",
+		req:  &pb.FileRequest{Filename: "foo"},
+	}, {
+		desc:  "generation matches",
+		req:   &pb.FileRequest{Filename: "foo", IfGenerationMatch: 42},
+		attrs: &storage.ObjectAttrs{Generation: 42},
+	}, {
+		desc:  "generation differs",
+		req:   &pb.FileRequest{Filename: "foo", IfGenerationMatch: 41},
+		attrs: &storage.ObjectAttrs{Generation: 42},
+		want:  codes.FailedPrecondition,
+	}, {
+		desc: "object does not exist",
+		req:  &pb.FileRequest{Filename: "foo", IfGenerationMatch: 42},
+		want: codes.FailedPrecondition,
+	}}
+	for _, test := range tests {
+		err := checkGeneration(test.req, test.attrs)
+		if got := status.Code(err); got != test.want {
+			t.Errorf("%s: checkGeneration() got err %v, want %v", test.desc, err, test.want)
+			continue
+		}
+		if err != nil && reasonOf(err) != reasonGenerationMismatch {
+			t.Errorf("%s: checkGeneration() got reason %q, want %q", test.desc, reasonOf(err), reasonGenerationMismatch)
+		}
+	}
+}
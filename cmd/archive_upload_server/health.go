@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// healthInterval is the interval of the storage checks of the health
+	// service.
+	healthInterval = 30 * time.Second
+	// healthTimeout bounds the storage check of each bucket.
+	healthTimeout = 10 * time.Second
+)
+
+// buckets returns the buckets of the config, of every route and project.
+func (c *config) buckets() []string {
+	seen := map[string]bool{}
+	for _, bkt := range c.Buckets {
+		seen[bkt] = true
+	}
+	for _, rt := range c.Routes {
+		seen[rt.Bucket] = true
+	}
+	if c.Default != nil {
+		seen[c.Default.Bucket] = true
+	}
+	var bkts []string
+	for bkt := range seen {
+		bkts = append(bkts, bkt)
+	}
+	sort.Strings(bkts)
+	return bkts
+}
+
+// checkStorage checks that each bucket of the config can be reached.
+func (r rvServer) checkStorage(ctx context.Context) error {
+	for _, bkt := range r.config().buckets() {
+		cctx, cancel := context.WithTimeout(ctx, healthTimeout)
+		err := r.objects().checkBucket(cctx, bkt)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("bucket %s is unreachable: %v", bkt, err)
+		}
+	}
+	return nil
+}
+
+// updateHealth sets the serving status of the server, and of the RV service,
+// from a storage check: a server which can not reach storage is NOT_SERVING.
+func (r rvServer) updateHealth(ctx context.Context, hs *health.Server) {
+	st := healthpb.HealthCheckResponse_SERVING
+	if err := r.checkStorage(ctx); err != nil {
+		glog.Errorf("health check failed: %v", err)
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	hs.SetServingStatus("", st)
+	hs.SetServingStatus(pb.RV_ServiceDesc.ServiceName, st)
+}
+
+// watchHealth updates the serving status every interval, until the context
+// is done.
+func (r rvServer) watchHealth(ctx context.Context, hs *health.Server, interval time.Duration) {
+	r.updateHealth(ctx, hs)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.updateHealth(ctx, hs)
+		}
+	}
+}
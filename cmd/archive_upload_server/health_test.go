@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestBuckets(t *testing.T) {
+	c := &config{
+		Buckets: map[string]string{"ROUTEVIEWS": "foo", "ROUTEVIEWS_RIB": "baz"},
+		Routes:  map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}},
+		Default: &route{Bucket: "qux"},
+	}
+	if diff := cmp.Diff(c.buckets(), []string{"baz", "foo", "qux"}); diff != "" {
+		t.Errorf("buckets() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestUpdateHealth(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+
+	tests := []struct {
+		desc string
+		conf *config
+		want healthpb.HealthCheckResponse_ServingStatus
+	}{{
+		desc: "storage reachable",
+		conf: &config{Buckets: map[string]string{"ROUTEVIEWS": "foo"}},
+		want: healthpb.HealthCheckResponse_SERVING,
+	}, {
+		desc: "bucket unreachable",
+		conf: &config{Buckets: map[string]string{"ROUTEVIEWS": "foo", "ROUTEVIEWS_RIB": "gone"}},
+		want: healthpb.HealthCheckResponse_NOT_SERVING,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := rvServer{conf: test.conf, sc: srv.Client()}
+			hs := health.NewServer()
+			r.updateHealth(ctx, hs)
+			for _, service := range []string{"", pb.RV_ServiceDesc.ServiceName} {
+				resp, err := hs.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+				if err != nil {
+					t.Fatalf("Check(%q) = %v; want nil err", service, err)
+				}
+				if got := resp.GetStatus(); got != test.want {
+					t.Errorf("Check(%q) = %v; want %v", service, got, test.want)
+				}
+			}
+		})
+	}
+}
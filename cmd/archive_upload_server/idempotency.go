@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+const (
+	// idemTTL is how long the response of a request is kept for its retries.
+	idemTTL = 10 * time.Minute
+	// idemSize bounds the responses kept, the oldest are evicted first.
+	idemSize = 10000
+)
+
+// idemKey identifies the retries of a request. The project is part of the key,
+// so a request_id reused across projects is not answered from another project.
+type idemKey struct {
+	proj, id, md5 string
+}
+
+// idemEntry is a request seen recently, done is closed once resp and err are
+// set.
+type idemEntry struct {
+	done    chan struct{}
+	resp    *pb.FileResponse
+	err     error
+	expires time.Time
+}
+
+// idemCache remembers the responses of recently seen requests, so that the
+// retries of a request return the response of the first rather than process
+// the upload again. It is safe for concurrent use.
+//
+// The cache is per instance, a retry routed to another instance is processed
+// again, and found UNCHANGED if the first stored the file.
+type idemCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[idemKey]*idemEntry
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newIdemCache returns a cache of at most size responses, each kept for ttl.
+func newIdemCache(ttl time.Duration, size int) *idemCache {
+	return &idemCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[idemKey]*idemEntry{},
+		now:     time.Now,
+	}
+}
+
+// do returns the response of the request of key: the response of a request
+// seen already, or of fn. A retry which arrives while the first is in flight
+// waits for it. Failures are not kept, the retry of a failed request is
+// processed again. cached reports whether the response is of an earlier
+// request.
+func (c *idemCache) do(ctx context.Context, key idemKey, fn func() (*pb.FileResponse, error)) (resp *pb.FileResponse, cached bool, err error) {
+	c.mu.Lock()
+	now := c.now()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if e.err == nil {
+			return e.resp, true, nil
+		}
+		// The first failed, process the retry.
+		return c.do(ctx, key, fn)
+	}
+	e := &idemEntry{done: make(chan struct{}), expires: now.Add(c.ttl)}
+	c.evict(now)
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.resp, e.err = fn()
+	c.mu.Lock()
+	if e.err != nil && c.entries[key] == e {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(e.done)
+	return e.resp, false, e.err
+}
+
+// evict drops the expired entries, and the oldest while the cache is full. It
+// is called with c.mu held.
+func (c *idemCache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for len(c.entries) >= c.size && len(c.entries) > 0 {
+		var oldest idemKey
+		var first time.Time
+		for k, e := range c.entries {
+			if first.IsZero() || e.expires.Before(first) {
+				oldest, first = k, e.expires
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+func TestIdemCache(t *testing.T) {
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := newIdemCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	calls := 0
+	upload := func(st pb.FileResponse_Status, err error) func() (*pb.FileResponse, error) {
+		return func() (*pb.FileResponse, error) {
+			calls++
+			if err != nil {
+				return nil, err
+			}
+			return &pb.FileResponse{Status: st}, nil
+		}
+	}
+	key := idemKey{proj: "ROUTEVIEWS", id: "1", md5: "abc"}
+
+	tests := []struct {
+		desc       string
+		key        idemKey
+		fn         func() (*pb.FileResponse, error)
+		advance    time.Duration
+		wantStatus pb.FileResponse_Status
+		wantCached bool
+		wantErr    bool
+		wantCalls  int
+	}{{
+		desc:       "first request",
+		key:        key,
+		fn:         upload(pb.FileResponse_SUCCESS, nil),
+		wantStatus: pb.FileResponse_SUCCESS,
+		wantCalls:  1,
+	}, {
+		desc:       "retry",
+		key:        key,
+		fn:         upload(pb.FileResponse_UNCHANGED, nil),
+		wantStatus: pb.FileResponse_SUCCESS,
+		wantCached: true,
+		wantCalls:  1,
+	}, {
+		desc:       "other content",
+		key:        idemKey{proj: "ROUTEVIEWS", id: "1", md5: "def"},
+		fn:         upload(pb.FileResponse_SUCCESS, nil),
+		wantStatus: pb.FileResponse_SUCCESS,
+		wantCalls:  2,
+	}, {
+		desc:      "failure",
+		key:       idemKey{proj: "RIPE_RIS", id: "2", md5: "abc"},
+		fn:        upload(pb.FileResponse_FAIL, errors.New("write failure")),
+		wantErr:   true,
+		wantCalls: 3,
+	}, {
+		desc:       "retry of a failure",
+		key:        idemKey{proj: "RIPE_RIS", id: "2", md5: "abc"},
+		fn:         upload(pb.FileResponse_SUCCESS, nil),
+		wantStatus: pb.FileResponse_SUCCESS,
+		wantCalls:  4,
+	}, {
+		desc:       "retry after the ttl",
+		key:        idemKey{proj: "RIPE_RIS", id: "2", md5: "abc"},
+		fn:         upload(pb.FileResponse_UNCHANGED, nil),
+		advance:    2 * time.Minute,
+		wantStatus: pb.FileResponse_UNCHANGED,
+		wantCalls:  5,
+	}}
+	for _, test := range tests {
+		now = now.Add(test.advance)
+		resp, cached, err := c.do(ctx, test.key, test.fn)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("[%s]: do() got nil err; want non-nil err", test.desc)
+		case !test.wantErr && err != nil:
+			t.Errorf("[%s]: do() got err: %v; want nil err", test.desc, err)
+		}
+		if got := resp.GetStatus(); got != test.wantStatus {
+			t.Errorf("[%s]: do() status = %v; want %v", test.desc, got, test.wantStatus)
+		}
+		if cached != test.wantCached {
+			t.Errorf("[%s]: do() cached = %v; want %v", test.desc, cached, test.wantCached)
+		}
+		if calls != test.wantCalls {
+			t.Errorf("[%s]: %d uploads; want %d", test.desc, calls, test.wantCalls)
+		}
+	}
+	if len(c.entries) > c.size {
+		t.Errorf("%d entries kept; want at most %d", len(c.entries), c.size)
+	}
+}
+
+func TestIdemCacheInFlight(t *testing.T) {
+	c := newIdemCache(time.Minute, 10)
+	key := idemKey{proj: "ROUTEVIEWS", id: "1", md5: "abc"}
+	release := make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	fn := func() (*pb.FileResponse, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return &pb.FileResponse{Status: pb.FileResponse_SUCCESS}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := c.do(context.Background(), key, fn); err != nil {
+				t.Errorf("do() got err: %v; want nil err", err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("%d uploads of concurrent retries; want 1", calls)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// kmsKeyPattern matches the resource name of a Cloud KMS key.
+var kmsKeyPattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// checkKMSKey checks a key is the resource name of a Cloud KMS key, a key
+// version is rejected as cloud-storage encrypts with the primary version.
+func checkKMSKey(key string) error {
+	if !kmsKeyPattern.MatchString(key) {
+		return fmt.Errorf("%q is not a KMS key, want projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>", key)
+	}
+	return nil
+}
+
+// checkKMSKeys checks the projects and keys of the KMS keys in the config.
+func checkKMSKeys(keys map[string]string) error {
+	for proj, key := range keys {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of KMS key", proj)
+		}
+		if err := checkKMSKey(key); err != nil {
+			return fmt.Errorf("bad KMS key of %s: %v", proj, err)
+		}
+	}
+	return nil
+}
+
+// kmsKey returns the Cloud KMS key the objects of a project are encrypted
+// with: the key of the project in the config, or else the -kms_key. Empty
+// leaves the objects to the default encryption of the bucket.
+func (r rvServer) kmsKey(proj string) string {
+	if key, ok := r.config().KMSKeys[proj]; ok {
+		return key
+	}
+	return r.kms
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestKMSKey(t *testing.T) {
+	const (
+		rpkiKey = "projects/rv/locations/us/keyRings/archives/cryptoKeys/rpki"
+		dfltKey = "projects/rv/locations/us/keyRings/archives/cryptoKeys/default"
+	)
+	r := rvServer{conf: &config{KMSKeys: map[string]string{"RPKI_RARC": rpkiKey}}}
+	if got := r.kmsKey("ROUTEVIEWS"); got != "" {
+		t.Errorf("kmsKey(ROUTEVIEWS) = %q; want the bucket default", got)
+	}
+	r.kms = dfltKey
+	for proj, want := range map[string]string{
+		"RPKI_RARC":  rpkiKey,
+		"ROUTEVIEWS": dfltKey,
+	} {
+		if got := r.kmsKey(proj); got != want {
+			t.Errorf("kmsKey(%s) = %q; want %q", proj, got, want)
+		}
+	}
+}
+
+func TestCheckKMSKeys(t *testing.T) {
+	tests := []struct {
+		desc    string
+		keys    map[string]string
+		wantErr bool
+	}{{
+		desc: "key of a project",
+		keys: map[string]string{"RPKI_RARC": "projects/rv/locations/us/keyRings/archives/cryptoKeys/rpki"},
+	}, {
+		desc:    "unknown project",
+		keys:    map[string]string{"ISOLARIO": "projects/rv/locations/us/keyRings/archives/cryptoKeys/rpki"},
+		wantErr: true,
+	}, {
+		desc:    "key version",
+		keys:    map[string]string{"RPKI_RARC": "projects/rv/locations/us/keyRings/archives/cryptoKeys/rpki/cryptoKeyVersions/1"},
+		wantErr: true,
+	}, {
+		desc:    "not a key",
+		keys:    map[string]string{"RPKI_RARC": "rpki"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		err := checkKMSKeys(test.keys)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("%s: checkKMSKeys() got nil err; want non-nil err", test.desc)
+		case !test.wantErr && err != nil:
+			t.Errorf("%s: checkKMSKeys() got err: %v; want nil err", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPageSize is the max objects and prefixes of a page of ListObjects, the
+// max of a page of a cloud-storage listing.
+const maxPageSize = 1000
+
+// listFields maps the fields of an ObjectInfo to the attributes of the objects
+// listed, only the attributes of the fields requested are fetched.
+var listFields = map[string]string{
+	"md5sum":        "MD5",
+	"crc32c":        "CRC32C",
+	"size":          "Size",
+	"generation":    "Generation",
+	"updated":       "Updated",
+	"storage_class": "StorageClass",
+}
+
+// listSelection returns the set of the fields requested, all if none are, and
+// the attributes of the objects to fetch for them.
+func listSelection(fields []string) (map[string]bool, []string, error) {
+	sel := map[string]bool{}
+	if len(fields) == 0 {
+		for f := range listFields {
+			sel[f] = true
+		}
+	}
+	for _, f := range fields {
+		if _, ok := listFields[f]; !ok {
+			return nil, nil, badField("fields", reasonInvalidField, "unknown field(%q) of ObjectInfo", f)
+		}
+		sel[f] = true
+	}
+	attrs := []string{"Name"}
+	for f := range sel {
+		attrs = append(attrs, listFields[f])
+	}
+	return sel, attrs, nil
+}
+
+// ListObjects returns a page of the objects of a project under a prefix, with
+// the fields requested. Filenames are those of the uploads, without the prefix
+// of the route of the project. Callers may list the files of the projects they
+// may upload to. The objects the server keeps under its reserved prefixes,
+// which no filename starts with, see cleanFilename, are skipped, so a page may
+// have fewer than its size.
+func (r rvServer) ListObjects(ctx context.Context, req *pb.ListObjectsRequest) (*pb.ListObjectsResponse, error) {
+	if err := r.needsGCS("ListObjects"); err != nil {
+		return nil, err
+	}
+	proj := req.GetProject()
+	if err := requireFields("ListObjectsRequest", field{"project", proj != pb.FileRequest_UNKNOWN}); err != nil {
+		return nil, err
+	}
+	size := int(req.GetPageSize())
+	if size < 0 {
+		return nil, badField("page_size", reasonInvalidField, "invalid page_size(%d)", size)
+	}
+	if size == 0 || size > maxPageSize {
+		size = maxPageSize
+	}
+	fields, attrs, err := listSelection(req.GetFields())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, proj.String()); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, 0); err != nil {
+		return nil, err
+	}
+	bkt, prefix, ok := r.config().route(proj.String())
+	if !ok {
+		return nil, unsupportedProject("project", proj)
+	}
+
+	q := &storage.Query{Prefix: prefix + req.GetPrefix(), Delimiter: req.GetDelimiter()}
+	if err := q.SetAttrSelection(attrs); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to select the attributes %v: %v", attrs, err)
+	}
+	ctx, span := tracer.Start(ctx, "gcs.list", objectAttrs(bkt, q.Prefix))
+	var objs []*storage.ObjectAttrs
+	next, err := iterator.NewPager(r.sc.Bucket(bkt).Objects(ctx, q), size, req.GetPageToken()).NextPage(&objs)
+	endSpan(span, err)
+	if err != nil {
+		glog.Errorf("failed to list the objects under %s/%s: %v", bkt, q.Prefix, err)
+		return nil, storageError(err, "failed to list the objects under %q", req.GetPrefix())
+	}
+
+	resp := &pb.ListObjectsResponse{NextPageToken: next}
+	for _, o := range objs {
+		// The objects under the delimiter are listed as prefixes, without a name.
+		if o.Name == "" {
+			if p := strings.TrimPrefix(o.Prefix, prefix); !strings.HasPrefix(p, "_") {
+				resp.Prefixes = append(resp.Prefixes, p)
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimPrefix(o.Name, prefix), "_") {
+			continue
+		}
+		resp.Objects = append(resp.Objects, objectInfo(o, prefix, fields))
+	}
+	return resp, nil
+}
+
+// objectInfo returns the fields requested of an object listed under the prefix
+// of a route.
+func objectInfo(attrs *storage.ObjectAttrs, prefix string, fields map[string]bool) *pb.ObjectInfo {
+	info := &pb.ObjectInfo{Filename: strings.TrimPrefix(attrs.Name, prefix)}
+	if fields["md5sum"] {
+		info.Md5Sum = uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	}
+	if fields["crc32c"] {
+		info.Crc32C = uploadutils.ChecksumFromAttrs(uploadutils.CRC32C, attrs)
+	}
+	if fields["size"] {
+		info.Size = attrs.Size
+	}
+	if fields["generation"] {
+		info.Generation = attrs.Generation
+	}
+	if fields["updated"] && !attrs.Updated.IsZero() {
+		info.Updated = attrs.Updated.UTC().Format(time.RFC3339)
+	}
+	if fields["storage_class"] {
+		info.StorageClass = attrs.StorageClass
+	}
+	return info
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestObjectInfo(t *testing.T) {
+	attrs := &storage.ObjectAttrs{
+		Name:         "rarc/2022/01/09/rpki-20220109T183000Z.tgz",
+		MD5:          []byte{0x50, 0xe3, 0x90, 0x31, 0x56, 0xf5, 0xd2, 0xda, 0xc6, 0xc9, 0xf8, 0x96, 0x26, 0xd4, 0x8c, 0x75},
+		CRC32C:       0x3863cc2f,
+		Size:         11,
+		Generation:   1641753000000000,
+		Updated:      time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC),
+		StorageClass: "NEARLINE",
+	}
+	tests := []struct {
+		desc    string
+		fields  []string
+		want    *pb.ObjectInfo
+		wantErr bool
+	}{{
+		desc: "all fields",
+		want: &pb.ObjectInfo{
+			Filename:     "2022/01/09/rpki-20220109T183000Z.tgz",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
+			Size:         11,
+			Generation:   1641753000000000,
+			Updated:      "2022-01-09T18:30:00Z",
+			StorageClass: "NEARLINE",
+		},
+	}, {
+		desc:   "projection",
+		fields: []string{"md5sum", "size"},
+		want: &pb.ObjectInfo{
+			Filename: "2022/01/09/rpki-20220109T183000Z.tgz",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Size:     11,
+		},
+	}, {
+		desc:    "unknown field",
+		fields:  []string{"owner"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		fields, _, err := listSelection(test.fields)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: listSelection(%v) got nil err; want non-nil err", test.desc, test.fields)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: listSelection(%v) got err: %v; want nil err", test.desc, test.fields, err)
+			continue
+		}
+		if diff := cmp.Diff(objectInfo(attrs, "rarc/", fields), test.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: objectInfo() diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	ctx := context.Background()
+	var objs []fakestorage.Object
+	for _, name := range []string{
+		"rarc/2022/01/09/rpki-20220109T183000Z.tgz",
+		"rarc/2022/01/10/rpki-20220110T183000Z.tgz",
+		"rarc/2022/02/01/rpki-20220201T183000Z.tgz",
+		"bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		// Objects of the server under its reserved prefixes.
+		"_uploads/0123456789abcdef",
+		"_quarantine/bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2",
+	} {
+		objs = append(objs, fakestorage.Object{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: name},
+			Content:     []byte("Foo Bar Baz"),
+		})
+	}
+	srv := fakestorage.NewServer(objs)
+	defer srv.Stop()
+	r := rvServer{
+		conf: &config{Routes: map[string]*route{
+			"RPKI_RARC":  {Bucket: "foo", Prefix: "rarc/"},
+			"ROUTEVIEWS": {Bucket: "foo"},
+		}},
+		sc: srv.Client(),
+	}
+
+	// The objects of a month are listed a page at a time.
+	req := &pb.ListObjectsRequest{
+		Project:  pb.FileRequest_RPKI_RARC,
+		Prefix:   "2022/01/",
+		PageSize: 1,
+		Fields:   []string{"size"},
+	}
+	var got []*pb.ObjectInfo
+	for {
+		resp, err := r.ListObjects(ctx, req)
+		if err != nil {
+			t.Fatalf("ListObjects(%v) got err: %v; want nil err", req, err)
+		}
+		got = append(got, resp.GetObjects()...)
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	want := []*pb.ObjectInfo{
+		{Filename: "2022/01/09/rpki-20220109T183000Z.tgz", Size: 11},
+		{Filename: "2022/01/10/rpki-20220110T183000Z.tgz", Size: 11},
+	}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("ListObjects() diff (-got +want):\n%s", diff)
+	}
+
+	// The months are listed as prefixes.
+	resp, err := r.ListObjects(ctx, &pb.ListObjectsRequest{
+		Project:   pb.FileRequest_RPKI_RARC,
+		Prefix:    "2022/",
+		Delimiter: "/",
+	})
+	if err != nil {
+		t.Fatalf("ListObjects(delimiter) got err: %v; want nil err", err)
+	}
+	if diff := cmp.Diff(resp.GetPrefixes(), []string{"2022/01/", "2022/02/"}); diff != "" {
+		t.Errorf("ListObjects(delimiter) prefixes diff (-got +want):\n%s", diff)
+	}
+
+	// The reserved objects are not listed under a route of no prefix.
+	resp, err = r.ListObjects(ctx, &pb.ListObjectsRequest{Project: pb.FileRequest_ROUTEVIEWS, Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("ListObjects(no prefix) got err: %v; want nil err", err)
+	}
+	if diff := cmp.Diff(resp.GetPrefixes(), []string{"bgpdata/", "rarc/"}); diff != "" {
+		t.Errorf("ListObjects(no prefix) prefixes diff (-got +want):\n%s", diff)
+	}
+	resp, err = r.ListObjects(ctx, &pb.ListObjectsRequest{Project: pb.FileRequest_ROUTEVIEWS, Fields: []string{"size"}})
+	if err != nil {
+		t.Fatalf("ListObjects(no prefix) got err: %v; want nil err", err)
+	}
+	for _, o := range resp.GetObjects() {
+		if strings.HasPrefix(o.GetFilename(), "_") {
+			t.Errorf("ListObjects(no prefix) listed reserved %s", o.GetFilename())
+		}
+	}
+	if got := len(resp.GetObjects()); got != 4 {
+		t.Errorf("ListObjects(no prefix) listed %d objects; want 4", got)
+	}
+
+	if _, err := r.ListObjects(ctx, &pb.ListObjectsRequest{Project: pb.FileRequest_PCH}); err == nil {
+		t.Error("ListObjects(PCH) got nil err; want non-nil err")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// localPrefix is the prefix of the -storage of a local directory.
+const localPrefix = "local:"
+
+// localStore stores the objects of FileUpload under a local directory, for
+// development and tests without a GCP project. The layout is that of the
+// buckets: <dir>/<bucket>/<object>, and the attributes of each object are
+// kept in a JSON sidecar file next to it, .<name>.meta.json. It is safe for
+// concurrent use by a single server.
+type localStore struct {
+	dir string
+	// mu serializes the writes, so their conditions hold.
+	mu sync.Mutex
+	// now is the time of the writes, time.Now if nil.
+	now func() time.Time
+}
+
+// localMeta is the sidecar file of an object.
+type localMeta struct {
+	Generation      int64             `json:"generation"`
+	Metageneration  int64             `json:"metageneration"`
+	Size            int64             `json:"size"`
+	MD5             string            `json:"md5"`
+	CRC32C          uint32            `json:"crc32c"`
+	ContentType     string            `json:"content_type,omitempty"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Updated         time.Time         `json:"updated"`
+}
+
+// parseStorage returns the local store of the -storage flag, nil if it is
+// empty, the server stores to cloud-storage, or to the s3 store of the
+// config.
+func parseStorage(s string) (*localStore, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, localPrefix) {
+		return nil, fmt.Errorf("bad storage %q, want local:<dir>", s)
+	}
+	dir, err := filepath.Abs(strings.TrimPrefix(s, localPrefix))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("storage %s is not a directory", dir)
+	}
+	return &localStore{dir: dir}, nil
+}
+
+// paths returns the paths of the content and sidecar files of an object.
+func (s *localStore) paths(bkt, obj string) (string, string) {
+	p := filepath.Join(s.dir, bkt, filepath.FromSlash(obj))
+	return p, filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".meta.json")
+}
+
+// checkBucket creates the directory of a bucket if it is missing, the
+// buckets of a local store need no setup.
+func (s *localStore) checkBucket(ctx context.Context, bkt string) error {
+	if bkt == "" || strings.ContainsAny(bkt, `/\`) || bkt == "." || bkt == ".." {
+		return fmt.Errorf("bad bucket %q", bkt)
+	}
+	return os.MkdirAll(filepath.Join(s.dir, bkt), 0755)
+}
+
+func (s *localStore) attrs(ctx context.Context, bkt, obj string) (*storage.ObjectAttrs, error) {
+	_, side := s.paths(bkt, obj)
+	b, err := ioutil.ReadFile(side)
+	if os.IsNotExist(err) {
+		return nil, storage.ErrObjectNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &localMeta{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("bad sidecar %s: %v", side, err)
+	}
+	sum, err := hex.DecodeString(m.MD5)
+	if err != nil {
+		return nil, fmt.Errorf("bad sidecar %s: %v", side, err)
+	}
+	return &storage.ObjectAttrs{
+		Bucket:          bkt,
+		Name:            obj,
+		Generation:      m.Generation,
+		Metageneration:  m.Metageneration,
+		Size:            m.Size,
+		MD5:             sum,
+		CRC32C:          m.CRC32C,
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		Metadata:        m.Metadata,
+		Updated:         m.Updated,
+	}, nil
+}
+
+func (s *localStore) time() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// localObjects is the objectStore of a server on its localStore, the metadata
+// of the objects is that of the server.
+type localObjects struct {
+	*localStore
+	r rvServer
+}
+
+// write writes the content of the object, and then its sidecar file, each to
+// a temporary file renamed over the last. The generations are the times of
+// the writes in microseconds, as those of cloud-storage, and the conditions
+// of the write are checked against the sidecar file. The content is verified
+// against the crc32c, if set.
+func (s localObjects) write(ctx context.Context, bkt, obj string, req *pb.FileRequest, conds storage.Conditions) (*storage.ObjectAttrs, error) {
+	b := req.GetContent()
+	crc := crc32.Checksum(b, castagnoli)
+	if want := req.GetCrc32C(); want != "" {
+		v, err := parseCRC32C(want)
+		if err != nil {
+			return nil, err
+		}
+		if v != crc {
+			return nil, checkSum("crc32c", strings.ToLower(want), fmt.Sprintf("%08x", crc))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, err := s.attrs(ctx, bkt, obj)
+	switch {
+	case err == storage.ErrObjectNotExist:
+		cur = nil
+	case err != nil:
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, obj)
+	}
+	if conds.DoesNotExist && cur != nil || conds.GenerationMatch != 0 && (cur == nil || cur.Generation != conds.GenerationMatch) {
+		return nil, writtenMeanwhile(req.GetFilename(), errors.New("the conditions of the write do not hold"))
+	}
+
+	now := s.time().UTC()
+	gen := now.UnixNano() / int64(time.Microsecond)
+	if cur != nil && gen <= cur.Generation {
+		gen = cur.Generation + 1
+	}
+	sum := md5.Sum(b)
+	ct, enc := s.r.contentHeaders(req, obj)
+	m := &localMeta{
+		Generation:      gen,
+		Metageneration:  1,
+		Size:            int64(len(b)),
+		MD5:             hex.EncodeToString(sum[:]),
+		CRC32C:          crc,
+		ContentType:     ct,
+		ContentEncoding: enc,
+		Metadata:        s.r.objectMeta(ctx, req),
+		Updated:         now,
+	}
+	side, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	p, sp := s.paths(bkt, obj)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, obj)
+	}
+	if err := writeFile(p, b); err != nil {
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, obj)
+	}
+	if err := writeFile(sp, side); err != nil {
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, obj)
+	}
+	return s.attrs(ctx, bkt, obj)
+}
+
+// writeFile writes a file to a temporary file of its directory, renamed over
+// the file, so readers never see a partial file.
+func writeFile(p string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-"+filepath.Base(p))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// localURL returns the file URL of an object of a local store.
+func (s *localStore) localURL(bkt, obj string) string {
+	return "file://" + path.Join(filepath.ToSlash(s.dir), bkt, obj)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseStorage(t *testing.T) {
+	dir := t.TempDir()
+	file := tempFile(t, "file", nil)
+	tests := []struct {
+		desc    string
+		storage string
+		wantDir string
+		wantErr bool
+	}{{
+		desc: "cloud-storage",
+	}, {
+		desc:    "local",
+		storage: "local:" + dir,
+		wantDir: dir,
+	}, {
+		desc:    "unknown backend",
+		storage: "gcs:" + dir,
+		wantErr: true,
+	}, {
+		desc:    "missing directory",
+		storage: "local:" + filepath.Join(dir, "missing"),
+		wantErr: true,
+	}, {
+		desc:    "not a directory",
+		storage: "local:" + file,
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		s, err := parseStorage(test.storage)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("%s: parseStorage(%q) got err: %v; want nil err", test.desc, test.storage, err)
+		case err == nil && test.wantErr:
+			t.Errorf("%s: parseStorage(%q) got nil err, want err", test.desc, test.storage)
+		case err == nil && test.wantDir == "" && s != nil:
+			t.Errorf("%s: parseStorage(%q) = %v, want nil", test.desc, test.storage, s)
+		case err == nil && test.wantDir != "" && (s == nil || s.dir != test.wantDir):
+			t.Errorf("%s: parseStorage(%q) = %v, want dir %s", test.desc, test.storage, s, test.wantDir)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	s := &localStore{dir: t.TempDir()}
+	store := rvServer{conf: &config{}, local: s}.objects()
+
+	if err := store.checkBucket(ctx, "foo"); err != nil {
+		t.Fatalf("checkBucket() got err: %v", err)
+	}
+	if err := store.checkBucket(ctx, "../foo"); err == nil {
+		t.Errorf("checkBucket(../foo) got nil err, want err")
+	}
+	if _, err := store.attrs(ctx, "foo", "a/b.bz2"); err != storage.ErrObjectNotExist {
+		t.Errorf("attrs() of a missing object got err: %v, want %v", err, storage.ErrObjectNotExist)
+	}
+
+	content := []byte("Foo Bar Baz")
+	req := &pb.FileRequest{Filename: "a/b.bz2", Content: content, Project: pb.FileRequest_ROUTEVIEWS, Crc32C: "3863cc2f"}
+	attrs, err := store.write(ctx, "foo", "a/b.bz2", req, storage.Conditions{DoesNotExist: true})
+	if err != nil {
+		t.Fatalf("write() got err: %v", err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(s.dir, "foo", "a", "b.bz2"))
+	if err != nil {
+		t.Fatalf("write() did not write the content: %v", err)
+	}
+	if diff := cmp.Diff(got, content); diff != "" {
+		t.Errorf("write() got content diff (-got +want):\n%s", diff)
+	}
+	sum := md5.Sum(content)
+	if hex.EncodeToString(attrs.MD5) != hex.EncodeToString(sum[:]) || attrs.CRC32C != 0x3863cc2f || attrs.Size != int64(len(content)) {
+		t.Errorf("write() got md5 %x, crc32c %x, size %d; want %x, 3863cc2f, %d", attrs.MD5, attrs.CRC32C, attrs.Size, sum, len(content))
+	}
+	if got, want := attrs.Metadata[converter.ProjectMetadataKey], pb.FileRequest_ROUTEVIEWS.String(); attrs.ContentType != "application/x-bzip2" || got != want {
+		t.Errorf("write() got content type %q, project %q; want application/x-bzip2, %s", attrs.ContentType, got, want)
+	}
+
+	// The conditions of the writes are checked against the sidecar file.
+	for _, conds := range []storage.Conditions{{DoesNotExist: true}, {GenerationMatch: attrs.Generation + 1}} {
+		if _, err := store.write(ctx, "foo", "a/b.bz2", req, conds); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("write(%+v) of an existing object got code %v, want %v", conds, status.Code(err), codes.FailedPrecondition)
+		}
+	}
+	replaced, err := store.write(ctx, "foo", "a/b.bz2", req, storage.Conditions{GenerationMatch: attrs.Generation})
+	if err != nil {
+		t.Fatalf("write() of the generation got err: %v", err)
+	}
+	if replaced.Generation <= attrs.Generation {
+		t.Errorf("write() got generation %d, want more than %d", replaced.Generation, attrs.Generation)
+	}
+
+	// The content is verified against the crc32c.
+	req.Crc32C = "00000000"
+	if _, err := store.write(ctx, "foo", "a/c.bz2", req, storage.Conditions{DoesNotExist: true}); reasonOf(err) != reasonChecksumMismatch {
+		t.Errorf("write() of a bad crc32c got reason %q, want %q", reasonOf(err), reasonChecksumMismatch)
+	}
+}
+
+func TestFileUploadLocal(t *testing.T) {
+	ctx := context.Background()
+	local := &localStore{dir: t.TempDir()}
+	conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+	fs, err := newRVServer(ctx, createConf(t, conf), nil, local)
+	if err != nil {
+		t.Fatalf("newRVServer() got err: %v", err)
+	}
+	req := &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Content:  []byte("Foo Bar Baz"),
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}
+	resp, err := fs.FileUpload(ctx, req)
+	if err != nil || resp.GetStatus() != pb.FileResponse_SUCCESS {
+		t.Fatalf("FileUpload() = %v, %v; want SUCCESS", resp, err)
+	}
+	if want := "file://" + filepath.ToSlash(local.dir) + "/foo/bar"; resp.GetObject() != want {
+		t.Errorf("FileUpload() got object %q, want %q", resp.GetObject(), want)
+	}
+	if resp, err := fs.FileUpload(ctx, req); err != nil || resp.GetStatus() != pb.FileResponse_UNCHANGED {
+		t.Errorf("FileUpload(resent) = %v, %v; want UNCHANGED", resp, err)
+	}
+	if _, err := fs.StartUpload(ctx, &pb.StartUploadRequest{}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("StartUpload() got code %v, want %v", status.Code(err), codes.Unimplemented)
+	}
+
+	// The options only cloud-storage supports are rejected.
+	conf.Replicas = map[string][]string{"foo": {"foo-dr"}}
+	if _, err := newRVServer(ctx, createConf(t, conf), nil, local); err == nil {
+		t.Errorf("newRVServer() with replicas got nil err, want err")
+	}
+}
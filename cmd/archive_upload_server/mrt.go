@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// errUploadAborted aborts the validation of an upload which failed otherwise.
+var errUploadAborted = errors.New("upload aborted")
+
+// checksMRT reports whether the content of a file is validated as MRT before
+// it is stored: with -validate_mrt, the ROUTEVIEWS updates archives. Other
+// files of the project are stored as they are.
+func (r rvServer) checksMRT(proj pb.FileRequest_Project, fn string) bool {
+	if !r.validateMRT || proj != pb.FileRequest_ROUTEVIEWS {
+		return false
+	}
+	_, ok := archiveprofile.ParseProject(proj, fn)
+	return ok
+}
+
+// invalidFormat returns the response to content which is not a valid MRT
+// archive, if err is a *converter.FormatError.
+func invalidFormat(err error) (*pb.FileResponse, bool) {
+	var fe *converter.FormatError
+	if !errors.As(err, &fe) {
+		return nil, false
+	}
+	return &pb.FileResponse{Status: pb.FileResponse_INVALID_FORMAT, ErrorMessage: fe.Error()}, true
+}
+
+// checkMRT validates the MRT archive of a file.
+func checkMRT(ctx context.Context, fn string, rd io.Reader) error {
+	_, span := tracer.Start(ctx, "validate.mrt")
+	_, err := converter.ValidateArchive(fn, rd)
+	endSpan(span, err)
+	return err
+}
+
+// checkStoredMRT validates the MRT archive of a file in an object, ie: staged
+// by a signed upload.
+func checkStoredMRT(ctx context.Context, obj *storage.ObjectHandle, fn string) error {
+	rc, err := obj.NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return checkMRT(ctx, fn, rc)
+}
+
+// mrtCheck validates the MRT archive of a file as its content is written, ie:
+// streamed through to cloud-storage, so the content is read once.
+type mrtCheck struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// newMRTCheck starts the validation of the content written to the check. The
+// check must be closed, by result or abort.
+func newMRTCheck(ctx context.Context, fn string) *mrtCheck {
+	pr, pw := io.Pipe()
+	c := &mrtCheck{pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := checkMRT(ctx, fn, pr)
+		// The rest of the content is drained, invalid content is still
+		// written, until the write is aborted.
+		io.Copy(io.Discard, pr)
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *mrtCheck) Write(p []byte) (int, error) {
+	return c.pw.Write(p)
+}
+
+// result ends the content, and returns the result of its validation.
+func (c *mrtCheck) result() error {
+	c.pw.Close()
+	return <-c.done
+}
+
+// abort ends the validation of content which failed otherwise. It is a no-op
+// once the result is returned.
+func (c *mrtCheck) abort() {
+	c.pw.CloseWithError(errUploadAborted)
+}
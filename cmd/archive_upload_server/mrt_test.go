@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// fakeMRT returns a gzip updates archive of a BGP4MP record, with a body of
+// length n.
+func fakeMRT(t *testing.T, n int) []byte {
+	t.Helper()
+	rec := make([]byte, 12+n)
+	binary.BigEndian.PutUint32(rec[0:4], 1639375940)
+	binary.BigEndian.PutUint16(rec[4:6], 16)
+	binary.BigEndian.PutUint16(rec[6:8], 4)
+	binary.BigEndian.PutUint32(rec[8:12], uint32(n))
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestChecksMRT(t *testing.T) {
+	const updates = "route-views4/bgpdata/2021.12/UPDATES/updates.20211212.0015.gz"
+	tests := []struct {
+		desc     string
+		validate bool
+		proj     pb.FileRequest_Project
+		fn       string
+		want     bool
+	}{{
+		desc:     "updates archive",
+		validate: true,
+		proj:     pb.FileRequest_ROUTEVIEWS,
+		fn:       updates,
+		want:     true,
+	}, {
+		desc: "disabled",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		fn:   updates,
+	}, {
+		desc:     "not an archive",
+		validate: true,
+		proj:     pb.FileRequest_ROUTEVIEWS,
+		fn:       "route-views4/README.txt",
+	}, {
+		desc:     "other project",
+		validate: true,
+		proj:     pb.FileRequest_RPKI_RARC,
+		fn:       updates,
+	}}
+	for _, test := range tests {
+		r := rvServer{validateMRT: test.validate}
+		if got := r.checksMRT(test.proj, test.fn); got != test.want {
+			t.Errorf("%s: checksMRT(%v, %q) = %v, want %v", test.desc, test.proj, test.fn, got, test.want)
+		}
+	}
+}
+
+func TestMRTCheck(t *testing.T) {
+	const fn = "bgpdata/2021.12/UPDATES/updates.20211212.0015.gz"
+	valid := fakeMRT(t, 20)
+	tests := []struct {
+		desc    string
+		content []byte
+		wantErr bool
+	}{{
+		desc:    "valid",
+		content: valid,
+	}, {
+		desc:    "truncated",
+		content: valid[:len(valid)-4],
+		wantErr: true,
+	}, {
+		desc:    "not MRT",
+		content: []byte("Foo Bar Baz"),
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		c := newMRTCheck(context.Background(), fn)
+		// The content is written in chunks, as it is received.
+		for b := test.content; len(b) > 0; {
+			n := 5
+			if n > len(b) {
+				n = len(b)
+			}
+			if _, err := c.Write(b[:n]); err != nil {
+				t.Fatalf("%s: Write() got err: %v", test.desc, err)
+			}
+			b = b[n:]
+		}
+		err := c.result()
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: result() got err %v, want err: %v", test.desc, err, test.wantErr)
+		}
+		if _, ok := invalidFormat(err); ok != test.wantErr {
+			t.Errorf("%s: invalidFormat(%v) = %v, want %v", test.desc, err, ok, test.wantErr)
+		}
+		c.abort()
+	}
+
+	// An aborted check does not block the writes.
+	c := newMRTCheck(context.Background(), fn)
+	c.abort()
+	if _, err := c.Write(valid); err == nil {
+		t.Error("Write() after abort() got nil err, want err")
+	}
+}
+
+func TestStoreFileInvalidMRT(t *testing.T) {
+	content := fakeMRT(t, 20)
+	content = content[:len(content)-4]
+	sum := md5.Sum(content)
+	req := &pb.FileRequest{
+		Filename: "route-views4/bgpdata/2021.12/UPDATES/updates.20211212.0015.gz",
+		Project:  pb.FileRequest_ROUTEVIEWS,
+		Content:  content,
+		Md5Sum:   hex.EncodeToString(sum[:]),
+	}
+	// The content is rejected before it is routed to storage.
+	r := rvServer{conf: &config{}, validateMRT: true}
+	resp, err := r.storeFile(context.Background(), req, &pb.FileResponse{}, &uploadRecord{})
+	if err != nil {
+		t.Fatalf("storeFile() got err: %v; want nil err", err)
+	}
+	if resp.GetStatus() != pb.FileResponse_INVALID_FORMAT || resp.GetErrorMessage() == "" {
+		t.Errorf("storeFile() = %v, want INVALID_FORMAT with an error message", resp)
+	}
+}
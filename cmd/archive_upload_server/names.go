@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// maxNameLen is the max length of an object name in cloud-storage, in bytes.
+const maxNameLen = 1024
+
+// profilePrefix marks a filename pattern which is the layout of an archive
+// profile, ie: profile:routeviews, see archiveprofile.Lookup.
+const profilePrefix = "profile:"
+
+// namePatterns are the filename patterns of a project, a filename must match
+// one of them. The config sets a single pattern or a list.
+type namePatterns []string
+
+// UnmarshalYAML unmarshals a single pattern, or a list of them.
+func (n *namePatterns) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var p string
+	if err := unmarshal(&p); err == nil {
+		*n = namePatterns{p}
+		return nil
+	}
+	var ps []string
+	if err := unmarshal(&ps); err != nil {
+		return err
+	}
+	*n = ps
+	return nil
+}
+
+// compileNamePatterns compiles the filename patterns of the projects in the
+// config into a regexp of each project, which matches the whole filename if
+// one of the patterns does. A pattern with the profilePrefix is the layout of
+// the archive profile.
+func compileNamePatterns(patterns map[string]namePatterns) (map[pb.FileRequest_Project]*regexp.Regexp, error) {
+	res := map[pb.FileRequest_Project]*regexp.Regexp{}
+	for proj, ps := range patterns {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return nil, fmt.Errorf("bad project %s of filename patterns", proj)
+		}
+		if len(ps) == 0 {
+			return nil, fmt.Errorf("bad filename patterns of %s: none", proj)
+		}
+		var alts []string
+		for _, p := range ps {
+			if strings.HasPrefix(p, profilePrefix) {
+				prof, err := archiveprofile.Lookup(strings.TrimPrefix(p, profilePrefix))
+				if err != nil {
+					return nil, fmt.Errorf("bad filename pattern of %s: %v", proj, err)
+				}
+				p = prof.Pattern.String()
+			}
+			if _, err := regexp.Compile(p); err != nil {
+				return nil, fmt.Errorf("bad filename pattern of %s: %v", proj, err)
+			}
+			alts = append(alts, `(?:`+p+`)`)
+		}
+		re, err := regexp.Compile(`^(?:` + strings.Join(alts, "|") + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("bad filename patterns of %s: %v", proj, err)
+		}
+		res[pb.FileRequest_Project(pb.FileRequest_Project_value[proj])] = re
+	}
+	return res, nil
+}
+
+// cleanFilename returns the canonical form of a filename: without leading,
+// repeated or trailing slashes, nor "." segments, ie: /bgpdata//./updates is
+// bgpdata/updates. Filenames which are not valid UTF-8, carry control
+// characters or a ".." segment, or are under a prefix the server stores its
+// own objects under, ie: _uploads/, are rejected.
+func cleanFilename(fn string) (string, error) {
+	if !utf8.ValidString(fn) {
+		return "", fmt.Errorf("filename(%q) is not valid UTF-8", fn)
+	}
+	if strings.IndexFunc(fn, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("filename(%q) has a control character", fn)
+	}
+	for _, seg := range strings.Split(fn, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("filename(%q) has a .. segment", fn)
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+fn), "/")
+	switch {
+	case clean == "":
+		return "", fmt.Errorf("filename(%q) is empty", fn)
+	case strings.HasPrefix(clean, "_"):
+		return "", fmt.Errorf("filename(%q) is reserved, it starts with _", fn)
+	case strings.HasPrefix(clean, ".well-known/acme-challenge/"):
+		return "", fmt.Errorf("filename(%q) is reserved by cloud-storage", fn)
+	case len(clean) > maxNameLen:
+		return "", fmt.Errorf("filename(%q) exceeds %d bytes", fn, maxNameLen)
+	}
+	return clean, nil
+}
+
+// checkFilename returns the canonical form of the filename of an upload, see
+// cleanFilename, which must match the filename patterns of its project, if
+// any. The date and time a pattern captures, in the named groups of an
+// archive profile, ie: "date" (YYYYMMDD) and "time" (HHMM), must be valid as
+// well. It fails with InvalidArgument, of reason NAMING_POLICY for a filename
+// off the patterns.
+func (r rvServer) checkFilename(proj pb.FileRequest_Project, fn string) (string, error) {
+	clean, err := cleanFilename(fn)
+	if err != nil {
+		return "", badField("filename", reasonInvalidField, "%v", err)
+	}
+	re, ok := r.namePatterns()[proj]
+	if !ok {
+		return clean, nil
+	}
+	// A profile of the pattern parses the date and time the filename carries.
+	if _, ok := (&archiveprofile.Profile{Pattern: re}).Parse(clean); !ok {
+		return "", badField("filename", reasonNamingPolicy, "filename(%q) does not match the naming policy of %s: %s", clean, proj, re)
+	}
+	return clean, nil
+}
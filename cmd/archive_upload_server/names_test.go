@@ -0,0 +1,197 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCleanFilename(t *testing.T) {
+	tests := []struct {
+		desc    string
+		fn      string
+		want    string
+		wantErr bool
+	}{{
+		desc: "canonical",
+		fn:   "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		want: "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+	}, {
+		desc: "absolute",
+		fn:   "/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		want: "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+	}, {
+		desc: "repeated slashes and dot segments",
+		fn:   "route-views4//bgpdata/./2022.01/UPDATES/updates.20220109.1830.bz2",
+		want: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+	}, {
+		desc: "dots in a name",
+		fn:   "rpki/..foo..",
+		want: "rpki/..foo..",
+	}, {
+		desc:    "path traversal",
+		fn:      "bgpdata/../../etc/passwd",
+		wantErr: true,
+	}, {
+		desc:    "leading traversal",
+		fn:      "../bar",
+		wantErr: true,
+	}, {
+		desc:    "only slashes",
+		fn:      "//",
+		wantErr: true,
+	}, {
+		desc:    "control character",
+		fn:      "bgpdata/bar\n",
+		wantErr: true,
+	}, {
+		desc:    "invalid UTF-8",
+		fn:      "bgpdata/\xff",
+		wantErr: true,
+	}, {
+		desc:    "reserved prefix",
+		fn:      "_sessions/foo/session",
+		wantErr: true,
+	}, {
+		desc:    "reserved by cloud-storage",
+		fn:      ".well-known/acme-challenge/foo",
+		wantErr: true,
+	}, {
+		desc:    "too long",
+		fn:      strings.Repeat("a", maxNameLen+1),
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		got, err := cleanFilename(test.fn)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: cleanFilename(%q) got err %v, want err: %v", test.desc, test.fn, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: cleanFilename(%q) = %q, want %q", test.desc, test.fn, got, test.want)
+		}
+	}
+}
+
+func TestCompileNamePatterns(t *testing.T) {
+	if _, err := compileNamePatterns(map[string]namePatterns{"FOO": {`.*`}}); err == nil {
+		t.Error("compileNamePatterns(unknown project) got nil err, want err")
+	}
+	if _, err := compileNamePatterns(map[string]namePatterns{"ROUTEVIEWS": {`.*`, `(`}}); err == nil {
+		t.Error("compileNamePatterns(bad regexp) got nil err, want err")
+	}
+	if _, err := compileNamePatterns(map[string]namePatterns{"ROUTEVIEWS": {"profile:foo"}}); err == nil {
+		t.Error("compileNamePatterns(unknown profile) got nil err, want err")
+	}
+	if _, err := compileNamePatterns(map[string]namePatterns{"ROUTEVIEWS": {}}); err == nil {
+		t.Error("compileNamePatterns(no patterns) got nil err, want err")
+	}
+}
+
+func TestNamePatternsYAML(t *testing.T) {
+	var got map[string]namePatterns
+	conf := "ROUTEVIEWS: 'bgpdata/.*'\nRPKI_RARC: ['\\d{4}/.*', 'latest/.*']\n"
+	if err := yaml.Unmarshal([]byte(conf), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() got err: %v; want nil err", err)
+	}
+	want := map[string]namePatterns{
+		"ROUTEVIEWS": {`bgpdata/.*`},
+		"RPKI_RARC":  {`\d{4}/.*`, `latest/.*`},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("yaml.Unmarshal() got diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestCheckFilename(t *testing.T) {
+	names, err := compileNamePatterns(map[string]namePatterns{
+		"ROUTEVIEWS": {`([^/]+/)?bgpdata/\d{4}\.\d{2}/(UPDATES|RIBS)/[^/]+\.bz2`},
+		"RIPE_RIS":   {"profile:ris"},
+		"RPKI_RARC": {
+			`(?P<year>\d{4})/(?P<month>\d{2})/(?P<day>\d{2})/[^/]+\.tgz`,
+			`latest/[^/]+\.json`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rvServer{names: names}
+	tests := []struct {
+		desc string
+		proj pb.FileRequest_Project
+		fn   string
+		want string
+		code codes.Code
+	}{{
+		desc: "matches",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		fn:   "/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		want: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+	}, {
+		// The pattern matches the whole filename.
+		desc: "partial match",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		fn:   "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2.tmp",
+		code: codes.InvalidArgument,
+	}, {
+		desc: "does not match",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		fn:   "route-views4/README",
+		code: codes.InvalidArgument,
+	}, {
+		desc: "project without a pattern",
+		proj: pb.FileRequest_PCH,
+		fn:   "rpki/2022/01/09/output.tgz",
+		want: "rpki/2022/01/09/output.tgz",
+	}, {
+		desc: "profile",
+		proj: pb.FileRequest_RIPE_RIS,
+		fn:   "rrc00/2022.01/updates.20220109.1830.gz",
+		want: "rrc00/2022.01/updates.20220109.1830.gz",
+	}, {
+		desc: "invalid time of the profile",
+		proj: pb.FileRequest_RIPE_RIS,
+		fn:   "rrc00/2022.01/updates.20220109.2575.gz",
+		code: codes.InvalidArgument,
+	}, {
+		desc: "first of the patterns",
+		proj: pb.FileRequest_RPKI_RARC,
+		fn:   "2022/01/09/output.tgz",
+		want: "2022/01/09/output.tgz",
+	}, {
+		desc: "second of the patterns",
+		proj: pb.FileRequest_RPKI_RARC,
+		fn:   "latest/roas.json",
+		want: "latest/roas.json",
+	}, {
+		desc: "invalid date",
+		proj: pb.FileRequest_RPKI_RARC,
+		fn:   "2022/13/09/output.tgz",
+		code: codes.InvalidArgument,
+	}, {
+		desc: "path traversal",
+		proj: pb.FileRequest_RPKI_RARC,
+		fn:   "../rpki",
+		code: codes.InvalidArgument,
+	}}
+	for _, test := range tests {
+		got, err := r.checkFilename(test.proj, test.fn)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%s: checkFilename(%q) got err %v, want %v", test.desc, test.fn, err, test.code)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: checkFilename(%q) = %q, want %q", test.desc, test.fn, got, test.want)
+		}
+	}
+
+	_, err = r.checkFilename(pb.FileRequest_ROUTEVIEWS, "route-views4/README")
+	if got := reasonOf(err); got != reasonNamingPolicy {
+		t.Errorf("checkFilename(off the policy) got reason %q, want %q", got, reasonNamingPolicy)
+	}
+}
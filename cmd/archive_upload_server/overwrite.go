@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// checkOverwrite looks up the object of a file before it is written. It
+// returns the attributes of the object, nil if it does not exist, and
+// whether its content is unchanged, so that the write is skipped.
+//
+// Archived files never change: the write of other content over an object is
+// refused with FailedPrecondition, unless the request sets allow_overwrite and
+// the caller is an admin of the project. The generation replaced is set in
+// the audit record. A request with if_generation_match fails unless the
+// object is at that generation, see checkGeneration.
+func (r rvServer) checkOverwrite(ctx context.Context, bkt, obj string, meta *pb.FileRequest, rec *uploadRecord) (*storage.ObjectAttrs, bool, error) {
+	attrs, ok := r.unchanged(ctx, bkt, obj, meta.GetMd5Sum())
+	if err := checkGeneration(meta, attrs); err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		// The object is about to be written, its attributes are looked up
+		// again until the write replaces them.
+		r.recent.drop(bkt, obj)
+	}
+	if ok || attrs == nil {
+		return attrs, ok, nil
+	}
+	sum := uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	if !meta.GetAllowOverwrite() {
+		return nil, false, withReason(status.Newf(codes.FailedPrecondition,
+			"%s exists with md5sum(%q), archived files are not replaced without allow_overwrite", meta.GetFilename(), sum),
+			reasonObjectExists, map[string]string{"md5sum": sum, "generation": strconv.FormatInt(attrs.Generation, 10)}).Err()
+	}
+	if err := r.authorizeAdmin(ctx, meta.GetProject().String()); err != nil {
+		return nil, false, err
+	}
+	rec.Replaced = attrs.Generation
+	glog.Warningf("Replacing gs://%s/%s generation(%d) md5sum(%q) with md5sum(%q)", bkt, obj, attrs.Generation, sum, meta.GetMd5Sum())
+	return attrs, false, nil
+}
+
+// writeConditions returns the preconditions of the write of an object, of the
+// attributes checkOverwrite found: the object looked up is the one replaced,
+// and an object written meanwhile, or missed by a failed lookup, is not.
+func writeConditions(attrs *storage.ObjectAttrs) storage.Conditions {
+	if attrs == nil {
+		return storage.Conditions{DoesNotExist: true}
+	}
+	return storage.Conditions{GenerationMatch: attrs.Generation}
+}
+
+// writtenMeanwhile returns the error of a write which failed its
+// preconditions, the object of the file was written meanwhile.
+func writtenMeanwhile(fn string, err error) error {
+	return withReason(status.Newf(codes.FailedPrecondition, "%s was written meanwhile, retry to compare it: %v", fn, err),
+		reasonWrittenMeanwhile, nil).Err()
+}
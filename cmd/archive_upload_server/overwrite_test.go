@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestWriteConditions(t *testing.T) {
+	if got, want := writeConditions(nil), (storage.Conditions{DoesNotExist: true}); got != want {
+		t.Errorf("writeConditions(nil) = %+v, want %+v", got, want)
+	}
+	attrs := &storage.ObjectAttrs{Generation: 42}
+	if got, want := writeConditions(attrs), (storage.Conditions{GenerationMatch: 42}); got != want {
+		t.Errorf("writeConditions(%d) = %+v, want %+v", attrs.Generation, got, want)
+	}
+}
+
+func TestCheckOverwrite(t *testing.T) {
+	srv := fakestorage.NewServer([]fakestorage.Object{storedObject("foo", "rarc/bar", []byte("Foo Bar Baz"))})
+	defer srv.Stop()
+	obj, err := srv.GetObject("foo", "rarc/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rvServer{
+		conf: &config{
+			Routes: map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}},
+			Admins: map[string][]string{"5678": {"RPKI_RARC"}},
+		},
+		sc:       srv.Client(),
+		validate: fakeValidate,
+	}
+
+	tests := []struct {
+		desc          string
+		auth          string
+		obj           string
+		req           *pb.FileRequest
+		wantExisting  bool
+		wantUnchanged bool
+		wantReplaced  int64
+		want          codes.Code
+	}{{
+		desc: "new file",
+		obj:  "rarc/baz",
+		req:  &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "baz", Md5Sum: "0123"},
+	}, {
+		desc:          "unchanged",
+		obj:           "rarc/bar",
+		req:           &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "50e3903156f5d2dac6c9f89626d48c75"},
+		wantExisting:  true,
+		wantUnchanged: true,
+	}, {
+		desc: "changed",
+		obj:  "rarc/bar",
+		req:  &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "0123"},
+		want: codes.FailedPrecondition,
+	}, {
+		desc: "allow_overwrite of a caller",
+		auth: "Bearer mirror",
+		obj:  "rarc/bar",
+		req:  &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "0123", AllowOverwrite: true},
+		want: codes.PermissionDenied,
+	}, {
+		desc:         "allow_overwrite of an admin",
+		auth:         "Bearer robot",
+		obj:          "rarc/bar",
+		req:          &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "0123", AllowOverwrite: true},
+		wantExisting: true,
+		wantReplaced: obj.Generation,
+	}, {
+		desc:         "if_generation_match of the object",
+		auth:         "Bearer robot",
+		obj:          "rarc/bar",
+		req:          &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "0123", AllowOverwrite: true, IfGenerationMatch: obj.Generation},
+		wantExisting: true,
+		wantReplaced: obj.Generation,
+	}, {
+		desc: "stale if_generation_match",
+		auth: "Bearer robot",
+		obj:  "rarc/bar",
+		req:  &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar", Md5Sum: "0123", AllowOverwrite: true, IfGenerationMatch: obj.Generation + 1},
+		want: codes.FailedPrecondition,
+	}}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.auth != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+		}
+		rec := &uploadRecord{}
+		existing, unchanged, err := r.checkOverwrite(ctx, "foo", test.obj, test.req, rec)
+		if got := status.Code(err); got != test.want {
+			t.Errorf("%s: checkOverwrite() got err %v, want %v", test.desc, err, test.want)
+			continue
+		}
+		if (existing != nil) != test.wantExisting || unchanged != test.wantUnchanged {
+			t.Errorf("%s: checkOverwrite() = %v, %v, want existing: %v, unchanged: %v", test.desc, existing, unchanged, test.wantExisting, test.wantUnchanged)
+		}
+		if rec.Replaced != test.wantReplaced {
+			t.Errorf("%s: checkOverwrite() recorded replaced generation %d, want %d", test.desc, rec.Replaced, test.wantReplaced)
+		}
+	}
+}
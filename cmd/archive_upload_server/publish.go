@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	"google.golang.org/api/pubsub/v1"
+)
+
+// uploadEventType is the eventType attribute of the upload notifications, the
+// other attributes match those of cloud-storage notifications, so the
+// converter handles either.
+const uploadEventType = "RV_UPLOAD"
+
+// publishTimeout bounds the publish of a notification, the upload succeeded
+// already and is not held up for long.
+const publishTimeout = 10 * time.Second
+
+// topicPattern matches the name of a Pub/Sub topic.
+var topicPattern = regexp.MustCompile(`^projects/[^/]+/topics/[^/]+$`)
+
+// uploadEvent is the data of the notification of a stored object, JSON
+// encoded.
+type uploadEvent struct {
+	Bucket     string `json:"bucket"`
+	Object     string `json:"object"`
+	Project    string `json:"project"`
+	MD5        string `json:"md5"`
+	Size       int64  `json:"size"`
+	Generation int64  `json:"generation"`
+}
+
+// publishFunc publishes a message with the attributes to the topic of the
+// notifications.
+type publishFunc func(ctx context.Context, data []byte, attrs map[string]string) error
+
+// newPublisher returns a publishFunc to a Pub/Sub topic,
+// projects/<project>/topics/<topic>.
+func newPublisher(ctx context.Context, topic string) (publishFunc, error) {
+	if !topicPattern.MatchString(topic) {
+		return nil, fmt.Errorf("bad topic %q, want projects/<project>/topics/<topic>", topic)
+	}
+	svc, err := pubsub.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub.NewService: %v", err)
+	}
+	return func(ctx context.Context, data []byte, attrs map[string]string) error {
+		_, err := svc.Projects.Topics.Publish(topic, &pubsub.PublishRequest{
+			Messages: []*pubsub.PubsubMessage{{
+				Data:       base64.StdEncoding.EncodeToString(data),
+				Attributes: attrs,
+			}},
+		}).Context(ctx).Do()
+		return err
+	}, nil
+}
+
+// notifyStored publishes the notification of an object stored, if r.publish
+// is set. A failure is logged, the object is stored regardless and the
+// notifications of the bucket, if any, still trigger the consumers.
+func (r rvServer) notifyStored(ctx context.Context, proj string, attrs *storage.ObjectAttrs) {
+	if r.publish == nil || attrs == nil {
+		return
+	}
+	data, err := json.Marshal(&uploadEvent{
+		Bucket:     attrs.Bucket,
+		Object:     attrs.Name,
+		Project:    proj,
+		MD5:        uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs),
+		Size:       attrs.Size,
+		Generation: attrs.Generation,
+	})
+	if err != nil {
+		glog.Errorf("failed to marshal the notification of %s/%s: %v", attrs.Bucket, attrs.Name, err)
+		return
+	}
+	ctx, span := tracer.Start(ctx, "pubsub.publish", objectAttrs(attrs.Bucket, attrs.Name))
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	err = r.publish(ctx, data, map[string]string{
+		"bucketId":  attrs.Bucket,
+		"objectId":  attrs.Name,
+		"eventType": uploadEventType,
+		"project":   proj,
+	})
+	endSpan(span, err)
+	if err != nil {
+		glog.Errorf("failed to publish the notification of %s/%s: %v", attrs.Bucket, attrs.Name, err)
+	}
+}
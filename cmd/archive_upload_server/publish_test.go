@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewPublisherBadTopic(t *testing.T) {
+	for _, topic := range []string{
+		"uploads",
+		"projects/foo",
+		"projects/foo/topics/",
+		"projects/foo/subscriptions/uploads",
+		"projects/foo/topics/uploads/extra",
+	} {
+		if _, err := newPublisher(context.Background(), topic); err == nil {
+			t.Errorf("newPublisher(%q) got nil err, want err", topic)
+		}
+	}
+}
+
+func TestNotifyStored(t *testing.T) {
+	md5Sum, _ := hex.DecodeString("50e3903156f5d2dac6c9f89626d48c75")
+	attrs := &storage.ObjectAttrs{
+		Bucket:     "foo",
+		Name:       "rarc/bar",
+		MD5:        md5Sum,
+		Size:       11,
+		Generation: 42,
+	}
+
+	tests := []struct {
+		desc       string
+		attrs      *storage.ObjectAttrs
+		err        error
+		wantEvent  *uploadEvent
+		wantAttrs  map[string]string
+		wantCalled bool
+	}{{
+		desc:       "published",
+		attrs:      attrs,
+		wantCalled: true,
+		wantEvent: &uploadEvent{
+			Bucket:     "foo",
+			Object:     "rarc/bar",
+			Project:    "RPKI_RARC",
+			MD5:        "50e3903156f5d2dac6c9f89626d48c75",
+			Size:       11,
+			Generation: 42,
+		},
+		wantAttrs: map[string]string{
+			"bucketId":  "foo",
+			"objectId":  "rarc/bar",
+			"eventType": "RV_UPLOAD",
+			"project":   "RPKI_RARC",
+		},
+	}, {
+		// A failure is only logged.
+		desc:       "publish failure",
+		attrs:      attrs,
+		err:        errors.New("unavailable"),
+		wantCalled: true,
+	}, {
+		desc:  "no attributes",
+		attrs: nil,
+	}}
+	for _, test := range tests {
+		var called bool
+		r := rvServer{publish: func(ctx context.Context, data []byte, attrs map[string]string) error {
+			called = true
+			if test.wantEvent != nil {
+				got := &uploadEvent{}
+				if err := json.Unmarshal(data, got); err != nil {
+					t.Fatalf("%s: json.Unmarshal(%q) got err: %v", test.desc, data, err)
+				}
+				if diff := cmp.Diff(got, test.wantEvent); diff != "" {
+					t.Errorf("%s: notifyStored() published unexpected data (-got +want):\n%s", test.desc, diff)
+				}
+			}
+			if test.wantAttrs != nil {
+				if diff := cmp.Diff(attrs, test.wantAttrs); diff != "" {
+					t.Errorf("%s: notifyStored() published unexpected attributes (-got +want):\n%s", test.desc, diff)
+				}
+			}
+			return test.err
+		}}
+		r.notifyStored(context.Background(), "RPKI_RARC", test.attrs)
+		if called != test.wantCalled {
+			t.Errorf("%s: notifyStored() published = %v, want %v", test.desc, called, test.wantCalled)
+		}
+	}
+
+	// Notifications are disabled without a publisher.
+	rvServer{}.notifyStored(context.Background(), "RPKI_RARC", attrs)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/status"
+)
+
+// quarantinePrefix is the prefix of the quarantined content of the uploads
+// which failed validation, in the bucket of their project:
+//
+//	_quarantine/<object>.<time of the upload>
+//
+// The objects carry the diagnostics of the upload in their metadata, see
+// quarantineMeta, and no project source, the converter skips them.
+const quarantinePrefix = "_quarantine/"
+
+// quarantineName returns the quarantine object of an upload of an object, each
+// upload has its own.
+func quarantineName(obj string, now time.Time) string {
+	return quarantinePrefix + obj + "." + now.UTC().Format("20060102T150405.000000000Z")
+}
+
+// quarantineMeta returns the diagnostic metadata of rejected content: the
+// request, the caller, and the error, status code and reason of the
+// rejection, INVALID_FORMAT for content which is not valid MRT.
+func (r rvServer) quarantineMeta(ctx context.Context, rpc string, meta *pb.FileRequest, cause error) map[string]string {
+	md := map[string]string{
+		"rpc":      rpc,
+		"project":  meta.GetProject().String(),
+		"filename": meta.GetFilename(),
+		"md5sum":   meta.GetMd5Sum(),
+		"error":    cause.Error(),
+	}
+	if st, ok := status.FromError(cause); ok {
+		md["code"], md["error"] = st.Code().String(), st.Message()
+	}
+	if _, ok := invalidFormat(cause); ok {
+		md["reason"] = pb.FileResponse_INVALID_FORMAT.String()
+	}
+	if crc := meta.GetCrc32C(); crc != "" {
+		md["crc32c"] = crc
+	}
+	if sum := meta.GetSha256(); sum != "" {
+		md["sha256"] = sum
+	}
+	if reason := reasonOf(cause); reason != "" {
+		md["reason"] = reason
+	}
+	if caller, err := r.caller(ctx); err == nil {
+		md["caller"] = caller
+	}
+	return md
+}
+
+// quarantines reports whether the content of an upload which failed with an
+// error is quarantined: content which does not match its checksums, or is not
+// valid MRT, see invalidFormat.
+func quarantines(err error) bool {
+	if _, ok := invalidFormat(err); ok {
+		return true
+	}
+	return reasonOf(err) == reasonChecksumMismatch
+}
+
+// quarantine writes the content of an upload to its quarantine object as it
+// is received, and keeps it if the upload is rejected. A nil quarantine, ie:
+// quarantine is disabled, discards the content. A failure to quarantine never
+// fails the upload, it is logged.
+type quarantine struct {
+	r      rvServer
+	bkt    string
+	name   string
+	wc     *storage.Writer
+	cancel context.CancelFunc
+	err    error
+}
+
+// newQuarantine starts the quarantine of the content of an upload of an
+// object, nil if quarantine is disabled.
+func (r rvServer) newQuarantine(ctx context.Context, bkt, obj string, meta *pb.FileRequest) *quarantine {
+	if !r.quarantine {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	name := quarantineName(obj, time.Now())
+	wc := r.sc.Bucket(bkt).Object(name).NewWriter(ctx)
+	// The content is encrypted as the object would have been.
+	wc.KMSKeyName = r.kmsKey(meta.GetProject().String())
+	return &quarantine{r: r, bkt: bkt, name: name, wc: wc, cancel: cancel}
+}
+
+// Write writes the content to the quarantine object, a failure is kept for
+// keep, the content is always accepted.
+func (q *quarantine) Write(b []byte) (int, error) {
+	if q == nil || q.err != nil {
+		return len(b), nil
+	}
+	if _, err := q.wc.Write(b); err != nil {
+		q.err = err
+	}
+	return len(b), nil
+}
+
+// keep stores the quarantine object of a rejected upload, with the
+// diagnostics of the rejection in its metadata.
+func (q *quarantine) keep(ctx context.Context, rpc string, meta *pb.FileRequest, cause error) {
+	if q == nil {
+		return
+	}
+	defer q.cancel()
+	if q.err == nil {
+		q.err = q.wc.Close()
+	}
+	if q.err != nil {
+		glog.Errorf("failed to quarantine the content of %s to gs://%s/%s: %v", meta.GetFilename(), q.bkt, q.name, q.err)
+		return
+	}
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
+	md := q.r.quarantineMeta(ctx, rpc, meta, cause)
+	if _, err := q.r.object(q.bkt, q.name).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: md}); err != nil {
+		glog.Errorf("failed to set the diagnostics of gs://%s/%s: %v", q.bkt, q.name, err)
+	}
+	glog.Warningf("Quarantined the content of %s to gs://%s/%s: %v", meta.GetFilename(), q.bkt, q.name, cause)
+}
+
+// discard aborts the quarantine object of an upload which is not rejected.
+func (q *quarantine) discard() {
+	if q != nil {
+		q.cancel()
+	}
+}
+
+// quarantineRequest quarantines the content of a rejected FileUpload, decoded,
+// in the bucket of its project.
+func (r rvServer) quarantineRequest(ctx context.Context, req *pb.FileRequest, cause error) {
+	bkt, prefix, ok := r.config().route(req.GetProject().String())
+	if !ok {
+		return
+	}
+	q := r.newQuarantine(ctx, bkt, prefix+req.GetFilename(), req)
+	q.Write(req.GetContent())
+	q.keep(ctx, "FileUpload", req, cause)
+}
+
+// quarantineObject quarantines the content of a rejected upload which is in
+// an object, ie: staged by a signed upload, by a copy within cloud-storage.
+func (r rvServer) quarantineObject(ctx context.Context, src *storage.ObjectHandle, bkt, obj, rpc string, meta *pb.FileRequest, cause error) {
+	if !r.quarantine {
+		return
+	}
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
+	name := quarantineName(obj, time.Now())
+	c := r.object(bkt, name).CopierFrom(src)
+	c.Metadata = r.quarantineMeta(ctx, rpc, meta, cause)
+	c.DestinationKMSKeyName = r.kmsKey(meta.GetProject().String())
+	if _, err := c.Run(ctx); err != nil {
+		glog.Errorf("failed to quarantine the content of %s to gs://%s/%s: %v", meta.GetFilename(), bkt, name, err)
+		return
+	}
+	glog.Warningf("Quarantined the content of %s to gs://%s/%s: %v", meta.GetFilename(), bkt, name, cause)
+}
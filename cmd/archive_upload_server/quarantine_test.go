@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuarantineName(t *testing.T) {
+	now := time.Date(2022, 1, 9, 18, 30, 5, 123, time.UTC)
+	got := quarantineName("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", now)
+	want := "_quarantine/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2.20220109T183005.000000123Z"
+	if got != want {
+		t.Errorf("quarantineName() = %q, want %q", got, want)
+	}
+}
+
+func TestQuarantines(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{{
+		desc: "checksum mismatch",
+		err:  checkSum("md5sum", "a", "b"),
+		want: true,
+	}, {
+		desc: "invalid MRT",
+		err:  &converter.FormatError{Offset: 12, Err: errors.New("truncated record")},
+		want: true,
+	}, {
+		desc: "malformed checksum",
+		err:  invalidChecksum("md5sum", "md5sum", "a"),
+	}, {
+		desc: "missing field",
+		err:  requireFields("FileRequest", field{"md5sum", false}),
+	}, {
+		desc: "storage failure",
+		err:  storageError(errors.New("503"), "failed storing object"),
+	}}
+	for _, test := range tests {
+		if got := quarantines(test.err); got != test.want {
+			t.Errorf("%s: quarantines(%v) = %v, want %v", test.desc, test.err, got, test.want)
+		}
+	}
+}
+
+func TestQuarantineMeta(t *testing.T) {
+	r := rvServer{}
+	meta := &pb.FileRequest{
+		Filename: "2022/01/09/output.tgz",
+		Md5Sum:   "a",
+		Crc32C:   "AAAAAA==",
+	}
+	got := r.quarantineMeta(context.Background(), "FileUpload", meta, checkSum("md5sum", "a", "b"))
+	want := map[string]string{
+		"rpc":      "FileUpload",
+		"project":  pb.FileRequest_UNKNOWN.String(),
+		"filename": "2022/01/09/output.tgz",
+		"md5sum":   "a",
+		"crc32c":   "AAAAAA==",
+		"code":     codes.FailedPrecondition.String(),
+		"error":    status.Convert(checkSum("md5sum", "a", "b")).Message(),
+		"reason":   reasonChecksumMismatch,
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("quarantineMeta() got diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	r := rvServer{}
+	q := r.newQuarantine(context.Background(), "foo", "bar", &pb.FileRequest{})
+	if q != nil {
+		t.Fatalf("newQuarantine() = %v, want nil as quarantine is disabled", q)
+	}
+	// A nil quarantine discards the content.
+	if n, err := q.Write([]byte("content")); n != 7 || err != nil {
+		t.Errorf("nil quarantine Write() = %d, %v; want 7, nil", n, err)
+	}
+	q.keep(context.Background(), "FileUpload", &pb.FileRequest{}, errors.New("rejected"))
+	q.discard()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// quota is the rate of the requests and bytes of a caller to a project, a
+// zero limit is unlimited.
+type quota struct {
+	// QPS is the requests per second, with a burst of a second of requests.
+	QPS float64
+	// BytesPerHour is the bytes of content received per hour, with a burst
+	// of an hour of bytes.
+	BytesPerHour int64 `yaml:"bytes_per_hour"`
+}
+
+// quotas limit the requests and bytes of each caller to each project. The
+// quota of a caller takes precedence over the quota of the project, which
+// takes precedence over the default.
+type quotas struct {
+	Default  quota
+	Projects map[string]quota
+	// Callers are keyed by the email or subject of the ID token, requests
+	// without an ID token share the quota of the caller "".
+	Callers map[string]quota
+}
+
+// of returns the quota of a caller to a project.
+func (q *quotas) of(caller, proj string) quota {
+	if c, ok := q.Callers[caller]; ok {
+		return c
+	}
+	if p, ok := q.Projects[proj]; ok {
+		return p
+	}
+	return q.Default
+}
+
+// tokenBucket holds the tokens of a rate, refilled since last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens of rate per second since the last refill, up to
+// burst.
+func (b *tokenBucket) refill(now time.Time, rate, burst float64) {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if d := now.Sub(b.last).Seconds(); d > 0 {
+		b.tokens += d * rate
+	}
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+}
+
+// usage is the request and byte buckets of a caller to a project.
+type usage struct {
+	reqs, bytes tokenBucket
+}
+
+type quotaKey struct {
+	caller, proj string
+}
+
+// limiter enforces the quotas, it is safe for concurrent use.
+type limiter struct {
+	q     *quotas
+	mu    sync.Mutex
+	usage map[quotaKey]*usage
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newLimiter returns a limiter of the quotas, nil if there are none.
+func newLimiter(q *quotas) *limiter {
+	if q == nil {
+		return nil
+	}
+	return &limiter{q: q, usage: map[quotaKey]*usage{}, now: time.Now}
+}
+
+// take takes reqs requests and size bytes from the quota of a caller to a
+// project. Nothing is taken when either is exceeded.
+func (l *limiter) take(caller, proj string, reqs int, size int64) error {
+	q := l.q.of(caller, proj)
+	if q.QPS <= 0 && q.BytesPerHour <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := quotaKey{caller, proj}
+	u, ok := l.usage[k]
+	if !ok {
+		u = &usage{}
+		l.usage[k] = u
+	}
+	now := l.now()
+	if q.QPS > 0 {
+		u.reqs.refill(now, q.QPS, q.QPS)
+		if u.reqs.tokens < float64(reqs) {
+			return status.Errorf(codes.ResourceExhausted, "caller %q exceeded the quota of %v requests per second to %s", caller, q.QPS, proj)
+		}
+	}
+	if q.BytesPerHour > 0 {
+		u.bytes.refill(now, float64(q.BytesPerHour)/time.Hour.Seconds(), float64(q.BytesPerHour))
+		if u.bytes.tokens < float64(size) {
+			return status.Errorf(codes.ResourceExhausted, "caller %q exceeded the quota of %d bytes per hour to %s", caller, q.BytesPerHour, proj)
+		}
+	}
+	if q.QPS > 0 {
+		u.reqs.tokens -= float64(reqs)
+	}
+	if q.BytesPerHour > 0 {
+		u.bytes.tokens -= float64(size)
+	}
+	return nil
+}
+
+// limit takes reqs requests and size bytes from the quota of the caller of a
+// request to a project, it fails with ResourceExhausted once the quota is
+// exceeded. Without quotas in the config it allows everything.
+func (r rvServer) limit(ctx context.Context, proj string, reqs int, size int64) error {
+	l := r.limiter()
+	if l == nil {
+		return nil
+	}
+	// A request without a valid ID token takes from the quota of "".
+	caller, _ := r.caller(ctx)
+	return l.take(caller, proj, reqs, size)
+}
+
+// checkQuotas checks the projects of the quotas in the config are known.
+func checkQuotas(q *quotas) error {
+	if q == nil {
+		return nil
+	}
+	for proj := range q.Projects {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of quota", proj)
+		}
+	}
+	return nil
+}
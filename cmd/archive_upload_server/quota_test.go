@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLimiterTake(t *testing.T) {
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := newLimiter(&quotas{
+		Default:  quota{QPS: 2},
+		Projects: map[string]quota{"ROUTEVIEWS_RIB": {BytesPerHour: 3600}},
+		Callers:  map[string]quota{"mirror": {QPS: 1, BytesPerHour: 100}},
+	})
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		desc    string
+		caller  string
+		proj    string
+		reqs    int
+		size    int64
+		advance time.Duration
+		want    codes.Code
+	}{{
+		desc: "default quota",
+		proj: "ROUTEVIEWS",
+		reqs: 1,
+		want: codes.OK,
+	}, {
+		desc: "default quota burst",
+		proj: "ROUTEVIEWS",
+		reqs: 1,
+		want: codes.OK,
+	}, {
+		desc: "default quota exceeded",
+		proj: "ROUTEVIEWS",
+		reqs: 1,
+		want: codes.ResourceExhausted,
+	}, {
+		desc:    "default quota refilled",
+		proj:    "ROUTEVIEWS",
+		reqs:    1,
+		advance: time.Second,
+		want:    codes.OK,
+	}, {
+		desc: "project quota, requests unlimited",
+		proj: "ROUTEVIEWS_RIB",
+		reqs: 100,
+		size: 3000,
+		want: codes.OK,
+	}, {
+		desc: "project quota exceeded",
+		proj: "ROUTEVIEWS_RIB",
+		size: 1000,
+		want: codes.ResourceExhausted,
+	}, {
+		desc:    "project quota refilled",
+		proj:    "ROUTEVIEWS_RIB",
+		size:    1000,
+		advance: 400 * time.Second,
+		want:    codes.OK,
+	}, {
+		desc:   "caller quota takes precedence",
+		caller: "mirror",
+		proj:   "ROUTEVIEWS_RIB",
+		reqs:   1,
+		size:   100,
+		want:   codes.OK,
+	}, {
+		desc:   "caller quota exceeded",
+		caller: "mirror",
+		proj:   "ROUTEVIEWS_RIB",
+		reqs:   1,
+		size:   1,
+		want:   codes.ResourceExhausted,
+	}, {
+		desc:   "caller quota is per project",
+		caller: "mirror",
+		proj:   "RPKI_RARC",
+		reqs:   1,
+		size:   100,
+		want:   codes.OK,
+	}}
+	for _, test := range tests {
+		now = now.Add(test.advance)
+		err := l.take(test.caller, test.proj, test.reqs, test.size)
+		if got := status.Code(err); got != test.want {
+			t.Errorf("[%s]: take() = %v; want %v", test.desc, err, test.want)
+		}
+	}
+}
+
+func TestFileUploadQuota(t *testing.T) {
+	r := rvServer{
+		conf:     &config{},
+		validate: fakeValidate,
+		limits:   newLimiter(&quotas{Callers: map[string]quota{"5678": {BytesPerHour: 5}}}),
+	}
+	req := &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Content:  []byte("Foo Bar Baz"),
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer robot"))
+	_, err := r.FileUpload(ctx, req)
+	if got := status.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("FileUpload(beyond the quota) = %v; want %v", err, codes.ResourceExhausted)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recovered returns the Internal error of a panic of a call, which is logged
+// with its stack. The stack is not returned to the caller.
+func recovered(method string, p interface{}) error {
+	glog.Errorf("panic in %s: %v\n%s", method, p, debug.Stack())
+	return status.Errorf(codes.Internal, "internal error in %s", method)
+}
+
+// recoverUnaryInterceptor recovers the panics of the unary RPCs into Internal
+// errors, rather than have them kill the server.
+func recoverUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				resp, err = nil, recovered(info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// recoverStreamInterceptor recovers the panics of the streaming RPCs into
+// Internal errors, rather than have them kill the server.
+func recoverStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
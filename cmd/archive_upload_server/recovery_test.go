@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoverUnaryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/rv.RV/FileUpload"}
+	tests := []struct {
+		desc    string
+		handler grpc.UnaryHandler
+		want    codes.Code
+	}{{
+		desc:    "no panic",
+		handler: func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil },
+	}, {
+		desc: "error",
+		handler: func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "")
+		},
+		want: codes.NotFound,
+	}, {
+		desc:    "panic",
+		handler: func(ctx context.Context, req interface{}) (interface{}, error) { panic("boom") },
+		want:    codes.Internal,
+	}}
+	for _, test := range tests {
+		resp, err := recoverUnaryInterceptor()(context.Background(), nil, info, test.handler)
+		if got := status.Code(err); got != test.want {
+			t.Errorf("%s: recoverUnaryInterceptor() got err %v, want %v", test.desc, err, test.want)
+		}
+		if err == nil && resp != "ok" {
+			t.Errorf("%s: recoverUnaryInterceptor() = %v, want ok", test.desc, resp)
+		}
+	}
+}
+
+func TestRecoverStreamInterceptor(t *testing.T) {
+	info := &grpc.StreamServerInfo{FullMethod: "/rv.RV/FileUploadStream"}
+	handler := func(srv interface{}, ss grpc.ServerStream) error { panic("boom") }
+	err := recoverStreamInterceptor()(nil, nil, info, handler)
+	if got := status.Code(err); got != codes.Internal {
+		t.Errorf("recoverStreamInterceptor() got err %v, want %v", err, codes.Internal)
+	}
+}
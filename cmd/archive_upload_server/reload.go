@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"regexp"
+	"sync"
+	"syscall"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// policy is the part of a server which a reload of its config replaces: the
+// config, ie: the routing of the projects to buckets, the callers and admins,
+// and the quotas, with the filename patterns and the limiter of the config.
+type policy struct {
+	conf   *config
+	names  map[pb.FileRequest_Project]*regexp.Regexp
+	limits *limiter
+}
+
+// liveConfig holds the policy of a server once its config is reloaded. The
+// copies of a server share it, so a reload reaches every RPC, the RPCs in
+// flight finish on the policy they started with or the new one. It is safe
+// for concurrent use, and a nil liveConfig holds nothing.
+type liveConfig struct {
+	mu sync.RWMutex
+	p  *policy
+}
+
+func (l *liveConfig) load() *policy {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.p
+}
+
+func (l *liveConfig) store(p *policy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.p = p
+}
+
+// config returns the config of the server, the last reloaded if any.
+func (r rvServer) config() *config {
+	if p := r.live.load(); p != nil {
+		return p.conf
+	}
+	return r.conf
+}
+
+// namePatterns returns the filename patterns of the config of the server.
+func (r rvServer) namePatterns() map[pb.FileRequest_Project]*regexp.Regexp {
+	if p := r.live.load(); p != nil {
+		return p.names
+	}
+	return r.names
+}
+
+// limiter returns the limiter of the quotas of the config of the server.
+func (r rvServer) limiter() *limiter {
+	if p := r.live.load(); p != nil {
+		return p.limits
+	}
+	return r.limits
+}
+
+// reload reads the config file again, checks it as at the start of the
+// server, and replaces the policy of the server with it. A config which fails
+// the checks is rejected, the server keeps its policy. The usage of the quotas
+// restarts with the new limiter.
+//
+// The s3 store is set at the start of the server only, a config which changes
+// it is rejected; the server settings only set flags at the start, their
+// changes are logged and ignored.
+func (r rvServer) reload(ctx context.Context, cf string, client *storage.Client) error {
+	if r.live == nil {
+		return errors.New("the server does not reload its config")
+	}
+	c, err := readConfigFile(cf)
+	if err != nil {
+		return err
+	}
+	cur := r.config()
+	if !reflect.DeepEqual(c.S3, cur.S3) {
+		return errors.New("s3 changes need a restart of the server")
+	}
+	nr, err := newRVServer(ctx, cf, client, r.local)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(nr.conf.Server, cur.Server) {
+		glog.Warningf("Ignored the changes of the server settings of %s, they need a restart of the server", cf)
+	}
+	r.live.store(&policy{conf: nr.conf, names: nr.names, limits: nr.limits})
+	return nil
+}
+
+// reloadOnSignal reloads the config on SIGHUP, until the context is done. A
+// reload which fails is logged, the server keeps its config.
+func (r rvServer) reloadOnSignal(ctx context.Context, cf string, client *storage.Client) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sig:
+			if err := r.reload(ctx, cf, client); err != nil {
+				glog.Errorf("failed to reload the config %s, kept the current config: %v", cf, err)
+				continue
+			}
+			glog.Infof("Reloaded the config %s", cf)
+		}
+	}
+}
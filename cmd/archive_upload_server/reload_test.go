@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+)
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	srv.CreateBucket("bar")
+
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() got err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cf := filepath.Join(dir, "config.yaml")
+	write := func(conf string) {
+		if err := ioutil.WriteFile(cf, []byte(conf), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile() got err: %v", err)
+		}
+	}
+	write("buckets:\n  ROUTEVIEWS: foo\n")
+	r, err := newRVServer(ctx, cf, srv.Client(), nil)
+	if err != nil {
+		t.Fatalf("newRVServer() got err: %v", err)
+	}
+	// The server serves RPCs of its copies, a reload reaches each of them.
+	served := *r
+
+	tests := []struct {
+		desc       string
+		conf       string
+		wantErr    bool
+		wantBucket string
+		wantQuotas bool
+	}{{
+		desc:       "routing and quotas",
+		conf:       "buckets:\n  ROUTEVIEWS: bar\nquotas:\n  default:\n    qps: 10\n",
+		wantBucket: "bar",
+		wantQuotas: true,
+	}, {
+		desc:       "missing bucket",
+		conf:       "buckets:\n  ROUTEVIEWS: gone\n",
+		wantErr:    true,
+		wantBucket: "bar",
+		wantQuotas: true,
+	}, {
+		desc:       "bad yaml",
+		conf:       "buckets: [",
+		wantErr:    true,
+		wantBucket: "bar",
+		wantQuotas: true,
+	}, {
+		desc:       "s3 change",
+		conf:       "buckets:\n  ROUTEVIEWS: foo\ns3:\n  endpoint: http://minio:9000\n",
+		wantErr:    true,
+		wantBucket: "bar",
+		wantQuotas: true,
+	}, {
+		desc:       "back to the start",
+		conf:       "buckets:\n  ROUTEVIEWS: foo\n",
+		wantBucket: "foo",
+	}}
+	for _, test := range tests {
+		write(test.conf)
+		err := r.reload(ctx, cf, srv.Client())
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("%s: reload() got err: %v; want nil err", test.desc, err)
+		case err == nil && test.wantErr:
+			t.Errorf("%s: reload() got nil err, want err", test.desc)
+		}
+		if bkt, _, _ := served.config().route("ROUTEVIEWS"); bkt != test.wantBucket {
+			t.Errorf("%s: reload() routes ROUTEVIEWS to %q, want %q", test.desc, bkt, test.wantBucket)
+		}
+		if got := served.limiter() != nil; got != test.wantQuotas {
+			t.Errorf("%s: reload() got quotas %v, want %v", test.desc, got, test.wantQuotas)
+		}
+	}
+
+	// A server without a live config keeps its config.
+	if err := (rvServer{conf: &config{}}).reload(ctx, cf, srv.Client()); err == nil {
+		t.Errorf("reload() without a live config got nil err, want err")
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// replicaInterval is the interval of the retries of the pending copies, and
+// of the log of the replication counters.
+const replicaInterval = 5 * time.Minute
+
+// replicaCopy is the copy of a generation of an object to a replica bucket.
+type replicaCopy struct {
+	bkt, obj string
+	gen      int64
+	dst      string
+	// failover is the copy of an object stored to the failover bucket back
+	// to its bucket, see failOver, which does not replace an object.
+	failover bool
+}
+
+// replicator keeps the copies to the replica buckets which failed, for
+// reconcile to retry, and counts them. It is safe for concurrent use.
+//
+// The pending copies are per instance, the copies pending when an instance
+// stops are left to a sync of the buckets, ie: gsutil rsync.
+type replicator struct {
+	mu      sync.Mutex
+	pending map[replicaCopy]bool
+	// failed counts the copies which failed, reconciled the failed copies
+	// retried since, and dropped those of a generation replaced or deleted
+	// meanwhile.
+	failed, reconciled, dropped int
+}
+
+// newReplicator returns a replicator without pending copies.
+func newReplicator() *replicator {
+	return &replicator{pending: map[replicaCopy]bool{}}
+}
+
+// fail records a failed copy, pending until it is reconciled.
+func (p *replicator) fail(c replicaCopy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.pending[c] {
+		p.failed++
+	}
+	p.pending[c] = true
+}
+
+// done removes a pending copy, reconciled or dropped.
+func (p *replicator) done(c replicaCopy, dropped bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.pending[c] {
+		return
+	}
+	delete(p.pending, c)
+	if dropped {
+		p.dropped++
+	} else {
+		p.reconciled++
+	}
+}
+
+// copies returns the pending copies.
+func (p *replicator) copies() []replicaCopy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var cs []replicaCopy
+	for c := range p.pending {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// String returns the counters of the replicator, for the logs.
+func (p *replicator) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fmt.Sprintf("%d pending, %d failed, %d reconciled, %d dropped", len(p.pending), p.failed, p.reconciled, p.dropped)
+}
+
+// replicas returns the replica buckets of a bucket, sorted.
+func (c *config) replicas(bkt string) []string {
+	dsts := append([]string(nil), c.Replicas[bkt]...)
+	sort.Strings(dsts)
+	return dsts
+}
+
+// checkReplicas checks that the replicas are of the buckets of the config, and
+// that each replica bucket exists and is not the bucket itself.
+func checkReplicas(ctx context.Context, client *storage.Client, c *config) error {
+	known := map[string]bool{}
+	for _, bkt := range c.buckets() {
+		known[bkt] = true
+	}
+	for bkt, dsts := range c.Replicas {
+		if !known[bkt] {
+			return fmt.Errorf("replicas of unknown bucket %s", bkt)
+		}
+		for _, dst := range dsts {
+			if dst == bkt {
+				return fmt.Errorf("bucket %s is its own replica", bkt)
+			}
+			if _, err := client.Bucket(dst).Attrs(ctx); err != nil {
+				return fmt.Errorf("bad replica bucket %s of %s: %v", dst, bkt, err)
+			}
+		}
+	}
+	return nil
+}
+
+// replicate copies a stored object to each replica bucket of its bucket, and
+// returns the status of each copy. A failed copy is left pending, for
+// reconcile to retry, the object is stored regardless.
+func (r rvServer) replicate(ctx context.Context, attrs *storage.ObjectAttrs) []*pb.Replica {
+	if attrs == nil {
+		return nil
+	}
+	var res []*pb.Replica
+	for _, dst := range r.config().replicas(attrs.Bucket) {
+		c := replicaCopy{bkt: attrs.Bucket, obj: attrs.Name, gen: attrs.Generation, dst: dst}
+		copied, err := r.copyReplica(ctx, c, attrs)
+		if err != nil {
+			glog.Errorf("failed to replicate %s/%s to %s: %v", c.bkt, c.obj, dst, err)
+			if r.replicas != nil {
+				r.replicas.fail(c)
+			}
+			res = append(res, &pb.Replica{Bucket: dst, Status: pb.Replica_PENDING, ErrorMessage: err.Error()})
+			continue
+		}
+		res = append(res, &pb.Replica{Bucket: dst, Status: pb.Replica_REPLICATED, Generation: copied.Generation})
+	}
+	return res
+}
+
+// copyReplica copies the generation of an object to a replica bucket, with
+// its metadata, storage class and holds. The copy is encrypted with the
+// default key of the replica bucket, the keys of the projects are regional;
+// the copy of a failover back to its bucket with the key of its project.
+func (r rvServer) copyReplica(ctx context.Context, c replicaCopy, attrs *storage.ObjectAttrs) (res *storage.ObjectAttrs, err error) {
+	ctx, span := tracer.Start(ctx, "gcs.replicate", objectAttrs(c.dst, c.obj))
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
+	src := r.sc.Bucket(c.bkt).Object(c.obj).Generation(c.gen)
+	dst := r.object(c.dst, c.obj)
+	if c.failover {
+		dst = dst.If(storage.Conditions{DoesNotExist: true})
+	}
+	cp := dst.CopierFrom(src)
+	if c.failover {
+		// The object is back in its bucket, encrypted as those of its
+		// project are.
+		cp.DestinationKMSKeyName = r.kmsKey(attrs.Metadata[converter.ProjectMetadataKey])
+	}
+	cp.StorageClass = attrs.StorageClass
+	cp.EventBasedHold = attrs.EventBasedHold
+	cp.TemporaryHold = attrs.TemporaryHold
+	return cp.Run(ctx)
+}
+
+// reconcileReplicas retries the pending copies, and logs the replication
+// counters. The copy of a generation replaced or deleted meanwhile is dropped,
+// the copy of the next generation is its own.
+func (r rvServer) reconcileReplicas(ctx context.Context) {
+	for _, c := range r.replicas.copies() {
+		attrs, err := r.sc.Bucket(c.bkt).Object(c.obj).Attrs(ctx)
+		switch {
+		case err == storage.ErrObjectNotExist || err == nil && attrs.Generation != c.gen:
+			glog.Warningf("dropped the replication of %s/%s generation(%d) to %s, it is replaced or deleted", c.bkt, c.obj, c.gen, c.dst)
+			r.replicas.done(c, true)
+			continue
+		case err != nil:
+			glog.Errorf("failed to reconcile %s/%s to %s: %v", c.bkt, c.obj, c.dst, err)
+			continue
+		}
+		_, err = r.copyReplica(ctx, c, attrs)
+		if c.failover && preconditionFailed(err) {
+			glog.Warningf("dropped the copy of %s/%s generation(%d) back to %s, it was written meanwhile", c.bkt, c.obj, c.gen, c.dst)
+			r.replicas.done(c, true)
+			continue
+		}
+		if err != nil {
+			glog.Errorf("failed to reconcile %s/%s to %s: %v", c.bkt, c.obj, c.dst, err)
+			continue
+		}
+		glog.Infof("Reconciled %s/%s generation(%d) to %s", c.bkt, c.obj, c.gen, c.dst)
+		r.replicas.done(c, false)
+	}
+	glog.Infof("Replication: %v", r.replicas)
+}
+
+// watchReplicas reconciles the pending copies every interval, until the
+// context is done.
+func (r rvServer) watchReplicas(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			// A reload may add the first replicas of the config, the copies
+			// pending are reconciled regardless.
+			if len(r.config().Replicas) > 0 || len(r.replicas.copies()) > 0 {
+				r.reconcileReplicas(ctx)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestReplicator(t *testing.T) {
+	p := newReplicator()
+	a := replicaCopy{bkt: "foo", obj: "rarc/bar", gen: 1, dst: "foo-dr"}
+	b := replicaCopy{bkt: "foo", obj: "rarc/baz", gen: 2, dst: "foo-dr"}
+	p.fail(a)
+	p.fail(b)
+	// A retry which fails again is counted once.
+	p.fail(a)
+	if got, want := p.String(), "2 pending, 2 failed, 0 reconciled, 0 dropped"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	p.done(a, false)
+	p.done(b, true)
+	// A copy done already is not counted again.
+	p.done(a, false)
+	if got, want := p.String(), "0 pending, 2 failed, 1 reconciled, 1 dropped"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := p.copies(); len(got) != 0 {
+		t.Errorf("copies() = %v, want none", got)
+	}
+}
+
+func TestCheckReplicasBad(t *testing.T) {
+	tests := []struct {
+		desc string
+		conf *config
+	}{{
+		desc: "unknown bucket",
+		conf: &config{
+			Buckets:  map[string]string{"RPKI_RARC": "foo"},
+			Replicas: map[string][]string{"bar": {"bar-dr"}},
+		},
+	}, {
+		desc: "own replica",
+		conf: &config{
+			Buckets:  map[string]string{"RPKI_RARC": "foo"},
+			Replicas: map[string][]string{"foo": {"foo"}},
+		},
+	}}
+	for _, test := range tests {
+		if err := checkReplicas(context.Background(), nil, test.conf); err == nil {
+			t.Errorf("%s: checkReplicas() got nil err, want err", test.desc)
+		}
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer([]fakestorage.Object{storedObject("foo", "rarc/bar", []byte("Foo Bar Baz"))})
+	defer srv.Stop()
+	srv.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "foo-dr"})
+	attrs, err := srv.Client().Bucket("foo").Object("rarc/bar").Attrs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rvServer{
+		conf: &config{
+			Routes:   map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}},
+			Replicas: map[string][]string{"foo": {"missing-dr", "foo-dr"}},
+		},
+		sc:       gcsClient(t, srv),
+		replicas: newReplicator(),
+	}
+
+	got := r.replicate(ctx, attrs)
+	copied, err := srv.Client().Bucket("foo-dr").Object("rarc/bar").Attrs(ctx)
+	if err != nil {
+		t.Fatalf("replicate() did not copy rarc/bar to foo-dr: %v", err)
+	}
+	want := []*pb.Replica{
+		{Bucket: "foo-dr", Status: pb.Replica_REPLICATED, Generation: copied.Generation},
+		{Bucket: "missing-dr", Status: pb.Replica_PENDING},
+	}
+	for _, rep := range got {
+		if rep.GetStatus() == pb.Replica_PENDING && rep.GetErrorMessage() == "" {
+			t.Errorf("replicate() to %s is pending without an error", rep.GetBucket())
+		}
+		rep.ErrorMessage = ""
+	}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("replicate() returned unexpected diff (-got +want):\n%s", diff)
+	}
+	if got, want := r.replicas.copies(), []replicaCopy{{bkt: "foo", obj: "rarc/bar", gen: attrs.Generation, dst: "missing-dr"}}; !cmp.Equal(got, want, cmp.AllowUnexported(replicaCopy{})) {
+		t.Errorf("replicate() left pending %v, want %v", got, want)
+	}
+
+	// The missing bucket is created, the pending copy is reconciled.
+	srv.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "missing-dr"})
+	r.reconcileReplicas(ctx)
+	if _, err := srv.Client().Bucket("missing-dr").Object("rarc/bar").Attrs(ctx); err != nil {
+		t.Errorf("reconcileReplicas() did not copy rarc/bar to missing-dr: %v", err)
+	}
+	if got, want := r.replicas.String(), "0 pending, 1 failed, 1 reconciled, 0 dropped"; got != want {
+		t.Errorf("reconcileReplicas() counters = %q, want %q", got, want)
+	}
+
+	// The copy of a deleted object is dropped.
+	r.replicas.fail(replicaCopy{bkt: "foo", obj: "rarc/gone", gen: 1, dst: "foo-dr"})
+	r.reconcileReplicas(ctx)
+	if got, want := r.replicas.String(), "0 pending, 2 failed, 1 reconciled, 1 dropped"; got != want {
+		t.Errorf("reconcileReplicas() counters = %q, want %q", got, want)
+	}
+
+	// Buckets without replicas are not copied.
+	if got := r.replicate(ctx, &storage.ObjectAttrs{Bucket: "foo-dr", Name: "rarc/bar"}); got != nil {
+		t.Errorf("replicate() of a bucket without replicas = %v, want nil", got)
+	}
+}
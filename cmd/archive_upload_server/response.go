@@ -0,0 +1,35 @@
+package main
+
+import (
+	"cloud.google.com/go/storage"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// withObject sets the stored, or unchanged, object in its response: its name,
+// generation and metageneration, size and checksums, and whether the write was
+// skipped as a duplicate, by the status of the response. The clients log and
+// verify the object from the response, without a lookup. attrs may be nil.
+func (r rvServer) withObject(resp *pb.FileResponse, attrs *storage.ObjectAttrs) *pb.FileResponse {
+	if attrs == nil {
+		return resp
+	}
+	switch {
+	case r.s3 != nil:
+		resp.Object = "s3://" + attrs.Bucket + "/" + attrs.Name
+	case r.local != nil:
+		resp.Object = r.local.localURL(attrs.Bucket, attrs.Name)
+	default:
+		resp.Object = "gs://" + attrs.Bucket + "/" + attrs.Name
+	}
+	resp.Generation, resp.Metageneration = attrs.Generation, attrs.Metageneration
+	resp.Size = attrs.Size
+	resp.Md5Sum = uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	// The S3 objects have no crc32c.
+	if r.s3 == nil {
+		resp.Crc32C = uploadutils.ChecksumFromAttrs(uploadutils.CRC32C, attrs)
+	}
+	resp.Sha256 = uploadutils.ChecksumFromAttrs(uploadutils.SHA256, attrs)
+	resp.Duplicate = resp.GetStatus() == pb.FileResponse_UNCHANGED
+	return resp
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestWithObject(t *testing.T) {
+	attrs := &storage.ObjectAttrs{
+		Bucket:         "routeviews-archives",
+		Name:           "bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+		Generation:     42,
+		Metageneration: 2,
+		Size:           11,
+		MD5:            []byte{0x50, 0xe3, 0x90, 0x31, 0x56, 0xf5, 0xd2, 0xda, 0xc6, 0xc9, 0xf8, 0x96, 0x26, 0xd4, 0x8c, 0x75},
+		CRC32C:         0x0a1b2c3d,
+		Metadata:       map[string]string{uploadutils.SHA256MetadataKey: "abcd"},
+	}
+	tests := []struct {
+		desc  string
+		r     rvServer
+		resp  *pb.FileResponse
+		attrs *storage.ObjectAttrs
+		want  *pb.FileResponse
+	}{{
+		desc:  "stored",
+		resp:  &pb.FileResponse{Status: pb.FileResponse_SUCCESS},
+		attrs: attrs,
+		want: &pb.FileResponse{
+			Status:         pb.FileResponse_SUCCESS,
+			Object:         "gs://routeviews-archives/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+			Generation:     42,
+			Metageneration: 2,
+			Size:           11,
+			Md5Sum:         "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:         "0a1b2c3d",
+			Sha256:         "abcd",
+		},
+	}, {
+		desc:  "duplicate",
+		resp:  &pb.FileResponse{Status: pb.FileResponse_UNCHANGED},
+		attrs: attrs,
+		want: &pb.FileResponse{
+			Status:         pb.FileResponse_UNCHANGED,
+			Object:         "gs://routeviews-archives/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+			Generation:     42,
+			Metageneration: 2,
+			Size:           11,
+			Md5Sum:         "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:         "0a1b2c3d",
+			Sha256:         "abcd",
+			Duplicate:      true,
+		},
+	}, {
+		desc:  "s3 backend",
+		r:     rvServer{s3: &s3Store{}},
+		resp:  &pb.FileResponse{Status: pb.FileResponse_SUCCESS},
+		attrs: &storage.ObjectAttrs{Bucket: "bkt", Name: "obj", Size: 11, MD5: attrs.MD5},
+		want: &pb.FileResponse{
+			Status: pb.FileResponse_SUCCESS,
+			Object: "s3://bkt/obj",
+			Size:   11,
+			Md5Sum: "50e3903156f5d2dac6c9f89626d48c75",
+		},
+	}, {
+		desc: "no object",
+		resp: &pb.FileResponse{Status: pb.FileResponse_FAIL},
+		want: &pb.FileResponse{Status: pb.FileResponse_FAIL},
+	}}
+	for _, test := range tests {
+		got := test.r.withObject(test.resp, test.attrs)
+		if diff := cmp.Diff(got, test.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: withObject() got diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
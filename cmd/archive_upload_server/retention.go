@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// retention is the retention of the objects of a project, for data which must
+// be immutable. A held object can not be deleted or replaced until the hold
+// is released, a changed file of the same name then fails to store.
+type retention struct {
+	// EventBasedHold places an event-based hold on each object, released once
+	// the event which starts its retention period occurs.
+	EventBasedHold bool `yaml:"event_based_hold"`
+	// TemporaryHold places a temporary hold on each object.
+	TemporaryHold bool `yaml:"temporary_hold"`
+	// BucketRetention requires a retention policy on the bucket of the
+	// project, the server fails to start without one.
+	BucketRetention bool `yaml:"bucket_retention"`
+}
+
+// checkRetention checks the projects of the retention in the config are
+// known, and the buckets of those which require a retention policy have one.
+func (r rvServer) checkRetention(ctx context.Context) error {
+	for proj, rt := range r.config().Retention {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of retention", proj)
+		}
+		if !rt.BucketRetention {
+			continue
+		}
+		bkt, _, ok := r.config().route(proj)
+		if !ok {
+			return fmt.Errorf("%s of retention is not supported", proj)
+		}
+		attrs, err := r.sc.Bucket(bkt).Attrs(ctx)
+		if err != nil {
+			return fmt.Errorf("bad bucket %s: %v", bkt, err)
+		}
+		if attrs.RetentionPolicy == nil || attrs.RetentionPolicy.RetentionPeriod <= 0 {
+			return fmt.Errorf("bucket %s of %s has no retention policy", bkt, proj)
+		}
+	}
+	return nil
+}
+
+// setHolds places the holds of the retention of a project on the attributes
+// of an object written or copied.
+func (r rvServer) setHolds(attrs *storage.ObjectAttrs, proj string) {
+	if rt, ok := r.config().Retention[proj]; ok {
+		attrs.EventBasedHold = rt.EventBasedHold
+		attrs.TemporaryHold = rt.TemporaryHold
+	}
+}
+
+// retentionOf returns the retention of a stored object, nil if it has none.
+func retentionOf(attrs *storage.ObjectAttrs) *pb.Retention {
+	if attrs == nil || !attrs.EventBasedHold && !attrs.TemporaryHold && attrs.RetentionExpirationTime.IsZero() {
+		return nil
+	}
+	rt := &pb.Retention{
+		EventBasedHold: attrs.EventBasedHold,
+		TemporaryHold:  attrs.TemporaryHold,
+	}
+	if !attrs.RetentionExpirationTime.IsZero() {
+		rt.RetainUntil = attrs.RetentionExpirationTime.UTC().Format(time.RFC3339)
+	}
+	return rt
+}
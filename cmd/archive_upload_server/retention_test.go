@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestRetentionOf(t *testing.T) {
+	tests := []struct {
+		desc  string
+		attrs *storage.ObjectAttrs
+		want  *pb.Retention
+	}{{
+		desc:  "no retention",
+		attrs: &storage.ObjectAttrs{},
+	}, {
+		desc:  "held",
+		attrs: &storage.ObjectAttrs{EventBasedHold: true, TemporaryHold: true},
+		want:  &pb.Retention{EventBasedHold: true, TemporaryHold: true},
+	}, {
+		desc:  "bucket retention",
+		attrs: &storage.ObjectAttrs{RetentionExpirationTime: time.Date(2032, 1, 9, 18, 30, 0, 0, time.UTC)},
+		want:  &pb.Retention{RetainUntil: "2032-01-09T18:30:00Z"},
+	}}
+	for _, test := range tests {
+		if diff := cmp.Diff(retentionOf(test.attrs), test.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: retentionOf() diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestSetHolds(t *testing.T) {
+	r := rvServer{conf: &config{Retention: map[string]*retention{
+		"RPKI_RARC": {EventBasedHold: true},
+	}}}
+	wc := &storage.Writer{}
+	r.setHolds(&wc.ObjectAttrs, "RPKI_RARC")
+	if !wc.EventBasedHold || wc.TemporaryHold {
+		t.Errorf("setHolds(RPKI_RARC) = event-based %v, temporary %v; want an event-based hold", wc.EventBasedHold, wc.TemporaryHold)
+	}
+	wc = &storage.Writer{}
+	r.setHolds(&wc.ObjectAttrs, "ROUTEVIEWS")
+	if wc.EventBasedHold || wc.TemporaryHold {
+		t.Error("setHolds(ROUTEVIEWS) placed a hold; want none")
+	}
+}
+
+func TestCheckRetention(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	buckets := map[string]string{"ROUTEVIEWS": "foo", "RPKI_RARC": "foo"}
+
+	tests := []struct {
+		desc    string
+		rt      map[string]*retention
+		wantErr bool
+	}{{
+		desc: "holds",
+		rt:   map[string]*retention{"RPKI_RARC": {EventBasedHold: true}},
+	}, {
+		desc:    "unknown project",
+		rt:      map[string]*retention{"ISOLARIO": {TemporaryHold: true}},
+		wantErr: true,
+	}, {
+		desc:    "bucket without a retention policy",
+		rt:      map[string]*retention{"RPKI_RARC": {BucketRetention: true}},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		r := rvServer{conf: &config{Buckets: buckets, Retention: test.rt}, sc: srv.Client()}
+		err := r.checkRetention(ctx)
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("%s: checkRetention() got nil err; want non-nil err", test.desc)
+		case !test.wantErr && err != nil:
+			t.Errorf("%s: checkRetention() got err: %v; want nil err", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/googleapi"
+)
+
+// s3MetaPrefix is the prefix of the headers of the user metadata of an S3
+// object.
+const s3MetaPrefix = "X-Amz-Meta-"
+
+// s3Config is the S3-compatible store, ie: an on-prem MinIO, the server
+// stores the objects of FileUpload in rather than cloud-storage. The buckets
+// of the config are buckets of the store.
+type s3Config struct {
+	// Endpoint is the URL of the store, ie: https://minio.example.net:9000,
+	// the objects are addressed by path: <endpoint>/<bucket>/<object>.
+	Endpoint string
+	// Region is the region requests are signed for, us-east-1 by default.
+	Region string
+}
+
+// s3Store stores the objects of FileUpload in an S3-compatible store. The
+// requests are signed with AWS Signature Version 4, with the credentials of
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optional AWS_SESSION_TOKEN
+// environment variables.
+type s3Store struct {
+	endpoint *url.URL
+	region   string
+	key      string
+	secret   string
+	token    string
+	client   *http.Client
+	// now is the time of the signatures, time.Now if nil.
+	now func() time.Time
+}
+
+// newS3Store returns the store of the config, with the credentials of the
+// environment, read by lookup, ie: os.LookupEnv.
+func newS3Store(c *s3Config, lookup func(string) (string, bool)) (*s3Store, error) {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("bad s3 endpoint(%q), want http(s)://host[:port]", c.Endpoint)
+	}
+	s := &s3Store{endpoint: u, region: c.Region, client: http.DefaultClient}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	s.key, _ = lookup("AWS_ACCESS_KEY_ID")
+	s.secret, _ = lookup("AWS_SECRET_ACCESS_KEY")
+	s.token, _ = lookup("AWS_SESSION_TOKEN")
+	if s.key == "" || s.secret == "" {
+		return nil, errors.New("the s3 backend needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// checkS3 checks that the config sets none of the options which only
+// cloud-storage supports.
+func checkS3(c *config) error {
+	return checkGCSOnly(c, "s3")
+}
+
+func (s *s3Store) checkBucket(ctx context.Context, bkt string) error {
+	resp, err := s.do(ctx, http.MethodHead, bkt, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) attrs(ctx context.Context, bkt, obj string) (*storage.ObjectAttrs, error) {
+	resp, err := s.do(ctx, http.MethodHead, bkt, obj, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	attrs := &storage.ObjectAttrs{
+		Bucket:          bkt,
+		Name:            obj,
+		Size:            resp.ContentLength,
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+		Etag:            resp.Header.Get("ETag"),
+		Metadata:        map[string]string{},
+	}
+	// The ETag of an object of a single PUT is its md5sum.
+	if sum, err := hex.DecodeString(strings.Trim(attrs.Etag, `"`)); err == nil && len(sum) == md5.Size {
+		attrs.MD5 = sum
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		attrs.Updated = t
+	}
+	// S3 keeps the keys of the metadata in lower case.
+	for k, v := range resp.Header {
+		if strings.HasPrefix(k, s3MetaPrefix) && len(v) > 0 {
+			attrs.Metadata[strings.ToLower(strings.TrimPrefix(k, s3MetaPrefix))] = v[0]
+		}
+	}
+	return attrs, nil
+}
+
+// s3Objects is the objectStore of a server on its s3Store, the metadata of the
+// objects is that of the server.
+type s3Objects struct {
+	*s3Store
+	r rvServer
+}
+
+// write puts the object with its metadata, the store verifies the content
+// against the md5sum, and the crc32c if set. An object which must not exist
+// is put with If-None-Match; the S3 objects have no generations, an
+// overwrite replaces the object unconditionally.
+func (s s3Objects) write(ctx context.Context, bkt, obj string, req *pb.FileRequest, conds storage.Conditions) (attrs *storage.ObjectAttrs, err error) {
+	b := req.GetContent()
+	ctx, span := tracer.Start(ctx, "s3.put", objectAttrs(bkt, obj), trace.WithAttributes(attribute.Int("bytes", len(b))))
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
+	sum := md5.Sum(b)
+	h := http.Header{}
+	h.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	ct, enc := s.r.contentHeaders(req, obj)
+	if ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	if enc != "" {
+		h.Set("Content-Encoding", enc)
+	}
+	if crc := req.GetCrc32C(); crc != "" {
+		v, err := parseCRC32C(crc)
+		if err != nil {
+			return nil, err
+		}
+		var be [4]byte
+		binary.BigEndian.PutUint32(be[:], v)
+		h.Set("X-Amz-Checksum-Crc32c", base64.StdEncoding.EncodeToString(be[:]))
+	}
+	md := s.r.objectMeta(ctx, req)
+	for k, v := range md {
+		h.Set(s3MetaPrefix+k, v)
+	}
+	if conds.DoesNotExist {
+		h.Set("If-None-Match", "*")
+	}
+	start := time.Now()
+	resp, err := s.do(ctx, http.MethodPut, bkt, obj, h, b)
+	s.r.shed.observe(start, err)
+	if err != nil {
+		if preconditionFailed(err) {
+			return nil, writtenMeanwhile(req.GetFilename(), err)
+		}
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, obj)
+	}
+	resp.Body.Close()
+	return &storage.ObjectAttrs{
+		Bucket:          bkt,
+		Name:            obj,
+		Size:            int64(len(b)),
+		MD5:             sum[:],
+		ContentType:     ct,
+		ContentEncoding: enc,
+		Etag:            resp.Header.Get("ETag"),
+		Metadata:        md,
+		Updated:         s.time(),
+	}, nil
+}
+
+// do sends a signed request for a bucket, or an object of it, and returns the
+// response of a 2xx status. The error of another status is a googleapi.Error
+// of it, storage.ErrObjectNotExist for a 404 of an object.
+func (s *s3Store) do(ctx context.Context, method, bkt, obj string, h http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bkt
+	if obj != "" {
+		u.Path += "/" + obj
+	}
+	u.RawPath = s3Escape(u.Path)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && obj != "" {
+		return nil, storage.ErrObjectNotExist
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, &googleapi.Error{Code: resp.StatusCode, Message: fmt.Sprintf("%s %s: %s", method, u.Path, bytes.TrimSpace(msg))}
+}
+
+func (s *s3Store) time() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// sign signs a request with AWS Signature Version 4, every header of the
+// request is signed.
+func (s *s3Store) sign(req *http.Request, body []byte) {
+	now := s.time().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonReq := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	reqHash := sha256.Sum256([]byte(canonReq))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(reqHash[:])
+	key := hmacSHA256([]byte("AWS4"+s.secret), day)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.key+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// s3Escape escapes a path as S3 signs it: every byte but the unreserved
+// characters and the slashes is percent-encoded.
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestS3Escape(t *testing.T) {
+	tests := []struct {
+		desc string
+		path string
+		want string
+	}{{
+		desc: "unreserved characters",
+		path: "/bkt/route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+		want: "/bkt/route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+	}, {
+		desc: "reserved characters",
+		path: "/bkt/a b+c=d~e",
+		want: "/bkt/a%20b%2Bc%3Dd~e",
+	}, {
+		desc: "multibyte characters",
+		path: "/bkt/é",
+		want: "/bkt/%C3%A9",
+	}}
+	for _, test := range tests {
+		if got := s3Escape(test.path); got != test.want {
+			t.Errorf("%s: s3Escape(%q) = %q, want %q", test.desc, test.path, got, test.want)
+		}
+	}
+}
+
+func TestNewS3Store(t *testing.T) {
+	creds := map[string]string{"AWS_ACCESS_KEY_ID": "key", "AWS_SECRET_ACCESS_KEY": "secret"}
+	tests := []struct {
+		desc       string
+		conf       *s3Config
+		env        map[string]string
+		wantRegion string
+		wantErr    bool
+	}{{
+		desc:       "default region",
+		conf:       &s3Config{Endpoint: "https://minio.example.net:9000"},
+		env:        creds,
+		wantRegion: "us-east-1",
+	}, {
+		desc:       "region",
+		conf:       &s3Config{Endpoint: "http://minio:9000", Region: "eu-west-1"},
+		env:        creds,
+		wantRegion: "eu-west-1",
+	}, {
+		desc:    "bad endpoint",
+		conf:    &s3Config{Endpoint: "minio:9000"},
+		env:     creds,
+		wantErr: true,
+	}, {
+		desc:    "missing credentials",
+		conf:    &s3Config{Endpoint: "https://minio.example.net:9000"},
+		env:     map[string]string{"AWS_ACCESS_KEY_ID": "key"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		lookup := func(k string) (string, bool) {
+			v, ok := test.env[k]
+			return v, ok
+		}
+		s, err := newS3Store(test.conf, lookup)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("%s: newS3Store() got err: %v; want nil err", test.desc, err)
+		case err == nil && test.wantErr:
+			t.Errorf("%s: newS3Store() got nil err, want err", test.desc)
+		case err == nil && s.region != test.wantRegion:
+			t.Errorf("%s: newS3Store() got region %q, want %q", test.desc, s.region, test.wantRegion)
+		}
+	}
+}
+
+func TestCheckS3(t *testing.T) {
+	tests := []struct {
+		desc    string
+		conf    *config
+		wantErr bool
+	}{{
+		desc: "buckets",
+		conf: &config{Buckets: map[string]string{"ROUTEVIEWS": "routeviews-archives"}},
+	}, {
+		desc:    "replicas",
+		conf:    &config{Replicas: map[string][]string{"routeviews-archives": {"routeviews-archives-dr"}}},
+		wantErr: true,
+	}, {
+		desc:    "kms keys",
+		conf:    &config{KMSKeys: map[string]string{"RPKI_RARC": "key"}},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		err := checkS3(test.conf)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("%s: checkS3() got err: %v; want nil err", test.desc, err)
+		case err == nil && test.wantErr:
+			t.Errorf("%s: checkS3() got nil err, want err", test.desc)
+		}
+	}
+}
+
+// fakeS3 is an S3 server of a single bucket, which keeps the requests it gets.
+type fakeS3 struct {
+	objects map[string][]byte
+	reqs    []*http.Request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.reqs = append(f.reqs, req)
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/20210901/us-east-1/s3/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch req.Method {
+	case http.MethodHead:
+		if req.URL.Path == "/bkt" {
+			return
+		}
+		b, ok := f.objects[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		sum := md5.Sum(b)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.Header().Set("X-Amz-Meta-Project", "ROUTEVIEWS")
+	case http.MethodPut:
+		if _, ok := f.objects[req.URL.Path]; ok && req.Header.Get("If-None-Match") == "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		b, _ := ioutil.ReadAll(req.Body)
+		f.objects[req.URL.Path] = b
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	f := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	s, err := newS3Store(&s3Config{Endpoint: srv.URL}, func(k string) (string, bool) {
+		return map[string]string{"AWS_ACCESS_KEY_ID": "key", "AWS_SECRET_ACCESS_KEY": "secret"}[k], true
+	})
+	if err != nil {
+		t.Fatalf("newS3Store() got err: %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC) }
+	store := rvServer{conf: &config{}, s3: s}.objects()
+	ctx := context.Background()
+
+	if err := store.checkBucket(ctx, "bkt"); err != nil {
+		t.Errorf("checkBucket() got err: %v", err)
+	}
+	if _, err := store.attrs(ctx, "bkt", "a/b.bz2"); err != storage.ErrObjectNotExist {
+		t.Errorf("attrs() of a missing object got err: %v, want %v", err, storage.ErrObjectNotExist)
+	}
+
+	content := []byte("content")
+	req := &pb.FileRequest{Filename: "a/b.bz2", Content: content, Project: pb.FileRequest_ROUTEVIEWS}
+	if _, err := store.write(ctx, "bkt", "a/b.bz2", req, storage.Conditions{DoesNotExist: true}); err != nil {
+		t.Fatalf("write() got err: %v", err)
+	}
+	if diff := cmp.Diff(f.objects["/bkt/a/b.bz2"], content); diff != "" {
+		t.Errorf("write() got content diff (-got +want):\n%s", diff)
+	}
+	put := f.reqs[len(f.reqs)-1]
+	sum := md5.Sum(content)
+	if got, want := put.Header.Get("Content-MD5"), base64.StdEncoding.EncodeToString(sum[:]); got != want {
+		t.Errorf("write() got Content-MD5 %q, want %q", got, want)
+	}
+	if got := put.Header.Get("Content-Type"); got != "application/x-bzip2" {
+		t.Errorf("write() got Content-Type %q, want application/x-bzip2", got)
+	}
+
+	attrs, err := store.attrs(ctx, "bkt", "a/b.bz2")
+	if err != nil {
+		t.Fatalf("attrs() got err: %v", err)
+	}
+	if diff := cmp.Diff(attrs.MD5, sum[:]); diff != "" {
+		t.Errorf("attrs() got md5 diff (-got +want):\n%s", diff)
+	}
+	if got := attrs.Metadata["project"]; got != "ROUTEVIEWS" {
+		t.Errorf("attrs() got project metadata %q, want ROUTEVIEWS", got)
+	}
+
+	_, err = store.write(ctx, "bkt", "a/b.bz2", req, storage.Conditions{DoesNotExist: true})
+	if got := status.Code(err); got != codes.FailedPrecondition {
+		t.Errorf("write() of an existing object got code %v, want %v", got, codes.FailedPrecondition)
+	}
+}
@@ -9,24 +9,50 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net"
+	"net/http"
 	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/golang/glog"
 	log "github.com/golang/glog"
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
 	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	"github.com/routeviews/google-cloud-storage/pkg/tracing"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	"github.com/routeviews/google-cloud-storage/pkg/version"
 	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	// Registers the gzip compressor, for clients which compress their calls.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 	"gopkg.in/yaml.v2"
 )
 
@@ -36,8 +62,13 @@ const (
 	// Be sure to have the JSON authentication bits in env(GOOGLE_APPLICATION_CREDENTIALS)
 	projectID = "1071922449970"
 
-	// Set a max receive message size: 50mb
+	// maxMsgSize is the default message size limit, of the clients as well.
 	maxMsgSize = 512 * 1024 * 1024
+
+	// storageRetryTimeout bounds a storage write with its retries, a failure
+	// which persists beyond it fails the RPC. The deadline of the RPC bounds
+	// it as well, see stageContext.
+	storageRetryTimeout = 5 * time.Minute
 )
 
 var (
@@ -46,6 +77,79 @@ var (
 	configFile = flag.String("config_file", "",
 		"YAML config file for the upload server.")
 
+	// Clients older than this protocol version are rejected, empty accepts every client.
+	minClientVersion = flag.String("min_client_version", "",
+		"Minimum client protocol version, ie: 1.1.0. Empty accepts every client.")
+	audience = flag.String("audience", "",
+		"Audience of the caller ID tokens, ie: the service URL. Empty accepts any audience.")
+	tlsCert = flag.String("tls_cert", "",
+		"PEM server certificate, serve TLS with it rather than plain text (Cloud Run terminates TLS).")
+	tlsKey = flag.String("tls_key", "",
+		"PEM private key of the tls_cert.")
+	clientCA = flag.String("client_ca", "",
+		"PEM CA bundle of the client certificates, requires mutual TLS of every client. Needs tls_cert.")
+	kmsKey = flag.String("kms_key", "",
+		"Cloud KMS key to encrypt the objects with, unless the config sets a key of the project. Empty uses the bucket default.")
+	// Signed URLs of direct uploads to cloud-storage, see SignUpload.
+	signedURLTTL = flag.Duration("signed_url_ttl", 0,
+		"Time the signed URLs of direct uploads are valid for, ie: 1h; 0 disables signed uploads.")
+	signerEmail = flag.String("signer_email", "",
+		"Service account to sign the URLs of direct uploads as; empty detects the account of the server.")
+	validateMRT = flag.Bool("validate_mrt", false,
+		"Reject the ROUTEVIEWS updates archives which are truncated or not valid MRT with INVALID_FORMAT, rather than store them.")
+	quarantineRejected = flag.Bool("quarantine", false,
+		"Keep the content of the uploads rejected by validation under _quarantine/ of the bucket of their project, rather than drop it.")
+	notifyTopic = flag.String("notify_topic", "",
+		"Pub/Sub topic to publish a notification of each object stored to, projects/<project>/topics/<topic>; empty disables it.")
+	storageBackend = flag.String("storage", "",
+		"Where the objects of FileUpload are stored: empty stores them to cloud-storage, or the s3 store of the config; local:<dir> stores them under a local directory, for development and tests.")
+	httpPort = flag.String("http_port", "",
+		"Port to serve the REST gateway of FileUpload on, POST /v1/files, with the TLS of the server; empty disables it.")
+	uploadLogTable = flag.String("upload_log_table", "",
+		"BigQuery table to insert a row of each object stored into, <project>.<dataset>.<table>; empty disables it.")
+	requestTimeout = flag.Duration("request_timeout", time.Hour,
+		"Deadline of the RPCs whose callers set none, ie: the longest upload; 0 leaves them unbounded.")
+	compositePartBytes = flag.Int("composite_part_bytes", 0,
+		"Size of the parts of the parallel composite uploads of the streamed files larger than it, ie: 67108864; 0 disables them.")
+	compositeParallelism = flag.Int("composite_parallelism", 4,
+		"Parts of a parallel composite upload written in parallel, each buffered in memory.")
+	shedLatency = flag.Duration("shed_latency", 2*time.Minute,
+		"Mean storage write latency, over the last minute, above which new uploads are shed with UNAVAILABLE; 0 disables it.")
+	shedErrorRate = flag.Float64("shed_error_rate", 0.5,
+		"Rate (0 to 1) of failed storage writes, over the last minute, above which new uploads are shed with UNAVAILABLE; 0 disables it.")
+	convertBucket = flag.String("convert_bucket", "",
+		"BigQuery bucket to convert the updates archives of the convert_now uploads to before the response; empty skips them.")
+
+	// Cloud Run kills an instance 10 seconds after SIGTERM.
+	drainTimeout = flag.Duration("drain_timeout", 9*time.Second,
+		"Time to let the uploads in flight finish after SIGTERM, before they are cancelled.")
+	auditLogs = flag.Bool("audit_log", true,
+		"Write a structured audit record of each upload to stdout, for Cloud Logging.")
+
+	// Tracing of the RPCs and their storage calls, see tracing.
+	traceProject = flag.String("trace_project", "", "GCP project to export traces to Cloud Trace in; empty disables tracing.")
+	traceRatio   = flag.Float64("trace_ratio", 0.1, "Fraction (0 to 1) of the requests to trace, traced client calls are always traced.")
+
+	// Transport tuning, see tuning. Zero keeps the grpc default.
+	maxMsgBytes = flag.Int("max_msg_bytes", maxMsgSize,
+		"Message size limit, of the decoded content as well. Raise the limit of the clients with it.")
+	maxStreams = flag.Uint("max_concurrent_streams", 0,
+		"Max concurrent streams (RPCs) of each client connection; 0 is unlimited.")
+	keepaliveTime = flag.Duration("keepalive_time", 0,
+		"Ping an idle client connection after this time; 0 is 2h.")
+	keepaliveTimeout = flag.Duration("keepalive_timeout", 0,
+		"Close a connection whose ping is unanswered after this time; 0 is 20s.")
+	keepaliveMinTime = flag.Duration("keepalive_min_time", 0,
+		"Min time between the keepalive pings of a client, those which ping more often are disconnected; 0 is 5m.")
+	keepaliveWithoutStream = flag.Bool("keepalive_permit_without_stream", false,
+		"Allow client keepalive pings on connections without an RPC in flight.")
+	maxConnIdle = flag.Duration("max_connection_idle", 0,
+		"Close a connection without an RPC for this time; 0 is unlimited.")
+	maxConnAge = flag.Duration("max_connection_age", 0,
+		"Close a connection after this time, to rebalance clients; 0 is unlimited.")
+	maxConnAgeGrace = flag.Duration("max_connection_age_grace", 0,
+		"Time to let the RPCs of a connection past max_connection_age finish, ie: the longest upload.")
+
 	// TODO(morrowc): find a method to define the TLS certificate to be used, if this will
 	//                not be done through GCLB's inbound https path.
 )
@@ -53,45 +157,328 @@ var (
 type rvServer struct {
 	conf *config
 	sc   *storage.Client
+	// minClientVersion is the minimum client protocol version accepted.
+	minClientVersion string
+	// audit is the audit log of the uploads, nil disables it.
+	audit *auditLog
+	// audience is the audience of the caller ID tokens, empty skips the check.
+	audience string
+	// validate validates the caller ID tokens, nil is idtoken.Validate.
+	validate validateFunc
+	// kms is the Cloud KMS key of the objects of the projects without a key
+	// in the config, empty uses the default encryption of the bucket.
+	kms string
+	// signTTL is the time the signed URLs of uploads are valid for, zero
+	// disables signed uploads.
+	signTTL time.Duration
+	// signer is the service account the URLs are signed as, empty detects it.
+	signer string
+	// validateMRT validates the ROUTEVIEWS updates archives before they are
+	// stored, see checksMRT.
+	validateMRT bool
+	// quarantine keeps the content of the uploads which fail validation, see
+	// quarantines.
+	quarantine bool
+	// s3 stores the objects of FileUpload, nil stores them in cloud-storage,
+	// see objects.
+	s3 *s3Store
+	// local stores the objects of FileUpload under a local directory, for
+	// development, see objects.
+	local *localStore
+	// publish publishes the notifications of the objects stored, nil
+	// disables them.
+	publish publishFunc
+	// uploadLog inserts the rows of the objects stored into the upload log,
+	// nil disables it.
+	uploadLog uploadLogFunc
+	// convert converts the archives of the uploads with convert_now before
+	// the response, nil skips them.
+	convert convertFunc
+	// replicas keeps the copies to the replica buckets pending, nil drops
+	// the failed copies.
+	replicas *replicator
+	// names are the filename patterns of the projects, see checkFilename.
+	names map[pb.FileRequest_Project]*regexp.Regexp
+	// limits enforces the quotas of the callers, nil allows everything.
+	limits *limiter
+	// maxMsgBytes is the message size limit, zero is maxMsgSize.
+	maxMsgBytes int
+	// idem keeps the responses of the requests with a request_id, for their
+	// retries, nil disables it.
+	idem *idemCache
+	// partSize is the size of the parts of the parallel composite uploads of
+	// FileUploadStream, zero disables them, see composite. partParallelism
+	// bounds the parts written in parallel by an upload.
+	partSize        int
+	partParallelism int
+	// shed sheds the new uploads while the storage is degraded, nil disables
+	// it.
+	shed *shedder
+	// recent keeps the attributes of the objects looked up recently, nil
+	// disables it.
+	recent *attrsCache
+	// stats counts the objects stored by the instance, see ServerStats, nil
+	// counts nothing.
+	stats *serverStats
+	// live holds the policy of the config once it is reloaded, which
+	// replaces conf, names and limits, see reload. nil never reloads.
+	live *liveConfig
 	pb.UnimplementedRVServer
 }
 
-// setProjectMeta set project source in the metadata of a GCS object. The
-// object must've existed when we set metadata.
-func (r rvServer) setProjectMeta(ctx context.Context, bkt, obj string, proj pb.FileRequest_Project) error {
+// objectMeta returns the metadata of the object of a file: the project
+// source, the sha256 checksum and source URL if the request carries them, the
+// collector and capture time of an archive of a known layout, and the caller
+// which uploaded it.
+func (r rvServer) objectMeta(ctx context.Context, req *pb.FileRequest) map[string]string {
+	md := map[string]string{
+		converter.ProjectMetadataKey: req.GetProject().String(),
+	}
+	if sum := req.GetSha256(); sum != "" {
+		md[uploadutils.SHA256MetadataKey] = sum
+	}
+	if u := req.GetSourceUrl(); u != "" {
+		md[uploadutils.SourceURLMetadataKey] = u
+	}
+	if a, ok := archiveprofile.ParseProject(req.GetProject(), req.GetFilename()); ok {
+		md[uploadutils.CollectorMetadataKey] = a.Collector
+		if !a.Time.IsZero() {
+			md[uploadutils.CaptureTimeMetadataKey] = a.Time.Format(time.RFC3339)
+		}
+	}
+	if caller, err := r.caller(ctx); err == nil {
+		md[uploadutils.UploaderMetadataKey] = caller
+	}
+	return md
+}
+
+// contentTypes are the content types of the compression formats of archives,
+// which are missing from the mime package.
+var contentTypes = map[string]string{
+	".bz2": "application/x-bzip2",
+	".gz":  "application/gzip",
+	".xz":  "application/x-xz",
+}
+
+// contentType returns the content type of a file from its extension, empty if
+// it is unknown.
+func contentType(fn string) string {
+	ext := strings.ToLower(path.Ext(fn))
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// object returns the handle of an object which retries transient failures,
+// ie: a 503, with the backoff of the storage client, so only persistent
+// failures fail the RPC. The writes of the server are safe to retry: the
+// content is in memory and verified by its checksums, and the metadata is set
+// to the same values on each attempt. A write which fails its preconditions is
+// not retried, see retryable.
+func (r rvServer) object(bkt, obj string) *storage.ObjectHandle {
+	return r.sc.Bucket(bkt).Object(obj).Retryer(storage.WithPolicy(storage.RetryAlways), storage.WithErrorFunc(retryable))
+}
+
+// retryable reports whether a failed storage call is retried: a transient
+// failure, but for a failed precondition, which fails the same way again.
+func retryable(err error) bool {
+	return !preconditionFailed(err) && storage.ShouldRetry(err)
+}
+
+// setProjectMeta set project source, and the rest of objectMeta, in the
+// metadata of a GCS object, and its content type and encoding, see
+// contentHeaders. The object must've existed when we set metadata, at the
+// generation written: the metadata of a generation written meanwhile is not
+// set. It returns the updated attributes of the object.
+func (r rvServer) setProjectMeta(ctx context.Context, bkt, obj string, gen int64, req *pb.FileRequest) (updated *storage.ObjectAttrs, err error) {
+	ctx, span := tracer.Start(ctx, "gcs.metadata", objectAttrs(bkt, obj))
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
 	// Set metadata once the object is created.
-	if _, err := r.sc.Bucket(bkt).Object(obj).Update(ctx, storage.ObjectAttrsToUpdate{
-		Metadata: map[string]string{
-			converter.ProjectMetadataKey: proj.String(),
-		},
-	}); err != nil {
-		return fmt.Errorf("failed to set metadata '%s:%s': %v", converter.ProjectMetadataKey, proj.String(), err)
+	attrs := storage.ObjectAttrsToUpdate{
+		Metadata: r.objectMeta(ctx, req),
 	}
-	glog.Infof("Set metadata for object: %s", obj)
-	return nil
+	ct, enc := r.contentHeaders(req, obj)
+	if ct != "" {
+		attrs.ContentType = ct
+	}
+	if enc != "" {
+		attrs.ContentEncoding = enc
+	}
+	updated, err = r.object(bkt, obj).If(storage.Conditions{GenerationMatch: gen}).Update(ctx, attrs)
+	if err != nil {
+		if preconditionFailed(err) {
+			return nil, writtenMeanwhile(req.GetFilename(), err)
+		}
+		return nil, storageError(err, "failed to set metadata '%s:%s'", converter.ProjectMetadataKey, req.GetProject().String())
+	}
+	return updated, nil
+}
+
+// setStoredMeta sets the metadata of an object stored by a request, see
+// setProjectMeta: the update of the metadata, rather than the write, triggers
+// the conversion of an archive. An object whose metadata is not set is
+// deleted, as the retry of the request would find it unchanged.
+func (r rvServer) setStoredMeta(ctx context.Context, bkt, obj string, stored *storage.ObjectAttrs, req *pb.FileRequest) (*storage.ObjectAttrs, error) {
+	updated, err := r.setProjectMeta(ctx, bkt, obj, stored.Generation, req)
+	if err != nil {
+		r.deletePartial(bkt, obj, stored.Generation)
+		return nil, err
+	}
+	return updated, nil
 }
 
-// fileStore stores a file ([]byte) to a designated bucket location (string).
-func (r rvServer) fileStore(ctx context.Context, bkt, fn string, b []byte) error {
+// fileStore stores the content of a request to a designated bucket location
+// (string). The crc32c checksum, if set, is sent along for cloud-storage to
+// verify, and the object gets the storage class and KMS key of the project, if
+// set, and the holds of the retention of the project. The write is made on the
+// conditions of writeConditions. It returns the attributes of the stored
+// object.
+func (r rvServer) fileStore(ctx context.Context, bkt, fn string, req *pb.FileRequest, conds storage.Conditions) (attrs *storage.ObjectAttrs, err error) {
+	b := req.GetContent()
+	ctx, span := tracer.Start(ctx, "gcs.write", objectAttrs(bkt, fn), trace.WithAttributes(attribute.Int("bytes", len(b))))
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
 	// Store the file content to the destination bucket.
-	wc := r.sc.Bucket(bkt).Object(fn).NewWriter(ctx)
-	defer wc.Close()
+	start := time.Now()
+	wc := r.object(bkt, fn).If(conds).NewWriter(ctx)
+	wc.StorageClass = r.config().storageClass(req.GetProject(), req.GetFilename(), time.Now())
+	wc.KMSKeyName = r.kmsKey(req.GetProject().String())
+	r.setHolds(&wc.ObjectAttrs, req.GetProject().String())
+	if err := setCRC32C(wc, req.GetCrc32C()); err != nil {
+		wc.Close()
+		return nil, err
+	}
 	if _, err := io.Copy(wc, bytes.NewReader(b)); err != nil {
-		return fmt.Errorf("failed copying content to destination: %s/%s: %v", bkt, fn, err)
+		wc.Close()
+		return nil, storageError(err, "failed copying content to destination: %s/%s", bkt, fn)
+	}
+	// The write is only committed, or rejected by cloud-storage, on Close.
+	err = wc.Close()
+	r.shed.observe(start, err)
+	if err != nil {
+		if preconditionFailed(err) {
+			return nil, writtenMeanwhile(req.GetFilename(), err)
+		}
+		return nil, storageError(err, "failed storing object: %s/%s", bkt, fn)
+	}
+	return wc.Attrs(), nil
+}
+
+// msgLimit returns the message size limit of the server.
+func (r rvServer) msgLimit() int {
+	if r.maxMsgBytes > 0 {
+		return r.maxMsgBytes
+	}
+	return maxMsgSize
+}
+
+// decodeContent returns the decoded content of a FileRequest. Decoded content
+// is limited to the message size limit, as plain content is.
+func decodeContent(enc string, b []byte, limit int) ([]byte, error) {
+	switch enc {
+	case "":
+		return b, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to decode gzip content: %v", err)
+		}
+		defer zr.Close()
+		content, err := ioutil.ReadAll(io.LimitReader(zr, int64(limit)+1))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to decode gzip content: %v", err)
+		}
+		if len(content) > limit {
+			return nil, status.Errorf(codes.InvalidArgument, "decoded content exceeds %d bytes", limit)
+		}
+		return content, nil
+	}
+	return nil, badField("content_encoding", reasonInvalidField, "unsupported content_encoding(%q)", enc)
+}
+
+// parseCRC32C parses a crc32c checksum, 8 hex digits.
+func parseCRC32C(sum string) (uint32, error) {
+	b, err := hex.DecodeString(sum)
+	if err != nil || len(b) != crc32.Size {
+		return 0, invalidChecksum("crc32c", "crc32c", sum)
 	}
-	glog.Infof("Stored object to GCS: %s/%s", bkt, fn)
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// setCRC32C has cloud-storage verify the content written against the crc32c
+// checksum, if one is set.
+func setCRC32C(wc *storage.Writer, sum string) error {
+	if sum == "" {
+		return nil
+	}
+	crc, err := parseCRC32C(sum)
+	if err != nil {
+		return err
+	}
+	wc.CRC32C = crc
+	wc.SendCRC32C = true
 	return nil
 }
 
-// newRVServer creates and returns a proper RV object.
-func newRVServer(ctx context.Context, cf string, client *storage.Client) (*rvServer, error) {
+// unchanged reports whether the object exists with the md5sum, so its write
+// may be skipped, and returns its attributes. A failed lookup is logged, and
+// the object written.
+func (r rvServer) unchanged(ctx context.Context, bkt, obj, sum string) (*storage.ObjectAttrs, bool) {
+	ctx, span := tracer.Start(ctx, "gcs.attrs", objectAttrs(bkt, obj))
+	attrs, err := r.lookup(ctx, bkt, obj)
+	span.End()
+	if err != nil {
+		if err != storage.ErrObjectNotExist {
+			glog.Errorf("failed to get the attributes of %s/%s: %v", bkt, obj, err)
+		}
+		return nil, false
+	}
+	got := uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	return attrs, got != "" && got == sum
+}
+
+// newRVServer creates and returns a proper RV object. The objects of
+// FileUpload are stored in the local store, if not nil.
+func newRVServer(ctx context.Context, cf string, client *storage.Client, local *localStore) (*rvServer, error) {
 	c, err := readConfigFile(cf)
 	if err != nil {
 		return nil, err
 	}
-	// Check if each bucket exists.
+	// The objects of FileUpload are stored in an S3-compatible store rather
+	// than cloud-storage if the config sets one.
+	var s3 *s3Store
+	switch {
+	case c.S3 != nil && local != nil:
+		return nil, errors.New("the s3 store of the config and local storage are exclusive")
+	case local != nil:
+		if err := checkGCSOnly(c, "local"); err != nil {
+			return nil, err
+		}
+	case c.S3 != nil:
+		if err := checkS3(c); err != nil {
+			return nil, err
+		}
+		if s3, err = newS3Store(c.S3, os.LookupEnv); err != nil {
+			return nil, err
+		}
+	case client == nil:
+		return nil, errors.New("no storage client")
+	}
+	store := rvServer{sc: client, s3: s3, local: local}.objects()
+	// Check if each project is known, and each bucket exists.
+	dests := map[string]string{}
 	for proj, bkt := range c.Buckets {
-		_, err := client.Bucket(bkt).Attrs(ctx)
+		dests[proj] = bkt
+	}
+	for proj, rt := range c.Routes {
+		dests[proj] = rt.Bucket
+	}
+	for proj, bkt := range dests {
+		err := store.checkBucket(ctx, bkt)
 		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
 			return nil, fmt.Errorf("bad project %s: %v", proj, err)
 		}
@@ -99,32 +486,118 @@ func newRVServer(ctx context.Context, cf string, client *storage.Client) (*rvSer
 			return nil, fmt.Errorf("bad bucket %s: %v", bkt, err)
 		}
 	}
-	return &rvServer{
-		conf: c,
-		sc:   client,
-	}, nil
+	if c.Default != nil {
+		if err := store.checkBucket(ctx, c.Default.Bucket); err != nil {
+			return nil, fmt.Errorf("bad default bucket %s: %v", c.Default.Bucket, err)
+		}
+	}
+	if err := checkCallers(c.Callers); err != nil {
+		return nil, err
+	}
+	if err := checkCallers(c.Admins); err != nil {
+		return nil, err
+	}
+	if err := checkQuotas(c.Quotas); err != nil {
+		return nil, err
+	}
+	if err := checkStorageClasses(c.StorageClasses); err != nil {
+		return nil, err
+	}
+	if err := checkKMSKeys(c.KMSKeys); err != nil {
+		return nil, err
+	}
+	if err := checkReplicas(ctx, client, c); err != nil {
+		return nil, err
+	}
+	if err := checkFailover(ctx, client, c); err != nil {
+		return nil, err
+	}
+	if err := checkConvertNow(c); err != nil {
+		return nil, err
+	}
+	if err := checkTranscode(c.Transcode); err != nil {
+		return nil, err
+	}
+	if err := checkMaxSizes(c.MaxSizes); err != nil {
+		return nil, err
+	}
+	names, err := compileNamePatterns(c.FilenamePatterns)
+	if err != nil {
+		return nil, err
+	}
+	r := &rvServer{
+		conf:     c,
+		sc:       client,
+		s3:       s3,
+		local:    local,
+		names:    names,
+		replicas: newReplicator(),
+		limits:   newLimiter(c.Quotas),
+		idem:     newIdemCache(idemTTL, idemSize),
+		recent:   newAttrsCache(attrsTTL, attrsSize),
+		stats:    newServerStats(time.Now),
+		live:     &liveConfig{},
+	}
+	if err := r.checkRetention(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
-// Store a RARC RPKI or Routeviews file to cloud storage.
-func (r rvServer) handleDataFile(ctx context.Context, req *pb.FileRequest, resp *pb.FileResponse) (*pb.FileResponse, error) {
-	if _, ok := r.conf.Buckets[req.GetProject().String()]; !ok {
+// Store a RARC RPKI, RIPE RIS or Routeviews file to cloud storage. The object is added
+// to the audit record of the upload.
+func (r rvServer) handleDataFile(ctx context.Context, req *pb.FileRequest, resp *pb.FileResponse, rec *uploadRecord) (*pb.FileResponse, error) {
+	bkt, prefix, ok := r.config().route(req.GetProject().String())
+	if !ok {
 		resp.Status = pb.FileResponse_FAIL
-		return resp, fmt.Errorf("%s is not supported", req.GetProject())
+		return resp, unsupportedProject("project", req.GetProject())
 	}
-	bkt := r.conf.Buckets[req.GetProject().String()]
+	obj := prefix + req.GetFilename()
+	rec.Object = "gs://" + bkt + "/" + obj
 
-	if err := r.fileStore(ctx, bkt, req.GetFilename(), req.GetContent()); err != nil {
+	// Clients resend unchanged content after transient errors, skip the write
+	// rather than create a new generation.
+	existing, ok, err := r.checkOverwrite(ctx, bkt, obj, req, rec)
+	if err != nil {
 		resp.Status = pb.FileResponse_FAIL
 		return resp, err
 	}
-	if err := r.setProjectMeta(ctx, bkt, req.GetFilename(), req.GetProject()); err != nil {
+	if ok {
+		resp.Status = pb.FileResponse_UNCHANGED
+		resp.Retention = retentionOf(existing)
+		rec.Generation = existing.Generation
+		return r.withObject(resp, existing), nil
+	}
+
+	attrs, err := r.objects().write(ctx, bkt, obj, req, writeConditions(existing))
+	var unchanged bool
+	if err != nil {
+		// A bucket which is unavailable fails over to its failover bucket.
+		attrs, unchanged, err = r.failOver(ctx, bkt, obj, req, rec, err)
+	}
+	if err != nil {
 		resp.Status = pb.FileResponse_FAIL
 		return resp, err
 	}
+	if unchanged {
+		// The object was stored to the failover bucket by a previous attempt.
+		resp.Status = pb.FileResponse_UNCHANGED
+		resp.Retention = retentionOf(attrs)
+		rec.Generation = attrs.Generation
+		return r.withObject(resp, attrs), nil
+	}
+	r.recent.put(attrs)
+	resp.Retention = retentionOf(attrs)
+	rec.Generation = attrs.Generation
+	resp.Replicas = r.replicate(ctx, attrs)
+	// The archive is converted before the notification, whose conversion
+	// finds it converted.
+	resp.Conversion = r.convertNow(ctx, req, attrs)
+	r.notifyStored(ctx, req.GetProject().String(), attrs)
+	r.logUpload(ctx, req.GetProject().String(), attrs)
+	r.stats.stored(req.GetProject().String(), attrs.Size)
 	resp.Status = pb.FileResponse_SUCCESS
-
-	glog.Infof("Finished processing datafile: %s", req.GetFilename())
-	return resp, nil
+	return r.withObject(resp, attrs), nil
 }
 
 // FileUpload collects a file and handles it according to the appropriate rules.
@@ -136,28 +609,318 @@ func (r rvServer) handleDataFile(ctx context.Context, req *pb.FileRequest, resp
 //
 // If any of these is missing the requset is invalid.
 //
-func (r rvServer) FileUpload(ctx context.Context, req *pb.FileRequest) (*pb.FileResponse, error) {
-	resp := &pb.FileResponse{}
+func (r rvServer) FileUpload(ctx context.Context, req *pb.FileRequest) (resp *pb.FileResponse, err error) {
+	rec := &uploadRecord{
+		RPC:      "FileUpload",
+		Filename: req.GetFilename(),
+		Project:  req.GetProject().String(),
+		Size:     int64(len(req.GetContent())),
+		MD5:      req.GetMd5Sum(),
+	}
+	defer func() { r.auditUpload(ctx, rec, resp.GetStatus(), err) }()
+	resp = &pb.FileResponse{}
 
 	fn := req.GetFilename()
 	content := req.GetContent()
 	proj := req.GetProject()
-	sum := req.GetMd5Sum()
-	if len(content) < 1 || proj == pb.FileRequest_UNKNOWN || len(fn) < 1 {
+	if err := requireFields("FileRequest",
+		field{"filename", len(fn) > 0},
+		field{"content", len(content) > 0},
+		field{"project", proj != pb.FileRequest_UNKNOWN}); err != nil {
+		resp.Status = pb.FileResponse_FAIL
+		return nil, err
+	}
+	fn, err = r.checkFilename(proj, fn)
+	if err != nil {
 		resp.Status = pb.FileResponse_FAIL
-		return nil, errors.New("base requirements for FileRequest unmet")
+		return nil, err
 	}
+	req.Filename, rec.Filename = fn, fn
 
-	// validate that content checksum matches the requseted checksum.
-	ts := md5.Sum(content)
-	tsString := hex.EncodeToString(ts[:])
-	if tsString != sum {
+	if err := r.authorize(ctx, proj.String()); err != nil {
+		resp.Status = pb.FileResponse_FAIL
+		return nil, err
+	}
+	if err := r.shed.admit(); err != nil {
+		resp.Status = pb.FileResponse_FAIL
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, int64(len(content))); err != nil {
+		resp.Status = pb.FileResponse_FAIL
+		return nil, err
+	}
+
+	// The retry of a request returns the response of the first.
+	if id := req.GetRequestId(); id != "" && r.idem != nil {
+		key := idemKey{proj: proj.String(), id: id, md5: req.GetMd5Sum()}
+		var cached bool
+		resp, cached, err = r.idem.do(ctx, key, func() (*pb.FileResponse, error) {
+			return r.storeFile(ctx, req, &pb.FileResponse{}, rec)
+		})
+		if cached {
+			glog.Infof("Request %s of %s is a retry, returned the first response", id, fn)
+		}
+		return resp, err
+	}
+	return r.storeFile(ctx, req, resp, rec)
+}
+
+// storeFile validates the content of a request, and stores it.
+func (r rvServer) storeFile(ctx context.Context, req *pb.FileRequest, resp *pb.FileResponse, rec *uploadRecord) (*pb.FileResponse, error) {
+	// Decode and validate the content, a span tells validation apart from the
+	// storage calls.
+	_, span := tracer.Start(ctx, "validate")
+	err := checkContent(req, r.msgLimit())
+	span.End()
+	if err != nil {
+		span.RecordError(err)
+		if quarantines(err) {
+			r.quarantineRequest(ctx, req, err)
+		}
+		resp.Status = pb.FileResponse_FAIL
+		return nil, err
+	}
+	rec.Size = int64(len(req.GetContent()))
+	if err := r.checkSize(req.GetProject(), "content", rec.Size); err != nil {
 		resp.Status = pb.FileResponse_FAIL
-		return nil, fmt.Errorf("checksum failure req(%q) != calc(%q)", sum, tsString)
+		return nil, err
+	}
+	if r.checksMRT(req.GetProject(), req.GetFilename()) {
+		if err := checkMRT(ctx, req.GetFilename(), bytes.NewReader(req.GetContent())); err != nil {
+			if invalid, ok := invalidFormat(err); ok {
+				r.quarantineRequest(ctx, req, err)
+				return invalid, nil
+			}
+			return nil, err
+		}
 	}
 
 	// Process the content based upon project requirements.
-	return r.handleDataFile(ctx, req, resp)
+	return r.handleDataFile(ctx, req, resp, rec)
+}
+
+// checkContent decodes the content of a request in place, and validates it
+// against the checksums of the request, which are of the decoded content.
+func checkContent(req *pb.FileRequest, limit int) error {
+	content, err := decodeContent(req.GetContentEncoding(), req.GetContent(), limit)
+	if err != nil {
+		return err
+	}
+	req.Content, req.ContentEncoding = content, ""
+
+	// validate that content checksum matches the requseted checksum, which is
+	// required.
+	if err := requireFields("FileRequest", field{"md5sum", req.GetMd5Sum() != ""}); err != nil {
+		return err
+	}
+	ts := md5.Sum(content)
+	if err := checkSum("md5sum", req.GetMd5Sum(), hex.EncodeToString(ts[:])); err != nil {
+		return err
+	}
+	// validate the optional crc32c checksum as well.
+	if crc := req.GetCrc32C(); crc != "" {
+		if _, err := parseCRC32C(crc); err != nil {
+			return err
+		}
+		calc, _ := uploadutils.Checksum(uploadutils.CRC32C, content)
+		if err := checkSum("crc32c", crc, calc); err != nil {
+			return err
+		}
+	}
+	// validate the optional sha256 checksum, it is stored in the metadata.
+	if want := req.GetSha256(); want != "" {
+		calc, _ := uploadutils.Checksum(uploadutils.SHA256, content)
+		return checkSum("sha256", want, calc)
+	}
+	return nil
+}
+
+// checkHashes checks the md5sum, and the crc32c and sha256 if set, of the file
+// metadata against the hashes of the content, see checkSum.
+func checkHashes(meta *pb.FileRequest, md5Sum, crc, sha hash.Hash) error {
+	if err := checkSum("md5sum", meta.GetMd5Sum(), hex.EncodeToString(md5Sum.Sum(nil))); err != nil {
+		return err
+	}
+	if err := checkSum("crc32c", meta.GetCrc32C(), hex.EncodeToString(crc.Sum(nil))); err != nil {
+		return err
+	}
+	return checkSum("sha256", meta.GetSha256(), hex.EncodeToString(sha.Sum(nil)))
+}
+
+// FileUploadStream collects a file in chunks, for files beyond the message
+// size limit. The first chunk carries the metadata, with the requirements of
+// FileUpload, and the content is written straight through to cloud-storage as
+// it is received. The object is not stored if the content does not match the
+// md5sum.
+func (r rvServer) FileUploadStream(stream pb.RV_FileUploadStreamServer) (err error) {
+	if err := r.needsGCS("FileUploadStream"); err != nil {
+		return err
+	}
+	rec := &uploadRecord{RPC: "FileUploadStream"}
+	var st pb.FileResponse_Status
+	defer func() { r.auditUpload(stream.Context(), rec, st, err) }()
+
+	chunk, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(status.Code(err), "failed to receive the file metadata: %v", err)
+	}
+	meta := chunk.GetMetadata()
+	rec.Filename, rec.Project, rec.MD5 = meta.GetFilename(), meta.GetProject().String(), meta.GetMd5Sum()
+	fn := meta.GetFilename()
+	proj := meta.GetProject()
+	sum := meta.GetMd5Sum()
+	if err := requireFields("FileChunk metadata",
+		field{"metadata.filename", len(fn) > 0},
+		field{"metadata.md5sum", len(sum) > 0},
+		field{"metadata.project", proj != pb.FileRequest_UNKNOWN}); err != nil {
+		return err
+	}
+	if enc := meta.GetContentEncoding(); enc != "" {
+		return badField("metadata.content_encoding", reasonInvalidField, "unsupported content_encoding(%q) of a stream", enc)
+	}
+	fn, err = r.checkFilename(proj, fn)
+	if err != nil {
+		return err
+	}
+	meta.Filename, rec.Filename = fn, fn
+	if err := r.authorize(stream.Context(), proj.String()); err != nil {
+		return err
+	}
+	if err := r.shed.admit(); err != nil {
+		return err
+	}
+	if err := r.limit(stream.Context(), proj.String(), 1, 0); err != nil {
+		return err
+	}
+	bkt, prefix, ok := r.config().route(proj.String())
+	if !ok {
+		return unsupportedProject("metadata.project", proj)
+	}
+	fn = prefix + fn
+	rec.Object = "gs://" + bkt + "/" + fn
+	wantSum, err := hex.DecodeString(sum)
+	if err != nil || len(wantSum) != md5.Size {
+		return invalidChecksum("metadata.md5sum", "md5sum", sum)
+	}
+	existing, ok, err := r.checkOverwrite(stream.Context(), bkt, fn, meta, rec)
+	if err != nil {
+		return err
+	}
+	if ok {
+		st, rec.Generation = pb.FileResponse_UNCHANGED, existing.Generation
+		return stream.SendAndClose(r.withObject(&pb.FileResponse{Status: st, Retention: retentionOf(existing)}, existing))
+	}
+
+	// Cancelling the context, or its expiry before the deadline of the
+	// stream, aborts the write, the object is not stored.
+	ctx, cancel := stageContext(stream.Context(), 0)
+	defer cancel()
+	wc := r.sc.Bucket(bkt).Object(fn).If(writeConditions(existing)).NewWriter(ctx)
+	// cloud-storage verifies the content against the md5sum as well.
+	wc.MD5 = wantSum
+	wc.ContentType, wc.ContentEncoding = r.contentHeaders(meta, fn)
+	wc.StorageClass = r.config().storageClass(proj, meta.GetFilename(), time.Now())
+	wc.KMSKeyName = r.kmsKey(proj.String())
+	r.setHolds(&wc.ObjectAttrs, proj.String())
+	if err := setCRC32C(wc, meta.GetCrc32C()); err != nil {
+		return err
+	}
+	// A file larger than a part is written in parallel parts, composed once
+	// it is received.
+	cw := r.newComposite(ctx, wc, bkt, fn, writeConditions(existing), sum)
+	defer cw.abort()
+	h := md5.New()
+	crc, _ := uploadutils.NewHash(uploadutils.CRC32C)
+	sha, _ := uploadutils.NewHash(uploadutils.SHA256)
+	// The content is quarantined as it is received, it is kept if rejected.
+	q := r.newQuarantine(ctx, bkt, fn, meta)
+	defer q.discard()
+	ws := []io.Writer{cw, h, crc, sha, q}
+	var check *mrtCheck
+	if r.checksMRT(proj, meta.GetFilename()) {
+		check = newMRTCheck(ctx, meta.GetFilename())
+		defer check.abort()
+		ws = append(ws, check)
+	}
+	w := io.MultiWriter(ws...)
+	var size int64
+	for {
+		// The bytes are taken from the quota as they are received.
+		if err := r.limit(stream.Context(), proj.String(), 0, int64(len(chunk.GetContent()))); err != nil {
+			return err
+		}
+		// A stream beyond the max size of the project is aborted, the object
+		// is not stored.
+		if err := r.checkSize(proj, "content", size+int64(len(chunk.GetContent()))); err != nil {
+			return err
+		}
+		n, err := w.Write(chunk.GetContent())
+		size += int64(n)
+		rec.Size = size
+		if err != nil {
+			return storageError(err, "failed copying content to destination: %s/%s", bkt, fn)
+		}
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status.Errorf(status.Code(err), "failed to receive the content of %s: %v", fn, err)
+		}
+	}
+	if err := requireFields("FileChunk", field{"content", size > 0}); err != nil {
+		return err
+	}
+
+	// validate that content checksum matches the requested checksum.
+	if err := checkHashes(meta, h, crc, sha); err != nil {
+		q.keep(stream.Context(), rec.RPC, meta, err)
+		return err
+	}
+	// Invalid content is not committed, the write is aborted on return.
+	if check != nil {
+		if err := check.result(); err != nil {
+			invalid, ok := invalidFormat(err)
+			if !ok {
+				return err
+			}
+			q.keep(stream.Context(), rec.RPC, meta, err)
+			st = invalid.GetStatus()
+			return stream.SendAndClose(invalid)
+		}
+	}
+	// The write is committed on Close, the rest of the write is paced by the
+	// stream.
+	_, span := tracer.Start(ctx, "gcs.close", objectAttrs(bkt, fn), trace.WithAttributes(attribute.Int64("bytes", size)))
+	start := time.Now()
+	err = cw.Close()
+	r.shed.observe(start, err)
+	endSpan(span, err)
+	if preconditionFailed(err) {
+		return writtenMeanwhile(meta.GetFilename(), err)
+	}
+	if err != nil {
+		return storageError(err, "failed storing object: %s/%s", bkt, fn)
+	}
+	// The metadata is set once the object is stored, as by FileUpload.
+	attrs, err := r.setStoredMeta(stream.Context(), bkt, fn, cw.Attrs(), meta)
+	if err != nil {
+		return err
+	}
+	st, rec.Generation = pb.FileResponse_SUCCESS, attrs.Generation
+	r.recent.put(attrs)
+	replicas := r.replicate(ctx, attrs)
+	conversion := r.convertNow(ctx, meta, attrs)
+	r.notifyStored(ctx, proj.String(), attrs)
+	r.logUpload(ctx, proj.String(), attrs)
+	r.stats.stored(proj.String(), attrs.Size)
+	return stream.SendAndClose(r.withObject(&pb.FileResponse{Status: st, Retention: retentionOf(attrs), Replicas: replicas, Conversion: conversion}, attrs))
+}
+
+// Compatibility returns the compatibility matrix of the upload protocol, and
+// whether the client protocol version is accepted by the server.
+func (r rvServer) Compatibility(ctx context.Context, req *pb.CompatibilityRequest) (*pb.CompatibilityResponse, error) {
+	return version.Matrix(req.GetClientVersion(), r.minClientVersion)
 }
 
 func readConfigFile(path string) (*config, error) {
@@ -179,12 +942,91 @@ func readConfigFile(path string) (*config, error) {
 }
 
 type config struct {
+	// Buckets maps a project, ie: ROUTEVIEWS, to the bucket its files are
+	// stored in.
 	Buckets map[string]string
+	// Routes maps a project to the bucket and object prefix its files are
+	// stored under, a route takes precedence over the bucket of the project.
+	Routes map[string]*route
+	// Default is the route of the projects without a route or a bucket, their
+	// files are stored under <prefix><project>/. Without it they are rejected.
+	Default *route
+	// Callers maps a caller, the email or subject of its ID token, to the
+	// projects it may upload to. Without callers every caller may upload to
+	// every project.
+	Callers map[string][]string
+	// Admins maps a caller to the projects it may delete the objects of, or
+	// replace with allow_overwrite. Without admins no caller may.
+	Admins map[string][]string
+	// Quotas limit the requests and bytes of each caller to each project.
+	// Without quotas callers are not limited.
+	Quotas *quotas
+	// KMSKeys maps a project to the Cloud KMS key its objects are encrypted
+	// with, ie: projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>.
+	KMSKeys map[string]string `yaml:"kms_keys"`
+	// Retention is the retention of the objects of each project.
+	Retention map[string]*retention
+	// StorageClasses are the storage class rules of each project, see
+	// classRule. Without rules files get the default class of the bucket.
+	StorageClasses map[string][]*classRule `yaml:"storage_classes"`
+	// FilenamePatterns maps a project to the regexps the filenames of its
+	// uploads must match one of, ie: the layouts of its archives, a single
+	// regexp or a list. Filenames are canonicalized before, see cleanFilename.
+	FilenamePatterns map[string]namePatterns `yaml:"filename_patterns"`
+	// Replicas maps a bucket to the replica buckets, ie: in another region or
+	// project, each object stored in it is copied to.
+	Replicas map[string][]string
+	// Failover maps a bucket to the bucket, ie: in another region, the
+	// objects of FileUpload are written to while the bucket is unavailable,
+	// see failOver.
+	Failover map[string]string
+	// ConvertNow are the projects whose updates archives are converted for
+	// BigQuery before the response, as if the uploads set convert_now.
+	ConvertNow []string `yaml:"convert_now"`
+	// Transcode maps a project to the patterns of the basenames, see
+	// path.Match, of its gzip compressed text files which are stored with
+	// Content-Encoding: gzip, as if the uploads set transcode.
+	Transcode map[string][]string
+	// MaxSizes maps a project to the max size, in bytes, of its files, uploads
+	// of larger files fail with InvalidArgument. Projects without one are
+	// limited by the message size limit of FileUpload only.
+	MaxSizes map[string]int64 `yaml:"max_sizes"`
+	// S3 is the S3-compatible store, ie: an on-prem MinIO, the objects of
+	// FileUpload are stored in rather than cloud-storage, nil stores them in
+	// cloud-storage. See s3Config.
+	S3 *s3Config
+	// Server maps a flag of the server, ie: tls_cert, to its value, for the
+	// flags set neither on the command line nor in the environment, see
+	// setFromConfig.
+	Server map[string]string
+}
+
+// route is the destination of the files of a project.
+type route struct {
+	Bucket string
+	Prefix string
+}
+
+// route returns the bucket and object prefix of the files of a project.
+func (c *config) route(proj string) (string, string, bool) {
+	if rt, ok := c.Routes[proj]; ok {
+		return rt.Bucket, rt.Prefix, true
+	}
+	if bkt, ok := c.Buckets[proj]; ok {
+		return bkt, "", true
+	}
+	if c.Default != nil {
+		return c.Default.Bucket, c.Default.Prefix + proj + "/", true
+	}
+	return "", "", false
 }
 
 func main() {
 	flag.Parse()
 	ctx := context.Background()
+	if err := setFromEnv(flag.CommandLine, os.LookupEnv); err != nil {
+		log.Fatalf("bad environment: %v", err)
+	}
 
 	if port == "" {
 		port = "9876"
@@ -198,28 +1040,163 @@ func main() {
 		log.Fatalf("failed to listen(): %v", err)
 	}
 
-	// Create a storage client, to add to the RV Server.
-	c, err := storage.NewClient(context.Background())
+	// The local store is set before the config is read, it is set on the
+	// command line or in the environment only.
+	local, err := parseStorage(*storageBackend)
 	if err != nil {
-		log.Fatalf("failed to create storage client: %v", err)
+		log.Fatalf("bad storage: %v", err)
+	}
+	// Create a storage client, to add to the RV Server. A server which stores
+	// to S3 runs without cloud-storage credentials, one which stores locally
+	// does without a client.
+	var c *storage.Client
+	if local == nil {
+		c, err = storage.NewClient(context.Background())
+		if err != nil {
+			log.Errorf("failed to create storage client: %v", err)
+			c = nil
+		}
 	}
 
-	r, err := newRVServer(ctx, *configFile, c)
+	r, err := newRVServer(ctx, *configFile, c, local)
 	if err != nil {
 		log.Fatalf("failed to create new rvServer: %v", err)
 	}
+	if err := setFromConfig(flag.CommandLine, r.conf.Server); err != nil {
+		log.Fatalf("bad config: %v", err)
+	}
+	if *minClientVersion != "" {
+		if _, err := version.Compare(*minClientVersion, version.Protocol); err != nil {
+			log.Fatalf("bad min_client_version: %v", err)
+		}
+		r.minClientVersion = *minClientVersion
+	}
+	r.audience = *audience
+	if *kmsKey != "" {
+		if err := checkKMSKey(*kmsKey); err != nil {
+			log.Fatalf("bad kms_key: %v", err)
+		}
+		r.kms = *kmsKey
+	}
+	if *signedURLTTL < 0 || *signedURLTTL > 7*24*time.Hour {
+		log.Fatalf("bad signed_url_ttl(%v): must be from 0 to 7 days", *signedURLTTL)
+	}
+	r.signTTL, r.signer = *signedURLTTL, *signerEmail
+	r.validateMRT = *validateMRT
+	r.quarantine = *quarantineRejected
+	r.shed = newShedder(*shedLatency, *shedErrorRate)
+	r.partSize, r.partParallelism = *compositePartBytes, *compositeParallelism
+	if (r.s3 != nil || r.local != nil) && (r.quarantine || r.kms != "" || *convertBucket != "") {
+		log.Fatalf("bad flags: quarantine, kms_key and convert_bucket are not supported by the %s backend", r.backend())
+	}
+	if *notifyTopic != "" {
+		r.publish, err = newPublisher(ctx, *notifyTopic)
+		if err != nil {
+			log.Fatalf("bad notify_topic: %v", err)
+		}
+	}
+	if *uploadLogTable != "" {
+		r.uploadLog, err = newUploadLog(ctx, *uploadLogTable)
+		if err != nil {
+			log.Fatalf("bad upload_log_table: %v", err)
+		}
+	}
+	if *requestTimeout < 0 {
+		log.Fatalf("bad request_timeout(%v): must not be negative", *requestTimeout)
+	}
+	if *convertBucket != "" {
+		r.convert = newConverter(c, *convertBucket)
+	}
+	if *auditLogs {
+		r.audit = newAuditLog(os.Stdout)
+	}
+
+	if *traceProject != "" {
+		flushTraces, err := tracing.Init(ctx, *traceProject, "rv-server", *traceRatio)
+		if err != nil {
+			log.Fatalf("failed to set up tracing: %v", err)
+		}
+		defer flushTraces(ctx)
+	}
+
+	tune := tuning{
+		maxMsgBytes: *maxMsgBytes,
+		maxStreams:  uint32(*maxStreams),
+		keepalive: keepalive.ServerParameters{
+			Time:                  *keepaliveTime,
+			Timeout:               *keepaliveTimeout,
+			MaxConnectionIdle:     *maxConnIdle,
+			MaxConnectionAge:      *maxConnAge,
+			MaxConnectionAgeGrace: *maxConnAgeGrace,
+		},
+		enforcement: keepalive.EnforcementPolicy{
+			MinTime:             *keepaliveMinTime,
+			PermitWithoutStream: *keepaliveWithoutStream,
+		},
+	}
+	if err := tune.check(); err != nil {
+		log.Fatalf("bad server tuning: %v", err)
+	}
+	r.maxMsgBytes = tune.maxMsgBytes
 
-	s := grpc.NewServer(
-		grpc.MaxMsgSize(maxMsgSize),
-		grpc.MaxRecvMsgSize(maxMsgSize),
-		grpc.MaxSendMsgSize(maxMsgSize),
+	// Calls are logged with the Internal status of a recovered panic.
+	unary := []grpc.UnaryServerInterceptor{
+		callLogUnaryInterceptor(log.Infof),
+		recoverUnaryInterceptor(),
+		otelgrpc.UnaryServerInterceptor(),
+		version.UnaryServerInterceptor(r.minClientVersion, log.Infof),
+		deadlineUnaryInterceptor(*requestTimeout),
+	}
+	opts := append(tune.options(),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(
+			callLogStreamInterceptor(log.Infof),
+			recoverStreamInterceptor(),
+			otelgrpc.StreamServerInterceptor(),
+			version.StreamServerInterceptor(r.minClientVersion, log.Infof),
+			deadlineStreamInterceptor(*requestTimeout),
+		),
 	)
+	var tc *tls.Config
+	if *tlsCert != "" || *tlsKey != "" || *clientCA != "" {
+		tc, err = serverTLS(*tlsCert, *tlsKey, *clientCA)
+		if err != nil {
+			log.Fatalf("bad TLS config: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tc)))
+		log.Infof("Serving TLS, mutual TLS required: %v", *clientCA != "")
+	}
+	s := grpc.NewServer(opts...)
 	pb.RegisterRVServer(s, r)
 
+	// Register the health service, it reports whether storage can be reached.
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(s, hs)
+	go r.watchHealth(ctx, hs, healthInterval)
+	go r.watchReplicas(ctx, replicaInterval)
+	go r.reloadOnSignal(ctx, *configFile, c)
+
 	// Register the reflection service on gRPC server.
 	reflection.Register(s)
+
+	// The gateway serves FileUpload over HTTP, through the interceptors of
+	// the gRPC server.
+	stop := stoppers{s}
+	if *httpPort != "" {
+		glis, err := net.Listen("tcp", ":"+*httpPort)
+		if err != nil {
+			log.Fatalf("failed to listen() on http_port: %v", err)
+		}
+		hsrv := &http.Server{Handler: newGateway(r, r.msgLimit(), unary...)}
+		stop = append(stop, gatewayStopper{hsrv})
+		go serveGateway(hsrv, glis, tc)
+		log.Infof("Serving the REST gateway on port : %s", *httpPort)
+	}
+
+	// Serve returns once the servers are drained.
+	go drainOnSignal(stop, hs, *drainTimeout)
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to listen&&serve: %v", err)
 	}
-
+	log.Infof("Server stopped")
 }
@@ -1,14 +1,39 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	"cloud.google.com/go/storage"
 	"github.com/fsouza/fake-gcs-server/fakestorage"
 	"github.com/google/go-cmp/cmp"
 	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
 	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/testing/protocmp"
 	"gopkg.in/yaml.v2"
 )
@@ -37,6 +62,289 @@ func tempFile(t *testing.T, fn string, data []byte) string {
 	return file.Name()
 }
 
+// storedObject returns a fake object of content, with the md5 and crc32c
+// checksums cloud-storage records for an upload.
+func storedObject(bucket, name string, content []byte) fakestorage.Object {
+	sum := md5.Sum(content)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli)))
+	return fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName: bucket,
+			Name:       name,
+			Md5Hash:    base64.StdEncoding.EncodeToString(sum[:]),
+			Crc32c:     base64.StdEncoding.EncodeToString(crc),
+		},
+		Content: content,
+	}
+}
+
+// gcsTransport is the transport of a client of a fake GCS server, which
+// honours the preconditions of the writes and copies as cloud-storage does.
+// The fake fails the uploads on a generation other than 0 with a 501, and
+// copies to buckets which do not exist, regardless of the preconditions of
+// the copy, answering without the generation of the copy. The metadata updates
+// are recorded to events, if set.
+type gcsTransport struct {
+	srv    *fakestorage.Server
+	base   http.RoundTripper
+	events *gcsEvents
+}
+
+func (t gcsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// /upload/storage/v1/b/<bucket>/o
+	// /storage/v1/b/<bucket>/o/<object>/rewriteTo/b/<bucket>/o/<object>
+	// /storage/v1/b/<bucket>/o/<object>
+	parts := strings.Split(req.URL.EscapedPath(), "/")
+	switch {
+	case req.Method == http.MethodPost && len(parts) == 7 && parts[1] == "upload":
+		return t.upload(req, parts[5])
+	case req.Method == http.MethodPost && len(parts) == 12 && parts[7] == "rewriteTo":
+		return t.rewrite(req, parts[9], parts[11])
+	case req.Method == http.MethodPatch && len(parts) == 7 && parts[5] == "o" && t.events != nil:
+		return t.patch(req, parts[4], parts[6])
+	}
+	return t.base.RoundTrip(req)
+}
+
+// patch updates the metadata of an object, and records the update.
+func (t gcsTransport) patch(req *http.Request, escBkt, escObj string) (*http.Response, error) {
+	bkt, _ := url.PathUnescape(escBkt)
+	obj, _ := url.PathUnescape(escObj)
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var attrs struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &attrs); err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		t.events.metadataUpdate(bkt, obj, attrs.Metadata)
+	}
+	return resp, err
+}
+
+// met reports whether an object meets the ifGenerationMatch precondition of a
+// request, if any.
+func (t gcsTransport) met(req *http.Request, bkt, obj string) bool {
+	m := req.URL.Query().Get("ifGenerationMatch")
+	if m == "" {
+		return true
+	}
+	var gen int64
+	if existing, err := t.srv.GetObject(bkt, obj); err == nil {
+		gen = existing.Generation
+	}
+	return m == strconv.FormatInt(gen, 10)
+}
+
+// upload checks the precondition of an upload, and uploads the object
+// without it.
+func (t gcsTransport) upload(req *http.Request, escBkt string) (*http.Response, error) {
+	bkt, _ := url.PathUnescape(escBkt)
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// The name of the object is in the metadata, the first part of a
+	// multipart upload or the body of a resumable one.
+	meta := io.Reader(bytes.NewReader(body))
+	if mt, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && strings.HasPrefix(mt, "multipart/") {
+		if meta, err = multipart.NewReader(bytes.NewReader(body), params["boundary"]).NextPart(); err != nil {
+			return nil, err
+		}
+	}
+	var attrs struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(meta).Decode(&attrs); err != nil {
+		return nil, err
+	}
+	if !t.met(req, bkt, attrs.Name) {
+		return fakeGCSError(req, http.StatusPreconditionFailed, "Precondition failed"), nil
+	}
+	unconditional := req.Clone(req.Context())
+	q := unconditional.URL.Query()
+	if q.Get("ifGenerationMatch") != "0" {
+		q.Del("ifGenerationMatch")
+	}
+	unconditional.URL.RawQuery = q.Encode()
+	unconditional.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return t.base.RoundTrip(unconditional)
+}
+
+// rewrite checks the destination bucket and the precondition of a copy, and
+// answers with the generation of the copy.
+func (t gcsTransport) rewrite(req *http.Request, escBkt, escObj string) (*http.Response, error) {
+	bkt, _ := url.PathUnescape(escBkt)
+	obj, _ := url.PathUnescape(escObj)
+	if _, _, err := t.srv.ListObjectsWithOptions(bkt, fakestorage.ListOptions{}); err != nil {
+		return fakeGCSError(req, http.StatusNotFound, fmt.Sprintf("bucket %s not found", bkt)), nil
+	}
+	if !t.met(req, bkt, obj) {
+		return fakeGCSError(req, http.StatusPreconditionFailed, "Precondition failed"), nil
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	var rewrite map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&rewrite); err != nil {
+		return nil, err
+	}
+	copied, err := t.srv.GetObject(bkt, obj)
+	if err != nil {
+		return nil, err
+	}
+	rewrite["resource"].(map[string]interface{})["generation"] = strconv.FormatInt(copied.Generation, 10)
+	b, err := json.Marshal(rewrite)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+	resp.ContentLength = int64(len(b))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// fakeGCSError returns the response of a failed request to cloud-storage.
+func fakeGCSError(req *http.Request, code int, msg string) *http.Response {
+	b, _ := json.Marshal(map[string]interface{}{"error": map[string]interface{}{"code": code, "message": msg}})
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}
+}
+
+// gcsEvents are the notifications cloud-storage publishes of the metadata
+// updates of the objects, the OBJECT_METADATA_UPDATE events which trigger the
+// conversion of an archive, see cmd/converter.
+type gcsEvents struct {
+	mu      sync.Mutex
+	updates map[string][]map[string]string
+}
+
+func (e *gcsEvents) metadataUpdate(bkt, obj string, md map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.updates == nil {
+		e.updates = map[string][]map[string]string{}
+	}
+	e.updates[bkt+"/"+obj] = append(e.updates[bkt+"/"+obj], md)
+}
+
+// converts reports whether a metadata update of an object set its project
+// source, for the converter to convert it.
+func (e *gcsEvents) converts(bkt, obj string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, md := range e.updates[bkt+"/"+obj] {
+		if md[converter.ProjectMetadataKey] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// gcsClient returns a client of a fake GCS server, which honours the
+// preconditions as cloud-storage does, see gcsTransport.
+func gcsClient(t *testing.T, srv *fakestorage.Server) *storage.Client {
+	t.Helper()
+	c, _ := gcsEventsClient(t, srv)
+	return c
+}
+
+// gcsEventsClient returns a client of a fake GCS server as gcsClient, and the
+// events of the metadata updates of its objects.
+func gcsEventsClient(t *testing.T, srv *fakestorage.Server) (*storage.Client, *gcsEvents) {
+	t.Helper()
+	events := &gcsEvents{}
+	hc := &http.Client{Transport: gcsTransport{srv: srv, base: srv.HTTPClient().Transport, events: events}}
+	c, err := storage.NewClient(context.Background(), option.WithHTTPClient(hc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, events
+}
+
+// gzipped returns the gzip encoding of b.
+func gzipped(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeContent(t *testing.T) {
+	content := []byte("Foo Bar Baz")
+	tests := []struct {
+		desc    string
+		enc     string
+		b       []byte
+		limit   int
+		wantErr bool
+	}{{
+		desc: "plain content",
+		b:    content,
+	}, {
+		desc: "gzip content",
+		enc:  "gzip",
+		b:    gzipped(content),
+	}, {
+		desc:    "corrupt gzip content",
+		enc:     "gzip",
+		b:       content,
+		wantErr: true,
+	}, {
+		desc:    "gzip content beyond the limit",
+		enc:     "gzip",
+		b:       gzipped(content),
+		limit:   5,
+		wantErr: true,
+	}, {
+		desc:    "unsupported encoding",
+		enc:     "br",
+		b:       content,
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			limit := test.limit
+			if limit == 0 {
+				limit = maxMsgSize
+			}
+			got, err := decodeContent(test.enc, test.b, limit)
+			switch {
+			case err != nil && !test.wantErr:
+				t.Fatalf("decodeContent() = %v; want nil err", err)
+			case err == nil && test.wantErr:
+				t.Fatal("decodeContent(): nil err; want non-nil err")
+			case err != nil:
+				return
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("decodeContent() = %q; want %q", got, content)
+			}
+		})
+	}
+}
+
 // TestFileUpload tests a full file-upload process request.
 func TestFileUpload(t *testing.T) {
 	tests := []struct {
@@ -76,6 +384,9 @@ func TestFileUpload(t *testing.T) {
 		want: &pb.FileResponse{
 			Status:       pb.FileResponse_SUCCESS,
 			ErrorMessage: "",
+			Object:       "gs://foo/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
 		},
 	}, {
 		desc: "Routeviews: Success",
@@ -94,6 +405,9 @@ func TestFileUpload(t *testing.T) {
 		want: &pb.FileResponse{
 			Status:       pb.FileResponse_SUCCESS,
 			ErrorMessage: "",
+			Object:       "gs://foo/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
 		},
 	}, {
 		desc: "Routeviews: sent to RV RIB bucket",
@@ -112,6 +426,118 @@ func TestFileUpload(t *testing.T) {
 		want: &pb.FileResponse{
 			Status:       pb.FileResponse_SUCCESS,
 			ErrorMessage: "",
+			Object:       "gs://baz/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
+		},
+	}, {
+		desc: "Routeviews: Success with crc32c",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename: "bar",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:   "3863cc2f",
+			Content:  []byte("Foo Bar Baz"),
+			Project:  pb.FileRequest_ROUTEVIEWS,
+		},
+		want: &pb.FileResponse{
+			Status:       pb.FileResponse_SUCCESS,
+			ErrorMessage: "",
+			Object:       "gs://foo/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
+		},
+	}, {
+		desc: "Routeviews: Failure - bad crc32c",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename: "bar",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:   "00000000",
+			Content:  []byte("Foo Bar Baz"),
+			Project:  pb.FileRequest_ROUTEVIEWS,
+		},
+		wantErr: true,
+	}, {
+		desc: "Routeviews: Failure - invalid crc32c",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename: "bar",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:   "3863cc2f00",
+			Content:  []byte("Foo Bar Baz"),
+			Project:  pb.FileRequest_ROUTEVIEWS,
+		},
+		wantErr: true,
+	}, {
+		desc: "Routeviews: Success with sha256",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename: "bar",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Sha256:   "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1",
+			Content:  []byte("Foo Bar Baz"),
+			Project:  pb.FileRequest_ROUTEVIEWS,
+		},
+		want: &pb.FileResponse{
+			Status:       pb.FileResponse_SUCCESS,
+			ErrorMessage: "",
+			Object:       "gs://foo/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
+			Sha256:       "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1",
+		},
+	}, {
+		desc: "Routeviews: Failure - bad sha256",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename: "bar",
+			Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+			Sha256:   "0000da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1",
+			Content:  []byte("Foo Bar Baz"),
+			Project:  pb.FileRequest_ROUTEVIEWS,
+		},
+		wantErr: true,
+	}, {
+		desc: "Routeviews: Success with gzip content",
+		conf: &config{
+			Buckets: map[string]string{
+				pb.FileRequest_ROUTEVIEWS.String(): "foo",
+			},
+		},
+		req: &pb.FileRequest{
+			Filename:        "bar",
+			Md5Sum:          "50e3903156f5d2dac6c9f89626d48c75",
+			Content:         gzipped([]byte("Foo Bar Baz")),
+			ContentEncoding: "gzip",
+			Project:         pb.FileRequest_ROUTEVIEWS,
+		},
+		want: &pb.FileResponse{
+			Status:       pb.FileResponse_SUCCESS,
+			ErrorMessage: "",
+			Object:       "gs://foo/bar",
+			Md5Sum:       "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:       "3863cc2f",
 		},
 	}}
 
@@ -122,7 +548,7 @@ func TestFileUpload(t *testing.T) {
 		srv.CreateBucket("baz")
 		srv.CreateBucket("foo")
 
-		fs, err := newRVServer(context.Background(), createConf(t, test.conf), cli)
+		fs, err := newRVServer(context.Background(), createConf(t, test.conf), cli, nil)
 		if err != nil {
 			t.Fatalf("[%v]: failed initialzing server: %v", test.desc, err)
 		}
@@ -133,23 +559,291 @@ func TestFileUpload(t *testing.T) {
 		case err == nil && test.wantErr:
 			t.Errorf("[%v]: did not get error when expoecting one", test.desc)
 		case err == nil:
-			if diff := cmp.Diff(got, test.want, protocmp.Transform()); diff != "" {
+			// The generation is of the stored object, checked below.
+			if diff := cmp.Diff(got, test.want, protocmp.Transform(), protocmp.IgnoreFields(&pb.FileResponse{}, "generation")); diff != "" {
 				t.Errorf("[%v] got/want mismatch:\n%v\n", test.desc, diff)
 			}
 		}
 
 		// Check validity of uploaded files.
 		if test.wantErr {
-			return
+			continue
 		}
 		wantBkt := test.conf.Buckets[test.req.GetProject().String()]
 		obj, err := srv.GetObject(wantBkt, test.req.Filename)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if got.GetGeneration() != obj.Generation {
+			t.Errorf("[%v]: got generation %d; want %d of the stored object", test.desc, got.GetGeneration(), obj.Generation)
+		}
 		if gotProj := obj.ObjectAttrs.Metadata[converter.ProjectMetadataKey]; gotProj != test.req.Project.String() {
 			t.Errorf("got metadata %s=%s; want %s", converter.ProjectMetadataKey, gotProj, test.req.Project.String())
 		}
+		if gotSum := obj.ObjectAttrs.Metadata[uploadutils.SHA256MetadataKey]; gotSum != test.req.Sha256 {
+			t.Errorf("got metadata %s=%s; want %s", uploadutils.SHA256MetadataKey, gotSum, test.req.Sha256)
+		}
+	}
+}
+
+func TestFileUploadUnchanged(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+	fs, err := newRVServer(ctx, createConf(t, conf), gcsClient(t, srv), nil)
+	if err != nil {
+		t.Fatalf("failed initialzing server: %v", err)
+	}
+	req := &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Content:  []byte("Foo Bar Baz"),
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}
+	if got, err := fs.FileUpload(ctx, req); err != nil || got.GetStatus() != pb.FileResponse_SUCCESS {
+		t.Fatalf("FileUpload() = %v, %v; want SUCCESS", got, err)
+	}
+	obj, err := srv.GetObject("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The resent content is not written again.
+	if got, err := fs.FileUpload(ctx, req); err != nil || got.GetStatus() != pb.FileResponse_UNCHANGED {
+		t.Errorf("FileUpload(resent) = %v, %v; want UNCHANGED", got, err)
+	}
+	again, err := srv.GetObject("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Generation != obj.Generation {
+		t.Errorf("generation = %d after an unchanged upload; want %d", again.Generation, obj.Generation)
+	}
+
+	// Changed content is refused, the archived object is kept.
+	req.Content = []byte("Foo Bar Qux")
+	sum := md5.Sum(req.Content)
+	req.Md5Sum = hex.EncodeToString(sum[:])
+	if got, err := fs.FileUpload(ctx, req); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("FileUpload(changed) = %v, %v; want FailedPrecondition", got, err)
+	}
+	// An overwrite is for the admins of the project.
+	req.AllowOverwrite = true
+	fs.validate = fakeValidate
+	actx := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer robot"))
+	if got, err := fs.FileUpload(actx, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("FileUpload(changed, allow_overwrite) = %v, %v; want PermissionDenied", got, err)
+	}
+	fs.conf.Admins = map[string][]string{"5678": {pb.FileRequest_ROUTEVIEWS.String()}}
+	if got, err := fs.FileUpload(actx, req); err != nil || got.GetStatus() != pb.FileResponse_SUCCESS {
+		t.Errorf("FileUpload(changed, allow_overwrite) by an admin = %v, %v; want SUCCESS", got, err)
+	}
+}
+
+// uploadStream is a FileUploadStream server stream which receives chunks.
+type uploadStream struct {
+	grpc.ServerStream
+	chunks []*pb.FileChunk
+	resp   *pb.FileResponse
+}
+
+func (s *uploadStream) Context() context.Context { return context.Background() }
+
+func (s *uploadStream) Recv() (*pb.FileChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return c, nil
+}
+
+func (s *uploadStream) SendAndClose(resp *pb.FileResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestFileUploadStream(t *testing.T) {
+	meta := func(md5sum string, proj pb.FileRequest_Project) *pb.FileChunk {
+		return &pb.FileChunk{Metadata: &pb.FileRequest{Filename: "bar", Md5Sum: md5sum, Project: proj}}
+	}
+	content := func(s string) *pb.FileChunk { return &pb.FileChunk{Content: []byte(s)} }
+
+	tests := []struct {
+		desc    string
+		chunks  []*pb.FileChunk
+		wantErr bool
+	}{{
+		desc:   "Success",
+		chunks: []*pb.FileChunk{meta("50e3903156f5d2dac6c9f89626d48c75", pb.FileRequest_ROUTEVIEWS), content("Foo "), content("Bar "), content("Baz")},
+	}, {
+		desc:    "Failure - bad checksum",
+		chunks:  []*pb.FileChunk{meta("50e3903156f5d2dac6c9f89626d48c76", pb.FileRequest_ROUTEVIEWS), content("Foo "), content("Bar "), content("Baz")},
+		wantErr: true,
+	}, {
+		desc:    "Failure - no metadata",
+		chunks:  []*pb.FileChunk{content("Foo Bar Baz")},
+		wantErr: true,
+	}, {
+		desc:    "Failure - unsupported project",
+		chunks:  []*pb.FileChunk{meta("50e3903156f5d2dac6c9f89626d48c75", pb.FileRequest_PCH), content("Foo Bar Baz")},
+		wantErr: true,
+	}, {
+		desc:    "Failure - no content",
+		chunks:  []*pb.FileChunk{meta("d41d8cd98f00b204e9800998ecf8427e", pb.FileRequest_ROUTEVIEWS)},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			srv := fakestorage.NewServer(nil)
+			defer srv.Stop()
+			srv.CreateBucket("foo")
+			conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+			cli, events := gcsEventsClient(t, srv)
+			fs, err := newRVServer(context.Background(), createConf(t, conf), cli, nil)
+			if err != nil {
+				t.Fatalf("failed initialzing server: %v", err)
+			}
+
+			stream := &uploadStream{chunks: test.chunks}
+			err = fs.FileUploadStream(stream)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FileUploadStream() = %v; want err %v", err, test.wantErr)
+			}
+			obj, objErr := srv.GetObject("foo", "bar")
+			if test.wantErr {
+				if objErr == nil {
+					t.Error("object stored by a failed stream; want no object")
+				}
+				return
+			}
+			if got := stream.resp.GetStatus(); got != pb.FileResponse_SUCCESS {
+				t.Errorf("FileUploadStream() status = %v; want SUCCESS", got)
+			}
+			if objErr != nil {
+				t.Fatal(objErr)
+			}
+			if got := string(obj.Content); got != "Foo Bar Baz" {
+				t.Errorf("stored content = %q; want %q", got, "Foo Bar Baz")
+			}
+			if gotProj := obj.ObjectAttrs.Metadata[converter.ProjectMetadataKey]; gotProj != pb.FileRequest_ROUTEVIEWS.String() {
+				t.Errorf("got metadata %s=%s; want %s", converter.ProjectMetadataKey, gotProj, pb.FileRequest_ROUTEVIEWS.String())
+			}
+			if !events.converts("foo", "bar") {
+				t.Error("FileUploadStream() updated no metadata of the object; want the update which triggers its conversion")
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	conf := &config{
+		Buckets: map[string]string{
+			"ROUTEVIEWS": "routeviews-archives",
+			"RPKI_RARC":  "routeviews-archives",
+		},
+		Routes: map[string]*route{
+			"RPKI_RARC": {Bucket: "rpki-archives", Prefix: "rarc/"},
+		},
+	}
+	tests := []struct {
+		desc       string
+		dflt       *route
+		proj       string
+		wantBucket string
+		wantPrefix string
+		wantOK     bool
+	}{{
+		desc:       "bucket",
+		proj:       "ROUTEVIEWS",
+		wantBucket: "routeviews-archives",
+		wantOK:     true,
+	}, {
+		desc:       "route overrides bucket",
+		proj:       "RPKI_RARC",
+		wantBucket: "rpki-archives",
+		wantPrefix: "rarc/",
+		wantOK:     true,
+	}, {
+		desc: "no route",
+		proj: "PCH",
+	}, {
+		desc:       "default route",
+		dflt:       &route{Bucket: "unrouted", Prefix: "incoming/"},
+		proj:       "PCH",
+		wantBucket: "unrouted",
+		wantPrefix: "incoming/PCH/",
+		wantOK:     true,
+	}}
+	for _, test := range tests {
+		conf.Default = test.dflt
+		bkt, prefix, ok := conf.route(test.proj)
+		if bkt != test.wantBucket || prefix != test.wantPrefix || ok != test.wantOK {
+			t.Errorf("%s: route(%s) = %s, %s, %v; want %s, %s, %v", test.desc, test.proj,
+				bkt, prefix, ok, test.wantBucket, test.wantPrefix, test.wantOK)
+		}
+	}
+}
+
+func TestObjectMeta(t *testing.T) {
+	r := rvServer{validate: fakeValidate}
+	tests := []struct {
+		desc string
+		auth string
+		req  *pb.FileRequest
+		want map[string]string
+	}{{
+		desc: "archive of a known layout",
+		auth: "Bearer mirror",
+		req: &pb.FileRequest{
+			Filename:  "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			Project:   pb.FileRequest_ROUTEVIEWS,
+			Sha256:    "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1",
+			SourceUrl: "ftp://archive.routeviews.org/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		want: map[string]string{
+			converter.ProjectMetadataKey:       pb.FileRequest_ROUTEVIEWS.String(),
+			uploadutils.SHA256MetadataKey:      "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1",
+			uploadutils.SourceURLMetadataKey:   "ftp://archive.routeviews.org/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			uploadutils.CollectorMetadataKey:   "route-views4",
+			uploadutils.CaptureTimeMetadataKey: "2022-01-09T18:30:00Z",
+			uploadutils.UploaderMetadataKey:    "mirror@example.iam.gserviceaccount.com",
+		},
+	}, {
+		desc: "other file, without a caller",
+		req: &pb.FileRequest{
+			Filename: "2022/01/09/rpki-20220109T183000Z.tgz",
+			Project:  pb.FileRequest_RPKI_RARC,
+		},
+		want: map[string]string{
+			converter.ProjectMetadataKey: pb.FileRequest_RPKI_RARC.String(),
+		},
+	}}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.auth != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", test.auth))
+		}
+		if diff := cmp.Diff(r.objectMeta(ctx, test.req), test.want); diff != "" {
+			t.Errorf("%s: objectMeta() diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	for fn, want := range map[string]string{
+		"bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2": "application/x-bzip2",
+		"rrc00/2022.01/updates.20220109.1830.gz":            "application/gzip",
+		"rpki/20220109.tar.XZ":                              "application/x-xz",
+		"notes.txt":                                         "text/plain; charset=utf-8",
+		"rib.mrt":                                           "",
+	} {
+		if got := contentType(fn); got != want {
+			t.Errorf("contentType(%s) = %q; want %q", fn, got, want)
+		}
 	}
 }
 
@@ -180,10 +874,73 @@ func TestBadConfig(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			_, err := newRVServer(context.Background(),
 				tempFile(t, "conf.yaml", test.data),
-				fakestorage.NewServer(nil).Client())
+				fakestorage.NewServer(nil).Client(), nil)
 			if err == nil {
 				t.Error("newRVServer: nil err; want non-nil err")
 			}
 		})
 	}
 }
+
+func TestCompatibility(t *testing.T) {
+	tests := []struct {
+		desc    string
+		min     string
+		client  string
+		want    bool
+		wantErr bool
+	}{
+		{
+			desc:   "no minimum",
+			client: "1.0.0",
+			want:   true,
+		},
+		{
+			desc:   "legacy client",
+			min:    "1.1.0",
+			client: "",
+			want:   false,
+		},
+		{
+			desc:   "current client",
+			min:    "1.1.0",
+			client: "1.1.0",
+			want:   true,
+		},
+		{
+			desc:    "bad client version",
+			min:     "1.1.0",
+			client:  "latest",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := rvServer{minClientVersion: test.min}
+			resp, err := r.Compatibility(context.Background(), &pb.CompatibilityRequest{ClientVersion: test.client})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Compatibility() = %v; want err %v", err, test.wantErr)
+			}
+			if got := resp.GetCompatible(); got != test.want {
+				t.Errorf("Compatibility() compatible = %v; want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &googleapi.Error{Code: http.StatusServiceUnavailable}, want: true},
+		{err: &googleapi.Error{Code: http.StatusPreconditionFailed}},
+		{err: fmt.Errorf("failed to write: %w", &googleapi.Error{Code: http.StatusPreconditionFailed})},
+		{err: &googleapi.Error{Code: http.StatusForbidden}},
+	} {
+		if got := retryable(test.err); got != test.want {
+			t.Errorf("retryable(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Resumable upload sessions are stored in the bucket of the project, so any
+// instance of the server may serve any call of a session:
+//
+//	_uploads/<id>/session                    the file metadata
+//	_uploads/<id>/chunk-<offset, 20 digits>  a chunk of the content
+//
+// Abandoned sessions are left behind, a lifecycle rule on the prefix
+// deletes them.
+const sessionPrefix = "_uploads/"
+
+// session is a resumable upload session. The id is <project>/<random hex>,
+// so the route of the session is known from the id alone.
+type session struct {
+	id     string
+	proj   string
+	bkt    string
+	bh     *storage.BucketHandle
+	prefix string
+	// objPrefix is the object prefix of the route of the project.
+	objPrefix string
+}
+
+// chunk is a stored chunk of a session.
+type chunk struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+// newSessionID returns a new, random session id of a project.
+func newSessionID(proj pb.FileRequest_Project) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return proj.String() + "/" + hex.EncodeToString(b), nil
+}
+
+// session returns the session of an id.
+func (r rvServer) session(id string) (*session, error) {
+	parts := strings.Split(id, "/")
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid session id(%q)", id)
+	}
+	bkt, objPrefix, ok := r.config().route(parts[0])
+	if !ok || pb.FileRequest_Project_value[parts[0]] == int32(pb.FileRequest_UNKNOWN) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid session id(%q), %s is not supported", id, parts[0])
+	}
+	return &session{
+		id:        id,
+		proj:      parts[0],
+		bkt:       bkt,
+		bh:        r.sc.Bucket(bkt),
+		prefix:    sessionPrefix + parts[1] + "/",
+		objPrefix: objPrefix,
+	}, nil
+}
+
+// metadata returns the file metadata of the session.
+func (s *session) metadata(ctx context.Context) (*pb.FileRequest, error) {
+	attrs, err := s.bh.Object(s.prefix + "session").Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, status.Errorf(codes.NotFound, "upload session(%s) does not exist", s.id)
+	}
+	if err != nil {
+		return nil, storageError(err, "failed to read upload session(%s)", s.id)
+	}
+	return uploadRequest(attrs.Metadata), nil
+}
+
+// uploadMeta returns the file metadata of an upload staged in the bucket,
+// the metadata of the object of a session, or of a signed upload.
+func uploadMeta(meta *pb.FileRequest) map[string]string {
+	return map[string]string{
+		"filename":        meta.GetFilename(),
+		"md5sum":          meta.GetMd5Sum(),
+		"crc32c":          meta.GetCrc32C(),
+		"sha256":          meta.GetSha256(),
+		"source_url":      meta.GetSourceUrl(),
+		"convert_sql":     strconv.FormatBool(meta.GetConvertSql()),
+		"project":         meta.GetProject().String(),
+		"allow_overwrite": strconv.FormatBool(meta.GetAllowOverwrite()),
+		"convert_now":     strconv.FormatBool(meta.GetConvertNow()),
+		"transcode":       strconv.FormatBool(meta.GetTranscode()),
+	}
+}
+
+// uploadRequest returns the file metadata of a staged upload, see uploadMeta.
+func uploadRequest(md map[string]string) *pb.FileRequest {
+	return &pb.FileRequest{
+		Filename:       md["filename"],
+		Md5Sum:         md["md5sum"],
+		Crc32C:         md["crc32c"],
+		Sha256:         md["sha256"],
+		SourceUrl:      md["source_url"],
+		ConvertSql:     md["convert_sql"] == "true",
+		Project:        pb.FileRequest_Project(pb.FileRequest_Project_value[md["project"]]),
+		AllowOverwrite: md["allow_overwrite"] == "true",
+		ConvertNow:     md["convert_now"] == "true",
+		Transcode:      md["transcode"] == "true",
+	}
+}
+
+// committed returns the contiguous chunks of the session from offset 0, and
+// the offset they end at.
+func (s *session) committed(ctx context.Context) ([]*chunk, int64, error) {
+	var chunks []*chunk
+	it := s.bh.Objects(ctx, &storage.Query{Prefix: s.prefix + "chunk-"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, 0, storageError(err, "failed to list upload session(%s)", s.id)
+		}
+		offset, err := strconv.ParseInt(strings.TrimPrefix(attrs.Name, s.prefix+"chunk-"), 10, 64)
+		if err != nil {
+			continue
+		}
+		chunks = append(chunks, &chunk{name: attrs.Name, offset: offset, size: attrs.Size})
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].offset < chunks[j].offset })
+
+	var end int64
+	for i, c := range chunks {
+		if c.offset != end {
+			return chunks[:i], end, nil
+		}
+		end += c.size
+	}
+	return chunks, end, nil
+}
+
+// StartUpload starts a resumable upload session, with the requirements of
+// FileUpload but the content, or returns the committed offset of the session
+// to resume.
+func (r rvServer) StartUpload(ctx context.Context, req *pb.StartUploadRequest) (*pb.UploadStatus, error) {
+	if err := r.needsGCS("StartUpload"); err != nil {
+		return nil, err
+	}
+	if id := req.GetSessionId(); id != "" {
+		s, err := r.session(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.authorize(ctx, s.proj); err != nil {
+			return nil, err
+		}
+		if err := r.limit(ctx, s.proj, 1, 0); err != nil {
+			return nil, err
+		}
+		if _, err := s.metadata(ctx); err != nil {
+			return nil, err
+		}
+		_, end, err := s.committed(ctx)
+		if err != nil {
+			return nil, err
+		}
+		glog.Infof("Resuming upload session(%s) at offset(%d)", id, end)
+		return &pb.UploadStatus{SessionId: id, CommittedOffset: end}, nil
+	}
+
+	meta := req.GetMetadata()
+	fn := meta.GetFilename()
+	proj := meta.GetProject()
+	sum := meta.GetMd5Sum()
+	if err := requireFields("StartUploadRequest",
+		field{"metadata.filename", len(fn) > 0},
+		field{"metadata.md5sum", len(sum) > 0},
+		field{"metadata.project", proj != pb.FileRequest_UNKNOWN}); err != nil {
+		return nil, err
+	}
+	if enc := meta.GetContentEncoding(); enc != "" {
+		return nil, badField("metadata.content_encoding", reasonInvalidField, "unsupported content_encoding(%q) of an upload session", enc)
+	}
+	fn, err := r.checkFilename(proj, fn)
+	if err != nil {
+		return nil, err
+	}
+	meta.Filename = fn
+	if _, _, ok := r.config().route(proj.String()); !ok {
+		return nil, unsupportedProject("metadata.project", proj)
+	}
+	if err := r.authorize(ctx, proj.String()); err != nil {
+		return nil, err
+	}
+	if err := r.shed.admit(); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, 0); err != nil {
+		return nil, err
+	}
+	if crc := meta.GetCrc32C(); crc != "" {
+		if _, err := parseCRC32C(crc); err != nil {
+			return nil, err
+		}
+	}
+	id, err := newSessionID(proj)
+	if err != nil {
+		return nil, err
+	}
+	s, err := r.session(id)
+	if err != nil {
+		return nil, err
+	}
+	wc := s.bh.Object(s.prefix + "session").NewWriter(ctx)
+	wc.Metadata = uploadMeta(meta)
+	if err := wc.Close(); err != nil {
+		return nil, storageError(err, "failed to start upload session of %s", fn)
+	}
+	glog.Infof("Started upload session(%s) of %s", id, fn)
+	return &pb.UploadStatus{SessionId: id}, nil
+}
+
+// UploadChunk stores a chunk at the committed offset of the session. A chunk
+// stored already, ie: resent after a lost response, is acknowledged again.
+func (r rvServer) UploadChunk(ctx context.Context, req *pb.UploadChunkRequest) (*pb.UploadStatus, error) {
+	if err := r.needsGCS("UploadChunk"); err != nil {
+		return nil, err
+	}
+	s, err := r.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, s.proj); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, s.proj, 1, int64(len(req.GetContent()))); err != nil {
+		return nil, err
+	}
+	meta, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content := req.GetContent()
+	if err := requireFields("UploadChunkRequest", field{"content", len(content) > 0}); err != nil {
+		return nil, err
+	}
+	_, end, err := s.committed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offset := req.GetOffset()
+	switch {
+	case offset < 0:
+		return nil, badField("offset", reasonInvalidField, "invalid chunk offset(%d)", offset)
+	case offset+int64(len(content)) <= end:
+		return &pb.UploadStatus{SessionId: s.id, CommittedOffset: end}, nil
+	case offset != end:
+		// The committed offset is in the ErrorInfo, the client resumes from
+		// it.
+		return nil, withReason(status.Newf(codes.FailedPrecondition,
+			"chunk offset(%d) is not the committed offset(%d) of upload session(%s)", offset, end, s.id),
+			reasonOffsetMismatch, map[string]string{"committed_offset": strconv.FormatInt(end, 10)}).Err()
+	}
+	// A chunk beyond the max size of the project is not stored.
+	if err := r.checkSize(meta.GetProject(), "content", offset+int64(len(content))); err != nil {
+		return nil, err
+	}
+
+	// Racing resends of the chunk store it once. A write which times out is
+	// aborted, the chunk is resent.
+	wctx, cancel := stageContext(ctx, storageRetryTimeout)
+	defer cancel()
+	start := time.Now()
+	wc := s.bh.Object(fmt.Sprintf("%schunk-%020d", s.prefix, offset)).If(storage.Conditions{DoesNotExist: true}).NewWriter(wctx)
+	// The chunks hold the content of the file, they are encrypted as it is.
+	wc.KMSKeyName = r.kmsKey(s.proj)
+	if _, err := wc.Write(content); err != nil {
+		wc.Close()
+		return nil, storageError(err, "failed to store chunk of upload session(%s)", s.id)
+	}
+	err = wc.Close()
+	r.shed.observe(start, err)
+	if err != nil && !preconditionFailed(err) {
+		return nil, storageError(err, "failed to store chunk of upload session(%s)", s.id)
+	}
+	return &pb.UploadStatus{SessionId: s.id, CommittedOffset: offset + int64(len(content))}, nil
+}
+
+// FinishUpload copies the chunks of the session to the file object, verified
+// against the md5sum, and the crc32c and sha256 if set, and deletes the session. The
+// object is not stored if the content does not match the checksums.
+func (r rvServer) FinishUpload(ctx context.Context, req *pb.FinishUploadRequest) (resp *pb.FileResponse, err error) {
+	if err := r.needsGCS("FinishUpload"); err != nil {
+		return nil, err
+	}
+	rec := &uploadRecord{RPC: "FinishUpload"}
+	defer func() { r.auditUpload(ctx, rec, resp.GetStatus(), err) }()
+
+	s, err := r.session(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, s.proj); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, s.proj, 1, 0); err != nil {
+		return nil, err
+	}
+	meta, err := s.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rec.Filename, rec.Project, rec.MD5 = meta.GetFilename(), meta.GetProject().String(), meta.GetMd5Sum()
+	chunks, end, err := s.committed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rec.Size = end
+	if end < 1 {
+		return nil, status.Errorf(codes.FailedPrecondition, "upload session(%s) has no content", s.id)
+	}
+	wantSum, err := hex.DecodeString(meta.GetMd5Sum())
+	if err != nil || len(wantSum) != md5.Size {
+		return nil, invalidChecksum("metadata.md5sum", "md5sum", meta.GetMd5Sum())
+	}
+
+	fn := s.objPrefix + meta.GetFilename()
+	rec.Object = "gs://" + s.bkt + "/" + fn
+	existing, ok, err := r.checkOverwrite(ctx, s.bkt, fn, meta, rec)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		rec.Generation = existing.Generation
+		s.delete(ctx, chunks)
+		return r.withObject(&pb.FileResponse{Status: pb.FileResponse_UNCHANGED, Retention: retentionOf(existing)}, existing), nil
+	}
+
+	// Cancelling the context, or its expiry before the deadline of the
+	// request, aborts the write, the object is not stored.
+	wctx, cancel := stageContext(ctx, 0)
+	defer cancel()
+	// The span covers the copy of the chunks, ended early by a failure.
+	wctx, span := tracer.Start(wctx, "gcs.write", objectAttrs(s.bkt, fn), trace.WithAttributes(attribute.Int64("bytes", end)))
+	defer span.End()
+	wc := s.bh.Object(fn).If(writeConditions(existing)).NewWriter(wctx)
+	// cloud-storage verifies the content against the md5sum as well.
+	wc.MD5 = wantSum
+	wc.ContentType, wc.ContentEncoding = r.contentHeaders(meta, fn)
+	wc.StorageClass = r.config().storageClass(meta.GetProject(), meta.GetFilename(), time.Now())
+	wc.KMSKeyName = r.kmsKey(s.proj)
+	r.setHolds(&wc.ObjectAttrs, s.proj)
+	if err := setCRC32C(wc, meta.GetCrc32C()); err != nil {
+		return nil, err
+	}
+	h := md5.New()
+	crc, _ := uploadutils.NewHash(uploadutils.CRC32C)
+	sha, _ := uploadutils.NewHash(uploadutils.SHA256)
+	// The content is quarantined as it is copied, it is kept if rejected.
+	q := r.newQuarantine(wctx, s.bkt, fn, meta)
+	defer q.discard()
+	ws := []io.Writer{wc, h, crc, sha, q}
+	var check *mrtCheck
+	if r.checksMRT(meta.GetProject(), meta.GetFilename()) {
+		check = newMRTCheck(ctx, meta.GetFilename())
+		defer check.abort()
+		ws = append(ws, check)
+	}
+	w := io.MultiWriter(ws...)
+	for _, c := range chunks {
+		rc, err := s.bh.Object(c.name).NewReader(ctx)
+		if err != nil {
+			return nil, storageError(err, "failed to read chunk of upload session(%s)", s.id)
+		}
+		_, err = io.Copy(w, rc)
+		rc.Close()
+		if err != nil {
+			return nil, storageError(err, "failed copying content to destination: %s/%s", s.bkt, fn)
+		}
+	}
+	if err := checkHashes(meta, h, crc, sha); err != nil {
+		q.keep(ctx, rec.RPC, meta, err)
+		return nil, err
+	}
+	// Invalid content is not committed, the write is aborted on return, and
+	// the session is deleted as its content will not change.
+	if check != nil {
+		if err := check.result(); err != nil {
+			invalid, ok := invalidFormat(err)
+			if !ok {
+				return nil, err
+			}
+			q.keep(ctx, rec.RPC, meta, err)
+			s.delete(ctx, chunks)
+			return invalid, nil
+		}
+	}
+	start := time.Now()
+	err = wc.Close()
+	r.shed.observe(start, err)
+	endSpan(span, err)
+	if preconditionFailed(err) {
+		return nil, writtenMeanwhile(meta.GetFilename(), err)
+	}
+	if err != nil {
+		return nil, storageError(err, "failed storing object: %s/%s", s.bkt, fn)
+	}
+	// The metadata is set once the object is stored, as by FileUpload.
+	attrs, err := r.setStoredMeta(ctx, s.bkt, fn, wc.Attrs(), meta)
+	if err != nil {
+		return nil, err
+	}
+	rec.Generation = attrs.Generation
+	r.recent.put(attrs)
+	s.delete(ctx, chunks)
+	replicas := r.replicate(ctx, attrs)
+	conversion := r.convertNow(ctx, meta, attrs)
+	r.notifyStored(ctx, s.proj, attrs)
+	r.logUpload(ctx, s.proj, attrs)
+	r.stats.stored(s.proj, attrs.Size)
+	return r.withObject(&pb.FileResponse{Status: pb.FileResponse_SUCCESS, Retention: retentionOf(attrs), Replicas: replicas, Conversion: conversion}, attrs), nil
+}
+
+// delete deletes the chunks and the session object of a finished session.
+func (s *session) delete(ctx context.Context, chunks []*chunk) {
+	for _, c := range chunks {
+		if err := s.bh.Object(c.name).Delete(ctx); err != nil {
+			glog.Errorf("failed to delete chunk(%s) of upload session(%s): %v", c.name, s.id, err)
+		}
+	}
+	if err := s.bh.Object(s.prefix + "session").Delete(ctx); err != nil {
+		glog.Errorf("failed to delete upload session(%s): %v", s.id, err)
+	}
+}
+
+// preconditionFailed reports whether a GCS request failed its preconditions.
+func preconditionFailed(err error) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUploadSession(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+	cli, events := gcsEventsClient(t, srv)
+	fs, err := newRVServer(ctx, createConf(t, conf), cli, nil)
+	if err != nil {
+		t.Fatalf("failed initialzing server: %v", err)
+	}
+
+	st, err := fs.StartUpload(ctx, &pb.StartUploadRequest{Metadata: &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}})
+	if err != nil {
+		t.Fatalf("StartUpload() = %v; want nil err", err)
+	}
+	id := st.GetSessionId()
+
+	chunk := func(offset int64, content string) (*pb.UploadStatus, error) {
+		return fs.UploadChunk(ctx, &pb.UploadChunkRequest{SessionId: id, Offset: offset, Content: []byte(content)})
+	}
+	if st, err := chunk(0, "Foo "); err != nil || st.GetCommittedOffset() != 4 {
+		t.Fatalf("UploadChunk(0) = %v, %v; want committed offset 4", st, err)
+	}
+	// A resent chunk is acknowledged, a chunk past the committed offset is refused.
+	if st, err := chunk(0, "Foo "); err != nil || st.GetCommittedOffset() != 4 {
+		t.Errorf("UploadChunk(0) resent = %v, %v; want committed offset 4", st, err)
+	}
+	if _, err := chunk(8, "Baz"); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UploadChunk(8) = %v; want FailedPrecondition", err)
+	}
+
+	// The client lost connectivity, and resumes the session.
+	st, err = fs.StartUpload(ctx, &pb.StartUploadRequest{SessionId: id})
+	if err != nil || st.GetCommittedOffset() != 4 {
+		t.Fatalf("StartUpload(resume) = %v, %v; want committed offset 4", st, err)
+	}
+	if _, err := chunk(4, "Bar "); err != nil {
+		t.Fatalf("UploadChunk(4) = %v; want nil err", err)
+	}
+	if _, err := chunk(8, "Baz"); err != nil {
+		t.Fatalf("UploadChunk(8) = %v; want nil err", err)
+	}
+
+	resp, err := fs.FinishUpload(ctx, &pb.FinishUploadRequest{SessionId: id})
+	if err != nil || resp.GetStatus() != pb.FileResponse_SUCCESS {
+		t.Fatalf("FinishUpload() = %v, %v; want SUCCESS", resp, err)
+	}
+	obj, err := srv.GetObject("foo", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(obj.Content); got != "Foo Bar Baz" {
+		t.Errorf("stored content = %q; want %q", got, "Foo Bar Baz")
+	}
+	if !events.converts("foo", "bar") {
+		t.Error("FinishUpload() updated no metadata of the object; want the update which triggers its conversion")
+	}
+	// The session is deleted.
+	if _, err := fs.StartUpload(ctx, &pb.StartUploadRequest{SessionId: id}); status.Code(err) != codes.NotFound {
+		t.Errorf("StartUpload(finished session) = %v; want NotFound", err)
+	}
+}
+
+func TestUploadSessionChecksum(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	conf := &config{Buckets: map[string]string{pb.FileRequest_ROUTEVIEWS.String(): "foo"}}
+	fs, err := newRVServer(ctx, createConf(t, conf), srv.Client(), nil)
+	if err != nil {
+		t.Fatalf("failed initialzing server: %v", err)
+	}
+
+	st, err := fs.StartUpload(ctx, &pb.StartUploadRequest{Metadata: &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c76",
+		Project:  pb.FileRequest_ROUTEVIEWS,
+	}})
+	if err != nil {
+		t.Fatalf("StartUpload() = %v; want nil err", err)
+	}
+	if _, err := fs.UploadChunk(ctx, &pb.UploadChunkRequest{SessionId: st.GetSessionId(), Content: []byte("Foo Bar Baz")}); err != nil {
+		t.Fatalf("UploadChunk() = %v; want nil err", err)
+	}
+	if _, err := fs.FinishUpload(ctx, &pb.FinishUploadRequest{SessionId: st.GetSessionId()}); err == nil {
+		t.Error("FinishUpload() with a bad checksum: nil err; want non-nil err")
+	}
+	if _, err := srv.GetObject("foo", "bar"); err == nil {
+		t.Error("object stored with a bad checksum; want no object")
+	}
+
+	if _, err := fs.StartUpload(ctx, &pb.StartUploadRequest{SessionId: "ROUTEVIEWS/missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("StartUpload(missing session) = %v; want NotFound", err)
+	}
+	if _, err := fs.StartUpload(ctx, &pb.StartUploadRequest{SessionId: "PCH/abc"}); err == nil {
+		t.Error("StartUpload(unsupported project session): nil err; want non-nil err")
+	}
+}
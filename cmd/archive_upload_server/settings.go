@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables which set the flags of
+// the server, ie: RV_TLS_CERT sets -tls_cert.
+const envPrefix = "RV_"
+
+// envName returns the environment variable of a flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(flagName)
+}
+
+// setFromEnv sets the flags not set on the command line from their
+// environment variables, see envName. The environment is read by lookup, ie:
+// os.LookupEnv.
+func setFromEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	set := setFlags(fs)
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] {
+			return
+		}
+		v, ok := lookup(envName(f.Name))
+		if !ok {
+			return
+		}
+		if sErr := fs.Set(f.Name, v); sErr != nil {
+			err = fmt.Errorf("bad %s(%q): %v", envName(f.Name), v, sErr)
+		}
+	})
+	return err
+}
+
+// setFromConfig sets the flags not set on the command line, nor from the
+// environment, from the server settings of the config, keyed by flag name.
+// Settings of unknown flags are rejected, as is the config_file, which the
+// config is read from.
+func setFromConfig(fs *flag.FlagSet, settings map[string]string) error {
+	set := setFlags(fs)
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	// Report the errors of the settings in a stable order.
+	sort.Strings(names)
+	for _, name := range names {
+		v := settings[name]
+		switch {
+		case name == "config_file":
+			return fmt.Errorf("config_file is not a server setting")
+		case fs.Lookup(name) == nil:
+			return fmt.Errorf("unknown server setting %s", name)
+		case set[name]:
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("bad server setting %s(%q): %v", name, v, err)
+		}
+	}
+	return nil
+}
+
+// setFlags returns the names of the flags which are set, on the command line
+// or by fs.Set.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+// testFlags returns a flag set with flags of each kind, and the command line
+// parsed.
+func testFlags(t *testing.T, args ...string) (*flag.FlagSet, *string, *int, *time.Duration) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config_file", "", "")
+	cert := fs.String("tls_cert", "", "")
+	size := fs.Int("max_msg_bytes", maxMsgSize, "")
+	drain := fs.Duration("drain_timeout", 9*time.Second, "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs, cert, size, drain
+}
+
+func TestSettings(t *testing.T) {
+	tests := []struct {
+		desc      string
+		args      []string
+		env       map[string]string
+		settings  map[string]string
+		wantCert  string
+		wantSize  int
+		wantDrain time.Duration
+	}{{
+		desc:      "defaults",
+		wantSize:  maxMsgSize,
+		wantDrain: 9 * time.Second,
+	}, {
+		desc: "config",
+		settings: map[string]string{
+			"tls_cert":      "/etc/rv/cert.pem",
+			"max_msg_bytes": "1024",
+			"drain_timeout": "5s",
+		},
+		wantCert:  "/etc/rv/cert.pem",
+		wantSize:  1024,
+		wantDrain: 5 * time.Second,
+	}, {
+		desc: "environment overrides config",
+		env:  map[string]string{"RV_MAX_MSG_BYTES": "2048"},
+		settings: map[string]string{
+			"tls_cert":      "/etc/rv/cert.pem",
+			"max_msg_bytes": "1024",
+		},
+		wantCert:  "/etc/rv/cert.pem",
+		wantSize:  2048,
+		wantDrain: 9 * time.Second,
+	}, {
+		desc:      "command line overrides environment and config",
+		args:      []string{"-max_msg_bytes=4096", "-drain_timeout=1s"},
+		env:       map[string]string{"RV_MAX_MSG_BYTES": "2048"},
+		settings:  map[string]string{"drain_timeout": "5s"},
+		wantSize:  4096,
+		wantDrain: time.Second,
+	}}
+	for _, test := range tests {
+		fs, cert, size, drain := testFlags(t, test.args...)
+		lookup := func(k string) (string, bool) {
+			v, ok := test.env[k]
+			return v, ok
+		}
+		if err := setFromEnv(fs, lookup); err != nil {
+			t.Errorf("%s: setFromEnv() got err: %v; want nil err", test.desc, err)
+			continue
+		}
+		if err := setFromConfig(fs, test.settings); err != nil {
+			t.Errorf("%s: setFromConfig() got err: %v; want nil err", test.desc, err)
+			continue
+		}
+		if *cert != test.wantCert || *size != test.wantSize || *drain != test.wantDrain {
+			t.Errorf("%s: got tls_cert(%q) max_msg_bytes(%d) drain_timeout(%v), want tls_cert(%q) max_msg_bytes(%d) drain_timeout(%v)",
+				test.desc, *cert, *size, *drain, test.wantCert, test.wantSize, test.wantDrain)
+		}
+	}
+}
+
+func TestBadSettings(t *testing.T) {
+	tests := []struct {
+		desc     string
+		settings map[string]string
+	}{{
+		desc:     "unknown flag",
+		settings: map[string]string{"tls_certificate": "/etc/rv/cert.pem"},
+	}, {
+		desc:     "bad value",
+		settings: map[string]string{"max_msg_bytes": "lots"},
+	}, {
+		desc:     "config file",
+		settings: map[string]string{"config_file": "/etc/rv/other.yaml"},
+	}}
+	for _, test := range tests {
+		fs, _, _, _ := testFlags(t)
+		if err := setFromConfig(fs, test.settings); err == nil {
+			t.Errorf("%s: setFromConfig(%v) got nil err, want err", test.desc, test.settings)
+		}
+	}
+
+	fs, _, _, _ := testFlags(t)
+	lookup := func(k string) (string, bool) { return "soon", k == "RV_DRAIN_TIMEOUT" }
+	if err := setFromEnv(fs, lookup); err == nil {
+		t.Error("setFromEnv(RV_DRAIN_TIMEOUT=soon) got nil err, want err")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// shedWindow is the rolling window of the storage writes the health of
+	// the storage is judged on.
+	shedWindow = time.Minute
+	// shedMinSamples is the writes of the window below which the storage is
+	// judged healthy, too few to judge on.
+	shedMinSamples = 10
+	// shedMaxSamples bounds the writes kept, the oldest are dropped first.
+	shedMaxSamples = 1000
+)
+
+// shedSample is the latency and outcome of a storage write.
+type shedSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// shedder tracks the latency and error rate of the storage writes over a
+// rolling window, and sheds the new uploads while either is degraded, rather
+// than accept uploads which would time out and waste the bandwidth of their
+// clients. It is safe for concurrent use, and a nil shedder sheds nothing.
+//
+// Uploads are shed until the writes which degraded the window age out of it,
+// the window then holds too few writes to judge on and uploads are accepted
+// again.
+type shedder struct {
+	mu sync.Mutex
+	// latency is the mean write latency above which uploads are shed, zero
+	// disables it.
+	latency time.Duration
+	// errorRate is the rate of failed writes above which uploads are shed,
+	// zero disables it.
+	errorRate float64
+	samples   []shedSample
+	shedding  bool
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newShedder returns a shedder of the latency and error rate thresholds, nil
+// if both are disabled.
+func newShedder(latency time.Duration, errorRate float64) *shedder {
+	if latency <= 0 && errorRate <= 0 {
+		return nil
+	}
+	return &shedder{latency: latency, errorRate: errorRate, now: time.Now}
+}
+
+// storageFailure reports whether the error of a storage write is a failure
+// of the storage: a 5xx or 429, a timeout, or a transport error. The errors of
+// the request, ie: a failed precondition or a cancellation, are not.
+func storageFailure(err error) bool {
+	var gErr *googleapi.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &gErr):
+		return gErr.Code >= 500 || gErr.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// observe records a storage write which started at start, and failed with
+// err, if not nil.
+func (s *shedder) observe(start time.Time, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.samples = append(s.samples, shedSample{at: now, latency: now.Sub(start), failed: storageFailure(err)})
+	if len(s.samples) > shedMaxSamples {
+		s.samples = s.samples[len(s.samples)-shedMaxSamples:]
+	}
+}
+
+// degraded returns why the storage is degraded, empty if it is not, and when
+// the oldest write of the window ages out of it. It is called with s.mu held.
+func (s *shedder) degraded(now time.Time) (string, time.Time) {
+	i := 0
+	for i < len(s.samples) && !now.Before(s.samples[i].at.Add(shedWindow)) {
+		i++
+	}
+	s.samples = s.samples[i:]
+	if len(s.samples) < shedMinSamples {
+		return "", time.Time{}
+	}
+	var total time.Duration
+	failed := 0
+	for _, sm := range s.samples {
+		total += sm.latency
+		if sm.failed {
+			failed++
+		}
+	}
+	expires := s.samples[0].at.Add(shedWindow)
+	mean := total / time.Duration(len(s.samples))
+	rate := float64(failed) / float64(len(s.samples))
+	switch {
+	case s.errorRate > 0 && rate > s.errorRate:
+		return "error rate " + strconv.FormatFloat(rate, 'f', 2, 64), expires
+	case s.latency > 0 && mean > s.latency:
+		return "mean write latency " + mean.Round(time.Millisecond).String(), expires
+	}
+	return "", time.Time{}
+}
+
+// isShedding reports whether new uploads were shed by the last admit.
+func (s *shedder) isShedding() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shedding
+}
+
+// admit returns nil if a new upload is accepted, or an Unavailable error with
+// a RetryInfo of when to retry it, while the storage is degraded.
+func (s *shedder) admit() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	now := s.now()
+	why, expires := s.degraded(now)
+	changed := (why != "") != s.shedding
+	s.shedding = why != ""
+	s.mu.Unlock()
+	if changed {
+		if why != "" {
+			glog.Warningf("Shedding new uploads, storage is degraded: %s", why)
+		} else {
+			glog.Infof("Accepting new uploads, storage recovered")
+		}
+	}
+	if why == "" {
+		return nil
+	}
+	retry := expires.Sub(now)
+	if retry < time.Second {
+		retry = time.Second
+	}
+	st := withReason(status.Newf(codes.Unavailable, "storage is degraded (%s), retry after %v", why, retry.Round(time.Second)),
+		reasonStorageDegraded, map[string]string{"retry_after": retry.Round(time.Second).String()})
+	if d, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retry)}); err == nil {
+		st = d
+	}
+	return st.Err()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStorageFailure(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{{
+		desc: "success",
+	}, {
+		desc: "server error",
+		err:  &googleapi.Error{Code: http.StatusServiceUnavailable},
+		want: true,
+	}, {
+		desc: "rate limited",
+		err:  &googleapi.Error{Code: http.StatusTooManyRequests},
+		want: true,
+	}, {
+		desc: "precondition failed",
+		err:  &googleapi.Error{Code: http.StatusPreconditionFailed},
+	}, {
+		desc: "cancelled",
+		err:  context.Canceled,
+	}, {
+		desc: "timeout",
+		err:  context.DeadlineExceeded,
+		want: true,
+	}, {
+		desc: "transport error",
+		err:  errors.New("connection reset by peer"),
+		want: true,
+	}}
+	for _, test := range tests {
+		if got := storageFailure(test.err); got != test.want {
+			t.Errorf("%s: storageFailure(%v) = %v, want %v", test.desc, test.err, got, test.want)
+		}
+	}
+}
+
+func TestShedder(t *testing.T) {
+	if s := newShedder(0, 0); s != nil {
+		t.Errorf("newShedder(0, 0) = %v, want nil", s)
+	}
+	var nilShedder *shedder
+	nilShedder.observe(time.Now(), errors.New("failed"))
+	if err := nilShedder.admit(); err != nil {
+		t.Errorf("admit() of a nil shedder got err: %v", err)
+	}
+
+	now := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc    string
+		latency time.Duration
+		err     error
+		n       int
+		want    codes.Code
+	}{{
+		desc:    "healthy",
+		latency: time.Second,
+		n:       shedMinSamples,
+	}, {
+		desc:    "too few writes",
+		latency: time.Hour,
+		err:     errors.New("failed"),
+		n:       shedMinSamples - 1,
+	}, {
+		desc:    "slow writes",
+		latency: 3 * time.Minute,
+		n:       shedMinSamples,
+		want:    codes.Unavailable,
+	}, {
+		desc:    "failed writes",
+		latency: time.Second,
+		err:     &googleapi.Error{Code: http.StatusServiceUnavailable},
+		n:       shedMinSamples,
+		want:    codes.Unavailable,
+	}}
+	for _, test := range tests {
+		s := newShedder(2*time.Minute, 0.5)
+		s.now = func() time.Time { return now }
+		for i := 0; i < test.n; i++ {
+			s.observe(now.Add(-test.latency), test.err)
+		}
+		err := s.admit()
+		if got := status.Code(err); got != test.want {
+			t.Errorf("%s: admit() got err %v, want %v", test.desc, err, test.want)
+			continue
+		}
+		if err != nil && reasonOf(err) != reasonStorageDegraded {
+			t.Errorf("%s: admit() got reason %q, want %q", test.desc, reasonOf(err), reasonStorageDegraded)
+		}
+		// The writes age out of the window, uploads are accepted again.
+		s.now = func() time.Time { return now.Add(shedWindow) }
+		if err := s.admit(); err != nil {
+			t.Errorf("%s: admit() past the window got err: %v", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/grpc/health"
+)
+
+// stopper is the part of grpc.Server which stops it.
+type stopper interface {
+	GracefulStop()
+	Stop()
+}
+
+// drain stops the server once the current RPCs finish, so the writers of the
+// uploads in flight close cleanly. RPCs which outlast the timeout are
+// cancelled, an upload cancelled part way stores no object. It reports whether
+// every RPC finished in time.
+func drain(s stopper, timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-stopped:
+		return true
+	case <-t.C:
+		s.Stop()
+		<-stopped
+		return false
+	}
+}
+
+// drainOnSignal drains the server on SIGTERM, which Cloud Run sends before it
+// recycles an instance, or on an interrupt. The health service reports
+// NOT_SERVING from then on, so no new uploads are routed to the server.
+func drainOnSignal(s stopper, hs *health.Server, timeout time.Duration) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	got := <-sig
+	glog.Infof("Received %v, draining the uploads in flight for at most %v", got, timeout)
+	hs.Shutdown()
+	if !drain(s, timeout) {
+		glog.Warningf("Drain timeout(%v) passed, cancelled the uploads in flight", timeout)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a server with an RPC in flight, which ends once done is
+// closed or the server is stopped.
+type fakeServer struct {
+	done chan struct{}
+
+	mu      sync.Mutex
+	stopped bool
+	once    sync.Once
+}
+
+func (s *fakeServer) GracefulStop() { <-s.done }
+
+func (s *fakeServer) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.once.Do(func() { close(s.done) })
+}
+
+func TestDrain(t *testing.T) {
+	// The RPC in flight finishes within the timeout.
+	s := &fakeServer{done: make(chan struct{})}
+	time.AfterFunc(10*time.Millisecond, func() { s.once.Do(func() { close(s.done) }) })
+	if !drain(s, time.Minute) {
+		t.Error("drain() = false; want true")
+	}
+	if s.stopped {
+		t.Error("drain() stopped the server; want a graceful stop")
+	}
+
+	// The RPC in flight outlasts the timeout, it is cancelled.
+	s = &fakeServer{done: make(chan struct{})}
+	if drain(s, 10*time.Millisecond) {
+		t.Error("drain() = true; want false")
+	}
+	if !s.stopped {
+		t.Error("drain() did not stop the server after the timeout")
+	}
+}
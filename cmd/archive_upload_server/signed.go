@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Signed uploads are staged in the bucket of the project, until they are
+// finalized:
+//
+//	_signed/<id>/content  the file content, with the file metadata
+//
+// The file metadata is signed into the URL as x-goog-meta headers, so the
+// staged object carries it and cloud-storage rejects a PUT of other metadata.
+// Abandoned uploads are left behind, a lifecycle rule on the prefix deletes
+// them.
+const signedPrefix = "_signed/"
+
+// stagedName returns the name of the staged object of a signed upload, which
+// shares the id scheme of the sessions.
+func (s *session) stagedName() string {
+	return signedPrefix + strings.TrimPrefix(s.prefix, sessionPrefix) + "content"
+}
+
+// SignUpload validates the file metadata, and returns a signed URL to PUT the
+// file content to, which expires after r.signTTL. The content is verified by
+// cloud-storage against the md5sum and size, and by FinalizeUpload.
+func (r rvServer) SignUpload(ctx context.Context, req *pb.SignUploadRequest) (*pb.SignUploadResponse, error) {
+	if err := r.needsGCS("SignUpload"); err != nil {
+		return nil, err
+	}
+	if r.signTTL <= 0 {
+		return nil, status.Error(codes.Unimplemented, "signed uploads are disabled")
+	}
+	meta := req.GetMetadata()
+	fn := meta.GetFilename()
+	proj := meta.GetProject()
+	sum := meta.GetMd5Sum()
+	size := req.GetSize()
+	if err := requireFields("SignUploadRequest",
+		field{"metadata.filename", len(fn) > 0},
+		field{"metadata.md5sum", len(sum) > 0},
+		field{"metadata.project", proj != pb.FileRequest_UNKNOWN},
+		field{"size", size > 0}); err != nil {
+		return nil, err
+	}
+	if enc := meta.GetContentEncoding(); enc != "" {
+		return nil, badField("metadata.content_encoding", reasonInvalidField, "unsupported content_encoding(%q) of a signed upload", enc)
+	}
+	if err := r.checkSize(proj, "size", size); err != nil {
+		return nil, err
+	}
+	fn, err := r.checkFilename(proj, fn)
+	if err != nil {
+		return nil, err
+	}
+	meta.Filename = fn
+	md5Sum, err := hex.DecodeString(sum)
+	if err != nil || len(md5Sum) != md5.Size {
+		return nil, invalidChecksum("metadata.md5sum", "md5sum", sum)
+	}
+	if crc := meta.GetCrc32C(); crc != "" {
+		if _, err := parseCRC32C(crc); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.authorize(ctx, proj.String()); err != nil {
+		return nil, err
+	}
+	if err := r.shed.admit(); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, size); err != nil {
+		return nil, err
+	}
+	bkt, prefix, ok := r.config().route(proj.String())
+	if !ok {
+		return nil, unsupportedProject("metadata.project", proj)
+	}
+	// An overwrite is refused before the content is PUT, and checked again by
+	// FinalizeUpload.
+	if _, ok, err := r.checkOverwrite(ctx, bkt, prefix+fn, meta, &uploadRecord{}); err != nil {
+		return nil, err
+	} else if ok {
+		return &pb.SignUploadResponse{Unchanged: true}, nil
+	}
+
+	id, err := newSessionID(proj)
+	if err != nil {
+		return nil, err
+	}
+	s, err := r.session(id)
+	if err != nil {
+		return nil, err
+	}
+	headers := []string{fmt.Sprintf("x-goog-content-length-range:%d,%d", size, size)}
+	for k, v := range uploadMeta(meta) {
+		if v != "" {
+			headers = append(headers, "x-goog-meta-"+strings.ReplaceAll(k, "_", "-")+":"+v)
+		}
+	}
+	sort.Strings(headers)
+	b64Sum := base64.StdEncoding.EncodeToString(md5Sum)
+	expires := time.Now().Add(r.signTTL)
+	url, err := s.bh.SignedURL(s.stagedName(), &storage.SignedURLOptions{
+		GoogleAccessID: r.signer,
+		Method:         "PUT",
+		Expires:        expires,
+		MD5:            b64Sum,
+		Headers:        headers,
+		Scheme:         storage.SigningSchemeV4,
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to sign the upload of %s: %v", fn, err)
+	}
+	glog.Infof("Signed upload(%s) of %s until %v", id, fn, expires)
+	return &pb.SignUploadResponse{
+		UploadId: id,
+		Url:      url,
+		Headers:  append(headers, "content-md5:"+b64Sum),
+		Expires:  expires.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// signedMeta returns the file metadata of a staged object, the x-goog-meta
+// headers of its signed upload.
+func signedMeta(md map[string]string) *pb.FileRequest {
+	m := map[string]string{}
+	for k, v := range md {
+		m[strings.ReplaceAll(strings.ToLower(k), "-", "_")] = v
+	}
+	return uploadRequest(m)
+}
+
+// FinalizeUpload verifies the staged content of a signed upload against the
+// checksums of the file, and copies it to the object of the file, with the
+// metadata, storage class, KMS key and holds of an upload.
+func (r rvServer) FinalizeUpload(ctx context.Context, req *pb.FinalizeUploadRequest) (resp *pb.FileResponse, err error) {
+	if err := r.needsGCS("FinalizeUpload"); err != nil {
+		return nil, err
+	}
+	rec := &uploadRecord{RPC: "FinalizeUpload"}
+	defer func() { r.auditUpload(ctx, rec, resp.GetStatus(), err) }()
+
+	s, err := r.session(req.GetUploadId())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, s.proj); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, s.proj, 1, 0); err != nil {
+		return nil, err
+	}
+	staged := s.bh.Object(s.stagedName())
+	attrs, err := staged.Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, status.Errorf(codes.FailedPrecondition, "signed upload(%s) has no content", s.id)
+	}
+	if err != nil {
+		return nil, storageError(err, "failed to read signed upload(%s)", s.id)
+	}
+	meta := signedMeta(attrs.Metadata)
+	if meta.GetProject().String() != s.proj {
+		return nil, status.Errorf(codes.InvalidArgument, "signed upload(%s) is not of %s", s.id, s.proj)
+	}
+	rec.Filename, rec.Project, rec.MD5, rec.Size = meta.GetFilename(), s.proj, meta.GetMd5Sum(), attrs.Size
+	// The staged object may be replaced until it expires, with other content.
+	if err := r.checkSize(meta.GetProject(), "size", attrs.Size); err != nil {
+		return nil, err
+	}
+
+	fn := s.objPrefix + meta.GetFilename()
+	rec.Object = "gs://" + s.bkt + "/" + fn
+
+	// cloud-storage verified the md5sum of the PUT, the checksums are verified
+	// again as the staged object may be replaced until it expires. A staged
+	// object of no md5, ie: composed, is read to hash it: the md5sum of its
+	// metadata is of the request.
+	if len(attrs.MD5) == 0 {
+		if err := verifyContent(ctx, staged, "md5sum", uploadutils.MD5, meta.GetMd5Sum()); err != nil {
+			if quarantines(err) {
+				r.quarantineObject(ctx, staged, s.bkt, fn, rec.RPC, meta, err)
+			}
+			return nil, err
+		}
+	} else if err := checkSum("md5sum", meta.GetMd5Sum(), hex.EncodeToString(attrs.MD5)); err != nil {
+		r.quarantineObject(ctx, staged, s.bkt, fn, rec.RPC, meta, err)
+		return nil, err
+	}
+	if err := checkSum("crc32c", meta.GetCrc32C(), uploadutils.ChecksumFromAttrs(uploadutils.CRC32C, attrs)); err != nil {
+		r.quarantineObject(ctx, staged, s.bkt, fn, rec.RPC, meta, err)
+		return nil, err
+	}
+	if want := meta.GetSha256(); want != "" {
+		if err := verifyContent(ctx, staged, "sha256", uploadutils.SHA256, want); err != nil {
+			if quarantines(err) {
+				r.quarantineObject(ctx, staged, s.bkt, fn, rec.RPC, meta, err)
+			}
+			return nil, err
+		}
+	}
+	if r.checksMRT(meta.GetProject(), meta.GetFilename()) {
+		if err := checkStoredMRT(ctx, staged, meta.GetFilename()); err != nil {
+			invalid, ok := invalidFormat(err)
+			if !ok {
+				return nil, storageError(err, "failed to validate signed upload(%s)", s.id)
+			}
+			r.quarantineObject(ctx, staged, s.bkt, fn, rec.RPC, meta, err)
+			deleteStaged(ctx, staged, s.id)
+			return invalid, nil
+		}
+	}
+	existing, ok, err := r.checkOverwrite(ctx, s.bkt, fn, meta, rec)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		rec.Generation = existing.Generation
+		deleteStaged(ctx, staged, s.id)
+		return r.withObject(&pb.FileResponse{Status: pb.FileResponse_UNCHANGED, Retention: retentionOf(existing)}, existing), nil
+	}
+
+	// The copy is bounded by the deadline of the request, it is not
+	// committed if it expires.
+	cctx, cancel := stageContext(ctx, 0)
+	defer cancel()
+	cctx, span := tracer.Start(cctx, "gcs.copy", objectAttrs(s.bkt, fn), trace.WithAttributes(attribute.Int64("bytes", attrs.Size)))
+	c := r.object(s.bkt, fn).If(writeConditions(existing)).CopierFrom(staged)
+	// The metadata of the copy replaces that of the signed upload.
+	c.Metadata = r.objectMeta(ctx, meta)
+	c.ContentType, c.ContentEncoding = r.contentHeaders(meta, fn)
+	c.StorageClass = r.config().storageClass(meta.GetProject(), meta.GetFilename(), time.Now())
+	c.DestinationKMSKeyName = r.kmsKey(s.proj)
+	r.setHolds(&c.ObjectAttrs, s.proj)
+	start := time.Now()
+	stored, err := c.Run(cctx)
+	r.shed.observe(start, err)
+	endSpan(span, err)
+	if preconditionFailed(err) {
+		return nil, writtenMeanwhile(meta.GetFilename(), err)
+	}
+	if err != nil {
+		return nil, storageError(err, "failed storing object: %s/%s", s.bkt, fn)
+	}
+	// The metadata is set again once the object is stored, as by FileUpload:
+	// the update, rather than the copy, triggers the conversion.
+	stored, err = r.setStoredMeta(ctx, s.bkt, fn, stored, meta)
+	if err != nil {
+		return nil, err
+	}
+	rec.Generation = stored.Generation
+	r.recent.put(stored)
+	deleteStaged(ctx, staged, s.id)
+	replicas := r.replicate(ctx, stored)
+	conversion := r.convertNow(ctx, meta, stored)
+	r.notifyStored(ctx, s.proj, stored)
+	r.logUpload(ctx, s.proj, stored)
+	r.stats.stored(s.proj, stored.Size)
+	return r.withObject(&pb.FileResponse{Status: pb.FileResponse_SUCCESS, Retention: retentionOf(stored), Replicas: replicas, Conversion: conversion}, stored), nil
+}
+
+// verifyContent reads a staged object, and verifies its content against the
+// checksum of the algorithm, named kind in the errors.
+func verifyContent(ctx context.Context, obj *storage.ObjectHandle, kind, algo, want string) error {
+	if want == "" {
+		return nil
+	}
+	rc, err := obj.NewReader(ctx)
+	if err != nil {
+		return storageError(err, "failed to read %s", obj.ObjectName())
+	}
+	defer rc.Close()
+	h, err := uploadutils.NewHash(algo)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, rc); err != nil {
+		return storageError(err, "failed to read %s", obj.ObjectName())
+	}
+	return checkSum(kind, want, hex.EncodeToString(h.Sum(nil)))
+}
+
+// deleteStaged deletes the staged object of a finalized signed upload.
+func deleteStaged(ctx context.Context, obj *storage.ObjectHandle, id string) {
+	if err := obj.Delete(ctx); err != nil {
+		glog.Errorf("failed to delete signed upload(%s): %v", id, err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestSignedMeta(t *testing.T) {
+	meta := &pb.FileRequest{
+		Filename:  "2022/01/09/rpki-20220109T183000Z.tgz",
+		Md5Sum:    "50e3903156f5d2dac6c9f89626d48c75",
+		Crc32C:    "3863cc2f",
+		SourceUrl: "https://ftp.ripe.net/rpki/2022/01/09/rpki-20220109T183000Z.tgz",
+		Project:   pb.FileRequest_RPKI_RARC,
+	}
+	// cloud-storage keeps the x-goog-meta headers as the metadata of the
+	// object, without the prefix.
+	md := map[string]string{}
+	for k, v := range uploadMeta(meta) {
+		md[strings.ReplaceAll(k, "_", "-")] = v
+	}
+	if diff := cmp.Diff(signedMeta(md), meta, protocmp.Transform()); diff != "" {
+		t.Errorf("signedMeta() diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestSignUploadRejected(t *testing.T) {
+	ctx := context.Background()
+	meta := &pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Project:  pb.FileRequest_RPKI_RARC,
+	}
+	tests := []struct {
+		desc    string
+		signTTL time.Duration
+		req     *pb.SignUploadRequest
+		want    codes.Code
+	}{{
+		desc: "disabled",
+		req:  &pb.SignUploadRequest{Metadata: meta, Size: 11},
+		want: codes.Unimplemented,
+	}, {
+		desc:    "no size",
+		signTTL: time.Hour,
+		req:     &pb.SignUploadRequest{Metadata: meta},
+		want:    codes.InvalidArgument,
+	}, {
+		desc:    "bad md5sum",
+		signTTL: time.Hour,
+		req: &pb.SignUploadRequest{
+			Metadata: &pb.FileRequest{Filename: "bar", Md5Sum: "abc", Project: pb.FileRequest_RPKI_RARC},
+			Size:     11,
+		},
+		want: codes.InvalidArgument,
+	}, {
+		desc:    "encoded content",
+		signTTL: time.Hour,
+		req: &pb.SignUploadRequest{
+			Metadata: &pb.FileRequest{Filename: "bar", Md5Sum: meta.Md5Sum, Project: pb.FileRequest_RPKI_RARC, ContentEncoding: "gzip"},
+			Size:     11,
+		},
+		want: codes.InvalidArgument,
+	}}
+	for _, test := range tests {
+		r := rvServer{conf: &config{}, signTTL: test.signTTL}
+		if _, err := r.SignUpload(ctx, test.req); status.Code(err) != test.want {
+			t.Errorf("%s: SignUpload() = %v; want %v", test.desc, err, test.want)
+		}
+	}
+}
+
+func TestFinalizeUpload(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer(nil)
+	defer srv.Stop()
+	srv.CreateBucket("foo")
+	cli, events := gcsEventsClient(t, srv)
+	r := rvServer{
+		conf: &config{Routes: map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}}},
+		sc:   cli,
+	}
+	id, err := newSessionID(pb.FileRequest_RPKI_RARC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := r.session(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.FinalizeUpload(ctx, &pb.FinalizeUploadRequest{UploadId: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("FinalizeUpload(not uploaded) = %v; want FailedPrecondition", err)
+	}
+
+	// The PUT to the signed URL stores the content and metadata.
+	md := map[string]string{}
+	for k, v := range uploadMeta(&pb.FileRequest{
+		Filename: "bar",
+		Md5Sum:   "50e3903156f5d2dac6c9f89626d48c75",
+		Project:  pb.FileRequest_RPKI_RARC,
+	}) {
+		md[strings.ReplaceAll(k, "_", "-")] = v
+	}
+	srv.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: s.stagedName(), Metadata: md},
+		Content:     []byte("Foo Bar Baz"),
+	})
+	resp, err := r.FinalizeUpload(ctx, &pb.FinalizeUploadRequest{UploadId: id})
+	if err != nil || resp.GetStatus() != pb.FileResponse_SUCCESS {
+		t.Fatalf("FinalizeUpload() = %v, %v; want SUCCESS", resp, err)
+	}
+	obj, err := srv.GetObject("foo", "rarc/bar")
+	if err != nil {
+		t.Fatalf("GetObject(rarc/bar) got err: %v; want the finalized object", err)
+	}
+	if string(obj.Content) != "Foo Bar Baz" {
+		t.Errorf("finalized content = %q; want %q", obj.Content, "Foo Bar Baz")
+	}
+	if !events.converts("foo", "rarc/bar") {
+		t.Error("FinalizeUpload() updated no metadata of the object; want the update which triggers its conversion")
+	}
+	if _, err := srv.GetObject("foo", s.stagedName()); err == nil {
+		t.Error("staged object exists after FinalizeUpload(); want it deleted")
+	}
+
+	// Staged content of no md5 is read to verify it, rather than trusting the
+	// md5sum of its metadata.
+	id, err = newSessionID(pb.FileRequest_RPKI_RARC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = r.session(id); err != nil {
+		t.Fatal(err)
+	}
+	srv.CreateObject(fakestorage.Object{
+		ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: s.stagedName(), Metadata: md},
+		Content:     []byte("Foo Bar"),
+	})
+	if _, err := r.FinalizeUpload(ctx, &pb.FinalizeUploadRequest{UploadId: id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("FinalizeUpload(other content) = %v; want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// checkMaxSizes checks the max file sizes of the projects of the config.
+func checkMaxSizes(sizes map[string]int64) error {
+	for proj, size := range sizes {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of max_sizes", proj)
+		}
+		if size <= 0 {
+			return fmt.Errorf("bad max size(%d) of %s, want > 0", size, proj)
+		}
+	}
+	return nil
+}
+
+// maxSize returns the max size of the files of a project, zero if the
+// project has none.
+func (r rvServer) maxSize(proj pb.FileRequest_Project) int64 {
+	for p, size := range r.config().MaxSizes {
+		if pb.FileRequest_Project_value[p] == int32(proj) {
+			return size
+		}
+	}
+	return 0
+}
+
+// checkSize returns an InvalidArgument error, see fileTooLarge, if a file of
+// a project, or its content received so far, exceeds the max size of the
+// project. The field is the field of the request which carries the size.
+func (r rvServer) checkSize(proj pb.FileRequest_Project, name string, size int64) error {
+	if limit := r.maxSize(proj); limit > 0 && size > limit {
+		return fileTooLarge(name, proj, size, limit)
+	}
+	return nil
+}
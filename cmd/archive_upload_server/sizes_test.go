@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckSize(t *testing.T) {
+	r := rvServer{conf: &config{MaxSizes: map[string]int64{"RPKI_RARC": 1024}}}
+	tests := []struct {
+		desc    string
+		proj    pb.FileRequest_Project
+		size    int64
+		wantErr bool
+	}{{
+		desc: "within the max size",
+		proj: pb.FileRequest_RPKI_RARC,
+		size: 1024,
+	}, {
+		desc:    "beyond the max size",
+		proj:    pb.FileRequest_RPKI_RARC,
+		size:    1025,
+		wantErr: true,
+	}, {
+		desc: "project without a max size",
+		proj: pb.FileRequest_ROUTEVIEWS,
+		size: 1 << 40,
+	}}
+	for _, test := range tests {
+		err := r.checkSize(test.proj, "content", test.size)
+		switch {
+		case err != nil && !test.wantErr:
+			t.Errorf("%s: checkSize(%d) got err: %v; want nil err", test.desc, test.size, err)
+		case err == nil && test.wantErr:
+			t.Errorf("%s: checkSize(%d) got nil err, want err", test.desc, test.size)
+		}
+	}
+
+	err := r.checkSize(pb.FileRequest_RPKI_RARC, "size", 2048)
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("checkSize(2048) got code %v, want %v", got, codes.InvalidArgument)
+	}
+	if got := reasonOf(err); got != reasonFileTooLarge {
+		t.Errorf("checkSize(2048) got reason %q, want %q", got, reasonFileTooLarge)
+	}
+	if diff := cmp.Diff(fieldViolations(err), []string{"size"}); diff != "" {
+		t.Errorf("checkSize(2048) got field violations diff (-got +want):\n%s", diff)
+	}
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Metadata["max_size"] != "1024" {
+			t.Errorf("checkSize(2048) got max_size(%q), want 1024", info.Metadata["max_size"])
+		}
+	}
+}
+
+func TestCheckMaxSizes(t *testing.T) {
+	if err := checkMaxSizes(map[string]int64{"RPKI_RARC": 1024}); err != nil {
+		t.Errorf("checkMaxSizes(RPKI_RARC) got err: %v; want nil err", err)
+	}
+	if err := checkMaxSizes(map[string]int64{"RPKI": 1024}); err == nil {
+		t.Error("checkMaxSizes(RPKI) got nil err, want err")
+	}
+	if err := checkMaxSizes(map[string]int64{"RPKI_RARC": 0}); err == nil {
+		t.Error("checkMaxSizes(RPKI_RARC: 0) got nil err, want err")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// ObjectStat returns whether the object of a file exists, and its checksums,
+// size and generation. Clients compare them to the file to skip its upload,
+// without credentials to cloud-storage. Callers may stat the files of the
+// projects they may upload to. The filename is canonicalized as that of an
+// upload, see checkFilename.
+func (r rvServer) ObjectStat(ctx context.Context, req *pb.ObjectStatRequest) (*pb.ObjectStatResponse, error) {
+	if err := r.needsGCS("ObjectStat"); err != nil {
+		return nil, err
+	}
+	proj := req.GetProject()
+	fn := req.GetFilename()
+	if err := requireFields("ObjectStatRequest",
+		field{"filename", len(fn) > 0},
+		field{"project", proj != pb.FileRequest_UNKNOWN}); err != nil {
+		return nil, err
+	}
+	fn, err := r.checkFilename(proj, fn)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorize(ctx, proj.String()); err != nil {
+		return nil, err
+	}
+	if err := r.limit(ctx, proj.String(), 1, 0); err != nil {
+		return nil, err
+	}
+	bkt, prefix, ok := r.config().route(proj.String())
+	if !ok {
+		return nil, unsupportedProject("project", proj)
+	}
+	obj := prefix + fn
+
+	ctx, span := tracer.Start(ctx, "gcs.attrs", objectAttrs(bkt, obj))
+	attrs, err := r.lookup(ctx, bkt, obj)
+	span.End()
+	switch {
+	case err == storage.ErrObjectNotExist:
+		return &pb.ObjectStatResponse{}, nil
+	case err != nil:
+		glog.Errorf("failed to get the attributes of %s/%s: %v", bkt, obj, err)
+		return nil, storageError(err, "failed to get the attributes of %s", fn)
+	}
+	return &pb.ObjectStatResponse{
+		Exists:     true,
+		Md5Sum:     uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs),
+		Crc32C:     uploadutils.ChecksumFromAttrs(uploadutils.CRC32C, attrs),
+		Size:       attrs.Size,
+		Generation: attrs.Generation,
+	}, nil
+}
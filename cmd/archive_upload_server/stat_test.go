@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestObjectStat(t *testing.T) {
+	ctx := context.Background()
+	srv := fakestorage.NewServer([]fakestorage.Object{
+		storedObject("foo", "rarc/bar", []byte("Foo Bar Baz")),
+		{
+			// An object of no checksums, ie: written by another client.
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "foo", Name: "rarc/unsummed"},
+			Content:     []byte("Foo Bar Baz"),
+		},
+	})
+	defer srv.Stop()
+	obj, err := srv.GetObject("foo", "rarc/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsummed, err := srv.GetObject("foo", "rarc/unsummed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rvServer{
+		conf: &config{Routes: map[string]*route{"RPKI_RARC": {Bucket: "foo", Prefix: "rarc/"}}},
+		sc:   srv.Client(),
+	}
+
+	tests := []struct {
+		desc    string
+		req     *pb.ObjectStatRequest
+		want    *pb.ObjectStatResponse
+		wantErr bool
+	}{{
+		desc: "stored",
+		req:  &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "bar"},
+		want: &pb.ObjectStatResponse{
+			Exists:     true,
+			Md5Sum:     "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:     "3863cc2f",
+			Size:       11,
+			Generation: obj.Generation,
+		},
+	}, {
+		desc: "canonicalized filename",
+		req:  &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "//bar/"},
+		want: &pb.ObjectStatResponse{
+			Exists:     true,
+			Md5Sum:     "50e3903156f5d2dac6c9f89626d48c75",
+			Crc32C:     "3863cc2f",
+			Size:       11,
+			Generation: obj.Generation,
+		},
+	}, {
+		desc: "no checksums",
+		req:  &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "unsummed"},
+		want: &pb.ObjectStatResponse{
+			Exists:     true,
+			Size:       11,
+			Generation: unsummed.Generation,
+		},
+	}, {
+		desc: "missing",
+		req:  &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "baz"},
+		want: &pb.ObjectStatResponse{},
+	}, {
+		desc:    "no filename",
+		req:     &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC},
+		wantErr: true,
+	}, {
+		desc:    "reserved filename",
+		req:     &pb.ObjectStatRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "_uploads/bar"},
+		wantErr: true,
+	}, {
+		desc:    "unsupported project",
+		req:     &pb.ObjectStatRequest{Project: pb.FileRequest_PCH, Filename: "bar"},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		got, err := r.ObjectStat(ctx, test.req)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: ObjectStat() got nil err; want non-nil err", test.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ObjectStat() got err: %v; want nil err", test.desc, err)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want, protocmp.Transform()); diff != "" {
+			t.Errorf("%s: ObjectStat() diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
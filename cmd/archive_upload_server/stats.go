@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/routeviews/google-cloud-storage/pkg/version"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// buildVersion is the version the server binary was built from, set by the
+// build, ie: -ldflags "-X main.buildVersion=v1.4.2".
+var buildVersion = "devel"
+
+// serverStats counts the objects stored by a server instance, and their
+// bytes, per project in the current period: the UTC day. The counts restart
+// with the period, and with the instance. It is safe for concurrent use, and
+// a nil serverStats counts nothing.
+type serverStats struct {
+	mu     sync.Mutex
+	start  time.Time
+	period time.Time
+	// projects are the counts of the period, by project.
+	projects map[string]*pb.ProjectStats
+	// conversions is the number of conversions in progress.
+	conversions int64
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// newServerStats returns the stats of an instance started now.
+func newServerStats(now func() time.Time) *serverStats {
+	start := now()
+	return &serverStats{start: start, period: start, projects: map[string]*pb.ProjectStats{}, now: now}
+}
+
+// roll starts a new period if the UTC day of now is past that of the period,
+// s.mu is held.
+func (s *serverStats) roll(now time.Time) {
+	day := now.UTC().Truncate(24 * time.Hour)
+	if day.After(s.period) {
+		s.period = day
+		s.projects = map[string]*pb.ProjectStats{}
+	}
+}
+
+// stored counts an object stored of a project.
+func (s *serverStats) stored(proj string, size int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roll(s.now())
+	ps, ok := s.projects[proj]
+	if !ok {
+		ps = &pb.ProjectStats{Project: pb.FileRequest_Project(pb.FileRequest_Project_value[proj])}
+		s.projects[proj] = ps
+	}
+	ps.Uploads++
+	ps.Bytes += size
+}
+
+// converting counts a conversion in progress, until the returned func is
+// called.
+func (s *serverStats) converting() func() {
+	if s == nil {
+		return func() {}
+	}
+	s.mu.Lock()
+	s.conversions++
+	s.mu.Unlock()
+	return func() {
+		s.mu.Lock()
+		s.conversions--
+		s.mu.Unlock()
+	}
+}
+
+// snapshot returns the stats of the instance, and of the current period.
+func (s *serverStats) snapshot() *pb.ServerStatsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.roll(now)
+	resp := &pb.ServerStatsResponse{
+		ServerVersion: version.Protocol,
+		BuildVersion:  buildVersion,
+		StartTime:     s.start.UTC().Format(time.RFC3339),
+		UptimeSeconds: int64(now.Sub(s.start) / time.Second),
+		PeriodStart:   s.period.UTC().Format(time.RFC3339),
+		Conversions:   s.conversions,
+	}
+	for _, ps := range s.projects {
+		resp.Projects = append(resp.Projects, &pb.ProjectStats{Project: ps.Project, Uploads: ps.Uploads, Bytes: ps.Bytes})
+		resp.Bytes += ps.Bytes
+	}
+	sort.Slice(resp.Projects, func(i, j int) bool {
+		return resp.Projects[i].Project < resp.Projects[j].Project
+	})
+	return resp
+}
+
+// ServerStats returns the uptime and build of the instance, the uploads and
+// bytes it stored per project in the current period, and its backlogs: the
+// copies to replica buckets pending, the conversions in progress, and whether
+// new uploads are shed.
+func (r rvServer) ServerStats(ctx context.Context, req *pb.ServerStatsRequest) (*pb.ServerStatsResponse, error) {
+	stats := r.stats
+	if stats == nil {
+		stats = newServerStats(time.Now)
+	}
+	resp := stats.snapshot()
+	if r.replicas != nil {
+		resp.PendingReplicas = int64(len(r.replicas.copies()))
+	}
+	resp.Shedding = r.shed.isShedding()
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/routeviews/google-cloud-storage/pkg/version"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestServerStats(t *testing.T) {
+	now := time.Date(2021, 9, 1, 22, 0, 0, 0, time.UTC)
+	stats := newServerStats(func() time.Time { return now })
+	r := rvServer{stats: stats, replicas: newReplicator()}
+	r.replicas.fail(replicaCopy{bkt: "routeviews-archives", obj: "a", gen: 1, dst: "routeviews-archives-dr"})
+
+	stats.stored("ROUTEVIEWS", 100)
+	stats.stored("RPKI_RARC", 10)
+	stats.stored("ROUTEVIEWS", 50)
+	done := stats.converting()
+	stats.converting()()
+
+	now = now.Add(90 * time.Minute)
+	got, err := r.ServerStats(context.Background(), &pb.ServerStatsRequest{})
+	if err != nil {
+		t.Fatalf("ServerStats() got err: %v", err)
+	}
+	want := &pb.ServerStatsResponse{
+		ServerVersion: version.Protocol,
+		BuildVersion:  "devel",
+		StartTime:     "2021-09-01T22:00:00Z",
+		UptimeSeconds: 5400,
+		PeriodStart:   "2021-09-01T22:00:00Z",
+		Projects: []*pb.ProjectStats{
+			{Project: pb.FileRequest_ROUTEVIEWS, Uploads: 2, Bytes: 150},
+			{Project: pb.FileRequest_RPKI_RARC, Uploads: 1, Bytes: 10},
+		},
+		Bytes:           160,
+		PendingReplicas: 1,
+		Conversions:     1,
+	}
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("ServerStats() got diff (-got +want):\n%s", diff)
+	}
+
+	// The counts restart with the UTC day.
+	done()
+	now = now.Add(time.Hour)
+	stats.stored("ROUTEVIEWS_RIB", 7)
+	got, err = r.ServerStats(context.Background(), &pb.ServerStatsRequest{})
+	if err != nil {
+		t.Fatalf("ServerStats() got err: %v", err)
+	}
+	want.UptimeSeconds = 9000
+	want.PeriodStart = "2021-09-02T00:00:00Z"
+	want.Projects = []*pb.ProjectStats{{Project: pb.FileRequest_ROUTEVIEWS_RIB, Uploads: 1, Bytes: 7}}
+	want.Bytes = 7
+	want.Conversions = 0
+	if diff := cmp.Diff(got, want, protocmp.Transform()); diff != "" {
+		t.Errorf("ServerStats() of the next day got diff (-got +want):\n%s", diff)
+	}
+
+	// A server without stats counts nothing.
+	var nilStats *serverStats
+	nilStats.stored("ROUTEVIEWS", 1)
+	nilStats.converting()()
+	if _, err := (rvServer{}).ServerStats(context.Background(), &pb.ServerStatsRequest{}); err != nil {
+		t.Errorf("ServerStats() without stats got err: %v", err)
+	}
+}
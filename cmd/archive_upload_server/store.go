@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// objectStore stores the objects of the files of FileUpload: cloud-storage,
+// see gcsStore, or an S3-compatible store, see s3Store.
+type objectStore interface {
+	// checkBucket checks that a bucket exists, and can be reached.
+	checkBucket(ctx context.Context, bkt string) error
+	// attrs returns the attributes of an object, storage.ErrObjectNotExist if
+	// it does not exist.
+	attrs(ctx context.Context, bkt, obj string) (*storage.ObjectAttrs, error)
+	// write stores the content of a request to an object, with the metadata
+	// of the file, on the conditions of the write, see writeConditions, and
+	// returns the attributes of the stored object. A write which fails its
+	// conditions fails with writtenMeanwhile.
+	write(ctx context.Context, bkt, obj string, req *pb.FileRequest, conds storage.Conditions) (*storage.ObjectAttrs, error)
+}
+
+// gcsStore stores the objects in cloud-storage, with the storage class, KMS
+// key and holds of their project.
+type gcsStore struct {
+	r rvServer
+}
+
+func (s gcsStore) checkBucket(ctx context.Context, bkt string) error {
+	_, err := s.r.sc.Bucket(bkt).Attrs(ctx)
+	return err
+}
+
+func (s gcsStore) attrs(ctx context.Context, bkt, obj string) (*storage.ObjectAttrs, error) {
+	return s.r.sc.Bucket(bkt).Object(obj).Attrs(ctx)
+}
+
+// write writes the object, and then sets the metadata of the generation
+// written, see setStoredMeta.
+func (s gcsStore) write(ctx context.Context, bkt, obj string, req *pb.FileRequest, conds storage.Conditions) (*storage.ObjectAttrs, error) {
+	attrs, err := s.r.fileStore(ctx, bkt, obj, req, conds)
+	if err != nil {
+		return nil, err
+	}
+	return s.r.setStoredMeta(ctx, bkt, obj, attrs, req)
+}
+
+// objects returns the store of the objects of FileUpload.
+func (r rvServer) objects() objectStore {
+	switch {
+	case r.s3 != nil:
+		return s3Objects{s3Store: r.s3, r: r}
+	case r.local != nil:
+		return localObjects{localStore: r.local, r: r}
+	}
+	return gcsStore{r: r}
+}
+
+// backend returns the name of the store of the objects: gcs, s3 or local.
+func (r rvServer) backend() string {
+	switch {
+	case r.s3 != nil:
+		return "s3"
+	case r.local != nil:
+		return "local"
+	}
+	return "gcs"
+}
+
+// needsGCS returns an Unimplemented error of an RPC which only cloud-storage
+// supports, ie: resumable and signed uploads, if the server stores to S3 or
+// locally.
+func (r rvServer) needsGCS(rpc string) error {
+	if b := r.backend(); b != "gcs" {
+		return status.Errorf(codes.Unimplemented, "%s is not supported by the %s backend", rpc, b)
+	}
+	return nil
+}
+
+// checkGCSOnly checks that the config sets none of the options which only
+// cloud-storage supports, for the store of another backend.
+func checkGCSOnly(c *config, backend string) error {
+	var opt string
+	switch {
+	case len(c.Replicas) > 0:
+		opt = "replicas are"
+	case len(c.Failover) > 0:
+		opt = "failover is"
+	case len(c.Retention) > 0:
+		opt = "retention is"
+	case len(c.StorageClasses) > 0:
+		opt = "storage_classes are"
+	case len(c.KMSKeys) > 0:
+		opt = "kms_keys are"
+	case len(c.ConvertNow) > 0:
+		opt = "convert_now is"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s not supported by the %s backend", opt, backend)
+}
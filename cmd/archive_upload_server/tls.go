@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// serverTLS returns the TLS config of the server, for deployments outside of
+// the managed TLS of Cloud Run. With a client CA bundle the server requires
+// mutual TLS, every client must present a certificate signed by one of its CAs.
+func serverTLS(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("set both tls_cert and tls_key")
+	}
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := cr.load(); err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{
+		GetCertificate: cr.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if caFile == "" {
+		return conf, nil
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the client CA bundle: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in the client CA bundle %s", caFile)
+	}
+	conf.ClientCAs = pool
+	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	return conf, nil
+}
+
+// certReloader serves the certificate of a key pair on disk, and reloads it
+// once the certificate file changes, ie: renewed by certbot on a bare VM, so
+// the server need not be restarted.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// load loads the key pair if the certificate file changed since the last
+// load, and returns the current certificate.
+func (c *certReloader) load() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fi, err := os.Stat(c.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the server certificate: %v", err)
+	}
+	if c.cert != nil && fi.ModTime().Equal(c.modTime) {
+		return c.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the server certificate: %v", err)
+	}
+	if c.cert != nil {
+		glog.Infof("Reloaded the server certificate %s", c.certFile)
+	}
+	c.cert, c.modTime = &cert, fi.ModTime()
+	return c.cert, nil
+}
+
+// GetCertificate serves the current certificate. A renewal which fails to load,
+// ie: the key is not written yet, keeps the certificate loaded before.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, err := c.load()
+	if err != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.cert != nil {
+			glog.Errorf("keeping the loaded server certificate: %v", err)
+			return c.cert, nil
+		}
+	}
+	return cert, err
+}
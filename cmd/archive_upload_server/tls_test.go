@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+// selfSigned returns the files of a self-signed PEM certificate and its key.
+func selfSigned(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "rv-server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tempFile(t, "cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyFile := tempFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return cert, keyFile
+}
+
+func TestServerTLS(t *testing.T) {
+	cert, key := selfSigned(t)
+	notPEM := tempFile(t, "ca.pem", []byte("not a certificate"))
+	tests := []struct {
+		desc           string
+		cert, key, ca  string
+		wantClientAuth tls.ClientAuthType
+		wantErr        bool
+	}{{
+		desc:           "TLS",
+		cert:           cert,
+		key:            key,
+		wantClientAuth: tls.NoClientCert,
+	}, {
+		desc:           "mutual TLS",
+		cert:           cert,
+		key:            key,
+		ca:             cert,
+		wantClientAuth: tls.RequireAndVerifyClientCert,
+	}, {
+		desc:    "client CA without a server certificate",
+		ca:      cert,
+		wantErr: true,
+	}, {
+		desc:    "certificate without a key",
+		cert:    cert,
+		wantErr: true,
+	}, {
+		desc:    "bad client CA bundle",
+		cert:    cert,
+		key:     key,
+		ca:      notPEM,
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := serverTLS(test.cert, test.key, test.ca)
+			switch {
+			case err != nil && !test.wantErr:
+				t.Fatalf("serverTLS() = %v; want nil err", err)
+			case err == nil && test.wantErr:
+				t.Fatal("serverTLS(): nil err; want non-nil err")
+			case err != nil:
+				return
+			}
+			if got.ClientAuth != test.wantClientAuth {
+				t.Errorf("serverTLS() ClientAuth = %v; want %v", got.ClientAuth, test.wantClientAuth)
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	cert, key := selfSigned(t)
+	cr := &certReloader{certFile: cert, keyFile: key}
+	first, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() = %v; want nil err", err)
+	}
+
+	// A renewal replaces the key pair in place.
+	renewedCert, renewedKey := selfSigned(t)
+	for src, dst := range map[string]string{renewedCert: cert, renewedKey: key} {
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(dst, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(cert, later, later); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() = %v; want nil err", err)
+	}
+	if bytes.Equal(renewed.Certificate[0], first.Certificate[0]) {
+		t.Error("GetCertificate() = the certificate before the renewal; want the renewed one")
+	}
+
+	// A renewal which fails to load keeps the loaded certificate.
+	if err := ioutil.WriteFile(key, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(cert, later, later); err != nil {
+		t.Fatal(err)
+	}
+	kept, err := cr.GetCertificate(nil)
+	if err != nil || !bytes.Equal(kept.Certificate[0], renewed.Certificate[0]) {
+		t.Errorf("GetCertificate() = %v; want the loaded certificate", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces the storage calls of the server, below the spans of the RPCs.
+// The spans are exported to Cloud Trace with -trace_project, see tracing.
+var tracer = otel.Tracer("github.com/routeviews/google-cloud-storage/cmd/archive_upload_server")
+
+// objectAttrs returns the span attributes of a cloud-storage object.
+func objectAttrs(bkt, obj string) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.String("bucket", bkt), attribute.String("object", obj))
+}
+
+// endSpan records the error of a span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
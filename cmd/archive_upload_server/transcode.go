@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+// gzipEncoding is the Content-Encoding of transcoded objects.
+const gzipEncoding = "gzip"
+
+// textTypes are the content types of the text files which are stored gzip
+// compressed, by the extension of the file compressed.
+var textTypes = map[string]string{
+	".csv":  "text/csv; charset=utf-8",
+	".json": "application/json",
+	".txt":  "text/plain; charset=utf-8",
+	".xml":  "application/xml",
+}
+
+// checkTranscode checks the filename rules of the projects whose gzip
+// compressed text files are transcoded.
+func checkTranscode(rules map[string][]string) error {
+	for proj, patterns := range rules {
+		if pb.FileRequest_Project_value[proj] == int32(pb.FileRequest_UNKNOWN) {
+			return fmt.Errorf("bad project %s of transcode", proj)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("bad transcode pattern %q of %s: %v", p, proj, err)
+			}
+		}
+	}
+	return nil
+}
+
+// transcodes reports whether the object of a file is stored with
+// Content-Encoding: gzip, if the request sets transcode or the basename of
+// the file matches a transcode rule of its project.
+func (r rvServer) transcodes(meta *pb.FileRequest) bool {
+	if meta.GetTranscode() {
+		return true
+	}
+	base := path.Base(meta.GetFilename())
+	for proj, patterns := range r.config().Transcode {
+		if pb.FileRequest_Project_value[proj] != int32(meta.GetProject()) {
+			continue
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, base); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contentHeaders returns the Content-Type and Content-Encoding of the object
+// of a file. A gzip compressed text file which transcodes, see transcodes, is
+// stored with Content-Encoding: gzip and the content type of the text, so
+// cloud-storage decompresses it for the HTTP clients which do not accept
+// gzip. Other files, ie: MRT archives, are stored as they are, with the
+// content type of their extension and no encoding, as the server and the
+// converter read them compressed.
+func (r rvServer) contentHeaders(meta *pb.FileRequest, obj string) (string, string) {
+	ct := contentType(obj)
+	if !strings.EqualFold(path.Ext(obj), ".gz") || !r.transcodes(meta) {
+		return ct, ""
+	}
+	text, ok := textTypes[strings.ToLower(path.Ext(strings.TrimSuffix(obj, path.Ext(obj))))]
+	if !ok {
+		return ct, ""
+	}
+	return text, gzipEncoding
+}
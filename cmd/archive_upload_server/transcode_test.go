@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+func TestContentHeaders(t *testing.T) {
+	r := rvServer{conf: &config{Transcode: map[string][]string{
+		"RPKI_RARC": {"*.json.gz", "*.csv.gz"},
+	}}}
+	tests := []struct {
+		desc     string
+		req      *pb.FileRequest
+		obj      string
+		wantType string
+		wantEnc  string
+	}{{
+		desc:     "not transcoded",
+		req:      &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS},
+		obj:      "notes.json.gz",
+		wantType: "application/gzip",
+	}, {
+		desc:     "requested",
+		req:      &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Transcode: true},
+		obj:      "notes.json.gz",
+		wantType: "application/json",
+		wantEnc:  "gzip",
+	}, {
+		desc:     "rule of the project",
+		req:      &pb.FileRequest{Project: pb.FileRequest_RPKI_RARC, Filename: "2022/01/09/roas.csv.gz"},
+		obj:      "rpki/2022/01/09/roas.csv.gz",
+		wantType: "text/csv; charset=utf-8",
+		wantEnc:  "gzip",
+	}, {
+		desc:     "rule of another project",
+		req:      &pb.FileRequest{Project: pb.FileRequest_RIPE_RIS, Filename: "rrc00/roas.csv.gz"},
+		obj:      "rrc00/roas.csv.gz",
+		wantType: "application/gzip",
+	}, {
+		desc:     "MRT archive",
+		req:      &pb.FileRequest{Project: pb.FileRequest_RIPE_RIS, Transcode: true},
+		obj:      "rrc00/2022.01/updates.20220109.1830.gz",
+		wantType: "application/gzip",
+	}, {
+		desc:     "not gzip",
+		req:      &pb.FileRequest{Project: pb.FileRequest_ROUTEVIEWS, Transcode: true},
+		obj:      "updates.20211212.0015.bz2",
+		wantType: "application/x-bzip2",
+	}}
+	for _, test := range tests {
+		gotType, gotEnc := r.contentHeaders(test.req, test.obj)
+		if gotType != test.wantType || gotEnc != test.wantEnc {
+			t.Errorf("%s: contentHeaders(%q) = %q, %q; want %q, %q", test.desc, test.obj, gotType, gotEnc, test.wantType, test.wantEnc)
+		}
+	}
+}
+
+func TestCheckTranscode(t *testing.T) {
+	if err := checkTranscode(map[string][]string{"RPKI_RARC": {"*.json.gz"}}); err != nil {
+		t.Errorf("checkTranscode(RPKI_RARC) got err: %v; want nil err", err)
+	}
+	if err := checkTranscode(map[string][]string{"RPKI": {"*.json.gz"}}); err == nil {
+		t.Error("checkTranscode(RPKI) got nil err, want err")
+	}
+	if err := checkTranscode(map[string][]string{"RPKI_RARC": {"[.json.gz"}}); err == nil {
+		t.Error("checkTranscode([.json.gz) got nil err, want err")
+	}
+}
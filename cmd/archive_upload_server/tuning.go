@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// tuning is the transport tuning of the server, from the flags. A zero
+// duration or limit keeps the grpc default.
+type tuning struct {
+	// maxMsgBytes is the message size limit, clients must not send larger
+	// messages. Decoded content is limited to it as well.
+	maxMsgBytes int
+	// maxStreams limits the concurrent streams of each connection.
+	maxStreams  uint32
+	keepalive   keepalive.ServerParameters
+	enforcement keepalive.EnforcementPolicy
+}
+
+// check checks the tuning is consistent.
+func (t tuning) check() error {
+	if t.maxMsgBytes < 1 {
+		return fmt.Errorf("bad max_msg_bytes(%d): must be positive", t.maxMsgBytes)
+	}
+	ka := t.keepalive
+	if ka.Time < 0 || ka.Timeout < 0 || ka.MaxConnectionIdle < 0 || ka.MaxConnectionAge < 0 || ka.MaxConnectionAgeGrace < 0 || t.enforcement.MinTime < 0 {
+		return errors.New("bad keepalive: durations must not be negative")
+	}
+	// Connections are recycled with uploads in flight otherwise.
+	if ka.MaxConnectionAge > 0 && ka.MaxConnectionAgeGrace == 0 {
+		return errors.New("bad keepalive: max_connection_age needs max_connection_age_grace, to let uploads finish")
+	}
+	return nil
+}
+
+// options returns the server options of the tuning.
+func (t tuning) options() []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(t.maxMsgBytes),
+		grpc.MaxSendMsgSize(t.maxMsgBytes),
+		grpc.KeepaliveParams(t.keepalive),
+		grpc.KeepaliveEnforcementPolicy(t.enforcement),
+	}
+	if t.maxStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(t.maxStreams))
+	}
+	return opts
+}
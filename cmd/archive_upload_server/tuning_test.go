@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/keepalive"
+)
+
+func TestTuningCheck(t *testing.T) {
+	tests := []struct {
+		desc    string
+		tune    tuning
+		wantErr bool
+	}{{
+		desc: "defaults",
+		tune: tuning{maxMsgBytes: maxMsgSize},
+	}, {
+		desc: "tuned",
+		tune: tuning{
+			maxMsgBytes: 2 * maxMsgSize,
+			maxStreams:  100,
+			keepalive: keepalive.ServerParameters{
+				Time:                  time.Minute,
+				Timeout:               10 * time.Second,
+				MaxConnectionAge:      time.Hour,
+				MaxConnectionAgeGrace: 30 * time.Minute,
+			},
+			enforcement: keepalive.EnforcementPolicy{MinTime: 30 * time.Second},
+		},
+	}, {
+		desc:    "no message size limit",
+		tune:    tuning{},
+		wantErr: true,
+	}, {
+		desc: "negative keepalive",
+		tune: tuning{
+			maxMsgBytes: maxMsgSize,
+			keepalive:   keepalive.ServerParameters{Time: -time.Minute},
+		},
+		wantErr: true,
+	}, {
+		desc: "max connection age without grace",
+		tune: tuning{
+			maxMsgBytes: maxMsgSize,
+			keepalive:   keepalive.ServerParameters{MaxConnectionAge: time.Hour},
+		},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		err := test.tune.check()
+		switch {
+		case test.wantErr && err == nil:
+			t.Errorf("[%s]: check() got nil err; want non-nil err", test.desc)
+		case !test.wantErr && err != nil:
+			t.Errorf("[%s]: check() got err: %v; want nil err", test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+	"google.golang.org/api/bigquery/v2"
+)
+
+// uploadLogTimeout bounds the insert of the row of an upload, the upload
+// succeeded already and is not held up for long.
+const uploadLogTimeout = 10 * time.Second
+
+// uploadTablePattern matches a BigQuery table, <project>.<dataset>.<table>.
+var uploadTablePattern = regexp.MustCompile(`^([^.]+)\.(\w+)\.(\w+)$`)
+
+// uploadRow is the row of the upload log of an object stored. The table
+// has the schema:
+//
+//	bucket:STRING, object:STRING, project:STRING, size:INTEGER,
+//	md5:STRING, generation:INTEGER, capture_time:TIMESTAMP,
+//	uploader:STRING, upload_time:TIMESTAMP
+//
+// capture_time is null for the files without one, ie: RPKI_RARC latest/.
+type uploadRow struct {
+	Bucket      string `json:"bucket"`
+	Object      string `json:"object"`
+	Project     string `json:"project"`
+	Size        int64  `json:"size"`
+	MD5         string `json:"md5"`
+	Generation  int64  `json:"generation"`
+	CaptureTime string `json:"capture_time,omitempty"`
+	Uploader    string `json:"uploader,omitempty"`
+	UploadTime  string `json:"upload_time"`
+}
+
+// uploadLogFunc inserts the row of an upload into the upload log, id
+// deduplicates the retried inserts.
+type uploadLogFunc func(ctx context.Context, id string, row *uploadRow) error
+
+// newUploadLog returns an uploadLogFunc to a BigQuery table,
+// <project>.<dataset>.<table>, which the rows are streamed into.
+func newUploadLog(ctx context.Context, table string) (uploadLogFunc, error) {
+	m := uploadTablePattern.FindStringSubmatch(table)
+	if m == nil {
+		return nil, fmt.Errorf("bad table %q, want <project>.<dataset>.<table>", table)
+	}
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery.NewService: %v", err)
+	}
+	return func(ctx context.Context, id string, row *uploadRow) error {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		values := map[string]bigquery.JsonValue{}
+		if err := json.Unmarshal(b, &values); err != nil {
+			return err
+		}
+		resp, err := svc.Tabledata.InsertAll(m[1], m[2], m[3], &bigquery.TableDataInsertAllRequest{
+			Rows: []*bigquery.TableDataInsertAllRequestRows{{InsertId: id, Json: values}},
+		}).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if len(resp.InsertErrors) == 0 {
+			return nil
+		}
+		var msgs []string
+		for _, e := range resp.InsertErrors[0].Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("row rejected: %s", strings.Join(msgs, "; "))
+	}, nil
+}
+
+// logUpload inserts the row of an object stored into the upload log, if
+// r.uploadLog is set. A failure is logged, the object is stored regardless;
+// the log is a record of the uploads, the bucket is the archive.
+func (r rvServer) logUpload(ctx context.Context, proj string, attrs *storage.ObjectAttrs) {
+	if r.uploadLog == nil || attrs == nil {
+		return
+	}
+	uploaded := attrs.Updated
+	if uploaded.IsZero() {
+		uploaded = time.Now()
+	}
+	md5sum := uploadutils.ChecksumFromAttrs(uploadutils.MD5, attrs)
+	row := &uploadRow{
+		Bucket:      attrs.Bucket,
+		Object:      attrs.Name,
+		Project:     proj,
+		Size:        attrs.Size,
+		MD5:         md5sum,
+		Generation:  attrs.Generation,
+		CaptureTime: attrs.Metadata[uploadutils.CaptureTimeMetadataKey],
+		Uploader:    attrs.Metadata[uploadutils.UploaderMetadataKey],
+		UploadTime:  uploaded.UTC().Format(time.RFC3339Nano),
+	}
+	// The S3 objects have no generations, the md5sum tells their writes
+	// apart.
+	id := fmt.Sprintf("%s/%s#%d:%s", attrs.Bucket, attrs.Name, attrs.Generation, md5sum)
+	ctx, span := tracer.Start(ctx, "bigquery.insert", objectAttrs(attrs.Bucket, attrs.Name))
+	ctx, cancel := context.WithTimeout(ctx, uploadLogTimeout)
+	defer cancel()
+	err := r.uploadLog(ctx, id, row)
+	endSpan(span, err)
+	if err != nil {
+		glog.Errorf("failed to log the upload of %s/%s: %v", attrs.Bucket, attrs.Name, err)
+	}
+}
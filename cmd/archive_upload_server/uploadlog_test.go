@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+	uploadutils "github.com/routeviews/google-cloud-storage/pkg/utils/upload"
+)
+
+func TestNewUploadLogBadTable(t *testing.T) {
+	for _, table := range []string{
+		"uploads",
+		"archive.uploads",
+		"routeviews.archive.",
+		"routeviews.archive.uploads.extra",
+		"projects/routeviews/datasets/archive/tables/uploads",
+	} {
+		if _, err := newUploadLog(context.Background(), table); err == nil {
+			t.Errorf("newUploadLog(%q) got nil err, want err", table)
+		}
+	}
+}
+
+func TestLogUpload(t *testing.T) {
+	md5Sum, _ := hex.DecodeString("50e3903156f5d2dac6c9f89626d48c75")
+	updated := time.Date(2021, 9, 1, 0, 20, 3, 0, time.UTC)
+	attrs := &storage.ObjectAttrs{
+		Bucket:     "foo",
+		Name:       "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+		MD5:        md5Sum,
+		Size:       11,
+		Generation: 42,
+		Updated:    updated,
+		Metadata: map[string]string{
+			uploadutils.CaptureTimeMetadataKey: "2021-09-01T00:00:00Z",
+			uploadutils.UploaderMetadataKey:    "rv-mirror@routeviews.iam.gserviceaccount.com",
+		},
+	}
+
+	tests := []struct {
+		desc       string
+		attrs      *storage.ObjectAttrs
+		err        error
+		wantID     string
+		wantRow    *uploadRow
+		wantCalled bool
+	}{{
+		desc:       "logged",
+		attrs:      attrs,
+		wantCalled: true,
+		wantID:     "foo/route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2#42:50e3903156f5d2dac6c9f89626d48c75",
+		wantRow: &uploadRow{
+			Bucket:      "foo",
+			Object:      "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+			Project:     "ROUTEVIEWS",
+			Size:        11,
+			MD5:         "50e3903156f5d2dac6c9f89626d48c75",
+			Generation:  42,
+			CaptureTime: "2021-09-01T00:00:00Z",
+			Uploader:    "rv-mirror@routeviews.iam.gserviceaccount.com",
+			UploadTime:  "2021-09-01T00:20:03Z",
+		},
+	}, {
+		desc:       "no capture time",
+		attrs:      &storage.ObjectAttrs{Bucket: "foo", Name: "latest/roas.json", MD5: md5Sum, Size: 11, Generation: 7, Updated: updated},
+		wantCalled: true,
+		wantID:     "foo/latest/roas.json#7:50e3903156f5d2dac6c9f89626d48c75",
+		wantRow: &uploadRow{
+			Bucket:     "foo",
+			Object:     "latest/roas.json",
+			Project:    "ROUTEVIEWS",
+			Size:       11,
+			MD5:        "50e3903156f5d2dac6c9f89626d48c75",
+			Generation: 7,
+			UploadTime: "2021-09-01T00:20:03Z",
+		},
+	}, {
+		// A failure is only logged.
+		desc:       "insert failure",
+		attrs:      attrs,
+		err:        errors.New("unavailable"),
+		wantCalled: true,
+	}, {
+		desc:  "no attributes",
+		attrs: nil,
+	}}
+	for _, test := range tests {
+		var called bool
+		r := rvServer{uploadLog: func(ctx context.Context, id string, row *uploadRow) error {
+			called = true
+			if test.wantRow != nil {
+				if id != test.wantID {
+					t.Errorf("%s: logUpload() got insert id %q, want %q", test.desc, id, test.wantID)
+				}
+				if diff := cmp.Diff(row, test.wantRow); diff != "" {
+					t.Errorf("%s: logUpload() inserted unexpected row (-got +want):\n%s", test.desc, diff)
+				}
+			}
+			return test.err
+		}}
+		r.logUpload(context.Background(), "ROUTEVIEWS", test.attrs)
+		if called != test.wantCalled {
+			t.Errorf("%s: logUpload() inserted = %v, want %v", test.desc, called, test.wantCalled)
+		}
+	}
+
+	// The log is disabled without a table.
+	rvServer{}.logUpload(context.Background(), "ROUTEVIEWS", attrs)
+}
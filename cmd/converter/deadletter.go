@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"cloud.google.com/go/storage"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/pubsub/v1"
+)
+
+// failedEventType is the eventType attribute of the dead letters published to
+// a topic, the other attributes match those of cloud-storage notifications.
+const failedEventType = "RV_CONVERSION_FAILED"
+
+// topicPattern matches the name of a Pub/Sub topic.
+var topicPattern = regexp.MustCompile(`^projects/[^/]+/topics/[^/]+$`)
+
+// conversionFailure is the dead letter of an archive whose conversion failed
+// for good, JSON encoded.
+type conversionFailure struct {
+	Bucket    string    `json:"bucket"`
+	Object    string    `json:"object"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	MessageID string    `json:"messageId"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+// deadLetterFunc reports a conversion failure to the dead letters.
+type deadLetterFunc func(ctx context.Context, f *conversionFailure) error
+
+// newDeadLetter returns the deadLetterFunc of a destination: a Pub/Sub topic,
+// projects/<project>/topics/<topic>, or a gs://<bucket>/<prefix> the failures
+// are written under as <prefix><object>.json.
+func newDeadLetter(ctx context.Context, gcsCli *storage.Client, dst string) (deadLetterFunc, error) {
+	if topicPattern.MatchString(dst) {
+		svc, err := pubsub.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("pubsub.NewService: %v", err)
+		}
+		return func(ctx context.Context, f *conversionFailure) error {
+			data, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			_, err = svc.Projects.Topics.Publish(dst, &pubsub.PublishRequest{
+				Messages: []*pubsub.PubsubMessage{{
+					Data: base64.StdEncoding.EncodeToString(data),
+					Attributes: map[string]string{
+						"bucketId":  f.Bucket,
+						"objectId":  f.Object,
+						"eventType": failedEventType,
+					},
+				}},
+			}).Context(ctx).Do()
+			return err
+		}, nil
+	}
+	bkt, prefix, err := parseGCSPrefix(dst)
+	if err != nil {
+		return nil, fmt.Errorf("bad dead letter destination(%q), want projects/<project>/topics/<topic> or gs://<bucket>/<prefix>", dst)
+	}
+	return gcsDeadLetter(gcsCli, bkt, prefix), nil
+}
+
+// gcsDeadLetter returns a deadLetterFunc writing the failures to a bucket,
+// the failure of each archive overwrites any earlier one.
+func gcsDeadLetter(gcsCli *storage.Client, bkt, prefix string) deadLetterFunc {
+	return func(ctx context.Context, f *conversionFailure) error {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		w := gcsCli.Bucket(bkt).Object(prefix + f.Object + ".json").NewWriter(ctx)
+		w.ContentType = "application/json"
+		if _, err := w.Write(data); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+}
+
+// retry reports whether a failed conversion is left to Pub/Sub to deliver
+// again rather than dead-lettered. An attempt of 0 is of a subscription which
+// does not count the deliveries, and a permanent error fails the same way
+// again, the failure is dead-lettered at once.
+func (s *server) retry(attempt int, err error) bool {
+	return s.deadLetter != nil && converter.Retryable(err) && attempt > 0 && attempt < s.maxAttempts
+}
+
+// fail dead-letters a conversion failure and marks the archive, so it is not
+// converted again until the mark is cleared.
+func (s *server) fail(ctx context.Context, msg *gcsPubSubEvent, cause error) error {
+	attrs := msg.Message.Attributes
+	err := s.deadLetter(ctx, &conversionFailure{
+		Bucket:    attrs.Bucket,
+		Object:    attrs.Object,
+		Error:     cause.Error(),
+		Attempts:  msg.DeliveryAttempt,
+		MessageID: msg.Message.MessageID,
+		FailedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot dead-letter gs://%s/%s: %v", attrs.Bucket, attrs.Object, err)
+	}
+	if err := converter.MarkFailed(ctx, s.gcsCli, attrs.Bucket, attrs.Object, cause); err != nil {
+		// The dead letter is out, the next failure only duplicates it.
+		log.Errorf("converter.MarkFailed: %v", err)
+	}
+	return nil
+}
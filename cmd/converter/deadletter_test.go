@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	"google.golang.org/api/googleapi"
+)
+
+// makeFakeAttemptMsg makes a fake notification of a metadata update, on its
+// attempt-th delivery.
+func makeFakeAttemptMsg(object, bucket string, attempt int) string {
+	msg := makeFakeMsgFormat("OBJECT_METADATA_UPDATE", object, bucket)
+	return strings.Replace(msg, `"subscription":`, `"deliveryAttempt": `+strconv.Itoa(attempt)+`, "subscription":`, 1)
+}
+
+func TestNewDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	gcs := fakestorage.NewServer(nil)
+	t.Cleanup(gcs.Stop)
+	for _, dst := range []string{"", "dead-letters", "projects/routeviews/topics", "gs://"} {
+		if _, err := newDeadLetter(ctx, gcs.Client(), dst); err == nil {
+			t.Errorf("newDeadLetter(%q) got nil err, want err", dst)
+		}
+	}
+	if _, err := newDeadLetter(ctx, gcs.Client(), "gs://dead-letters/converter"); err != nil {
+		t.Errorf("newDeadLetter() of a GCS prefix got err: %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := &googleapi.Error{Code: 503}
+	s := &server{deadLetter: gcsDeadLetter(nil, "dead-letters", ""), maxAttempts: 3}
+	tests := []struct {
+		desc    string
+		s       *server
+		attempt int
+		err     error
+		want    bool
+	}{
+		{desc: "transient error before the last attempt", s: s, attempt: 2, err: unavailable, want: true},
+		{desc: "transient error on the last attempt", s: s, attempt: 3, err: unavailable},
+		{desc: "deliveries not counted", s: s, err: unavailable},
+		{desc: "permanent error", s: s, attempt: 1, err: errors.New("cannot decompress archive")},
+		{desc: "no dead letters", s: &server{maxAttempts: 3}, attempt: 1, err: unavailable},
+	}
+	for _, test := range tests {
+		if got := test.s.retry(test.attempt, test.err); got != test.want {
+			t.Errorf("retry(%d, %v) of %s = %v, want %v", test.attempt, test.err, test.desc, got, test.want)
+		}
+	}
+}
+
+func TestArchiveUploadHandleDeadLetter(t *testing.T) {
+	const object = "route-views4/bgpdata/updates/2021.12/updates.20211212.0015.bz2"
+	tests := []struct {
+		desc     string
+		attempt  int
+		metadata map[string]string
+		wantCode int
+		// wantFailed is whether the archive is dead-lettered and marked.
+		wantFailed bool
+	}{
+		{
+			desc:       "permanent error dead-lettered at once",
+			attempt:    1,
+			wantCode:   http.StatusOK,
+			wantFailed: true,
+		}, {
+			desc:       "dead-lettered on the last attempt",
+			attempt:    3,
+			wantCode:   http.StatusOK,
+			wantFailed: true,
+		}, {
+			desc:       "dead-lettered at once without delivery attempts",
+			wantCode:   http.StatusOK,
+			wantFailed: true,
+		}, {
+			desc:     "skipped when marked as failed",
+			attempt:  3,
+			metadata: map[string]string{converter.FailedMetadataKey: "earlier failure"},
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			fakegcs := fakestorage.NewServer([]fakestorage.Object{{
+				// The project source is missing, the conversion fails.
+				ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "src-bucket", Name: object, Metadata: test.metadata},
+				Content:     []byte{1, 2, 3, 4},
+			}})
+			fakegcs.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "dst-bucket"})
+			fakegcs.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "dead-letters"})
+			t.Cleanup(fakegcs.Stop)
+			server := &server{
+				gcsCli:      fakegcs.Client(),
+				dstBucket:   "dst-bucket",
+				deadLetter:  gcsDeadLetter(fakegcs.Client(), "dead-letters", "converter/"),
+				maxAttempts: 3,
+			}
+
+			req, err := http.NewRequest("POST", "/", bytes.NewBufferString(makeFakeAttemptMsg(object, "src-bucket", test.attempt)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(server.archiveUploadHandler).ServeHTTP(rr, req)
+			if rr.Code != test.wantCode {
+				t.Errorf("archiveUploadHandler() got code %d, want %d", rr.Code, test.wantCode)
+			}
+
+			dl, err := fakegcs.GetObject("dead-letters", "converter/"+object+".json")
+			if gotFailed := err == nil; gotFailed != test.wantFailed {
+				t.Fatalf("archiveUploadHandler() dead-lettered: %v, want %v", gotFailed, test.wantFailed)
+			}
+			if !test.wantFailed {
+				return
+			}
+			var f conversionFailure
+			if err := json.Unmarshal(dl.Content, &f); err != nil {
+				t.Fatalf("failed to decode the dead letter: %v", err)
+			}
+			if f.Bucket != "src-bucket" || f.Object != object || f.Attempts != test.attempt || f.Error == "" {
+				t.Errorf("archiveUploadHandler() got dead letter %+v", f)
+			}
+			src, err := fakegcs.GetObject("src-bucket", object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if src.Metadata[converter.FailedMetadataKey] == "" {
+				t.Errorf("archiveUploadHandler() did not mark the archive, got metadata %v", src.Metadata)
+			}
+		})
+	}
+}
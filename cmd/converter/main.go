@@ -3,23 +3,168 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	resourceusage "github.com/routeviews/google-cloud-storage/pkg/resource_usage"
+	storagetier "github.com/routeviews/google-cloud-storage/pkg/storage_tier"
 	log "github.com/sirupsen/logrus"
 
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
 	"cloud.google.com/go/storage"
 )
 
-var isDebug = flag.Bool("debug", false, "Debug mode - more verbose logging.")
+var (
+	isDebug    = flag.Bool("debug", false, "Debug mode - more verbose logging.")
+	coldPolicy = flag.String("cold_policy", "read",
+		"How to read archives in Nearline/Coldline/Archive storage: read, reject or restore.")
+	usageInterval = flag.Duration("usage_interval", 10*time.Minute,
+		"Interval to log the resource usage of the server at, 0 disables it.")
+	parquetOutput = flag.String("parquet_output", "",
+		"gs://<bucket>/<prefix> to write the converted records to as Parquet files as well, partitioned by collector and date; empty writes none.")
+	avroOutput = flag.String("avro_output", "",
+		"gs://<bucket>/<prefix> to write the converted records to as Avro files as well, partitioned by collector and date; empty writes none.")
+	avroSchemaIDs = flag.String("avro_schema_ids", "",
+		"Schema registry IDs to stamp the Avro files with, by kind, ie: updates=12,ribs=13.")
+	jsonOutput = flag.Bool("json", true,
+		"Write the JSONL records of the BigQuery load, false writes only the Parquet or Avro files.")
+	bigqueryDataset = flag.String("bigquery_dataset", "",
+		"<project>.<dataset> to write the converted records to with the BigQuery Storage Write API, rather than the JSONL records of the load; empty writes none.")
+	bigqueryTables = flag.String("bigquery_tables", "",
+		"YAML file of the datasets, tables and partitioning of the Storage Write API output of each project, rather than -bigquery_dataset.")
+	migrateClustering = flag.Bool("migrate_clustering", false,
+		"Cluster the BigQuery tables which exist already, recluster their rows, and exit.")
+	deadLetter = flag.String("dead_letter", "",
+		"projects/<project>/topics/<topic> or gs://<bucket>/<prefix> to report the archives whose conversion failed max_attempts times to, and mark them failed; empty retries none.")
+	maxAttempts = flag.Int("max_attempts", 5,
+		"Deliveries of a notification before its failed conversion is dead-lettered, as counted by the subscription.")
+	conversionAttempts = flag.Int("conversion_attempts", converter.DefaultRetryPolicy.Attempts,
+		"Attempts of a conversion which fails with a transient GCS or BigQuery error, within a delivery.")
+	workers = flag.Int("workers", runtime.NumCPU(),
+		"Conversions at once, the other notifications wait their turn; set the concurrency of the service above it.")
+	memoryBudget = flag.Int64("memory_budget", 0,
+		"Bytes of memory of the conversions at once, estimated by the size of their archives; 0 is unlimited.")
+	summaryInterval = flag.Duration("summary_interval", 10*time.Minute,
+		"Interval to log the summaries of the conversions by collector and type at, 0 disables them; the metrics are served at /metrics regardless.")
+	retryBackoff = flag.Duration("retry_backoff", converter.DefaultRetryPolicy.InitialBackoff,
+		"Wait before the second attempt of a conversion, doubled before each later one, jittered.")
+)
 
 type server struct {
 	gcsCli    *storage.Client
 	dstBucket string
+	// coldPolicy decides how archives in a cold storage class are read.
+	coldPolicy storagetier.Policy
+	// parquetBucket and parquetPrefix are where the records are written as
+	// Parquet files, none if the bucket is empty.
+	parquetBucket string
+	parquetPrefix string
+	// avroBucket and avroPrefix are where the records are written as Avro
+	// files, none if the bucket is empty, stamped with avroSchemaIDs.
+	avroBucket    string
+	avroPrefix    string
+	avroSchemaIDs map[string]int
+	// noJSON skips the JSONL records, for the Parquet or Avro files only.
+	noJSON bool
+	// bq writes the records to the bqTables of the project of each archive,
+	// with the Storage Write API, if it is set.
+	bq       *managedwriter.Client
+	bqTables *converter.Tables
+	// deadLetter reports the conversions which failed maxAttempts times, if
+	// it is set; the earlier failures are retried.
+	deadLetter  deadLetterFunc
+	maxAttempts int
+	// retryPolicy bounds the attempts of a conversion within a delivery,
+	// nil is the default policy.
+	retryPolicy *converter.RetryPolicy
+	// pool bounds the conversions at once, nil is unlimited.
+	pool *pool
+	// metrics record the conversions, if set.
+	metrics *conversionMetrics
+}
+
+// parseDataset parses a <project>.<dataset> name.
+func parseDataset(s string) (string, string, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("bad dataset(%q), want <project>.<dataset>", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseGCSPrefix parses a gs://<bucket>/<prefix> URL, the prefix may be empty.
+func parseGCSPrefix(u string) (string, string, error) {
+	rest := strings.TrimPrefix(u, "gs://")
+	if rest == u || rest == "" || strings.HasPrefix(rest, "/") {
+		return "", "", fmt.Errorf("bad GCS URL(%q), want gs://<bucket>/<prefix>", u)
+	}
+	bkt, prefix := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		bkt, prefix = rest[:i], rest[i+1:]
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return bkt, prefix, nil
+}
+
+// parseSchemaIDs parses the schema registry IDs of the kinds of records, ie:
+// updates=12,ribs=13.
+func parseSchemaIDs(s string) (map[string]int, error) {
+	ids := map[string]int{}
+	for _, kv := range strings.Split(s, ",") {
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("bad schema ID(%q), want <kind>=<id>", kv)
+		}
+		id, err := strconv.Atoi(parts[1])
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("bad schema ID(%q) of %s", parts[1], parts[0])
+		}
+		ids[parts[0]] = id
+	}
+	return ids, nil
+}
+
+// loadTables returns the Tables of the Storage Write API output: those of the
+// YAML file if it is set, or the default tables of a <project>.<dataset>, nil
+// if neither is set.
+func loadTables(dataset, file string) (*converter.Tables, error) {
+	switch {
+	case file != "":
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		t, err := converter.ParseTables(b)
+		if err != nil {
+			return nil, fmt.Errorf("bad bigquery_tables %s: %v", file, err)
+		}
+		return t, nil
+	case dataset != "":
+		project, ds, err := parseDataset(dataset)
+		if err != nil {
+			return nil, fmt.Errorf("bad bigquery_dataset: %v", err)
+		}
+		return &converter.Tables{Default: converter.TableConfig{Project: project, Dataset: ds}}, nil
+	}
+	return nil, nil
 }
 
 func newServer(ctx context.Context, cli *storage.Client, dstBucket string) (*server, error) {
@@ -45,11 +190,15 @@ type gcsPubSubEvent struct {
 		MessageID string `json:"messageId"`
 	} `json:"message"`
 	Subscription string `json:"subscription"`
+	// DeliveryAttempt is set by the subscriptions with a dead letter policy,
+	// 0 otherwise.
+	DeliveryAttempt int `json:"deliveryAttempt"`
 }
 
 // archiveUploadHandler handles any new object changes from the archive bucket.
 // It will not return an HTTP error because all errrors are fatal and should
-// not be retried.
+// not be retried, unless failures are dead-lettered: a failed conversion is
+// then retried until its last attempt, and dead-lettered.
 func (s *server) archiveUploadHandler(w http.ResponseWriter, r *http.Request) {
 	var msg gcsPubSubEvent
 	body, err := ioutil.ReadAll(r.Body)
@@ -65,36 +214,101 @@ func (s *server) archiveUploadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// The archive server will set metadata of project source after the object
 	// is created, so we will look for metadata update messages instead of
-	// object creations.
-	if msg.Message.Attributes.EventType != "OBJECT_METADATA_UPDATE" {
-		log.Infof("Skipped non-'OBJECT_METADATA_UPDATE' msg: id %s, type %s", msg.Message.MessageID, msg.Message.Attributes.EventType)
+	// object creations. The notifications of the archive server itself are
+	// published once the object and its metadata are stored.
+	switch msg.Message.Attributes.EventType {
+	case "OBJECT_METADATA_UPDATE", "RV_UPLOAD":
+	default:
+		log.Infof("Skipped non-'OBJECT_METADATA_UPDATE' or 'RV_UPLOAD' msg: id %s, type %s", msg.Message.MessageID, msg.Message.Attributes.EventType)
+		return
+	}
+
+	var size int64
+	if s.pool.budgeted() {
+		size = archiveSize(r.Context(), s.gcsCli, msg.Message.Attributes.Bucket, msg.Message.Attributes.Object)
+	}
+	queued := time.Now()
+	release, err := s.pool.acquire(r.Context(), size)
+	if err != nil {
+		// Delivered again once the request is gone, ie: timed out in the
+		// queue.
+		log.WithFields(log.Fields{
+			"object":    msg.Message.Attributes.Object,
+			"messageID": msg.Message.MessageID,
+		}).Warnf("no worker for the conversion: %v", err)
+		http.Error(w, fmt.Sprintf("no worker: %v", err), http.StatusServiceUnavailable)
 		return
 	}
+	defer release()
 
 	log.WithFields(log.Fields{
 		"bucket":    msg.Message.Attributes.Bucket,
 		"object":    msg.Message.Attributes.Object,
 		"messageID": msg.Message.MessageID,
+		"queued":    time.Since(queued).String(),
 	}).Info("Converting archive")
+	stats := &converter.Stats{}
+	start := time.Now()
 	err = converter.ProcessMRTArchive(r.Context(), s.gcsCli, &converter.Config{
-		SrcBucket: msg.Message.Attributes.Bucket,
-		SrcObject: msg.Message.Attributes.Object,
-		DstBucket: s.dstBucket,
+		SrcBucket:      msg.Message.Attributes.Bucket,
+		SrcObject:      msg.Message.Attributes.Object,
+		DstBucket:      s.dstBucket,
+		ColdPolicy:     s.coldPolicy,
+		ParquetBucket:  s.parquetBucket,
+		ParquetPrefix:  s.parquetPrefix,
+		AvroBucket:     s.avroBucket,
+		AvroPrefix:     s.avroPrefix,
+		AvroSchemaIDs:  s.avroSchemaIDs,
+		NoJSON:         s.noJSON,
+		BigQuery:       s.bq,
+		BigQueryTables: s.bqTables,
+		Retry:          s.retryPolicy,
+		Stats:          stats,
 	})
+	if !stats.Skipped && !errors.Is(err, converter.ErrMarkedFailed) {
+		s.metrics.observe(stats, time.Since(start), err)
+	}
+	if errors.Is(err, converter.ErrMarkedFailed) {
+		log.WithFields(log.Fields{
+			"bucket": msg.Message.Attributes.Bucket,
+			"object": msg.Message.Attributes.Object,
+		}).Info("Skipped archive marked as failed")
+		return
+	}
 	if err != nil {
 		log.WithFields(log.Fields{
 			"dstBucket": s.dstBucket,
 			"object":    msg.Message.Attributes.Object,
+			"attempt":   msg.DeliveryAttempt,
 		}).Errorf("converter.ProcessMRTArchive: %v", err)
+		if s.retry(msg.DeliveryAttempt, err) {
+			http.Error(w, fmt.Sprintf("converter.ProcessMRTArchive: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if s.deadLetter != nil {
+			if err := s.fail(r.Context(), &msg, err); err != nil {
+				// Delivered again, the dead letter may go through then.
+				log.Error(err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
 		w.Write([]byte(fmt.Sprintf("converter.ProcessMRTArchive: %v", err)))
 		return
 	}
-	log.WithFields(log.Fields{
+	fields := log.Fields{
 		"bucket":    msg.Message.Attributes.Bucket,
 		"dstBucket": s.dstBucket,
 		"object":    msg.Message.Attributes.Object,
 		"messageID": msg.Message.MessageID,
-	}).Info("Archive converted")
+	}
+	// Usage is process wide, it covers the concurrent conversions too.
+	if u, err := resourceusage.Snapshot(); err == nil {
+		for k, v := range u.Fields() {
+			fields[k] = v
+		}
+	}
+	log.WithFields(fields).Info("Archive converted")
 }
 
 func main() {
@@ -104,6 +318,8 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 	log.SetFormatter(&log.JSONFormatter{})
+	// The backoffs of the retries are jittered apart across the instances.
+	rand.Seed(time.Now().UnixNano())
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -119,7 +335,76 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if srvr.coldPolicy, err = storagetier.ParsePolicy(*coldPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if *parquetOutput != "" {
+		if srvr.parquetBucket, srvr.parquetPrefix, err = parseGCSPrefix(*parquetOutput); err != nil {
+			log.Fatalf("bad parquet_output: %v", err)
+		}
+	}
+	if *avroOutput != "" {
+		if srvr.avroBucket, srvr.avroPrefix, err = parseGCSPrefix(*avroOutput); err != nil {
+			log.Fatalf("bad avro_output: %v", err)
+		}
+	}
+	if srvr.avroSchemaIDs, err = parseSchemaIDs(*avroSchemaIDs); err != nil {
+		log.Fatalf("bad avro_schema_ids: %v", err)
+	}
+	if srvr.bqTables, err = loadTables(*bigqueryDataset, *bigqueryTables); err != nil {
+		log.Fatal(err)
+	}
+	if srvr.bqTables != nil {
+		project := srvr.bqTables.Default.Project
+		bq, err := bigquery.NewClient(ctx, project)
+		if err != nil {
+			log.Fatalf("bigquery.NewClient: %v", err)
+		}
+		if *migrateClustering {
+			if err := converter.MigrateClustering(ctx, bq, srvr.bqTables); err != nil {
+				log.Fatalf("converter.MigrateClustering: %v", err)
+			}
+			log.Info("Tables reclustered")
+			return
+		}
+		if err := converter.EnsureTables(ctx, bq, srvr.bqTables); err != nil {
+			log.Fatalf("converter.EnsureTables: %v", err)
+		}
+		bq.Close()
+		if srvr.bq, err = managedwriter.NewClient(ctx, project); err != nil {
+			log.Fatalf("managedwriter.NewClient: %v", err)
+		}
+		defer srvr.bq.Close()
+	}
+	srvr.pool = newPool(*workers, *memoryBudget)
+	srvr.retryPolicy = &converter.RetryPolicy{
+		Attempts:       *conversionAttempts,
+		InitialBackoff: *retryBackoff,
+		MaxBackoff:     converter.DefaultRetryPolicy.MaxBackoff,
+	}
+	if *deadLetter != "" {
+		if srvr.deadLetter, err = newDeadLetter(ctx, cli, *deadLetter); err != nil {
+			log.Fatalf("bad dead_letter: %v", err)
+		}
+		srvr.maxAttempts = *maxAttempts
+	}
+	if srvr.noJSON = !*jsonOutput; srvr.noJSON && srvr.parquetBucket == "" && srvr.avroBucket == "" && srvr.bq == nil {
+		log.Fatal("-json=false needs -parquet_output, -avro_output or -bigquery_dataset")
+	}
+
+	reg := prometheus.NewRegistry()
+	srvr.metrics = newConversionMetrics(reg)
+	if *summaryInterval > 0 {
+		go srvr.metrics.report(ctx, *summaryInterval)
+	}
+
+	if *usageInterval > 0 {
+		go resourceusage.Report(ctx, *usageInterval, func(u *resourceusage.Usage) {
+			log.WithFields(log.Fields(u.Fields())).Info("Resource usage")
+		})
+	}
 
+	http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
 	http.HandleFunc("/", srvr.archiveUploadHandler)
 	log.Printf("Listening on port %s", port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
@@ -105,7 +105,7 @@ func TestArchiveUploadHandle(t *testing.T) {
 			pubsubMsg: "bad JSON pubsub message",
 		},
 		{
-			desc:      "skipped because it's not a OBJECT_METADATA_UPDATE",
+			desc:      "skipped because it's not a OBJECT_METADATA_UPDATE or RV_UPLOAD",
 			pubsubMsg: makeFakeMsgFormat("OBJECT_DELETE", "route-views4/bgpdata/updates/2021.12/updates.20211212.0015.bz2", "src-bucket"),
 		},
 		{
@@ -171,6 +171,28 @@ func TestArchiveUploadHandle(t *testing.T) {
 				"gs://dst-bucket/route-views4/bgpdata/updates/2021.12/updates.20211212.0015.gz",
 			},
 		},
+		{
+			desc:      "success of an archive server notification",
+			pubsubMsg: makeFakeMsgFormat("RV_UPLOAD", "route-views4/bgpdata/updates/2021.12/updates.20211212.0015.bz2", "src-bucket"),
+			fakeobjects: []fakestorage.Object{
+				{
+					ObjectAttrs: fakestorage.ObjectAttrs{
+						BucketName: "src-bucket",
+						Name:       "route-views4/bgpdata/updates/2021.12/updates.20211212.0015.bz2",
+						Metadata: map[string]string{
+							converter.ProjectMetadataKey: pb.FileRequest_ROUTEVIEWS.String(),
+						},
+					},
+					Content: makeFakeCompressedMRT(t, mrt.NewBGP4MPMessage(100000, 6447, 0, "1.0.0.0", "2.0.0.0", true, bgp.NewBGPUpdateMessage(nil, nil, []*bgp.IPAddrPrefix{
+						bgp.NewIPAddrPrefix(24, "10.0.0.0"),
+						bgp.NewIPAddrPrefix(24, "20.0.0.0"),
+					}))),
+				},
+			},
+			dstObjects: []string{
+				"gs://dst-bucket/route-views4/bgpdata/updates/2021.12/updates.20211212.0015.gz",
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
@@ -221,3 +243,85 @@ func TestArchiveUploadHandle(t *testing.T) {
 		})
 	}
 }
+
+func TestParseGCSPrefix(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantBucket string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{url: "gs://routeviews-parquet", wantBucket: "routeviews-parquet"},
+		{url: "gs://routeviews-parquet/", wantBucket: "routeviews-parquet"},
+		{url: "gs://routeviews-parquet/mrt", wantBucket: "routeviews-parquet", wantPrefix: "mrt/"},
+		{url: "gs://routeviews-parquet/mrt/v1/", wantBucket: "routeviews-parquet", wantPrefix: "mrt/v1/"},
+		{url: "routeviews-parquet/mrt", wantErr: true},
+		{url: "gs://", wantErr: true},
+	}
+	for _, test := range tests {
+		bkt, prefix, err := parseGCSPrefix(test.url)
+		if (err != nil) != test.wantErr || bkt != test.wantBucket || prefix != test.wantPrefix {
+			t.Errorf("parseGCSPrefix(%q) = %q, %q, %v; want %q, %q, err %v", test.url, bkt, prefix, err, test.wantBucket, test.wantPrefix, test.wantErr)
+		}
+	}
+}
+
+func TestParseSchemaIDs(t *testing.T) {
+	tests := []struct {
+		ids     string
+		want    map[string]int
+		wantErr bool
+	}{
+		{ids: "", want: map[string]int{}},
+		{ids: "updates=12,ribs=13", want: map[string]int{"updates": 12, "ribs": 13}},
+		{ids: "updates", wantErr: true},
+		{ids: "updates=x", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseSchemaIDs(test.ids)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseSchemaIDs(%q) got err: %v, want err %v", test.ids, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(got, test.want); diff != "" {
+			t.Errorf("parseSchemaIDs(%q) got diff (-got +want):\n%s", test.ids, diff)
+		}
+	}
+}
+
+func TestParseDataset(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantProject string
+		wantDataset string
+		wantErr     bool
+	}{
+		{name: "routeviews.mrt", wantProject: "routeviews", wantDataset: "mrt"},
+		{name: "mrt", wantErr: true},
+		{name: "routeviews.", wantErr: true},
+		{name: "routeviews.mrt.updates", wantErr: true},
+	}
+	for _, test := range tests {
+		project, dataset, err := parseDataset(test.name)
+		if (err != nil) != test.wantErr || project != test.wantProject || dataset != test.wantDataset {
+			t.Errorf("parseDataset(%q) = %q, %q, %v; want %q, %q, err %v", test.name, project, dataset, err, test.wantProject, test.wantDataset, test.wantErr)
+		}
+	}
+}
+
+func TestLoadTables(t *testing.T) {
+	got, err := loadTables("routeviews.mrt", "")
+	if err != nil {
+		t.Fatalf("loadTables() got err: %v", err)
+	}
+	want := converter.TableConfig{Project: "routeviews", Dataset: "mrt", UpdatesTable: "updates", RibsTable: "ribs", Partitioning: converter.PartitionIngestion}
+	if diff := cmp.Diff(got.For("ROUTEVIEWS"), want); diff != "" {
+		t.Errorf("loadTables() got diff (-got +want):\n%s", diff)
+	}
+	if got, err := loadTables("", ""); got != nil || err != nil {
+		t.Errorf("loadTables() of neither got %v, %v; want nil tables", got, err)
+	}
+	if _, err := loadTables("mrt", ""); err == nil {
+		t.Errorf("loadTables() of a bad dataset got nil err, want err")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	log "github.com/sirupsen/logrus"
+)
+
+// unknownLabel is the collector or type of the archives which have none, ie:
+// whose name is neither of updates nor of a RIB dump.
+const unknownLabel = "unknown"
+
+// conversionMetrics are the metrics of the conversions by collector and type
+// of archive, exported to Prometheus and summarized in the logs, so a
+// collector whose archives stop parsing stands out. It is safe for
+// concurrent use.
+type conversionMetrics struct {
+	conversions *prometheus.CounterVec
+	records     *prometheus.CounterVec
+	parseErrors *prometheus.CounterVec
+	rows        *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+
+	mu sync.Mutex
+	// summaries are the counts since the last summary was logged.
+	summaries map[summaryKey]*summary
+}
+
+type summaryKey struct {
+	collector, kind string
+}
+
+// summary counts the conversions of a collector and type of archive.
+type summary struct {
+	archives    int64
+	failed      int64
+	records     int64
+	parseErrors int64
+	rows        int64
+	latency     time.Duration
+}
+
+// newConversionMetrics returns the metrics of the conversions, registered
+// with reg.
+func newConversionMetrics(reg prometheus.Registerer) *conversionMetrics {
+	labels := []string{"collector", "type"}
+	m := &conversionMetrics{
+		conversions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rv_converter_conversions_total",
+			Help: "Archives converted, by result: ok or failed.",
+		}, append(labels, "result")),
+		records: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rv_converter_records_total",
+			Help: "MRT records read of the archives.",
+		}, labels),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rv_converter_parse_errors_total",
+			Help: "MRT records of the archives which failed to parse.",
+		}, labels),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rv_converter_rows_total",
+			Help: "Rows of updates and RIB entries written for BigQuery.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rv_converter_conversion_seconds",
+			Help:    "Duration of the conversions of the archives, their retries included.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		}, labels),
+		summaries: map[summaryKey]*summary{},
+	}
+	reg.MustRegister(m.conversions, m.records, m.parseErrors, m.rows, m.latency)
+	return m
+}
+
+// observe records the conversion of an archive which took d and failed with
+// err, if not nil. A nil conversionMetrics records nothing.
+func (m *conversionMetrics) observe(st *converter.Stats, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	k := summaryKey{collector: st.Collector, kind: st.Kind}
+	if k.collector == "" {
+		k.collector = unknownLabel
+	}
+	if k.kind == "" {
+		k.kind = unknownLabel
+	}
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	m.conversions.WithLabelValues(k.collector, k.kind, result).Inc()
+	m.records.WithLabelValues(k.collector, k.kind).Add(float64(st.Records))
+	m.parseErrors.WithLabelValues(k.collector, k.kind).Add(float64(st.ParseErrors))
+	m.rows.WithLabelValues(k.collector, k.kind).Add(float64(st.Rows))
+	m.latency.WithLabelValues(k.collector, k.kind).Observe(d.Seconds())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.summaries[k]
+	if !ok {
+		s = &summary{}
+		m.summaries[k] = s
+	}
+	s.archives++
+	if err != nil {
+		s.failed++
+	}
+	s.records += st.Records
+	s.parseErrors += st.ParseErrors
+	s.rows += st.Rows
+	s.latency += d
+}
+
+// summarize returns the log fields of the summaries since the last call, in
+// order of collector and type, and starts new ones.
+func (m *conversionMetrics) summarize() []log.Fields {
+	m.mu.Lock()
+	summaries := m.summaries
+	m.summaries = map[summaryKey]*summary{}
+	m.mu.Unlock()
+
+	keys := make([]summaryKey, 0, len(summaries))
+	for k := range summaries {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].collector != keys[j].collector {
+			return keys[i].collector < keys[j].collector
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	var res []log.Fields
+	for _, k := range keys {
+		s := summaries[k]
+		res = append(res, log.Fields{
+			"collector":   k.collector,
+			"type":        k.kind,
+			"archives":    s.archives,
+			"failed":      s.failed,
+			"records":     s.records,
+			"parseErrors": s.parseErrors,
+			"rows":        s.rows,
+			"meanLatency": (s.latency / time.Duration(s.archives)).String(),
+		})
+	}
+	return res
+}
+
+// report logs the summaries every interval until ctx is done.
+func (m *conversionMetrics) report(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			for _, f := range m.summarize() {
+				log.WithFields(f).Info("Conversion summary")
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	converter "github.com/routeviews/google-cloud-storage/pkg/mrt_converter"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestConversionMetrics(t *testing.T) {
+	m := newConversionMetrics(prometheus.NewRegistry())
+	m.observe(&converter.Stats{Collector: "route-views2", Kind: "updates", Records: 10, ParseErrors: 1, Rows: 9}, 2*time.Second, nil)
+	m.observe(&converter.Stats{Collector: "route-views2", Kind: "updates", Records: 4, ParseErrors: 4}, 4*time.Second, errors.New("failed"))
+	m.observe(&converter.Stats{Collector: "rrc00", Kind: "ribs", Records: 100, Rows: 1000}, time.Minute, nil)
+	// An archive which could not be read is of no collector nor type.
+	m.observe(&converter.Stats{}, time.Second, errors.New("metadata is missing"))
+
+	for _, c := range []struct {
+		desc string
+		c    prometheus.Collector
+		want float64
+	}{
+		{desc: "records", c: m.records.WithLabelValues("route-views2", "updates"), want: 14},
+		{desc: "parse errors", c: m.parseErrors.WithLabelValues("route-views2", "updates"), want: 5},
+		{desc: "rows", c: m.rows.WithLabelValues("rrc00", "ribs"), want: 1000},
+		{desc: "ok conversions", c: m.conversions.WithLabelValues("route-views2", "updates", "ok"), want: 1},
+		{desc: "failed conversions", c: m.conversions.WithLabelValues("route-views2", "updates", "failed"), want: 1},
+		{desc: "unknown conversions", c: m.conversions.WithLabelValues(unknownLabel, unknownLabel, "failed"), want: 1},
+	} {
+		if got := testutil.ToFloat64(c.c); got != c.want {
+			t.Errorf("%s got %v, want %v", c.desc, got, c.want)
+		}
+	}
+
+	want := []log.Fields{
+		{"collector": "route-views2", "type": "updates", "archives": int64(2), "failed": int64(1), "records": int64(14), "parseErrors": int64(5), "rows": int64(9), "meanLatency": "3s"},
+		{"collector": "rrc00", "type": "ribs", "archives": int64(1), "failed": int64(0), "records": int64(100), "parseErrors": int64(0), "rows": int64(1000), "meanLatency": "1m0s"},
+		{"collector": unknownLabel, "type": unknownLabel, "archives": int64(1), "failed": int64(1), "records": int64(0), "parseErrors": int64(0), "rows": int64(0), "meanLatency": "1s"},
+	}
+	if diff := cmp.Diff(m.summarize(), want); diff != "" {
+		t.Errorf("summarize() got diff (-got +want):\n%s", diff)
+	}
+	if got := m.summarize(); got != nil {
+		t.Errorf("summarize() after a summary got %v, want none", got)
+	}
+}
+
+func TestNilConversionMetrics(t *testing.T) {
+	var m *conversionMetrics
+	m.observe(&converter.Stats{Records: 1}, time.Second, nil)
+}
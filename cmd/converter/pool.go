@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/routeviews/google-cloud-storage/pkg/inflight"
+)
+
+// memoryPerArchiveByte estimates the memory of a conversion by byte of its
+// compressed archive: the decompressor, and the rows the Parquet, Avro and
+// BigQuery outputs buffer, grow with the archive.
+const memoryPerArchiveByte = 4
+
+// pool bounds the conversions of the server at once, so a burst of archives
+// is converted concurrently within the CPU and memory of the instance: at most
+// workers conversions, of archives whose estimated memory fits the budget.
+// The conversions beyond either wait their turn in order.
+type pool struct {
+	workers chan struct{}
+	budget  int64
+	memory  *inflight.Limiter
+}
+
+// newPool returns a pool of workers and a memory budget in bytes, a budget of
+// 0 is unlimited.
+func newPool(workers int, memoryBudget int64) *pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &pool{
+		workers: make(chan struct{}, workers),
+		budget:  memoryBudget,
+		memory:  inflight.New(memoryBudget),
+	}
+}
+
+// acquire blocks until a worker and the memory of an archive of size bytes
+// are available, or ctx is done. Call release once the conversion is done.
+// A nil pool is unlimited.
+func (p *pool) acquire(ctx context.Context, size int64) (release func(), err error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	mem := size * memoryPerArchiveByte
+	if err := p.memory.Acquire(ctx, mem); err != nil {
+		<-p.workers
+		return nil, err
+	}
+	return func() {
+		p.memory.Release(mem)
+		<-p.workers
+	}, nil
+}
+
+// budgeted reports whether the pool has a memory budget, the size of each
+// archive is needed then.
+func (p *pool) budgeted() bool {
+	return p != nil && p.budget > 0
+}
+
+// archiveSize returns the size of an archive, 0 if its attributes cannot be
+// read; the conversion fails on its own then.
+func archiveSize(ctx context.Context, gcsCli *storage.Client, bucket, object string) int64 {
+	attrs, err := gcsCli.Bucket(bucket).Object(object).Attrs(ctx)
+	if err != nil {
+		return 0
+	}
+	return attrs.Size
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// acquired reports whether a conversion of an archive of size bytes gets a
+// worker of the pool before a short timeout, and its release.
+func acquired(t *testing.T, p *pool, size int64) (bool, func()) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release, err := p.acquire(ctx, size)
+	if err != nil {
+		return false, nil
+	}
+	return true, release
+}
+
+func TestPoolWorkers(t *testing.T) {
+	p := newPool(2, 0)
+	ok1, release1 := acquired(t, p, 1<<30)
+	ok2, release2 := acquired(t, p, 1<<30)
+	if !ok1 || !ok2 {
+		t.Fatalf("acquire() of 2 workers got %v, %v; want both", ok1, ok2)
+	}
+	if ok, _ := acquired(t, p, 1); ok {
+		t.Fatal("acquire() of a third conversion got a worker, want it to wait")
+	}
+	release1()
+	ok3, release3 := acquired(t, p, 1)
+	if !ok3 {
+		t.Fatal("acquire() after a release got no worker, want one")
+	}
+	release2()
+	release3()
+}
+
+func TestPoolMemoryBudget(t *testing.T) {
+	p := newPool(4, 100*memoryPerArchiveByte)
+	if !p.budgeted() {
+		t.Error("budgeted() of a memory budget = false, want true")
+	}
+	ok, release := acquired(t, p, 60)
+	if !ok {
+		t.Fatal("acquire() within the budget got nothing")
+	}
+	if ok, _ := acquired(t, p, 60); ok {
+		t.Fatal("acquire() beyond the budget got a worker, want it to wait")
+	}
+	release()
+	// An archive larger than the whole budget is converted alone.
+	ok, release = acquired(t, p, 1000)
+	if !ok {
+		t.Fatal("acquire() of an archive larger than the budget got nothing")
+	}
+	release()
+	if p.memory.InUse() != 0 || len(p.workers) != 0 {
+		t.Errorf("pool got %d bytes and %d workers in use after the releases, want none", p.memory.InUse(), len(p.workers))
+	}
+}
+
+func TestNilPool(t *testing.T) {
+	var p *pool
+	if p.budgeted() {
+		t.Error("budgeted() of a nil pool = true, want false")
+	}
+	if ok, release := acquired(t, p, 1<<40); !ok {
+		t.Error("acquire() of a nil pool got nothing, want unlimited")
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,142 @@
+// Package main serves the contribution statistics of the archives as a
+// public, read-only JSON API for the RouteViews community pages and annual
+// reports.
+//
+// The statistics are collected from the archive and converted buckets when
+// the server starts, and refreshed every -refresh interval.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/routeviews/google-cloud-storage/pkg/stats"
+)
+
+var (
+	archiveBucket   = flag.String("archive_bucket", "routeviews-archives", "GCS bucket that saves all raws MRT archives.")
+	convertedBucket = flag.String("converted_bucket", "routeviews-bigquery", "GCS bucket that saves all converted MRT archives.")
+	refresh         = flag.Duration("refresh", 6*time.Hour, "Interval to recollect the statistics at.")
+)
+
+type server struct {
+	gcsCli          *storage.Client
+	archiveBucket   string
+	convertedBucket string
+
+	mu     sync.RWMutex
+	report *stats.Report
+}
+
+// collect recollects the statistics, the previous report is served until the
+// collection succeeds.
+func (s *server) collect(ctx context.Context) error {
+	r, err := stats.Collect(ctx, s.gcsCli, s.archiveBucket, s.convertedBucket)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = r
+	return nil
+}
+
+func (s *server) currentReport() *stats.Report {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.report
+}
+
+type summary struct {
+	Generated time.Time `json:"generated"`
+	Files     int64     `json:"files"`
+	Bytes     int64     `json:"bytes"`
+	Coverage  float64   `json:"coverage"`
+}
+
+// handle serves the statistics produced by f from the current report.
+func (s *server) handle(f func(r *stats.Report, req *http.Request) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r := s.currentReport()
+		if r == nil {
+			http.Error(w, "statistics are not collected yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// The statistics are public, so any community page may fetch them.
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if err := json.NewEncoder(w).Encode(f(r, req)); err != nil {
+			log.Errorf("json.Encode: %v", err)
+		}
+	}
+}
+
+func daily(r *stats.Report, req *http.Request) interface{} {
+	q := req.URL.Query()
+	return r.Filter(q.Get("collector"), q.Get("from"), q.Get("to"))
+}
+
+func monthly(r *stats.Report, _ *http.Request) interface{} {
+	return r.Months
+}
+
+func total(r *stats.Report, _ *http.Request) interface{} {
+	res := &summary{Generated: r.Generated, Coverage: r.Coverage}
+	for _, m := range r.Months {
+		res.Files += m.Files
+		res.Bytes += m.Bytes
+	}
+	return res
+}
+
+func main() {
+	ctx := context.Background()
+	flag.Parse()
+	log.SetFormatter(&log.JSONFormatter{})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+		log.Infof("Defaulting to port %s", port)
+	}
+	cli, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatalf("storage.NewClient: %v", err)
+	}
+	s := &server{
+		gcsCli:          cli,
+		archiveBucket:   *archiveBucket,
+		convertedBucket: *convertedBucket,
+	}
+
+	go func() {
+		for {
+			if err := s.collect(ctx); err != nil {
+				log.Errorf("stats.Collect: %v", err)
+			} else {
+				log.Info("Statistics collected")
+			}
+			time.Sleep(*refresh)
+		}
+	}()
+
+	http.HandleFunc("/v1/stats/daily", s.handle(daily))
+	http.HandleFunc("/v1/stats/monthly", s.handle(monthly))
+	http.HandleFunc("/v1/stats/summary", s.handle(total))
+	log.Printf("Listening on port %s", port)
+	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -60,9 +60,11 @@ func newConMgr(ctx context.Context, sc *storage.Client, srcBkt, dstBkt string, w
 					dataSource = defaultDataSource.String()
 				}
 
-				// Reset metadata to trigger conversion.
+				// Reset metadata to trigger conversion, clearing the mark of an
+				// earlier failed conversion.
 				_, err = gcsObj.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: map[string]string{
 					converter.ProjectMetadataKey: dataSource,
+					converter.FailedMetadataKey:  "",
 				}})
 				if err != nil {
 					glog.Errorf("failed to update metadata of gs://%s/%s: %v", srcBkt, obj, err)
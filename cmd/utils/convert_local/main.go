@@ -10,7 +10,7 @@ import (
 
 var (
 	collector = flag.String("collector", "", "Collector name of this archive.")
-	archive   = flag.String("archive", "", "Path to the bz2 MRT archive.")
+	archive   = flag.String("archive", "", "Path to the MRT archive, gzip, bzip2 or xz compressed, or not.")
 	output    = flag.String("output", "", "Output path of the converted archive.")
 )
 
@@ -0,0 +1,71 @@
+// Package main restores archives in a cold storage class back to STANDARD.
+//
+// Run with -dry_run (the default) first to list the cold objects under a
+// prefix and the expected retrieval fee, then rerun with -dry_run=false to
+// rewrite them to STANDARD before a bulk read or conversion. The rewrites are
+// billed the retrieval fee reported by the dry run.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"cloud.google.com/go/storage"
+	"github.com/golang/glog"
+	"google.golang.org/api/iterator"
+
+	storagetier "github.com/routeviews/google-cloud-storage/pkg/storage_tier"
+)
+
+var (
+	bucket  = flag.String("bucket", "routeviews-archives", "GCS bucket that saves all raws MRT archives.")
+	rootDir = flag.String("root_dir", "", "The directory to restore from the bucket. Empty means the root of the bucket.")
+	dryRun  = flag.Bool("dry_run", true, "Only report the cold objects and the expected retrieval fee.")
+)
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+
+	sc, err := storage.NewClient(ctx)
+	if err != nil {
+		glog.Exit(err)
+	}
+	defer sc.Close()
+
+	var objects, bytes int64
+	var cost float64
+	it := sc.Bucket(*bucket).Objects(ctx, &storage.Query{Prefix: *rootDir})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			glog.Fatal(err)
+		}
+		est := storagetier.EstimateFor(attrs)
+		if !est.Cold() {
+			continue
+		}
+		objects++
+		bytes += est.Bytes
+		cost += est.Cost
+		if *dryRun {
+			glog.Infof("Cold: gs://%s/%s (%s)", *bucket, attrs.Name, est)
+			continue
+		}
+		if _, err := storagetier.RestoreObject(ctx, sc.Bucket(*bucket).Object(attrs.Name)); err != nil {
+			glog.Errorf("RestoreObject: %v", err)
+			continue
+		}
+		glog.Infof("Restored: gs://%s/%s (%s)", *bucket, attrs.Name, est)
+	}
+
+	verb := "Restored"
+	if *dryRun {
+		verb = "Would restore"
+	}
+	fmt.Printf("%s %d objects, %d bytes, retrieval fee ~$%.2f\n", verb, objects, bytes, cost)
+}
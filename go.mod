@@ -6,6 +6,7 @@ require (
 	cloud.google.com/go/bigquery v1.50.0
 	cloud.google.com/go/cloudtasks v1.10.0
 	cloud.google.com/go/storage v1.29.0
+	github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace v1.16.0
 	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/dsnet/compress v0.0.1
 	github.com/fsouza/fake-gcs-server v1.31.1
@@ -13,8 +14,15 @@ require (
 	github.com/google/go-cmp v0.5.9
 	github.com/jlaffaye/ftp v0.0.0-20211117213618-11820403398b
 	github.com/osrg/gobgp v0.0.0-20211201041502-6248c576b118
+	github.com/prometheus/client_golang v1.15.1
 	github.com/routeviews/google-cloud-storage/proto/rv v0.0.0-00010101000000-000000000000
 	github.com/sirupsen/logrus v1.8.1
+	github.com/ulikunitz/xz v0.5.11
+	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.42.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+	golang.org/x/net v0.10.0
 	golang.org/x/oauth2 v0.7.0
 	google.golang.org/api v0.114.0
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1
@@ -0,0 +1,319 @@
+// Package archiveprofile describes the directory layouts and filename
+// patterns of the MRT archives published by each routing data project, so
+// that one tool can mirror the archives of every project.
+//
+// Profiles of new data sources may be defined in a YAML file, see Load.
+package archiveprofile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"gopkg.in/yaml.v2"
+)
+
+// Compression formats of archives.
+const (
+	Bzip2 = "bz2"
+	Gzip  = "gz"
+	Xz    = "xz"
+	None  = "none"
+)
+
+// Profile is the archive layout of a routing data project.
+type Profile struct {
+	Name    string
+	Project pb.FileRequest_Project
+	// Pattern matches the paths of the archives to mirror, relative to the
+	// archive site root. The named groups "collector", "date" (YYYYMMDD) and
+	// "time" (HHMM) are parsed from the path, separators such as dashes in
+	// the date and time are ignored.
+	Pattern *regexp.Regexp
+	// DefaultCollector is the collector of a path without a collector.
+	DefaultCollector string
+	// Compression is the compression format of the archives.
+	Compression string
+}
+
+// Archive is the information parsed from an archive path.
+type Archive struct {
+	Collector string
+	Time      time.Time
+}
+
+var profiles = map[string]*Profile{
+	// RouteViews: [<collector>/]bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2
+	// Only updates are mirrored. The route-views2 archives live at the root.
+	"routeviews": {
+		Name:             "routeviews",
+		Project:          pb.FileRequest_ROUTEVIEWS,
+		Pattern:          regexp.MustCompile(`^(?:(?P<collector>[^/]+)/)?bgpdata/\d{4}\.\d{2}/UPDATES/updates\.(?P<date>\d{8})\.(?P<time>\d{4})\.(?:bz2|gz)$`),
+		DefaultCollector: "route-views2",
+		Compression:      Bzip2,
+	},
+	// RouteViews RIBs: [<collector>/]bgpdata/2022.01/RIBS/rib.20220109.1800.bz2
+	"routeviews-rib": {
+		Name:             "routeviews-rib",
+		Project:          pb.FileRequest_ROUTEVIEWS_RIB,
+		Pattern:          regexp.MustCompile(`^(?:(?P<collector>[^/]+)/)?bgpdata/\d{4}\.\d{2}/RIBS/rib\.(?P<date>\d{8})\.(?P<time>\d{4})\.(?:bz2|gz)$`),
+		DefaultCollector: "route-views2",
+		Compression:      Bzip2,
+	},
+	// RIPE RIS: rrc00/2022.01/updates.20220109.1830.gz and the bview.* RIB dumps.
+	"ris": {
+		Name:        "ris",
+		Project:     pb.FileRequest_RIPE_RIS,
+		Pattern:     regexp.MustCompile(`^(?P<collector>rrc\d{2})/\d{4}\.\d{2}/(?:updates|bview)\.(?P<date>\d{8})\.(?P<time>\d{4})\.gz$`),
+		Compression: Gzip,
+	},
+	// Packet Clearing House:
+	//   route-collector.ams.pch.net/2022/01/09/route-collector.ams.pch.net-mrt-bgp-updates-2022-01-09-18-30.gz
+	"pch": {
+		Name:        "pch",
+		Project:     pb.FileRequest_PCH,
+		Pattern:     regexp.MustCompile(`^(?P<collector>route-collector\.[^/]+)/\d{4}/\d{2}/\d{2}/[^/]+-mrt-bgp-updates-(?P<date>\d{4}-\d{2}-\d{2})-(?P<time>\d{2}-\d{2})\.gz$`),
+		Compression: Gzip,
+	},
+}
+
+// Lookup returns the profile of a name.
+func Lookup(name string) (*Profile, error) {
+	p, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown archive profile %q, want one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names returns the names of all profiles.
+func Names() []string {
+	var names []string
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// digits strips the separators from a date or time.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Parse parses an archive path, the leading slash is ignored. It returns
+// false if the path is not an archive of the profile.
+func (p *Profile) Parse(path string) (*Archive, bool) {
+	m := p.Pattern.FindStringSubmatch(strings.TrimLeft(path, "/"))
+	if m == nil {
+		return nil, false
+	}
+	a := &Archive{Collector: p.DefaultCollector}
+	var date, hhmm string
+	parts := map[string]string{}
+	for i, name := range p.Pattern.SubexpNames() {
+		// A group of an alternative which did not match is empty, ie: of the
+		// patterns of the filename policy of the upload server.
+		if m[i] == "" {
+			continue
+		}
+		switch name {
+		case "collector":
+			a.Collector = m[i]
+		case "date":
+			date = digits(m[i])
+		case "time":
+			hhmm = digits(m[i])
+		case "year", "month", "day", "hour", "minute":
+			parts[name] = m[i]
+		}
+	}
+	// The date and time of a layout are parsed in parts.
+	if date == "" && parts["year"] != "" {
+		date = parts["year"] + orDefault(parts["month"], "01") + orDefault(parts["day"], "01")
+		hhmm = orDefault(parts["hour"], "00") + orDefault(parts["minute"], "00")
+	}
+	if date != "" {
+		if hhmm == "" {
+			hhmm = "0000"
+		}
+		t, err := time.Parse("200601021504", date+hhmm)
+		if err != nil {
+			return nil, false
+		}
+		a.Time = t
+	}
+	return a, true
+}
+
+// ParseProject parses the path of an archive of a project with the built-in
+// profiles of the project. The path may carry leading directories beyond the
+// archive site root, ie: an ftp path or an object prefix, which are skipped.
+func ParseProject(proj pb.FileRequest_Project, path string) (*Archive, bool) {
+	for _, name := range Names() {
+		p := profiles[name]
+		if p.Project != proj {
+			continue
+		}
+		for rest := strings.TrimLeft(path, "/"); rest != ""; {
+			if a, ok := p.Parse(rest); ok {
+				return a, true
+			}
+			i := strings.Index(rest, "/")
+			if i < 0 {
+				break
+			}
+			rest = rest[i+1:]
+		}
+	}
+	return nil, false
+}
+
+// layoutTokens are the placeholders of a layout and the patterns they match.
+var layoutTokens = []struct {
+	token, group, pattern string
+}{
+	{"{collector}", "collector", `[^/]+`},
+	{"{YYYY}", "year", `\d{4}`},
+	{"{MM}", "month", `\d{2}`},
+	{"{DD}", "day", `\d{2}`},
+	{"{hh}", "hour", `\d{2}`},
+	{"{mm}", "minute", `\d{2}`},
+	{"*", "", `[^/]*`},
+}
+
+// compileLayout compiles a layout template, ie:
+//
+//	{collector}/bgpdata/{YYYY}.{MM}/UPDATES/updates.{YYYY}{MM}{DD}.{hh}{mm}.bz2
+//
+// into a pattern. The first occurrence of each placeholder is captured, * matches
+// any characters except a slash, and the rest of the layout matches literally.
+func compileLayout(layout string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	seen := map[string]bool{}
+	for rest := layout; rest != ""; {
+		matched := false
+		for _, t := range layoutTokens {
+			if !strings.HasPrefix(rest, t.token) {
+				continue
+			}
+			if t.group != "" && !seen[t.group] {
+				fmt.Fprintf(&b, "(?P<%s>%s)", t.group, t.pattern)
+				seen[t.group] = true
+			} else {
+				fmt.Fprintf(&b, "(?:%s)", t.pattern)
+			}
+			rest = rest[len(t.token):]
+			matched = true
+			break
+		}
+		if !matched {
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// profileConfig is a profile in a YAML profiles file.
+type profileConfig struct {
+	Name string `yaml:"name"`
+	// Project is a name of rv.proto.FileRequest.Project.
+	Project string `yaml:"project"`
+	// Either a pattern or a layout template describes the archive paths.
+	Pattern          string `yaml:"pattern"`
+	Layout           string `yaml:"layout"`
+	DefaultCollector string `yaml:"default_collector"`
+	Compression      string `yaml:"compression"`
+}
+
+func (c *profileConfig) profile() (*Profile, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("profile has no name")
+	}
+	proj, ok := pb.FileRequest_Project_value[c.Project]
+	if !ok || proj == int32(pb.FileRequest_UNKNOWN) {
+		return nil, fmt.Errorf("profile %s: bad project %q", c.Name, c.Project)
+	}
+	p := &Profile{
+		Name:             strings.ToLower(c.Name),
+		Project:          pb.FileRequest_Project(proj),
+		DefaultCollector: c.DefaultCollector,
+		Compression:      c.Compression,
+	}
+	switch p.Compression {
+	case "":
+		p.Compression = None
+	case Bzip2, Gzip, Xz, None:
+	default:
+		return nil, fmt.Errorf("profile %s: unsupported compression %q", c.Name, c.Compression)
+	}
+
+	var err error
+	switch {
+	case c.Pattern != "" && c.Layout != "":
+		return nil, fmt.Errorf("profile %s: set either a pattern or a layout", c.Name)
+	case c.Pattern != "":
+		p.Pattern, err = regexp.Compile(c.Pattern)
+	case c.Layout != "":
+		p.Pattern, err = compileLayout(c.Layout)
+	default:
+		return nil, fmt.Errorf("profile %s: no pattern or layout", c.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %v", c.Name, err)
+	}
+	return p, nil
+}
+
+// Load reads profiles from a YAML file and makes them available to Lookup. A
+// profile replaces a built-in profile of the same name. The file is formatted
+// as:
+//
+//	profiles:
+//	  - name: isolario
+//	    project: ROUTEVIEWS
+//	    layout: "{collector}/{YYYY}_{MM}/updates.{YYYY}{MM}{DD}.{hh}{mm}.bz2"
+//	    compression: bz2
+func Load(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ioutil.ReadFile: %v", err)
+	}
+	var conf struct {
+		Profiles []*profileConfig `yaml:"profiles"`
+	}
+	if err := yaml.UnmarshalStrict(raw, &conf); err != nil {
+		return fmt.Errorf("yaml: %v", err)
+	}
+	var loaded []*Profile
+	for _, c := range conf.Profiles {
+		p, err := c.profile()
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, p)
+	}
+	for _, p := range loaded {
+		profiles[p.Name] = p
+	}
+	return nil
+}
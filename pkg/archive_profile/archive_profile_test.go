@@ -0,0 +1,233 @@
+package archiveprofile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		desc    string
+		profile string
+		path    string
+		want    *Archive
+	}{
+		{
+			desc:    "routeviews collector",
+			profile: "routeviews",
+			path:    "/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:    &Archive{Collector: "route-views4", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "routeviews2 at the root",
+			profile: "routeviews",
+			path:    "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:    &Archive{Collector: "route-views2", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "routeviews ribs are skipped",
+			profile: "routeviews",
+			path:    "route-views4/bgpdata/2022.01/RIBS/rib.20220109.1800.bz2",
+		},
+		{
+			desc:    "routeviews rib",
+			profile: "routeviews-rib",
+			path:    "route-views4/bgpdata/2022.01/RIBS/rib.20220109.1800.bz2",
+			want:    &Archive{Collector: "route-views4", Time: time.Date(2022, 1, 9, 18, 0, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "ris updates",
+			profile: "ris",
+			path:    "rrc00/2022.01/updates.20220109.1830.gz",
+			want:    &Archive{Collector: "rrc00", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "ris bview",
+			profile: "ris",
+			path:    "rrc21/2022.01/bview.20220109.1600.gz",
+			want:    &Archive{Collector: "rrc21", Time: time.Date(2022, 1, 9, 16, 0, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "ris is not routeviews",
+			profile: "ris",
+			path:    "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc:    "pch updates",
+			profile: "pch",
+			path:    "route-collector.ams.pch.net/2022/01/09/route-collector.ams.pch.net-mrt-bgp-updates-2022-01-09-18-30.gz",
+			want:    &Archive{Collector: "route-collector.ams.pch.net", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "pch rib snapshots are skipped",
+			profile: "pch",
+			path:    "route-collector.ams.pch.net/2022/01/09/route-collector.ams.pch.net-mrt-bgp-snapshot-2022-01-09-18-30.gz",
+		},
+		{
+			desc:    "invalid date",
+			profile: "ris",
+			path:    "rrc00/2022.13/updates.20221309.1830.gz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p, err := Lookup(test.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := p.Parse(test.path)
+			if ok != (test.want != nil) {
+				t.Fatalf("Parse(%s) = _, %v; want %v", test.path, ok, test.want != nil)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Parse(%s) mismatch (-want, +got):\n%s", test.path, diff)
+			}
+		})
+	}
+}
+
+func TestParseProject(t *testing.T) {
+	tests := []struct {
+		desc string
+		proj pb.FileRequest_Project
+		path string
+		want *Archive
+	}{
+		{
+			desc: "ftp path",
+			proj: pb.FileRequest_ROUTEVIEWS,
+			path: "/bgpdata/route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want: &Archive{Collector: "route-views4", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc: "object name",
+			proj: pb.FileRequest_RIPE_RIS,
+			path: "ris/rrc00/2022.01/updates.20220109.1830.gz",
+			want: &Archive{Collector: "rrc00", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc: "archive of another project",
+			proj: pb.FileRequest_RIPE_RIS,
+			path: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc: "project without a profile",
+			proj: pb.FileRequest_RPKI_RARC,
+			path: "2022/01/09/rpki-20220109T183000Z.tgz",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, ok := ParseProject(test.proj, test.path)
+			if ok != (test.want != nil) {
+				t.Fatalf("ParseProject(%s) = _, %v; want %v", test.path, ok, test.want != nil)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("ParseProject(%s) mismatch (-want, +got):\n%s", test.path, diff)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, err := Lookup("isolario"); err == nil {
+		t.Error("Lookup(isolario): nil err; want non-nil err")
+	}
+	p, err := Lookup("RIS")
+	if err != nil {
+		t.Fatalf("Lookup(RIS) = %v; want nil err", err)
+	}
+	if p.Name != "ris" {
+		t.Errorf("Lookup(RIS) = %s; want ris", p.Name)
+	}
+}
+
+func TestConfigProfile(t *testing.T) {
+	tests := []struct {
+		desc    string
+		conf    *profileConfig
+		path    string
+		want    *Archive
+		wantErr bool
+	}{
+		{
+			desc: "layout",
+			conf: &profileConfig{
+				Name:        "isolario",
+				Project:     "ROUTEVIEWS",
+				Layout:      "{collector}/{YYYY}_{MM}/updates.{YYYY}{MM}{DD}.{hh}{mm}.bz2",
+				Compression: Bzip2,
+			},
+			path: "Alderaan/2018_07/updates.20180709.1830.bz2",
+			want: &Archive{Collector: "Alderaan", Time: time.Date(2018, 7, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc: "layout wildcard and literal dots",
+			conf: &profileConfig{
+				Name:             "local",
+				Project:          "ROUTEVIEWS",
+				Layout:           "dumps/{YYYY}.{MM}/*.{YYYY}{MM}{DD}.gz",
+				DefaultCollector: "local",
+			},
+			path: "dumps/2022.01/rib.20220109.gz",
+			want: &Archive{Collector: "local", Time: time.Date(2022, 1, 9, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			desc: "layout does not match",
+			conf: &profileConfig{
+				Name:    "local",
+				Project: "ROUTEVIEWS",
+				Layout:  "dumps/{YYYY}.{MM}/*.{YYYY}{MM}{DD}.gz",
+			},
+			path: "dumps/2022x01/rib.20220109.gz",
+		},
+		{
+			desc: "pattern",
+			conf: &profileConfig{
+				Name:    "ris-updates",
+				Project: "RIPE_RIS",
+				Pattern: `^(?P<collector>rrc\d{2})/\d{4}\.\d{2}/updates\.(?P<date>\d{8})\.(?P<time>\d{4})\.gz$`,
+			},
+			path: "rrc00/2022.01/updates.20220109.1830.gz",
+			want: &Archive{Collector: "rrc00", Time: time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)},
+		},
+		{
+			desc:    "unknown project",
+			conf:    &profileConfig{Name: "isolario", Project: "ISOLARIO", Layout: "{collector}/*"},
+			wantErr: true,
+		},
+		{
+			desc:    "pattern and layout",
+			conf:    &profileConfig{Name: "isolario", Project: "ROUTEVIEWS", Layout: "*", Pattern: ".*"},
+			wantErr: true,
+		},
+		{
+			desc:    "bad compression",
+			conf:    &profileConfig{Name: "isolario", Project: "ROUTEVIEWS", Layout: "*", Compression: "zip"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p, err := test.conf.profile()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("profile() = %v; want err %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, ok := p.Parse(test.path)
+			if ok != (test.want != nil) {
+				t.Fatalf("Parse(%s) = _, %v; want %v", test.path, ok, test.want != nil)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Parse(%s) mismatch (-want, +got):\n%s", test.path, diff)
+			}
+		})
+	}
+}
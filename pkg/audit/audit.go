@@ -0,0 +1,124 @@
+// Package audit records what a sync run changed in the archive bucket, as an
+// append-only JSONL log written to GCS once the run ends.
+//
+// Each record carries the SHA-256 of the previous line, so a log whose lines
+// were edited, reordered or removed fails Verify. The log object is written
+// only if it does not exist, it is never overwritten.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Actions recorded in the log.
+const (
+	// Upload is a file uploaded to the archive.
+	Upload = "upload"
+	// Preserve is an archived generation copied aside before an overwrite.
+	Preserve = "preserve"
+)
+
+// Record is a line of the log.
+type Record struct {
+	Filename  string    `json:"filename"`
+	MD5       string    `json:"md5"`
+	Size      int64     `json:"size"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	// Prev is the hex SHA-256 of the previous line, empty for the first.
+	Prev string `json:"prev"`
+}
+
+// Log is the audit log of a run, it is safe for concurrent use.
+type Log struct {
+	actor string
+
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	prev string
+	n    int
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// New returns an empty log of the changes made by actor.
+func New(actor string) *Log {
+	return &Log{actor: actor, now: time.Now}
+}
+
+// Record appends a change to the log.
+func (l *Log) Record(filename, md5 string, size int64, action string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	line, _ := json.Marshal(&Record{
+		Filename:  filename,
+		MD5:       md5,
+		Size:      size,
+		Action:    action,
+		Timestamp: l.now().UTC(),
+		Actor:     l.actor,
+		Prev:      l.prev,
+	})
+	sum := sha256.Sum256(line)
+	l.prev = hex.EncodeToString(sum[:])
+	l.buf.Write(line)
+	l.buf.WriteByte('\n')
+	l.n++
+}
+
+// Len returns the number of records.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.n
+}
+
+// Write writes the log to the object name, which must not exist.
+func (l *Log) Write(ctx context.Context, bh *storage.BucketHandle, name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w := bh.Object(name).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	w.Metadata = map[string]string{"actor": l.actor, "records": fmt.Sprint(l.n)}
+	if _, err := w.Write(l.buf.Bytes()); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write the audit log(%s): %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write the audit log(%s): %v", name, err)
+	}
+	return nil
+}
+
+// Verify checks the chain of a log, it returns the number of records.
+func Verify(r io.Reader) (int, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	var prev string
+	n := 0
+	for s.Scan() {
+		n++
+		var rec Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return n, fmt.Errorf("record %d: %v", n, err)
+		}
+		if rec.Prev != prev {
+			return n, fmt.Errorf("record %d: chain broken, prev is %q; want %q", n, rec.Prev, prev)
+		}
+		sum := sha256.Sum256(s.Bytes())
+		prev = hex.EncodeToString(sum[:])
+	}
+	return n, s.Err()
+}
@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)
+	l := New("mass_upload/ftp@mirror1")
+	l.now = func() time.Time { return now }
+	l.Record("_history/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2.1641753000", "50e3903156f5d2dac6c9f89626d48c75", 100, Preserve)
+	l.Record("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", "c2a6e3f1cd0e1a6fbc0b84b1e8c9f41e", 120, Upload)
+	l.Record("bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2", "0f1f3a4b7c9d2e5f6a8b0c1d2e3f4a5b", 80, Upload)
+	if l.Len() != 3 {
+		t.Fatalf("Len() = %d; want 3", l.Len())
+	}
+
+	log := l.buf.String()
+	if n, err := Verify(strings.NewReader(log)); err != nil || n != 3 {
+		t.Errorf("Verify() = %d, %v; want 3, nil", n, err)
+	}
+
+	lines := strings.SplitAfter(log, "\n")
+	tests := []struct {
+		desc string
+		log  string
+	}{
+		{
+			desc: "edited",
+			log:  strings.Replace(log, `"size":120`, `"size":121`, 1),
+		},
+		{
+			desc: "reordered",
+			log:  lines[0] + lines[2] + lines[1],
+		},
+		{
+			desc: "removed",
+			log:  lines[0] + lines[2],
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := Verify(bytes.NewBufferString(test.log)); err == nil {
+				t.Error("Verify(): nil err; want non-nil err")
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"golang.org/x/oauth2"
@@ -13,12 +15,26 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/oauth"
+
+	"github.com/routeviews/google-cloud-storage/pkg/version"
 )
 
 // Set a max receive message size: 500mb
 const maxMsgSize = 512 * 1024 * 1024
 
-func NewAuthConn(ctx context.Context, host string, saPath string) (*grpc.ClientConn, error) {
+// versionInterceptors send the protocol version and the name of the running
+// tool, ie: mass_upload/1.1.0, with every request and stream.
+func versionInterceptors() []grpc.DialOption {
+	client := filepath.Base(os.Args[0]) + "/" + version.Protocol
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(version.UnaryClientInterceptor(client)),
+		grpc.WithStreamInterceptor(version.StreamClientInterceptor(client)),
+	}
+}
+
+// NewAuthConn makes a TLS connection to host, authenticated with an identity
+// token. extra options are appended, ie: a proxy dialer.
+func NewAuthConn(ctx context.Context, host string, saPath string, extra ...grpc.DialOption) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 
 	var idTokenSource oauth2.TokenSource
@@ -60,13 +76,19 @@ func NewAuthConn(ctx context.Context, host string, saPath string) (*grpc.ClientC
 			grpc.WithPerRPCCredentials(oauth.TokenSource{idTokenSource}),
 		}...,
 	)
+	opts = append(opts, versionInterceptors()...)
+	opts = append(opts, extra...)
 
 	return grpc.Dial(host, opts...)
 }
 
-func InsecureConn(host string) (*grpc.ClientConn, error) {
-	return grpc.Dial(host,
+// InsecureConn makes a plain text connection to host, extra options are
+// appended.
+func InsecureConn(host string, extra ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
 		grpc.WithInsecure(),
 		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(maxMsgSize)),
-	)
+	}
+	opts = append(opts, versionInterceptors()...)
+	return grpc.Dial(host, append(opts, extra...)...)
 }
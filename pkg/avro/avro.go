@@ -0,0 +1,157 @@
+// Package avro writes Avro object container files, for the pipelines which
+// ingest the converted archives as Avro, ie: Kafka or Dataflow.
+//
+// The records are encoded by the caller, with the Append functions of the
+// Avro binary encoding, against the schema of the file. The records are
+// written in blocks, compressed with deflate, each closed with the sync
+// marker of the file.
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// magic starts every object container file.
+const magic = "Obj\x01"
+
+// DefaultBlockSize is the bytes of encoded records of a block, if the writer
+// sets none.
+const DefaultBlockSize = 1 << 20
+
+// AppendLong appends the encoding of an int or long: a zig-zag varint.
+func AppendLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], uint64((v<<1)^(v>>63)))]...)
+}
+
+// AppendBool appends the encoding of a boolean.
+func AppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// AppendString appends the encoding of a string, or bytes.
+func AppendString(b []byte, s string) []byte {
+	return append(AppendLong(b, int64(len(s))), s...)
+}
+
+// AppendStrings appends the encoding of an array of strings.
+func AppendStrings(b []byte, ss []string) []byte {
+	if len(ss) > 0 {
+		b = AppendLong(b, int64(len(ss)))
+		for _, s := range ss {
+			b = AppendString(b, s)
+		}
+	}
+	return AppendLong(b, 0)
+}
+
+// AppendLongs appends the encoding of an array of longs.
+func AppendLongs(b []byte, vs []int64) []byte {
+	if len(vs) > 0 {
+		b = AppendLong(b, int64(len(vs)))
+		for _, v := range vs {
+			b = AppendLong(b, v)
+		}
+	}
+	return AppendLong(b, 0)
+}
+
+// Writer writes the records of a schema as an object container file.
+type Writer struct {
+	w     io.Writer
+	sync  [16]byte
+	size  int
+	block bytes.Buffer
+	count int
+	err   error
+}
+
+// NewWriter returns a Writer of the records of a schema, the JSON of an Avro
+// schema, with the metadata of the file, ie: the schema registry subject of
+// the schema. The keys of the metadata must not start with "avro.", which
+// Avro reserves. Blocks are written once they hold size bytes of records,
+// DefaultBlockSize if it is not positive.
+func NewWriter(w io.Writer, schema string, meta map[string]string, size int) (*Writer, error) {
+	if size <= 0 {
+		size = DefaultBlockSize
+	}
+	aw := &Writer{w: w, size: size}
+	if _, err := rand.Read(aw.sync[:]); err != nil {
+		return nil, fmt.Errorf("failed to make the sync marker: %v", err)
+	}
+	all := map[string]string{"avro.schema": schema, "avro.codec": "deflate"}
+	for k, v := range meta {
+		if _, ok := all[k]; ok || len(k) >= 5 && k[:5] == "avro." {
+			return nil, fmt.Errorf("metadata key %q is reserved", k)
+		}
+		all[k] = v
+	}
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := []byte(magic)
+	h = AppendLong(h, int64(len(keys)))
+	for _, k := range keys {
+		h = AppendString(AppendString(h, k), all[k])
+	}
+	h = AppendLong(h, 0)
+	h = append(h, aw.sync[:]...)
+	if _, err := w.Write(h); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// Append appends an encoded record, the block is written once it is full.
+func (w *Writer) Append(record []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.block.Write(record)
+	w.count++
+	if w.block.Len() >= w.size {
+		w.flush()
+	}
+	return w.err
+}
+
+// flush writes the records appended as a block: the count of its records,
+// the size of the compressed records, the records and the sync marker.
+func (w *Writer) flush() {
+	if w.count == 0 || w.err != nil {
+		return
+	}
+	var z bytes.Buffer
+	zw, err := flate.NewWriter(&z, flate.DefaultCompression)
+	if err != nil {
+		w.err = err
+		return
+	}
+	zw.Write(w.block.Bytes())
+	if w.err = zw.Close(); w.err != nil {
+		return
+	}
+	b := AppendLong(AppendLong(nil, int64(w.count)), int64(z.Len()))
+	b = append(append(b, z.Bytes()...), w.sync[:]...)
+	_, w.err = w.w.Write(b)
+	w.block.Reset()
+	w.count = 0
+}
+
+// Close writes the records appended. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	w.flush()
+	return w.err
+}
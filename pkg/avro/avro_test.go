@@ -0,0 +1,124 @@
+package avro
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		desc string
+		got  []byte
+		want []byte
+	}{
+		{desc: "zero", got: AppendLong(nil, 0), want: []byte{0x00}},
+		{desc: "negative", got: AppendLong(nil, -1), want: []byte{0x01}},
+		{desc: "positive", got: AppendLong(nil, 64), want: []byte{0x80, 0x01}},
+		{desc: "bool", got: AppendBool(nil, true), want: []byte{0x01}},
+		{desc: "string", got: AppendString(nil, "foo"), want: []byte{0x06, 'f', 'o', 'o'}},
+		{desc: "strings", got: AppendStrings(nil, []string{"a", "b"}), want: []byte{0x04, 0x02, 'a', 0x02, 'b', 0x00}},
+		{desc: "empty array", got: AppendLongs(nil, nil), want: []byte{0x00}},
+		{desc: "longs", got: AppendLongs(nil, []int64{1, 2}), want: []byte{0x04, 0x02, 0x04, 0x00}},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(test.got, test.want); diff != "" {
+			t.Errorf("%s: got diff (-got +want):\n%s", test.desc, diff)
+		}
+	}
+}
+
+// reader decodes the Avro binary encoding.
+type reader struct {
+	t *testing.T
+	b []byte
+}
+
+func (r *reader) long() int64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.t.Fatalf("bad long at %v", r.b)
+	}
+	r.b = r.b[n:]
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n > len(r.b) {
+		r.t.Fatalf("%d bytes beyond the end of the file", n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes(int(r.long())))
+}
+
+func TestWriter(t *testing.T) {
+	const schema = `{"type":"record","name":"R","fields":[{"name":"s","type":"string"}]}`
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, schema, map[string]string{"avro.codec": "null"}, 0); err == nil {
+		t.Errorf("NewWriter() of a reserved metadata key got nil err, want err")
+	}
+	w, err := NewWriter(&buf, schema, map[string]string{"rv.schema.subject": "rv-updates-value"}, 4)
+	if err != nil {
+		t.Fatalf("NewWriter() got err: %v", err)
+	}
+	for _, s := range []string{"abc", "d", "ef"} {
+		if err := w.Append(AppendString(nil, s)); err != nil {
+			t.Fatalf("Append(%q) got err: %v", s, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() got err: %v", err)
+	}
+
+	r := &reader{t: t, b: buf.Bytes()}
+	if got := string(r.bytes(4)); got != magic {
+		t.Fatalf("file starts with %q, want %q", got, magic)
+	}
+	meta := map[string]string{}
+	for n := r.long(); n != 0; n = r.long() {
+		for i := int64(0); i < n; i++ {
+			k := r.string()
+			meta[k] = r.string()
+		}
+	}
+	want := map[string]string{"avro.schema": schema, "avro.codec": "deflate", "rv.schema.subject": "rv-updates-value"}
+	if diff := cmp.Diff(meta, want); diff != "" {
+		t.Errorf("metadata diff (-got +want):\n%s", diff)
+	}
+	sync := r.bytes(16)
+
+	// A block of abc, of the 4 bytes of the block size, then a block of d and
+	// ef.
+	var got []string
+	var counts []int64
+	for len(r.b) > 0 {
+		count := r.long()
+		counts = append(counts, count)
+		data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(r.bytes(int(r.long())))))
+		if err != nil {
+			t.Fatalf("block got err: %v", err)
+		}
+		br := &reader{t: t, b: data}
+		for i := int64(0); i < count; i++ {
+			got = append(got, br.string())
+		}
+		if !bytes.Equal(r.bytes(16), sync) {
+			t.Fatalf("block does not end with the sync marker")
+		}
+	}
+	if diff := cmp.Diff(got, []string{"abc", "d", "ef"}); diff != "" {
+		t.Errorf("records diff (-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(counts, []int64{1, 2}); diff != "" {
+		t.Errorf("block counts diff (-got +want):\n%s", diff)
+	}
+}
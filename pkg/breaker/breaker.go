@@ -0,0 +1,123 @@
+// Package breaker implements a circuit breaker for the remote services of a
+// sync. When the error rate over a window crosses a threshold the breaker
+// opens, pausing the work so the service may recover, and it aborts the sync
+// only after sustained failure.
+package breaker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minEvents is the number of outcomes in the window needed to open the
+// breaker, so that a single early error does not trip it.
+const minEvents = 10
+
+type event struct {
+	at  time.Time
+	err error
+}
+
+// Breaker is a circuit breaker, it is safe for concurrent use.
+type Breaker struct {
+	window    time.Duration
+	threshold float64
+	pause     time.Duration
+	maxTrips  int
+
+	mu sync.Mutex
+	// events are the outcomes within the window.
+	events []event
+	// trips counts the trips without a success in between.
+	trips     int
+	openUntil time.Time
+	lastErr   error
+	aborted   error
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// New returns a breaker which opens for pause when the error rate over window
+// reaches threshold (0 to 1), and aborts after maxTrips consecutive trips.
+func New(window time.Duration, threshold float64, pause time.Duration, maxTrips int) *Breaker {
+	return &Breaker{
+		window:    window,
+		threshold: threshold,
+		pause:     pause,
+		maxTrips:  maxTrips,
+		now:       time.Now,
+	}
+}
+
+// Record records the outcome of an operation, a nil err is a success.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if err == nil && !now.Before(b.openUntil) {
+		b.trips = 0
+	}
+	if err != nil {
+		b.lastErr = err
+	}
+
+	// Drop the outcomes which left the window.
+	i := 0
+	for i < len(b.events) && now.Sub(b.events[i].at) > b.window {
+		i++
+	}
+	b.events = append(b.events[i:], event{at: now, err: err})
+
+	if b.aborted != nil || now.Before(b.openUntil) || len(b.events) < minEvents {
+		return
+	}
+	var errs int
+	for _, e := range b.events {
+		if e.err != nil {
+			errs++
+		}
+	}
+	if float64(errs)/float64(len(b.events)) < b.threshold {
+		return
+	}
+	total := len(b.events)
+	b.trips++
+	b.events = nil
+	b.openUntil = now.Add(b.pause)
+	if b.trips > b.maxTrips {
+		b.aborted = fmt.Errorf("%d of the last %d operations failed, %d times in a row; last error: %v",
+			errs, total, b.trips, b.lastErr)
+	}
+}
+
+// Wait blocks while the breaker is open. It returns the reason of the abort
+// once the failure is sustained, or the context error.
+func (b *Breaker) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	aborted := b.aborted
+	d := b.openUntil.Sub(b.now())
+	b.mu.Unlock()
+	if aborted != nil {
+		return aborted
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return b.Err()
+}
+
+// Err returns the reason of the abort, nil if the breaker has not aborted.
+func (b *Breaker) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.aborted
+}
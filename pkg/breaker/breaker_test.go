@@ -0,0 +1,91 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	start := time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)
+	now := start
+	b := New(time.Minute, 0.5, 10*time.Second, 1)
+	b.now = func() time.Time { return now }
+	failure := errors.New("gcs unavailable")
+
+	// A low error rate does not open the breaker.
+	for i := 0; i < 20; i++ {
+		if i%4 == 0 {
+			b.Record(failure)
+		} else {
+			b.Record(nil)
+		}
+	}
+	if !b.openUntil.IsZero() {
+		t.Fatalf("breaker opened at a 25%% error rate")
+	}
+
+	// Errors which left the window are forgotten.
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < minEvents-1; i++ {
+		b.Record(failure)
+	}
+	if !b.openUntil.IsZero() {
+		t.Fatalf("breaker opened with %d outcomes in the window; want at least %d", minEvents-1, minEvents)
+	}
+
+	// The first trip pauses.
+	b.Record(failure)
+	if got, want := b.openUntil, now.Add(10*time.Second); !got.Equal(want) {
+		t.Fatalf("breaker open until %v; want %v", got, want)
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("Err() = %v after one trip; want nil", err)
+	}
+
+	// The second trip in a row aborts.
+	now = now.Add(10 * time.Second)
+	for i := 0; i < minEvents; i++ {
+		b.Record(failure)
+	}
+	if err := b.Wait(context.Background()); err == nil {
+		t.Fatal("Wait() = nil after sustained failure; want abort reason")
+	}
+}
+
+func TestBreakerRecovers(t *testing.T) {
+	now := time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)
+	b := New(time.Minute, 0.5, 10*time.Second, 1)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < minEvents; i++ {
+		b.Record(errors.New("ftp timeout"))
+	}
+	// A success after the pause resets the consecutive trips.
+	now = now.Add(10 * time.Second)
+	b.Record(nil)
+	if b.trips != 0 {
+		t.Fatalf("got %d trips after a success; want 0", b.trips)
+	}
+	for i := 0; i < minEvents; i++ {
+		b.Record(errors.New("ftp timeout"))
+	}
+	if err := b.Err(); err != nil {
+		t.Errorf("Err() = %v; want nil, the trips were not in a row", err)
+	}
+}
+
+func TestWaitOpen(t *testing.T) {
+	b := New(time.Minute, 0.5, 20*time.Millisecond, 5)
+	for i := 0; i < minEvents; i++ {
+		b.Record(errors.New("grpc unavailable"))
+	}
+	start := time.Now()
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v; want nil", err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond {
+		t.Errorf("Wait() returned after %v; want it to pause", d)
+	}
+}
@@ -0,0 +1,79 @@
+// Package budget limits the work of a single sync run: the files processed,
+// the bytes transferred and the wall time. A run which exhausts its budget
+// records a checkpoint, the first path it left unprocessed, so the next run
+// resumes from there.
+package budget
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Budget limits the files, bytes and duration of a run, a zero limit is
+// unlimited. It is safe for concurrent use.
+type Budget struct {
+	maxFiles int64
+	maxBytes int64
+	deadline time.Time
+
+	mu    sync.Mutex
+	files int64
+	bytes int64
+	// exhausted is the reason the budget ran out, empty until then.
+	exhausted string
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// New returns a budget of a run starting now.
+func New(maxFiles, maxBytes int64, maxDuration time.Duration) *Budget {
+	b := &Budget{
+		maxFiles: maxFiles,
+		maxBytes: maxBytes,
+		now:      time.Now,
+	}
+	if maxDuration > 0 {
+		b.deadline = b.now().Add(maxDuration)
+	}
+	return b
+}
+
+// Take reserves a file from the budget. It returns false once the budget is
+// exhausted, the file must then be left for the next run. The byte limit is
+// checked before the file is transferred, so a run may exceed it by the size
+// of the files in flight.
+func (b *Budget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exhausted != "" {
+		return false
+	}
+	switch {
+	case b.maxFiles > 0 && b.files >= b.maxFiles:
+		b.exhausted = fmt.Sprintf("max files (%d) reached", b.maxFiles)
+	case b.maxBytes > 0 && b.bytes >= b.maxBytes:
+		b.exhausted = fmt.Sprintf("max bytes (%d) reached", b.maxBytes)
+	case !b.deadline.IsZero() && !b.now().Before(b.deadline):
+		b.exhausted = fmt.Sprintf("max duration reached at %s", b.deadline.Format(time.RFC3339))
+	}
+	if b.exhausted != "" {
+		return false
+	}
+	b.files++
+	return true
+}
+
+// Spend records the bytes transferred for a file.
+func (b *Budget) Spend(bytes int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += bytes
+}
+
+// Exhausted returns the reason the budget ran out, empty if it has not.
+func (b *Budget) Exhausted() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exhausted
+}
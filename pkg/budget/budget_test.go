@@ -0,0 +1,119 @@
+package budget
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		desc        string
+		maxFiles    int64
+		maxBytes    int64
+		maxDuration time.Duration
+		// spend is the bytes transferred for each file taken.
+		spend   int64
+		elapsed time.Duration
+		want    int
+	}{
+		{
+			desc: "unlimited",
+			want: 100,
+		},
+		{
+			desc:     "max files",
+			maxFiles: 3,
+			want:     3,
+		},
+		{
+			desc:     "max bytes checked before the transfer",
+			maxBytes: 250,
+			spend:    100,
+			want:     3,
+		},
+		{
+			desc:        "max duration",
+			maxDuration: time.Hour,
+			elapsed:     time.Minute,
+			want:        60,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			now := time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)
+			b := New(test.maxFiles, test.maxBytes, 0)
+			b.now = func() time.Time { return now }
+			if test.maxDuration > 0 {
+				b.deadline = now.Add(test.maxDuration)
+			}
+
+			got := 0
+			for i := 0; i < 100 && b.Take(); i++ {
+				got++
+				b.Spend(test.spend)
+				now = now.Add(test.elapsed)
+			}
+			if got != test.want {
+				t.Errorf("Take() succeeded %d times; want %d", got, test.want)
+			}
+			exhausted := test.want < 100
+			if (b.Exhausted() != "") != exhausted {
+				t.Errorf("Exhausted() = %q; want exhausted %v", b.Exhausted(), exhausted)
+			}
+		})
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	var c Checkpoint
+	for _, p := range []string{
+		"bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2",
+		"bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		"bgpdata/2022.02/UPDATES/updates.20220201.0000.bz2",
+	} {
+		c.Skip(p)
+	}
+	want := "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2"
+	if got := c.Path(); got != want {
+		t.Errorf("Path() = %q; want %q", got, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "checkpoint")
+	if got, err := Load(file); err != nil || got != "" {
+		t.Errorf("Load(missing) = %q, %v; want empty, nil", got, err)
+	}
+	if err := Save(file, c.Path()); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	if got, err := Load(file); err != nil || got != want {
+		t.Errorf("Load() = %q, %v; want %q, nil", got, err, want)
+	}
+	if err := Save(file, ""); err != nil {
+		t.Fatalf("Save(empty) = %v", err)
+	}
+	if got, err := Load(file); err != nil || got != "" {
+		t.Errorf("Load(removed) = %q, %v; want empty, nil", got, err)
+	}
+}
+
+func TestBefore(t *testing.T) {
+	resume := "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2"
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{"bgpdata/2021.12", true},
+		{"bgpdata/2021.12/UPDATES/", true},
+		{"bgpdata", false},
+		{"bgpdata/2022.01", false},
+		{"bgpdata/2022.01/UPDATES", false},
+		{"bgpdata/2022.02", false},
+	}
+	for _, test := range tests {
+		if got := Before(test.dir, resume); got != test.want {
+			t.Errorf("Before(%q) = %v; want %v", test.dir, got, test.want)
+		}
+	}
+}
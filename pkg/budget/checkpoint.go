@@ -0,0 +1,67 @@
+package budget
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Checkpoint collects the paths a run left unprocessed, and keeps the first
+// of them in name order. It is safe for concurrent use.
+type Checkpoint struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Skip records a path left unprocessed.
+func (c *Checkpoint) Skip(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || path < c.path {
+		c.path = path
+	}
+}
+
+// Path returns the first path left unprocessed, empty if there is none.
+func (c *Checkpoint) Path() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.path
+}
+
+// Load reads the path to resume from a checkpoint file, the path is empty
+// if the file does not exist.
+func Load(file string) (string, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the checkpoint(%s): %v", file, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Save writes the path to resume from to a checkpoint file. An empty path
+// removes the file, the next run starts from the beginning.
+func Save(file, path string) error {
+	if path == "" {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove the checkpoint(%s): %v", file, err)
+		}
+		return nil
+	}
+	if err := ioutil.WriteFile(file, []byte(path+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write the checkpoint(%s): %v", file, err)
+	}
+	return nil
+}
+
+// Before reports whether every path below dir sorts before the resume path,
+// so the whole directory was processed by an earlier run.
+func Before(dir, resume string) bool {
+	dir = strings.TrimSuffix(dir, "/") + "/"
+	return dir < resume && !strings.HasPrefix(resume, dir)
+}
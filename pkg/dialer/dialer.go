@@ -0,0 +1,119 @@
+// Package dialer dials the outbound connections of the sync tools, directly
+// or through an egress proxy, so that mirror hosts behind a proxy can reach
+// the ftp sites and the upload service.
+//
+// Proxies are given as URLs:
+//
+//	socks5://[user:pass@]host:1080
+//	http://[user:pass@]host:3128
+//
+// An http proxy is used with the CONNECT method.
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc dials a connection to address.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// New returns a dial func through the proxy at proxyURL, or a direct dial
+// func if proxyURL is empty. The timeout bounds the connection to the proxy,
+// or the direct connection.
+func New(proxyURL string, timeout time.Duration) (DialFunc, error) {
+	direct := &net.Dialer{Timeout: timeout}
+	if proxyURL == "" {
+		return direct.DialContext, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %v", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, direct)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxyURL, err)
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("proxy %q does not support contexts", proxyURL)
+		}
+		return cd.DialContext, nil
+	case "http":
+		return (&httpConnect{proxy: u, forward: direct}).DialContext, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q, use socks5 or http", u.Scheme)
+}
+
+// Func adapts a DialFunc to a dial func without a context, ie: for the ftp
+// client.
+func (d DialFunc) Func() func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		return d(context.Background(), network, address)
+	}
+}
+
+// httpConnect dials through an http proxy with the CONNECT method.
+type httpConnect struct {
+	proxy   *url.URL
+	forward *net.Dialer
+}
+
+func (h *httpConnect) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := h.forward.DialContext(ctx, "tcp", h.proxy.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %v", h.proxy.Host, err)
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if u := h.proxy.User; u != nil {
+		pass, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to CONNECT to %s through proxy %s: %v", address, h.proxy.Host, err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to CONNECT to %s through proxy %s: %v", address, h.proxy.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", h.proxy.Host, address, resp.Status)
+	}
+	// The server may speak first, ie: the ftp greeting, and be buffered already.
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn is a connection whose reads are buffered.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
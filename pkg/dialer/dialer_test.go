@@ -0,0 +1,117 @@
+package dialer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// connectProxy serves CONNECT requests on a local listener, it returns the
+// proxy address.
+func connectProxy(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				if req.Header.Get("Proxy-Authorization") == "" {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// greeter is a server which speaks first, like an ftp server.
+func greeter(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "220 ready\r\n")
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestHTTPConnect(t *testing.T) {
+	proxyAddr := connectProxy(t)
+	server := greeter(t)
+	ctx := context.Background()
+
+	dial, err := New("http://user:pass@"+proxyAddr, time.Second)
+	if err != nil {
+		t.Fatalf("New() = %v; want nil err", err)
+	}
+	conn, err := dial(ctx, "tcp", server)
+	if err != nil {
+		t.Fatalf("dial() = %v; want nil err", err)
+	}
+	defer conn.Close()
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || got != "220 ready\r\n" {
+		t.Errorf("read %q, %v; want the greeting", got, err)
+	}
+
+	// The proxy refuses requests without credentials.
+	dial, err = New("http://"+proxyAddr, time.Second)
+	if err != nil {
+		t.Fatalf("New() = %v; want nil err", err)
+	}
+	if _, err := dial(ctx, "tcp", server); err == nil {
+		t.Error("dial() without credentials: nil err; want non-nil err")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		proxy   string
+		wantErr bool
+	}{
+		{proxy: ""},
+		{proxy: "socks5://127.0.0.1:1080"},
+		{proxy: "http://proxy.example.net:3128"},
+		{proxy: "https://proxy.example.net:3128", wantErr: true},
+		{proxy: "://", wantErr: true},
+	}
+	for _, test := range tests {
+		if _, err := New(test.proxy, time.Second); (err != nil) != test.wantErr {
+			t.Errorf("New(%q) = %v; want err %v", test.proxy, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package inflight bounds the bytes of file content buffered in memory at
+// once, so that several large files processed concurrently do not exhaust
+// the memory of a small host.
+//
+// A Limiter is a semaphore weighted by bytes. Waiters are served in order, so
+// a large file is not starved by a stream of small ones.
+package inflight
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Limiter limits the bytes in flight, it is safe for concurrent use.
+type Limiter struct {
+	max int64
+
+	mu   sync.Mutex
+	used int64
+	// waiters are the pending acquisitions, in order.
+	waiters list.List
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// New returns a limiter of max bytes in flight, a max of 0 is unlimited.
+func New(max int64) *Limiter {
+	return &Limiter{max: max}
+}
+
+// clamp limits a request to the whole budget, so a file larger than the
+// budget is processed alone rather than never.
+func (l *Limiter) clamp(n int64) int64 {
+	if n > l.max {
+		return l.max
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Acquire blocks until n bytes are available, or ctx is done. Release the
+// same n once the content is no longer buffered.
+func (l *Limiter) Acquire(ctx context.Context, n int64) error {
+	if l == nil || l.max == 0 {
+		return nil
+	}
+	n = l.clamp(n)
+	l.mu.Lock()
+	if l.waiters.Len() == 0 && l.used+n <= l.max {
+		l.used += n
+		l.mu.Unlock()
+		return nil
+	}
+	w := &waiter{n: n, ready: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.ready:
+			// Acquired while cancelled, give it back.
+			l.used -= n
+		default:
+			l.waiters.Remove(elem)
+		}
+		l.notify()
+		return ctx.Err()
+	}
+}
+
+// Release returns n bytes acquired with Acquire.
+func (l *Limiter) Release(n int64) {
+	if l == nil || l.max == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used -= l.clamp(n)
+	l.notify()
+}
+
+// InUse returns the bytes in flight.
+func (l *Limiter) InUse() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.used
+}
+
+// notify serves the waiters in order while their requests fit, l.mu is held.
+func (l *Limiter) notify() {
+	for {
+		front := l.waiters.Front()
+		if front == nil {
+			return
+		}
+		w := front.Value.(*waiter)
+		if l.used+w.n > l.max {
+			return
+		}
+		l.used += w.n
+		l.waiters.Remove(front)
+		close(w.ready)
+	}
+}
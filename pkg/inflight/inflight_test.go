@@ -0,0 +1,101 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	l := New(100)
+	if err := l.Acquire(ctx, 60); err != nil {
+		t.Fatalf("Acquire(60) = %v; want nil err", err)
+	}
+
+	// A request which does not fit waits for a release.
+	acquired := make(chan struct{})
+	go func() {
+		if err := l.Acquire(ctx, 50); err != nil {
+			t.Errorf("Acquire(50) = %v; want nil err", err)
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire(50) returned with 60 of 100 bytes in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.Release(60)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire(50) still blocked after Release(60)")
+	}
+	if got := l.InUse(); got != 50 {
+		t.Errorf("InUse() = %d; want 50", got)
+	}
+
+	// Requests over the budget are clamped to it, they run alone.
+	ctx2, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx2, 1000); err == nil {
+		t.Error("Acquire(1000) with 50 bytes in use: nil err; want the context error")
+	}
+	l.Release(50)
+	if err := l.Acquire(ctx, 1000); err != nil {
+		t.Fatalf("Acquire(1000) = %v; want nil err", err)
+	}
+	if got := l.InUse(); got != 100 {
+		t.Errorf("InUse() = %d; want 100", got)
+	}
+	l.Release(1000)
+	if got := l.InUse(); got != 0 {
+		t.Errorf("InUse() = %d; want 0", got)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	ctx := context.Background()
+	l := New(100)
+	l.Acquire(ctx, 100)
+
+	// A large waiter is not overtaken by a small one which fits.
+	large := make(chan struct{})
+	go func() {
+		l.Acquire(ctx, 80)
+		close(large)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	small := make(chan struct{})
+	go func() {
+		l.Acquire(ctx, 10)
+		close(small)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	l.Release(30)
+	select {
+	case <-small:
+		t.Fatal("the small waiter overtook the large waiter")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.Release(70)
+	for _, ch := range []chan struct{}{large, small} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("waiter still blocked with the budget free")
+		}
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	l := New(0)
+	for i := 0; i < 3; i++ {
+		if err := l.Acquire(context.Background(), 1<<40); err != nil {
+			t.Fatalf("Acquire() = %v; want nil err", err)
+		}
+	}
+	l.Release(1 << 40)
+}
@@ -0,0 +1,177 @@
+// Package lease coordinates the runs of a sync, so that overlapping cron runs,
+// or two hosts syncing the same collector, do not both upload the same files.
+//
+// A lease is a GCS object, _leases/<name>, whose metadata records the holder
+// and the expiry. The object is written with generation preconditions, so
+// only one of the racing runs acquires or renews it. A holder which dies
+// stops renewing, and the lease may be taken over once it expires.
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// Prefix is the object name prefix of the leases.
+const Prefix = "_leases/"
+
+// HeldError is returned when the lease is held by another holder.
+type HeldError struct {
+	Name    string
+	Holder  string
+	Expires time.Time
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("lease %s is held by %s until %s", e.Name, e.Holder, e.Expires.Format(time.RFC3339))
+}
+
+// requestTimeout bounds each request of a lease, its retries included, so a
+// renewal which keeps failing is not retried past the expiry of the lease.
+const requestTimeout = time.Minute
+
+// object is the lease object, a gcsObject but for the tests.
+type object interface {
+	attrs(ctx context.Context) (*storage.ObjectAttrs, error)
+	// write writes the object if it meets cond, and returns its attributes.
+	write(ctx context.Context, cond storage.Conditions, metadata map[string]string, content []byte) (*storage.ObjectAttrs, error)
+	delete(ctx context.Context, cond storage.Conditions) error
+}
+
+// gcsObject is the object of a lease in GCS.
+type gcsObject struct {
+	oh *storage.ObjectHandle
+}
+
+func (o gcsObject) attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	return o.oh.Attrs(ctx)
+}
+
+func (o gcsObject) write(ctx context.Context, cond storage.Conditions, metadata map[string]string, content []byte) (*storage.ObjectAttrs, error) {
+	w := o.oh.If(cond).NewWriter(ctx)
+	w.ContentType = "text/plain"
+	w.Metadata = metadata
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return w.Attrs(), nil
+}
+
+func (o gcsObject) delete(ctx context.Context, cond storage.Conditions) error {
+	return o.oh.If(cond).Delete(ctx)
+}
+
+// Lease is an acquired lease.
+type Lease struct {
+	Name   string
+	Holder string
+	obj    object
+	ttl    time.Duration
+	// gen is the generation of the lease object written by this holder.
+	gen int64
+}
+
+// Acquire acquires the lease name for holder, until it is released or ttl
+// passes without a renewal. It returns a HeldError if another holder has it.
+func Acquire(ctx context.Context, bh *storage.BucketHandle, name, holder string, ttl time.Duration) (*Lease, error) {
+	return acquire(ctx, gcsObject{oh: bh.Object(Prefix + name)}, name, holder, ttl)
+}
+
+func acquire(ctx context.Context, obj object, name, holder string, ttl time.Duration) (*Lease, error) {
+	l := &Lease{
+		Name:   name,
+		Holder: holder,
+		obj:    obj,
+		ttl:    ttl,
+	}
+	actx, cancel := context.WithTimeout(ctx, requestTimeout)
+	attrs, err := l.obj.attrs(actx)
+	cancel()
+	var cond storage.Conditions
+	switch {
+	case err == storage.ErrObjectNotExist:
+		cond = storage.Conditions{DoesNotExist: true}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read lease %s: %v", name, err)
+	default:
+		expires, _ := time.Parse(time.RFC3339, attrs.Metadata["expires"])
+		if h := attrs.Metadata["holder"]; h != holder && time.Now().Before(expires) {
+			return nil, &HeldError{Name: name, Holder: h, Expires: expires}
+		}
+		// The lease expired, or is a leftover of this holder.
+		cond = storage.Conditions{GenerationMatch: attrs.Generation}
+	}
+	if err := l.write(ctx, cond); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// write writes the lease object, and records its generation.
+func (l *Lease) write(ctx context.Context, cond storage.Conditions) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	attrs, err := l.obj.write(ctx, cond, map[string]string{
+		"holder":  l.Holder,
+		"expires": time.Now().Add(l.ttl).UTC().Format(time.RFC3339),
+	}, []byte(l.Holder+"\n"))
+	if err != nil {
+		if preconditionFailed(err) {
+			return fmt.Errorf("lease %s was taken by another holder", l.Name)
+		}
+		return fmt.Errorf("failed to write lease %s: %v", l.Name, err)
+	}
+	l.gen = attrs.Generation
+	return nil
+}
+
+// preconditionFailed reports whether a GCS request failed its preconditions.
+func preconditionFailed(err error) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == http.StatusPreconditionFailed
+}
+
+// Renew extends the lease by its ttl. It fails if the lease was taken over.
+func (l *Lease) Renew(ctx context.Context) error {
+	return l.write(ctx, storage.Conditions{GenerationMatch: l.gen})
+}
+
+// Keep renews the lease every interval until ctx is done. It returns the
+// error of a failed renewal, the lease is lost then.
+func (l *Lease) Keep(ctx context.Context, interval time.Duration) error {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+			if err := l.Renew(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Release deletes the lease, unless it was taken over.
+func (l *Lease) Release(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	if err := l.obj.delete(ctx, storage.Conditions{GenerationMatch: l.gen}); err != nil {
+		if preconditionFailed(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to release lease %s: %v", l.Name, err)
+	}
+	return nil
+}
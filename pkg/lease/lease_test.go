@@ -0,0 +1,129 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// memObject is a lease object in memory, which honours the generation
+// preconditions of its writes and deletes as GCS does.
+type memObject struct {
+	cur *storage.ObjectAttrs
+	gen int64
+}
+
+func (o *memObject) met(cond storage.Conditions) error {
+	switch {
+	case cond.DoesNotExist && o.cur != nil,
+		cond.GenerationMatch != 0 && (o.cur == nil || o.cur.Generation != cond.GenerationMatch):
+		return &googleapi.Error{Code: http.StatusPreconditionFailed}
+	}
+	return nil
+}
+
+func (o *memObject) attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	if o.cur == nil {
+		return nil, storage.ErrObjectNotExist
+	}
+	return o.cur, nil
+}
+
+func (o *memObject) write(ctx context.Context, cond storage.Conditions, metadata map[string]string, content []byte) (*storage.ObjectAttrs, error) {
+	if err := o.met(cond); err != nil {
+		return nil, err
+	}
+	o.gen++
+	o.cur = &storage.ObjectAttrs{Generation: o.gen, Metadata: metadata, Size: int64(len(content))}
+	return o.cur, nil
+}
+
+func (o *memObject) delete(ctx context.Context, cond storage.Conditions) error {
+	if err := o.met(cond); err != nil {
+		return err
+	}
+	if o.cur == nil {
+		return storage.ErrObjectNotExist
+	}
+	o.cur = nil
+	return nil
+}
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	objs := map[string]*memObject{}
+	acq := func(name, holder string, ttl time.Duration) (*Lease, error) {
+		if objs[name] == nil {
+			objs[name] = &memObject{}
+		}
+		return acquire(ctx, objs[name], name, holder, ttl)
+	}
+
+	l, err := acq("route-views2", "host-a/1", time.Hour)
+	if err != nil {
+		t.Fatalf("Acquire() = %v; want nil err", err)
+	}
+
+	// A second holder is refused while the lease is held.
+	_, err = acq("route-views2", "host-b/2", time.Hour)
+	var held *HeldError
+	if !errors.As(err, &held) || held.Holder != "host-a/1" {
+		t.Fatalf("Acquire(held) = %v; want a HeldError of host-a/1", err)
+	}
+
+	// Other names are independent.
+	if _, err := acq("route-views3", "host-b/2", time.Hour); err != nil {
+		t.Errorf("Acquire(other name) = %v; want nil err", err)
+	}
+
+	if err := l.Renew(ctx); err != nil {
+		t.Errorf("Renew() = %v; want nil err", err)
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Fatalf("Release() = %v; want nil err", err)
+	}
+	if objs["route-views2"].cur != nil {
+		t.Errorf("lease object after Release(): %+v; want none", objs["route-views2"].cur)
+	}
+
+	// An expired lease is taken over.
+	expired, err := acq("route-views2", "host-a/1", -time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() = %v; want nil err", err)
+	}
+	if _, err := acq("route-views2", "host-b/2", time.Hour); err != nil {
+		t.Errorf("Acquire(expired) = %v; want nil err", err)
+	}
+
+	// The holder of the expired lease can neither renew nor release it.
+	if err := expired.Renew(ctx); err == nil {
+		t.Error("Renew(taken over) = nil err; want err")
+	}
+	if err := expired.Release(ctx); err != nil {
+		t.Errorf("Release(taken over) = %v; want nil err", err)
+	}
+	if got := objs["route-views2"].cur; got == nil || got.Metadata["holder"] != "host-b/2" {
+		t.Errorf("lease object after Release(taken over): %+v; want the lease of host-b/2", got)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	obj := &memObject{}
+	l, err := acquire(ctx, obj, "route-views2", "host-a/1", -time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() = %v; want nil err", err)
+	}
+	if _, err := acquire(ctx, obj, "route-views2", "host-b/2", time.Hour); err != nil {
+		t.Fatalf("Acquire(expired) = %v; want nil err", err)
+	}
+	if err := l.Keep(ctx, time.Millisecond); err == nil {
+		t.Error("Keep(taken over) = nil err; want err")
+	}
+}
@@ -0,0 +1,107 @@
+// Package listing caches the GCS object listing of the directories of a
+// bucket, so that a sync looks up the attributes of every file in a
+// directory with one list call rather than a call per file.
+package listing
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// maxDirs is the number of directories cached, a sync walks the directories
+// in turn so only the most recent are needed.
+const maxDirs = 64
+
+// Cache is a cache of directory listings, it is safe for concurrent use.
+type Cache struct {
+	bh *storage.BucketHandle
+
+	mu   sync.Mutex
+	dirs map[string]*dir
+	// order is the order the directories were listed in, oldest first.
+	order []string
+}
+
+type dir struct {
+	// ready is closed once the listing completes.
+	ready   chan struct{}
+	objects map[string]*storage.ObjectAttrs
+	err     error
+}
+
+// New returns an empty cache of the bucket.
+func New(bh *storage.BucketHandle) *Cache {
+	return &Cache{bh: bh, dirs: map[string]*dir{}}
+}
+
+// Attrs returns the attributes of the object name, or storage.ErrObjectNotExist.
+// The directory of the object is listed on first use.
+func (c *Cache) Attrs(ctx context.Context, name string) (*storage.ObjectAttrs, error) {
+	prefix := path.Dir(name) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+
+	c.mu.Lock()
+	d, ok := c.dirs[prefix]
+	if !ok {
+		d = &dir{ready: make(chan struct{})}
+		c.dirs[prefix] = d
+		c.order = append(c.order, prefix)
+		if len(c.order) > maxDirs {
+			delete(c.dirs, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		d.objects, d.err = c.list(ctx, prefix)
+		close(d.ready)
+		if d.err != nil {
+			// Do not cache the failure, the next lookup lists again.
+			c.mu.Lock()
+			if c.dirs[prefix] == d {
+				delete(c.dirs, prefix)
+			}
+			c.mu.Unlock()
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-d.ready:
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	attrs, ok := d.objects[name]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return attrs, nil
+}
+
+// list lists the objects directly under prefix.
+func (c *Cache) list(ctx context.Context, prefix string) (map[string]*storage.ObjectAttrs, error) {
+	objects := map[string]*storage.ObjectAttrs{}
+	it := c.bh.Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the objects under %q: %v", prefix, err)
+		}
+		// Sub-directories are listed as prefixes, without a name.
+		if attrs.Name != "" {
+			objects[attrs.Name] = attrs
+		}
+	}
+}
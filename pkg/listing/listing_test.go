@@ -0,0 +1,57 @@
+package listing
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+)
+
+func TestAttrs(t *testing.T) {
+	ctx := context.Background()
+	fakegcs := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2"},
+			Content:     []byte("foo"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2"},
+			Content:     []byte("foobar"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "README"},
+			Content:     []byte("baz"),
+		},
+	})
+	defer fakegcs.Stop()
+	c := New(fakegcs.Client().Bucket("archives"))
+
+	tests := []struct {
+		name     string
+		wantSize int64
+		wantErr  error
+	}{
+		{name: "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", wantSize: 3},
+		{name: "bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2", wantSize: 6},
+		{name: "bgpdata/2022.01/UPDATES/updates.20220109.1900.bz2", wantErr: storage.ErrObjectNotExist},
+		{name: "bgpdata/2022.01/UPDATES", wantErr: storage.ErrObjectNotExist},
+		{name: "README", wantSize: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attrs, err := c.Attrs(ctx, test.name)
+			if err != test.wantErr {
+				t.Fatalf("Attrs() = %v; want %v", err, test.wantErr)
+			}
+			if err == nil && attrs.Size != test.wantSize {
+				t.Errorf("Attrs() size = %d; want %d", attrs.Size, test.wantSize)
+			}
+		})
+	}
+	// The directories of the updates, of the root and of the UPDATES
+	// directory looked up as an object.
+	if len(c.dirs) != 3 {
+		t.Errorf("listed %d directories; want 3", len(c.dirs))
+	}
+}
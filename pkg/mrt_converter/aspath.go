@@ -0,0 +1,134 @@
+package converter
+
+import "github.com/osrg/gobgp/pkg/packet/bgp"
+
+// asSegment is a segment of an AS path, of 4-octet ASes.
+type asSegment struct {
+	typ uint8
+	as  []uint32
+}
+
+// confed returns whether the segment is of the confederation of the peer,
+// which does not count in the length of the path, RFC 5065.
+func (s asSegment) confed() bool {
+	return s.typ == bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ || s.typ == bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SET
+}
+
+// length is the length of the segment in the path: an AS_SET counts as one.
+func (s asSegment) length() int {
+	switch {
+	case s.confed():
+		return 0
+	case s.typ == bgp.BGP_ASPATH_ATTR_TYPE_SET:
+		return 1
+	}
+	return len(s.as)
+}
+
+func pathLength(segs []asSegment) int {
+	n := 0
+	for _, s := range segs {
+		n += s.length()
+	}
+	return n
+}
+
+// asPathSegments returns the AS path of the path attributes: the AS_PATH, with
+// the AS4_PATH of a 2-octet session merged in, RFC 6793: the leading ASes of
+// the AS_PATH which the AS4_PATH lacks, then the AS4_PATH. An AS4_PATH longer
+// than the AS_PATH is ignored.
+func asPathSegments(attrs []bgp.PathAttributeInterface) []asSegment {
+	var path, path4 []asSegment
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeAsPath:
+			for _, p := range a.Value {
+				switch p := p.(type) {
+				case *bgp.As4PathParam:
+					path = append(path, asSegment{typ: p.Type, as: p.AS})
+				case *bgp.AsPathParam:
+					as := make([]uint32, len(p.AS))
+					for i, v := range p.AS {
+						as[i] = uint32(v)
+					}
+					path = append(path, asSegment{typ: p.Type, as: as})
+				}
+			}
+		case *bgp.PathAttributeAs4Path:
+			for _, p := range a.Value {
+				path4 = append(path4, asSegment{typ: p.Type, as: p.AS})
+			}
+		}
+	}
+	keep := pathLength(path) - pathLength(path4)
+	if len(path4) == 0 || keep < 0 {
+		return path
+	}
+	var merged []asSegment
+	for _, s := range path {
+		if keep <= 0 {
+			break
+		}
+		if l := s.length(); l > keep {
+			s.as = s.as[:keep]
+		}
+		keep -= s.length()
+		merged = append(merged, s)
+	}
+	return append(merged, path4...)
+}
+
+// pathAnalysis is derived from the AS path of a route, so the queries need
+// not parse the payload of the AS_PATH.
+type pathAnalysis struct {
+	// OriginAS is the last AS of the path, none if the path ends in an
+	// AS_SET.
+	OriginAS uint32
+	// Length is the length of the path, RFC 4271: an AS_SET counts as one,
+	// the confederation segments as none.
+	Length int
+	// Prepends is the count of the ASes which repeat the AS before them.
+	Prepends int
+	HasASSet bool
+	// FirstHopAS is the first AS of the path, LastHopAS the AS before the
+	// origin, skipping the prepends of the origin; none if it is an AS_SET.
+	FirstHopAS uint32
+	LastHopAS  uint32
+}
+
+// analyzeASPath analyzes the AS path of the path attributes, of the ASes out
+// of the confederation of the peer, nil if there is none.
+func analyzeASPath(attrs []bgp.PathAttributeInterface) *pathAnalysis {
+	a := &pathAnalysis{}
+	// seq are the ASes of the path, 0 for an AS_SET.
+	var seq []uint32
+	for _, s := range asPathSegments(attrs) {
+		switch {
+		case s.confed():
+			continue
+		case s.typ == bgp.BGP_ASPATH_ATTR_TYPE_SET:
+			a.HasASSet = true
+			seq = append(seq, 0)
+			continue
+		}
+		for _, as := range s.as {
+			if len(seq) > 0 && seq[len(seq)-1] == as {
+				a.Prepends++
+			}
+			seq = append(seq, as)
+		}
+	}
+	if len(seq) == 0 {
+		return nil
+	}
+	a.Length = len(seq)
+	a.FirstHopAS = seq[0]
+	a.OriginAS = seq[len(seq)-1]
+	for i := len(seq) - 2; i >= 0; i-- {
+		if seq[i] != a.OriginAS || a.OriginAS == 0 {
+			a.LastHopAS = seq[i]
+			break
+		}
+	}
+	return a
+}
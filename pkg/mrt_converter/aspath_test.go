@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+func as4Segment(typ uint8, as ...uint32) *bgp.As4PathParam {
+	return &bgp.As4PathParam{Type: typ, Num: uint8(len(as)), AS: as}
+}
+
+func as4Path(segs ...*bgp.As4PathParam) *bgp.PathAttributeAsPath {
+	p := &bgp.PathAttributeAsPath{}
+	for _, s := range segs {
+		p.Value = append(p.Value, s)
+	}
+	return p
+}
+
+func TestAnalyzeASPath(t *testing.T) {
+	const seq, set, confed = bgp.BGP_ASPATH_ATTR_TYPE_SEQ, bgp.BGP_ASPATH_ATTR_TYPE_SET, bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ
+	tests := []struct {
+		desc  string
+		attrs []bgp.PathAttributeInterface
+		want  *pathAnalysis
+	}{
+		{
+			desc: "no AS path",
+		}, {
+			desc:  "prepended path",
+			attrs: []bgp.PathAttributeInterface{as4Path(as4Segment(seq, 3356, 3356, 174, 65001, 65001, 65001))},
+			want:  &pathAnalysis{OriginAS: 65001, Length: 6, Prepends: 3, FirstHopAS: 3356, LastHopAS: 174},
+		}, {
+			desc:  "prepended origin only",
+			attrs: []bgp.PathAttributeInterface{as4Path(as4Segment(seq, 65001, 65001))},
+			want:  &pathAnalysis{OriginAS: 65001, Length: 2, Prepends: 1, FirstHopAS: 65001},
+		}, {
+			desc:  "path ending in an AS_SET",
+			attrs: []bgp.PathAttributeInterface{as4Path(as4Segment(seq, 3356, 174), as4Segment(set, 65001, 65002))},
+			want:  &pathAnalysis{Length: 3, HasASSet: true, FirstHopAS: 3356, LastHopAS: 174},
+		}, {
+			desc:  "confederation segment skipped",
+			attrs: []bgp.PathAttributeInterface{as4Path(as4Segment(confed, 65100, 65101), as4Segment(seq, 3356, 65001))},
+			want:  &pathAnalysis{OriginAS: 65001, Length: 2, FirstHopAS: 3356, LastHopAS: 3356},
+		}, {
+			desc: "2-octet path merged with the AS4_PATH",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeAsPath{Value: []bgp.AsPathParamInterface{
+					&bgp.AsPathParam{Type: seq, Num: 3, AS: []uint16{701, 23456, 23456}},
+				}},
+				&bgp.PathAttributeAs4Path{Value: []*bgp.As4PathParam{as4Segment(seq, 100000, 4200000000)}},
+			},
+			want: &pathAnalysis{OriginAS: 4200000000, Length: 3, FirstHopAS: 701, LastHopAS: 100000},
+		}, {
+			desc: "AS4_PATH longer than the AS_PATH ignored",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeAsPath{Value: []bgp.AsPathParamInterface{
+					&bgp.AsPathParam{Type: seq, Num: 1, AS: []uint16{701}},
+				}},
+				&bgp.PathAttributeAs4Path{Value: []*bgp.As4PathParam{as4Segment(seq, 100000, 4200000000)}},
+			},
+			want: &pathAnalysis{OriginAS: 701, Length: 1, FirstHopAS: 701},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if diff := cmp.Diff(analyzeASPath(test.attrs), test.want); diff != "" {
+				t.Errorf("analyzeASPath() got diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
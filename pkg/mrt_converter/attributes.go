@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+// asTrans is the AS of the 2-octet attributes of a 4-octet AS, RFC 6793.
+const asTrans = 23456
+
+// origins are the names of the values of ORIGIN.
+var origins = map[uint8]string{
+	bgp.BGP_ORIGIN_ATTR_TYPE_IGP:        "IGP",
+	bgp.BGP_ORIGIN_ATTR_TYPE_EGP:        "EGP",
+	bgp.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE: "INCOMPLETE",
+}
+
+// pathAttributes are the standard path attributes of a route, decoded, and the
+// attributes GoBGP does not know, raw; all of them are kept in the payloads of
+// the attributes as well.
+type pathAttributes struct {
+	// Origin is IGP, EGP or INCOMPLETE.
+	Origin string `json:",omitempty"`
+	// NextHop is the NEXT_HOP, or the next hop of the MP_REACH_NLRI.
+	NextHop string `json:",omitempty"`
+	// MED and LocalPref are nil if the route lacks them.
+	MED             *uint32 `json:",omitempty"`
+	LocalPref       *uint32 `json:",omitempty"`
+	AtomicAggregate bool    `json:",omitempty"`
+	// AggregatorAS is the AS of the AGGREGATOR, or of the AS4_AGGREGATOR of a
+	// 2-octet session.
+	AggregatorAS      uint32 `json:",omitempty"`
+	AggregatorAddress string `json:",omitempty"`
+	// Communities are the communities of RFC 1997, ie: 65000:100.
+	Communities  []string `json:",omitempty"`
+	OriginatorID string   `json:",omitempty"`
+	ClusterList  []string `json:",omitempty"`
+	// Unknown are the attributes of types GoBGP does not know, ie: of newer
+	// RFCs, or of private use.
+	Unknown []*unknownAttribute `json:",omitempty"`
+}
+
+// unknownAttribute is an attribute of a type GoBGP does not know, its value
+// in hex.
+type unknownAttribute struct {
+	AttrType bgp.BGPAttrType
+	Flags    bgp.BGPAttrFlag
+	Value    string
+}
+
+// translatePathAttributes decodes the path attributes, nil if there are none
+// of pathAttributes.
+func translatePathAttributes(attrs []bgp.PathAttributeInterface) *pathAttributes {
+	p := &pathAttributes{}
+	found := false
+	var mpReach string
+	var as4Aggregator *bgp.PathAttributeAggregatorParam
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeOrigin:
+			p.Origin = origins[a.Value]
+		case *bgp.PathAttributeNextHop:
+			p.NextHop = ipString(a.Value)
+		case *bgp.PathAttributeMpReachNLRI:
+			mpReach = mpNextHop(a)
+		case *bgp.PathAttributeMultiExitDisc:
+			v := a.Value
+			p.MED = &v
+		case *bgp.PathAttributeLocalPref:
+			v := a.Value
+			p.LocalPref = &v
+		case *bgp.PathAttributeAtomicAggregate:
+			p.AtomicAggregate = true
+		case *bgp.PathAttributeAggregator:
+			p.AggregatorAS = a.Value.AS
+			p.AggregatorAddress = ipString(a.Value.Address)
+		case *bgp.PathAttributeAs4Aggregator:
+			as4Aggregator = &a.Value
+		case *bgp.PathAttributeCommunities:
+			for _, c := range a.Value {
+				p.Communities = append(p.Communities, fmt.Sprintf("%d:%d", c>>16, c&0xffff))
+			}
+		case *bgp.PathAttributeOriginatorId:
+			p.OriginatorID = ipString(a.Value)
+		case *bgp.PathAttributeClusterList:
+			for _, id := range a.Value {
+				p.ClusterList = append(p.ClusterList, ipString(id))
+			}
+		case *bgp.PathAttributeUnknown:
+			p.Unknown = append(p.Unknown, &unknownAttribute{
+				AttrType: a.Type,
+				Flags:    a.Flags,
+				Value:    hex.EncodeToString(a.Value),
+			})
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	if p.NextHop == "" {
+		p.NextHop = mpReach
+	}
+	// The AS4_AGGREGATOR is ignored unless the AGGREGATOR is of AS_TRANS.
+	if as4Aggregator != nil && p.AggregatorAS == asTrans {
+		p.AggregatorAS = as4Aggregator.AS
+		p.AggregatorAddress = ipString(as4Aggregator.Address)
+	}
+	return p
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+func TestTranslatePathAttributes(t *testing.T) {
+	med, localPref := uint32(0), uint32(200)
+	tests := []struct {
+		desc  string
+		attrs []bgp.PathAttributeInterface
+		want  *pathAttributes
+	}{
+		{
+			desc: "no attributes",
+		}, {
+			desc:  "AS path only",
+			attrs: []bgp.PathAttributeInterface{as4Path(as4Segment(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, 100000))},
+		}, {
+			desc: "standard attributes",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeOrigin{Value: bgp.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE},
+				&bgp.PathAttributeNextHop{Value: net.ParseIP("192.0.2.1")},
+				&bgp.PathAttributeMultiExitDisc{Value: 0},
+				&bgp.PathAttributeLocalPref{Value: 200},
+				&bgp.PathAttributeAtomicAggregate{},
+				&bgp.PathAttributeAggregator{Value: bgp.PathAttributeAggregatorParam{AS: 65001, Address: net.ParseIP("192.0.2.2")}},
+				&bgp.PathAttributeCommunities{Value: []uint32{65000<<16 | 100, 0xffffff01}},
+				&bgp.PathAttributeOriginatorId{Value: net.ParseIP("192.0.2.3")},
+				&bgp.PathAttributeClusterList{Value: []net.IP{net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.5")}},
+			},
+			want: &pathAttributes{
+				Origin:            "INCOMPLETE",
+				NextHop:           "192.0.2.1",
+				MED:               &med,
+				LocalPref:         &localPref,
+				AtomicAggregate:   true,
+				AggregatorAS:      65001,
+				AggregatorAddress: "192.0.2.2",
+				Communities:       []string{"65000:100", "65535:65281"},
+				OriginatorID:      "192.0.2.3",
+				ClusterList:       []string{"192.0.2.4", "192.0.2.5"},
+			},
+		}, {
+			desc: "next hop of MP_REACH_NLRI",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeMpReachNLRI{Nexthop: net.ParseIP("2001:db8::1")},
+			},
+			want: &pathAttributes{NextHop: "2001:db8::1"},
+		}, {
+			desc: "AS4_AGGREGATOR of a 2-octet session",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeAggregator{Value: bgp.PathAttributeAggregatorParam{AS: asTrans, Address: net.ParseIP("192.0.2.2")}},
+				&bgp.PathAttributeAs4Aggregator{Value: bgp.PathAttributeAggregatorParam{AS: 4200000000, Address: net.ParseIP("192.0.2.6")}},
+			},
+			want: &pathAttributes{AggregatorAS: 4200000000, AggregatorAddress: "192.0.2.6"},
+		}, {
+			desc: "unknown attribute",
+			attrs: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeUnknown{
+					PathAttribute: bgp.PathAttribute{Flags: bgp.BGP_ATTR_FLAG_OPTIONAL | bgp.BGP_ATTR_FLAG_TRANSITIVE, Type: 0xFF},
+					Value:         []byte{0xde, 0xad},
+				},
+			},
+			want: &pathAttributes{Unknown: []*unknownAttribute{{AttrType: 0xFF, Flags: 0xC0, Value: "dead"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if diff := cmp.Diff(translatePathAttributes(test.attrs), test.want); diff != "" {
+				t.Errorf("translatePathAttributes() got diff (-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPathAttributesField(t *testing.T) {
+	localPref := uint32(200)
+	b := appendPathAttributesField(nil, 12, &pathAttributes{
+		Origin:    "IGP",
+		LocalPref: &localPref,
+		Unknown:   []*unknownAttribute{{AttrType: 0xFF, Flags: 0xC0, Value: "dead"}},
+	})
+	unknown := string(appendStr(appendInt(appendInt(nil, 1, 0xFF), 2, 0xC0), 3, "dead"))
+	got := decodeRow(t, []byte(decodeRow(t, b)[0].Bytes))
+	// The attributes the route lacks are not in the row.
+	want := []field{
+		{Num: 1, Bytes: "IGP"},
+		{Num: 4, Int: 200},
+		{Num: 11, Bytes: unknown},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("appendPathAttributesField() got diff (-got +want):\n%s", diff)
+	}
+	if b := appendPathAttributesField(nil, 12, nil); b != nil {
+		t.Errorf("appendPathAttributesField(nil) = %x, want nil", b)
+	}
+}
@@ -0,0 +1,182 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/routeviews/google-cloud-storage/pkg/avro"
+)
+
+// The Avro schemas of the converted records, of the fields of the BigQuery
+// rows. The timestamps are in microseconds.
+const (
+	avroAttribute = `{"type":"record","name":"Attribute","fields":[` +
+		`{"name":"AttrType","type":"int"},{"name":"Payload","type":"string"}]}`
+	updateSchema = `{"type":"record","name":"Update","namespace":"org.routeviews.mrt","fields":[` +
+		`{"name":"Collector","type":"string"},` +
+		`{"name":"SeenAt","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+		`{"name":"PeerAS","type":"long"},` +
+		`{"name":"Announced","type":{"type":"array","items":"string"}},` +
+		`{"name":"Withdrawn","type":{"type":"array","items":"string"}},` +
+		`{"name":"Attributes","type":{"type":"array","items":` + avroAttribute + `}},` +
+		`{"name":"AnnouncedPathIDs","type":{"type":"array","items":"long"}},` +
+		`{"name":"WithdrawnPathIDs","type":{"type":"array","items":"long"}}]}`
+	ribSchema = `{"type":"record","name":"RibEntry","namespace":"org.routeviews.mrt","fields":[` +
+		`{"name":"Collector","type":"string"},` +
+		`{"name":"DumpedAt","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+		`{"name":"OriginatedAt","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+		`{"name":"PeerAS","type":"long"},` +
+		`{"name":"PeerIP","type":"string"},` +
+		`{"name":"PeerBGPID","type":"string"},` +
+		`{"name":"Prefix","type":"string"},` +
+		`{"name":"PathID","type":"long"},` +
+		`{"name":"Attributes","type":{"type":"array","items":` + avroAttribute + `}}]}`
+)
+
+// avroSchemaVersion is the version of the schemas the files are stamped with,
+// bumped with each change of the schemas.
+const avroSchemaVersion = 1
+
+// avroKinds are the schema of each kind of record, and its fully qualified
+// name, the subject of the schema in a schema registry of the record name
+// strategy.
+var avroKinds = map[string]struct{ schema, subject string }{
+	"updates": {updateSchema, "org.routeviews.mrt.Update"},
+	"ribs":    {ribSchema, "org.routeviews.mrt.RibEntry"},
+}
+
+// AvroName returns the name of the Avro file of the records of a kind, updates
+// or ribs, of an archive, see partitionName, ie:
+// updates/collector=route-views2/date=2021-09-01/updates.20210901.0000.avro.
+func AvroName(kind, collector string, t time.Time, obj string) string {
+	return partitionName(kind, collector, t, obj, ".avro")
+}
+
+func appendMicros(b []byte, t time.Time) []byte {
+	return avro.AppendLong(b, t.UnixNano()/int64(time.Microsecond))
+}
+
+func appendAttributes(b []byte, attrs []*attributePayload) []byte {
+	if len(attrs) > 0 {
+		b = avro.AppendLong(b, int64(len(attrs)))
+		for _, a := range attrs {
+			b = avro.AppendString(avro.AppendLong(b, int64(a.AttrType)), a.Payload)
+		}
+	}
+	return avro.AppendLong(b, 0)
+}
+
+func pathIDs(ids []uint32) []int64 {
+	var res []int64
+	for _, id := range ids {
+		res = append(res, int64(id))
+	}
+	return res
+}
+
+type avroFile struct {
+	wc io.WriteCloser
+	w  *avro.Writer
+}
+
+// avroSink is the recordSink of Avro files, a file per kind of record, opened
+// with the first record of its kind. Each file is stamped with the metadata
+// of its schema: its subject and version, and its schema registry ID if it
+// has one, and with the collector and archive of its records.
+type avroSink struct {
+	collector string
+	obj       string
+	// ids are the schema registry IDs of the schemas, by kind.
+	ids     map[string]int
+	open    func(name string) io.WriteCloser
+	updates *avroFile
+	ribs    *avroFile
+	err     error
+}
+
+func newAvroSink(collector, obj string, ids map[string]int, open func(name string) io.WriteCloser) *avroSink {
+	return &avroSink{collector: collector, obj: obj, ids: ids, open: open}
+}
+
+// file returns the file of a kind, opened if it is not already.
+func (s *avroSink) file(f **avroFile, kind string, t time.Time) *avroFile {
+	if *f == nil {
+		k := avroKinds[kind]
+		meta := map[string]string{
+			"rv.schema.subject": k.subject,
+			"rv.schema.version": strconv.Itoa(avroSchemaVersion),
+			"rv.collector":      s.collector,
+			"rv.archive":        s.obj,
+		}
+		if id, ok := s.ids[kind]; ok {
+			meta["rv.schema.id"] = strconv.Itoa(id)
+		}
+		wc := s.open(AvroName(kind, s.collector, t, s.obj))
+		w, err := avro.NewWriter(wc, k.schema, meta, 0)
+		if err != nil {
+			s.err = fmt.Errorf("avro.NewWriter(%s): %v", kind, err)
+		}
+		*f = &avroFile{wc: wc, w: w}
+	}
+	return *f
+}
+
+func (s *avroSink) addUpdate(u *update) error {
+	if s.err != nil {
+		return s.err
+	}
+	f := s.file(&s.updates, "updates", u.SeenAt)
+	if s.err != nil {
+		return s.err
+	}
+	b := avro.AppendString(nil, u.Collector)
+	b = appendMicros(b, u.SeenAt)
+	b = avro.AppendLong(b, int64(u.PeerAS))
+	b = avro.AppendStrings(b, u.Announced)
+	b = avro.AppendStrings(b, u.Withdrawn)
+	b = appendAttributes(b, u.Attributes)
+	b = avro.AppendLongs(b, pathIDs(u.AnnouncedPathIDs))
+	b = avro.AppendLongs(b, pathIDs(u.WithdrawnPathIDs))
+	s.err = f.w.Append(b)
+	return s.err
+}
+
+func (s *avroSink) addRib(rows []*ribEntry) error {
+	for _, r := range rows {
+		if s.err != nil {
+			return s.err
+		}
+		f := s.file(&s.ribs, "ribs", r.DumpedAt)
+		if s.err != nil {
+			return s.err
+		}
+		b := avro.AppendString(nil, r.Collector)
+		b = appendMicros(b, r.DumpedAt)
+		b = appendMicros(b, r.OriginatedAt)
+		b = avro.AppendLong(b, int64(r.PeerAS))
+		b = avro.AppendString(b, r.PeerIP)
+		b = avro.AppendString(b, r.PeerBGPID)
+		b = avro.AppendString(b, r.Prefix)
+		b = avro.AppendLong(b, int64(r.PathID))
+		b = appendAttributes(b, r.Attributes)
+		s.err = f.w.Append(b)
+	}
+	return s.err
+}
+
+func (s *avroSink) close() error {
+	for _, f := range []*avroFile{s.updates, s.ribs} {
+		if f == nil {
+			continue
+		}
+		if s.err == nil && f.w != nil {
+			s.err = f.w.Close()
+		}
+		if err := f.wc.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAvroName(t *testing.T) {
+	ts := time.Date(2021, 9, 1, 0, 15, 0, 0, time.UTC)
+	got := AvroName("updates", "route-views2", ts, "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2")
+	if want := "updates/collector=route-views2/date=2021-09-01/updates.20210901.0000.avro"; got != want {
+		t.Errorf("AvroName() = %q, want %q", got, want)
+	}
+}
+
+func TestAvroSink(t *testing.T) {
+	files := map[string]*nopCloser{}
+	s := newAvroSink("rrc00", "ripe-ris/rrc00/2021.09/bview.20210901.0000.gz", map[string]int{"ribs": 13}, func(name string) io.WriteCloser {
+		files[name] = &nopCloser{Buffer: &bytes.Buffer{}}
+		return files[name]
+	})
+	ts := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	rows := []*ribEntry{{
+		Collector:    "rrc00",
+		DumpedAt:     ts,
+		OriginatedAt: ts.Add(-time.Hour),
+		PeerAS:       100000,
+		PeerIP:       "192.0.2.1",
+		PeerBGPID:    "192.0.2.1",
+		Prefix:       "10.0.0.0/24",
+		Attributes:   []*attributePayload{fourOctetASPath},
+	}}
+	if err := s.addRib(rows); err != nil {
+		t.Fatalf("addRib() got err: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("close() got err: %v", err)
+	}
+	name := "ribs/collector=rrc00/date=2021-09-01/bview.20210901.0000.avro"
+	f, ok := files[name]
+	if !ok || len(files) != 1 {
+		t.Fatalf("avroSink got %d files, want %s only", len(files), name)
+	}
+	if !f.closed {
+		t.Errorf("close() left %s open", name)
+	}
+	b := f.Bytes()
+	if !bytes.HasPrefix(b, []byte("Obj\x01")) {
+		t.Fatalf("%s is not an Avro file", name)
+	}
+	for _, want := range []string{"org.routeviews.mrt.RibEntry", "rv.schema.id", "rv.collector", "rrc00", "ripe-ris/rrc00/2021.09/bview.20210901.0000.gz"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("%s got no %q in its header", name, want)
+		}
+	}
+}
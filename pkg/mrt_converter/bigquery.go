@@ -0,0 +1,476 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// bqBatchBytes and bqBatchRows bound the rows of an append, well within
+	// the 10MB of an AppendRows request.
+	bqBatchBytes = 8 << 20
+	bqBatchRows  = 10000
+	// bqPipelined is the most appends of a stream in flight, their results
+	// are waited for once there are more.
+	bqPipelined = 16
+)
+
+func bqField(name string, num int32, label descriptorpb.FieldDescriptorProto_Label, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(num),
+		Label:  label.Enum(),
+		Type:   typ.Enum(),
+	}
+}
+
+const (
+	bqOptional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	bqRepeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	bqInt64    = descriptorpb.FieldDescriptorProto_TYPE_INT64
+	bqString   = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	bqBool     = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+)
+
+// bqNested is a repeated field of a row of a nested message, the descriptors
+// of the rows are self-contained.
+func bqNested(name, msg string, num int32, fields ...*descriptorpb.FieldDescriptorProto) (*descriptorpb.FieldDescriptorProto, *descriptorpb.DescriptorProto) {
+	f := bqField(name, num, bqRepeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	f.TypeName = proto.String(msg)
+	return f, &descriptorpb.DescriptorProto{Name: proto.String(msg), Field: fields}
+}
+
+// bqAttributes is the field of the path attributes of a row.
+func bqAttributes(num int32) (*descriptorpb.FieldDescriptorProto, *descriptorpb.DescriptorProto) {
+	return bqNested("Attributes", "Attribute", num,
+		bqField("AttrType", 1, bqOptional, bqInt64),
+		bqField("Payload", 2, bqOptional, bqString))
+}
+
+// bqCommunities are the fields of the extended and large communities of a
+// row, and their messages.
+func bqCommunities(num int32) ([]*descriptorpb.FieldDescriptorProto, []*descriptorpb.DescriptorProto) {
+	ext, extMsg := bqNested("ExtendedCommunities", "ExtendedCommunity", num,
+		bqField("Type", 1, bqOptional, bqInt64),
+		bqField("Subtype", 2, bqOptional, bqInt64),
+		bqField("Value", 3, bqOptional, bqString))
+	large, largeMsg := bqNested("LargeCommunities", "LargeCommunity", num+1,
+		bqField("GlobalAdmin", 1, bqOptional, bqInt64),
+		bqField("LocalData1", 2, bqOptional, bqInt64),
+		bqField("LocalData2", 3, bqOptional, bqInt64))
+	return []*descriptorpb.FieldDescriptorProto{ext, large}, []*descriptorpb.DescriptorProto{extMsg, largeMsg}
+}
+
+// bqASPath is the field of the analysis of the AS path of a row, a single
+// nested message, and its message.
+func bqASPath(num int32) (*descriptorpb.FieldDescriptorProto, *descriptorpb.DescriptorProto) {
+	f, msg := bqNested("ASPath", "ASPathAnalysis", num,
+		bqField("OriginAS", 1, bqOptional, bqInt64),
+		bqField("Length", 2, bqOptional, bqInt64),
+		bqField("Prepends", 3, bqOptional, bqInt64),
+		bqField("HasASSet", 4, bqOptional, bqBool),
+		bqField("FirstHopAS", 5, bqOptional, bqInt64),
+		bqField("LastHopAS", 6, bqOptional, bqInt64))
+	f.Label = bqOptional.Enum()
+	return f, msg
+}
+
+// bqPathAttributes is the field of the decoded path attributes of a row, a
+// single nested message, and its message.
+func bqPathAttributes(num int32) (*descriptorpb.FieldDescriptorProto, *descriptorpb.DescriptorProto) {
+	unknown, unknownMsg := bqNested("Unknown", "UnknownAttribute", 11,
+		bqField("AttrType", 1, bqOptional, bqInt64),
+		bqField("Flags", 2, bqOptional, bqInt64),
+		bqField("Value", 3, bqOptional, bqString))
+	f, msg := bqNested("PathAttributes", "PathAttributes", num,
+		bqField("Origin", 1, bqOptional, bqString),
+		bqField("NextHop", 2, bqOptional, bqString),
+		bqField("MED", 3, bqOptional, bqInt64),
+		bqField("LocalPref", 4, bqOptional, bqInt64),
+		bqField("AtomicAggregate", 5, bqOptional, bqBool),
+		bqField("AggregatorAS", 6, bqOptional, bqInt64),
+		bqField("AggregatorAddress", 7, bqOptional, bqString),
+		bqField("Communities", 8, bqRepeated, bqString),
+		bqField("OriginatorID", 9, bqOptional, bqString),
+		bqField("ClusterList", 10, bqRepeated, bqString),
+		unknown)
+	f.Label = bqOptional.Enum()
+	msg.NestedType = []*descriptorpb.DescriptorProto{unknownMsg}
+	return f, msg
+}
+
+// updateDescriptor is the proto2 descriptor of the rows of the updates table,
+// the fields are named after its columns, the timestamps are in
+// microseconds.
+func updateDescriptor() *descriptorpb.DescriptorProto {
+	attrs, attr := bqAttributes(6)
+	comms, commMsgs := bqCommunities(9)
+	path, pathMsg := bqASPath(11)
+	pattrs, pattrsMsg := bqPathAttributes(12)
+	return &descriptorpb.DescriptorProto{
+		Name: proto.String("Update"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			bqField("Collector", 1, bqOptional, bqString),
+			bqField("SeenAt", 2, bqOptional, bqInt64),
+			bqField("PeerAS", 3, bqOptional, bqInt64),
+			bqField("Announced", 4, bqRepeated, bqString),
+			bqField("Withdrawn", 5, bqRepeated, bqString),
+			attrs,
+			bqField("AnnouncedPathIDs", 7, bqRepeated, bqInt64),
+			bqField("WithdrawnPathIDs", 8, bqRepeated, bqInt64),
+			comms[0],
+			comms[1],
+			path,
+			pattrs,
+			bqField("AddressFamily", 13, bqOptional, bqString),
+		},
+		NestedType: append([]*descriptorpb.DescriptorProto{attr, pathMsg, pattrsMsg}, commMsgs...),
+	}
+}
+
+// ribDescriptor is the proto2 descriptor of the rows of the ribs table.
+func ribDescriptor() *descriptorpb.DescriptorProto {
+	attrs, attr := bqAttributes(9)
+	comms, commMsgs := bqCommunities(10)
+	path, pathMsg := bqASPath(12)
+	pattrs, pattrsMsg := bqPathAttributes(13)
+	return &descriptorpb.DescriptorProto{
+		Name: proto.String("RibEntry"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			bqField("Collector", 1, bqOptional, bqString),
+			bqField("DumpedAt", 2, bqOptional, bqInt64),
+			bqField("OriginatedAt", 3, bqOptional, bqInt64),
+			bqField("PeerAS", 4, bqOptional, bqInt64),
+			bqField("PeerIP", 5, bqOptional, bqString),
+			bqField("PeerBGPID", 6, bqOptional, bqString),
+			bqField("Prefix", 7, bqOptional, bqString),
+			bqField("PathID", 8, bqOptional, bqInt64),
+			attrs,
+			comms[0],
+			comms[1],
+			path,
+			pattrs,
+			bqField("AddressFamily", 14, bqOptional, bqString),
+		},
+		NestedType: append([]*descriptorpb.DescriptorProto{attr, pathMsg, pattrsMsg}, commMsgs...),
+	}
+}
+
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), uint64(v))
+}
+
+func appendStr(b []byte, num protowire.Number, v string) []byte {
+	return protowire.AppendString(protowire.AppendTag(b, num, protowire.BytesType), v)
+}
+
+func appendMicrosField(b []byte, num protowire.Number, t time.Time) []byte {
+	return appendInt(b, num, t.UnixNano()/int64(time.Microsecond))
+}
+
+func appendAttributeFields(b []byte, num protowire.Number, attrs []*attributePayload) []byte {
+	for _, a := range attrs {
+		m := appendStr(appendInt(nil, 1, int64(a.AttrType)), 2, a.Payload)
+		b = protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), m)
+	}
+	return b
+}
+
+func appendCommunityFields(b []byte, num protowire.Number, ext []*extendedCommunity, large []*largeCommunity) []byte {
+	for _, c := range ext {
+		m := appendStr(appendInt(appendInt(nil, 1, int64(c.Type)), 2, int64(c.Subtype)), 3, c.Value)
+		b = protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), m)
+	}
+	for _, c := range large {
+		m := appendInt(appendInt(appendInt(nil, 1, int64(c.GlobalAdmin)), 2, int64(c.LocalData1)), 3, int64(c.LocalData2))
+		b = protowire.AppendBytes(protowire.AppendTag(b, num+1, protowire.BytesType), m)
+	}
+	return b
+}
+
+func appendASPathField(b []byte, num protowire.Number, a *pathAnalysis) []byte {
+	if a == nil {
+		return b
+	}
+	var set int64
+	if a.HasASSet {
+		set = 1
+	}
+	m := appendInt(nil, 1, int64(a.OriginAS))
+	m = appendInt(m, 2, int64(a.Length))
+	m = appendInt(m, 3, int64(a.Prepends))
+	m = appendInt(m, 4, set)
+	m = appendInt(m, 5, int64(a.FirstHopAS))
+	m = appendInt(m, 6, int64(a.LastHopAS))
+	return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), m)
+}
+
+func appendPathAttributesField(b []byte, num protowire.Number, p *pathAttributes) []byte {
+	if p == nil {
+		return b
+	}
+	// The attributes the route lacks are left null.
+	var m []byte
+	if p.Origin != "" {
+		m = appendStr(m, 1, p.Origin)
+	}
+	if p.NextHop != "" {
+		m = appendStr(m, 2, p.NextHop)
+	}
+	if p.MED != nil {
+		m = appendInt(m, 3, int64(*p.MED))
+	}
+	if p.LocalPref != nil {
+		m = appendInt(m, 4, int64(*p.LocalPref))
+	}
+	if p.AtomicAggregate {
+		m = appendInt(m, 5, 1)
+	}
+	if p.AggregatorAS != 0 {
+		m = appendInt(m, 6, int64(p.AggregatorAS))
+	}
+	if p.AggregatorAddress != "" {
+		m = appendStr(m, 7, p.AggregatorAddress)
+	}
+	for _, c := range p.Communities {
+		m = appendStr(m, 8, c)
+	}
+	if p.OriginatorID != "" {
+		m = appendStr(m, 9, p.OriginatorID)
+	}
+	for _, id := range p.ClusterList {
+		m = appendStr(m, 10, id)
+	}
+	for _, u := range p.Unknown {
+		a := appendStr(appendInt(appendInt(nil, 1, int64(u.AttrType)), 2, int64(u.Flags)), 3, u.Value)
+		m = protowire.AppendBytes(protowire.AppendTag(m, 11, protowire.BytesType), a)
+	}
+	return protowire.AppendBytes(protowire.AppendTag(b, num, protowire.BytesType), m)
+}
+
+// updateRow encodes an update as a row of updateDescriptor.
+func updateRow(u *update) []byte {
+	b := appendStr(nil, 1, u.Collector)
+	b = appendMicrosField(b, 2, u.SeenAt)
+	b = appendInt(b, 3, int64(u.PeerAS))
+	for _, p := range u.Announced {
+		b = appendStr(b, 4, p)
+	}
+	for _, p := range u.Withdrawn {
+		b = appendStr(b, 5, p)
+	}
+	b = appendAttributeFields(b, 6, u.Attributes)
+	for _, id := range u.AnnouncedPathIDs {
+		b = appendInt(b, 7, int64(id))
+	}
+	for _, id := range u.WithdrawnPathIDs {
+		b = appendInt(b, 8, int64(id))
+	}
+	b = appendCommunityFields(b, 9, u.ExtendedCommunities, u.LargeCommunities)
+	b = appendASPathField(b, 11, u.ASPath)
+	b = appendPathAttributesField(b, 12, u.PathAttributes)
+	return appendStr(b, 13, u.AddressFamily)
+}
+
+// ribRow encodes a RIB entry as a row of ribDescriptor.
+func ribRow(r *ribEntry) []byte {
+	b := appendStr(nil, 1, r.Collector)
+	b = appendMicrosField(b, 2, r.DumpedAt)
+	b = appendMicrosField(b, 3, r.OriginatedAt)
+	b = appendInt(b, 4, int64(r.PeerAS))
+	b = appendStr(b, 5, r.PeerIP)
+	b = appendStr(b, 6, r.PeerBGPID)
+	b = appendStr(b, 7, r.Prefix)
+	b = appendInt(b, 8, int64(r.PathID))
+	b = appendAttributeFields(b, 9, r.Attributes)
+	b = appendCommunityFields(b, 10, r.ExtendedCommunities, r.LargeCommunities)
+	b = appendASPathField(b, 12, r.ASPath)
+	b = appendPathAttributesField(b, 13, r.PathAttributes)
+	return appendStr(b, 14, r.AddressFamily)
+}
+
+// bqStream is a pending stream of the Storage Write API: its rows are
+// committed to its table at once, or never.
+type bqStream interface {
+	// appendRows appends rows at an offset of the stream, an append retried
+	// at the same offset is not appended twice.
+	appendRows(rows [][]byte, offset int64) error
+	// commit finalizes the stream, and commits its rows.
+	commit() error
+	// abort closes the stream, its rows are dropped.
+	abort()
+}
+
+// bqOpener opens a pending stream of the table of a kind of records, of the
+// rows of a descriptor.
+type bqOpener func(kind string, desc *descriptorpb.DescriptorProto) (bqStream, error)
+
+// managedStream is the bqStream of a managedwriter.ManagedStream.
+type managedStream struct {
+	ctx     context.Context
+	cli     *managedwriter.Client
+	parent  string
+	ms      *managedwriter.ManagedStream
+	results []*managedwriter.AppendResult
+}
+
+// managedStreams opens the pending streams of the tables of a TableConfig.
+func managedStreams(ctx context.Context, cli *managedwriter.Client, c TableConfig) bqOpener {
+	return func(kind string, desc *descriptorpb.DescriptorProto) (bqStream, error) {
+		parent := managedwriter.TableParentFromParts(c.Project, c.Dataset, c.table(kind))
+		ms, err := cli.NewManagedStream(ctx,
+			managedwriter.WithDestinationTable(parent),
+			managedwriter.WithType(managedwriter.PendingStream),
+			managedwriter.WithSchemaDescriptor(desc),
+			managedwriter.EnableWriteRetries(true))
+		if err != nil {
+			return nil, err
+		}
+		return &managedStream{ctx: ctx, cli: cli, parent: parent, ms: ms}, nil
+	}
+}
+
+// appendRows pipelines the appends, their results are waited for once
+// bqPipelined are in flight.
+func (s *managedStream) appendRows(rows [][]byte, offset int64) error {
+	r, err := s.ms.AppendRows(s.ctx, rows, managedwriter.WithOffset(offset))
+	if err != nil {
+		return err
+	}
+	s.results = append(s.results, r)
+	if len(s.results) >= bqPipelined {
+		return s.wait()
+	}
+	return nil
+}
+
+func (s *managedStream) wait() error {
+	for _, r := range s.results {
+		if _, err := r.GetResult(s.ctx); err != nil {
+			return err
+		}
+	}
+	s.results = nil
+	return nil
+}
+
+func (s *managedStream) commit() error {
+	defer s.ms.Close()
+	if err := s.wait(); err != nil {
+		return err
+	}
+	if _, err := s.ms.Finalize(s.ctx); err != nil {
+		return fmt.Errorf("Finalize(%s): %w", s.ms.StreamName(), err)
+	}
+	resp, err := s.cli.BatchCommitWriteStreams(s.ctx, &storagepb.BatchCommitWriteStreamsRequest{
+		Parent:       s.parent,
+		WriteStreams: []string{s.ms.StreamName()},
+	})
+	if err != nil {
+		return fmt.Errorf("BatchCommitWriteStreams(%s): %w", s.parent, err)
+	}
+	if errs := resp.GetStreamErrors(); len(errs) > 0 {
+		return fmt.Errorf("BatchCommitWriteStreams(%s): %s: %s", s.parent, errs[0].GetEntity(), errs[0].GetErrorMessage())
+	}
+	return nil
+}
+
+func (s *managedStream) abort() {
+	s.ms.Close()
+}
+
+// bqTable is the pending stream of a table, and the rows of its next append.
+type bqTable struct {
+	stream bqStream
+	rows   [][]byte
+	size   int
+	offset int64
+}
+
+func (t *bqTable) flush() error {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	if err := t.stream.appendRows(t.rows, t.offset); err != nil {
+		return err
+	}
+	t.offset += int64(len(t.rows))
+	t.rows, t.size = nil, 0
+	return nil
+}
+
+// bqSink is the recordSink of the Storage Write API: the rows of each kind of
+// record are appended to a pending stream of the table of the kind, opened
+// with its first row, and committed once the whole archive is
+// converted. The rows of a failed conversion are never committed, the retry
+// appends them again. An archive is of a single kind, so its rows are
+// committed at once.
+type bqSink struct {
+	open    bqOpener
+	updates *bqTable
+	ribs    *bqTable
+	err     error
+}
+
+func newBQSink(open bqOpener) *bqSink {
+	return &bqSink{open: open}
+}
+
+func (s *bqSink) add(t **bqTable, kind string, desc func() *descriptorpb.DescriptorProto, row []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if *t == nil {
+		stream, err := s.open(kind, desc())
+		if err != nil {
+			s.err = fmt.Errorf("failed to open the stream of the %s: %w", kind, err)
+			return s.err
+		}
+		*t = &bqTable{stream: stream}
+	}
+	(*t).rows = append((*t).rows, row)
+	(*t).size += len(row)
+	if len((*t).rows) >= bqBatchRows || (*t).size >= bqBatchBytes {
+		s.err = (*t).flush()
+	}
+	return s.err
+}
+
+func (s *bqSink) addUpdate(u *update) error {
+	return s.add(&s.updates, "updates", updateDescriptor, updateRow(u))
+}
+
+func (s *bqSink) addRib(rows []*ribEntry) error {
+	for _, r := range rows {
+		if err := s.add(&s.ribs, "ribs", ribDescriptor, ribRow(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close appends the rest of the rows, and commits the streams, or aborts them
+// all after an error.
+func (s *bqSink) close() error {
+	for _, t := range []*bqTable{s.updates, s.ribs} {
+		if t == nil {
+			continue
+		}
+		if s.err == nil {
+			s.err = t.flush()
+		}
+		if s.err != nil {
+			t.stream.abort()
+			continue
+		}
+		s.err = t.stream.commit()
+	}
+	return s.err
+}
@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// field is a field of an encoded row, of a varint or of bytes.
+type field struct {
+	Num   protowire.Number
+	Int   uint64
+	Bytes string
+}
+
+func decodeRow(t *testing.T, b []byte) []field {
+	var fields []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag of %x", b)
+		}
+		b = b[n:]
+		f := field{Num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.Int, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeString(b)
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("bad field %d of %x", num, b)
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestUpdateRow(t *testing.T) {
+	u := &update{
+		Collector:        "route-views2",
+		SeenAt:           time.Unix(1630454400, 1000),
+		PeerAS:           100000,
+		Announced:        []string{"10.0.0.0/24"},
+		Withdrawn:        []string{"30.0.0.0/24"},
+		Attributes:       []*attributePayload{{AttrType: 2, Payload: "{}"}},
+		AnnouncedPathIDs: []uint32{7},
+		LargeCommunities: []*largeCommunity{{GlobalAdmin: 65000, LocalData1: 1, LocalData2: 2}},
+		ASPath:           &pathAnalysis{OriginAS: 100000, Length: 2, HasASSet: true, FirstHopAS: 100000},
+		AddressFamily:    "ipv4-unicast",
+	}
+	attr := string(appendStr(appendInt(nil, 1, 2), 2, "{}"))
+	large := string(appendInt(appendInt(appendInt(nil, 1, 65000), 2, 1), 3, 2))
+	var path []byte
+	for i, v := range []int64{100000, 2, 0, 1, 100000, 0} {
+		path = appendInt(path, protowire.Number(i+1), v)
+	}
+	want := []field{
+		{Num: 1, Bytes: "route-views2"},
+		{Num: 2, Int: 1630454400000001},
+		{Num: 3, Int: 100000},
+		{Num: 4, Bytes: "10.0.0.0/24"},
+		{Num: 5, Bytes: "30.0.0.0/24"},
+		{Num: 6, Bytes: attr},
+		{Num: 7, Int: 7},
+		{Num: 10, Bytes: large},
+		{Num: 11, Bytes: string(path)},
+		{Num: 13, Bytes: "ipv4-unicast"},
+	}
+	if diff := cmp.Diff(decodeRow(t, updateRow(u)), want); diff != "" {
+		t.Errorf("updateRow() got diff (-got +want):\n%s", diff)
+	}
+
+	// The fields of the row are those of its descriptor.
+	nums := map[protowire.Number]string{}
+	for _, f := range updateDescriptor().GetField() {
+		nums[protowire.Number(f.GetNumber())] = f.GetName()
+	}
+	for _, f := range want {
+		if _, ok := nums[f.Num]; !ok {
+			t.Errorf("updateDescriptor() got no field %d", f.Num)
+		}
+	}
+}
+
+func TestRibDescriptor(t *testing.T) {
+	d := ribDescriptor()
+	nested := map[string]bool{}
+	for _, m := range d.GetNestedType() {
+		nested[m.GetName()] = true
+	}
+	var names []string
+	for _, f := range d.GetField() {
+		names = append(names, f.GetName())
+		if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE && !nested[f.GetTypeName()] {
+			t.Errorf("ribDescriptor() field %s of type %s, want a nested type", f.GetName(), f.GetTypeName())
+		}
+	}
+	want := []string{"Collector", "DumpedAt", "OriginatedAt", "PeerAS", "PeerIP", "PeerBGPID", "Prefix", "PathID", "Attributes", "ExtendedCommunities", "LargeCommunities", "ASPath", "PathAttributes", "AddressFamily"}
+	if diff := cmp.Diff(names, want); diff != "" {
+		t.Errorf("ribDescriptor() got fields diff (-got +want):\n%s", diff)
+	}
+}
+
+// fakeStream is a bqStream which keeps the offsets of its appends.
+type fakeStream struct {
+	offsets   []int64
+	rows      int
+	failAt    int64
+	committed bool
+	aborted   bool
+}
+
+func (f *fakeStream) appendRows(rows [][]byte, offset int64) error {
+	if f.failAt > 0 && offset >= f.failAt {
+		return errors.New("append failed")
+	}
+	f.offsets = append(f.offsets, offset)
+	f.rows += len(rows)
+	return nil
+}
+
+func (f *fakeStream) commit() error {
+	f.committed = true
+	return nil
+}
+
+func (f *fakeStream) abort() {
+	f.aborted = true
+}
+
+func TestBQSink(t *testing.T) {
+	u := &update{Collector: "route-views2", Announced: []string{"10.0.0.0/24"}}
+	tests := []struct {
+		desc          string
+		updates       int
+		failAt        int64
+		wantOffsets   []int64
+		wantCommitted bool
+		wantErr       bool
+	}{
+		{
+			desc:          "single append",
+			updates:       10,
+			wantOffsets:   []int64{0},
+			wantCommitted: true,
+		},
+		{
+			desc:          "batched appends",
+			updates:       bqBatchRows*2 + 1,
+			wantOffsets:   []int64{0, bqBatchRows, bqBatchRows * 2},
+			wantCommitted: true,
+		},
+		{
+			desc:        "failed append",
+			updates:     bqBatchRows*2 + 1,
+			failAt:      bqBatchRows,
+			wantOffsets: []int64{0},
+			wantErr:     true,
+		},
+	}
+	for _, test := range tests {
+		var kinds []string
+		stream := &fakeStream{failAt: test.failAt}
+		s := newBQSink(func(kind string, desc *descriptorpb.DescriptorProto) (bqStream, error) {
+			kinds = append(kinds, kind)
+			return stream, nil
+		})
+		var err error
+		for i := 0; i < test.updates && err == nil; i++ {
+			err = s.addUpdate(u)
+		}
+		if cerr := s.close(); err == nil {
+			err = cerr
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: got err: %v, want err %v", test.desc, err, test.wantErr)
+		}
+		if diff := cmp.Diff(kinds, []string{"updates"}); diff != "" {
+			t.Errorf("%s: got kinds diff (-got +want):\n%s", test.desc, diff)
+		}
+		if diff := cmp.Diff(stream.offsets, test.wantOffsets); diff != "" {
+			t.Errorf("%s: got offsets diff (-got +want):\n%s", test.desc, diff)
+		}
+		if stream.committed != test.wantCommitted || stream.aborted == test.wantCommitted {
+			t.Errorf("%s: got committed %v, aborted %v; want committed %v", test.desc, stream.committed, stream.aborted, test.wantCommitted)
+		}
+		if !test.wantErr && stream.rows != test.updates {
+			t.Errorf("%s: got %d rows, want %d", test.desc, stream.rows, test.updates)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	log "github.com/sirupsen/logrus"
+)
+
+// clusterFields are the columns the tables of the kinds of records are
+// clustered on, the most selective first, so the queries of a prefix, of a
+// peer or of a collector scan a fraction of a partition. The prefixes of an
+// update are repeated, which BigQuery does not cluster on, the updates are
+// clustered on the peer AS and the collector only.
+var clusterFields = map[string][]string{
+	"updates": {"PeerAS", "Collector"},
+	"ribs":    {"Prefix", "PeerAS", "Collector"},
+}
+
+// clustered returns whether a table of a kind of records is clustered on its
+// clusterFields.
+func clustered(c *bigquery.Clustering, kind string) bool {
+	want := clusterFields[kind]
+	if c == nil || len(c.Fields) != len(want) {
+		return false
+	}
+	for i, f := range want {
+		if c.Fields[i] != f {
+			return false
+		}
+	}
+	return true
+}
+
+// reclusterQuery returns the DML which rewrites every row of a table, the
+// rows are rewritten clustered by the clustering of the table.
+func reclusterQuery(c TableConfig, kind string) string {
+	return fmt.Sprintf("UPDATE `%s.%s.%s` SET Collector = Collector WHERE TRUE", c.Project, c.Dataset, c.table(kind))
+}
+
+// MigrateClustering clusters the tables of the configs which exist already,
+// see EnsureTables, and reclusters the rows they have, which a change of the
+// clustering of a table leaves as they are. The rewrite of a table is billed
+// as a scan of the whole table.
+func MigrateClustering(ctx context.Context, cli *bigquery.Client, t *Tables) error {
+	if err := EnsureTables(ctx, cli, t); err != nil {
+		return err
+	}
+	for _, c := range t.configs() {
+		for _, kind := range []string{"updates", "ribs"} {
+			q := reclusterQuery(c, kind)
+			log.Infof("Reclustering %s.%s.%s", c.Project, c.Dataset, c.table(kind))
+			job, err := cli.Query(q).Run(ctx)
+			if err != nil {
+				return fmt.Errorf("%s: %v", q, err)
+			}
+			st, err := job.Wait(ctx)
+			if err == nil {
+				err = st.Err()
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", q, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+)
+
+func TestClustered(t *testing.T) {
+	tests := []struct {
+		desc string
+		c    *bigquery.Clustering
+		kind string
+		want bool
+	}{
+		{desc: "not clustered", kind: "ribs"},
+		{desc: "clustered", c: &bigquery.Clustering{Fields: []string{"Prefix", "PeerAS", "Collector"}}, kind: "ribs", want: true},
+		{desc: "other order", c: &bigquery.Clustering{Fields: []string{"PeerAS", "Prefix", "Collector"}}, kind: "ribs"},
+		{desc: "other fields", c: &bigquery.Clustering{Fields: []string{"Collector"}}, kind: "updates"},
+		{desc: "updates", c: &bigquery.Clustering{Fields: []string{"PeerAS", "Collector"}}, kind: "updates", want: true},
+	}
+	for _, test := range tests {
+		if got := clustered(test.c, test.kind); got != test.want {
+			t.Errorf("%s: clustered() = %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestReclusterQuery(t *testing.T) {
+	c := (&Tables{Default: TableConfig{Project: "routeviews", Dataset: "ris", RibsTable: "bview"}}).For("")
+	want := "UPDATE `routeviews.ris.bview` SET Collector = Collector WHERE TRUE"
+	if got := reclusterQuery(c, "ribs"); got != want {
+		t.Errorf("reclusterQuery() = %q, want %q", got, want)
+	}
+}
+
+func TestTablesConfigs(t *testing.T) {
+	tables := &Tables{
+		Default: TableConfig{Project: "routeviews", Dataset: "mrt"},
+		Projects: map[string]TableConfig{
+			"ROUTEVIEWS": {Dataset: "mrt"},
+			"RIPE_RIS":   {Dataset: "ris"},
+		},
+	}
+	if got := len(tables.configs()); got != 2 {
+		t.Errorf("configs() got %d configs, want 2", got)
+	}
+}
@@ -0,0 +1,40 @@
+package converter
+
+import "github.com/osrg/gobgp/pkg/packet/bgp"
+
+// extendedCommunity is an extended community of the path attributes, RFC
+// 4360: its type and subtype, and its value as GoBGP prints it, ie: 65000:100
+// of a route target.
+type extendedCommunity struct {
+	Type    uint8
+	Subtype uint8
+	Value   string
+}
+
+// largeCommunity is a large community of the path attributes, RFC 8092.
+type largeCommunity struct {
+	GlobalAdmin uint32
+	LocalData1  uint32
+	LocalData2  uint32
+}
+
+// translateCommunities decodes the extended and large communities of the path
+// attributes, which are kept in the payloads of the attributes as well.
+func translateCommunities(attrs []bgp.PathAttributeInterface) ([]*extendedCommunity, []*largeCommunity) {
+	var ext []*extendedCommunity
+	var large []*largeCommunity
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeExtendedCommunities:
+			for _, c := range a.Value {
+				typ, sub := c.GetTypes()
+				ext = append(ext, &extendedCommunity{Type: uint8(typ), Subtype: uint8(sub), Value: c.String()})
+			}
+		case *bgp.PathAttributeLargeCommunities:
+			for _, c := range a.Values {
+				large = append(large, &largeCommunity{GlobalAdmin: c.ASN, LocalData1: c.LocalData1, LocalData2: c.LocalData2})
+			}
+		}
+	}
+	return ext, large
+}
@@ -0,0 +1,33 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+func TestTranslateCommunities(t *testing.T) {
+	attrs := []bgp.PathAttributeInterface{
+		&bgp.PathAttributeExtendedCommunities{
+			Value: []bgp.ExtendedCommunityInterface{
+				&bgp.TwoOctetAsSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 100, IsTransitive: true},
+			},
+		},
+		&bgp.PathAttributeLargeCommunities{
+			Values: []*bgp.LargeCommunity{{ASN: 4200000000, LocalData1: 1, LocalData2: 2}},
+		},
+	}
+	ext, large := translateCommunities(attrs)
+	if diff := cmp.Diff(ext, []*extendedCommunity{{Type: 0, Subtype: 2, Value: "65000:100"}}); diff != "" {
+		t.Errorf("translateCommunities() got extended communities diff (-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(large, []*largeCommunity{{GlobalAdmin: 4200000000, LocalData1: 1, LocalData2: 2}}); diff != "" {
+		t.Errorf("translateCommunities() got large communities diff (-got +want):\n%s", diff)
+	}
+
+	ext, large = translateCommunities(nil)
+	if ext != nil || large != nil {
+		t.Errorf("translateCommunities(nil) = %v, %v; want nil", ext, large)
+	}
+}
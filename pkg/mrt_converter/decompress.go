@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/ulikunitz/xz"
+)
+
+// The magic bytes of the compression formats of the archives: RouteViews
+// archives are bzip2 compressed, RIPE RIS archives gzip compressed, and some
+// collectors archive with xz.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	// bzip2Magic is followed by the block size, '1' to '9', and the magic
+	// of a block, or of the end of an empty stream.
+	bzip2Magic       = []byte("BZh")
+	bzip2BlockMagic  = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2StreamMagic = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// decompressFunc returns the reader of the decompressed content of an archive.
+type decompressFunc func(_ io.Reader) (io.Reader, error)
+
+// uncompressed reads archives which are decompressed already.
+func uncompressed(r io.Reader) (io.Reader, error) { return r, nil }
+
+// decompress returns the reader of the decompressed content of an archive, of
+// the format of its magic bytes: gzip, bzip2 or xz. The content of another
+// archive is read as it is, ie: an uncompressed archive.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	// Peek returns the bytes there are of a shorter archive.
+	head, _ := br.Peek(len(bzip2Magic) + 1 + len(bzip2BlockMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(head, xzMagic):
+		return xz.NewReader(br)
+	case isBzip2(head):
+		return bzip2.NewReader(br), nil
+	}
+	return br, nil
+}
+
+// isBzip2 returns whether the head of an archive is of bzip2, the magic of the
+// block is checked as well: BZh is the timestamp of an uncompressed archive of
+// April 2005.
+func isBzip2(head []byte) bool {
+	n := len(bzip2Magic)
+	if len(head) < n+1+len(bzip2BlockMagic) || !bytes.HasPrefix(head, bzip2Magic) || head[n] < '1' || head[n] > '9' {
+		return false
+	}
+	block := head[n+1:]
+	return bytes.Equal(block, bzip2BlockMagic) || bytes.Equal(block, bzip2StreamMagic)
+}
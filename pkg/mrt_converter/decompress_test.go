@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	content := []byte("route-views2")
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(content)
+	gw.Close()
+
+	tests := []struct {
+		desc    string
+		archive []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			desc:    "gzip",
+			archive: gz.Bytes(),
+			want:    content,
+		}, {
+			desc: "bzip2",
+			archive: []byte{66, 90, 104, 57, 49, 65, 89, 38, 83, 89, 235, 152, 57, 208, 0, 0, 3, 25, 128, 0,
+				2, 16, 0, 2, 32, 159, 128, 32, 0, 34, 0, 15, 80, 128, 105, 166, 135, 188, 174, 220,
+				0, 60, 93, 201, 20, 225, 66, 67, 174, 96, 231, 64},
+			want: content,
+		}, {
+			desc: "xz",
+			archive: []byte{253, 55, 122, 88, 90, 0, 0, 1, 105, 34, 222, 54, 2, 0, 33, 1, 22, 0, 0, 0,
+				116, 47, 229, 163, 1, 0, 11, 114, 111, 117, 116, 101, 45, 118, 105, 101, 119, 115, 50, 0,
+				205, 104, 28, 35, 0, 1, 32, 12, 162, 221, 180, 188, 144, 66, 153, 13, 1, 0, 0, 0,
+				0, 1, 89, 90},
+			want: content,
+		}, {
+			desc:    "uncompressed",
+			archive: content,
+			want:    content,
+		}, {
+			// The timestamp of a record of April 2005 is BZh.
+			desc:    "uncompressed of a bzip2 magic",
+			archive: []byte{'B', 'Z', 'h', '9', 0, 16, 0, 4, 0, 0, 0, 0},
+			want:    []byte{'B', 'Z', 'h', '9', 0, 16, 0, 4, 0, 0, 0, 0},
+		}, {
+			desc:    "empty",
+			archive: []byte{},
+			want:    []byte{},
+		}, {
+			desc:    "truncated gzip",
+			archive: gz.Bytes()[:5],
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r, err := decompress(bytes.NewReader(test.archive))
+			if err == nil {
+				var got []byte
+				got, err = ioutil.ReadAll(r)
+				if err == nil && !bytes.Equal(got, test.want) {
+					t.Errorf("decompress() read %q, want %q", got, test.want)
+				}
+			}
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("decompress() got err %v, want err: %v", err, test.wantErr)
+			}
+		})
+	}
+}
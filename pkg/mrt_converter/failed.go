@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// FailedMetadataKey marks an archive whose conversion failed for good in its
+// GCS metadata, its value is the error. The archive is not converted again
+// until the key is cleared.
+const FailedMetadataKey = "conversion_failed"
+
+// maxFailedLen bounds the error kept in the metadata, GCS limits the metadata
+// of an object to 8KiB in all.
+const maxFailedLen = 1024
+
+// ErrMarkedFailed is the error of the conversion of an archive marked with
+// FailedMetadataKey.
+var ErrMarkedFailed = errors.New("archive marked as failed")
+
+// failedValue returns the value of FailedMetadataKey of an error.
+func failedValue(err error) string {
+	v := err.Error()
+	if len(v) > maxFailedLen {
+		v = strings.ToValidUTF8(v[:maxFailedLen], "")
+	}
+	if v == "" {
+		// An empty value would clear the key.
+		v = "unknown error"
+	}
+	return v
+}
+
+// MarkFailed marks an archive with FailedMetadataKey, so the notification of
+// its metadata update, and any later one, does not convert it again.
+func MarkFailed(ctx context.Context, gcsCli *storage.Client, bucket, object string, cause error) error {
+	_, err := gcsCli.Bucket(bucket).Object(object).Update(ctx, storage.ObjectAttrsToUpdate{
+		Metadata: map[string]string{FailedMetadataKey: failedValue(cause)},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot mark gs://%s/%s as failed: %v", bucket, object, err)
+	}
+	return nil
+}
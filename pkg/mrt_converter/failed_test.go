@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFailedValue(t *testing.T) {
+	tests := []struct {
+		desc    string
+		err     error
+		wantLen int
+		want    string
+	}{
+		{
+			desc:    "short error",
+			err:     errors.New("cannot decompress archive"),
+			wantLen: 25,
+			want:    "cannot decompress archive",
+		}, {
+			desc:    "long error truncated",
+			err:     errors.New(strings.Repeat("x", 2*maxFailedLen)),
+			wantLen: maxFailedLen,
+		}, {
+			desc:    "truncated within a rune",
+			err:     errors.New("x" + strings.Repeat("é", maxFailedLen)),
+			wantLen: maxFailedLen - 1,
+		}, {
+			desc:    "empty error",
+			err:     errors.New(""),
+			wantLen: 13,
+			want:    "unknown error",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := failedValue(test.err)
+			if len(got) != test.wantLen || !utf8.ValidString(got) {
+				t.Errorf("failedValue() = %d bytes, valid UTF-8: %v; want %d bytes of valid UTF-8", len(got), utf8.ValidString(got), test.wantLen)
+			}
+			if test.want != "" && got != test.want {
+				t.Errorf("failedValue() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
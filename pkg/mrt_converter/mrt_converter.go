@@ -1,21 +1,25 @@
 package converter
 
 import (
-	"bytes"
-	"compress/bzip2"
 	"compress/gzip"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/bigquery/storage/managedwriter"
 	"cloud.google.com/go/storage"
 	"github.com/osrg/gobgp/pkg/packet/bgp"
 	"github.com/osrg/gobgp/pkg/packet/mrt"
 
+	archiveprofile "github.com/routeviews/google-cloud-storage/pkg/archive_profile"
+	storagetier "github.com/routeviews/google-cloud-storage/pkg/storage_tier"
 	pb "github.com/routeviews/google-cloud-storage/proto/rv"
 	log "github.com/sirupsen/logrus"
 )
@@ -41,12 +45,65 @@ type update struct {
 	Announced  []string
 	Withdrawn  []string
 	Attributes []*attributePayload
+	// AddressFamily is the AFI/SAFI of the prefixes, as GoBGP names it, ie:
+	// ipv6-unicast.
+	AddressFamily string
+
+	// The path identifiers of the announced and withdrawn prefixes, in
+	// order, of the updates of ADD-PATH sessions only.
+	AnnouncedPathIDs []uint32 `json:",omitempty"`
+	WithdrawnPathIDs []uint32 `json:",omitempty"`
+
+	// The extended and large communities of the path attributes, decoded.
+	ExtendedCommunities []*extendedCommunity `json:",omitempty"`
+	LargeCommunities    []*largeCommunity    `json:",omitempty"`
+	// ASPath is the analysis of the AS path, of the updates which have one.
+	ASPath *pathAnalysis `json:",omitempty"`
+	// PathAttributes are the path attributes, decoded.
+	PathAttributes *pathAttributes `json:",omitempty"`
+}
+
+// updateSubTypes are the BGP4MP subtypes of the updates which are converted,
+// the value is whether the NLRI of the subtype carry ADD-PATH path
+// identifiers (RFC 8050).
+var updateSubTypes = map[mrt.MRTSubTypeBGP4MP]bool{
+	mrt.MESSAGE:             false,
+	mrt.MESSAGE_AS4:         false,
+	mrt.MESSAGE_ADDPATH:     true,
+	mrt.MESSAGE_AS4_ADDPATH: true,
 }
 
 type Config struct {
 	SrcBucket string
 	DstBucket string
 	SrcObject string
+	// ColdPolicy decides how archives in a cold storage class are read.
+	ColdPolicy storagetier.Policy
+	// ParquetBucket is the bucket to write the records as Parquet files to
+	// as well, under ParquetPrefix, see ParquetName; empty writes none.
+	ParquetBucket string
+	ParquetPrefix string
+	// AvroBucket is the bucket to write the records as Avro files to as
+	// well, under AvroPrefix, see AvroName; empty writes none. The files are
+	// stamped with the schema registry IDs of their schemas in AvroSchemaIDs,
+	// by kind: updates or ribs.
+	AvroBucket    string
+	AvroPrefix    string
+	AvroSchemaIDs map[string]int
+	// NoJSON skips the JSONL records of the BigQuery load, the records are
+	// only written to the Parquet or Avro files.
+	NoJSON bool
+	// BigQuery writes the records to the tables of BigQueryTables of the
+	// project of the archive, with the Storage Write API rather than the
+	// JSONL records of the load. The converted object is then written empty,
+	// it marks the archive as committed.
+	BigQuery       *managedwriter.Client
+	BigQueryTables *Tables
+	// Retry bounds the attempts of a conversion failed with a Retryable
+	// error, nil is DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// Stats, if set, are the counts of the conversion, of its last attempt.
+	Stats *Stats
 }
 
 // routeViewsCollectorFromPath extracts the RV collector name from the input
@@ -71,32 +128,71 @@ func routeViewsCollectorFromPath(filename string) (string, error) {
 	return dirs[1], nil
 }
 
+// risCollectorFromPath extracts the RIS collector name, ie: rrc00, from the
+// path of a RIPE RIS archive of updates, or bview RIB dump. The RIS archives
+// may be stored under a prefix, ie: ripe-ris/rrc00/2022.01/updates.20220109.1830.gz.
+func risCollectorFromPath(filename string) (string, error) {
+	a, ok := archiveprofile.ParseProject(pb.FileRequest_RIPE_RIS, strings.TrimPrefix(filename, "/"))
+	if !ok {
+		return "", fmt.Errorf("file %s is not a valid RIPE RIS archive path", filename)
+	}
+	return a.Collector, nil
+}
+
 // readArchive reads from the source bucket and object. It returns the
-// collector name and its content reader if successful.
-func readArchive(ctx context.Context, gcsCli *storage.Client, bucket, object string) (string, io.Reader, error) {
+// project and the collector name of the archive, and its content reader if
+// successful. Archives in a cold
+// storage class are handled according to the cold policy.
+func readArchive(ctx context.Context, gcsCli *storage.Client, bucket, object string, cold storagetier.Policy) (string, string, io.Reader, error) {
 	obj := gcsCli.Bucket(bucket).Object(object)
 
-	// Read content from the object.
-	r, err := obj.NewReader(ctx)
+	// Extract project type from the object metadata.
+	attrs, err := obj.Attrs(ctx)
 	if err != nil {
-		return "", nil, fmt.Errorf("NewReader(gs://%s/%s): %v", bucket, object, err)
+		return "", "", nil, fmt.Errorf("obj.Attrs: %w", err)
+	}
+	if _, ok := attrs.Metadata[FailedMetadataKey]; ok {
+		return "", "", nil, ErrMarkedFailed
 	}
 
-	// Extract project type from the object metadata.
-	attrs, err := obj.Attrs(ctx)
+	est, err := storagetier.Prepare(ctx, obj, attrs, cold)
 	if err != nil {
-		return "", nil, fmt.Errorf("obj.Attrs: %v", err)
+		return "", "", nil, err
+	}
+	if est.Cold() {
+		msg := "reading archive from cold storage"
+		if est.Restored {
+			msg = "restored archive from cold storage"
+		}
+		log.WithFields(log.Fields{
+			"object":  fmt.Sprintf("gs://%s/%s", bucket, object),
+			"class":   est.Class,
+			"bytes":   est.Bytes,
+			"costUSD": est.Cost,
+			"latency": est.Latency.String(),
+		}).Warn(msg)
+	}
+
+	// Read content from the object.
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("NewReader(gs://%s/%s): %w", bucket, object, err)
 	}
 	projectType, ok := attrs.Metadata[ProjectMetadataKey]
 	if !ok {
-		return "", nil, fmt.Errorf("metadata '%s' is missing from gs://%s/%s", ProjectMetadataKey, bucket, object)
+		return "", "", nil, fmt.Errorf("metadata '%s' is missing from gs://%s/%s", ProjectMetadataKey, bucket, object)
 	}
 	var collector string
 	switch projectType {
 	case pb.FileRequest_ROUTEVIEWS.String():
 		collector, err = routeViewsCollectorFromPath(object)
 		if err != nil {
-			return "", nil, err
+			return "", "", nil, err
+		}
+	case pb.FileRequest_RIPE_RIS.String():
+		collector, err = risCollectorFromPath(object)
+		if err != nil {
+			return "", "", nil, err
 		}
 	default:
 		// If project type is unknown, we will just leave collector empty and
@@ -104,7 +200,7 @@ func readArchive(ctx context.Context, gcsCli *storage.Client, bucket, object str
 		log.Warnf("unsupported project type %s", projectType)
 	}
 
-	return collector, r, nil
+	return projectType, collector, r, nil
 }
 
 func translateAttrs(attrs []bgp.PathAttributeInterface) []*attributePayload {
@@ -125,7 +221,7 @@ func translateAttrs(attrs []bgp.PathAttributeInterface) []*attributePayload {
 	return res
 }
 
-func translatePrefixes(prefixes []*bgp.IPAddrPrefix) []string {
+func translatePrefixes(prefixes []bgp.AddrPrefixInterface) []string {
 	var res []string
 	for _, p := range prefixes {
 		res = append(res, p.String())
@@ -133,9 +229,76 @@ func translatePrefixes(prefixes []*bgp.IPAddrPrefix) []string {
 	return res
 }
 
+// translatePathIDs returns the ADD-PATH path identifiers of the prefixes, in
+// the order of translatePrefixes.
+func translatePathIDs(prefixes []bgp.AddrPrefixInterface) []uint32 {
+	var res []uint32
+	for _, p := range prefixes {
+		res = append(res, p.PathIdentifier())
+	}
+	return res
+}
+
+// addPathOption decodes the NLRI of the families of the updates with their
+// ADD-PATH path identifiers.
+var addPathOption = &bgp.MarshallingOption{AddPath: map[bgp.RouteFamily]bgp.BGPAddPathMode{
+	bgp.RF_IPv4_UC: bgp.BGP_ADD_PATH_RECEIVE,
+	bgp.RF_IPv6_UC: bgp.BGP_ADD_PATH_RECEIVE,
+	bgp.RF_IPv4_MC: bgp.BGP_ADD_PATH_RECEIVE,
+	bgp.RF_IPv6_MC: bgp.BGP_ADD_PATH_RECEIVE,
+}}
+
+// parseAddPath parses the body of a BGP4MP message of an ADD-PATH subtype.
+// GoBGP decodes the BGP message of these subtypes without the path
+// identifiers, which garbles their NLRI, so the BGP4MP header is decoded here
+// and the BGP message with addPathOption.
+func parseAddPath(subType mrt.MRTSubTypeBGP4MP, buf []byte) (*mrt.BGP4MPMessage, error) {
+	h := &mrt.BGP4MPHeader{}
+	if subType == mrt.MESSAGE_AS4_ADDPATH {
+		if len(buf) < 8 {
+			return nil, fmt.Errorf("not all BGP4MP header bytes available")
+		}
+		h.PeerAS, h.LocalAS = binary.BigEndian.Uint32(buf[:4]), binary.BigEndian.Uint32(buf[4:8])
+		buf = buf[8:]
+	} else {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("not all BGP4MP header bytes available")
+		}
+		h.PeerAS, h.LocalAS = uint32(binary.BigEndian.Uint16(buf[:2])), uint32(binary.BigEndian.Uint16(buf[2:4]))
+		buf = buf[4:]
+	}
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("not all BGP4MP header bytes available")
+	}
+	h.InterfaceIndex, h.AddressFamily = binary.BigEndian.Uint16(buf[:2]), binary.BigEndian.Uint16(buf[2:4])
+	buf = buf[4:]
+	ipLen := net.IPv4len
+	switch h.AddressFamily {
+	case bgp.AFI_IP:
+	case bgp.AFI_IP6:
+		ipLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unsupported address family: %d", h.AddressFamily)
+	}
+	if len(buf) < 2*ipLen+bgp.BGP_HEADER_LENGTH {
+		return nil, fmt.Errorf("not all BGP4MP message bytes available")
+	}
+	h.PeerIpAddress, h.LocalIpAddress = net.IP(buf[:ipLen]), net.IP(buf[ipLen:2*ipLen])
+	msg, err := bgp.ParseBGPMessage(buf[2*ipLen:], addPathOption)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := msg.Body.(*bgp.BGPUpdate); !ok {
+		return nil, fmt.Errorf("BGP message of type %d is not an update", msg.Header.Type)
+	}
+	return &mrt.BGP4MPMessage{BGP4MPHeader: h, BGPMessage: msg}, nil
+}
+
 // parseUpdate converts a pair of MRT header and message into a BigQuery
 // compatible update. A BGP4MP_ET message will be treated as a BGP4MP message,
-// and the microsecond field will be ignored.
+// and the microsecond field will be ignored. The prefixes of an ADD-PATH
+// message carry their path identifiers, those of the multiprotocol attributes
+// are converted along with those of IPv4 unicast.
 func parseUpdate(collector string, h *mrt.MRTHeader, buf []byte) (*update, error) {
 	if h == nil {
 		return nil, fmt.Errorf("header cannot be nil")
@@ -151,49 +314,72 @@ func parseUpdate(collector string, h *mrt.MRTHeader, buf []byte) (*update, error
 		buf = buf[4:]
 	}
 
-	msg, err := mrt.ParseMRTBody(h, buf)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse body: %v", err)
+	var mrtMsg *mrt.BGP4MPMessage
+	if updateSubTypes[mrt.MRTSubTypeBGP4MP(h.SubType)] {
+		m, err := parseAddPath(mrt.MRTSubTypeBGP4MP(h.SubType), buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse body: %v", err)
+		}
+		mrtMsg = m
+	} else {
+		msg, err := mrt.ParseMRTBody(h, buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse body: %v", err)
+		}
+		mrtMsg = msg.Body.(*mrt.BGP4MPMessage)
 	}
-
-	mrtMsg := msg.Body.(*mrt.BGP4MPMessage)
 	bgpUpdate := mrtMsg.BGPMessage.Body.(*bgp.BGPUpdate)
-	return &update{
-		SeenAt:     h.GetTime(),
-		PeerAS:     mrtMsg.PeerAS,
-		Collector:  collector,
-		Announced:  translatePrefixes(bgpUpdate.NLRI),
-		Withdrawn:  translatePrefixes(bgpUpdate.WithdrawnRoutes),
-		Attributes: translateAttrs(bgpUpdate.PathAttributes),
-	}, nil
+	prefixes := translateNLRI(bgpUpdate)
+	u := &update{
+		SeenAt:        h.GetTime(),
+		PeerAS:        mrtMsg.PeerAS,
+		Collector:     collector,
+		Announced:     translatePrefixes(prefixes.announced),
+		Withdrawn:     translatePrefixes(prefixes.withdrawn),
+		Attributes:    translateAttrs(bgpUpdate.PathAttributes),
+		AddressFamily: prefixes.family.String(),
+	}
+	u.ExtendedCommunities, u.LargeCommunities = translateCommunities(bgpUpdate.PathAttributes)
+	u.ASPath = analyzeASPath(bgpUpdate.PathAttributes)
+	u.PathAttributes = translatePathAttributes(bgpUpdate.PathAttributes)
+	if updateSubTypes[mrt.MRTSubTypeBGP4MP(h.SubType)] {
+		u.AnnouncedPathIDs = translatePathIDs(prefixes.announced)
+		u.WithdrawnPathIDs = translatePathIDs(prefixes.withdrawn)
+	}
+	return u, nil
 }
 
-type bzReaderFunc func(_ io.Reader) io.Reader
-
-func convertNext(r io.Reader, w io.Writer, collector string) error {
+func convertNext(r io.Reader, w io.Writer, collector string, st *dumpState) error {
 	buf := make([]byte, mrt.MRT_COMMON_HEADER_LEN)
 	_, err := io.ReadFull(r, buf)
 	if err == io.EOF {
 		return err
 	} else if err != nil {
+		st.stats.parseError()
 		return fmt.Errorf("failed to read MRT header: %v", err)
 	}
 
 	h := &mrt.MRTHeader{}
 	err = h.DecodeFromBytes(buf)
 	if err != nil {
+		st.stats.parseError()
 		return fmt.Errorf("(*mrt.MRTHeader).DecodeFromBytes: %v", err)
 	}
 
 	buf = make([]byte, h.Len)
 	_, err = io.ReadFull(r, buf)
 	if err != nil {
+		st.stats.parseError()
 		return fmt.Errorf("failed to read MRT body: %v", err)
 	}
+	st.stats.record()
 
-	// We only parse updates at the moment.
-	if (h.Type != mrt.BGP4MP && h.Type != mrt.BGP4MP_ET) ||
-		(h.SubType != uint16(mrt.MESSAGE_AS4) && h.SubType != uint16(mrt.MESSAGE)) {
+	// RIB dumps are converted into rows of their entries.
+	if h.Type == mrt.TABLE_DUMPv2 {
+		return convertRib(w, collector, h, buf, st)
+	}
+	// Otherwise we only parse updates, of ADD-PATH sessions too.
+	if _, ok := updateSubTypes[mrt.MRTSubTypeBGP4MP(h.SubType)]; (h.Type != mrt.BGP4MP && h.Type != mrt.BGP4MP_ET) || !ok {
 		log.WithFields(log.Fields{"type": h.Type, "subType": h.SubType}).Debug("unsupported message types")
 		return nil
 	}
@@ -201,6 +387,7 @@ func convertNext(r io.Reader, w io.Writer, collector string) error {
 	update, err := parseUpdate(collector, h, buf)
 	if err != nil {
 		log.Debug(fmt.Errorf("failed to parse update: %v, bytes: %v", err, buf))
+		st.stats.parseError()
 		return nil
 	}
 
@@ -212,29 +399,80 @@ func convertNext(r io.Reader, w io.Writer, collector string) error {
 	if _, err := w.Write(append(b, '\n')); err != nil {
 		return fmt.Errorf("writer.Write: %v", err)
 	}
+	for _, s := range st.sinks {
+		if err := s.addUpdate(update); err != nil {
+			return err
+		}
+	}
+	st.stats.rows(1)
 	return nil
 }
 
-// Convert translates the bzip'ed MRT raw bytes into a BigQuery compatible
-// format and write to the destination.
+// Convert translates the MRT raw bytes, of updates or a TABLE_DUMP_V2 RIB
+// dump, compressed with gzip, bzip2 or xz, or not at all, see decompress, into
+// a BigQuery compatible format and write to the destination.
 func Convert(collector string, r io.Reader, dst io.Writer) {
-	convert(collector, r, dst, bzip2.NewReader)
+	convert(collector, r, dst, decompress)
 }
 
-func convert(collector string, r io.Reader, dst io.Writer, bzip2Reader bzReaderFunc) {
-	br := bzip2Reader(r)
-	gw := gzip.NewWriter(dst)
-	defer gw.Close()
+func convert(collector string, r io.Reader, dst io.Writer, dc decompressFunc) {
+	if err := convertWith(collector, r, dst, dc, &dumpState{}); err != nil {
+		log.Errorf("cannot convert archive: %v", err)
+	}
+}
 
+// archiveReader reads an archive, and keeps the first error of the read other
+// than its end.
+type archiveReader struct {
+	r   io.Reader
+	err error
+}
+
+func (a *archiveReader) Read(b []byte) (int, error) {
+	n, err := a.r.Read(b)
+	if err != nil && err != io.EOF && a.err == nil {
+		a.err = err
+	}
+	return n, err
+}
+
+// convertWith converts an archive with the state of its conversion, ie: its
+// Parquet files. A record which does not parse ends the conversion, but an
+// error of reading or decompressing the archive fails it: the conversion of
+// the archive is cut short, and the rest of it is not written to dst.
+func convertWith(collector string, r io.Reader, dst io.Writer, dc decompressFunc, st *dumpState) error {
+	br, err := dc(r)
+	if err != nil {
+		return fmt.Errorf("cannot decompress archive: %w", err)
+	}
+
+	gw := gzip.NewWriter(dst)
+	ar := &archiveReader{r: br}
 	for {
-		err := convertNext(br, gw, collector)
-		if err != nil {
-			if err != io.EOF {
-				log.Errorf("cannot convert message: %v", err)
-			}
-			break
+		err := convertNext(ar, gw, collector, st)
+		if err == nil {
+			continue
+		}
+		if ar.err != nil {
+			return fmt.Errorf("cannot read archive: %w", ar.err)
+		}
+		if err != io.EOF {
+			log.Errorf("cannot convert message: %v", err)
+		}
+		return gw.Close()
+	}
+}
+
+// closeSinks closes the sinks of the conversion of an archive, and returns the
+// first error.
+func closeSinks(sinks []recordSink, cfg *Config) error {
+	var first error
+	for _, s := range sinks {
+		if err := s.close(); err != nil && first == nil {
+			first = fmt.Errorf("failed to write the files of gs://%s/%s: %w", cfg.SrcBucket, cfg.SrcObject, err)
 		}
 	}
+	return first
 }
 
 // ObjExists checks if a converted archive already exists at the
@@ -245,39 +483,116 @@ func ObjExists(ctx context.Context, gcsCli *storage.Client, object, bucket strin
 		if err == storage.ErrObjectNotExist {
 			return false, nil
 		}
-		return false, fmt.Errorf("cannot open gs://%s/%s: %v", bucket, object, err)
+		return false, fmt.Errorf("cannot open gs://%s/%s: %w", bucket, object, err)
 	}
 	return true, nil
 }
 
+// ConvertedName returns the name of the converted object of an archive, in
+// the destination bucket.
+func ConvertedName(obj string) string {
+	return strings.Replace(obj, filepath.Ext(obj), ".gz", 1)
+}
+
 // ProcessMRTArchive converts an MRT dump into updates on GCS, which will later
 // be picked up by BigQuery automatically. ProcessMRTDump converts on a best-
-// effort basis as it will convert as much as it can from every archive, and it only
-// supports archives of updates.
+// effort basis as it will convert as much as it can from every archive, and it
+// supports the bzip2 RouteViews, gzip RIPE RIS and xz archives of updates and of
+// TABLE_DUMP_V2 RIB dumps, whose entries are converted into RIB snapshot rows.
+//
+// The conversions which fail with a Retryable error, of the storage or of
+// BigQuery, are attempted again as cfg.Retry allows, the whole archive each
+// time. An archive which fails to be read to its end is not converted at all,
+// see convertWith.
 func ProcessMRTArchive(ctx context.Context, gcsCli *storage.Client, cfg *Config) error {
-	return processMRTArchive(ctx, gcsCli, cfg, bzip2.NewReader)
+	p := DefaultRetryPolicy
+	if cfg.Retry != nil {
+		p = *cfg.Retry
+	}
+	return withRetries(ctx, p, fmt.Sprintf("gs://%s/%s", cfg.SrcBucket, cfg.SrcObject), func() error {
+		return processMRTArchive(ctx, gcsCli, cfg, decompress)
+	})
 }
 
-func processMRTArchive(ctx context.Context, gcsCli *storage.Client, cfg *Config, br bzReaderFunc) error {
-	dstObject := strings.Replace(cfg.SrcObject, filepath.Ext(cfg.SrcObject), ".gz", 1)
-	if found, err := ObjExists(ctx, gcsCli, dstObject, cfg.DstBucket); err != nil {
-		return fmt.Errorf("ObjExists: %v", err)
+func processMRTArchive(ctx context.Context, gcsCli *storage.Client, cfg *Config, dc decompressFunc) error {
+	if cfg.Stats != nil {
+		*cfg.Stats = Stats{Kind: archiveKind(cfg.SrcObject)}
+	}
+	dstObject := ConvertedName(cfg.SrcObject)
+	if cfg.NoJSON && cfg.BigQuery == nil {
+		if cfg.ParquetBucket == "" && cfg.AvroBucket == "" {
+			return fmt.Errorf("no output: NoJSON needs Parquet or Avro files")
+		}
+	} else if found, err := ObjExists(ctx, gcsCli, dstObject, cfg.DstBucket); err != nil {
+		return fmt.Errorf("ObjExists: %w", err)
 	} else if found {
 		log.Warnf("converted archive gs://%s/%s already exists.", cfg.DstBucket, dstObject)
+		if cfg.Stats != nil {
+			cfg.Stats.Skipped = true
+		}
 		return nil
 	}
 
-	collector, reader, err := readArchive(ctx, gcsCli, cfg.SrcBucket, cfg.SrcObject)
+	project, collector, reader, err := readArchive(ctx, gcsCli, cfg.SrcBucket, cfg.SrcObject, cfg.ColdPolicy)
 	if err != nil {
-		return fmt.Errorf("readArchive(%s, %s): %v", cfg.SrcBucket, cfg.SrcObject, err)
+		return fmt.Errorf("readArchive(%s, %s): %w", cfg.SrcBucket, cfg.SrcObject, err)
+	}
+	if cfg.Stats != nil {
+		cfg.Stats.Collector = collector
 	}
 
-	buf := bytes.NewBuffer(nil)
-	convert(collector, reader, buf, br)
+	// The archives are decompressed by their magic bytes, whatever their
+	// extension.
+	if reader, err = dc(reader); err != nil {
+		return fmt.Errorf("failed to decompress gs://%s/%s: %v", cfg.SrcBucket, cfg.SrcObject, err)
+	}
 
-	// Only write messages if the whole conversion is done.
+	// The converted messages are streamed to the object, RIB dumps are too
+	// large to buffer, which is only created once the whole conversion is
+	// done and the writer is closed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	st := &dumpState{stats: cfg.Stats}
+	if cfg.ParquetBucket != "" {
+		st.sinks = append(st.sinks, newParquetSink(collector, cfg.SrcObject, func(name string) io.WriteCloser {
+			return gcsCli.Bucket(cfg.ParquetBucket).Object(cfg.ParquetPrefix + name).NewWriter(ctx)
+		}))
+	}
+	if cfg.AvroBucket != "" {
+		st.sinks = append(st.sinks, newAvroSink(collector, cfg.SrcObject, cfg.AvroSchemaIDs, func(name string) io.WriteCloser {
+			return gcsCli.Bucket(cfg.AvroBucket).Object(cfg.AvroPrefix + name).NewWriter(ctx)
+		}))
+	}
+	// An archive which fails to be read to its end is converted by none of
+	// the outputs: the cancelled writes are aborted, and the retry of the
+	// conversion reads the archive again.
+	if cfg.BigQuery == nil && cfg.NoJSON {
+		if err := convertWith(collector, reader, ioutil.Discard, uncompressed, st); err != nil {
+			return fmt.Errorf("failed to convert gs://%s/%s: %w", cfg.SrcBucket, cfg.SrcObject, err)
+		}
+		return closeSinks(st.sinks, cfg)
+	}
 	dst := gcsCli.Bucket(cfg.DstBucket).Object(dstObject).NewWriter(ctx)
-	dst.Write(buf.Bytes())
-	defer dst.Close()
+	var out io.Writer = dst
+	if cfg.BigQuery != nil {
+		st.sinks = append(st.sinks, newBQSink(managedStreams(ctx, cfg.BigQuery, cfg.BigQueryTables.For(project))))
+		out = ioutil.Discard
+	}
+	// The converted object is aborted by the cancel alone: closing it would
+	// race the cancel with the end of its content, and may write it.
+	if err := convertWith(collector, reader, out, uncompressed, st); err != nil {
+		cancel()
+		return fmt.Errorf("failed to convert gs://%s/%s: %w", cfg.SrcBucket, cfg.SrcObject, err)
+	}
+	// The files of the sinks are written, and the rows of BigQuery
+	// committed, before the converted object; a failure aborts the converted
+	// object, so the retry of the conversion writes them all again.
+	if err := closeSinks(st.sinks, cfg); err != nil {
+		cancel()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write gs://%s/%s: %w", cfg.DstBucket, dstObject, err)
+	}
 	return nil
 }
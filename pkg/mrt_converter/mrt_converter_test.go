@@ -5,10 +5,13 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
@@ -53,7 +56,7 @@ var (
 	}))
 
 	gobgpCmpOpts = cmp.AllowUnexported(bgp.IPAddrPrefix{}, bgp.PrefixDefault{})
-	fakeBzip     = func(r io.Reader) io.Reader { return r }
+	fakeBzip     = uncompressed
 )
 
 var (
@@ -72,6 +75,9 @@ var (
 		Payload: marshalAttr(bgp.NewPathAttributeAs4Path([]*bgp.As4PathParam{
 			{Type: bgp.BGP_ASPATH_ATTR_TYPE_SEQ, Num: 1, AS: []uint32{100000}}})),
 	}
+	// originAS100000 is the analysis of the paths of AS 100000, of 4-octet
+	// ASes or 2-octet with the AS4_PATH.
+	originAS100000 = &pathAnalysis{OriginAS: 100000, Length: 1, FirstHopAS: 100000}
 )
 
 func encodeMRTMessage(t *testing.T, msg *mrt.MRTMessage) []byte {
@@ -148,11 +154,13 @@ func TestParseUpdate(t *testing.T) {
 			header:    fakeMRTHeader(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, len(encodeBGP4MP(t, fakeAS4Ann))),
 			body:      encodeBGP4MP(t, fakeAS4Ann),
 			want: &update{
-				Collector:  "route-views3",
-				SeenAt:     fakeTime,
-				PeerAS:     100000, // 4-octet ASN as peer.
-				Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
-				Attributes: []*attributePayload{fourOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        fakeTime,
+				PeerAS:        100000, // 4-octet ASN as peer.
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+				Attributes:    []*attributePayload{fourOctetASPath},
+				ASPath:        originAS100000,
 			},
 		},
 		{
@@ -161,11 +169,13 @@ func TestParseUpdate(t *testing.T) {
 			header:    fakeMRTHeader(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE, len(encodeBGP4MP(t, fakeAnn))),
 			body:      encodeBGP4MP(t, fakeAnn),
 			want: &update{
-				Collector:  "route-views3",
-				SeenAt:     fakeTime,
-				PeerAS:     15169,
-				Announced:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        fakeTime,
+				PeerAS:        15169,
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				ASPath:        originAS100000,
 			},
 		},
 		{
@@ -174,11 +184,12 @@ func TestParseUpdate(t *testing.T) {
 			header:    fakeMRTHeader(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, len(encodeBGP4MP(t, fakeAS4Withdrawal))),
 			body:      encodeBGP4MP(t, fakeAS4Withdrawal),
 			want: &update{
-				Collector:  "route-views3",
-				SeenAt:     fakeTime,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        fakeTime,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
 			},
 		},
 		{
@@ -188,11 +199,13 @@ func TestParseUpdate(t *testing.T) {
 			// Add fake microseconds for extened timestamp field.
 			body: append([]byte{1, 2, 3, 4}, encodeBGP4MP(t, fakeAS4Ann)...),
 			want: &update{
-				Collector:  "route-views3",
-				SeenAt:     fakeTime,
-				PeerAS:     100000, // 4-octet ASN as peer.
-				Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
-				Attributes: []*attributePayload{fourOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        fakeTime,
+				PeerAS:        100000, // 4-octet ASN as peer.
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+				Attributes:    []*attributePayload{fourOctetASPath},
+				ASPath:        originAS100000,
 			},
 		},
 		{
@@ -272,6 +285,44 @@ func TestRouteViewsCollectorFromPath(t *testing.T) {
 	}
 }
 
+func TestRISCollectorFromPath(t *testing.T) {
+	tests := []struct {
+		desc    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "updates",
+			path: "rrc00/2022.01/updates.20220109.1830.gz",
+			want: "rrc00",
+		},
+		{
+			desc: "updates under a prefix",
+			path: "/ripe-ris/rrc21/2022.01/updates.20220109.1830.gz",
+			want: "rrc21",
+		},
+		{
+			desc: "bview dump",
+			path: "rrc00/2022.01/bview.20220109.1600.gz",
+			want: "rrc00",
+		},
+		{
+			desc:    "bad file path - RouteViews archive",
+			path:    "route-views.sg/bgpdata/2021.11/UPDATES/updates.20211101.0000.bz2",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := risCollectorFromPath(test.path)
+			if gotErr := err != nil; test.wantErr != gotErr || got != test.want {
+				t.Errorf("risCollectorFromPath(%s) = '%s', %v; want '%s', wantErr = %v", test.path, got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
 func concatMsgs(msgs ...[]byte) []byte {
 	var res []byte
 	for _, msg := range msgs {
@@ -306,6 +357,30 @@ func makeResponse(t *testing.T, updates []*update) []byte {
 	return res
 }
 
+func TestConvertedName(t *testing.T) {
+	tests := []struct {
+		desc string
+		obj  string
+		want string
+	}{
+		{
+			desc: "RouteViews archive",
+			obj:  "route-views4/bgpdata/2021.12/UPDATES/updates.20211212.0015.bz2",
+			want: "route-views4/bgpdata/2021.12/UPDATES/updates.20211212.0015.gz",
+		},
+		{
+			desc: "RIPE RIS archive",
+			obj:  "ripe-ris/rrc00/2022.01/updates.20220109.1830.gz",
+			want: "ripe-ris/rrc00/2022.01/updates.20220109.1830.gz",
+		},
+	}
+	for _, test := range tests {
+		if got := ConvertedName(test.obj); got != test.want {
+			t.Errorf("%s: ConvertedName(%q) = %q, want %q", test.desc, test.obj, got, test.want)
+		}
+	}
+}
+
 func TestConvertMRT(t *testing.T) {
 	fakeTime := time.Now()
 	unextended := time.Unix(fakeTime.Unix(), 0)
@@ -320,11 +395,13 @@ func TestConvertMRT(t *testing.T) {
 			collector: "route-views2",
 			archive:   encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Ann)),
 			want: []*update{{
-				Collector:  "route-views2",
-				SeenAt:     unextended,
-				PeerAS:     100000, // 4-octet ASN as peer.
-				Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
-				Attributes: []*attributePayload{fourOctetASPath},
+				Collector:     "route-views2",
+				SeenAt:        unextended,
+				PeerAS:        100000, // 4-octet ASN as peer.
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+				Attributes:    []*attributePayload{fourOctetASPath},
+				ASPath:        originAS100000,
 			}},
 		},
 		{
@@ -332,11 +409,13 @@ func TestConvertMRT(t *testing.T) {
 			collector: "route-views3",
 			archive:   encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE, fakeAnn)),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     15169,
-				Announced:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        15169,
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				ASPath:        originAS100000,
 			}},
 		},
 		{
@@ -344,11 +423,12 @@ func TestConvertMRT(t *testing.T) {
 			collector: "route-views3",
 			archive:   encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal)),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
 			}},
 		},
 		{
@@ -356,11 +436,12 @@ func TestConvertMRT(t *testing.T) {
 			collector: "route-views3",
 			archive:   encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP_ET, mrt.MESSAGE_AS4, fakeAS4Withdrawal)),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
 			}},
 		}, {
 			desc:      "convert an archive with multiple updates",
@@ -371,23 +452,28 @@ func TestConvertMRT(t *testing.T) {
 				encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP_ET, mrt.MESSAGE_AS4, fakeAS4Withdrawal)),
 			),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000, // 4-octet ASN as peer.
-				Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
-				Attributes: []*attributePayload{fourOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000, // 4-octet ASN as peer.
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+				Attributes:    []*attributePayload{fourOctetASPath},
+				ASPath:        originAS100000,
 			}, {
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     15169,
-				Announced:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        15169,
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				ASPath:        originAS100000,
 			}, {
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
 			}},
 		}, {
 			desc:      "incomplete message - bad header",
@@ -397,11 +483,13 @@ func TestConvertMRT(t *testing.T) {
 				encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP_ET, mrt.MESSAGE_AS4, fakeAS4Withdrawal))[:10],
 			),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     15169,
-				Announced:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        15169,
+				AddressFamily: "ipv4-unicast",
+				Announced:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    []*attributePayload{twoOctetAS4Path, twoOctetASPath},
+				ASPath:        originAS100000,
 			}},
 		}, {
 			desc:      "incomplete message - bad body",
@@ -421,11 +509,89 @@ func TestConvertMRT(t *testing.T) {
 				encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal))),
 
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
+			}},
+		}, {
+			desc:      "convert an ADD-PATH update",
+			collector: "route-views3",
+			// BGP4MP MESSAGE_AS4_ADDPATH of two paths to 10.0.0.0/24, of
+			// path identifiers 7 and 8.
+			archive: []byte{97, 157, 202, 61, 0, 16, 0, 9, 0, 0, 0, 59, 0, 1, 134, 160, 0, 0,
+				25, 47, 0, 0, 0, 1, 1, 0, 0, 0, 2, 0, 0, 0, 255, 255, 255, 255, 255, 255, 255,
+				255, 255, 255, 255, 255, 255, 255, 255, 255,
+				0, 39, 2, 0, 0, 0, 0,
+				0, 0, 0, 7, 24, 10, 0, 0,
+				0, 0, 0, 8, 24, 10, 0, 0},
+			want: []*update{{
+				Collector:        "route-views3",
+				SeenAt:           time.Unix(1637730877, 0),
+				PeerAS:           100000,
+				AddressFamily:    "ipv4-unicast",
+				Announced:        []string{"10.0.0.0/24", "10.0.0.0/24"},
+				AnnouncedPathIDs: []uint32{7, 8},
+			}},
+		}, {
+			desc:      "convert IPv6 updates",
+			collector: "route-views6",
+			archive: concatMsgs(
+				// BGP4MP MESSAGE_AS4 of IPv6 peers, announcing 2606:4700::/32
+				// of AS path 6939 13335 in MP_REACH_NLRI, of a next hop of 32
+				// bytes: an unspecified global address, and fe80::1.
+				[]byte{97, 157, 202, 61, 0, 16, 0, 4, 0, 0, 0, 129, 0, 0, 27, 27, 0, 0, 25, 47, 0, 0, 0, 2,
+					32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+					32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+					255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255,
+					0, 85, 2, 0, 0, 0, 62,
+					64, 1, 1, 0,
+					64, 2, 10, 2, 2, 0, 0, 27, 27, 0, 0, 52, 23,
+					128, 14, 42, 0, 2, 1, 32,
+					0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+					254, 128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+					0, 32, 38, 6, 71, 0},
+				// Its withdrawal in MP_UNREACH_NLRI.
+				[]byte{97, 157, 202, 61, 0, 16, 0, 4, 0, 0, 0, 78, 0, 0, 27, 27, 0, 0, 25, 47, 0, 0, 0, 2,
+					32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+					32, 1, 13, 184, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+					255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255,
+					0, 34, 2, 0, 0, 0, 11,
+					128, 15, 8, 0, 2, 1, 32, 38, 6, 71, 0},
+			),
+			want: []*update{{
+				Collector:     "route-views6",
+				SeenAt:        time.Unix(1637730877, 0),
+				PeerAS:        6939,
+				AddressFamily: "ipv6-unicast",
+				Announced:     []string{"2606:4700::/32"},
+				Attributes: []*attributePayload{{
+					AttrType: bgp.BGP_ATTR_TYPE_ORIGIN,
+					Payload:  marshalAttr(bgp.NewPathAttributeOrigin(bgp.BGP_ORIGIN_ATTR_TYPE_IGP)),
+				}, {
+					AttrType: bgp.BGP_ATTR_TYPE_AS_PATH,
+					Payload: marshalAttr(bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+						&bgp.As4PathParam{Type: bgp.BGP_ASPATH_ATTR_TYPE_SEQ, Num: 2, AS: []uint32{6939, 13335}}})),
+				}, {
+					AttrType: bgp.BGP_ATTR_TYPE_MP_REACH_NLRI,
+					Payload: marshalAttr(bgp.NewPathAttributeMpReachNLRI("::", []bgp.AddrPrefixInterface{
+						bgp.NewIPv6AddrPrefix(32, "2606:4700::")})),
+				}},
+				ASPath:         &pathAnalysis{OriginAS: 13335, Length: 2, FirstHopAS: 6939, LastHopAS: 6939},
+				PathAttributes: &pathAttributes{Origin: "IGP", NextHop: "fe80::1"},
+			}, {
+				Collector:     "route-views6",
+				SeenAt:        time.Unix(1637730877, 0),
+				PeerAS:        6939,
+				AddressFamily: "ipv6-unicast",
+				Withdrawn:     []string{"2606:4700::/32"},
+				Attributes: []*attributePayload{{
+					AttrType: bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI,
+					Payload: marshalAttr(bgp.NewPathAttributeMpUnreachNLRI([]bgp.AddrPrefixInterface{
+						bgp.NewIPv6AddrPrefix(32, "2606:4700::")})),
+				}},
 			}},
 		}, {
 			desc:      "ignore unrecognized types of messages",
@@ -436,11 +602,12 @@ func TestConvertMRT(t *testing.T) {
 					mrt.NewBGP4MPStateChange(15169, 6447, 0, "1.0.0.0", "2.0.0.0", true, mrt.CONNECT, mrt.ACTIVE))),
 			),
 			want: []*update{{
-				Collector:  "route-views3",
-				SeenAt:     unextended,
-				PeerAS:     100000,
-				Withdrawn:  []string{"30.0.0.0/24", "40.0.0.0/24"},
-				Attributes: nil,
+				Collector:     "route-views3",
+				SeenAt:        unextended,
+				PeerAS:        100000,
+				AddressFamily: "ipv4-unicast",
+				Withdrawn:     []string{"30.0.0.0/24", "40.0.0.0/24"},
+				Attributes:    nil,
 			}},
 		},
 	}
@@ -473,7 +640,7 @@ func (w *badWriter) Write([]byte) (int, error) {
 func TestConvertMRTErrors(t *testing.T) {
 	t.Run("bad writer", func(t *testing.T) {
 		dst := &badWriter{err: fmt.Errorf("GCS not available")}
-		err := convertNext(bytes.NewReader(encodeMRTMessage(t, fakeMRTMessage(t, time.Now(), mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal))), dst, "routeviews.sg")
+		err := convertNext(bytes.NewReader(encodeMRTMessage(t, fakeMRTMessage(t, time.Now(), mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal))), dst, "routeviews.sg", &dumpState{})
 		if err == nil {
 			t.Error("convert() => nil err; want non-nil err")
 		}
@@ -515,11 +682,13 @@ func TestProcessMRTArchive(t *testing.T) {
 		t.Error(err)
 	}
 	wantUpdates := []*update{{
-		Collector:  "route-views2",
-		SeenAt:     fakeTime,
-		PeerAS:     100000,
-		Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
-		Attributes: []*attributePayload{fourOctetASPath},
+		Collector:     "route-views2",
+		SeenAt:        fakeTime,
+		PeerAS:        100000,
+		AddressFamily: "ipv4-unicast",
+		Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+		Attributes:    []*attributePayload{fourOctetASPath},
+		ASPath:        originAS100000,
 	}}
 
 	// Check if converted archive is expected.
@@ -550,6 +719,61 @@ func TestProcessMRTArchive(t *testing.T) {
 	}
 }
 
+func TestProcessRISArchive(t *testing.T) {
+	ctx := context.Background()
+
+	fakeTime := time.Unix(time.Now().Unix(), 0)
+	var fakeMRT bytes.Buffer
+	gw := gzip.NewWriter(&fakeMRT)
+	gw.Write(encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Ann)))
+	gw.Close()
+
+	dstBucket := "test-dst-bucket"
+	srcBucket := "test-src-bucket"
+	srcObject := "ripe-ris/rrc00/2022.01/updates.20220109.1830.gz"
+	fakegcs := fakestorage.NewServer([]fakestorage.Object{{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName: srcBucket,
+			Name:       srcObject,
+			Metadata: map[string]string{
+				ProjectMetadataKey: pb.FileRequest_RIPE_RIS.String(),
+			},
+		},
+		Content: fakeMRT.Bytes(),
+	}})
+	fakegcs.CreateBucketWithOpts(fakestorage.CreateBucketOpts{
+		Name: dstBucket,
+	})
+	t.Cleanup(fakegcs.Stop)
+
+	err := ProcessMRTArchive(ctx, fakegcs.Client(), &Config{
+		SrcBucket: srcBucket,
+		DstBucket: dstBucket,
+		SrcObject: srcObject,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	wantUpdates := []*update{{
+		Collector:     "rrc00",
+		SeenAt:        fakeTime,
+		PeerAS:        100000,
+		AddressFamily: "ipv4-unicast",
+		Announced:     []string{"10.0.0.0/24", "20.0.0.0/24"},
+		Attributes:    []*attributePayload{fourOctetASPath},
+		ASPath:        originAS100000,
+	}}
+
+	gotObj, err := fakegcs.GetObject(dstBucket, srcObject)
+	if err != nil {
+		t.Fatalf("fakegcs.GetObject(%s, %s): %v", dstBucket, srcObject, err)
+	}
+	want := makeResponse(t, wantUpdates)
+	if got := decompressed(t, bytes.NewBuffer(gotObj.Content)); string(want) != string(got) {
+		t.Errorf("ProcessMRTArchive() outputs mismatched:\nwant: %s\ngot: %s", string(want), string(got))
+	}
+}
+
 func TestProcessMRTArchiveErrors(t *testing.T) {
 	tests := []struct {
 		desc     string
@@ -596,3 +820,46 @@ func TestProcessMRTArchiveErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessMRTArchiveReadError(t *testing.T) {
+	ctx := context.Background()
+	first := encodeMRTMessage(t, fakeMRTMessage(t, time.Now(), mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Ann))
+	archive := concatMsgs(first, encodeMRTMessage(t, fakeMRTMessage(t, time.Now(), mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal)))
+	srcObject := "bgpdata/2021.11/UPDATES/updates.20211101.0000.bz2"
+	fakegcs := fakestorage.NewServer([]fakestorage.Object{{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName: "src-bucket",
+			Name:       srcObject,
+			Metadata:   map[string]string{ProjectMetadataKey: pb.FileRequest_ROUTEVIEWS.String()},
+		},
+		Content: archive,
+	}})
+	fakegcs.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "test-bucket"})
+	t.Cleanup(fakegcs.Stop)
+	cfg := &Config{SrcBucket: "src-bucket", SrcObject: srcObject, DstBucket: "test-bucket"}
+
+	// The read of the archive fails past its first message.
+	reset := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	cutShort := func(r io.Reader) (io.Reader, error) {
+		return io.MultiReader(io.LimitReader(r, int64(len(first))), iotest.ErrReader(reset)), nil
+	}
+	err := processMRTArchive(ctx, fakegcs.Client(), cfg, cutShort)
+	if !errors.Is(err, reset) || !Retryable(err) {
+		t.Fatalf("processMRTArchive(read error) = %v; want a Retryable error of the read", err)
+	}
+	if _, err := fakegcs.GetObject("test-bucket", ConvertedName(srcObject)); err == nil {
+		t.Fatal("got a converted object of the archive cut short; want none")
+	}
+
+	// The retry converts the whole archive, rather than skipping it.
+	if err := processMRTArchive(ctx, fakegcs.Client(), cfg, fakeBzip); err != nil {
+		t.Fatalf("processMRTArchive() = %v; want nil err", err)
+	}
+	obj, err := fakegcs.GetObject("test-bucket", ConvertedName(srcObject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bytes.Count(decompressed(t, bytes.NewBuffer(obj.Content)), []byte("\n")); got != 2 {
+		t.Errorf("converted %d updates; want 2", got)
+	}
+}
@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"net"
+
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+// updatePrefixes are the prefixes of an update: of its NLRI and withdrawn
+// routes, of IPv4 unicast, and of its MP_REACH_NLRI and MP_UNREACH_NLRI, RFC
+// 4760, ie: of IPv6 unicast; and their address family.
+type updatePrefixes struct {
+	announced []bgp.AddrPrefixInterface
+	withdrawn []bgp.AddrPrefixInterface
+	// family is that of the multiprotocol attributes, IPv4 unicast if there
+	// are none, ie: of the End-of-RIB of IPv4 unicast, an empty update.
+	family bgp.RouteFamily
+}
+
+func translateNLRI(u *bgp.BGPUpdate) updatePrefixes {
+	p := updatePrefixes{family: bgp.RF_IPv4_UC}
+	for _, n := range u.NLRI {
+		p.announced = append(p.announced, n)
+	}
+	for _, n := range u.WithdrawnRoutes {
+		p.withdrawn = append(p.withdrawn, n)
+	}
+	for _, attr := range u.PathAttributes {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeMpReachNLRI:
+			p.family = bgp.AfiSafiToRouteFamily(a.AFI, a.SAFI)
+			p.announced = append(p.announced, a.Value...)
+		case *bgp.PathAttributeMpUnreachNLRI:
+			p.family = bgp.AfiSafiToRouteFamily(a.AFI, a.SAFI)
+			p.withdrawn = append(p.withdrawn, a.Value...)
+		}
+	}
+	return p
+}
+
+// mpNextHop returns the next hop of an MP_REACH_NLRI. The next hop of IPv6 is
+// a global address, and a link-local one if its length is 32, RFC 2545; some
+// collectors record a next hop of 32 bytes of an unspecified global address,
+// the link-local address is the next hop then.
+func mpNextHop(a *bgp.PathAttributeMpReachNLRI) string {
+	if (a.Nexthop == nil || a.Nexthop.IsUnspecified()) && a.LinkLocalNexthop != nil && !a.LinkLocalNexthop.IsUnspecified() {
+		return ipString(a.LinkLocalNexthop)
+	}
+	return ipString(a.Nexthop)
+}
+
+func ipString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+)
+
+func TestTranslateNLRI(t *testing.T) {
+	v6 := []bgp.AddrPrefixInterface{bgp.NewIPv6AddrPrefix(32, "2606:4700::")}
+	tests := []struct {
+		desc          string
+		update        *bgp.BGPUpdate
+		wantAnnounced []string
+		wantWithdrawn []string
+		wantFamily    string
+	}{
+		{
+			desc: "IPv4 unicast",
+			update: &bgp.BGPUpdate{
+				NLRI:            []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "10.0.0.0")},
+				WithdrawnRoutes: []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "30.0.0.0")},
+			},
+			wantAnnounced: []string{"10.0.0.0/24"},
+			wantWithdrawn: []string{"30.0.0.0/24"},
+			wantFamily:    "ipv4-unicast",
+		}, {
+			desc:       "End-of-RIB of IPv4 unicast",
+			update:     &bgp.BGPUpdate{},
+			wantFamily: "ipv4-unicast",
+		}, {
+			desc: "IPv6 announcement",
+			update: &bgp.BGPUpdate{PathAttributes: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeMpReachNLRI{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST, Value: v6},
+			}},
+			wantAnnounced: []string{"2606:4700::/32"},
+			wantFamily:    "ipv6-unicast",
+		}, {
+			desc: "IPv6 withdrawal",
+			update: &bgp.BGPUpdate{PathAttributes: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeMpUnreachNLRI{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST, Value: v6},
+			}},
+			wantWithdrawn: []string{"2606:4700::/32"},
+			wantFamily:    "ipv6-unicast",
+		}, {
+			desc: "End-of-RIB of IPv6 unicast",
+			update: &bgp.BGPUpdate{PathAttributes: []bgp.PathAttributeInterface{
+				&bgp.PathAttributeMpUnreachNLRI{AFI: bgp.AFI_IP6, SAFI: bgp.SAFI_UNICAST},
+			}},
+			wantFamily: "ipv6-unicast",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := translateNLRI(test.update)
+			if diff := cmp.Diff(translatePrefixes(got.announced), test.wantAnnounced); diff != "" {
+				t.Errorf("translateNLRI() got announced diff (-got +want):\n%s", diff)
+			}
+			if diff := cmp.Diff(translatePrefixes(got.withdrawn), test.wantWithdrawn); diff != "" {
+				t.Errorf("translateNLRI() got withdrawn diff (-got +want):\n%s", diff)
+			}
+			if f := got.family.String(); f != test.wantFamily {
+				t.Errorf("translateNLRI() got family %s, want %s", f, test.wantFamily)
+			}
+		})
+	}
+}
+
+func TestMPNextHop(t *testing.T) {
+	tests := []struct {
+		desc string
+		attr *bgp.PathAttributeMpReachNLRI
+		want string
+	}{
+		{
+			desc: "global next hop",
+			attr: &bgp.PathAttributeMpReachNLRI{Nexthop: net.ParseIP("2001:db8::1")},
+			want: "2001:db8::1",
+		}, {
+			desc: "global and link-local next hops",
+			attr: &bgp.PathAttributeMpReachNLRI{Nexthop: net.ParseIP("2001:db8::1"), LinkLocalNexthop: net.ParseIP("fe80::1")},
+			want: "2001:db8::1",
+		}, {
+			desc: "unspecified global next hop",
+			attr: &bgp.PathAttributeMpReachNLRI{Nexthop: net.ParseIP("::"), LinkLocalNexthop: net.ParseIP("fe80::1")},
+			want: "fe80::1",
+		}, {
+			desc: "no next hop",
+			attr: &bgp.PathAttributeMpReachNLRI{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := mpNextHop(test.attr); got != test.want {
+				t.Errorf("mpNextHop() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/routeviews/google-cloud-storage/pkg/parquet"
+)
+
+// The Parquet schemas of the converted records: updates are flattened into a
+// row per prefix, RIB entries are a row each. The path attributes are the
+// JSON of the attributes of the BigQuery rows.
+var (
+	updateColumns = []parquet.Column{
+		{Name: "collector", Type: parquet.String},
+		{Name: "seen_at", Type: parquet.Timestamp},
+		{Name: "peer_as", Type: parquet.Int64},
+		{Name: "prefix", Type: parquet.String},
+		{Name: "path_id", Type: parquet.Int64},
+		{Name: "withdrawn", Type: parquet.Bool},
+		{Name: "attributes", Type: parquet.String},
+	}
+	ribColumns = []parquet.Column{
+		{Name: "collector", Type: parquet.String},
+		{Name: "dumped_at", Type: parquet.Timestamp},
+		{Name: "originated_at", Type: parquet.Timestamp},
+		{Name: "peer_as", Type: parquet.Int64},
+		{Name: "peer_ip", Type: parquet.String},
+		{Name: "peer_bgp_id", Type: parquet.String},
+		{Name: "prefix", Type: parquet.String},
+		{Name: "path_id", Type: parquet.Int64},
+		{Name: "attributes", Type: parquet.String},
+	}
+)
+
+// ParquetName returns the name of the Parquet file of the records of a kind,
+// updates or ribs, of an archive, see partitionName, ie:
+// updates/collector=route-views2/date=2021-09-01/updates.20210901.0000.parquet.
+func ParquetName(kind, collector string, t time.Time, obj string) string {
+	return partitionName(kind, collector, t, obj, ".parquet")
+}
+
+// parquetFile is a Parquet file of the sink, and the object it is written to.
+type parquetFile struct {
+	wc io.WriteCloser
+	w  *parquet.Writer
+}
+
+// parquetSink is the recordSink of Parquet files, a file per kind of record,
+// opened with the first record of its kind. The first error fails every later
+// write, and is returned by close. A nil sink writes nothing.
+type parquetSink struct {
+	collector string
+	obj       string
+	// open opens the object of a file of the sink.
+	open    func(name string) io.WriteCloser
+	updates *parquetFile
+	ribs    *parquetFile
+	err     error
+}
+
+func newParquetSink(collector, obj string, open func(name string) io.WriteCloser) *parquetSink {
+	return &parquetSink{collector: collector, obj: obj, open: open}
+}
+
+// file returns the file of a kind, opened if it is not already.
+func (s *parquetSink) file(f **parquetFile, kind string, cols []parquet.Column, t time.Time) *parquetFile {
+	if *f == nil {
+		wc := s.open(ParquetName(kind, s.collector, t, s.obj))
+		w, err := parquet.NewWriter(wc, cols, 0)
+		if err != nil {
+			s.err = fmt.Errorf("parquet.NewWriter(%s): %v", kind, err)
+		}
+		*f = &parquetFile{wc: wc, w: w}
+	}
+	return *f
+}
+
+// attributesJSON returns the JSON of the path attributes, empty if there are
+// none.
+func attributesJSON(attrs []*attributePayload) (string, error) {
+	if len(attrs) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %v", err)
+	}
+	return string(b), nil
+}
+
+// pathID returns the path identifier of the i-th prefix, zero without
+// ADD-PATH.
+func pathID(ids []uint32, i int) int64 {
+	if i < len(ids) {
+		return int64(ids[i])
+	}
+	return 0
+}
+
+// addUpdate writes a row of each prefix of an update, announced or withdrawn,
+// or a single row without a prefix for an update of neither, ie: of the
+// prefixes of other families in its MP_REACH_NLRI attribute.
+func (s *parquetSink) addUpdate(u *update) error {
+	if s == nil || s.err != nil {
+		return s.failed()
+	}
+	attrs, err := attributesJSON(u.Attributes)
+	if err != nil {
+		return err
+	}
+	f := s.file(&s.updates, "updates", updateColumns, u.SeenAt)
+	write := func(prefix string, id int64, withdrawn bool) {
+		if s.err == nil {
+			s.err = f.w.Write(u.Collector, u.SeenAt, int64(u.PeerAS), prefix, id, withdrawn, attrs)
+		}
+	}
+	for i, p := range u.Announced {
+		write(p, pathID(u.AnnouncedPathIDs, i), false)
+	}
+	for i, p := range u.Withdrawn {
+		write(p, pathID(u.WithdrawnPathIDs, i), true)
+	}
+	if len(u.Announced) == 0 && len(u.Withdrawn) == 0 {
+		write("", 0, false)
+	}
+	return s.err
+}
+
+// addRib writes a row of each entry of a RIB record.
+func (s *parquetSink) addRib(rows []*ribEntry) error {
+	if s == nil || s.err != nil {
+		return s.failed()
+	}
+	for _, r := range rows {
+		attrs, err := attributesJSON(r.Attributes)
+		if err != nil {
+			return err
+		}
+		f := s.file(&s.ribs, "ribs", ribColumns, r.DumpedAt)
+		if s.err == nil {
+			s.err = f.w.Write(r.Collector, r.DumpedAt, r.OriginatedAt, int64(r.PeerAS), r.PeerIP, r.PeerBGPID, r.Prefix, int64(r.PathID), attrs)
+		}
+		if s.err != nil {
+			return s.err
+		}
+	}
+	return nil
+}
+
+func (s *parquetSink) failed() error {
+	if s == nil {
+		return nil
+	}
+	return s.err
+}
+
+// close writes the footers of the files and closes their objects, it returns
+// the first error of the sink.
+func (s *parquetSink) close() error {
+	if s == nil {
+		return nil
+	}
+	for _, f := range []*parquetFile{s.updates, s.ribs} {
+		if f == nil {
+			continue
+		}
+		if s.err == nil && f.w != nil {
+			s.err = f.w.Close()
+		}
+		if err := f.wc.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
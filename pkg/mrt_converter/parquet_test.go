@@ -0,0 +1,106 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParquetName(t *testing.T) {
+	tests := []struct {
+		desc      string
+		kind      string
+		collector string
+		obj       string
+		want      string
+	}{
+		{
+			desc:      "RouteViews updates",
+			kind:      "updates",
+			collector: "route-views2",
+			obj:       "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2",
+			want:      "updates/collector=route-views2/date=2021-09-01/updates.20210901.0000.parquet",
+		},
+		{
+			desc:      "RIPE RIS bview",
+			kind:      "ribs",
+			collector: "rrc00",
+			obj:       "ripe-ris/rrc00/2021.09/bview.20210901.0000.gz",
+			want:      "ribs/collector=rrc00/date=2021-09-01/bview.20210901.0000.parquet",
+		},
+		{
+			desc: "unknown collector",
+			kind: "updates",
+			obj:  "updates.20210901.0000.bz2",
+			want: "updates/collector=unknown/date=2021-09-01/updates.20210901.0000.parquet",
+		},
+	}
+	ts := time.Date(2021, 9, 1, 0, 15, 0, 0, time.UTC)
+	for _, test := range tests {
+		if got := ParquetName(test.kind, test.collector, ts, test.obj); got != test.want {
+			t.Errorf("%s: ParquetName() = %q, want %q", test.desc, got, test.want)
+		}
+	}
+}
+
+// nopCloser is a buffer of an object of a parquetSink.
+type nopCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (c *nopCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestParquetSink(t *testing.T) {
+	files := map[string]*nopCloser{}
+	s := newParquetSink("route-views2", "route-views2/bgpdata/2021.09/UPDATES/updates.20210901.0000.bz2", func(name string) io.WriteCloser {
+		files[name] = &nopCloser{Buffer: &bytes.Buffer{}}
+		return files[name]
+	})
+	ts := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	u := &update{
+		Collector:  "route-views2",
+		SeenAt:     ts,
+		PeerAS:     100000,
+		Announced:  []string{"10.0.0.0/24", "20.0.0.0/24"},
+		Withdrawn:  []string{"30.0.0.0/24"},
+		Attributes: []*attributePayload{fourOctetASPath},
+	}
+	if err := s.addUpdate(u); err != nil {
+		t.Fatalf("addUpdate() got err: %v", err)
+	}
+	if err := s.addUpdate(u); err != nil {
+		t.Fatalf("addUpdate() got err: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("close() got err: %v", err)
+	}
+	var names []string
+	for name, f := range files {
+		names = append(names, name)
+		if !f.closed {
+			t.Errorf("close() left %s open", name)
+		}
+		b := f.Bytes()
+		if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+			t.Errorf("%s is not a Parquet file", name)
+		}
+	}
+	if diff := cmp.Diff(names, []string{"updates/collector=route-views2/date=2021-09-01/updates.20210901.0000.parquet"}); diff != "" {
+		t.Errorf("parquetSink got files diff (-got +want):\n%s", diff)
+	}
+
+	var nilSink *parquetSink
+	if err := nilSink.addUpdate(u); err != nil {
+		t.Errorf("addUpdate() of a nil sink got err: %v", err)
+	}
+	if err := nilSink.close(); err != nil {
+		t.Errorf("close() of a nil sink got err: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/cenkalti/backoff"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy bounds the attempts of the conversion of an archive which fails
+// with a Retryable error.
+type RetryPolicy struct {
+	// Attempts is the number of attempts, 1 or less makes a single one.
+	Attempts int
+	// InitialBackoff is the wait before the second attempt, doubled before
+	// each later one up to MaxBackoff. Each wait is jittered by up to half of
+	// its backoff either way.
+	InitialBackoff time.Duration
+	// MaxBackoff of 0 is a minute.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy of the Configs without one.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 4, InitialBackoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+// backOff returns the backoff of the policy, which stops after its attempts
+// or once ctx is done.
+func (p RetryPolicy) backOff(ctx context.Context) backoff.BackOff {
+	if p.Attempts <= 1 {
+		// WithMaxRetries of 0 retries forever.
+		return backoff.WithContext(&backoff.StopBackOff{}, ctx)
+	}
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = p.InitialBackoff
+	b.Multiplier = 2
+	if p.MaxBackoff > 0 {
+		b.MaxInterval = p.MaxBackoff
+	}
+	// The attempts bound the retries, rather than their time.
+	b.MaxElapsedTime = 0
+	b.Reset()
+	return backoff.WithContext(backoff.WithMaxRetries(b, uint64(p.Attempts-1)), ctx)
+}
+
+// Retryable reports whether the error of a conversion is of the storage or of
+// BigQuery, which may succeed on a retry: a 5xx, 429 or 408, a transient gRPC
+// code, a timeout or a transport error. The other errors are permanent, ie: an
+// archive which cannot be decompressed or lacks its metadata fails the same
+// way again, as does a missing object or a cancelled request.
+func Retryable(err error) bool {
+	var gErr *googleapi.Error
+	var sErr interface{ GRPCStatus() *status.Status }
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, ErrMarkedFailed),
+		errors.Is(err, storage.ErrObjectNotExist), errors.Is(err, storage.ErrBucketNotExist):
+		return false
+	case errors.As(err, &gErr):
+		return gErr.Code >= 500 || gErr.Code == http.StatusTooManyRequests || gErr.Code == http.StatusRequestTimeout
+	case errors.As(err, &sErr):
+		switch sErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.DeadlineExceeded:
+			return true
+		}
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+	return errors.As(err, &netErr)
+}
+
+// withRetries calls f until it succeeds, fails with an error which is not
+// Retryable, or the attempts of the policy are made, waiting the backoff of
+// the policy in between. It returns the error of the last attempt.
+func withRetries(ctx context.Context, p RetryPolicy, object string, f func() error) error {
+	attempt := 0
+	return backoff.RetryNotify(func() error {
+		attempt++
+		err := f()
+		if err != nil && !Retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, p.backOff(ctx), func(err error, wait time.Duration) {
+		log.WithFields(log.Fields{
+			"object":  object,
+			"attempt": attempt,
+			"backoff": wait.String(),
+		}).Warnf("retrying conversion: %v", err)
+	})
+}
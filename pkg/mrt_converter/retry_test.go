@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/cenkalti/backoff"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		desc string
+		err  error
+		want bool
+	}{
+		{desc: "no error"},
+		{desc: "GCS 503", err: fmt.Errorf("failed to write gs://b/o: %w", &googleapi.Error{Code: 503}), want: true},
+		{desc: "GCS 429", err: &googleapi.Error{Code: 429}, want: true},
+		{desc: "GCS 403", err: &googleapi.Error{Code: 403}},
+		{desc: "BigQuery unavailable", err: fmt.Errorf("Finalize(s): %w", status.Error(codes.Unavailable, "try again")), want: true},
+		{desc: "BigQuery invalid rows", err: status.Error(codes.InvalidArgument, "bad row")},
+		{desc: "transport error", err: fmt.Errorf("NewReader(gs://b/o): %w", &net.OpError{Op: "read", Err: errors.New("connection reset")}), want: true},
+		{desc: "timeout", err: context.DeadlineExceeded, want: true},
+		{desc: "cancelled", err: fmt.Errorf("obj.Attrs: %w", context.Canceled)},
+		{desc: "missing object", err: fmt.Errorf("readArchive(b, o): %w", storage.ErrObjectNotExist)},
+		{desc: "marked as failed", err: fmt.Errorf("readArchive(b, o): %w", ErrMarkedFailed)},
+		{desc: "parse error", err: errors.New("metadata 'routingDataProject' is missing from gs://b/o")},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if got := Retryable(test.err); got != test.want {
+				t.Errorf("Retryable(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		b := RetryPolicy{Attempts: 6, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}.backOff(context.Background())
+		for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+			// The jitter of the backoff package rounds up by a nanosecond.
+			if got := b.NextBackOff(); got < want/2 || got > want*3/2+1 {
+				t.Fatalf("NextBackOff() after attempt %d = %v, want between %v and %v", attempt+1, got, want/2, want*3/2)
+			}
+		}
+		if got := b.NextBackOff(); got != backoff.Stop {
+			t.Fatalf("NextBackOff() after the last attempt = %v, want Stop", got)
+		}
+	}
+	if got := (RetryPolicy{Attempts: 1, InitialBackoff: time.Second}).backOff(context.Background()).NextBackOff(); got != backoff.Stop {
+		t.Errorf("NextBackOff() of a single attempt = %v, want Stop", got)
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	unavailable := &googleapi.Error{Code: 503}
+	tests := []struct {
+		desc         string
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			desc:         "success",
+			errs:         []error{nil},
+			wantAttempts: 1,
+		}, {
+			desc:         "success on a retry",
+			errs:         []error{unavailable, unavailable, nil},
+			wantAttempts: 3,
+		}, {
+			desc:         "attempts exhausted",
+			errs:         []error{unavailable, unavailable, unavailable, unavailable},
+			wantAttempts: 3,
+			wantErr:      unavailable,
+		}, {
+			desc:         "permanent error not retried",
+			errs:         []error{ErrMarkedFailed, nil},
+			wantAttempts: 1,
+			wantErr:      ErrMarkedFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			attempts := 0
+			err := withRetries(context.Background(), RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond}, "gs://b/o", func() error {
+				attempts++
+				return test.errs[attempts-1]
+			})
+			if err != test.wantErr || attempts != test.wantAttempts {
+				t.Errorf("withRetries() = %v after %d attempts, want %v after %d", err, attempts, test.wantErr, test.wantAttempts)
+			}
+		})
+	}
+}
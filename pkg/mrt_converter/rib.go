@@ -0,0 +1,138 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+	log "github.com/sirupsen/logrus"
+)
+
+// ribSubTypes are the TABLE_DUMP_V2 subtypes which are converted: the peer
+// index table, and the unicast RIBs, with or without path identifiers.
+var ribSubTypes = map[mrt.MRTSubTypeTableDumpv2]bool{
+	mrt.PEER_INDEX_TABLE:         true,
+	mrt.RIB_IPV4_UNICAST:         true,
+	mrt.RIB_IPV6_UNICAST:         true,
+	mrt.RIB_IPV4_UNICAST_ADDPATH: true,
+	mrt.RIB_IPV6_UNICAST_ADDPATH: true,
+}
+
+// ribEntry represents a route of a TABLE_DUMP_V2 RIB dump: the path of a peer
+// to a prefix at the time of the dump. It will be written as JSON, which will
+// then be picked up by BigQuery, as a row of a RIB snapshot.
+type ribEntry struct {
+	Collector string
+	// DumpedAt is the time of the RIB record, OriginatedAt the time the peer
+	// announced the route.
+	DumpedAt     time.Time
+	OriginatedAt time.Time
+	PeerAS       uint32
+	PeerIP       string
+	PeerBGPID    string
+	Prefix       string
+	// AddressFamily is the AFI/SAFI of the prefix, ie: ipv6-unicast.
+	AddressFamily string
+	// PathID is the path identifier of the ADD-PATH RIBs, zero otherwise.
+	PathID     uint32 `json:",omitempty"`
+	Attributes []*attributePayload
+	// The extended and large communities of the path attributes, decoded.
+	ExtendedCommunities []*extendedCommunity `json:",omitempty"`
+	LargeCommunities    []*largeCommunity    `json:",omitempty"`
+	// ASPath is the analysis of the AS path, of the entries which have one.
+	ASPath *pathAnalysis `json:",omitempty"`
+	// PathAttributes are the path attributes, decoded.
+	PathAttributes *pathAttributes `json:",omitempty"`
+}
+
+// dumpState is the state of the conversion of an archive across its MRT
+// messages: the peer index table of a RIB dump, which its RIB entries refer
+// to by index, the sinks the records are written to as well, ie: Parquet
+// files, and the counts of the conversion, if any.
+type dumpState struct {
+	peers *mrt.PeerIndexTable
+	sinks []recordSink
+	stats *Stats
+}
+
+// parseRib converts a RIB record of a dump into the BigQuery compatible rows
+// of its entries, one row per peer. The entries of a peer missing from the
+// peer index table are skipped.
+func parseRib(collector string, h *mrt.MRTHeader, rib *mrt.Rib, peers *mrt.PeerIndexTable) ([]*ribEntry, error) {
+	if peers == nil {
+		return nil, fmt.Errorf("RIB record %d precedes the peer index table", rib.SequenceNumber)
+	}
+	var rows []*ribEntry
+	for _, e := range rib.Entries {
+		if int(e.PeerIndex) >= len(peers.Peers) {
+			log.WithFields(log.Fields{"sequence": rib.SequenceNumber, "peerIndex": e.PeerIndex}).Debug("RIB entry of an unknown peer")
+			continue
+		}
+		p := peers.Peers[e.PeerIndex]
+		ext, large := translateCommunities(e.PathAttributes)
+		rows = append(rows, &ribEntry{
+			Collector:     collector,
+			DumpedAt:      h.GetTime(),
+			OriginatedAt:  time.Unix(int64(e.OriginatedTime), 0),
+			PeerAS:        p.AS,
+			PeerIP:        p.IpAddress.String(),
+			PeerBGPID:     p.BgpId.String(),
+			Prefix:        rib.Prefix.String(),
+			AddressFamily: rib.RouteFamily.String(),
+			PathID:        e.PathIdentifier,
+			Attributes:    translateAttrs(e.PathAttributes),
+
+			ExtendedCommunities: ext,
+			LargeCommunities:    large,
+			ASPath:              analyzeASPath(e.PathAttributes),
+			PathAttributes:      translatePathAttributes(e.PathAttributes),
+		})
+	}
+	return rows, nil
+}
+
+// convertRib converts a TABLE_DUMP_V2 message: a peer index table is kept in
+// the state of the dump, the entries of a RIB record are written as JSONL.
+// Messages which fail to parse are skipped, as updates are.
+func convertRib(w io.Writer, collector string, h *mrt.MRTHeader, buf []byte, st *dumpState) error {
+	if !ribSubTypes[mrt.MRTSubTypeTableDumpv2(h.SubType)] {
+		log.WithFields(log.Fields{"type": h.Type, "subType": h.SubType}).Debug("unsupported message types")
+		return nil
+	}
+	msg, err := mrt.ParseMRTBody(h, buf)
+	if err != nil {
+		log.Debug(fmt.Errorf("failed to parse RIB message: %v, bytes: %v", err, buf))
+		st.stats.parseError()
+		return nil
+	}
+	var rows []*ribEntry
+	switch body := msg.Body.(type) {
+	case *mrt.PeerIndexTable:
+		st.peers = body
+		return nil
+	case *mrt.Rib:
+		if rows, err = parseRib(collector, h, body, st.peers); err != nil {
+			log.Debug(fmt.Errorf("failed to parse RIB: %v", err))
+			st.stats.parseError()
+			return nil
+		}
+	}
+	for _, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %v", err)
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("writer.Write: %v", err)
+		}
+	}
+	for _, s := range st.sinks {
+		if err := s.addRib(rows); err != nil {
+			return err
+		}
+	}
+	st.stats.rows(len(rows))
+	return nil
+}
@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/osrg/gobgp/pkg/packet/bgp"
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+)
+
+var (
+	fakePeers = mrt.NewPeerIndexTable("128.223.51.102", "", []*mrt.Peer{
+		mrt.NewPeer("1.1.1.1", "10.0.0.1", 100000, true),
+		mrt.NewPeer("2.2.2.2", "2001:db8::2", 6447, true),
+	})
+	fakeRib = mrt.NewRib(1, bgp.NewIPAddrPrefix(24, "10.0.0.0"), []*mrt.RibEntry{
+		mrt.NewRibEntry(0, 1630454000, 0, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{&bgp.As4PathParam{Type: bgp.BGP_ASPATH_ATTR_TYPE_SEQ, Num: 1, AS: []uint32{100000}}}),
+		}, false),
+		mrt.NewRibEntry(1, 1630453000, 0, nil, false),
+		// An entry of a peer missing from the peer index table.
+		mrt.NewRibEntry(7, 1630453000, 0, nil, false),
+	})
+	fakeRib6 = mrt.NewRib(2, bgp.NewIPv6AddrPrefix(32, "2001:db8::"), []*mrt.RibEntry{
+		mrt.NewRibEntry(1, 1630452000, 0, nil, false),
+	})
+)
+
+func makeRibResponse(t *testing.T, rows []*ribEntry) []byte {
+	t.Helper()
+	var res []byte
+	for _, r := range rows {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, append(b, '\n')...)
+	}
+	return res
+}
+
+func TestConvertRib(t *testing.T) {
+	fakeTime := time.Unix(1630454400, 0)
+	peerTable := encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.PEER_INDEX_TABLE, fakePeers))
+	rib := encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.RIB_IPV4_UNICAST, fakeRib))
+	rib6 := encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.RIB_IPV6_UNICAST, fakeRib6))
+	tests := []struct {
+		desc      string
+		collector string
+		archive   []byte
+		want      []*ribEntry
+	}{
+		{
+			desc:      "RIB dump",
+			collector: "route-views2",
+			archive:   concatMsgs(peerTable, rib, rib6),
+			want: []*ribEntry{{
+				Collector:     "route-views2",
+				DumpedAt:      fakeTime,
+				OriginatedAt:  time.Unix(1630454000, 0),
+				PeerAS:        100000,
+				PeerIP:        "10.0.0.1",
+				PeerBGPID:     "1.1.1.1",
+				Prefix:        "10.0.0.0/24",
+				AddressFamily: "ipv4-unicast",
+				Attributes:    []*attributePayload{fourOctetASPath},
+				ASPath:        originAS100000,
+			}, {
+				Collector:     "route-views2",
+				DumpedAt:      fakeTime,
+				OriginatedAt:  time.Unix(1630453000, 0),
+				PeerAS:        6447,
+				PeerIP:        "2001:db8::2",
+				PeerBGPID:     "2.2.2.2",
+				Prefix:        "10.0.0.0/24",
+				AddressFamily: "ipv4-unicast",
+			}, {
+				Collector:     "route-views2",
+				DumpedAt:      fakeTime,
+				OriginatedAt:  time.Unix(1630452000, 0),
+				PeerAS:        6447,
+				PeerIP:        "2001:db8::2",
+				PeerBGPID:     "2.2.2.2",
+				Prefix:        "2001:db8::/32",
+				AddressFamily: "ipv6-unicast",
+			}},
+		},
+		{
+			desc:      "RIB without a peer index table",
+			collector: "route-views2",
+			archive:   concatMsgs(rib, rib6),
+		},
+		{
+			desc:      "ignore unsupported subtypes",
+			collector: "route-views2",
+			archive: concatMsgs(peerTable,
+				encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.RIB_IPV4_MULTICAST, fakeRib)),
+				rib6),
+			want: []*ribEntry{{
+				Collector:     "route-views2",
+				DumpedAt:      fakeTime,
+				OriginatedAt:  time.Unix(1630452000, 0),
+				PeerAS:        6447,
+				PeerIP:        "2001:db8::2",
+				PeerBGPID:     "2.2.2.2",
+				Prefix:        "2001:db8::/32",
+				AddressFamily: "ipv6-unicast",
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			convert(test.collector, bytes.NewBuffer(test.archive), buf, fakeBzip)
+
+			got := decompressed(t, buf)
+			want := makeRibResponse(t, test.want)
+			if string(want) != string(got) {
+				t.Errorf("convert() outputs mismatched:\nwant: %s\ngot: %s", string(want), string(got))
+			}
+		})
+	}
+}
+
+func TestProcessRISBview(t *testing.T) {
+	ctx := context.Background()
+
+	fakeTime := time.Unix(time.Now().Unix(), 0)
+	var fakeMRT bytes.Buffer
+	gw := gzip.NewWriter(&fakeMRT)
+	gw.Write(encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.PEER_INDEX_TABLE, fakePeers)))
+	gw.Write(encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.TABLE_DUMPv2, mrt.RIB_IPV6_UNICAST, fakeRib6)))
+	gw.Close()
+
+	dstBucket := "test-dst-bucket"
+	srcBucket := "test-src-bucket"
+	srcObject := "ripe-ris/rrc00/2022.01/bview.20220109.1600.gz"
+	fakegcs := fakestorage.NewServer([]fakestorage.Object{{
+		ObjectAttrs: fakestorage.ObjectAttrs{
+			BucketName: srcBucket,
+			Name:       srcObject,
+			Metadata: map[string]string{
+				ProjectMetadataKey: pb.FileRequest_RIPE_RIS.String(),
+			},
+		},
+		Content: fakeMRT.Bytes(),
+	}})
+	fakegcs.CreateBucketWithOpts(fakestorage.CreateBucketOpts{
+		Name: dstBucket,
+	})
+	t.Cleanup(fakegcs.Stop)
+
+	err := ProcessMRTArchive(ctx, fakegcs.Client(), &Config{
+		SrcBucket: srcBucket,
+		DstBucket: dstBucket,
+		SrcObject: srcObject,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	wantRows := []*ribEntry{{
+		Collector:     "rrc00",
+		DumpedAt:      fakeTime,
+		OriginatedAt:  time.Unix(1630452000, 0),
+		PeerAS:        6447,
+		PeerIP:        "2001:db8::2",
+		PeerBGPID:     "2.2.2.2",
+		Prefix:        "2001:db8::/32",
+		AddressFamily: "ipv6-unicast",
+	}}
+
+	gotObj, err := fakegcs.GetObject(dstBucket, srcObject)
+	if err != nil {
+		t.Fatalf("fakegcs.GetObject(%s, %s): %v", dstBucket, srcObject, err)
+	}
+	want := makeRibResponse(t, wantRows)
+	if got := decompressed(t, bytes.NewBuffer(gotObj.Content)); string(want) != string(got) {
+		t.Errorf("ProcessMRTArchive() outputs mismatched:\nwant: %s\ngot: %s", string(want), string(got))
+	}
+}
@@ -0,0 +1,34 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// recordSink writes the converted records of an archive in another format as
+// well, ie: as Parquet or Avro files.
+type recordSink interface {
+	addUpdate(u *update) error
+	addRib(rows []*ribEntry) error
+	// close writes the rest of the records, and returns the first error of
+	// the sink.
+	close() error
+}
+
+// partitionName returns the name of the file of the records of a kind, updates
+// or ribs, of an archive, partitioned by collector and date as Spark, DuckDB
+// and Dataflow read them:
+//
+//	<kind>/collector=<collector>/date=<YYYY-MM-DD>/<archive><ext>
+//
+// The date is the UTC date of the first record of the archive.
+func partitionName(kind, collector string, t time.Time, obj, ext string) string {
+	if collector == "" {
+		collector = "unknown"
+	}
+	base := path.Base(obj)
+	base = strings.TrimSuffix(base, path.Ext(base))
+	return fmt.Sprintf("%s/collector=%s/date=%s/%s%s", kind, collector, t.UTC().Format("2006-01-02"), base, ext)
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"path"
+	"strings"
+)
+
+// Stats are the counts of the conversion of an archive, labelled by its
+// collector and kind, so a collector whose archives stop parsing stands out.
+// A nil Stats counts nothing.
+type Stats struct {
+	// Collector is empty until the archive is read, or if it has none.
+	Collector string
+	// Kind is updates or ribs, by the name of the archive, empty if the name
+	// is neither.
+	Kind string
+	// Records are the MRT records read, of any type.
+	Records int64
+	// ParseErrors are the records which failed to parse and were skipped,
+	// and the corrupt or truncated record which ended the conversion, if any.
+	ParseErrors int64
+	// Rows are the rows of the updates and RIB entries written.
+	Rows int64
+	// Skipped is set if the archive was converted already, and is not read.
+	Skipped bool
+}
+
+func (s *Stats) record() {
+	if s != nil {
+		s.Records++
+	}
+}
+
+func (s *Stats) parseError() {
+	if s != nil {
+		s.ParseErrors++
+	}
+}
+
+func (s *Stats) rows(n int) {
+	if s != nil {
+		s.Rows += int64(n)
+	}
+}
+
+// archiveKind returns the kind of an archive by its name: ribs of the RIB
+// dumps of RouteViews and the bviews of RIPE RIS, updates of the updates of
+// either.
+func archiveKind(object string) string {
+	base := strings.ToLower(path.Base(object))
+	switch {
+	case strings.HasPrefix(base, "rib.") || strings.HasPrefix(base, "bview."):
+		return "ribs"
+	case strings.HasPrefix(base, "updates."):
+		return "updates"
+	}
+	return ""
+}
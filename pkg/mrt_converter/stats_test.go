@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+)
+
+func TestArchiveKind(t *testing.T) {
+	tests := []struct {
+		object string
+		want   string
+	}{
+		{object: "route-views2/bgpdata/2021.11/UPDATES/updates.20211101.0000.bz2", want: "updates"},
+		{object: "route-views2/bgpdata/2021.11/RIBS/rib.20211101.0000.bz2", want: "ribs"},
+		{object: "rrc00/2021.11/updates.20211101.0000.gz", want: "updates"},
+		{object: "rrc00/2021.11/bview.20211101.0000.gz", want: "ribs"},
+		{object: "route-views2/bgpdata/2021.11/README"},
+	}
+	for _, test := range tests {
+		if got := archiveKind(test.object); got != test.want {
+			t.Errorf("archiveKind(%q) = %q, want %q", test.object, got, test.want)
+		}
+	}
+}
+
+func TestConvertStats(t *testing.T) {
+	fakeTime := time.Now()
+	header, err := fakeMRTHeader(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, 4).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := concatMsgs(
+		encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Ann)),
+		// An update whose body does not parse is skipped.
+		header, []byte{1, 2, 3, 4},
+		encodeMRTMessage(t, fakeMRTMessage(t, fakeTime, mrt.BGP4MP, mrt.MESSAGE_AS4, fakeAS4Withdrawal)),
+		// A truncated record ends the conversion.
+		header[:5],
+	)
+	st := &dumpState{stats: &Stats{Collector: "route-views2", Kind: "updates"}}
+	convertWith("route-views2", bytes.NewReader(archive), ioutil.Discard, fakeBzip, st)
+	want := &Stats{Collector: "route-views2", Kind: "updates", Records: 3, ParseErrors: 2, Rows: 2}
+	if diff := cmp.Diff(st.stats, want); diff != "" {
+		t.Errorf("convertWith() got stats diff (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,310 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+	"gopkg.in/yaml.v2"
+)
+
+// The partitioning of the tables of the Storage Write API output.
+const (
+	// PartitionIngestion partitions the rows by the time they are
+	// committed, the _PARTITIONTIME of the table.
+	PartitionIngestion = "ingestion"
+	// PartitionCapture partitions the rows by the time the collector
+	// captured their records: SeenAt of the updates, DumpedAt of the RIB
+	// entries.
+	PartitionCapture = "capture"
+)
+
+// captureFields are the capture timestamps of the kinds of records.
+var captureFields = map[string]string{
+	"updates": "SeenAt",
+	"ribs":    "DumpedAt",
+}
+
+// TableConfig is where the records of the archives of a project are written
+// with the Storage Write API: the dataset, of a cloud project, the tables of
+// the updates and RIB entries, and how the tables are partitioned.
+type TableConfig struct {
+	Project      string `yaml:"project"`
+	Dataset      string `yaml:"dataset"`
+	UpdatesTable string `yaml:"updates_table"`
+	RibsTable    string `yaml:"ribs_table"`
+	// Partitioning is PartitionIngestion, the default, or PartitionCapture.
+	Partitioning string `yaml:"partitioning"`
+	// PartitionExpiration is how long the partitions are kept, forever if
+	// zero.
+	PartitionExpiration time.Duration `yaml:"partition_expiration"`
+}
+
+// table returns the table of a kind of records, updates or ribs.
+func (c TableConfig) table(kind string) string {
+	if kind == "ribs" {
+		return c.RibsTable
+	}
+	return c.UpdatesTable
+}
+
+// timePartitioning returns the partitioning of the table of a kind of records.
+func (c TableConfig) timePartitioning(kind string) *bigquery.TimePartitioning {
+	p := &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Expiration: c.PartitionExpiration}
+	if c.Partitioning == PartitionCapture {
+		p.Field = captureFields[kind]
+	}
+	return p
+}
+
+func (c TableConfig) validate() error {
+	switch {
+	case c.Project == "" || c.Dataset == "":
+		return errors.New("no project or dataset")
+	case c.Partitioning != PartitionIngestion && c.Partitioning != PartitionCapture:
+		return fmt.Errorf("bad partitioning(%q), want %s or %s", c.Partitioning, PartitionIngestion, PartitionCapture)
+	case c.PartitionExpiration < 0:
+		return fmt.Errorf("negative partition_expiration(%v)", c.PartitionExpiration)
+	}
+	return nil
+}
+
+// Tables maps the source projects of the archives, ie: ROUTEVIEWS, to the
+// TableConfig of their records, the fields a project does not set are those
+// of the default.
+type Tables struct {
+	Default  TableConfig            `yaml:"default"`
+	Projects map[string]TableConfig `yaml:"projects"`
+}
+
+// ParseTables parses the YAML of Tables, ie:
+//
+//	default:
+//	  project: routeviews
+//	  dataset: mrt
+//	  partitioning: capture
+//	projects:
+//	  RIPE_RIS:
+//	    dataset: ris
+//	    partition_expiration: 8760h
+//
+// The tables are updates and ribs, partitioned by ingestion time, unless
+// they are set.
+func ParseTables(b []byte) (*Tables, error) {
+	t := &Tables{}
+	if err := yaml.UnmarshalStrict(b, t); err != nil {
+		return nil, err
+	}
+	for project := range t.Projects {
+		if err := t.For(project).validate(); err != nil {
+			return nil, fmt.Errorf("tables of %s: %v", project, err)
+		}
+	}
+	if err := t.For("").validate(); err != nil {
+		return nil, fmt.Errorf("default tables: %v", err)
+	}
+	return t, nil
+}
+
+// For returns the TableConfig of a project.
+func (t *Tables) For(project string) TableConfig {
+	c := t.Default
+	if p, ok := t.Projects[project]; ok {
+		if p.Project != "" {
+			c.Project = p.Project
+		}
+		if p.Dataset != "" {
+			c.Dataset = p.Dataset
+		}
+		if p.UpdatesTable != "" {
+			c.UpdatesTable = p.UpdatesTable
+		}
+		if p.RibsTable != "" {
+			c.RibsTable = p.RibsTable
+		}
+		if p.Partitioning != "" {
+			c.Partitioning = p.Partitioning
+		}
+		if p.PartitionExpiration != 0 {
+			c.PartitionExpiration = p.PartitionExpiration
+		}
+	}
+	if c.UpdatesTable == "" {
+		c.UpdatesTable = "updates"
+	}
+	if c.RibsTable == "" {
+		c.RibsTable = "ribs"
+	}
+	if c.Partitioning == "" {
+		c.Partitioning = PartitionIngestion
+	}
+	return c
+}
+
+// configs returns the distinct TableConfigs, of the default and of each
+// project.
+func (t *Tables) configs() []TableConfig {
+	cs := []TableConfig{t.For("")}
+	seen := map[TableConfig]bool{cs[0]: true}
+	for project := range t.Projects {
+		if c := t.For(project); !seen[c] {
+			seen[c] = true
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+var (
+	attributesSchema = bigquery.Schema{
+		{Name: "AttrType", Type: bigquery.IntegerFieldType},
+		{Name: "Payload", Type: bigquery.StringFieldType},
+	}
+	extendedCommunitySchema = bigquery.Schema{
+		{Name: "Type", Type: bigquery.IntegerFieldType},
+		{Name: "Subtype", Type: bigquery.IntegerFieldType},
+		{Name: "Value", Type: bigquery.StringFieldType},
+	}
+	largeCommunitySchema = bigquery.Schema{
+		{Name: "GlobalAdmin", Type: bigquery.IntegerFieldType},
+		{Name: "LocalData1", Type: bigquery.IntegerFieldType},
+		{Name: "LocalData2", Type: bigquery.IntegerFieldType},
+	}
+	asPathSchema = bigquery.Schema{
+		{Name: "OriginAS", Type: bigquery.IntegerFieldType},
+		{Name: "Length", Type: bigquery.IntegerFieldType},
+		{Name: "Prepends", Type: bigquery.IntegerFieldType},
+		{Name: "HasASSet", Type: bigquery.BooleanFieldType},
+		{Name: "FirstHopAS", Type: bigquery.IntegerFieldType},
+		{Name: "LastHopAS", Type: bigquery.IntegerFieldType},
+	}
+	pathAttributesSchema = bigquery.Schema{
+		{Name: "Origin", Type: bigquery.StringFieldType},
+		{Name: "NextHop", Type: bigquery.StringFieldType},
+		{Name: "MED", Type: bigquery.IntegerFieldType},
+		{Name: "LocalPref", Type: bigquery.IntegerFieldType},
+		{Name: "AtomicAggregate", Type: bigquery.BooleanFieldType},
+		{Name: "AggregatorAS", Type: bigquery.IntegerFieldType},
+		{Name: "AggregatorAddress", Type: bigquery.StringFieldType},
+		{Name: "Communities", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "OriginatorID", Type: bigquery.StringFieldType},
+		{Name: "ClusterList", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "Unknown", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+			{Name: "AttrType", Type: bigquery.IntegerFieldType},
+			{Name: "Flags", Type: bigquery.IntegerFieldType},
+			{Name: "Value", Type: bigquery.StringFieldType},
+		}},
+	}
+	// bqSchemas are the schemas of the tables of the kinds of records, of
+	// updateDescriptor and ribDescriptor.
+	bqSchemas = map[string]bigquery.Schema{
+		"updates": {
+			{Name: "Collector", Type: bigquery.StringFieldType},
+			{Name: "SeenAt", Type: bigquery.TimestampFieldType},
+			{Name: "PeerAS", Type: bigquery.IntegerFieldType},
+			{Name: "Announced", Type: bigquery.StringFieldType, Repeated: true},
+			{Name: "Withdrawn", Type: bigquery.StringFieldType, Repeated: true},
+			{Name: "Attributes", Type: bigquery.RecordFieldType, Repeated: true, Schema: attributesSchema},
+			{Name: "AnnouncedPathIDs", Type: bigquery.IntegerFieldType, Repeated: true},
+			{Name: "WithdrawnPathIDs", Type: bigquery.IntegerFieldType, Repeated: true},
+			{Name: "ExtendedCommunities", Type: bigquery.RecordFieldType, Repeated: true, Schema: extendedCommunitySchema},
+			{Name: "LargeCommunities", Type: bigquery.RecordFieldType, Repeated: true, Schema: largeCommunitySchema},
+			{Name: "ASPath", Type: bigquery.RecordFieldType, Schema: asPathSchema},
+			{Name: "PathAttributes", Type: bigquery.RecordFieldType, Schema: pathAttributesSchema},
+			{Name: "AddressFamily", Type: bigquery.StringFieldType},
+		},
+		"ribs": {
+			{Name: "Collector", Type: bigquery.StringFieldType},
+			{Name: "DumpedAt", Type: bigquery.TimestampFieldType},
+			{Name: "OriginatedAt", Type: bigquery.TimestampFieldType},
+			{Name: "PeerAS", Type: bigquery.IntegerFieldType},
+			{Name: "PeerIP", Type: bigquery.StringFieldType},
+			{Name: "PeerBGPID", Type: bigquery.StringFieldType},
+			{Name: "Prefix", Type: bigquery.StringFieldType},
+			{Name: "PathID", Type: bigquery.IntegerFieldType},
+			{Name: "Attributes", Type: bigquery.RecordFieldType, Repeated: true, Schema: attributesSchema},
+			{Name: "ExtendedCommunities", Type: bigquery.RecordFieldType, Repeated: true, Schema: extendedCommunitySchema},
+			{Name: "LargeCommunities", Type: bigquery.RecordFieldType, Repeated: true, Schema: largeCommunitySchema},
+			{Name: "ASPath", Type: bigquery.RecordFieldType, Schema: asPathSchema},
+			{Name: "PathAttributes", Type: bigquery.RecordFieldType, Schema: pathAttributesSchema},
+			{Name: "AddressFamily", Type: bigquery.StringFieldType},
+		},
+	}
+)
+
+// EnsureTables creates the tables of the configs which do not exist, with
+// their partitioning and clustering, see clusterFields. The partitioning of a
+// table which exists cannot change, a table partitioned otherwise is an error,
+// but the expiration of its partitions and its clustering are updated; the
+// rows it has are not reclustered, see MigrateClustering. The columns it lacks
+// are added.
+func EnsureTables(ctx context.Context, cli *bigquery.Client, t *Tables) error {
+	for _, c := range t.configs() {
+		for _, kind := range []string{"updates", "ribs"} {
+			if err := ensureTable(ctx, cli, c, kind); err != nil {
+				return fmt.Errorf("%s.%s.%s: %v", c.Project, c.Dataset, c.table(kind), err)
+			}
+		}
+	}
+	return nil
+}
+
+func ensureTable(ctx context.Context, cli *bigquery.Client, c TableConfig, kind string) error {
+	tbl := cli.DatasetInProject(c.Project, c.Dataset).Table(c.table(kind))
+	want := c.timePartitioning(kind)
+	md, err := tbl.Metadata(ctx)
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return tbl.Create(ctx, &bigquery.TableMetadata{
+			Schema:           bqSchemas[kind],
+			TimePartitioning: want,
+			Clustering:       &bigquery.Clustering{Fields: clusterFields[kind]},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	got := md.TimePartitioning
+	if got == nil || got.Field != want.Field {
+		return fmt.Errorf("table is not partitioned by %s", c.Partitioning)
+	}
+	var update bigquery.TableMetadataToUpdate
+	if got.Expiration != want.Expiration {
+		p := *got
+		p.Expiration = want.Expiration
+		update.TimePartitioning = &p
+	}
+	if !clustered(md.Clustering, kind) {
+		update.Clustering = &bigquery.Clustering{Fields: clusterFields[kind]}
+	}
+	if s, ok := addColumns(md.Schema, bqSchemas[kind]); ok {
+		update.Schema = s
+	}
+	if update.TimePartitioning == nil && update.Clustering == nil && update.Schema == nil {
+		return nil
+	}
+	_, err = tbl.Update(ctx, update, md.ETag)
+	return err
+}
+
+// addColumns returns the schema of a table with the columns of want it lacks,
+// ie: of the columns added to the records since it was created, and whether it
+// lacks any.
+func addColumns(got, want bigquery.Schema) (bigquery.Schema, bool) {
+	have := map[string]bool{}
+	for _, f := range got {
+		have[strings.ToLower(f.Name)] = true
+	}
+	s := append(bigquery.Schema{}, got...)
+	for _, f := range want {
+		if !have[strings.ToLower(f.Name)] {
+			s = append(s, f)
+		}
+	}
+	return s, len(s) > len(got)
+}
@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTablesFor(t *testing.T) {
+	tables := &Tables{
+		Default: TableConfig{Project: "routeviews", Dataset: "mrt", Partitioning: PartitionCapture},
+		Projects: map[string]TableConfig{
+			"RIPE_RIS": {Dataset: "ris", UpdatesTable: "ris_updates", PartitionExpiration: 8760 * time.Hour},
+		},
+	}
+	tests := []struct {
+		desc    string
+		project string
+		want    TableConfig
+	}{
+		{
+			desc:    "default",
+			project: "ROUTEVIEWS",
+			want:    TableConfig{Project: "routeviews", Dataset: "mrt", UpdatesTable: "updates", RibsTable: "ribs", Partitioning: PartitionCapture},
+		},
+		{
+			desc:    "project",
+			project: "RIPE_RIS",
+			want:    TableConfig{Project: "routeviews", Dataset: "ris", UpdatesTable: "ris_updates", RibsTable: "ribs", Partitioning: PartitionCapture, PartitionExpiration: 8760 * time.Hour},
+		},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(tables.For(test.project), test.want); diff != "" {
+			t.Errorf("%s: For(%q) got diff (-got +want):\n%s", test.desc, test.project, diff)
+		}
+	}
+	if got := (&Tables{}).For("ROUTEVIEWS").Partitioning; got != PartitionIngestion {
+		t.Errorf("For() got partitioning %q by default, want %q", got, PartitionIngestion)
+	}
+}
+
+func TestTableConfigValidate(t *testing.T) {
+	ok := TableConfig{Project: "routeviews", Dataset: "mrt", Partitioning: PartitionIngestion}
+	tests := []struct {
+		desc    string
+		edit    func(c *TableConfig)
+		wantErr bool
+	}{
+		{desc: "valid", edit: func(c *TableConfig) {}},
+		{desc: "no dataset", edit: func(c *TableConfig) { c.Dataset = "" }, wantErr: true},
+		{desc: "bad partitioning", edit: func(c *TableConfig) { c.Partitioning = "hourly" }, wantErr: true},
+		{desc: "negative expiration", edit: func(c *TableConfig) { c.PartitionExpiration = -time.Hour }, wantErr: true},
+	}
+	for _, test := range tests {
+		c := ok
+		test.edit(&c)
+		if err := c.validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: validate() got err: %v, want err %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestTimePartitioning(t *testing.T) {
+	c := TableConfig{Partitioning: PartitionCapture, PartitionExpiration: time.Hour}
+	tests := []struct {
+		kind string
+		c    TableConfig
+		want *bigquery.TimePartitioning
+	}{
+		{kind: "updates", c: c, want: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Expiration: time.Hour, Field: "SeenAt"}},
+		{kind: "ribs", c: c, want: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Expiration: time.Hour, Field: "DumpedAt"}},
+		{kind: "ribs", c: TableConfig{Partitioning: PartitionIngestion}, want: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType}},
+	}
+	for _, test := range tests {
+		if diff := cmp.Diff(test.c.timePartitioning(test.kind), test.want); diff != "" {
+			t.Errorf("timePartitioning(%s) of %s got diff (-got +want):\n%s", test.kind, test.c.Partitioning, diff)
+		}
+	}
+}
+
+func TestAddColumns(t *testing.T) {
+	got := bigquery.Schema{
+		{Name: "collector", Type: bigquery.StringFieldType},
+		{Name: "Prefix", Type: bigquery.StringFieldType},
+	}
+	want := bigquery.Schema{
+		{Name: "Collector", Type: bigquery.StringFieldType},
+		{Name: "Prefix", Type: bigquery.StringFieldType},
+		{Name: "LargeCommunities", Type: bigquery.RecordFieldType, Repeated: true, Schema: largeCommunitySchema},
+	}
+	s, ok := addColumns(got, want)
+	if !ok {
+		t.Fatalf("addColumns() got no columns added, want LargeCommunities")
+	}
+	if diff := cmp.Diff(s, append(got, want[2])); diff != "" {
+		t.Errorf("addColumns() got diff (-got +want):\n%s", diff)
+	}
+	if _, ok := addColumns(want, want); ok {
+		t.Errorf("addColumns() of the same schema got columns added")
+	}
+}
@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+)
+
+// mrtTypes are the MRT record types of RFC 6396, and its extensions, which
+// the archives may hold. The types deprecated by RFC 6396 are not expected.
+var mrtTypes = map[mrt.MRTType]bool{
+	mrt.OSPFv2:       true,
+	mrt.TABLE_DUMP:   true,
+	mrt.TABLE_DUMPv2: true,
+	mrt.BGP4MP:       true,
+	mrt.BGP4MP_ET:    true,
+	mrt.ISIS:         true,
+	mrt.ISIS_ET:      true,
+	mrt.OSPFv3:       true,
+	mrt.OSPFv3_ET:    true,
+}
+
+// FormatError is the error of content which is not a valid MRT archive, ie:
+// truncated, or not MRT at all.
+type FormatError struct {
+	// Offset is the offset of the bad record in the decompressed content.
+	Offset int64
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("invalid MRT at offset %d: %v", e.Offset, e.Err)
+}
+
+// sourceReader keeps the error of the reader of an archive, so that a failure
+// to read it is told apart from its decompression.
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// ValidateArchive decompresses an MRT archive by the extension of its name,
+// bz2 or gz, and validates its records, see Validate. It returns the number
+// of records, and a *FormatError if the archive is not valid, or the error of
+// the reader.
+func ValidateArchive(name string, r io.Reader) (int, error) {
+	src := &sourceReader{r: r}
+	var mr io.Reader = src
+	switch path.Ext(name) {
+	case ".bz2":
+		mr = bzip2.NewReader(src)
+	case ".gz":
+		gr, err := gzip.NewReader(src)
+		if err != nil {
+			if src.err != nil {
+				return 0, fmt.Errorf("failed to read %s: %v", name, src.err)
+			}
+			return 0, &FormatError{Err: fmt.Errorf("gzip.NewReader: %v", err)}
+		}
+		defer gr.Close()
+		mr = gr
+	}
+	n, err := Validate(mr)
+	if err != nil && src.err != nil {
+		return n, fmt.Errorf("failed to read %s: %v", name, src.err)
+	}
+	return n, err
+}
+
+// Validate reads the MRT records of decompressed content, and checks that each
+// has a header of a known type and its full body. The bodies are not parsed,
+// the converter skips the records it can not parse. It returns the number of
+// records, and a *FormatError if the content is empty, truncated or not MRT.
+func Validate(r io.Reader) (int, error) {
+	var off int64
+	buf := make([]byte, mrt.MRT_COMMON_HEADER_LEN)
+	for n := 0; ; n++ {
+		_, err := io.ReadFull(r, buf)
+		switch {
+		case err == io.EOF && n > 0:
+			return n, nil
+		case err == io.EOF:
+			return 0, &FormatError{Err: errors.New("no MRT records")}
+		case err != nil:
+			return n, &FormatError{Offset: off, Err: fmt.Errorf("failed to read MRT header: %v", err)}
+		}
+
+		h := &mrt.MRTHeader{}
+		if err := h.DecodeFromBytes(buf); err != nil {
+			return n, &FormatError{Offset: off, Err: fmt.Errorf("(*mrt.MRTHeader).DecodeFromBytes: %v", err)}
+		}
+		if !mrtTypes[h.Type] {
+			return n, &FormatError{Offset: off, Err: fmt.Errorf("unknown MRT type %d", h.Type)}
+		}
+		// The extended timestamp types carry the microseconds in the body.
+		if (h.Type == mrt.BGP4MP_ET || h.Type == mrt.ISIS_ET || h.Type == mrt.OSPFv3_ET) && h.Len < 4 {
+			return n, &FormatError{Offset: off, Err: fmt.Errorf("bad extended timestamp of length %d", h.Len)}
+		}
+		// The body is skipped rather than read, the length of a record which
+		// is not MRT is arbitrary.
+		if _, err := io.CopyN(io.Discard, r, int64(h.Len)); err != nil {
+			return n, &FormatError{Offset: off, Err: fmt.Errorf("failed to read MRT body of length %d: %v", h.Len, err)}
+		}
+		off += int64(mrt.MRT_COMMON_HEADER_LEN) + int64(h.Len)
+	}
+}
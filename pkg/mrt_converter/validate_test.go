@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/osrg/gobgp/pkg/packet/mrt"
+)
+
+// fakeRecord returns an MRT record of a type, with a body of length n.
+func fakeRecord(typ mrt.MRTType, n int) []byte {
+	b := make([]byte, mrt.MRT_COMMON_HEADER_LEN+n)
+	binary.BigEndian.PutUint32(b[0:4], 1639375940)
+	binary.BigEndian.PutUint16(b[4:6], uint16(typ))
+	binary.BigEndian.PutUint16(b[6:8], uint16(mrt.MESSAGE_AS4))
+	binary.BigEndian.PutUint32(b[8:12], uint32(n))
+	return b
+}
+
+func TestValidate(t *testing.T) {
+	valid := append(fakeRecord(mrt.BGP4MP, 20), fakeRecord(mrt.BGP4MP_ET, 24)...)
+	tests := []struct {
+		desc       string
+		content    []byte
+		want       int
+		wantOffset int64
+		wantErr    bool
+	}{{
+		desc:    "valid",
+		content: valid,
+		want:    2,
+	}, {
+		desc:    "table dump",
+		content: fakeRecord(mrt.TABLE_DUMPv2, 8),
+		want:    1,
+	}, {
+		desc:    "empty",
+		wantErr: true,
+	}, {
+		desc:       "truncated body",
+		content:    valid[:len(valid)-1],
+		wantOffset: 32,
+		wantErr:    true,
+	}, {
+		desc:       "truncated header",
+		content:    valid[:40],
+		wantOffset: 32,
+		wantErr:    true,
+	}, {
+		desc:    "not MRT",
+		content: []byte("<html><body>Not Found</body></html>"),
+		wantErr: true,
+	}, {
+		desc:    "bad extended timestamp",
+		content: fakeRecord(mrt.BGP4MP_ET, 2),
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		got, err := Validate(bytes.NewReader(test.content))
+		if test.wantErr {
+			var fe *FormatError
+			if !errors.As(err, &fe) {
+				t.Errorf("%s: Validate() got err %v, want a *FormatError", test.desc, err)
+				continue
+			}
+			if fe.Offset != test.wantOffset {
+				t.Errorf("%s: Validate() got offset %d, want %d", test.desc, fe.Offset, test.wantOffset)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Validate() got err: %v; want nil err", test.desc, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: Validate() = %d records, want %d", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestValidateArchive(t *testing.T) {
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(fakeRecord(mrt.BGP4MP, 20))
+	gw.Close()
+
+	tests := []struct {
+		desc       string
+		name       string
+		content    []byte
+		want       int
+		wantFormat bool
+		wantErr    bool
+	}{{
+		desc:    "gzip",
+		name:    "rrc00/2022.01/updates.20220109.1830.gz",
+		content: gz.Bytes(),
+		want:    1,
+	}, {
+		desc:    "uncompressed",
+		name:    "updates.20220109.1830",
+		content: fakeRecord(mrt.BGP4MP, 20),
+		want:    1,
+	}, {
+		desc:       "not gzip",
+		name:       "rrc00/2022.01/updates.20220109.1830.gz",
+		content:    fakeRecord(mrt.BGP4MP, 20),
+		wantFormat: true,
+		wantErr:    true,
+	}, {
+		desc:       "not bzip2",
+		name:       "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		content:    gz.Bytes(),
+		wantFormat: true,
+		wantErr:    true,
+	}, {
+		desc:    "read failure",
+		name:    "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		r := iotest.ErrReader(errors.New("unavailable"))
+		if test.content != nil {
+			r = bytes.NewReader(test.content)
+		}
+		got, err := ValidateArchive(test.name, r)
+		var fe *FormatError
+		if gotFormat := errors.As(err, &fe); gotFormat != test.wantFormat {
+			t.Errorf("%s: ValidateArchive() got err %v, want a *FormatError: %v", test.desc, err, test.wantFormat)
+		}
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: ValidateArchive() got err %v, want err: %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: ValidateArchive() = %d records, want %d", test.desc, got, test.want)
+		}
+	}
+}
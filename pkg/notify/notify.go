@@ -0,0 +1,85 @@
+// Package notify posts the summary of a sync run to a webhook, so operators
+// learn that a nightly mirror failed without scraping the logs.
+//
+// The summary is posted as JSON. Its "text" field is a one line summary,
+// which Slack incoming webhooks (and compatible chat services) display.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statuses of a run.
+const (
+	OK = "ok"
+	// Errors is a run which completed, but failed to process some files.
+	Errors = "errors"
+	// Aborted is a run which stopped before it completed.
+	Aborted = "aborted"
+)
+
+// Summary is the summary of a run.
+type Summary struct {
+	Tool     string         `json:"tool"`
+	Host     string         `json:"host"`
+	Archive  string         `json:"archive"`
+	Status   string         `json:"status"`
+	Reason   string         `json:"reason,omitempty"`
+	Started  time.Time      `json:"started"`
+	Duration string         `json:"duration"`
+	Metrics  map[string]int `json:"metrics"`
+}
+
+// Text is the one line summary of the run.
+func (s *Summary) Text() string {
+	var keys []string
+	for k := range s.Metrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var metrics []string
+	for _, k := range keys {
+		metrics = append(metrics, fmt.Sprintf("%s: %d", k, s.Metrics[k]))
+	}
+	text := fmt.Sprintf("%s on %s of %s: %s after %s (%s)",
+		s.Tool, s.Host, s.Archive, s.Status, s.Duration, strings.Join(metrics, ", "))
+	if s.Reason != "" {
+		text += ": " + s.Reason
+	}
+	return text
+}
+
+// Post posts the summary to the webhook url.
+func Post(ctx context.Context, url string, s *Summary) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+		*Summary
+	}{s.Text(), s})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid notify url: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post the run summary: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to post the run summary: %s: %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPost(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	s := &Summary{
+		Tool:     "mass_upload",
+		Host:     "mirror1",
+		Archive:  "ftp://archive.routeviews.org/",
+		Status:   Aborted,
+		Reason:   "12 of the last 20 operations failed, 6 times in a row",
+		Started:  time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC),
+		Duration: "1h0m0s",
+		Metrics:  map[string]int{"sync": 3, "error": 12},
+	}
+	if err := Post(context.Background(), srv.URL, s); err != nil {
+		t.Fatalf("Post() = %v; want nil err", err)
+	}
+	wantText := "mass_upload on mirror1 of ftp://archive.routeviews.org/: aborted after 1h0m0s (error: 12, sync: 3): 12 of the last 20 operations failed, 6 times in a row"
+	if got["text"] != wantText {
+		t.Errorf("text = %q; want %q", got["text"], wantText)
+	}
+	if got["status"] != Aborted {
+		t.Errorf("status = %v; want %s", got["status"], Aborted)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	if err := Post(context.Background(), failing.URL, s); err == nil {
+		t.Error("Post() to a failing webhook: nil err; want non-nil err")
+	}
+}
@@ -0,0 +1,320 @@
+// Package parquet writes Parquet files of flat records, for the consumers of
+// the converted archives which read them without BigQuery, ie: Spark or
+// DuckDB.
+//
+// Only what the converter needs is supported: required columns of booleans,
+// 64 bit integers, timestamps and UTF-8 strings, PLAIN encoded in one page per
+// column of each row group, compressed with gzip. The rows are buffered one row
+// group at a time, the footer is written on Close.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// magic starts and ends every Parquet file.
+const magic = "PAR1"
+
+// DefaultRowGroupSize is the rows of a row group, if the writer sets none.
+const DefaultRowGroupSize = 100000
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	// Bool columns hold bool values.
+	Bool Type = iota
+	// Int64 columns hold int64 values.
+	Int64
+	// String columns hold UTF-8 string values.
+	String
+	// Timestamp columns hold time.Time values, stored as the microseconds
+	// since the epoch, in UTC.
+	Timestamp
+)
+
+// The Parquet physical types, converted types, encodings, codecs and page
+// types of the file metadata.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageData = 0
+)
+
+// Column is a column of the schema of a file.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// physical returns the physical type of the column.
+func (c Column) physical() int32 {
+	switch c.Type {
+	case Bool:
+		return typeBoolean
+	case String:
+		return typeByteArray
+	}
+	return typeInt64
+}
+
+// chunk is the column chunk of a row group in the file, for the footer.
+type chunk struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+type rowGroup struct {
+	rows   int64
+	chunks []chunk
+}
+
+// Writer writes the rows of a schema as a Parquet file.
+type Writer struct {
+	w      io.Writer
+	cols   []Column
+	size   int
+	offset int64
+	// values are the PLAIN encoded values of each column of the row group,
+	// bools the booleans of each bool column, bit-packed on flush.
+	values [][]byte
+	bools  [][]bool
+	rows   int
+	groups []rowGroup
+	err    error
+}
+
+// NewWriter returns a Writer of a schema, which writes row groups of size rows,
+// DefaultRowGroupSize if it is not positive.
+func NewWriter(w io.Writer, cols []Column, size int) (*Writer, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("a schema needs at least one column")
+	}
+	if size <= 0 {
+		size = DefaultRowGroupSize
+	}
+	pw := &Writer{w: w, cols: cols, size: size, values: make([][]byte, len(cols)), bools: make([][]bool, len(cols))}
+	pw.write([]byte(magic))
+	return pw, pw.err
+}
+
+// write writes to the file, and keeps the offset of the file, or the error of
+// the first write which failed.
+func (w *Writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// Write writes a row, of a value of each column, in order. The row group is
+// written once it is full.
+func (w *Writer) Write(row ...interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.cols) {
+		return fmt.Errorf("row of %d values, want %d", len(row), len(w.cols))
+	}
+	for i, c := range w.cols {
+		if err := c.check(row[i]); err != nil {
+			return err
+		}
+	}
+	for i, c := range w.cols {
+		w.append(i, c, row[i])
+	}
+	w.rows++
+	if w.rows >= w.size {
+		w.flush()
+	}
+	return w.err
+}
+
+// check checks that a value is of the type of the column. The values of a row
+// are checked before the row is appended, a bad value fails the row only.
+func (c Column) check(v interface{}) error {
+	var ok bool
+	switch c.Type {
+	case Bool:
+		_, ok = v.(bool)
+	case Int64:
+		_, ok = v.(int64)
+	case Timestamp:
+		_, ok = v.(time.Time)
+	case String:
+		_, ok = v.(string)
+	}
+	if !ok {
+		return fmt.Errorf("column %s: bad %T value", c.Name, v)
+	}
+	return nil
+}
+
+// append appends the PLAIN encoding of a value to its column.
+func (w *Writer) append(i int, c Column, v interface{}) {
+	var b [8]byte
+	switch c.Type {
+	case Bool:
+		w.bools[i] = append(w.bools[i], v.(bool))
+	case Int64:
+		binary.LittleEndian.PutUint64(b[:], uint64(v.(int64)))
+		w.values[i] = append(w.values[i], b[:]...)
+	case Timestamp:
+		binary.LittleEndian.PutUint64(b[:], uint64(v.(time.Time).UnixNano()/int64(time.Microsecond)))
+		w.values[i] = append(w.values[i], b[:]...)
+	case String:
+		s := v.(string)
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+		w.values[i] = append(append(w.values[i], b[:4]...), s...)
+	}
+}
+
+// packBools bit-packs booleans, least significant bit first.
+func packBools(bs []bool) []byte {
+	b := make([]byte, (len(bs)+7)/8)
+	for i, v := range bs {
+		if v {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
+// flush writes the buffered rows as a row group, a gzip compressed data page
+// per column.
+func (w *Writer) flush() {
+	if w.rows == 0 || w.err != nil {
+		return
+	}
+	g := rowGroup{rows: int64(w.rows)}
+	for i, c := range w.cols {
+		data := w.values[i]
+		if c.Type == Bool {
+			data = packBools(w.bools[i])
+		}
+		var z bytes.Buffer
+		zw := gzip.NewWriter(&z)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			w.err = err
+			return
+		}
+		var h compact
+		h.begin()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(data)))
+		h.i32(3, int32(z.Len()))
+		h.structField(5)
+		h.i32(1, int32(w.rows))
+		h.i32(2, encodingPlain)
+		h.i32(3, encodingRLE)
+		h.i32(4, encodingRLE)
+		h.end()
+		h.end()
+		ch := chunk{
+			offset:       w.offset,
+			uncompressed: int64(h.buf.Len() + len(data)),
+			compressed:   int64(h.buf.Len() + z.Len()),
+		}
+		w.write(h.buf.Bytes())
+		w.write(z.Bytes())
+		g.chunks = append(g.chunks, ch)
+		w.values[i], w.bools[i] = w.values[i][:0], w.bools[i][:0]
+	}
+	w.groups = append(w.groups, g)
+	w.rows = 0
+}
+
+// Close writes the buffered rows, and the footer of the file. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	w.flush()
+	if w.err != nil {
+		return w.err
+	}
+	var rows int64
+	for _, g := range w.groups {
+		rows += g.rows
+	}
+	var m compact
+	m.begin()
+	m.i32(1, 1)
+	// The schema is a root of the columns.
+	m.list(2, ctStruct, len(w.cols)+1)
+	m.begin()
+	m.string(4, "schema")
+	m.i32(5, int32(len(w.cols)))
+	m.end()
+	for _, c := range w.cols {
+		m.begin()
+		m.i32(1, c.physical())
+		m.i32(3, repetitionRequired)
+		m.string(4, c.Name)
+		switch c.Type {
+		case String:
+			m.i32(6, convertedUTF8)
+		case Timestamp:
+			m.i32(6, convertedTimestampMicros)
+		}
+		m.end()
+	}
+	m.i64(3, rows)
+	m.list(4, ctStruct, len(w.groups))
+	for _, g := range w.groups {
+		m.begin()
+		m.list(1, ctStruct, len(g.chunks))
+		var total int64
+		for i, ch := range g.chunks {
+			total += ch.uncompressed
+			m.begin()
+			m.i64(2, ch.offset)
+			m.structField(3)
+			m.i32(1, w.cols[i].physical())
+			m.list(2, ctI32, 2)
+			m.zigzag(encodingPlain)
+			m.zigzag(encodingRLE)
+			m.list(3, ctBinary, 1)
+			m.binary(w.cols[i].Name)
+			m.i32(4, codecGzip)
+			m.i64(5, g.rows)
+			m.i64(6, ch.uncompressed)
+			m.i64(7, ch.compressed)
+			m.i64(9, ch.offset)
+			m.end()
+			m.end()
+		}
+		m.i64(2, total)
+		m.i64(3, g.rows)
+		m.end()
+	}
+	m.string(6, "routeviews google-cloud-storage")
+	m.end()
+
+	w.write(m.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(m.buf.Len()))
+	w.write(n[:])
+	w.write([]byte(magic))
+	return w.err
+}
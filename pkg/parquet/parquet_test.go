@@ -0,0 +1,209 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// decoder decodes the Thrift compact protocol, into a map of field id to
+// value of each struct: int64, string, []interface{} or map[int16]interface{}.
+type decoder struct {
+	b   []byte
+	err bool
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) == 0 {
+		d.err = true
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) varint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) zigzag() int64 {
+	v := d.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *decoder) value(typ byte) interface{} {
+	switch typ {
+	case ctI32, ctI64:
+		return d.zigzag()
+	case ctBinary:
+		n := int(d.varint())
+		if n > len(d.b) {
+			d.err = true
+			return ""
+		}
+		s := string(d.b[:n])
+		d.b = d.b[n:]
+		return s
+	case ctList:
+		h := d.byte()
+		n, et := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(d.varint())
+		}
+		var l []interface{}
+		for i := 0; i < n && !d.err; i++ {
+			l = append(l, d.value(et))
+		}
+		return l
+	case ctStruct:
+		return d.structure()
+	}
+	d.err = true
+	return nil
+}
+
+func (d *decoder) structure() map[int16]interface{} {
+	m := map[int16]interface{}{}
+	var last int16
+	for !d.err {
+		h := d.byte()
+		if h == 0 {
+			break
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(d.zigzag())
+		}
+		m[id] = d.value(h & 0x0f)
+		last = id
+	}
+	return m
+}
+
+func TestCompact(t *testing.T) {
+	var c compact
+	c.begin()
+	c.i32(1, -1)
+	c.i64(3, 300)
+	c.string(20, "ab")
+	c.list(21, ctI32, 2)
+	c.zigzag(0)
+	c.zigzag(3)
+	c.end()
+	want := []byte{
+		0x15, 0x01, // field 1, i32, zigzag -1
+		0x26, 0xd8, 0x04, // field 3 (delta 2), i64, zigzag 300
+		0x08, 0x28, 0x02, 'a', 'b', // field 20 (long form), binary
+		0x19, 0x25, 0x00, 0x06, // field 21 (delta 1), list of 2 i32
+		0x00, // stop
+	}
+	if diff := cmp.Diff(c.buf.Bytes(), want); diff != "" {
+		t.Errorf("compact got diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	cols := []Column{
+		{Name: "collector", Type: String},
+		{Name: "seen_at", Type: Timestamp},
+		{Name: "peer_as", Type: Int64},
+		{Name: "withdrawn", Type: Bool},
+	}
+	seen := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, cols, 2)
+	if err != nil {
+		t.Fatalf("NewWriter() got err: %v", err)
+	}
+	rows := [][]interface{}{
+		{"route-views2", seen, int64(6447), false},
+		{"route-views3", seen, int64(100000), true},
+		{"rrc00", seen, int64(15169), true},
+	}
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			t.Fatalf("Write(%v) got err: %v", row, err)
+		}
+	}
+	if err := w.Write("route-views2", seen, 6447, false); err == nil {
+		t.Errorf("Write() of an int value of an int64 column got nil err, want err")
+	}
+	if err := w.Write("route-views2"); err == nil {
+		t.Errorf("Write() of a short row got nil err, want err")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() got err: %v", err)
+	}
+
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(magic)) || !bytes.HasSuffix(b, []byte(magic)) {
+		t.Fatalf("file does not start and end with %q", magic)
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	d := &decoder{b: b[len(b)-8-n : len(b)-8]}
+	meta := d.structure()
+	if d.err || len(d.b) != 0 {
+		t.Fatalf("footer of %d bytes does not decode", n)
+	}
+	if got := meta[3]; got != int64(3) {
+		t.Errorf("footer got num_rows %v, want 3", got)
+	}
+	var names []string
+	for _, e := range meta[2].([]interface{}) {
+		names = append(names, e.(map[int16]interface{})[4].(string))
+	}
+	if diff := cmp.Diff(names, []string{"schema", "collector", "seen_at", "peer_as", "withdrawn"}); diff != "" {
+		t.Errorf("footer got schema diff (-got +want):\n%s", diff)
+	}
+
+	// The values of each column, of both row groups.
+	got := map[string][]byte{}
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("footer got %d row groups, want 2", len(groups))
+	}
+	for _, g := range groups {
+		for i, ch := range g.(map[int16]interface{})[1].([]interface{}) {
+			md := ch.(map[int16]interface{})[3].(map[int16]interface{})
+			off, size := md[9].(int64), md[7].(int64)
+			pd := &decoder{b: b[off : off+size]}
+			ph := pd.structure()
+			if pd.err || int64(len(pd.b)) != ph[3].(int64) {
+				t.Fatalf("column %s: bad page header %v", cols[i].Name, ph)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(pd.b))
+			if err != nil {
+				t.Fatalf("column %s: gzip.NewReader() got err: %v", cols[i].Name, err)
+			}
+			data, err := ioutil.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("column %s: page got err: %v", cols[i].Name, err)
+			}
+			got[cols[i].Name] = append(got[cols[i].Name], data...)
+		}
+	}
+	micros := make([]byte, 8)
+	binary.LittleEndian.PutUint64(micros, uint64(seen.UnixNano()/1000))
+	want := map[string][]byte{
+		"collector": append(append(append([]byte{12, 0, 0, 0}, "route-views2"...), append([]byte{12, 0, 0, 0}, "route-views3"...)...), append([]byte{5, 0, 0, 0}, "rrc00"...)...),
+		"seen_at":   append(append(append([]byte{}, micros...), micros...), micros...),
+		"peer_as":   {0x2f, 0x19, 0, 0, 0, 0, 0, 0, 0xa0, 0x86, 0x01, 0, 0, 0, 0, 0, 0x41, 0x3b, 0, 0, 0, 0, 0, 0},
+		// Bit-packed per row group: false, true; then true.
+		"withdrawn": {0x02, 0x01},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("pages got values diff (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,91 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// The types of the fields of the Thrift compact protocol.
+const (
+	ctI32    = 5
+	ctI64    = 6
+	ctBinary = 8
+	ctList   = 9
+	ctStruct = 12
+)
+
+// compact encodes the Thrift structs of the Parquet metadata with the Thrift
+// compact protocol. Structs are written field by field, in increasing order of
+// field id, and closed with end.
+type compact struct {
+	buf bytes.Buffer
+	// last is the id of the last field written of each open struct.
+	last []int16
+}
+
+func (c *compact) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	c.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (c *compact) zigzag(v int64) {
+	c.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+// field writes the header of a field of the current struct.
+func (c *compact) field(id int16, typ byte) {
+	last := &c.last[len(c.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		c.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		c.buf.WriteByte(typ)
+		c.zigzag(int64(id))
+	}
+	*last = id
+}
+
+// begin opens a struct, the top-level struct or the value of a field or list.
+func (c *compact) begin() { c.last = append(c.last, 0) }
+
+// end closes the current struct.
+func (c *compact) end() {
+	c.buf.WriteByte(0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, ctI32)
+	c.zigzag(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, ctI64)
+	c.zigzag(v)
+}
+
+func (c *compact) binary(v string) {
+	c.varint(uint64(len(v)))
+	c.buf.WriteString(v)
+}
+
+func (c *compact) string(id int16, v string) {
+	c.field(id, ctBinary)
+	c.binary(v)
+}
+
+// list writes the header of a list field of n elements of a type.
+func (c *compact) list(id int16, typ byte, n int) {
+	c.field(id, ctList)
+	if n < 15 {
+		c.buf.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	c.buf.WriteByte(0xf0 | typ)
+	c.varint(uint64(n))
+}
+
+// structField opens a struct as the value of a field.
+func (c *compact) structField(id int16) {
+	c.field(id, ctStruct)
+	c.begin()
+}
@@ -0,0 +1,72 @@
+// Package pathrewrite maps archive source paths to cloud storage object
+// names with an ordered set of regular expression rewrite rules, e.g. to
+// prefix the collector name or strip a leading /bgpdata.
+package pathrewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ruleSep separates the pattern from the replacement in a rule definition.
+const ruleSep = "=>"
+
+// Rule replaces all matches of Pattern with Replacement, in which $1 (or
+// ${name}) is expanded to the submatch, as in regexp.ReplaceAllString.
+type Rule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+func (r *Rule) String() string {
+	return r.Pattern.String() + ruleSep + r.Replacement
+}
+
+// ParseRule parses a rule definition: PATTERN=>REPLACEMENT.
+func ParseRule(s string) (*Rule, error) {
+	i := strings.Index(s, ruleSep)
+	if i < 0 {
+		return nil, fmt.Errorf("rewrite rule %q is not PATTERN%sREPLACEMENT", s, ruleSep)
+	}
+	re, err := regexp.Compile(s[:i])
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern in rewrite rule %q: %v", s, err)
+	}
+	return &Rule{Pattern: re, Replacement: s[i+len(ruleSep):]}, nil
+}
+
+// Rules is an ordered set of rewrite rules. It implements flag.Value, every
+// occurrence of the flag appends a rule.
+type Rules []*Rule
+
+func (rs *Rules) String() string {
+	if rs == nil {
+		return ""
+	}
+	var defs []string
+	for _, r := range *rs {
+		defs = append(defs, r.String())
+	}
+	return strings.Join(defs, ",")
+}
+
+// Set parses and appends a rule.
+func (rs *Rules) Set(s string) error {
+	r, err := ParseRule(s)
+	if err != nil {
+		return err
+	}
+	*rs = append(*rs, r)
+	return nil
+}
+
+// Apply applies every rule to the path in order, each rule is applied to the
+// result of the previous one. The path is returned unchanged if no rule
+// matches.
+func (rs Rules) Apply(path string) string {
+	for _, r := range rs {
+		path = r.Pattern.ReplaceAllString(path, r.Replacement)
+	}
+	return path
+}
@@ -0,0 +1,66 @@
+package pathrewrite
+
+import (
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		desc  string
+		rules []string
+		path  string
+		want  string
+	}{
+		{
+			desc: "no rules",
+			path: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc:  "prefix collector",
+			rules: []string{`^bgpdata/=>route-views2/bgpdata/`},
+			path:  "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:  "route-views2/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc:  "strip bgpdata with submatch",
+			rules: []string{`^([^/]+)/bgpdata/=>$1/`},
+			path:  "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:  "route-views4/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc:  "rules apply in order",
+			rules: []string{`^bgpdata/=>route-views2/bgpdata/`, `/bgpdata/=>/`},
+			path:  "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:  "route-views2/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+		{
+			desc:  "no match",
+			rules: []string{`^ris/=>ripe/`},
+			path:  "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want:  "bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var rs Rules
+			for _, r := range test.rules {
+				if err := rs.Set(r); err != nil {
+					t.Fatalf("Set(%q) = %v; want nil err", r, err)
+				}
+			}
+			if got := rs.Apply(test.path); got != test.want {
+				t.Errorf("Apply(%s) = %s; want %s", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, s := range []string{"bgpdata", "([=>x"} {
+		if _, err := ParseRule(s); err == nil {
+			t.Errorf("ParseRule(%q): nil err; want non-nil err", s)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Package progress tracks the progress of a long running sync: the files
+// discovered and processed, the throughput, and the estimated time until the
+// sync completes.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tracker tracks the progress of a sync, it is safe for concurrent use.
+type Tracker struct {
+	mu         sync.Mutex
+	start      time.Time
+	discovered int64
+	processed  int64
+	bytes      int64
+	// walkDone is set once every file is discovered.
+	walkDone bool
+	// dir is the directory being walked.
+	dir string
+	// lastActivity is the time of the last directory walked, or file
+	// discovered or processed.
+	lastActivity time.Time
+	// now is replaced by tests.
+	now func() time.Time
+}
+
+// New returns a tracker of a sync starting now.
+func New() *Tracker {
+	now := time.Now()
+	return &Tracker{start: now, lastActivity: now, now: time.Now}
+}
+
+// Walking records the directory being walked.
+func (t *Tracker) Walking(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dir = dir
+	t.lastActivity = t.now()
+}
+
+// Discovered records a file found for processing.
+func (t *Tracker) Discovered() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.discovered++
+	t.lastActivity = t.now()
+}
+
+// WalkDone records that every file is discovered.
+func (t *Tracker) WalkDone() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.walkDone = true
+}
+
+// Processed records a processed file and the bytes transferred for it.
+func (t *Tracker) Processed(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.processed++
+	t.bytes += bytes
+	t.lastActivity = t.now()
+}
+
+// Report is a snapshot of the progress.
+type Report struct {
+	Discovered int64
+	Processed  int64
+	Bytes      int64
+	Elapsed    time.Duration
+	// Final is set if every file is discovered, the percentage and ETA are
+	// lower bounds until then.
+	Final       bool
+	Percent     float64
+	BytesPerSec float64
+	FilesPerSec float64
+	// ETA is the estimated time to process the remaining discovered files,
+	// zero if unknown.
+	ETA time.Duration
+	// Dir is the directory being walked.
+	Dir string
+	// LastActivity is the time of the last directory walked, or file
+	// discovered or processed.
+	LastActivity time.Time
+}
+
+// Report returns a snapshot of the progress.
+func (t *Tracker) Report() *Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := &Report{
+		Discovered:   t.discovered,
+		Processed:    t.processed,
+		Bytes:        t.bytes,
+		Elapsed:      t.now().Sub(t.start),
+		Final:        t.walkDone,
+		Dir:          t.dir,
+		LastActivity: t.lastActivity,
+	}
+	if r.Discovered > 0 {
+		r.Percent = 100 * float64(r.Processed) / float64(r.Discovered)
+	}
+	if secs := r.Elapsed.Seconds(); secs > 0 {
+		r.BytesPerSec = float64(r.Bytes) / secs
+		r.FilesPerSec = float64(r.Processed) / secs
+	}
+	if r.FilesPerSec > 0 {
+		remaining := float64(r.Discovered - r.Processed)
+		r.ETA = time.Duration(remaining / r.FilesPerSec * float64(time.Second)).Round(time.Second)
+	}
+	return r
+}
+
+func (r *Report) String() string {
+	eta := "unknown"
+	if r.ETA > 0 || r.Processed == r.Discovered {
+		eta = r.ETA.String()
+	}
+	qualifier := ""
+	if !r.Final {
+		qualifier = " (still discovering files)"
+	}
+	return fmt.Sprintf("%d/%d files (%.1f%%)%s, %d bytes, %.0f bytes/s, %.2f files/s, elapsed %v, eta %s",
+		r.Processed, r.Discovered, r.Percent, qualifier, r.Bytes, r.BytesPerSec, r.FilesPerSec,
+		r.Elapsed.Round(time.Second), eta)
+}
+
+// Every calls f with a progress report every interval until ctx is done.
+func (t *Tracker) Every(ctx context.Context, interval time.Duration, f func(*Report)) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			f(t.Report())
+		}
+	}
+}
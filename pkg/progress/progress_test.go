@@ -0,0 +1,48 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReport(t *testing.T) {
+	start := time.Date(2022, 1, 9, 18, 30, 0, 0, time.UTC)
+	now := start
+	tr := &Tracker{start: start, lastActivity: start, now: func() time.Time { return now }}
+
+	if got := tr.Report(); got.ETA != 0 || got.Percent != 0 {
+		t.Errorf("empty Report() = %v; want no ETA and 0%%", got)
+	}
+
+	tr.Walking("bgpdata/2022.01/UPDATES")
+	for i := 0; i < 10; i++ {
+		tr.Discovered()
+	}
+	now = start.Add(3 * time.Second)
+	tr.Processed(1000)
+	tr.Processed(3000)
+	now = start.Add(4 * time.Second)
+
+	want := &Report{
+		Discovered:   10,
+		Processed:    2,
+		Bytes:        4000,
+		Elapsed:      4 * time.Second,
+		Percent:      20,
+		BytesPerSec:  1000,
+		FilesPerSec:  0.5,
+		ETA:          16 * time.Second,
+		Dir:          "bgpdata/2022.01/UPDATES",
+		LastActivity: start.Add(3 * time.Second),
+	}
+	if diff := cmp.Diff(want, tr.Report()); diff != "" {
+		t.Errorf("Report() mismatch (-want, +got):\n%s", diff)
+	}
+
+	tr.WalkDone()
+	if !tr.Report().Final {
+		t.Error("Report() is not final after WalkDone()")
+	}
+}
@@ -0,0 +1,80 @@
+// Package resourceusage reports the CPU, memory, goroutine and GC usage of
+// the running process, so that operators can right-size the VMs running the
+// archive tools and compare usage across versions to spot leaks.
+package resourceusage
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// Usage is a snapshot of the resources used by the process since it started.
+type Usage struct {
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	// MaxRSS is the peak resident set size in bytes.
+	MaxRSS     int64
+	Goroutines int
+	// HeapAlloc is the number of bytes of allocated heap objects.
+	HeapAlloc    uint64
+	NumGC        uint32
+	GCPauseTotal time.Duration
+}
+
+// Snapshot returns the current resource usage of the process.
+func Snapshot() (*Usage, error) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return nil, fmt.Errorf("syscall.Getrusage: %v", err)
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return &Usage{
+		UserCPU:   time.Duration(ru.Utime.Nano()),
+		SystemCPU: time.Duration(ru.Stime.Nano()),
+		// Linux reports the peak RSS in kilobytes.
+		MaxRSS:       int64(ru.Maxrss) * 1024,
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    ms.HeapAlloc,
+		NumGC:        ms.NumGC,
+		GCPauseTotal: time.Duration(ms.PauseTotalNs),
+	}, nil
+}
+
+func (u *Usage) String() string {
+	return fmt.Sprintf("cpu user %v, cpu system %v, peak rss %d bytes, goroutines %d, heap %d bytes, gc runs %d, gc pause %v",
+		u.UserCPU, u.SystemCPU, u.MaxRSS, u.Goroutines, u.HeapAlloc, u.NumGC, u.GCPauseTotal)
+}
+
+// Fields returns the usage as structured logging fields.
+func (u *Usage) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"cpuUserSec":   u.UserCPU.Seconds(),
+		"cpuSystemSec": u.SystemCPU.Seconds(),
+		"maxRSSBytes":  u.MaxRSS,
+		"goroutines":   u.Goroutines,
+		"heapBytes":    u.HeapAlloc,
+		"numGC":        u.NumGC,
+		"gcPauseSec":   u.GCPauseTotal.Seconds(),
+	}
+}
+
+// Report calls f with a usage snapshot every interval until ctx is done.
+// Snapshot errors are skipped.
+func Report(ctx context.Context, interval time.Duration, f func(*Usage)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if u, err := Snapshot(); err == nil {
+				f(u)
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package resourceusage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	u, err := Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() = %v; want nil err", err)
+	}
+	if u.MaxRSS <= 0 {
+		t.Errorf("got MaxRSS %d; want > 0", u.MaxRSS)
+	}
+	if u.Goroutines < 1 {
+		t.Errorf("got %d goroutines; want >= 1", u.Goroutines)
+	}
+	if len(u.Fields()) != 7 {
+		t.Errorf("got %d fields; want 7", len(u.Fields()))
+	}
+}
+
+func TestReport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan *Usage)
+	done := make(chan struct{})
+	go func() {
+		Report(ctx, time.Millisecond, func(u *Usage) { reports <- u })
+		close(done)
+	}()
+
+	if u := <-reports; u == nil {
+		t.Error("got nil usage report")
+	}
+	cancel()
+	// Drain the reports which raced with the cancellation until Report returns.
+	for {
+		select {
+		case <-reports:
+		case <-done:
+			return
+		}
+	}
+}
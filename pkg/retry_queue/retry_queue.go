@@ -0,0 +1,192 @@
+// Package retryqueue persists the files a sync run failed to process, so the
+// next run processes them first and no failure is silently dropped.
+//
+// The queue is a JSONL file, one entry per line, stored locally or as a GCS
+// object (gs://bucket/object).
+package retryqueue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Entry is a file which failed to process.
+type Entry struct {
+	Name string `json:"name"`
+	// Attempts counts the runs which failed to process the file.
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// Queue is the retry queue of a run, it is safe for concurrent use.
+type Queue struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	// queued are the names loaded from the previous runs.
+	queued map[string]bool
+}
+
+// New returns an empty queue.
+func New() *Queue {
+	return &Queue{entries: map[string]*Entry{}, queued: map[string]bool{}}
+}
+
+// Fail records a failure to process the file name.
+func (q *Queue) Fail(name string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.entries[name]
+	if !ok {
+		e = &Entry{Name: name}
+		q.entries[name] = e
+	}
+	e.Attempts++
+	e.LastError = err.Error()
+	e.LastFailure = time.Now().UTC()
+}
+
+// Done removes the file name, it was processed.
+func (q *Queue) Done(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.entries, name)
+}
+
+// Queued reports whether the file name was loaded from the previous runs.
+func (q *Queue) Queued(name string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queued[name]
+}
+
+// Entries returns the entries sorted by name.
+func (q *Queue) Entries() []*Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var res []*Entry
+	for _, e := range q.entries {
+		c := *e
+		res = append(res, &c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Read reads a queue of JSONL entries.
+func Read(r io.Reader) (*Queue, error) {
+	q := New()
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(s.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("invalid retry queue entry %q: %v", s.Text(), err)
+		}
+		q.entries[e.Name] = e
+		q.queued[e.Name] = true
+	}
+	return q, s.Err()
+}
+
+// Write writes the queue as JSONL entries.
+func (q *Queue) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, e := range q.Entries() {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitGCS splits a gs://bucket/object location, ok is false for a local path.
+func splitGCS(loc string) (bucket, object string, ok bool) {
+	if !strings.HasPrefix(loc, "gs://") {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(loc, "gs://"), "/", 2)
+	if len(parts) != 2 {
+		return parts[0], "", true
+	}
+	return parts[0], parts[1], true
+}
+
+// Load loads the queue at loc, a local path or gs://bucket/object. A missing
+// queue is empty.
+func Load(ctx context.Context, gcs *storage.Client, loc string) (*Queue, error) {
+	var b []byte
+	var err error
+	if bucket, object, ok := splitGCS(loc); ok {
+		if object == "" {
+			return nil, fmt.Errorf("invalid retry queue %q, want gs://bucket/object", loc)
+		}
+		var r *storage.Reader
+		r, err = gcs.Bucket(bucket).Object(object).NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return New(), nil
+		}
+		if err == nil {
+			defer r.Close()
+			b, err = ioutil.ReadAll(r)
+		}
+	} else {
+		b, err = ioutil.ReadFile(loc)
+		if os.IsNotExist(err) {
+			return New(), nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the retry queue(%s): %v", loc, err)
+	}
+	return Read(bytes.NewReader(b))
+}
+
+// Save saves the queue to loc, an empty queue removes it.
+func (q *Queue) Save(ctx context.Context, gcs *storage.Client, loc string) error {
+	var buf bytes.Buffer
+	if err := q.Write(&buf); err != nil {
+		return err
+	}
+	bucket, object, isGCS := splitGCS(loc)
+	switch {
+	case isGCS && buf.Len() == 0:
+		err := gcs.Bucket(bucket).Object(object).Delete(ctx)
+		if err != nil && err != storage.ErrObjectNotExist {
+			return fmt.Errorf("failed to remove the retry queue(%s): %v", loc, err)
+		}
+	case isGCS:
+		w := gcs.Bucket(bucket).Object(object).NewWriter(ctx)
+		w.ContentType = "application/x-ndjson"
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to write the retry queue(%s): %v", loc, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to write the retry queue(%s): %v", loc, err)
+		}
+	case buf.Len() == 0:
+		if err := os.Remove(loc); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove the retry queue(%s): %v", loc, err)
+		}
+	default:
+		if err := ioutil.WriteFile(loc, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write the retry queue(%s): %v", loc, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package retryqueue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	loc := filepath.Join(t.TempDir(), "retry.jsonl")
+
+	// A missing queue is empty.
+	q, err := Load(ctx, nil, loc)
+	if err != nil || len(q.Entries()) != 0 {
+		t.Fatalf("Load(missing) = %v, %v; want an empty queue", q.Entries(), err)
+	}
+
+	q.Fail("bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2", errors.New("550 transfer failed"))
+	q.Fail("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", errors.New("550 transfer failed"))
+	if err := q.Save(ctx, nil, loc); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+
+	// The next run loads the queue, the failures are counted across runs.
+	q, err = Load(ctx, nil, loc)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if !q.Queued("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2") {
+		t.Error("Queued() = false for a failed file; want true")
+	}
+	q.Fail("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2", errors.New("connection reset"))
+	q.Done("bgpdata/2022.01/UPDATES/updates.20220109.1845.bz2")
+	got := q.Entries()
+	if len(got) != 1 || got[0].Attempts != 2 || got[0].LastError != "connection reset" {
+		t.Errorf("Entries() = %+v; want one entry with 2 attempts", got)
+	}
+
+	// An empty queue removes the file.
+	q.Done("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2")
+	if err := q.Save(ctx, nil, loc); err != nil {
+		t.Fatalf("Save(empty) = %v", err)
+	}
+	if q, err := Load(ctx, nil, loc); err != nil || q.Queued("bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2") {
+		t.Errorf("Load(removed) = %v; want an empty queue", err)
+	}
+}
+
+func TestSplitGCS(t *testing.T) {
+	bucket, object, ok := splitGCS("gs://routeviews-archives/_retry/route-views2.jsonl")
+	if !ok || bucket != "routeviews-archives" || object != "_retry/route-views2.jsonl" {
+		t.Errorf("splitGCS() = %q, %q, %v", bucket, object, ok)
+	}
+	if _, _, ok := splitGCS("/var/lib/mass_upload/retry.jsonl"); ok {
+		t.Error("splitGCS(local path) = true; want false")
+	}
+}
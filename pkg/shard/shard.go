@@ -0,0 +1,52 @@
+// Package shard partitions the archives of a site across the instances of a
+// sync, so that instances running in parallel do not duplicate work.
+//
+// Archives are partitioned by collector and month, so each instance walks the
+// whole site but only transfers the months it owns. The partition depends
+// only on the key and the shard count, it is stable across runs and hosts.
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Shard is one of Total partitions, numbered from 0.
+type Shard struct {
+	N     int
+	Total int
+}
+
+// New returns shard n of total. A total of 0 or 1 is a single shard owning
+// every key.
+func New(n, total int) (*Shard, error) {
+	if total <= 1 {
+		if n != 0 {
+			return nil, fmt.Errorf("shard %d is out of range, there is a single shard", n)
+		}
+		return &Shard{N: 0, Total: 1}, nil
+	}
+	if n < 0 || n >= total {
+		return nil, fmt.Errorf("shard %d is out of range [0, %d)", n, total)
+	}
+	return &Shard{N: n, Total: total}, nil
+}
+
+// Of returns the shard number of a key.
+func Of(key string, total int) int {
+	if total <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(total))
+}
+
+// Owns reports whether the shard owns a key.
+func (s *Shard) Owns(key string) bool {
+	return Of(key, s.Total) == s.N
+}
+
+func (s *Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.N, s.Total)
+}
@@ -0,0 +1,61 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		n, total int
+		wantErr  bool
+	}{
+		{n: 0, total: 0},
+		{n: 0, total: 1},
+		{n: 9, total: 10},
+		{n: 10, total: 10, wantErr: true},
+		{n: -1, total: 10, wantErr: true},
+		{n: 1, total: 0, wantErr: true},
+	}
+	for _, test := range tests {
+		_, err := New(test.n, test.total)
+		if (err != nil) != test.wantErr {
+			t.Errorf("New(%d, %d) = %v; want err %v", test.n, test.total, err, test.wantErr)
+		}
+	}
+}
+
+func TestOwns(t *testing.T) {
+	const total = 10
+	var shards []*Shard
+	for n := 0; n < total; n++ {
+		s, err := New(n, total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards = append(shards, s)
+	}
+
+	// Every key is owned by exactly one shard, and the shards share the work.
+	counts := make([]int, total)
+	for year := 2001; year <= 2022; year++ {
+		for month := 1; month <= 12; month++ {
+			key := fmt.Sprintf("route-views2/%d.%02d", year, month)
+			owners := 0
+			for _, s := range shards {
+				if s.Owns(key) {
+					owners++
+					counts[s.N]++
+				}
+			}
+			if owners != 1 {
+				t.Errorf("%s is owned by %d shards; want 1", key, owners)
+			}
+		}
+	}
+	for n, c := range counts {
+		if c == 0 {
+			t.Errorf("shard %d owns no key", n)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package stats aggregates contribution statistics of the archives: files and
+// bytes per collector per day, monthly growth, and how much of the update
+// archives has been converted for BigQuery.
+//
+// The statistics are derived from listings of the archive bucket and the
+// converted (BigQuery) bucket, no object content is read.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// archiveDate matches the date in archive filenames, such as
+// updates.20220109.1830.bz2 or rib.20220109.0000.bz2.
+var archiveDate = regexp.MustCompile(`\.(\d{8})\.\d{4}\.`)
+
+// CollectorDay is the archive contribution of one collector on one day.
+type CollectorDay struct {
+	Collector string `json:"collector"`
+	// Day is formatted as YYYY-MM-DD.
+	Day   string `json:"day"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+	// Updates is the number of update archives, the only archives converted.
+	Updates   int64 `json:"updates"`
+	Converted int64 `json:"converted"`
+}
+
+// Month is the archive contribution of all collectors in one month.
+type Month struct {
+	// Month is formatted as YYYY-MM.
+	Month string `json:"month"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+	// BytesGrowth is the growth in bytes relative to the previous month, e.g.
+	// 0.1 for 10%. It is 0 for the first month.
+	BytesGrowth float64 `json:"bytesGrowth"`
+}
+
+// Report holds the statistics of the archives.
+type Report struct {
+	Generated time.Time       `json:"generated"`
+	Days      []*CollectorDay `json:"days"`
+	Months    []*Month        `json:"months"`
+	// Coverage is the fraction of update archives which are converted.
+	Coverage float64 `json:"coverage"`
+}
+
+// collectorFromPath returns the collector of an archive path, the archives of
+// route-views2 are stored at the root of the bucket. Prefixes starting with an
+// underscore, such as the _history/ generations preserved by mass_upload, hold
+// no collector.
+func collectorFromPath(name string) string {
+	dirs := strings.Split(strings.TrimLeft(name, "/"), "/")
+	if len(dirs) < 2 || strings.HasPrefix(dirs[0], "_") {
+		return ""
+	}
+	if dirs[0] == "bgpdata" {
+		return "route-views2"
+	}
+	return dirs[0]
+}
+
+// dayFromPath returns the YYYY-MM-DD date of an archive path.
+func dayFromPath(name string) (string, error) {
+	m := archiveDate.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return "", fmt.Errorf("no date in archive name %s", name)
+	}
+	t, err := time.Parse("20060102", m[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid date in archive name %s: %v", name, err)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+// convertedName is the name the converter writes a converted archive to.
+func convertedName(name string) string {
+	return strings.Replace(name, filepath.Ext(name), ".gz", 1)
+}
+
+func listObjects(ctx context.Context, bh *storage.BucketHandle, f func(*storage.ObjectAttrs)) error {
+	it := bh.Objects(ctx, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f(attrs)
+	}
+}
+
+// Collect lists the archive and converted buckets and aggregates the
+// statistics. Objects without a collector or date in their path are skipped.
+func Collect(ctx context.Context, gcsCli *storage.Client, archiveBucket, convertedBucket string) (*Report, error) {
+	converted := map[string]bool{}
+	if convertedBucket != "" {
+		err := listObjects(ctx, gcsCli.Bucket(convertedBucket), func(attrs *storage.ObjectAttrs) {
+			converted[attrs.Name] = true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gs://%s: %v", convertedBucket, err)
+		}
+	}
+
+	days := map[string]*CollectorDay{}
+	err := listObjects(ctx, gcsCli.Bucket(archiveBucket), func(attrs *storage.ObjectAttrs) {
+		collector := collectorFromPath(attrs.Name)
+		day, err := dayFromPath(attrs.Name)
+		if collector == "" || err != nil {
+			return
+		}
+		k := collector + "/" + day
+		cd, ok := days[k]
+		if !ok {
+			cd = &CollectorDay{Collector: collector, Day: day}
+			days[k] = cd
+		}
+		cd.Files++
+		cd.Bytes += attrs.Size
+		if strings.Contains(attrs.Name, "UPDATES/") {
+			cd.Updates++
+			if converted[convertedName(attrs.Name)] {
+				cd.Converted++
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gs://%s: %v", archiveBucket, err)
+	}
+	return newReport(days), nil
+}
+
+func newReport(days map[string]*CollectorDay) *Report {
+	r := &Report{Generated: time.Now().UTC()}
+	months := map[string]*Month{}
+	var updates, converted int64
+	for _, cd := range days {
+		r.Days = append(r.Days, cd)
+		updates += cd.Updates
+		converted += cd.Converted
+
+		k := cd.Day[:len("2006-01")]
+		m, ok := months[k]
+		if !ok {
+			m = &Month{Month: k}
+			months[k] = m
+			r.Months = append(r.Months, m)
+		}
+		m.Files += cd.Files
+		m.Bytes += cd.Bytes
+	}
+	sort.Slice(r.Days, func(i, j int) bool {
+		if r.Days[i].Day != r.Days[j].Day {
+			return r.Days[i].Day < r.Days[j].Day
+		}
+		return r.Days[i].Collector < r.Days[j].Collector
+	})
+	sort.Slice(r.Months, func(i, j int) bool { return r.Months[i].Month < r.Months[j].Month })
+	for i := 1; i < len(r.Months); i++ {
+		if prev := r.Months[i-1].Bytes; prev > 0 {
+			r.Months[i].BytesGrowth = float64(r.Months[i].Bytes-prev) / float64(prev)
+		}
+	}
+	if updates > 0 {
+		r.Coverage = float64(converted) / float64(updates)
+	}
+	return r
+}
+
+// Filter returns the days of a collector between from and to, inclusive. An
+// empty collector, from or to is not filtered on.
+func (r *Report) Filter(collector, from, to string) []*CollectorDay {
+	var res []*CollectorDay
+	for _, cd := range r.Days {
+		if (collector != "" && cd.Collector != collector) ||
+			(from != "" && cd.Day < from) ||
+			(to != "" && cd.Day > to) {
+			continue
+		}
+		res = append(res, cd)
+	}
+	return res
+}
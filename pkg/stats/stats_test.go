@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestDayFromPath(t *testing.T) {
+	tests := []struct {
+		desc    string
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{
+			desc: "updates",
+			name: "route-views4/bgpdata/2022.01/UPDATES/updates.20220109.1830.bz2",
+			want: "2022-01-09",
+		},
+		{
+			desc: "ribs",
+			name: "bgpdata/2022.01/RIBS/rib.20220131.0000.bz2",
+			want: "2022-01-31",
+		},
+		{
+			desc:    "no date",
+			name:    "route-views4/bgpdata/README",
+			wantErr: true,
+		},
+		{
+			desc:    "invalid date",
+			name:    "route-views4/bgpdata/2022.01/UPDATES/updates.20221309.1830.bz2",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := dayFromPath(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("dayFromPath(%s) = %v; want err %v", test.name, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("dayFromPath(%s) = %s; want %s", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	fakegcs := fakestorage.NewServer([]fakestorage.Object{
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "bgpdata/2022.01/UPDATES/updates.20220131.0000.bz2"},
+			Content:     []byte("12345"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "route-views4/bgpdata/2022.01/UPDATES/updates.20220131.0000.bz2"},
+			Content:     []byte("1234"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "route-views4/bgpdata/2022.01/UPDATES/updates.20220131.0015.bz2"},
+			Content:     []byte("1234"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "route-views4/bgpdata/2022.02/RIBS/rib.20220201.0000.bz2"},
+			Content:     []byte("123456789012"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "_history/route-views4/bgpdata/2022.01/UPDATES/updates.20220131.0000.bz2.1"},
+			Content:     []byte("12"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "archives", Name: "README"},
+			Content:     []byte("skipped"),
+		},
+		{
+			ObjectAttrs: fakestorage.ObjectAttrs{BucketName: "converted", Name: "route-views4/bgpdata/2022.01/UPDATES/updates.20220131.0000.gz"},
+			Content:     []byte("converted"),
+		},
+	})
+	defer fakegcs.Stop()
+
+	got, err := Collect(context.Background(), fakegcs.Client(), "archives", "converted")
+	if err != nil {
+		t.Fatalf("Collect() = %v; want nil err", err)
+	}
+	want := &Report{
+		Days: []*CollectorDay{
+			{Collector: "route-views2", Day: "2022-01-31", Files: 1, Bytes: 5, Updates: 1},
+			{Collector: "route-views4", Day: "2022-01-31", Files: 2, Bytes: 8, Updates: 2, Converted: 1},
+			{Collector: "route-views4", Day: "2022-02-01", Files: 1, Bytes: 12},
+		},
+		Months: []*Month{
+			{Month: "2022-01", Files: 3, Bytes: 13},
+			{Month: "2022-02", Files: 1, Bytes: 12, BytesGrowth: -1.0 / 13},
+		},
+		Coverage: 1.0 / 3,
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Report{}, "Generated"), cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Collect() mismatch (-want, +got):\n%s", diff)
+	}
+
+	if days := got.Filter("route-views4", "2022-02-01", ""); len(days) != 1 || days[0].Day != "2022-02-01" {
+		t.Errorf("Filter() = %v; want the route-views4 day 2022-02-01", days)
+	}
+}
@@ -0,0 +1,197 @@
+// Package storagetier describes the retrieval characteristics of the GCS
+// storage classes, and restores archived objects back to the STANDARD class.
+//
+// Objects older than the bucket lifecycle threshold are transitioned to
+// NEARLINE, COLDLINE or ARCHIVE. Reads of those objects are billed a
+// per-GB retrieval fee, so readers of historical data should check the
+// estimate (or restore the object) before reading.
+package storagetier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	Standard = "STANDARD"
+	Nearline = "NEARLINE"
+	Coldline = "COLDLINE"
+	Archive  = "ARCHIVE"
+
+	gigabyte = 1 << 30
+)
+
+// retrievalCostPerGB is the USD fee per GB read from each storage class.
+// https://cloud.google.com/storage/pricing#retrieval-pricing
+var retrievalCostPerGB = map[string]float64{
+	Standard: 0,
+	Nearline: 0.01,
+	Coldline: 0.02,
+	Archive:  0.05,
+}
+
+// minStorageDays is the minimum storage duration of each class, a rewrite
+// of the object before this duration incurs an early-deletion charge.
+var minStorageDays = map[string]int{
+	Standard: 0,
+	Nearline: 30,
+	Coldline: 90,
+	Archive:  365,
+}
+
+// firstByteLatency is the typical latency to the first byte of a read from
+// each class. The cold classes of GCS are read without a thaw, unlike the
+// archive tiers of other clouds: a read costs its retrieval fee, not a wait.
+// https://cloud.google.com/storage/docs/storage-classes
+var firstByteLatency = map[string]time.Duration{
+	Standard: 100 * time.Millisecond,
+	Nearline: 100 * time.Millisecond,
+	Coldline: 100 * time.Millisecond,
+	Archive:  100 * time.Millisecond,
+}
+
+// Estimate is the expected cost of reading an object in full.
+type Estimate struct {
+	Class string
+	Bytes int64
+	// Cost is the retrieval fee in USD.
+	Cost float64
+	// Latency is the typical latency to the first byte of the read.
+	Latency time.Duration
+	// MinStorageDays is the minimum storage duration of the class.
+	MinStorageDays int
+	// Restored is set if the object was rewritten to STANDARD to be read, the
+	// rewrite is billed the retrieval fee.
+	Restored bool
+}
+
+// Cold reports whether a read of the object is billed a retrieval fee.
+func (e *Estimate) Cold() bool {
+	return e.Class != Standard
+}
+
+func (e *Estimate) String() string {
+	s := fmt.Sprintf("class %s, %d bytes, retrieval fee ~$%.4f, first byte ~%s", e.Class, e.Bytes, e.Cost, e.Latency)
+	if e.Restored {
+		s += ", restored to " + Standard
+	}
+	return s
+}
+
+// normalize maps the legacy and empty storage classes to their current names.
+func normalize(class string) string {
+	switch c := strings.ToUpper(class); c {
+	case "", "MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY":
+		return Standard
+	default:
+		return c
+	}
+}
+
+// EstimateFor returns the retrieval estimate for an object.
+func EstimateFor(attrs *storage.ObjectAttrs) *Estimate {
+	class := normalize(attrs.StorageClass)
+	return &Estimate{
+		Class:          class,
+		Bytes:          attrs.Size,
+		Cost:           retrievalCostPerGB[class] * float64(attrs.Size) / gigabyte,
+		Latency:        firstByteLatency[class],
+		MinStorageDays: minStorageDays[class],
+	}
+}
+
+// Policy decides how reads of objects in a cold storage class are handled.
+type Policy int
+
+const (
+	// Read reads the object in place, paying the retrieval fee.
+	Read Policy = iota
+	// Reject refuses to read the object, returning the retrieval estimate.
+	Reject
+	// Restore rewrites the object to STANDARD before reading it.
+	Restore
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Read:
+		return "read"
+	case Reject:
+		return "reject"
+	case Restore:
+		return "restore"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy parses a policy name: read, reject or restore.
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{Read, Reject, Restore} {
+		if strings.EqualFold(s, p.String()) {
+			return p, nil
+		}
+	}
+	return Read, fmt.Errorf("unknown cold storage policy %q", s)
+}
+
+// ColdObjectError is returned when a cold object is read under the Reject
+// policy.
+type ColdObjectError struct {
+	Object   string
+	Estimate *Estimate
+}
+
+func (e *ColdObjectError) Error() string {
+	return fmt.Sprintf("%s is in cold storage (%s); restore it to %s before reading", e.Object, e.Estimate, Standard)
+}
+
+// RestoreObject rewrites an object onto itself in the STANDARD class. The
+// object's metadata is preserved. The rewrite reads the object, and is billed
+// the retrieval fee of its class.
+func RestoreObject(ctx context.Context, oh *storage.ObjectHandle) (*storage.ObjectAttrs, error) {
+	attrs, err := oh.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("obj.Attrs: %w", err)
+	}
+	if normalize(attrs.StorageClass) == Standard {
+		return attrs, nil
+	}
+	c := oh.If(storage.Conditions{GenerationMatch: attrs.Generation}).CopierFrom(oh)
+	c.StorageClass = Standard
+	c.Metadata = attrs.Metadata
+	c.ContentType = attrs.ContentType
+	c.ContentEncoding = attrs.ContentEncoding
+	restored, err := c.Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore gs://%s/%s to %s: %w", attrs.Bucket, attrs.Name, Standard, err)
+	}
+	return restored, nil
+}
+
+// Prepare applies the policy to an object about to be read. It returns the
+// estimate of the read. The estimate of an object restored is of its cold
+// class, as the rewrite is billed the retrieval fee; the reads after it are
+// free.
+func Prepare(ctx context.Context, oh *storage.ObjectHandle, attrs *storage.ObjectAttrs, p Policy) (*Estimate, error) {
+	est := EstimateFor(attrs)
+	if !est.Cold() {
+		return est, nil
+	}
+	switch p {
+	case Reject:
+		return est, &ColdObjectError{
+			Object:   fmt.Sprintf("gs://%s/%s", attrs.Bucket, attrs.Name),
+			Estimate: est,
+		}
+	case Restore:
+		if _, err := RestoreObject(ctx, oh); err != nil {
+			return est, err
+		}
+		est.Restored = true
+	}
+	return est, nil
+}
@@ -0,0 +1,85 @@
+package storagetier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEstimateFor(t *testing.T) {
+	tests := []struct {
+		desc     string
+		attrs    *storage.ObjectAttrs
+		want     *Estimate
+		wantCold bool
+	}{
+		{
+			desc:  "empty class is standard",
+			attrs: &storage.ObjectAttrs{Size: gigabyte},
+			want:  &Estimate{Class: Standard, Bytes: gigabyte, Latency: 100 * time.Millisecond},
+		},
+		{
+			desc:  "legacy regional class is standard",
+			attrs: &storage.ObjectAttrs{StorageClass: "REGIONAL", Size: gigabyte},
+			want:  &Estimate{Class: Standard, Bytes: gigabyte, Latency: 100 * time.Millisecond},
+		},
+		{
+			desc:     "coldline",
+			attrs:    &storage.ObjectAttrs{StorageClass: Coldline, Size: 2 * gigabyte},
+			want:     &Estimate{Class: Coldline, Bytes: 2 * gigabyte, Cost: 0.04, Latency: 100 * time.Millisecond, MinStorageDays: 90},
+			wantCold: true,
+		},
+		{
+			desc:     "archive",
+			attrs:    &storage.ObjectAttrs{StorageClass: "archive", Size: gigabyte},
+			want:     &Estimate{Class: Archive, Bytes: gigabyte, Cost: 0.05, Latency: 100 * time.Millisecond, MinStorageDays: 365},
+			wantCold: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := EstimateFor(test.attrs)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("EstimateFor() mismatch (-want, +got):\n%s", diff)
+			}
+			if got.Cold() != test.wantCold {
+				t.Errorf("Cold() = %v; want %v", got.Cold(), test.wantCold)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, p := range []Policy{Read, Reject, Restore} {
+		got, err := ParsePolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParsePolicy(%q) = %v, %v; want %v, nil", p.String(), got, err, p)
+		}
+	}
+	if _, err := ParsePolicy("thaw"); err == nil {
+		t.Error("ParsePolicy(thaw): nil err; want non-nil err")
+	}
+}
+
+func TestPrepareReject(t *testing.T) {
+	attrs := &storage.ObjectAttrs{Bucket: "foo", Name: "bar", StorageClass: Archive, Size: gigabyte}
+	_, err := Prepare(context.Background(), nil, attrs, Reject)
+	var coldErr *ColdObjectError
+	if !errors.As(err, &coldErr) {
+		t.Fatalf("Prepare() err = %v; want a ColdObjectError", err)
+	}
+	if coldErr.Object != "gs://foo/bar" {
+		t.Errorf("got object %s; want gs://foo/bar", coldErr.Object)
+	}
+
+	// Standard objects are never rejected.
+	attrs.StorageClass = Standard
+	if _, err := Prepare(context.Background(), nil, attrs, Reject); err != nil {
+		t.Errorf("Prepare(standard) = %v; want nil err", err)
+	}
+}
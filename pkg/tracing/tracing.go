@@ -0,0 +1,36 @@
+// Package tracing exports the OpenTelemetry traces of the sync tools to
+// Cloud Trace.
+//
+// Until Init is called the global tracer provider is a no-op, so
+// instrumented code costs nothing when tracing is disabled.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	texporter "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Init installs a global tracer provider which exports the spans of service
+// to Cloud Trace in project, sampling ratio (0 to 1) of the traces. The
+// returned func flushes the spans, call it before the process exits.
+func Init(ctx context.Context, project, service string, ratio float64) (func(context.Context) error, error) {
+	exp, err := texporter.New(texporter.WithProjectID(project))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Cloud Trace exporter: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
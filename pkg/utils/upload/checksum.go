@@ -0,0 +1,99 @@
+package uploadutils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	"cloud.google.com/go/storage"
+)
+
+// Checksum algorithms which may be used to verify archive content.
+const (
+	MD5    = "md5"
+	CRC32C = "crc32c"
+	SHA256 = "sha256"
+)
+
+// SHA256MetadataKey is the object metadata key of the sha256 checksum of the
+// content, GCS does not record one itself.
+const SHA256MetadataKey = "sha256"
+
+// MD5MetadataKey is the object metadata key of the md5sum of the content of a
+// composite object, GCS records none for them. The upload server sets it on
+// the objects it composes, of the md5sum it verified.
+const MD5MetadataKey = "md5sum"
+
+// Object metadata keys of the provenance of a file, set by the upload server.
+const (
+	// CollectorMetadataKey is the route collector of an archive.
+	CollectorMetadataKey = "collector"
+	// CaptureTimeMetadataKey is the capture time of an archive, in RFC 3339,
+	// parsed from its filename.
+	CaptureTimeMetadataKey = "capture_time"
+	// SourceURLMetadataKey is the URL the file was mirrored from.
+	SourceURLMetadataKey = "source_url"
+	// UploaderMetadataKey is the caller which uploaded the file.
+	UploaderMetadataKey = "uploader"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ValidChecksum reports whether algo is a supported checksum algorithm.
+func ValidChecksum(algo string) bool {
+	switch algo {
+	case MD5, CRC32C, SHA256:
+		return true
+	}
+	return false
+}
+
+// NewHash returns a hash of the algorithm, for content which is checksummed
+// as it is received. The hex encoded Sum matches Checksum.
+func NewHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case MD5:
+		return md5.New(), nil
+	case CRC32C:
+		return crc32.New(crc32cTable), nil
+	case SHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+}
+
+// Checksum returns the hex encoded checksum of the content.
+func Checksum(algo string, b []byte) (string, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+	h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ChecksumFromAttrs returns the hex encoded checksum GCS records for an
+// object, or an empty string if GCS has no record of that algorithm. The
+// sha256 is read from the object metadata, if the upload stored one, as is the
+// md5sum of a composite object.
+func ChecksumFromAttrs(algo string, attrs *storage.ObjectAttrs) string {
+	switch algo {
+	case MD5:
+		if len(attrs.MD5) == 0 {
+			return attrs.Metadata[MD5MetadataKey]
+		}
+		return hex.EncodeToString(attrs.MD5)
+	case CRC32C:
+		// The crc32c of empty content is 0, of other content an unset one.
+		if attrs.CRC32C == 0 && attrs.Size > 0 {
+			return ""
+		}
+		return fmt.Sprintf("%08x", attrs.CRC32C)
+	case SHA256:
+		return attrs.Metadata[SHA256MetadataKey]
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package uploadutils
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestChecksum(t *testing.T) {
+	content := []byte("Foo Bar Baz")
+	tests := []struct {
+		algo    string
+		want    string
+		wantErr bool
+	}{
+		{algo: MD5, want: "50e3903156f5d2dac6c9f89626d48c75"},
+		{algo: CRC32C, want: "3863cc2f"},
+		{algo: SHA256, want: "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1"},
+		{algo: "sha1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.algo, func(t *testing.T) {
+			got, err := Checksum(test.algo, content)
+			switch {
+			case err != nil && !test.wantErr:
+				t.Fatalf("Checksum(%s) = %v; want nil err", test.algo, err)
+			case err == nil && test.wantErr:
+				t.Fatalf("Checksum(%s): nil err; want non-nil err", test.algo)
+			}
+			if got != test.want {
+				t.Errorf("Checksum(%s) = %s; want %s", test.algo, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewHash(t *testing.T) {
+	content := []byte("Foo Bar Baz")
+	for _, algo := range []string{MD5, CRC32C, SHA256} {
+		h, err := NewHash(algo)
+		if err != nil {
+			t.Fatalf("NewHash(%s) = %v; want nil err", algo, err)
+		}
+		// Content received in parts, e.g. a resumed transfer.
+		h.Write(content[:4])
+		h.Write(content[4:])
+		want, _ := Checksum(algo, content)
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			t.Errorf("NewHash(%s) sum = %s; want %s", algo, got, want)
+		}
+	}
+}
+
+func TestChecksumFromAttrs(t *testing.T) {
+	// Composite objects carry a CRC32C but no MD5.
+	composite := &storage.ObjectAttrs{CRC32C: 0x3863cc2f}
+	if got := ChecksumFromAttrs(MD5, composite); got != "" {
+		t.Errorf("ChecksumFromAttrs(md5) = %q; want empty", got)
+	}
+	if got, want := ChecksumFromAttrs(CRC32C, composite), "3863cc2f"; got != want {
+		t.Errorf("ChecksumFromAttrs(crc32c) = %q; want %q", got, want)
+	}
+	if got := ChecksumFromAttrs(SHA256, composite); got != "" {
+		t.Errorf("ChecksumFromAttrs(sha256) = %q; want empty", got)
+	}
+
+	// An unset CRC32C is no checksum, but of empty content.
+	if got := ChecksumFromAttrs(CRC32C, &storage.ObjectAttrs{Size: 11}); got != "" {
+		t.Errorf("ChecksumFromAttrs(crc32c) of no crc32c = %q; want empty", got)
+	}
+	if got, want := ChecksumFromAttrs(CRC32C, &storage.ObjectAttrs{}), "00000000"; got != want {
+		t.Errorf("ChecksumFromAttrs(crc32c) of empty content = %q; want %q", got, want)
+	}
+
+	// The sha256 is stored in the metadata by the upload server.
+	sum := "cd19da525f20096a817197bf263f3fdbe6485f00ec7354b691171358ebb9f1a1"
+	stored := &storage.ObjectAttrs{Metadata: map[string]string{SHA256MetadataKey: sum}}
+	if got := ChecksumFromAttrs(SHA256, stored); got != sum {
+		t.Errorf("ChecksumFromAttrs(sha256) = %q; want %q", got, sum)
+	}
+
+	// The md5sum of a composite object is stored in the metadata by the
+	// upload server.
+	md5Sum := "50e3903156f5d2dac6c9f89626d48c75"
+	composed := &storage.ObjectAttrs{CRC32C: 0x3863cc2f, Metadata: map[string]string{MD5MetadataKey: md5Sum}}
+	if got := ChecksumFromAttrs(MD5, composed); got != md5Sum {
+		t.Errorf("ChecksumFromAttrs(md5) = %q; want %q", got, md5Sum)
+	}
+}
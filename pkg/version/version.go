@@ -0,0 +1,219 @@
+// Package version negotiates the upload protocol version between the upload
+// clients and the server.
+//
+// Clients send their protocol version, and the name and version of the tool,
+// in the request metadata. The server logs them, rejects clients older than a
+// minimum protocol version, and serves the compatibility matrix: the
+// capabilities of the protocol and the version which introduced each. This
+// lets checksum algorithms, chunking and naming policies evolve across a
+// fleet of independently updated collectors.
+package version
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	pb "github.com/routeviews/google-cloud-storage/proto/rv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Protocol is the upload protocol version of this tree. Bump the minor
+	// version, and add a capability, when the protocol gains a feature.
+	Protocol = "1.24.0"
+	// Legacy is the protocol version of clients which send no version, they
+	// predate the negotiation.
+	Legacy = "1.0.0"
+
+	// ProtocolKey is the metadata key of the client protocol version.
+	ProtocolKey = "rv-protocol-version"
+	// ClientKey is the metadata key of the client tool, ie: mass_upload/1.1.0.
+	ClientKey = "rv-client"
+
+	// compatibilityMethod is exempt from the version gate, so that old clients
+	// can learn why they are rejected.
+	compatibilityMethod = "/rv.proto.RV/Compatibility"
+	// healthService is exempt as well, health probes send no version.
+	healthService = "/grpc.health.v1.Health/"
+)
+
+// Capabilities is the compatibility matrix of the protocol.
+var Capabilities = []*pb.Capability{
+	{Name: "md5", MinVersion: "1.0.0", Description: "FileUpload verifies the content with the md5sum field."},
+	{Name: "version", MinVersion: "1.1.0", Description: "Version metadata and the Compatibility RPC."},
+	{Name: "gzip", MinVersion: "1.2.0", Description: "Requests may use gzip call compression."},
+	{Name: "stream", MinVersion: "1.3.0", Description: "FileUploadStream uploads files in chunks, beyond the message size limit."},
+	{Name: "resumable", MinVersion: "1.4.0", Description: "StartUpload, UploadChunk and FinishUpload sessions resume from the committed offset."},
+	{Name: "unchanged", MinVersion: "1.5.0", Description: "Uploads of content stored already return UNCHANGED, without a write."},
+	{Name: "crc32c", MinVersion: "1.6.0", Description: "Uploads may carry a crc32c, which cloud-storage verifies the write with."},
+	{Name: "sha256", MinVersion: "1.7.0", Description: "Uploads may carry a sha256, which is stored in the object metadata."},
+	{Name: "content_encoding", MinVersion: "1.8.0", Description: "FileUpload content may be gzip encoded, it is stored decoded."},
+	{Name: "request_id", MinVersion: "1.9.0", Description: "Retries of a FileUpload with the same request_id return the first response."},
+	{Name: "source_url", MinVersion: "1.10.0", Description: "Uploads may carry a source_url, which is stored in the object metadata."},
+	{Name: "retention", MinVersion: "1.11.0", Description: "Responses carry the retention of the stored object: its holds and the end of its retention period."},
+	{Name: "object_stat", MinVersion: "1.12.0", Description: "ObjectStat returns whether the object of a file exists, and its checksums, size and generation."},
+	{Name: "list_objects", MinVersion: "1.13.0", Description: "ListObjects returns a page of the objects of a project under a prefix."},
+	{Name: "delete_object", MinVersion: "1.14.0", Description: "DeleteObject deletes the object of a file, for the admins of its project, with an audited reason."},
+	{Name: "signed_upload", MinVersion: "1.15.0", Description: "SignUpload returns a signed URL to PUT a file to cloud-storage directly, FinalizeUpload stores it."},
+	{Name: "replicas", MinVersion: "1.16.0", Description: "Responses carry the status of the copies of the stored object in the replica buckets of its bucket."},
+	{Name: "invalid_format", MinVersion: "1.17.0", Description: "Uploads of ROUTEVIEWS updates archives which are not valid MRT return INVALID_FORMAT, and are not stored."},
+	{Name: "allow_overwrite", MinVersion: "1.18.0", Description: "Uploads of other content over a stored object fail with FAILED_PRECONDITION, unless an admin sets allow_overwrite."},
+	{Name: "error_details", MinVersion: "1.19.0", Description: "Errors carry a google.rpc.ErrorInfo of their reason, and the BadRequest or PreconditionFailure of the fields or checksums at fault."},
+	{Name: "convert_now", MinVersion: "1.20.0", Description: "Uploads with convert_now convert the updates archive for BigQuery before the response, which carries the conversion."},
+	{Name: "transcode", MinVersion: "1.21.0", Description: "Gzip compressed text files uploaded with transcode are stored with Content-Encoding: gzip and the content type of the text."},
+	{Name: "generations", MinVersion: "1.22.0", Description: "Responses carry the generation and metageneration of the object, uploads with if_generation_match replace only that generation."},
+	{Name: "object_details", MinVersion: "1.23.0", Description: "Responses carry the object, its size and checksums, and whether the write was skipped as a duplicate."},
+	{Name: "server_stats", MinVersion: "1.24.0", Description: "ServerStats returns the uptime and build of the server, its uploads per project in the period, and its backlogs."},
+}
+
+// parse parses a MAJOR.MINOR.PATCH version, MINOR and PATCH are optional.
+func parse(v string) ([3]int, error) {
+	var res [3]int
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) > 3 {
+		return res, fmt.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return res, fmt.Errorf("invalid version %q", v)
+		}
+		res[i] = n
+	}
+	return res, nil
+}
+
+// Compare returns -1, 0 or 1 if version a is older than, equal to or newer
+// than version b.
+func Compare(a, b string) (int, error) {
+	va, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// Compatible reports whether a client protocol version is accepted by a
+// server with the minimum version min. An empty min accepts every client.
+func Compatible(client, min string) (bool, error) {
+	if min == "" {
+		return true, nil
+	}
+	c, err := Compare(client, min)
+	if err != nil {
+		return false, err
+	}
+	return c >= 0, nil
+}
+
+// FromIncomingContext returns the protocol version and tool of the client
+// which sent the request. Clients without a version are Legacy.
+func FromIncomingContext(ctx context.Context) (string, string) {
+	protocol, client := Legacy, "unknown"
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return protocol, client
+	}
+	if v := md.Get(ProtocolKey); len(v) > 0 && v[0] != "" {
+		protocol = v[0]
+	}
+	if v := md.Get(ClientKey); len(v) > 0 && v[0] != "" {
+		client = v[0]
+	}
+	return protocol, client
+}
+
+// UnaryClientInterceptor adds the protocol version and the client tool, ie:
+// mass_upload/1.1.0, to the metadata of every request.
+func UnaryClientInterceptor(client string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, ProtocolKey, Protocol, ClientKey, client)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor adds the protocol version and the client tool to
+// the metadata of every stream, see UnaryClientInterceptor.
+func StreamClientInterceptor(client string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, ProtocolKey, Protocol, ClientKey, client)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// check logs the version of the client of a request to method with logf,
+// and rejects clients older than min.
+func check(ctx context.Context, method, min string, logf func(format string, args ...interface{})) error {
+	protocol, client := FromIncomingContext(ctx)
+	logf("%s from client(%s) protocol version(%s)", method, client, protocol)
+	if method == compatibilityMethod || strings.HasPrefix(method, healthService) {
+		return nil
+	}
+	ok, err := Compatible(protocol, min)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "bad client protocol version: %v", err)
+	}
+	if !ok {
+		return status.Errorf(codes.FailedPrecondition,
+			"client(%s) protocol version %s is older than the minimum %s supported by the server, upgrade the client",
+			client, protocol, min)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor logs the version of the client of every request
+// with logf, and rejects clients older than min with FailedPrecondition. An
+// empty min accepts every client.
+func UnaryServerInterceptor(min string, logf func(format string, args ...interface{})) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, info.FullMethod, min, logf); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the UnaryServerInterceptor of streams.
+func StreamServerInterceptor(min string, logf func(format string, args ...interface{})) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), info.FullMethod, min, logf); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Matrix returns the compatibility response of a server with the minimum
+// client version min, to a client of version client.
+func Matrix(client, min string) (*pb.CompatibilityResponse, error) {
+	if client == "" {
+		client = Legacy
+	}
+	ok, err := Compatible(client, min)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CompatibilityResponse{
+		ServerVersion:    Protocol,
+		MinClientVersion: min,
+		Compatible:       ok,
+		Capabilities:     Capabilities,
+	}, nil
+}
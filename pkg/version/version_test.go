@@ -0,0 +1,138 @@
+package version
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    int
+		wantErr bool
+	}{
+		{a: "1.0.0", b: "1.0.0", want: 0},
+		{a: "1.0.0", b: "1.1.0", want: -1},
+		{a: "1.10.0", b: "1.9.3", want: 1},
+		{a: "v2", b: "1.9.3", want: 1},
+		{a: "1.1", b: "1.1.0", want: 0},
+		{a: "1.x", b: "1.0.0", wantErr: true},
+		{a: "1.0.0.0", b: "1.0.0", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := Compare(test.a, test.b)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Compare(%s, %s) = %v; want err %v", test.a, test.b, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Compare(%s, %s) = %d; want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+	nolog := func(string, ...interface{}) {}
+
+	tests := []struct {
+		desc     string
+		min      string
+		md       metadata.MD
+		method   string
+		wantCode codes.Code
+	}{
+		{
+			desc:     "no minimum",
+			method:   "/rv.proto.RV/FileUpload",
+			wantCode: codes.OK,
+		},
+		{
+			desc:     "new enough client",
+			min:      "1.1.0",
+			md:       metadata.Pairs(ProtocolKey, "1.1.0", ClientKey, "mass_upload/1.1.0"),
+			method:   "/rv.proto.RV/FileUpload",
+			wantCode: codes.OK,
+		},
+		{
+			desc:     "legacy client rejected",
+			min:      "1.1.0",
+			method:   "/rv.proto.RV/FileUpload",
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			desc:     "legacy client may check compatibility",
+			min:      "1.1.0",
+			method:   compatibilityMethod,
+			wantCode: codes.OK,
+		},
+		{
+			desc:     "health probes are not gated",
+			min:      "1.1.0",
+			method:   "/grpc.health.v1.Health/Check",
+			wantCode: codes.OK,
+		},
+		{
+			desc:     "bad version",
+			min:      "1.1.0",
+			md:       metadata.Pairs(ProtocolKey, "latest"),
+			method:   "/rv.proto.RV/FileUpload",
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx := context.Background()
+			if test.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, test.md)
+			}
+			_, err := UnaryServerInterceptor(test.min, nolog)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: test.method}, handler)
+			if got := status.Code(err); got != test.wantCode {
+				t.Errorf("got code %v (%v); want %v", got, err, test.wantCode)
+			}
+		})
+	}
+}
+
+// serverStream is a grpc.ServerStream with the incoming context ctx.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	handler := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+	nolog := func(string, ...interface{}) {}
+	info := &grpc.StreamServerInfo{FullMethod: "/rv.proto.RV/FileUploadStream", IsClientStream: true}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ProtocolKey, "1.3.0"))
+	if err := StreamServerInterceptor("1.3.0", nolog)(nil, &serverStream{ctx: ctx}, info, handler); err != nil {
+		t.Errorf("new enough client: got %v; want nil err", err)
+	}
+	err := StreamServerInterceptor("1.3.0", nolog)(nil, &serverStream{ctx: context.Background()}, info, handler)
+	if got := status.Code(err); got != codes.FailedPrecondition {
+		t.Errorf("legacy client: got code %v (%v); want %v", got, err, codes.FailedPrecondition)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	got, err := Matrix("", "1.1.0")
+	if err != nil {
+		t.Fatalf("Matrix() = %v; want nil err", err)
+	}
+	if got.GetCompatible() {
+		t.Error("legacy client is compatible with minimum 1.1.0; want incompatible")
+	}
+	if got.GetServerVersion() != Protocol || len(got.GetCapabilities()) != len(Capabilities) {
+		t.Errorf("Matrix() = %v; want server version %s and every capability", got, Protocol)
+	}
+}
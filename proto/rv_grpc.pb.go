@@ -21,6 +21,47 @@ type RVClient interface {
 	// FileUpload accepts a single file upload request and
 	// returns a status message to the caller.
 	FileUpload(ctx context.Context, in *FileRequest, opts ...grpc.CallOption) (*FileResponse, error)
+	// FileUploadStream accepts a file too large for a single FileUpload
+	// request: the first message carries the file metadata, the following
+	// messages carry the content in order. It returns a status message once the
+	// stream is closed and the file is stored.
+	FileUploadStream(ctx context.Context, opts ...grpc.CallOption) (RV_FileUploadStreamClient, error)
+	// StartUpload starts a resumable upload session of a file, or resumes one.
+	// It returns the session and its committed offset, the offset of the next
+	// chunk to upload.
+	StartUpload(ctx context.Context, in *StartUploadRequest, opts ...grpc.CallOption) (*UploadStatus, error)
+	// UploadChunk stores a chunk of the file content at the committed offset of
+	// the session, and returns the new committed offset.
+	UploadChunk(ctx context.Context, in *UploadChunkRequest, opts ...grpc.CallOption) (*UploadStatus, error)
+	// FinishUpload verifies the content of the session against the md5sum, and
+	// stores the file.
+	FinishUpload(ctx context.Context, in *FinishUploadRequest, opts ...grpc.CallOption) (*FileResponse, error)
+	// Compatibility returns the protocol version of the server, the minimum
+	// client protocol version it accepts, and the capabilities of the protocol
+	// with the version which introduced each.
+	Compatibility(ctx context.Context, in *CompatibilityRequest, opts ...grpc.CallOption) (*CompatibilityResponse, error)
+	// ObjectStat returns whether the object of a file exists, and its
+	// checksums, size and generation, so a client may skip the upload of a file
+	// stored already without credentials to cloud-storage.
+	ObjectStat(ctx context.Context, in *ObjectStatRequest, opts ...grpc.CallOption) (*ObjectStatResponse, error)
+	// ListObjects returns a page of the objects of a project under a prefix,
+	// so mirror clients and audit tools may enumerate what is archived.
+	ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsResponse, error)
+	// DeleteObject deletes the object of a corrupt or misnamed file. Only the
+	// admins of the project may delete its objects, the reason of each delete
+	// is recorded in the audit log.
+	DeleteObject(ctx context.Context, in *DeleteObjectRequest, opts ...grpc.CallOption) (*DeleteObjectResponse, error)
+	// SignUpload returns a time-limited signed URL to PUT the content of a file
+	// to cloud-storage directly, for files too large to send through the
+	// server. The upload is staged until FinalizeUpload.
+	SignUpload(ctx context.Context, in *SignUploadRequest, opts ...grpc.CallOption) (*SignUploadResponse, error)
+	// FinalizeUpload verifies the content of a signed upload against its
+	// checksums, and stores the file.
+	FinalizeUpload(ctx context.Context, in *FinalizeUploadRequest, opts ...grpc.CallOption) (*FileResponse, error)
+	// ServerStats returns the uptime and build of the server instance, the
+	// uploads and bytes it stored per project in the current period, and its
+	// backlogs, for monitoring agents and dashboards.
+	ServerStats(ctx context.Context, in *ServerStatsRequest, opts ...grpc.CallOption) (*ServerStatsResponse, error)
 }
 
 type rVClient struct {
@@ -40,6 +81,130 @@ func (c *rVClient) FileUpload(ctx context.Context, in *FileRequest, opts ...grpc
 	return out, nil
 }
 
+func (c *rVClient) FileUploadStream(ctx context.Context, opts ...grpc.CallOption) (RV_FileUploadStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RV_ServiceDesc.Streams[0], "/rv.proto.RV/FileUploadStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rVFileUploadStreamClient{stream}
+	return x, nil
+}
+
+type RV_FileUploadStreamClient interface {
+	Send(*FileChunk) error
+	CloseAndRecv() (*FileResponse, error)
+	grpc.ClientStream
+}
+
+type rVFileUploadStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *rVFileUploadStreamClient) Send(m *FileChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rVFileUploadStreamClient) CloseAndRecv() (*FileResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(FileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *rVClient) StartUpload(ctx context.Context, in *StartUploadRequest, opts ...grpc.CallOption) (*UploadStatus, error) {
+	out := new(UploadStatus)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/StartUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) UploadChunk(ctx context.Context, in *UploadChunkRequest, opts ...grpc.CallOption) (*UploadStatus, error) {
+	out := new(UploadStatus)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/UploadChunk", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) FinishUpload(ctx context.Context, in *FinishUploadRequest, opts ...grpc.CallOption) (*FileResponse, error) {
+	out := new(FileResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/FinishUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) Compatibility(ctx context.Context, in *CompatibilityRequest, opts ...grpc.CallOption) (*CompatibilityResponse, error) {
+	out := new(CompatibilityResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/Compatibility", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) ObjectStat(ctx context.Context, in *ObjectStatRequest, opts ...grpc.CallOption) (*ObjectStatResponse, error) {
+	out := new(ObjectStatResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/ObjectStat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsResponse, error) {
+	out := new(ListObjectsResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/ListObjects", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) DeleteObject(ctx context.Context, in *DeleteObjectRequest, opts ...grpc.CallOption) (*DeleteObjectResponse, error) {
+	out := new(DeleteObjectResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/DeleteObject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) SignUpload(ctx context.Context, in *SignUploadRequest, opts ...grpc.CallOption) (*SignUploadResponse, error) {
+	out := new(SignUploadResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/SignUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) FinalizeUpload(ctx context.Context, in *FinalizeUploadRequest, opts ...grpc.CallOption) (*FileResponse, error) {
+	out := new(FileResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/FinalizeUpload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rVClient) ServerStats(ctx context.Context, in *ServerStatsRequest, opts ...grpc.CallOption) (*ServerStatsResponse, error) {
+	out := new(ServerStatsResponse)
+	err := c.cc.Invoke(ctx, "/rv.proto.RV/ServerStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RVServer is the server API for RV service.
 // All implementations must embed UnimplementedRVServer
 // for forward compatibility
@@ -47,6 +212,47 @@ type RVServer interface {
 	// FileUpload accepts a single file upload request and
 	// returns a status message to the caller.
 	FileUpload(context.Context, *FileRequest) (*FileResponse, error)
+	// FileUploadStream accepts a file too large for a single FileUpload
+	// request: the first message carries the file metadata, the following
+	// messages carry the content in order. It returns a status message once the
+	// stream is closed and the file is stored.
+	FileUploadStream(RV_FileUploadStreamServer) error
+	// StartUpload starts a resumable upload session of a file, or resumes one.
+	// It returns the session and its committed offset, the offset of the next
+	// chunk to upload.
+	StartUpload(context.Context, *StartUploadRequest) (*UploadStatus, error)
+	// UploadChunk stores a chunk of the file content at the committed offset of
+	// the session, and returns the new committed offset.
+	UploadChunk(context.Context, *UploadChunkRequest) (*UploadStatus, error)
+	// FinishUpload verifies the content of the session against the md5sum, and
+	// stores the file.
+	FinishUpload(context.Context, *FinishUploadRequest) (*FileResponse, error)
+	// Compatibility returns the protocol version of the server, the minimum
+	// client protocol version it accepts, and the capabilities of the protocol
+	// with the version which introduced each.
+	Compatibility(context.Context, *CompatibilityRequest) (*CompatibilityResponse, error)
+	// ObjectStat returns whether the object of a file exists, and its
+	// checksums, size and generation, so a client may skip the upload of a file
+	// stored already without credentials to cloud-storage.
+	ObjectStat(context.Context, *ObjectStatRequest) (*ObjectStatResponse, error)
+	// ListObjects returns a page of the objects of a project under a prefix,
+	// so mirror clients and audit tools may enumerate what is archived.
+	ListObjects(context.Context, *ListObjectsRequest) (*ListObjectsResponse, error)
+	// DeleteObject deletes the object of a corrupt or misnamed file. Only the
+	// admins of the project may delete its objects, the reason of each delete
+	// is recorded in the audit log.
+	DeleteObject(context.Context, *DeleteObjectRequest) (*DeleteObjectResponse, error)
+	// SignUpload returns a time-limited signed URL to PUT the content of a file
+	// to cloud-storage directly, for files too large to send through the
+	// server. The upload is staged until FinalizeUpload.
+	SignUpload(context.Context, *SignUploadRequest) (*SignUploadResponse, error)
+	// FinalizeUpload verifies the content of a signed upload against its
+	// checksums, and stores the file.
+	FinalizeUpload(context.Context, *FinalizeUploadRequest) (*FileResponse, error)
+	// ServerStats returns the uptime and build of the server instance, the
+	// uploads and bytes it stored per project in the current period, and its
+	// backlogs, for monitoring agents and dashboards.
+	ServerStats(context.Context, *ServerStatsRequest) (*ServerStatsResponse, error)
 	mustEmbedUnimplementedRVServer()
 }
 
@@ -57,6 +263,39 @@ type UnimplementedRVServer struct {
 func (UnimplementedRVServer) FileUpload(context.Context, *FileRequest) (*FileResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FileUpload not implemented")
 }
+func (UnimplementedRVServer) FileUploadStream(RV_FileUploadStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method FileUploadStream not implemented")
+}
+func (UnimplementedRVServer) StartUpload(context.Context, *StartUploadRequest) (*UploadStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartUpload not implemented")
+}
+func (UnimplementedRVServer) UploadChunk(context.Context, *UploadChunkRequest) (*UploadStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadChunk not implemented")
+}
+func (UnimplementedRVServer) FinishUpload(context.Context, *FinishUploadRequest) (*FileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishUpload not implemented")
+}
+func (UnimplementedRVServer) Compatibility(context.Context, *CompatibilityRequest) (*CompatibilityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Compatibility not implemented")
+}
+func (UnimplementedRVServer) ObjectStat(context.Context, *ObjectStatRequest) (*ObjectStatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ObjectStat not implemented")
+}
+func (UnimplementedRVServer) ListObjects(context.Context, *ListObjectsRequest) (*ListObjectsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListObjects not implemented")
+}
+func (UnimplementedRVServer) DeleteObject(context.Context, *DeleteObjectRequest) (*DeleteObjectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteObject not implemented")
+}
+func (UnimplementedRVServer) SignUpload(context.Context, *SignUploadRequest) (*SignUploadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignUpload not implemented")
+}
+func (UnimplementedRVServer) FinalizeUpload(context.Context, *FinalizeUploadRequest) (*FileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinalizeUpload not implemented")
+}
+func (UnimplementedRVServer) ServerStats(context.Context, *ServerStatsRequest) (*ServerStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ServerStats not implemented")
+}
 func (UnimplementedRVServer) mustEmbedUnimplementedRVServer() {}
 
 // UnsafeRVServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +327,212 @@ func _RV_FileUpload_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RV_FileUploadStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RVServer).FileUploadStream(&rVFileUploadStreamServer{stream})
+}
+
+type RV_FileUploadStreamServer interface {
+	SendAndClose(*FileResponse) error
+	Recv() (*FileChunk, error)
+	grpc.ServerStream
+}
+
+type rVFileUploadStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *rVFileUploadStreamServer) SendAndClose(m *FileResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rVFileUploadStreamServer) Recv() (*FileChunk, error) {
+	m := new(FileChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RV_StartUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).StartUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/StartUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).StartUpload(ctx, req.(*StartUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_UploadChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).UploadChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/UploadChunk",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).UploadChunk(ctx, req.(*UploadChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_FinishUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).FinishUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/FinishUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).FinishUpload(ctx, req.(*FinishUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_Compatibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompatibilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).Compatibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/Compatibility",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).Compatibility(ctx, req.(*CompatibilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_ObjectStat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ObjectStatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).ObjectStat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/ObjectStat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).ObjectStat(ctx, req.(*ObjectStatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_ListObjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListObjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).ListObjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/ListObjects",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).ListObjects(ctx, req.(*ListObjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_DeleteObject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteObjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).DeleteObject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/DeleteObject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).DeleteObject(ctx, req.(*DeleteObjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_SignUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).SignUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/SignUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).SignUpload(ctx, req.(*SignUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_FinalizeUpload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinalizeUploadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).FinalizeUpload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/FinalizeUpload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).FinalizeUpload(ctx, req.(*FinalizeUploadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RV_ServerStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ServerStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RVServer).ServerStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rv.proto.RV/ServerStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RVServer).ServerStats(ctx, req.(*ServerStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // RV_ServiceDesc is the grpc.ServiceDesc for RV service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,7 +544,53 @@ var RV_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "FileUpload",
 			Handler:    _RV_FileUpload_Handler,
 		},
+		{
+			MethodName: "StartUpload",
+			Handler:    _RV_StartUpload_Handler,
+		},
+		{
+			MethodName: "UploadChunk",
+			Handler:    _RV_UploadChunk_Handler,
+		},
+		{
+			MethodName: "FinishUpload",
+			Handler:    _RV_FinishUpload_Handler,
+		},
+		{
+			MethodName: "Compatibility",
+			Handler:    _RV_Compatibility_Handler,
+		},
+		{
+			MethodName: "ObjectStat",
+			Handler:    _RV_ObjectStat_Handler,
+		},
+		{
+			MethodName: "ListObjects",
+			Handler:    _RV_ListObjects_Handler,
+		},
+		{
+			MethodName: "DeleteObject",
+			Handler:    _RV_DeleteObject_Handler,
+		},
+		{
+			MethodName: "SignUpload",
+			Handler:    _RV_SignUpload_Handler,
+		},
+		{
+			MethodName: "FinalizeUpload",
+			Handler:    _RV_FinalizeUpload_Handler,
+		},
+		{
+			MethodName: "ServerStats",
+			Handler:    _RV_ServerStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FileUploadStream",
+			Handler:       _RV_FileUploadStream_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "rv.proto",
 }
@@ -14,14 +14,40 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08rv.proto\x12\x08rv.proto\"\xde\x01\n\x0b\x46ileRequest\x12\x10\n\x08\x66ilename\x18\x01 \x01(\t\x12\x0e\n\x06md5sum\x18\x02 \x01(\t\x12\x0f\n\x07\x63ontent\x18\x03 \x01(\x0c\x12\x13\n\x0b\x63onvert_sql\x18\x04 \x01(\x08\x12.\n\x07project\x18\x05 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\"W\n\x07Project\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0e\n\nROUTEVIEWS\x10\x01\x12\x12\n\x0eROUTEVIEWS_RIB\x10\x04\x12\x0c\n\x08RIPE_RIS\x10\x02\x12\r\n\tRPKI_RARC\x10\x03\"\x82\x01\n\x0c\x46ileResponse\x12-\n\x06status\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileResponse.Status\x12\x15\n\rerror_message\x18\x02 \x01(\t\",\n\x06Status\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0b\n\x07SUCCESS\x10\x01\x12\x08\n\x04\x46\x41IL\x10\x02\x32\x41\n\x02RV\x12;\n\nFileUpload\x12\x15.rv.proto.FileRequest\x1a\x16.rv.proto.FileResponseB5Z3github.com/routeviews/google-cloud-storage/proto/rvb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08rv.proto\x12\x08rv.proto\"\xa7\x03\n\x0b\x46ileRequest\x12\x10\n\x08\x66ilename\x18\x01 \x01(\t\x12\x0e\n\x06md5sum\x18\x02 \x01(\t\x12\x0f\n\x07\x63ontent\x18\x03 \x01(\x0c\x12\x13\n\x0b\x63onvert_sql\x18\x04 \x01(\x08\x12.\n\x07project\x18\x05 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\x12\x0e\n\x06\x63rc32c\x18\x06 \x01(\t\x12\x0e\n\x06sha256\x18\x07 \x01(\t\x12\x18\n\x10\x63ontent_encoding\x18\x08 \x01(\t\x12\x12\n\nrequest_id\x18\t \x01(\t\x12\x12\n\nsource_url\x18\n \x01(\t\x12\x17\n\x0f\x61llow_overwrite\x18\x0b \x01(\x08\x12\x13\n\x0b\x63onvert_now\x18\x0c \x01(\x08\x12\x11\n\ttranscode\x18\r \x01(\x08\x12\x1b\n\x13if_generation_match\x18\x0e \x01(\x03\"`\n\x07Project\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0e\n\nROUTEVIEWS\x10\x01\x12\x12\n\x0eROUTEVIEWS_RIB\x10\x04\x12\x0c\n\x08RIPE_RIS\x10\x02\x12\r\n\tRPKI_RARC\x10\x03\x12\x07\n\x03PCH\x10\x05\"E\n\tFileChunk\x12\'\n\x08metadata\x18\x01 \x01(\x0b\x32\x15.rv.proto.FileRequest\x12\x0f\n\x07\x63ontent\x18\x02 \x01(\x0c\"Q\n\x12StartUploadRequest\x12\'\n\x08metadata\x18\x01 \x01(\x0b\x32\x15.rv.proto.FileRequest\x12\x12\n\nsession_id\x18\x02 \x01(\t\"I\n\x12UploadChunkRequest\x12\x12\n\nsession_id\x18\x01 \x01(\t\x12\x0e\n\x06offset\x18\x02 \x01(\x03\x12\x0f\n\x07\x63ontent\x18\x03 \x01(\x0c\"<\n\x0cUploadStatus\x12\x12\n\nsession_id\x18\x01 \x01(\t\x12\x18\n\x10\x63ommitted_offset\x18\x02 \x01(\x03\")\n\x13\x46inishUploadRequest\x12\x12\n\nsession_id\x18\x01 \x01(\t\"\xa9\x03\n\x0c\x46ileResponse\x12-\n\x06status\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileResponse.Status\x12\x15\n\rerror_message\x18\x02 \x01(\t\x12&\n\tretention\x18\x03 \x01(\x0b\x32\x13.rv.proto.Retention\x12#\n\x08replicas\x18\x04 \x03(\x0b\x32\x11.rv.proto.Replica\x12(\n\nconversion\x18\x05 \x01(\x0b\x32\x14.rv.proto.Conversion\x12\x12\n\ngeneration\x18\x06 \x01(\x03\x12\x16\n\x0emetageneration\x18\x07 \x01(\x03\x12\x0e\n\x06object\x18\x08 \x01(\t\x12\x0c\n\x04size\x18\t \x01(\x03\x12\x0e\n\x06md5sum\x18\n \x01(\t\x12\x0e\n\x06\x63rc32c\x18\x0b \x01(\t\x12\x0e\n\x06sha256\x18\x0c \x01(\t\x12\x11\n\tduplicate\x18\r \x01(\x08\"O\n\x06Status\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0b\n\x07SUCCESS\x10\x01\x12\x08\n\x04\x46\x41IL\x10\x02\x12\r\n\tUNCHANGED\x10\x03\x12\x12\n\x0eINVALID_FORMAT\x10\x04\"\x9f\x01\n\nConversion\x12+\n\x06status\x18\x01 \x01(\x0e\x32\x1b.rv.proto.Conversion.Status\x12\x15\n\rerror_message\x18\x02 \x01(\t\x12\x0e\n\x06object\x18\x03 \x01(\t\"=\n\x06Status\x12\x0b\n\x07UNKNOWN\x10\x00\x12\r\n\tCONVERTED\x10\x01\x12\n\n\x06\x46\x41ILED\x10\x02\x12\x0b\n\x07SKIPPED\x10\x03\"\xa2\x01\n\x07Replica\x12\x0e\n\x06\x62ucket\x18\x01 \x01(\t\x12(\n\x06status\x18\x02 \x01(\x0e\x32\x18.rv.proto.Replica.Status\x12\x15\n\rerror_message\x18\x03 \x01(\t\x12\x12\n\ngeneration\x18\x04 \x01(\x03\"2\n\x06Status\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x0e\n\nREPLICATED\x10\x01\x12\x0b\n\x07PENDING\x10\x02\"S\n\tRetention\x12\x18\n\x10\x65vent_based_hold\x18\x01 \x01(\x08\x12\x16\n\x0etemporary_hold\x18\x02 \x01(\x08\x12\x14\n\x0cretain_until\x18\x03 \x01(\t\"U\n\x11ObjectStatRequest\x12.\n\x07project\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\x12\x10\n\x08\x66ilename\x18\x02 \x01(\t\"f\n\x12ObjectStatResponse\x12\x0e\n\x06\x65xists\x18\x01 \x01(\x08\x12\x0e\n\x06md5sum\x18\x02 \x01(\t\x12\x0e\n\x06\x63rc32c\x18\x03 \x01(\t\x12\x0c\n\x04size\x18\x04 \x01(\x03\x12\x12\n\ngeneration\x18\x05 \x01(\x03\"\x9e\x01\n\x12ListObjectsRequest\x12.\n\x07project\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\x12\x0e\n\x06prefix\x18\x02 \x01(\t\x12\x11\n\tdelimiter\x18\x03 \x01(\t\x12\x12\n\npage_token\x18\x04 \x01(\t\x12\x11\n\tpage_size\x18\x05 \x01(\x05\x12\x0e\n\x06\x66ields\x18\x06 \x03(\t\"\x88\x01\n\nObjectInfo\x12\x10\n\x08\x66ilename\x18\x01 \x01(\t\x12\x0e\n\x06md5sum\x18\x02 \x01(\t\x12\x0e\n\x06\x63rc32c\x18\x03 \x01(\t\x12\x0c\n\x04size\x18\x04 \x01(\x03\x12\x12\n\ngeneration\x18\x05 \x01(\x03\x12\x0f\n\x07updated\x18\x06 \x01(\t\x12\x15\n\rstorage_class\x18\x07 \x01(\t\"g\n\x13ListObjectsResponse\x12%\n\x07objects\x18\x01 \x03(\x0b\x32\x14.rv.proto.ObjectInfo\x12\x10\n\x08prefixes\x18\x02 \x03(\t\x12\x17\n\x0fnext_page_token\x18\x03 \x01(\t\"{\n\x13\x44\x65leteObjectRequest\x12.\n\x07project\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\x12\x10\n\x08\x66ilename\x18\x02 \x01(\t\x12\x0e\n\x06reason\x18\x03 \x01(\t\x12\x12\n\ngeneration\x18\x04 \x01(\x03\"*\n\x14\x44\x65leteObjectResponse\x12\x12\n\ngeneration\x18\x01 \x01(\x03\"J\n\x11SignUploadRequest\x12\'\n\x08metadata\x18\x01 \x01(\x0b\x32\x15.rv.proto.FileRequest\x12\x0c\n\x04size\x18\x02 \x01(\x03\"i\n\x12SignUploadResponse\x12\x11\n\tupload_id\x18\x01 \x01(\t\x12\x0b\n\x03url\x18\x02 \x01(\t\x12\x0f\n\x07headers\x18\x03 \x03(\t\x12\x0f\n\x07\x65xpires\x18\x04 \x01(\t\x12\x11\n\tunchanged\x18\x05 \x01(\x08\"*\n\x15\x46inalizeUploadRequest\x12\x11\n\tupload_id\x18\x01 \x01(\t\".\n\x14\x43ompatibilityRequest\x12\x16\n\x0e\x63lient_version\x18\x01 \x01(\t\"D\n\nCapability\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\x13\n\x0bmin_version\x18\x02 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x03 \x01(\t\"\x8b\x01\n\x15\x43ompatibilityResponse\x12\x16\n\x0eserver_version\x18\x01 \x01(\t\x12\x1a\n\x12min_client_version\x18\x02 \x01(\t\x12\x12\n\ncompatible\x18\x03 \x01(\x08\x12*\n\x0c\x63\x61pabilities\x18\x04 \x03(\x0b\x32\x14.rv.proto.Capability\"\x14\n\x12ServerStatsRequest\"^\n\x0cProjectStats\x12.\n\x07project\x18\x01 \x01(\x0e\x32\x1d.rv.proto.FileRequest.Project\x12\x0f\n\x07uploads\x18\x02 \x01(\x03\x12\r\n\x05\x62ytes\x18\x03 \x01(\x03\"\x80\x02\n\x13ServerStatsResponse\x12\x16\n\x0eserver_version\x18\x01 \x01(\t\x12\x15\n\rbuild_version\x18\x02 \x01(\t\x12\x12\n\nstart_time\x18\x03 \x01(\t\x12\x16\n\x0euptime_seconds\x18\x04 \x01(\x03\x12\x14\n\x0cperiod_start\x18\x05 \x01(\t\x12(\n\x08projects\x18\x06 \x03(\x0b\x32\x16.rv.proto.ProjectStats\x12\r\n\x05\x62ytes\x18\x07 \x01(\x03\x12\x18\n\x10pending_replicas\x18\x08 \x01(\x03\x12\x13\n\x0b\x63onversions\x18\t \x01(\x03\x12\x10\n\x08shedding\x18\n \x01(\x08\x32\xeb\x06\n\x02RV\x12;\n\nFileUpload\x12\x15.rv.proto.FileRequest\x1a\x16.rv.proto.FileResponse\x12\x41\n\x10\x46ileUploadStream\x12\x13.rv.proto.FileChunk\x1a\x16.rv.proto.FileResponse(\x01\x12\x43\n\x0bStartUpload\x12\x1c.rv.proto.StartUploadRequest\x1a\x16.rv.proto.UploadStatus\x12\x43\n\x0bUploadChunk\x12\x1c.rv.proto.UploadChunkRequest\x1a\x16.rv.proto.UploadStatus\x12\x45\n\x0c\x46inishUpload\x12\x1d.rv.proto.FinishUploadRequest\x1a\x16.rv.proto.FileResponse\x12P\n\rCompatibility\x12\x1e.rv.proto.CompatibilityRequest\x1a\x1f.rv.proto.CompatibilityResponse\x12G\n\nObjectStat\x12\x1b.rv.proto.ObjectStatRequest\x1a\x1c.rv.proto.ObjectStatResponse\x12J\n\x0bListObjects\x12\x1c.rv.proto.ListObjectsRequest\x1a\x1d.rv.proto.ListObjectsResponse\x12M\n\x0c\x44\x65leteObject\x12\x1d.rv.proto.DeleteObjectRequest\x1a\x1e.rv.proto.DeleteObjectResponse\x12G\n\nSignUpload\x12\x1b.rv.proto.SignUploadRequest\x1a\x1c.rv.proto.SignUploadResponse\x12I\n\x0e\x46inalizeUpload\x12\x1f.rv.proto.FinalizeUploadRequest\x1a\x16.rv.proto.FileResponse\x12J\n\x0bServerStats\x12\x1c.rv.proto.ServerStatsRequest\x1a\x1d.rv.proto.ServerStatsResponseB5Z3github.com/routeviews/google-cloud-storage/proto/rvb\x06proto3')
 
 
 
 _FILEREQUEST = DESCRIPTOR.message_types_by_name['FileRequest']
+_FILECHUNK = DESCRIPTOR.message_types_by_name['FileChunk']
+_STARTUPLOADREQUEST = DESCRIPTOR.message_types_by_name['StartUploadRequest']
+_UPLOADCHUNKREQUEST = DESCRIPTOR.message_types_by_name['UploadChunkRequest']
+_UPLOADSTATUS = DESCRIPTOR.message_types_by_name['UploadStatus']
+_FINISHUPLOADREQUEST = DESCRIPTOR.message_types_by_name['FinishUploadRequest']
 _FILERESPONSE = DESCRIPTOR.message_types_by_name['FileResponse']
+_CONVERSION = DESCRIPTOR.message_types_by_name['Conversion']
+_REPLICA = DESCRIPTOR.message_types_by_name['Replica']
+_RETENTION = DESCRIPTOR.message_types_by_name['Retention']
+_OBJECTSTATREQUEST = DESCRIPTOR.message_types_by_name['ObjectStatRequest']
+_OBJECTSTATRESPONSE = DESCRIPTOR.message_types_by_name['ObjectStatResponse']
+_LISTOBJECTSREQUEST = DESCRIPTOR.message_types_by_name['ListObjectsRequest']
+_OBJECTINFO = DESCRIPTOR.message_types_by_name['ObjectInfo']
+_LISTOBJECTSRESPONSE = DESCRIPTOR.message_types_by_name['ListObjectsResponse']
+_DELETEOBJECTREQUEST = DESCRIPTOR.message_types_by_name['DeleteObjectRequest']
+_DELETEOBJECTRESPONSE = DESCRIPTOR.message_types_by_name['DeleteObjectResponse']
+_SIGNUPLOADREQUEST = DESCRIPTOR.message_types_by_name['SignUploadRequest']
+_SIGNUPLOADRESPONSE = DESCRIPTOR.message_types_by_name['SignUploadResponse']
+_FINALIZEUPLOADREQUEST = DESCRIPTOR.message_types_by_name['FinalizeUploadRequest']
+_COMPATIBILITYREQUEST = DESCRIPTOR.message_types_by_name['CompatibilityRequest']
+_CAPABILITY = DESCRIPTOR.message_types_by_name['Capability']
+_COMPATIBILITYRESPONSE = DESCRIPTOR.message_types_by_name['CompatibilityResponse']
+_SERVERSTATSREQUEST = DESCRIPTOR.message_types_by_name['ServerStatsRequest']
+_PROJECTSTATS = DESCRIPTOR.message_types_by_name['ProjectStats']
+_SERVERSTATSRESPONSE = DESCRIPTOR.message_types_by_name['ServerStatsResponse']
 _FILEREQUEST_PROJECT = _FILEREQUEST.enum_types_by_name['Project']
 _FILERESPONSE_STATUS = _FILERESPONSE.enum_types_by_name['Status']
+_CONVERSION_STATUS = _CONVERSION.enum_types_by_name['Status']
+_REPLICA_STATUS = _REPLICA.enum_types_by_name['Status']
 FileRequest = _reflection.GeneratedProtocolMessageType('FileRequest', (_message.Message,), {
   'DESCRIPTOR' : _FILEREQUEST,
   '__module__' : 'rv_pb2'
@@ -29,6 +55,41 @@ FileRequest = _reflection.GeneratedProtocolMessageType('FileRequest', (_message.
   })
 _sym_db.RegisterMessage(FileRequest)
 
+FileChunk = _reflection.GeneratedProtocolMessageType('FileChunk', (_message.Message,), {
+  'DESCRIPTOR' : _FILECHUNK,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.FileChunk)
+  })
+_sym_db.RegisterMessage(FileChunk)
+
+StartUploadRequest = _reflection.GeneratedProtocolMessageType('StartUploadRequest', (_message.Message,), {
+  'DESCRIPTOR' : _STARTUPLOADREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.StartUploadRequest)
+  })
+_sym_db.RegisterMessage(StartUploadRequest)
+
+UploadChunkRequest = _reflection.GeneratedProtocolMessageType('UploadChunkRequest', (_message.Message,), {
+  'DESCRIPTOR' : _UPLOADCHUNKREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.UploadChunkRequest)
+  })
+_sym_db.RegisterMessage(UploadChunkRequest)
+
+UploadStatus = _reflection.GeneratedProtocolMessageType('UploadStatus', (_message.Message,), {
+  'DESCRIPTOR' : _UPLOADSTATUS,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.UploadStatus)
+  })
+_sym_db.RegisterMessage(UploadStatus)
+
+FinishUploadRequest = _reflection.GeneratedProtocolMessageType('FinishUploadRequest', (_message.Message,), {
+  'DESCRIPTOR' : _FINISHUPLOADREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.FinishUploadRequest)
+  })
+_sym_db.RegisterMessage(FinishUploadRequest)
+
 FileResponse = _reflection.GeneratedProtocolMessageType('FileResponse', (_message.Message,), {
   'DESCRIPTOR' : _FILERESPONSE,
   '__module__' : 'rv_pb2'
@@ -36,19 +97,204 @@ FileResponse = _reflection.GeneratedProtocolMessageType('FileResponse', (_messag
   })
 _sym_db.RegisterMessage(FileResponse)
 
+Conversion = _reflection.GeneratedProtocolMessageType('Conversion', (_message.Message,), {
+  'DESCRIPTOR' : _CONVERSION,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.Conversion)
+  })
+_sym_db.RegisterMessage(Conversion)
+
+Replica = _reflection.GeneratedProtocolMessageType('Replica', (_message.Message,), {
+  'DESCRIPTOR' : _REPLICA,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.Replica)
+  })
+_sym_db.RegisterMessage(Replica)
+
+Retention = _reflection.GeneratedProtocolMessageType('Retention', (_message.Message,), {
+  'DESCRIPTOR' : _RETENTION,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.Retention)
+  })
+_sym_db.RegisterMessage(Retention)
+
+ObjectStatRequest = _reflection.GeneratedProtocolMessageType('ObjectStatRequest', (_message.Message,), {
+  'DESCRIPTOR' : _OBJECTSTATREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ObjectStatRequest)
+  })
+_sym_db.RegisterMessage(ObjectStatRequest)
+
+ObjectStatResponse = _reflection.GeneratedProtocolMessageType('ObjectStatResponse', (_message.Message,), {
+  'DESCRIPTOR' : _OBJECTSTATRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ObjectStatResponse)
+  })
+_sym_db.RegisterMessage(ObjectStatResponse)
+
+ListObjectsRequest = _reflection.GeneratedProtocolMessageType('ListObjectsRequest', (_message.Message,), {
+  'DESCRIPTOR' : _LISTOBJECTSREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ListObjectsRequest)
+  })
+_sym_db.RegisterMessage(ListObjectsRequest)
+
+ObjectInfo = _reflection.GeneratedProtocolMessageType('ObjectInfo', (_message.Message,), {
+  'DESCRIPTOR' : _OBJECTINFO,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ObjectInfo)
+  })
+_sym_db.RegisterMessage(ObjectInfo)
+
+ListObjectsResponse = _reflection.GeneratedProtocolMessageType('ListObjectsResponse', (_message.Message,), {
+  'DESCRIPTOR' : _LISTOBJECTSRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ListObjectsResponse)
+  })
+_sym_db.RegisterMessage(ListObjectsResponse)
+
+DeleteObjectRequest = _reflection.GeneratedProtocolMessageType('DeleteObjectRequest', (_message.Message,), {
+  'DESCRIPTOR' : _DELETEOBJECTREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.DeleteObjectRequest)
+  })
+_sym_db.RegisterMessage(DeleteObjectRequest)
+
+DeleteObjectResponse = _reflection.GeneratedProtocolMessageType('DeleteObjectResponse', (_message.Message,), {
+  'DESCRIPTOR' : _DELETEOBJECTRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.DeleteObjectResponse)
+  })
+_sym_db.RegisterMessage(DeleteObjectResponse)
+
+SignUploadRequest = _reflection.GeneratedProtocolMessageType('SignUploadRequest', (_message.Message,), {
+  'DESCRIPTOR' : _SIGNUPLOADREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.SignUploadRequest)
+  })
+_sym_db.RegisterMessage(SignUploadRequest)
+
+SignUploadResponse = _reflection.GeneratedProtocolMessageType('SignUploadResponse', (_message.Message,), {
+  'DESCRIPTOR' : _SIGNUPLOADRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.SignUploadResponse)
+  })
+_sym_db.RegisterMessage(SignUploadResponse)
+
+FinalizeUploadRequest = _reflection.GeneratedProtocolMessageType('FinalizeUploadRequest', (_message.Message,), {
+  'DESCRIPTOR' : _FINALIZEUPLOADREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.FinalizeUploadRequest)
+  })
+_sym_db.RegisterMessage(FinalizeUploadRequest)
+
+CompatibilityRequest = _reflection.GeneratedProtocolMessageType('CompatibilityRequest', (_message.Message,), {
+  'DESCRIPTOR' : _COMPATIBILITYREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.CompatibilityRequest)
+  })
+_sym_db.RegisterMessage(CompatibilityRequest)
+
+Capability = _reflection.GeneratedProtocolMessageType('Capability', (_message.Message,), {
+  'DESCRIPTOR' : _CAPABILITY,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.Capability)
+  })
+_sym_db.RegisterMessage(Capability)
+
+CompatibilityResponse = _reflection.GeneratedProtocolMessageType('CompatibilityResponse', (_message.Message,), {
+  'DESCRIPTOR' : _COMPATIBILITYRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.CompatibilityResponse)
+  })
+_sym_db.RegisterMessage(CompatibilityResponse)
+
+ServerStatsRequest = _reflection.GeneratedProtocolMessageType('ServerStatsRequest', (_message.Message,), {
+  'DESCRIPTOR' : _SERVERSTATSREQUEST,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ServerStatsRequest)
+  })
+_sym_db.RegisterMessage(ServerStatsRequest)
+
+ProjectStats = _reflection.GeneratedProtocolMessageType('ProjectStats', (_message.Message,), {
+  'DESCRIPTOR' : _PROJECTSTATS,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ProjectStats)
+  })
+_sym_db.RegisterMessage(ProjectStats)
+
+ServerStatsResponse = _reflection.GeneratedProtocolMessageType('ServerStatsResponse', (_message.Message,), {
+  'DESCRIPTOR' : _SERVERSTATSRESPONSE,
+  '__module__' : 'rv_pb2'
+  # @@protoc_insertion_point(class_scope:rv.proto.ServerStatsResponse)
+  })
+_sym_db.RegisterMessage(ServerStatsResponse)
+
 _RV = DESCRIPTOR.services_by_name['RV']
 if _descriptor._USE_C_DESCRIPTORS == False:
 
   DESCRIPTOR._options = None
   DESCRIPTOR._serialized_options = b'Z3github.com/routeviews/google-cloud-storage/proto/rv'
   _FILEREQUEST._serialized_start=23
-  _FILEREQUEST._serialized_end=245
-  _FILEREQUEST_PROJECT._serialized_start=158
-  _FILEREQUEST_PROJECT._serialized_end=245
-  _FILERESPONSE._serialized_start=248
-  _FILERESPONSE._serialized_end=378
-  _FILERESPONSE_STATUS._serialized_start=334
-  _FILERESPONSE_STATUS._serialized_end=378
-  _RV._serialized_start=380
-  _RV._serialized_end=445
+  _FILEREQUEST._serialized_end=446
+  _FILEREQUEST_PROJECT._serialized_start=350
+  _FILEREQUEST_PROJECT._serialized_end=446
+  _FILECHUNK._serialized_start=448
+  _FILECHUNK._serialized_end=517
+  _STARTUPLOADREQUEST._serialized_start=519
+  _STARTUPLOADREQUEST._serialized_end=600
+  _UPLOADCHUNKREQUEST._serialized_start=602
+  _UPLOADCHUNKREQUEST._serialized_end=675
+  _UPLOADSTATUS._serialized_start=677
+  _UPLOADSTATUS._serialized_end=737
+  _FINISHUPLOADREQUEST._serialized_start=739
+  _FINISHUPLOADREQUEST._serialized_end=780
+  _FILERESPONSE._serialized_start=783
+  _FILERESPONSE._serialized_end=1208
+  _FILERESPONSE_STATUS._serialized_start=1129
+  _FILERESPONSE_STATUS._serialized_end=1208
+  _CONVERSION._serialized_start=1211
+  _CONVERSION._serialized_end=1370
+  _CONVERSION_STATUS._serialized_start=1309
+  _CONVERSION_STATUS._serialized_end=1370
+  _REPLICA._serialized_start=1373
+  _REPLICA._serialized_end=1535
+  _REPLICA_STATUS._serialized_start=1485
+  _REPLICA_STATUS._serialized_end=1535
+  _RETENTION._serialized_start=1537
+  _RETENTION._serialized_end=1620
+  _OBJECTSTATREQUEST._serialized_start=1622
+  _OBJECTSTATREQUEST._serialized_end=1707
+  _OBJECTSTATRESPONSE._serialized_start=1709
+  _OBJECTSTATRESPONSE._serialized_end=1811
+  _LISTOBJECTSREQUEST._serialized_start=1814
+  _LISTOBJECTSREQUEST._serialized_end=1972
+  _OBJECTINFO._serialized_start=1975
+  _OBJECTINFO._serialized_end=2111
+  _LISTOBJECTSRESPONSE._serialized_start=2113
+  _LISTOBJECTSRESPONSE._serialized_end=2216
+  _DELETEOBJECTREQUEST._serialized_start=2218
+  _DELETEOBJECTREQUEST._serialized_end=2341
+  _DELETEOBJECTRESPONSE._serialized_start=2343
+  _DELETEOBJECTRESPONSE._serialized_end=2385
+  _SIGNUPLOADREQUEST._serialized_start=2387
+  _SIGNUPLOADREQUEST._serialized_end=2461
+  _SIGNUPLOADRESPONSE._serialized_start=2463
+  _SIGNUPLOADRESPONSE._serialized_end=2568
+  _FINALIZEUPLOADREQUEST._serialized_start=2570
+  _FINALIZEUPLOADREQUEST._serialized_end=2612
+  _COMPATIBILITYREQUEST._serialized_start=2614
+  _COMPATIBILITYREQUEST._serialized_end=2660
+  _CAPABILITY._serialized_start=2662
+  _CAPABILITY._serialized_end=2730
+  _COMPATIBILITYRESPONSE._serialized_start=2733
+  _COMPATIBILITYRESPONSE._serialized_end=2872
+  _SERVERSTATSREQUEST._serialized_start=2874
+  _SERVERSTATSREQUEST._serialized_end=2894
+  _PROJECTSTATS._serialized_start=2896
+  _PROJECTSTATS._serialized_end=2990
+  _SERVERSTATSRESPONSE._serialized_start=2993
+  _SERVERSTATSRESPONSE._serialized_end=3249
+  _RV._serialized_start=3252
+  _RV._serialized_end=4127
 # @@protoc_insertion_point(module_scope)
@@ -20,6 +20,61 @@ class RVStub(object):
                 request_serializer=rv__pb2.FileRequest.SerializeToString,
                 response_deserializer=rv__pb2.FileResponse.FromString,
                 )
+        self.FileUploadStream = channel.stream_unary(
+                '/rv.proto.RV/FileUploadStream',
+                request_serializer=rv__pb2.FileChunk.SerializeToString,
+                response_deserializer=rv__pb2.FileResponse.FromString,
+                )
+        self.StartUpload = channel.unary_unary(
+                '/rv.proto.RV/StartUpload',
+                request_serializer=rv__pb2.StartUploadRequest.SerializeToString,
+                response_deserializer=rv__pb2.UploadStatus.FromString,
+                )
+        self.UploadChunk = channel.unary_unary(
+                '/rv.proto.RV/UploadChunk',
+                request_serializer=rv__pb2.UploadChunkRequest.SerializeToString,
+                response_deserializer=rv__pb2.UploadStatus.FromString,
+                )
+        self.FinishUpload = channel.unary_unary(
+                '/rv.proto.RV/FinishUpload',
+                request_serializer=rv__pb2.FinishUploadRequest.SerializeToString,
+                response_deserializer=rv__pb2.FileResponse.FromString,
+                )
+        self.Compatibility = channel.unary_unary(
+                '/rv.proto.RV/Compatibility',
+                request_serializer=rv__pb2.CompatibilityRequest.SerializeToString,
+                response_deserializer=rv__pb2.CompatibilityResponse.FromString,
+                )
+        self.ObjectStat = channel.unary_unary(
+                '/rv.proto.RV/ObjectStat',
+                request_serializer=rv__pb2.ObjectStatRequest.SerializeToString,
+                response_deserializer=rv__pb2.ObjectStatResponse.FromString,
+                )
+        self.ListObjects = channel.unary_unary(
+                '/rv.proto.RV/ListObjects',
+                request_serializer=rv__pb2.ListObjectsRequest.SerializeToString,
+                response_deserializer=rv__pb2.ListObjectsResponse.FromString,
+                )
+        self.DeleteObject = channel.unary_unary(
+                '/rv.proto.RV/DeleteObject',
+                request_serializer=rv__pb2.DeleteObjectRequest.SerializeToString,
+                response_deserializer=rv__pb2.DeleteObjectResponse.FromString,
+                )
+        self.SignUpload = channel.unary_unary(
+                '/rv.proto.RV/SignUpload',
+                request_serializer=rv__pb2.SignUploadRequest.SerializeToString,
+                response_deserializer=rv__pb2.SignUploadResponse.FromString,
+                )
+        self.FinalizeUpload = channel.unary_unary(
+                '/rv.proto.RV/FinalizeUpload',
+                request_serializer=rv__pb2.FinalizeUploadRequest.SerializeToString,
+                response_deserializer=rv__pb2.FileResponse.FromString,
+                )
+        self.ServerStats = channel.unary_unary(
+                '/rv.proto.RV/ServerStats',
+                request_serializer=rv__pb2.ServerStatsRequest.SerializeToString,
+                response_deserializer=rv__pb2.ServerStatsResponse.FromString,
+                )
 
 
 class RVServicer(object):
@@ -34,6 +89,102 @@ class RVServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def FileUploadStream(self, request_iterator, context):
+        """FileUploadStream accepts a file too large for a single FileUpload
+        request: the first message carries the file metadata, the following
+        messages carry the content in order. It returns a status message once the
+        stream is closed and the file is stored.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def StartUpload(self, request, context):
+        """StartUpload starts a resumable upload session of a file, or resumes one.
+        It returns the session and its committed offset, the offset of the next
+        chunk to upload.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def UploadChunk(self, request, context):
+        """UploadChunk stores a chunk of the file content at the committed offset of
+        the session, and returns the new committed offset.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def FinishUpload(self, request, context):
+        """FinishUpload verifies the content of the session against the md5sum, and
+        stores the file.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Compatibility(self, request, context):
+        """Compatibility returns the protocol version of the server, the minimum
+        client protocol version it accepts, and the capabilities of the protocol
+        with the version which introduced each.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ObjectStat(self, request, context):
+        """ObjectStat returns whether the object of a file exists, and its
+        checksums, size and generation, so a client may skip the upload of a file
+        stored already without credentials to cloud-storage.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ListObjects(self, request, context):
+        """ListObjects returns a page of the objects of a project under a prefix,
+        so mirror clients and audit tools may enumerate what is archived.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def DeleteObject(self, request, context):
+        """DeleteObject deletes the object of a corrupt or misnamed file. Only the
+        admins of the project may delete its objects, the reason of each delete
+        is recorded in the audit log.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def SignUpload(self, request, context):
+        """SignUpload returns a time-limited signed URL to PUT the content of a file
+        to cloud-storage directly, for files too large to send through the
+        server. The upload is staged until FinalizeUpload.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def FinalizeUpload(self, request, context):
+        """FinalizeUpload verifies the content of a signed upload against its
+        checksums, and stores the file.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def ServerStats(self, request, context):
+        """ServerStats returns the uptime and build of the server instance, the
+        uploads and bytes it stored per project in the current period, and its
+        backlogs, for monitoring agents and dashboards.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_RVServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -42,6 +193,61 @@ def add_RVServicer_to_server(servicer, server):
                     request_deserializer=rv__pb2.FileRequest.FromString,
                     response_serializer=rv__pb2.FileResponse.SerializeToString,
             ),
+            'FileUploadStream': grpc.stream_unary_rpc_method_handler(
+                    servicer.FileUploadStream,
+                    request_deserializer=rv__pb2.FileChunk.FromString,
+                    response_serializer=rv__pb2.FileResponse.SerializeToString,
+            ),
+            'StartUpload': grpc.unary_unary_rpc_method_handler(
+                    servicer.StartUpload,
+                    request_deserializer=rv__pb2.StartUploadRequest.FromString,
+                    response_serializer=rv__pb2.UploadStatus.SerializeToString,
+            ),
+            'UploadChunk': grpc.unary_unary_rpc_method_handler(
+                    servicer.UploadChunk,
+                    request_deserializer=rv__pb2.UploadChunkRequest.FromString,
+                    response_serializer=rv__pb2.UploadStatus.SerializeToString,
+            ),
+            'FinishUpload': grpc.unary_unary_rpc_method_handler(
+                    servicer.FinishUpload,
+                    request_deserializer=rv__pb2.FinishUploadRequest.FromString,
+                    response_serializer=rv__pb2.FileResponse.SerializeToString,
+            ),
+            'Compatibility': grpc.unary_unary_rpc_method_handler(
+                    servicer.Compatibility,
+                    request_deserializer=rv__pb2.CompatibilityRequest.FromString,
+                    response_serializer=rv__pb2.CompatibilityResponse.SerializeToString,
+            ),
+            'ObjectStat': grpc.unary_unary_rpc_method_handler(
+                    servicer.ObjectStat,
+                    request_deserializer=rv__pb2.ObjectStatRequest.FromString,
+                    response_serializer=rv__pb2.ObjectStatResponse.SerializeToString,
+            ),
+            'ListObjects': grpc.unary_unary_rpc_method_handler(
+                    servicer.ListObjects,
+                    request_deserializer=rv__pb2.ListObjectsRequest.FromString,
+                    response_serializer=rv__pb2.ListObjectsResponse.SerializeToString,
+            ),
+            'DeleteObject': grpc.unary_unary_rpc_method_handler(
+                    servicer.DeleteObject,
+                    request_deserializer=rv__pb2.DeleteObjectRequest.FromString,
+                    response_serializer=rv__pb2.DeleteObjectResponse.SerializeToString,
+            ),
+            'SignUpload': grpc.unary_unary_rpc_method_handler(
+                    servicer.SignUpload,
+                    request_deserializer=rv__pb2.SignUploadRequest.FromString,
+                    response_serializer=rv__pb2.SignUploadResponse.SerializeToString,
+            ),
+            'FinalizeUpload': grpc.unary_unary_rpc_method_handler(
+                    servicer.FinalizeUpload,
+                    request_deserializer=rv__pb2.FinalizeUploadRequest.FromString,
+                    response_serializer=rv__pb2.FileResponse.SerializeToString,
+            ),
+            'ServerStats': grpc.unary_unary_rpc_method_handler(
+                    servicer.ServerStats,
+                    request_deserializer=rv__pb2.ServerStatsRequest.FromString,
+                    response_serializer=rv__pb2.ServerStatsResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'rv.proto.RV', rpc_method_handlers)
@@ -69,3 +275,190 @@ class RV(object):
             rv__pb2.FileResponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def FileUploadStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_unary(request_iterator, target, '/rv.proto.RV/FileUploadStream',
+            rv__pb2.FileChunk.SerializeToString,
+            rv__pb2.FileResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def StartUpload(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/StartUpload',
+            rv__pb2.StartUploadRequest.SerializeToString,
+            rv__pb2.UploadStatus.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def UploadChunk(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/UploadChunk',
+            rv__pb2.UploadChunkRequest.SerializeToString,
+            rv__pb2.UploadStatus.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def FinishUpload(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/FinishUpload',
+            rv__pb2.FinishUploadRequest.SerializeToString,
+            rv__pb2.FileResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Compatibility(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/Compatibility',
+            rv__pb2.CompatibilityRequest.SerializeToString,
+            rv__pb2.CompatibilityResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ObjectStat(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/ObjectStat',
+            rv__pb2.ObjectStatRequest.SerializeToString,
+            rv__pb2.ObjectStatResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ListObjects(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/ListObjects',
+            rv__pb2.ListObjectsRequest.SerializeToString,
+            rv__pb2.ListObjectsResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def DeleteObject(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/DeleteObject',
+            rv__pb2.DeleteObjectRequest.SerializeToString,
+            rv__pb2.DeleteObjectResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def SignUpload(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/SignUpload',
+            rv__pb2.SignUploadRequest.SerializeToString,
+            rv__pb2.SignUploadResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def FinalizeUpload(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/FinalizeUpload',
+            rv__pb2.FinalizeUploadRequest.SerializeToString,
+            rv__pb2.FileResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def ServerStats(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/rv.proto.RV/ServerStats',
+            rv__pb2.ServerStatsRequest.SerializeToString,
+            rv__pb2.ServerStatsResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
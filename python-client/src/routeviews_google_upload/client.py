@@ -21,6 +21,14 @@ grpc_max_size_options = [
     ('grpc.max_receive_message_length', MAX_MESSAGE_SIZE),
 ]
 
+# The upload protocol version and tool sent with every request, the server
+# may reject clients older than its minimum protocol version.
+PROTOCOL_VERSION = '1.1.0'
+VERSION_METADATA = [
+    ('rv-protocol-version', PROTOCOL_VERSION),
+    ('rv-client', 'routeviews-google-upload/0.2.0'),
+]
+
 
 def read_bytes(file_path):
     with open(file_path, "rb") as f:
@@ -92,7 +100,7 @@ class Client:
         """
         payload = generate_FileRequest(file_path, to_sql, filename)
         grpc_client = rv_pb2_grpc.RVStub(self.channel)
-        response = grpc_client.FileUpload(payload)
+        response = grpc_client.FileUpload(payload, metadata=VERSION_METADATA)
         logger.info("Upload Status: " + str(response.status))
         if response.error_message:
             logger.error(f'Error uploading {file_path} --- Error message: {response.error_message}')